@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/sparse"
+)
+
+// histogramBounds are the upper bounds in bytes of the chunk size histogram
+// buckets; a last bucket counts larger chunks
+var histogramBounds = []int64{256, 512, 1 << 10, 2 << 10, 4 << 10, 8 << 10, 16 << 10, 32 << 10, 64 << 10, 128 << 10, 256 << 10, 512 << 10, 1 << 20}
+
+// Bucket counts the chunks of at most UpTo bytes that no smaller bucket
+// counts; UpTo is 0 for the last bucket
+type Bucket struct {
+	UpTo  int64 `json:"up_to"`
+	Count int64 `json:"count"`
+}
+
+// Result is what one chunking configuration made of the analysed data
+type Result struct {
+	Strategy  string `json:"strategy"`
+	Algorithm string `json:"algorithm"`
+	MinSize   int    `json:"min_size"`
+	AvgSize   int    `json:"avg_size"`
+	MaxSize   int    `json:"max_size"`
+
+	Files        int   `json:"files"`
+	Bytes        int64 `json:"bytes"`
+	HoleBytes    int64 `json:"hole_bytes"` // in chunks of zeros, stored as holes
+	Chunks       int64 `json:"chunks"`
+	UniqueChunks int64 `json:"unique_chunks"`
+	UniqueBytes  int64 `json:"unique_bytes"`
+
+	MeanChunkSize float64  `json:"mean_chunk_size"`
+	DedupeRatio   float64  `json:"dedupe_ratio"` // data bytes per unique byte
+	IndexBytes    int64    `json:"index_bytes"`  // estimated size of the chunk index
+	Seconds       float64  `json:"seconds"`      // spent splitting and hashing
+	Throughput    float64  `json:"throughput_mb_per_sec"`
+	Histogram     []Bucket `json:"histogram"`
+}
+
+// config is the state of one chunking configuration during an analysis
+type config struct {
+	chunker *chunking.Chunker
+	result  Result
+	unique  map[chunking.Fingerprint]struct{}
+	elapsed time.Duration
+}
+
+// analyzer runs several chunking configurations over the same data. Data is
+// chunked in blocks of bufferSize bytes, as the ingest node chunks the data
+// it buffers of a file, and chunks made only of zeros count as holes.
+type analyzer struct {
+	configs         []*config
+	bufferSize      int
+	indexEntryBytes int
+	buffer          []byte
+}
+
+// newAnalyzer creates an analyzer of the chunking profiles, estimating the
+// index to take indexEntryBytes per unique chunk
+func newAnalyzer(profiles []chunking.Profile, bufferSize, indexEntryBytes int) (*analyzer, error) {
+	a := &analyzer{bufferSize: bufferSize, indexEntryBytes: indexEntryBytes}
+	for _, profile := range profiles {
+		chunker, err := chunking.NewProfileChunker(profile)
+		if err != nil {
+			return nil, err
+		}
+		a.configs = append(a.configs, &config{
+			chunker: chunker,
+			result: Result{
+				Strategy:  profile.Strategy(),
+				Algorithm: profile.Algorithm,
+				MinSize:   profile.MinSize,
+				AvgSize:   profile.AvgSize,
+				MaxSize:   profile.MaxSize,
+				Histogram: make([]Bucket, len(histogramBounds)+1),
+			},
+			unique: make(map[chunking.Fingerprint]struct{}),
+		})
+	}
+	return a, nil
+}
+
+// AddFile chunks the contents of a file with every configuration
+func (a *analyzer) AddFile(r io.Reader) error {
+	if a.buffer == nil {
+		a.buffer = make([]byte, a.bufferSize)
+	}
+	for _, c := range a.configs {
+		c.result.Files++
+	}
+	for {
+		n, err := io.ReadFull(r, a.buffer)
+		if n > 0 {
+			for _, c := range a.configs {
+				c.add(a.buffer[:n])
+			}
+		}
+		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// add chunks one block of data
+func (c *config) add(data []byte) {
+	began := time.Now()
+	chunks := c.chunker.Split(data)
+	algorithm := c.chunker.HashAlgorithm()
+	holes := make([]bool, len(chunks))
+	for i := range chunks {
+		if sparse.IsZero(chunks[i].Data) {
+			holes[i] = true
+		} else {
+			chunks[i].Fingerprint = algorithm.Sum(chunks[i].Data)
+		}
+	}
+	c.elapsed += time.Since(began)
+
+	r := &c.result
+	r.Bytes += int64(len(data))
+	for i, chunk := range chunks {
+		if holes[i] {
+			r.HoleBytes += chunk.Size
+			continue
+		}
+		r.Chunks++
+		r.Histogram[bucketOf(chunk.Size)].Count++
+		if _, ok := c.unique[chunk.Fingerprint]; !ok {
+			c.unique[chunk.Fingerprint] = struct{}{}
+			r.UniqueChunks++
+			r.UniqueBytes += chunk.Size
+		}
+	}
+}
+
+// bucketOf returns the histogram bucket of a chunk size
+func bucketOf(size int64) int {
+	for i, bound := range histogramBounds {
+		if size <= bound {
+			return i
+		}
+	}
+	return len(histogramBounds)
+}
+
+// Results returns the results of every configuration, in the order of the
+// profiles
+func (a *analyzer) Results() []Result {
+	results := make([]Result, len(a.configs))
+	for i, c := range a.configs {
+		r := c.result
+		for b := range r.Histogram[:len(histogramBounds)] {
+			r.Histogram[b].UpTo = histogramBounds[b]
+		}
+		if r.Chunks > 0 {
+			r.MeanChunkSize = float64(r.Bytes-r.HoleBytes) / float64(r.Chunks)
+		}
+		if r.UniqueBytes > 0 {
+			r.DedupeRatio = float64(r.Bytes-r.HoleBytes) / float64(r.UniqueBytes)
+		}
+		r.IndexBytes = r.UniqueChunks * int64(a.indexEntryBytes)
+		r.Seconds = c.elapsed.Seconds()
+		if r.Seconds > 0 {
+			r.Throughput = float64(r.Bytes) / r.Seconds / (1 << 20)
+		}
+		results[i] = r
+	}
+	return results
+}
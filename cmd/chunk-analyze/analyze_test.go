@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+)
+
+func TestAnalyzer(t *testing.T) {
+	profiles, err := analysisProfiles(nil, "rabin,fixed", "1024,4096", chunking.Blake3)
+	if err != nil {
+		t.Fatalf("analysisProfiles failed: %v", err)
+	}
+	if len(profiles) != 4 || profiles[0].Strategy() != "rabin:256:1024:4096" || profiles[3].Strategy() != "fixed:4096" {
+		t.Fatalf("Unexpected profiles %v", profiles)
+	}
+	a, err := newAnalyzer(profiles, 16*1024, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same data twice, and a file of zeros
+	data := make([]byte, 40000)
+	rand.New(rand.NewSource(47)).Read(data)
+	for _, file := range [][]byte{data, data, make([]byte, 8192)} {
+		if err := a.AddFile(bytes.NewReader(file)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results := a.Results()
+	for _, r := range results {
+		if r.Files != 3 || r.Bytes != 2*40000+8192 || r.HoleBytes != 8192 {
+			t.Errorf("%s: unexpected totals %+v", r.Strategy, r)
+		}
+		if r.DedupeRatio != 2 || r.UniqueChunks*2 != r.Chunks || r.IndexBytes != 100*r.UniqueChunks {
+			t.Errorf("%s: expected every chunk twice, got %+v", r.Strategy, r)
+		}
+		var counted int64
+		for _, bucket := range r.Histogram {
+			counted += bucket.Count
+		}
+		if counted != r.Chunks {
+			t.Errorf("%s: histogram counts %d of %d chunks", r.Strategy, counted, r.Chunks)
+		}
+	}
+
+	// Rabin chunks follow the average they are labelled with
+	if small, large := results[0], results[1]; small.MeanChunkSize >= large.MeanChunkSize {
+		t.Errorf("Expected %s to make smaller chunks than %s, got %.0f and %.0f", small.Strategy, large.Strategy, small.MeanChunkSize, large.MeanChunkSize)
+	}
+
+	// Fixed blocks restart at each buffer: two 16 KiB buffers of four blocks,
+	// then 7232 bytes in two blocks per file
+	fixed := results[3]
+	if fixed.Chunks != 2*10 || fixed.Histogram[bucketOf(4096)].Count != 2*10 {
+		t.Errorf("Unexpected fixed:4096 chunks %+v", fixed)
+	}
+
+	var decoded []Result
+	var out bytes.Buffer
+	if err := writeResults(&out, "json", results); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || len(decoded) != 4 || decoded[3].UniqueChunks != fixed.UniqueChunks {
+		t.Errorf("Unexpected JSON output (%v): %s", err, out.String())
+	}
+
+	out.Reset()
+	if err := writeResults(&out, "csv", results); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil || len(rows) != 5 || len(rows[0]) != len(rows[1]) || rows[4][0] != "fixed:4096" {
+		t.Errorf("Unexpected CSV output (%v): %v", err, rows)
+	}
+
+	out.Reset()
+	if err := writeResults(&out, "text", results); err != nil || !strings.Contains(out.String(), "fixed:1024 chunk sizes:") {
+		t.Errorf("Unexpected text output (%v): %s", err, out.String())
+	}
+}
+
+func TestAnalysisProfilesInvalid(t *testing.T) {
+	for _, c := range []struct {
+		strategies        []string
+		algorithms, sizes string
+	}{
+		{[]string{"fixed"}, "", ""},
+		{nil, "gear", "4096"},
+		{nil, "rabin", "many"},
+		{nil, "rabin", "2"},
+	} {
+		if _, err := analysisProfiles(c.strategies, c.algorithms, c.sizes, chunking.Blake3); err == nil {
+			t.Errorf("Expected %v/%q/%q to be rejected", c.strategies, c.algorithms, c.sizes)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/fswalk"
+)
+
+// strategyList is a repeatable flag collecting chunking strategies
+type strategyList []string
+
+func (s *strategyList) String() string { return strings.Join(*s, ",") }
+
+func (s *strategyList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func main() {
+	var strategies strategyList
+	flag.Var(&strategies, "strategy", "Chunking strategy to analyse, fixed:<size> or rabin[:<min>:<avg>:<max>] (repeatable); overrides -algorithms and -avg-sizes")
+	algorithms := flag.String("algorithms", "rabin,fixed", "Comma-separated chunking algorithms to analyse at each of -avg-sizes")
+	avgSizes := flag.String("avg-sizes", "4096,8192,16384", "Comma-separated average chunk sizes; rabin chunks range from a quarter to four times the average")
+	filesFrom := flag.String("files-from", "", "Read files and directories to analyse from this file, one per line, or - for standard input")
+	hash := flag.String("hash", "blake3", "Fingerprint hash: blake3, sha256 or blake3-512")
+	bufferSize := flag.Int("buffer", 64*1024*1024, "Bytes of a file chunked at once, like the ingest node's CHECKPOINT_BYTES")
+	indexEntryBytes := flag.Int("index-entry-bytes", 256, "Estimated bytes the chunk index takes per unique chunk")
+	format := flag.String("format", "text", "Output format: text, json or csv")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <file or directory>...\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Runs chunking configurations over real data and reports chunk sizes, deduplication,\nindex size and throughput for each.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	algorithm, err := chunking.ParseHashAlgorithm(*hash)
+	if err != nil {
+		log.Fatalf("Invalid -hash: %v", err)
+	}
+	profiles, err := analysisProfiles(strategies, *algorithms, *avgSizes, algorithm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *bufferSize < 1 || *indexEntryBytes < 0 {
+		log.Fatal("-buffer must be positive and -index-entry-bytes not negative")
+	}
+	roots := flag.Args()
+	if *filesFrom != "" {
+		listed, err := readFileList(*filesFrom)
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", *filesFrom, err)
+		}
+		roots = append(roots, listed...)
+	}
+	if len(roots) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	a, err := newAnalyzer(profiles, *bufferSize, *indexEntryBytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	walker, err := fswalk.NewWalker(fswalk.Options{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	report := &fswalk.Report{}
+	err = walker.Walk(roots, report, func(file fswalk.File) error {
+		f, err := os.Open(file.Path)
+		if err != nil {
+			report.Fail(file.Path, err)
+			return nil
+		}
+		defer f.Close()
+		if err := a.AddFile(f); err != nil {
+			report.Fail(file.Path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, failed := range report.Errors {
+		log.Printf("Warning: Skipped %s", failed)
+	}
+
+	if err := writeResults(os.Stdout, *format, a.Results()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// analysisProfiles returns the profiles of the strategies, or else of every
+// algorithm at every average size
+func analysisProfiles(strategies []string, algorithms, avgSizes string, hash chunking.HashAlgorithm) ([]chunking.Profile, error) {
+	var profiles []chunking.Profile
+	for _, strategy := range strategies {
+		profile, err := chunking.ParseStrategy(strategy, hash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -strategy: %w", err)
+		}
+		profiles = append(profiles, profile)
+	}
+	if len(profiles) > 0 {
+		return profiles, nil
+	}
+
+	for _, name := range strings.Split(algorithms, ",") {
+		for _, text := range strings.Split(avgSizes, ",") {
+			avg, err := strconv.Atoi(strings.TrimSpace(text))
+			if err != nil {
+				return nil, fmt.Errorf("invalid average size %q", text)
+			}
+			var profile chunking.Profile
+			switch strings.TrimSpace(name) {
+			case chunking.AlgorithmRabin:
+				profile = chunking.RabinProfile(avg/4, avg, avg*4, hash)
+			case chunking.AlgorithmFixed:
+				profile = chunking.FixedProfile(avg, hash)
+			default:
+				return nil, fmt.Errorf("unknown chunking algorithm %q, expected %s or %s", name, chunking.AlgorithmRabin, chunking.AlgorithmFixed)
+			}
+			if err := profile.Validate(); err != nil {
+				return nil, fmt.Errorf("%s at average size %d: %w", name, avg, err)
+			}
+			profiles = append(profiles, profile)
+		}
+	}
+	return profiles, nil
+}
+
+// readFileList reads paths one per line, skipping blank lines
+func readFileList(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var paths []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			paths = append(paths, line)
+		}
+	}
+	return paths, scanner.Err()
+}
+
+// writeResults writes the results in a format
+func writeResults(w io.Writer, format string, results []Result) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	case "csv":
+		return writeCSV(w, results)
+	case "text":
+		return writeText(w, results)
+	default:
+		return fmt.Errorf("unknown format %q, expected text, json or csv", format)
+	}
+}
+
+// writeCSV writes one row per configuration, with a column per histogram
+// bucket
+func writeCSV(w io.Writer, results []Result) error {
+	out := csv.NewWriter(w)
+	header := []string{"strategy", "algorithm", "min_size", "avg_size", "max_size", "files", "bytes", "hole_bytes",
+		"chunks", "unique_chunks", "unique_bytes", "mean_chunk_size", "dedupe_ratio", "index_bytes", "seconds", "throughput_mb_per_sec"}
+	for _, bound := range histogramBounds {
+		header = append(header, fmt.Sprintf("chunks_le_%d", bound))
+	}
+	header = append(header, fmt.Sprintf("chunks_gt_%d", histogramBounds[len(histogramBounds)-1]))
+	out.Write(header)
+
+	for _, r := range results {
+		row := []string{r.Strategy, r.Algorithm, strconv.Itoa(r.MinSize), strconv.Itoa(r.AvgSize), strconv.Itoa(r.MaxSize),
+			strconv.Itoa(r.Files), strconv.FormatInt(r.Bytes, 10), strconv.FormatInt(r.HoleBytes, 10),
+			strconv.FormatInt(r.Chunks, 10), strconv.FormatInt(r.UniqueChunks, 10), strconv.FormatInt(r.UniqueBytes, 10),
+			strconv.FormatFloat(r.MeanChunkSize, 'f', 1, 64), strconv.FormatFloat(r.DedupeRatio, 'f', 4, 64),
+			strconv.FormatInt(r.IndexBytes, 10), strconv.FormatFloat(r.Seconds, 'f', 3, 64), strconv.FormatFloat(r.Throughput, 'f', 1, 64)}
+		for _, bucket := range r.Histogram {
+			row = append(row, strconv.FormatInt(bucket.Count, 10))
+		}
+		out.Write(row)
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// writeText writes a summary table followed by each configuration's
+// histogram
+func writeText(w io.Writer, results []Result) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "strategy\tbytes\tchunks\tunique\tmean size\tdedupe\tindex\tMB/s\t")
+	for _, r := range results {
+		fmt.Fprintf(table, "%s\t%d\t%d\t%d\t%.0f\t%.2fx\t%s\t%.1f\t\n",
+			r.Strategy, r.Bytes, r.Chunks, r.UniqueChunks, r.MeanChunkSize, r.DedupeRatio, formatBytes(r.IndexBytes), r.Throughput)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		fmt.Fprintf(w, "\n%s chunk sizes:\n", r.Strategy)
+		for _, bucket := range r.Histogram {
+			if bucket.Count == 0 {
+				continue
+			}
+			label := "> " + formatBytes(histogramBounds[len(histogramBounds)-1])
+			if bucket.UpTo > 0 {
+				label = "<= " + formatBytes(bucket.UpTo)
+			}
+			share := float64(bucket.Count) / float64(r.Chunks)
+			fmt.Fprintf(w, "  %11s %10d %5.1f%% %s\n", label, bucket.Count, 100*share, strings.Repeat("#", int(40*share+0.5)))
+		}
+	}
+	return nil
+}
+
+// formatBytes formats a byte count with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
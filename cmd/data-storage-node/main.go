@@ -3,22 +3,33 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
+	"strconv"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
 
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/db"
 	"github.com/radhakrishnan.venkat/dedupe-engine/internal/minio"
 	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
 )
 
+// maxHasChunksBatch bounds the number of fingerprints accepted by one HasChunks call
+const maxHasChunksBatch = 10000
+
 type server struct {
 	pb.UnimplementedStorageServiceServer
 	minioClient *minio.Client
 	nodeID      string
+	state       *NodeState
 }
 
 func (s *server) StoreChunk(ctx context.Context, req *pb.StoreChunkRequest) (*pb.StoreChunkResponse, error) {
@@ -28,9 +39,17 @@ func (s *server) StoreChunk(ctx context.Context, req *pb.StoreChunkRequest) (*pb
 	if len(req.ChunkData) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "chunk_data is required")
 	}
+	if err := s.state.CheckWritable(); err != nil {
+		return nil, err
+	}
+	if err := verifyFingerprint(req.Fingerprint, req.ChunkData); err != nil {
+		s.state.RecordWrite(0, true)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 
 	// Store chunk in MinIO
 	err := s.minioClient.StoreChunk(ctx, req.Fingerprint, req.ChunkData)
+	s.state.RecordWrite(int64(len(req.ChunkData)), err != nil)
 	if err != nil {
 		log.Printf("Failed to store chunk %s: %v", req.Fingerprint, err)
 		return &pb.StoreChunkResponse{
@@ -54,6 +73,7 @@ func (s *server) GetChunk(ctx context.Context, req *pb.GetChunkRequest) (*pb.Get
 	// Check if chunk exists
 	exists, err := s.minioClient.ChunkExists(ctx, req.Fingerprint)
 	if err != nil {
+		s.state.RecordRead(true)
 		log.Printf("Failed to check chunk existence for %s: %v", req.Fingerprint, err)
 		return &pb.GetChunkResponse{
 			Found:        false,
@@ -69,6 +89,7 @@ func (s *server) GetChunk(ctx context.Context, req *pb.GetChunkRequest) (*pb.Get
 
 	// Get chunk from MinIO
 	data, err := s.minioClient.GetChunk(ctx, req.Fingerprint)
+	s.state.RecordRead(err != nil)
 	if err != nil {
 		log.Printf("Failed to get chunk %s: %v", req.Fingerprint, err)
 		return &pb.GetChunkResponse{
@@ -93,6 +114,106 @@ func (s *server) GetChunk(ctx context.Context, req *pb.GetChunkRequest) (*pb.Get
 	}, nil
 }
 
+func (s *server) StoreChunks(stream pb.StorageService_StoreChunksServer) error {
+	if err := s.state.CheckWritable(); err != nil {
+		return err
+	}
+	resp := &pb.StoreChunksResponse{StorageNodeId: s.nodeID}
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(resp)
+		}
+		if err != nil {
+			return status.Errorf(codes.Internal, "Failed to receive chunk: %v", err)
+		}
+
+		ack := &pb.StoreChunkAck{Fingerprint: req.Fingerprint}
+		resp.Acks = append(resp.Acks, ack)
+
+		if req.Fingerprint == "" || len(req.ChunkData) == 0 {
+			ack.ErrorMessage = "fingerprint and chunk_data are required"
+			continue
+		}
+		// The node may start draining or fill up while the stream is open
+		if err := s.state.CheckWritable(); err != nil {
+			ack.ErrorMessage = status.Convert(err).Message()
+			continue
+		}
+		if err := verifyFingerprint(req.Fingerprint, req.ChunkData); err != nil {
+			log.Printf("Rejected chunk %s: %v", req.Fingerprint, err)
+			s.state.RecordWrite(0, true)
+			ack.ErrorMessage = err.Error()
+			continue
+		}
+		err = s.minioClient.StoreChunk(stream.Context(), req.Fingerprint, req.ChunkData)
+		s.state.RecordWrite(int64(len(req.ChunkData)), err != nil)
+		if err != nil {
+			log.Printf("Failed to store chunk %s: %v", req.Fingerprint, err)
+			ack.ErrorMessage = err.Error()
+			continue
+		}
+		ack.Success = true
+	}
+}
+
+func (s *server) HasChunks(ctx context.Context, req *pb.HasChunksRequest) (*pb.HasChunksResponse, error) {
+	if len(req.Fingerprints) > maxHasChunksBatch {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d fingerprints per request", maxHasChunksBatch)
+	}
+
+	resp := &pb.HasChunksResponse{}
+	for _, fingerprint := range req.Fingerprints {
+		exists, err := s.minioClient.ChunkExists(ctx, fingerprint)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to check chunk %s: %v", fingerprint, err)
+		}
+		if exists {
+			resp.PresentFingerprints = append(resp.PresentFingerprints, fingerprint)
+		}
+	}
+	return resp, nil
+}
+
+func (s *server) DeleteChunk(ctx context.Context, req *pb.DeleteChunkRequest) (*pb.DeleteChunkResponse, error) {
+	if req.Fingerprint == "" {
+		return nil, status.Error(codes.InvalidArgument, "fingerprint is required")
+	}
+
+	if err := s.minioClient.DeleteChunk(ctx, req.Fingerprint); err != nil {
+		log.Printf("Failed to delete chunk %s: %v", req.Fingerprint, err)
+		return &pb.DeleteChunkResponse{
+			Success:      false,
+			ErrorMessage: err.Error(),
+		}, nil
+	}
+
+	return &pb.DeleteChunkResponse{Success: true}, nil
+}
+
+func (s *server) NodeStatus(ctx context.Context, req *pb.NodeStatusRequest) (*pb.NodeStatusResponse, error) {
+	return s.state.Status(), nil
+}
+
+func (s *server) SetDrainMode(ctx context.Context, req *pb.SetDrainModeRequest) (*pb.SetDrainModeResponse, error) {
+	s.state.SetDraining(req.Draining)
+	return &pb.SetDrainModeResponse{Draining: req.Draining}, nil
+}
+
+// verifyFingerprint checks that data hashes to the fingerprint it was sent
+// with, using the hash algorithm the fingerprint names
+func verifyFingerprint(fingerprint string, data []byte) error {
+	expected, err := chunking.ParseFingerprint(fingerprint)
+	if err != nil {
+		return err
+	}
+	if actual := expected.Algorithm().Sum(data); actual != expected {
+		return fmt.Errorf("fingerprint mismatch: expected %s, computed %s", fingerprint, actual)
+	}
+	return nil
+}
+
 func main() {
 	// Get configuration from environment variables
 	minioEndpoint := getEnv("MINIO_ENDPOINT", "localhost:9000")
@@ -101,6 +222,15 @@ func main() {
 	minioBucket := getEnv("MINIO_BUCKET", "dedupe-chunks")
 	grpcPort := getEnv("GRPC_PORT", "50052")
 	nodeID := getEnv("NODE_ID", "data-storage-node-1")
+	cockroachAddr := getEnv("COCKROACHDB_ADDR", "")
+	scrubInterval := getEnv("SCRUB_INTERVAL", "")
+	capacity := int64(getEnvInt("STORAGE_CAPACITY_BYTES", 0))
+	reserve := int64(getEnvInt("STORAGE_RESERVE_BYTES", int(capacity/20)))
+	usageScanInterval := getEnv("USAGE_SCAN_INTERVAL", "10m")
+	scrubConfig := ScrubConfig{
+		ChunksPerSecond: getEnvInt("SCRUB_CHUNKS_PER_SEC", 200),
+		BytesPerSecond:  int64(getEnvInt("SCRUB_BYTES_PER_SEC", 16*1024*1024)),
+	}
 
 	// Initialize MinIO client
 	minioClient, err := minio.NewClient(minioEndpoint, minioAccessKey, minioSecretKey, minioBucket, false)
@@ -108,17 +238,75 @@ func main() {
 		log.Fatalf("Failed to create MinIO client: %v", err)
 	}
 
+	// Initialize database client if address provided; the scrubber uses it to
+	// cross-check stored objects against the chunks table
+	scrubber := NewScrubber(minioClient, nil, nodeID, scrubConfig)
+	if cockroachAddr != "" {
+		dbClient, err := db.NewDB(fmt.Sprintf("postgres://root@%s/dedupe_engine?sslmode=disable", cockroachAddr))
+		if err != nil {
+			log.Printf("Warning: Failed to connect to CockroachDB: %v", err)
+		} else {
+			scrubber = NewScrubber(minioClient, dbClient, nodeID, scrubConfig)
+			log.Printf("Connected to CockroachDB at %s", cockroachAddr)
+		}
+	}
+
+	// One-shot scrub: "data-storage-node scrub"
+	if len(os.Args) > 1 && os.Args[1] == "scrub" {
+		report, err := scrubber.Run(context.Background())
+		if err != nil {
+			log.Fatalf("Scrub failed: %v", err)
+		}
+		logScrubReport(report)
+		for _, fingerprint := range report.Corrupt {
+			log.Printf("  corrupt: %s", fingerprint)
+		}
+		for _, fingerprint := range report.Missing {
+			log.Printf("  missing: %s", fingerprint)
+		}
+		for _, fingerprint := range report.Orphaned {
+			log.Printf("  orphaned: %s", fingerprint)
+		}
+		if len(report.Corrupt) > 0 || len(report.Missing) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Background scrubbing
+	if scrubInterval != "" {
+		interval, err := time.ParseDuration(scrubInterval)
+		if err != nil {
+			log.Fatalf("Invalid SCRUB_INTERVAL %q: %v", scrubInterval, err)
+		}
+		go scrubber.RunPeriodically(context.Background(), interval)
+		log.Printf("Background scrubbing every %v", interval)
+	}
+
 	// Create gRPC server
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", grpcPort))
 	if err != nil {
 		log.Fatalf("Failed to listen: %v", err)
 	}
 
+	state := NewNodeState(nodeID, capacity, reserve)
+	state.SetDraining(getEnv("DRAINING", "false") == "true")
+
+	// Standard gRPC health checks; NOT_SERVING while object storage cannot be listed
+	healthServer := health.NewServer()
+	interval, err := time.ParseDuration(usageScanInterval)
+	if err != nil {
+		log.Fatalf("Invalid USAGE_SCAN_INTERVAL %q: %v", usageScanInterval, err)
+	}
+	go runUsageScans(context.Background(), state, minioClient, healthServer, interval)
+
 	s := grpc.NewServer()
 	pb.RegisterStorageServiceServer(s, &server{
 		minioClient: minioClient,
 		nodeID:      nodeID,
+		state:       state,
 	})
+	healthpb.RegisterHealthServer(s, healthServer)
 
 	log.Printf("Data Storage Node starting on port %s", grpcPort)
 	if err := s.Serve(lis); err != nil {
@@ -126,9 +314,42 @@ func main() {
 	}
 }
 
+// runUsageScans refreshes the node's usage now and then every interval, and
+// reports the result through the health service
+func runUsageScans(ctx context.Context, state *NodeState, store chunkLister, healthServer *health.Server, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		servingStatus := healthpb.HealthCheckResponse_SERVING
+		if err := state.RefreshUsage(ctx, store); err != nil {
+			log.Printf("Usage scan failed: %v", err)
+			servingStatus = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		healthServer.SetServingStatus("", servingStatus)
+		healthServer.SetServingStatus(pb.StorageService_ServiceDesc.ServiceName, servingStatus)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+		log.Printf("Warning: Ignoring invalid %s=%q", key, value)
+	}
+	return defaultValue
+}
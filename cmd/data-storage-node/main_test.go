@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// fakeStoreStream replays chunks to StoreChunks, calling before ahead of
+// each, and keeps the response
+type fakeStoreStream struct {
+	grpc.ServerStream
+	requests []*pb.StoreChunkRequest
+	before   func(i int)
+	received int
+	resp     *pb.StoreChunksResponse
+}
+
+func (f *fakeStoreStream) Context() context.Context { return context.Background() }
+
+func (f *fakeStoreStream) Recv() (*pb.StoreChunkRequest, error) {
+	if f.received == len(f.requests) {
+		return nil, io.EOF
+	}
+	f.before(f.received)
+	f.received++
+	return f.requests[f.received-1], nil
+}
+
+func (f *fakeStoreStream) SendAndClose(resp *pb.StoreChunksResponse) error {
+	f.resp = resp
+	return nil
+}
+
+func TestStoreChunksStopsWhenNotWritable(t *testing.T) {
+	chunk := func(data string) *pb.StoreChunkRequest {
+		return &pb.StoreChunkRequest{Fingerprint: chunking.ComputeFingerprint([]byte(data)).String(), ChunkData: []byte(data)}
+	}
+
+	for name, stop := range map[string]func(state *NodeState){
+		"draining": func(state *NodeState) { state.SetDraining(true) },
+		"full":     func(state *NodeState) { state.RecordWrite(960, false) },
+	} {
+		state := NewNodeState("node-1", 1000, 50)
+		s := &server{nodeID: "node-1", state: state}
+		stream := &fakeStoreStream{
+			requests: []*pb.StoreChunkRequest{chunk("a"), chunk("b")},
+			before: func(i int) {
+				if i == 0 {
+					stop(state)
+				}
+			},
+		}
+		if err := s.StoreChunks(stream); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(stream.resp.GetAcks()) != 2 {
+			t.Fatalf("%s: expected an ack for each chunk, got %v", name, stream.resp)
+		}
+		for _, ack := range stream.resp.Acks {
+			if ack.Success || ack.ErrorMessage == "" {
+				t.Errorf("%s: expected chunk %s to be rejected, got %v", name, ack.Fingerprint, ack)
+			}
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/db"
+)
+
+// scrubIndexPageSize is the number of fingerprints read from the chunks table at a time
+const scrubIndexPageSize = 1000
+
+// chunkStore is the object storage used by the scrubber
+type chunkStore interface {
+	ListChunks(ctx context.Context, fn func(fingerprint string, size int64) error) error
+	GetChunk(ctx context.Context, fingerprint string) ([]byte, error)
+	QuarantineChunk(ctx context.Context, fingerprint string) error
+}
+
+// chunkIndex is the metadata index the scrubber cross-checks object storage against
+type chunkIndex interface {
+	ListChunkFingerprints(ctx context.Context, storageNodeID, after string, limit int) ([]string, error)
+	RemoveChunkReplica(ctx context.Context, fingerprint, storageNodeID string) error
+	QuarantineChunk(ctx context.Context, q *db.QuarantinedChunk) error
+}
+
+// ScrubConfig controls how fast the scrubber reads stored chunks
+type ScrubConfig struct {
+	ChunksPerSecond int   // 0 means unlimited
+	BytesPerSecond  int64 // 0 means unlimited
+}
+
+// ScrubReport summarises a single scrub pass
+type ScrubReport struct {
+	StartTime    time.Time
+	EndTime      time.Time
+	ChunksRead   int
+	BytesRead    int64
+	Corrupt      []string // fingerprints whose data no longer hashes to the key
+	Orphaned     []string // objects with no row in the chunks table
+	Missing      []string // rows in the chunks table with no object
+	ReadFailures int
+}
+
+// Scrubber re-verifies stored chunks against their fingerprints
+type Scrubber struct {
+	store  chunkStore
+	index  chunkIndex // nil when no database is configured
+	nodeID string
+	config ScrubConfig
+}
+
+// NewScrubber creates a scrubber; index may be nil to skip the cross-check
+func NewScrubber(store chunkStore, index chunkIndex, nodeID string, config ScrubConfig) *Scrubber {
+	return &Scrubber{
+		store:  store,
+		index:  index,
+		nodeID: nodeID,
+		config: config,
+	}
+}
+
+// Run performs one full pass over object storage. Objects are listed in
+// fingerprint order and merged with the equally ordered chunks table, so
+// orphans and missing objects are found without holding either side in memory.
+func (s *Scrubber) Run(ctx context.Context) (*ScrubReport, error) {
+	report := &ScrubReport{StartTime: time.Now()}
+	limiter := newThrottle(s.config)
+	cursor := &indexCursor{index: s.index, nodeID: s.nodeID}
+
+	err := s.store.ListChunks(ctx, func(fingerprint string, size int64) error {
+		// Every indexed fingerprint sorting before this object has no object
+		for {
+			next, ok, err := cursor.peek(ctx)
+			if err != nil {
+				return err
+			}
+			if !ok || next >= fingerprint {
+				break
+			}
+			report.Missing = append(report.Missing, next)
+			cursor.advance()
+		}
+		if next, ok, err := cursor.peek(ctx); err != nil {
+			return err
+		} else if ok && next == fingerprint {
+			cursor.advance()
+		} else if s.index != nil {
+			report.Orphaned = append(report.Orphaned, fingerprint)
+		}
+
+		if err := limiter.wait(ctx, size); err != nil {
+			return err
+		}
+
+		data, err := s.store.GetChunk(ctx, fingerprint)
+		if err != nil {
+			log.Printf("Scrub: failed to read chunk %s: %v", fingerprint, err)
+			report.ReadFailures++
+			return nil
+		}
+		report.ChunksRead++
+		report.BytesRead += int64(len(data))
+
+		if err := verifyFingerprint(fingerprint, data); err != nil {
+			report.Corrupt = append(report.Corrupt, fingerprint)
+			if err := s.quarantineChunk(ctx, fingerprint, err.Error()); err != nil {
+				log.Printf("Scrub: failed to quarantine chunk %s: %v", fingerprint, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Whatever remains in the index has no object at all
+	for {
+		next, ok, err := cursor.peek(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		report.Missing = append(report.Missing, next)
+		cursor.advance()
+	}
+
+	report.EndTime = time.Now()
+	return report, nil
+}
+
+// RunPeriodically runs a scrub pass every interval until ctx is cancelled
+func (s *Scrubber) RunPeriodically(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := s.Run(ctx)
+			if err != nil {
+				log.Printf("Scrub failed: %v", err)
+				continue
+			}
+			logScrubReport(report)
+		}
+	}
+}
+
+// quarantineChunk moves a corrupt chunk under the quarantine prefix and, when
+// a database is configured, adds it to the quarantined_chunks table. This node
+// is dropped from the chunk's placement, and the chunk row is removed once no
+// replica is left, so that the next backup containing the same data stores a
+// good copy again instead of deduplicating against the bad one.
+func (s *Scrubber) quarantineChunk(ctx context.Context, fingerprint, reason string) error {
+	log.Printf("Scrub: quarantining chunk %s: %s", fingerprint, reason)
+
+	if err := s.store.QuarantineChunk(ctx, fingerprint); err != nil {
+		return err
+	}
+	if s.index == nil {
+		return nil
+	}
+	entry := &db.QuarantinedChunk{
+		Fingerprint:   fingerprint,
+		StorageNodeID: s.nodeID,
+		Reason:        reason,
+		DetectedTime:  time.Now(),
+	}
+	if err := s.index.QuarantineChunk(ctx, entry); err != nil {
+		return err
+	}
+	return s.index.RemoveChunkReplica(ctx, fingerprint, s.nodeID)
+}
+
+// logScrubReport writes a one-line summary of a scrub pass
+func logScrubReport(report *ScrubReport) {
+	log.Printf("Scrub completed in %v: %d chunks (%d bytes) read, %d corrupt, %d orphaned, %d missing, %d read failures",
+		report.EndTime.Sub(report.StartTime).Round(time.Millisecond), report.ChunksRead, report.BytesRead,
+		len(report.Corrupt), len(report.Orphaned), len(report.Missing), report.ReadFailures)
+}
+
+// indexCursor pages through this node's rows of the chunks table in fingerprint order
+type indexCursor struct {
+	index  chunkIndex
+	nodeID string
+	page   []string
+	last   string
+	done   bool
+}
+
+// peek returns the next indexed fingerprint without consuming it
+func (c *indexCursor) peek(ctx context.Context) (string, bool, error) {
+	if c.index == nil {
+		return "", false, nil
+	}
+	if len(c.page) == 0 && !c.done {
+		page, err := c.index.ListChunkFingerprints(ctx, c.nodeID, c.last, scrubIndexPageSize)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to list indexed chunks: %w", err)
+		}
+		c.page = page
+		c.done = len(page) < scrubIndexPageSize
+		if len(page) > 0 {
+			c.last = page[len(page)-1]
+		}
+	}
+	if len(c.page) == 0 {
+		return "", false, nil
+	}
+	return c.page[0], true, nil
+}
+
+// advance consumes the fingerprint returned by peek
+func (c *indexCursor) advance() {
+	c.page = c.page[1:]
+}
+
+// throttle paces reads so that scrubbing does not starve live traffic
+type throttle struct {
+	config ScrubConfig
+	start  time.Time
+	chunks int
+	bytes  int64
+}
+
+func newThrottle(config ScrubConfig) *throttle {
+	return &throttle{config: config, start: time.Now()}
+}
+
+// wait blocks until reading one more chunk of size bytes stays within the limits
+func (t *throttle) wait(ctx context.Context, size int64) error {
+	t.chunks++
+	t.bytes += size
+
+	var due time.Duration
+	if t.config.ChunksPerSecond > 0 {
+		due = time.Duration(float64(t.chunks) / float64(t.config.ChunksPerSecond) * float64(time.Second))
+	}
+	if t.config.BytesPerSecond > 0 {
+		if byBytes := time.Duration(float64(t.bytes) / float64(t.config.BytesPerSecond) * float64(time.Second)); byBytes > due {
+			due = byBytes
+		}
+	}
+
+	delay := due - time.Since(t.start)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/db"
+)
+
+// memStore is an in-memory chunkStore
+type memStore struct {
+	objects     map[string][]byte
+	quarantined map[string][]byte
+}
+
+func newMemStore() *memStore {
+	return &memStore{objects: make(map[string][]byte), quarantined: make(map[string][]byte)}
+}
+
+func (m *memStore) ListChunks(ctx context.Context, fn func(fingerprint string, size int64) error) error {
+	keys := make([]string, 0, len(m.objects))
+	for key := range m.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := fn(key, int64(len(m.objects[key]))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memStore) GetChunk(ctx context.Context, fingerprint string) ([]byte, error) {
+	data, ok := m.objects[fingerprint]
+	if !ok {
+		return nil, fmt.Errorf("chunk %s not found", fingerprint)
+	}
+	return data, nil
+}
+
+func (m *memStore) QuarantineChunk(ctx context.Context, fingerprint string) error {
+	m.quarantined[fingerprint] = m.objects[fingerprint]
+	delete(m.objects, fingerprint)
+	return nil
+}
+
+// memIndex is an in-memory chunkIndex
+type memIndex struct {
+	fingerprints map[string]bool
+	quarantined  []db.QuarantinedChunk
+}
+
+func (m *memIndex) ListChunkFingerprints(ctx context.Context, storageNodeID, after string, limit int) ([]string, error) {
+	var all []string
+	for fingerprint := range m.fingerprints {
+		if fingerprint > after {
+			all = append(all, fingerprint)
+		}
+	}
+	sort.Strings(all)
+	if len(all) > limit {
+		all = all[:limit]
+	}
+	return all, nil
+}
+
+func (m *memIndex) RemoveChunkReplica(ctx context.Context, fingerprint, storageNodeID string) error {
+	delete(m.fingerprints, fingerprint)
+	return nil
+}
+
+func (m *memIndex) QuarantineChunk(ctx context.Context, q *db.QuarantinedChunk) error {
+	m.quarantined = append(m.quarantined, *q)
+	return nil
+}
+
+func TestScrubberFindsCorruptOrphanedAndMissing(t *testing.T) {
+	store := newMemStore()
+	index := &memIndex{fingerprints: make(map[string]bool)}
+
+	for i := 0; i < 10; i++ {
+		data := []byte(fmt.Sprintf("chunk data %d", i))
+		fingerprint := chunking.ComputeFingerprint(data).String()
+		store.objects[fingerprint] = data
+		index.fingerprints[fingerprint] = true
+	}
+
+	// Corrupt one stored chunk
+	corrupt := chunking.ComputeFingerprint([]byte("chunk data 3")).String()
+	store.objects[corrupt] = []byte("bit rot")
+
+	// An object nobody references
+	orphan := chunking.ComputeFingerprint([]byte("orphan")).String()
+	store.objects[orphan] = []byte("orphan")
+
+	// A referenced chunk whose object is gone
+	missing := chunking.ComputeFingerprint([]byte("missing")).String()
+	index.fingerprints[missing] = true
+
+	scrubber := NewScrubber(store, index, "node-1", ScrubConfig{})
+	report, err := scrubber.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Scrub failed: %v", err)
+	}
+
+	if report.ChunksRead != 11 {
+		t.Errorf("Expected 11 chunks read, got %d", report.ChunksRead)
+	}
+	if len(report.Corrupt) != 1 || report.Corrupt[0] != corrupt {
+		t.Errorf("Expected corrupt [%s], got %v", corrupt, report.Corrupt)
+	}
+	if len(report.Orphaned) != 1 || report.Orphaned[0] != orphan {
+		t.Errorf("Expected orphaned [%s], got %v", orphan, report.Orphaned)
+	}
+	if len(report.Missing) != 1 || report.Missing[0] != missing {
+		t.Errorf("Expected missing [%s], got %v", missing, report.Missing)
+	}
+
+	// The corrupt chunk is quarantined and no longer indexed
+	if _, ok := store.objects[corrupt]; ok {
+		t.Error("Expected corrupt chunk to be removed from the live key space")
+	}
+	if _, ok := store.quarantined[corrupt]; !ok {
+		t.Error("Expected corrupt chunk to be quarantined")
+	}
+	if index.fingerprints[corrupt] {
+		t.Error("Expected corrupt chunk to be removed from the index")
+	}
+	if len(index.quarantined) != 1 || index.quarantined[0].StorageNodeID != "node-1" {
+		t.Errorf("Expected one quarantine entry for node-1, got %v", index.quarantined)
+	}
+}
+
+func TestScrubberPagesThroughIndex(t *testing.T) {
+	store := newMemStore()
+	index := &memIndex{fingerprints: make(map[string]bool)}
+
+	// More chunks than one index page
+	for i := 0; i < scrubIndexPageSize+5; i++ {
+		data := []byte(fmt.Sprintf("chunk %d", i))
+		fingerprint := chunking.ComputeFingerprint(data).String()
+		store.objects[fingerprint] = data
+		index.fingerprints[fingerprint] = true
+	}
+
+	report, err := NewScrubber(store, index, "node-1", ScrubConfig{}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Scrub failed: %v", err)
+	}
+	if len(report.Orphaned) != 0 || len(report.Missing) != 0 || len(report.Corrupt) != 0 {
+		t.Errorf("Expected a clean report, got %d orphaned, %d missing, %d corrupt",
+			len(report.Orphaned), len(report.Missing), len(report.Corrupt))
+	}
+}
+
+func TestScrubberRateLimit(t *testing.T) {
+	store := newMemStore()
+	for i := 0; i < 5; i++ {
+		data := []byte(fmt.Sprintf("chunk %d", i))
+		store.objects[chunking.ComputeFingerprint(data).String()] = data
+	}
+
+	start := time.Now()
+	_, err := NewScrubber(store, nil, "node-1", ScrubConfig{ChunksPerSecond: 50}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Scrub failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Expected 5 chunks at 50/s to take at least 100ms, took %v", elapsed)
+	}
+}
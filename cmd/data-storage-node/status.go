@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// statusWindowMinutes is how far back NodeStatus error rates look
+const statusWindowMinutes = 5
+
+// chunkLister lists stored objects for the usage scan
+type chunkLister interface {
+	ListChunks(ctx context.Context, fn func(fingerprint string, size int64) error) error
+}
+
+// opCounter counts operations and failures in one-minute buckets
+type opCounter struct {
+	buckets [statusWindowMinutes]opBucket
+	mutex   sync.Mutex
+}
+
+type opBucket struct {
+	minute int64
+	total  int64
+	failed int64
+}
+
+// record counts one operation at now
+func (c *opCounter) record(now time.Time, failed bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	minute := now.Unix() / 60
+	bucket := &c.buckets[minute%statusWindowMinutes]
+	if bucket.minute != minute {
+		*bucket = opBucket{minute: minute}
+	}
+	bucket.total++
+	if failed {
+		bucket.failed++
+	}
+}
+
+// rate returns the operations in the window ending at now and the fraction that failed
+func (c *opCounter) rate(now time.Time) (int64, float64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	minute := now.Unix() / 60
+	var total, failed int64
+	for _, bucket := range c.buckets {
+		if bucket.minute > minute-statusWindowMinutes && bucket.minute <= minute {
+			total += bucket.total
+			failed += bucket.failed
+		}
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	return total, float64(failed) / float64(total)
+}
+
+// NodeState tracks usage, error rates and drain mode of a storage node
+type NodeState struct {
+	nodeID    string
+	capacity  int64 // 0 means unlimited
+	reserve   int64 // writes are rejected once free space drops below this
+	startTime time.Time
+
+	reads    opCounter
+	writes   opCounter
+	draining atomic.Bool
+
+	usageMutex   sync.RWMutex
+	usedBytes    int64
+	chunkCount   int64
+	usageUpdated time.Time
+}
+
+// NewNodeState creates the state for a node with the given capacity in bytes
+func NewNodeState(nodeID string, capacity, reserve int64) *NodeState {
+	return &NodeState{
+		nodeID:    nodeID,
+		capacity:  capacity,
+		reserve:   reserve,
+		startTime: time.Now(),
+	}
+}
+
+// SetDraining enters or leaves drain mode
+func (n *NodeState) SetDraining(draining bool) {
+	if n.draining.Swap(draining) == draining {
+		return
+	}
+	if draining {
+		log.Printf("Drain mode enabled: rejecting new writes")
+	} else {
+		log.Printf("Drain mode disabled: accepting writes")
+	}
+}
+
+// RecordRead counts a read; failed reports whether it errored
+func (n *NodeState) RecordRead(failed bool) {
+	n.reads.record(time.Now(), failed)
+}
+
+// RecordWrite counts a write and, when it succeeded, adds size bytes to the usage.
+// Overwrites are counted again until the next usage scan corrects the total.
+func (n *NodeState) RecordWrite(size int64, failed bool) {
+	n.writes.record(time.Now(), failed)
+	if failed {
+		return
+	}
+	n.usageMutex.Lock()
+	n.usedBytes += size
+	n.chunkCount++
+	n.usageMutex.Unlock()
+}
+
+// RefreshUsage recomputes used bytes and chunk count by listing every object
+func (n *NodeState) RefreshUsage(ctx context.Context, store chunkLister) error {
+	var used, count int64
+	if err := store.ListChunks(ctx, func(fingerprint string, size int64) error {
+		used += size
+		count++
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	n.usageMutex.Lock()
+	n.usedBytes = used
+	n.chunkCount = count
+	n.usageUpdated = time.Now()
+	n.usageMutex.Unlock()
+	return nil
+}
+
+// CheckWritable returns a gRPC error when the node must not accept writes
+func (n *NodeState) CheckWritable() error {
+	if n.draining.Load() {
+		return status.Errorf(codes.FailedPrecondition, "Data Storage Node %s is draining", n.nodeID)
+	}
+	if n.isFull() {
+		return status.Errorf(codes.ResourceExhausted, "Data Storage Node %s is full", n.nodeID)
+	}
+	return nil
+}
+
+func (n *NodeState) isFull() bool {
+	if n.capacity <= 0 {
+		return false
+	}
+	n.usageMutex.RLock()
+	defer n.usageMutex.RUnlock()
+	return n.capacity-n.usedBytes < n.reserve
+}
+
+// Status builds a NodeStatus response
+func (n *NodeState) Status() *pb.NodeStatusResponse {
+	now := time.Now()
+	reads, readErrorRate := n.reads.rate(now)
+	writes, writeErrorRate := n.writes.rate(now)
+
+	n.usageMutex.RLock()
+	resp := &pb.NodeStatusResponse{
+		StorageNodeId:  n.nodeID,
+		UsedBytes:      n.usedBytes,
+		CapacityBytes:  n.capacity,
+		ChunkCount:     n.chunkCount,
+		ReadErrorRate:  readErrorRate,
+		WriteErrorRate: writeErrorRate,
+		Reads:          reads,
+		Writes:         writes,
+		UptimeSeconds:  int64(now.Sub(n.startTime).Seconds()),
+	}
+	if !n.usageUpdated.IsZero() {
+		resp.UsageUpdatedTime = n.usageUpdated.Unix()
+	}
+	if n.capacity > 0 {
+		resp.FreeBytes = max(n.capacity-n.usedBytes, 0)
+	}
+	n.usageMutex.RUnlock()
+
+	resp.Draining = n.draining.Load()
+	resp.Full = n.isFull()
+	resp.AcceptingWrites = !resp.Draining && !resp.Full
+	return resp
+}
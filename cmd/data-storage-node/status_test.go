@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestOpCounterWindow(t *testing.T) {
+	var counter opCounter
+	start := time.Unix(1700000000, 0)
+
+	counter.record(start, false)
+	counter.record(start, true)
+	counter.record(start.Add(2*time.Minute), false)
+	counter.record(start.Add(2*time.Minute), false)
+
+	total, errorRate := counter.rate(start.Add(2 * time.Minute))
+	if total != 4 || errorRate != 0.25 {
+		t.Errorf("Expected 4 ops at 25%% errors, got %d at %v", total, errorRate)
+	}
+
+	// The first minute falls out of the window
+	total, errorRate = counter.rate(start.Add(statusWindowMinutes * time.Minute))
+	if total != 2 || errorRate != 0 {
+		t.Errorf("Expected 2 ops without errors, got %d at %v", total, errorRate)
+	}
+
+	// Reusing a bucket discards its old counts
+	counter.record(start.Add(statusWindowMinutes*time.Minute), true)
+	total, errorRate = counter.rate(start.Add(statusWindowMinutes * time.Minute))
+	if total != 3 || errorRate != 1.0/3 {
+		t.Errorf("Expected 3 ops with one error, got %d at %v", total, errorRate)
+	}
+}
+
+func TestNodeStateWritable(t *testing.T) {
+	store := newMemStore()
+	store.objects["a"] = make([]byte, 600)
+	store.objects["b"] = make([]byte, 300)
+
+	state := NewNodeState("node-1", 1000, 50)
+	if err := state.RefreshUsage(context.Background(), store); err != nil {
+		t.Fatalf("Failed to refresh usage: %v", err)
+	}
+	resp := state.Status()
+	if resp.UsedBytes != 900 || resp.FreeBytes != 100 || resp.ChunkCount != 2 || !resp.AcceptingWrites {
+		t.Errorf("Unexpected status: %+v", resp)
+	}
+
+	state.SetDraining(true)
+	if err := state.CheckWritable(); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected draining node to reject writes, got %v", err)
+	}
+	state.SetDraining(false)
+
+	// Writing into the reserve marks the node full
+	state.RecordWrite(60, false)
+	if err := state.CheckWritable(); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Expected full node to reject writes, got %v", err)
+	}
+	resp = state.Status()
+	if !resp.Full || resp.AcceptingWrites || resp.FreeBytes != 40 {
+		t.Errorf("Unexpected status: %+v", resp)
+	}
+}
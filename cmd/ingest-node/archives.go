@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+)
+
+// Archive-aware ingest chunks the contents of each tar or zip member apart
+// from the headers around it. A member's contents then chunk as they do when
+// the file is backed up on its own, so the two share chunks. The file's
+// chunks still follow each other in archive order, so the archive restores
+// byte for byte without being rebuilt.
+
+// writeArchive passes data about to be buffered to the archive splitter and
+// records where member contents begin or end in it
+func (f *pendingFile) writeArchive(data []byte) {
+	if f.archive == nil {
+		return
+	}
+	seen := f.resent(int64(len(data)))
+	for _, cut := range f.archive.Write(data[seen:]) {
+		f.cuts = append(f.cuts, len(f.data)+int(seen)+cut)
+	}
+}
+
+// skipArchive passes a hole to the archive splitter
+func (f *pendingFile) skipArchive(size uint64) {
+	if f.archive != nil {
+		f.archive.Skip(int64(size) - f.resent(int64(size)))
+	}
+}
+
+// resent returns how many of the next n bytes of a resumed file the archive
+// splitter already saw before the checkpoint. The checkpoint is taken after
+// the last cut stored, so they hold no cuts.
+func (f *pendingFile) resent(n int64) int64 {
+	seen := min(n, f.archiveSeen)
+	f.archiveSeen -= seen
+	return seen
+}
+
+// splitFileData finds the chunks of the buffered data of a file in order and
+// passes each to emit, stopping early if emit returns false. The data of an
+// archive is chunked separately between its cuts.
+func splitFileData(chunker *chunking.Chunker, file *pendingFile, emit func(chunking.Chunk) bool) {
+	start := 0
+	for _, end := range append(file.cuts[:len(file.cuts):len(file.cuts)], len(file.data)) {
+		for start < end {
+			size := chunker.Boundary(file.data[start:end])
+			if !emit(chunking.Chunk{Data: file.data[start : start+size], Offset: int64(start), Size: int64(size)}) {
+				return
+			}
+			start += size
+		}
+	}
+}
+
+// storeBufferedData stores the data of a file that has grown past
+// checkpointBytes. The data of an archive is stored up to its last cut and
+// the rest kept, so that the member being received is not chunked in two
+// pieces where its standalone copy is not.
+func (s *IngestServer) storeBufferedData(ctx context.Context, upload *jobUpload, filePath string, file *pendingFile) error {
+	n := len(file.cuts)
+	if n == 0 || file.cuts[n-1] == 0 {
+		return s.storeFileData(ctx, upload, filePath, file)
+	}
+	end := file.cuts[n-1]
+	rest := append([]byte(nil), file.data[end:]...)
+	file.data = file.data[:end]
+	if err := s.storeFileData(ctx, upload, filePath, file); err != nil {
+		return err
+	}
+	file.data = rest
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/archive"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// archiveFixture returns file contents and a tar holding them after another
+// member, so the contents sit at an offset a standalone copy does not
+func archiveFixture(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	content := make([]byte, 40000)
+	rand.New(rand.NewSource(1)).Read(content)
+
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	w.WriteHeader(&tar.Header{Name: "notes.txt", Mode: 0o644, Size: 5})
+	w.Write([]byte("notes"))
+	w.WriteHeader(&tar.Header{Name: "data/content.bin", Mode: 0o644, Size: int64(len(content))})
+	w.Write(content)
+	w.Close()
+	return content, buf.Bytes()
+}
+
+// backupFile backs up one file in segments of segmentSize in its own job
+func backupFile(t *testing.T, server *IngestServer, jobID, path string, data []byte, archives bool, segmentSize int) []string {
+	t.Helper()
+	start := &pb.BackupStart{ClientId: "client-a", BackupJobId: jobID, Archives: archives}
+	requests := []*pb.BackupRequest{
+		{RequestType: &pb.BackupRequest_StartBackup{StartBackup: start}},
+		{RequestType: &pb.BackupRequest_FileEntry{FileEntry: &pb.FileEntry{FilePath: path, Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o644}}},
+	}
+	for offset := 0; offset < len(data); offset += segmentSize {
+		end := min(offset+segmentSize, len(data))
+		requests = append(requests, segmentAt(path, data[offset:end], uint64(offset), 0, end == len(data)))
+	}
+	requests = append(requests, backupEnd(jobID, "COMPLETED"))
+	if err := server.StreamBackup(&fakeBackupStream{requests: requests}); err != nil {
+		t.Fatalf("StreamBackup of %s failed: %v", jobID, err)
+	}
+	manifests, err := server.manifests.List(context.Background(), jobID)
+	if err != nil || len(manifests) != 1 || manifests[0].Size != int64(len(data)) {
+		t.Fatalf("Unexpected manifests of %s: %v (%v)", jobID, manifests, err)
+	}
+	return manifests[0].Chunks
+}
+
+func TestArchiveMembersShareChunks(t *testing.T) {
+	server := NewIngestServer("0")
+	content, stream := archiveFixture(t)
+
+	plain := backupFile(t, server, "job-plain", "/data/content.bin", content, false, 1000)
+	aware := backupFile(t, server, "job-aware", "/backups/data.tar", stream, true, 1000)
+	unaware := backupFile(t, server, "job-unaware", "/backups/data.tar", stream, false, 1000)
+
+	contains := func(refs []string, ref string) bool {
+		for _, r := range refs {
+			if r == ref {
+				return true
+			}
+		}
+		return false
+	}
+	for _, ref := range plain {
+		if !contains(aware, ref) {
+			t.Errorf("Chunk %s of the standalone file is missing from the archive-aware backup", ref[:16])
+		}
+	}
+	if contains(unaware, plain[0]) {
+		t.Errorf("Expected the archive chunked as one stream to split the contents differently")
+	}
+}
+
+func TestChunkArchiveData(t *testing.T) {
+	server := NewIngestServer("0")
+	content, stream := archiveFixture(t)
+
+	// The chunks of an archive follow each other in order, so they restore
+	// it byte for byte
+	file := &pendingFile{archive: archive.NewSplitter()}
+	for offset := 0; offset < len(stream); offset += 777 {
+		data := stream[offset:min(offset+777, len(stream))]
+		file.writeArchive(data)
+		file.data = append(file.data, data...)
+	}
+	var chunks []chunking.Chunk
+	splitFileData(server.chunker, file, func(chunk chunking.Chunk) bool {
+		chunks = append(chunks, chunk)
+		return true
+	})
+	var restored []byte
+	for i, chunk := range chunks {
+		if chunk.Offset != int64(len(restored)) {
+			t.Fatalf("Chunk %d at offset %d, expected %d", i, chunk.Offset, len(restored))
+		}
+		restored = append(restored, chunk.Data...)
+	}
+	if !bytes.Equal(restored, stream) {
+		t.Fatal("Chunks do not reassemble the archive")
+	}
+
+	// The member's contents start a chunk of their own
+	start := bytes.Index(stream, content)
+	found := false
+	for _, chunk := range chunks {
+		if chunk.Offset == int64(start) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected a chunk to begin at the member's contents, offset %d", start)
+	}
+}
+
+func TestResumedArchiveSplitsAsBefore(t *testing.T) {
+	ctx := context.Background()
+	server := NewIngestServer("0")
+	server.checkpointBytes = 4096
+	// Members a few checkpoints long, so that one checkpoint follows a
+	// member boundary inside a segment
+	random := rand.New(rand.NewSource(2))
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for i, size := range []int{6000, 3000, 6000} {
+		content := make([]byte, size)
+		random.Read(content)
+		w.WriteHeader(&tar.Header{Name: fmt.Sprintf("member-%d", i), Mode: 0o644, Size: int64(size)})
+		w.Write(content)
+	}
+	w.Close()
+	stream := buf.Bytes()
+	path := "/backups/data.tar"
+	want := backupFile(t, server, "job-whole", path, stream, true, 1000)
+
+	entry := &pb.FileEntry{FilePath: path, Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o644}
+	segments := func(from, to int) []*pb.BackupRequest {
+		var requests []*pb.BackupRequest
+		for offset := from; offset < to; {
+			end := min((offset/1000+1)*1000, len(stream))
+			requests = append(requests, segmentAt(path, stream[offset:end], uint64(offset), 0, end == len(stream)))
+			offset = end
+		}
+		return requests
+	}
+
+	// The stream breaks at every segment; the resumed job continues from
+	// its checkpoint, after some of the data the splitter saw when its
+	// buffered data was not stored
+	resumedAfterCut := 0
+	for interrupt := 1000; interrupt < len(stream); interrupt += 1000 {
+		jobID := fmt.Sprintf("job-%d", interrupt)
+		start := &pb.BackupStart{ClientId: "client-a", BackupJobId: jobID, Archives: true}
+		requests := []*pb.BackupRequest{
+			{RequestType: &pb.BackupRequest_StartBackup{StartBackup: start}},
+			{RequestType: &pb.BackupRequest_FileEntry{FileEntry: entry}},
+		}
+		if err := server.StreamBackup(&fakeBackupStream{requests: append(requests, segments(0, interrupt)...)}); err != nil {
+			t.Fatal(err)
+		}
+		checkpoint, _ := server.checkpoints.Get(ctx, jobID)
+		if checkpoint == nil || checkpoint.PartialFile != path {
+			continue
+		}
+		var splitter archive.Splitter
+		if err := splitter.UnmarshalBinary(checkpoint.PartialArchive); err != nil {
+			t.Fatalf("%s: checkpoint without the archive state: %v", jobID, err)
+		}
+		if splitter.Offset() > checkpoint.PartialOffset {
+			resumedAfterCut++
+		}
+
+		resume := &pb.BackupStart{ClientId: "client-a", BackupJobId: jobID, Archives: true, Resume: true}
+		requests = []*pb.BackupRequest{{RequestType: &pb.BackupRequest_StartBackup{StartBackup: resume}}}
+		requests = append(requests, segments(int(checkpoint.PartialOffset), len(stream))...)
+		if err := server.StreamBackup(&fakeBackupStream{requests: append(requests, backupEnd(jobID, "COMPLETED"))}); err != nil {
+			t.Fatalf("%s: resumed backup failed: %v", jobID, err)
+		}
+		manifests, _ := server.manifests.List(ctx, jobID)
+		if len(manifests) != 1 || !slices.Equal(manifests[0].Chunks, want) {
+			t.Errorf("%s: resumed at %d, the archive is chunked differently", jobID, checkpoint.PartialOffset)
+		}
+	}
+	if resumedAfterCut == 0 {
+		t.Error("Expected a checkpoint with buffered data the splitter saw")
+	}
+}
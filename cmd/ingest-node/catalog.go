@@ -0,0 +1,486 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/db"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/jobstate"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// Pages of catalog listings hold defaultPageSize items unless the request
+// asks for up to maxPageSize
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// restorableJobBatch is the number of jobs searches and version listings
+// read per query as they page through the jobs of a client
+const restorableJobBatch = 500
+
+// CatalogServer implements the CatalogService over the job history and
+// manifests of an ingest node
+type CatalogServer struct {
+	pb.UnimplementedCatalogServiceServer
+
+	ingest   *IngestServer
+	jobBatch int // jobs per page of restorableJobs
+}
+
+// NewCatalogServer creates a CatalogServer for an ingest node
+func NewCatalogServer(ingest *IngestServer) *CatalogServer {
+	return &CatalogServer{ingest: ingest, jobBatch: restorableJobBatch}
+}
+
+// ListJobs lists the backup jobs of a client, most recently started first
+func (c *CatalogServer) ListJobs(ctx context.Context, req *pb.ListJobsRequest) (*pb.ListJobsResponse, error) {
+	if req.ClientId == "" {
+		return nil, status.Error(codes.InvalidArgument, "Client ID is required")
+	}
+	filter := db.BackupJobFilter{
+		ClientID:       req.ClientId,
+		BackupPolicyID: req.BackupPolicyId,
+		Limit:          pageSize(req.PageSize) + 1,
+	}
+	for _, value := range req.States {
+		state, ok := stateOf(value)
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid job state %v", value)
+		}
+		filter.States = append(filter.States, state)
+	}
+	if req.StartedAfter != 0 {
+		filter.StartedAfter = time.Unix(req.StartedAfter, 0)
+	}
+	if req.StartedBefore != 0 {
+		filter.StartedBefore = time.Unix(req.StartedBefore, 0)
+	}
+	if req.PageToken != "" {
+		var err error
+		if filter.AfterStart, filter.AfterJobID, err = decodeJobToken(req.PageToken); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "Invalid page token")
+		}
+	}
+
+	jobs, err := c.ingest.history.List(ctx, filter)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to list backup jobs: %v", err)
+	}
+	resp := &pb.ListJobsResponse{}
+	if len(jobs) == filter.Limit {
+		jobs = jobs[:len(jobs)-1]
+		last := jobs[len(jobs)-1]
+		resp.NextPageToken = encodeJobToken(last.StartTime, last.JobID)
+	}
+	for i := range jobs {
+		resp.Jobs = append(resp.Jobs, jobInfo(&jobs[i]))
+	}
+	return resp, nil
+}
+
+// GetJob describes a backup job with its statistics
+func (c *CatalogServer) GetJob(ctx context.Context, req *pb.GetJobRequest) (*pb.JobInfo, error) {
+	job, err := c.ownedJob(ctx, req.ClientId, req.BackupJobId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Counters of an unfinished job are only recorded in its checkpoints,
+	// unless it runs here
+	stats := job.Stats
+	if job.EndTime == nil {
+		c.ingest.backupMutex.RLock()
+		running := c.ingest.backupJobs[job.JobID]
+		c.ingest.backupMutex.RUnlock()
+		if running != nil {
+			running.mutex.Lock()
+			stats = running.stats()
+			running.mutex.Unlock()
+		} else if checkpoint, err := c.ingest.checkpoints.Get(ctx, job.JobID); err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to read checkpoint of %s: %v", job.JobID, err)
+		} else if checkpoint != nil {
+			stats = checkpoint.JobStats
+		}
+	}
+	entries, bytes, err := c.ingest.manifests.Summary(ctx, job.JobID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to summarize manifest of %s: %v", job.JobID, err)
+	}
+
+	info := jobInfo(job)
+	info.Stats = &pb.JobStats{
+		FilesProcessed:    uint64(stats.FilesProcessed),
+		FilesUnchanged:    uint64(stats.FilesUnchanged),
+		EntriesProcessed:  uint64(stats.EntriesProcessed),
+		ChunksProcessed:   uint64(stats.ChunksProcessed),
+		BytesProcessed:    uint64(stats.BytesProcessed),
+		BytesDeduplicated: uint64(stats.BytesDeduplicated),
+		ChunksDelta:       uint64(stats.ChunksDelta),
+		BytesDeltaSaved:   uint64(stats.BytesDeltaSaved),
+		ManifestEntries:   uint64(entries),
+		ManifestBytes:     uint64(bytes),
+	}
+	return info, nil
+}
+
+// ListFiles lists the entries of a backup job under a path prefix, ordered
+// by path
+func (c *CatalogServer) ListFiles(ctx context.Context, req *pb.ListFilesRequest) (*pb.ListFilesResponse, error) {
+	if _, err := c.ownedJob(ctx, req.ClientId, req.BackupJobId); err != nil {
+		return nil, err
+	}
+	page := db.FileManifestPage{
+		Prefix:       req.Prefix,
+		ChildrenOnly: req.ChildrenOnly,
+		Limit:        pageSize(req.PageSize) + 1,
+	}
+	if page.ChildrenOnly && page.Prefix != "" && !strings.HasSuffix(page.Prefix, "/") {
+		page.Prefix += "/"
+	}
+	if req.PageToken != "" {
+		var err error
+		if page.AfterPath, err = decodePathToken(req.PageToken); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "Invalid page token")
+		}
+	}
+
+	manifests, err := c.ingest.manifests.Page(ctx, req.BackupJobId, page)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to list files of %s: %v", req.BackupJobId, err)
+	}
+	resp := &pb.ListFilesResponse{}
+	if len(manifests) == page.Limit {
+		manifests = manifests[:len(manifests)-1]
+		resp.NextPageToken = encodePathToken(manifests[len(manifests)-1].FilePath)
+	}
+	for i := range manifests {
+		entry, err := fileEntry(&manifests[i])
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to read manifest: %v", err)
+		}
+		resp.Files = append(resp.Files, entry)
+	}
+	return resp, nil
+}
+
+// SearchFiles finds the paths matching a substring or glob in the restorable
+// jobs of a client, ordered by path
+func (c *CatalogServer) SearchFiles(ctx context.Context, req *pb.SearchFilesRequest) (*pb.SearchFilesResponse, error) {
+	search := db.FileSearch{Like: "%" + escapeLike(req.Pattern) + "%", Limit: pageSize(req.PageSize) + 1}
+	if req.Glob {
+		search.Like = globLike(req.Pattern)
+	}
+	if req.PageToken != "" {
+		var err error
+		if search.AfterPath, err = decodePathToken(req.PageToken); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "Invalid page token")
+		}
+	}
+
+	// Each batch of jobs yields its first paths, which include every path
+	// among the first of all jobs, so merging them gives the page
+	starts := make(map[string]time.Time)
+	found := make(map[string][]string)
+	err := c.restorableJobs(ctx, req.ClientId, req.StartedAfter, req.StartedBefore, func(jobs []db.BackupJob) error {
+		search.JobIDs = search.JobIDs[:0]
+		for _, job := range jobs {
+			search.JobIDs = append(search.JobIDs, job.JobID)
+			starts[job.JobID] = job.StartTime
+		}
+		batch, err := c.ingest.manifests.Search(ctx, search)
+		if err != nil {
+			return status.Errorf(codes.Internal, "Failed to search files of %s: %v", req.ClientId, err)
+		}
+		for _, match := range batch {
+			found[match.FilePath] = append(found[match.FilePath], match.JobIDs...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]db.FileMatch, 0, len(found))
+	for path, jobIDs := range found {
+		matches = append(matches, db.FileMatch{FilePath: path, JobIDs: jobIDs})
+	}
+	slices.SortFunc(matches, func(a, b db.FileMatch) int { return strings.Compare(a.FilePath, b.FilePath) })
+	matches = matches[:min(len(matches), search.Limit)]
+
+	resp := &pb.SearchFilesResponse{}
+	if len(matches) == search.Limit {
+		matches = matches[:len(matches)-1]
+		resp.NextPageToken = encodePathToken(matches[len(matches)-1].FilePath)
+	}
+	for _, match := range matches {
+		found := &pb.FileMatch{FilePath: match.FilePath, Backups: uint32(len(match.JobIDs))}
+		var first, last time.Time
+		for _, jobID := range match.JobIDs {
+			start := starts[jobID]
+			if first.IsZero() || start.Before(first) {
+				first = start
+			}
+			if last.IsZero() || start.After(last) || (start.Equal(last) && jobID > found.LatestBackupJobId) {
+				last = start
+				found.LatestBackupJobId = jobID
+			}
+		}
+		found.FirstSeen, found.LastSeen = first.Unix(), last.Unix()
+		resp.Matches = append(resp.Matches, found)
+	}
+	return resp, nil
+}
+
+// ListVersions lists the distinct versions of a path in the restorable jobs
+// of a client, newest first. Consecutive jobs that recorded the same content
+// share a version. Content hashes depend on chunk boundaries, so across a
+// change of chunking profile a file is compared by its metadata instead.
+func (c *CatalogServer) ListVersions(ctx context.Context, req *pb.ListVersionsRequest) (*pb.ListVersionsResponse, error) {
+	if req.FilePath == "" {
+		return nil, status.Error(codes.InvalidArgument, "File path is required")
+	}
+	// Jobs are newest first, so each version starts with the job that last
+	// recorded it and grows back to the job that first did
+	resp := &pb.ListVersionsResponse{}
+	var version *pb.FileVersion
+	var key string
+	var previous *pb.FileEntry // entry of the newer job next to this one
+	var profile int            // chunking profile previous was recorded with
+	err := c.restorableJobs(ctx, req.ClientId, req.StartedAfter, req.StartedBefore, func(jobs []db.BackupJob) error {
+		jobIDs := make([]string, len(jobs))
+		for i, job := range jobs {
+			jobIDs[i] = job.JobID
+		}
+		manifests, err := c.ingest.manifests.Versions(ctx, jobIDs, req.FilePath)
+		if err != nil {
+			return status.Errorf(codes.Internal, "Failed to look up versions of %s: %v", req.FilePath, err)
+		}
+		recorded := make(map[string]*db.FileManifest, len(manifests))
+		for i := range manifests {
+			recorded[manifests[i].JobID] = &manifests[i]
+		}
+
+		for _, job := range jobs {
+			manifest := recorded[job.JobID]
+			if manifest == nil {
+				version = nil
+				continue
+			}
+			entry, err := fileEntry(manifest)
+			if err != nil {
+				return status.Errorf(codes.Internal, "Failed to read manifest: %v", err)
+			}
+			if entry.Type == pb.FileType_FILE_TYPE_REGULAR && entry.ContentHash == "" && entry.LinkTarget == "" {
+				entry.ContentHash = contentHash(manifest.Chunks)
+			}
+			content := strings.Join([]string{entry.Type.String(), entry.ContentHash, entry.LinkTarget}, "\x00")
+			if version == nil || (content != key && (manifest.ChunkingProfile == profile || !unchangedAcrossProfiles(previous, entry))) {
+				version = &pb.FileVersion{Entry: entry, BackupJobId: job.JobID, BackupTime: job.StartTime.Unix()}
+				resp.Versions = append(resp.Versions, version)
+			}
+			key, profile, previous = content, manifest.ChunkingProfile, entry
+			version.FirstBackupJobId = job.JobID
+			version.FirstBackupTime = job.StartTime.Unix()
+			version.Backups++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// unchangedAcrossProfiles reports whether a regular file kept its content
+// between two jobs that chunked it with different profiles, whose content
+// hashes differ either way. Like the stream handler's test for an unchanged
+// file, its size, mtime, inode and status change time must all match.
+func unchangedAcrossProfiles(newer, older *pb.FileEntry) bool {
+	return newer.Type == pb.FileType_FILE_TYPE_REGULAR && older.Type == newer.Type &&
+		older.Inode != 0 && older.CtimeNs != 0 &&
+		older.Size == newer.Size &&
+		older.MtimeNs == newer.MtimeNs &&
+		older.Inode == newer.Inode &&
+		older.CtimeNs == newer.CtimeNs
+}
+
+// DeleteJob deletes an ended backup job of a client with its manifest and
+// checkpoint. The job is forgotten last, so a delete that fails part way can
+// be retried. Chunks stay stored, as other jobs may refer to them.
+func (c *CatalogServer) DeleteJob(ctx context.Context, req *pb.DeleteJobRequest) (*pb.DeleteJobResponse, error) {
+	job, err := c.ownedJob(ctx, req.ClientId, req.BackupJobId)
+	if err != nil {
+		return nil, err
+	}
+	if job.EndTime == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "Backup job %s is %s; only ended jobs can be deleted", job.JobID, job.Status)
+	}
+
+	entries, err := c.ingest.manifests.Delete(ctx, job.JobID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to delete manifest of %s: %v", job.JobID, err)
+	}
+	if err := c.ingest.checkpoints.Delete(ctx, job.JobID); err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to delete checkpoint of %s: %v", job.JobID, err)
+	}
+	err = c.ingest.history.Delete(ctx, job.JobID)
+	if errors.Is(err, db.ErrJobNotEnded) {
+		return nil, status.Errorf(codes.FailedPrecondition, "Backup job %s has not ended", job.JobID)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to delete backup job %s: %v", job.JobID, err)
+	}
+	c.ingest.backupMutex.Lock()
+	delete(c.ingest.backupJobs, job.JobID)
+	c.ingest.backupMutex.Unlock()
+
+	log.Printf("Deleted backup job %s of client %s with %d manifest entries", job.JobID, job.ClientID, entries)
+	return &pb.DeleteJobResponse{EntriesDeleted: uint64(entries)}, nil
+}
+
+// ownedJob returns a recorded backup job of a client
+func (c *CatalogServer) ownedJob(ctx context.Context, clientID, jobID string) (*db.BackupJob, error) {
+	if clientID == "" {
+		return nil, status.Error(codes.InvalidArgument, "Client ID is required")
+	}
+	if jobID == "" {
+		return nil, status.Error(codes.InvalidArgument, "Backup job ID is required")
+	}
+	job, err := c.ingest.history.Get(ctx, jobID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to look up job %s: %v", jobID, err)
+	}
+	if job == nil {
+		return nil, status.Errorf(codes.NotFound, "Backup job %s does not exist", jobID)
+	}
+	if job.ClientID != clientID {
+		return nil, status.Errorf(codes.PermissionDenied, "Backup job %s belongs to another client", jobID)
+	}
+	return job, nil
+}
+
+// restorableJobs passes the jobs of a client started in a time range whose
+// snapshots may be restored to fn, most recently started first, in batches
+// of at most jobBatch read with the keyset cursor of ListJobs
+func (c *CatalogServer) restorableJobs(ctx context.Context, clientID string, startedAfter, startedBefore int64, fn func([]db.BackupJob) error) error {
+	if clientID == "" {
+		return status.Error(codes.InvalidArgument, "Client ID is required")
+	}
+	filter := db.BackupJobFilter{ClientID: clientID, States: jobstate.Successful, Limit: c.jobBatch}
+	if startedAfter != 0 {
+		filter.StartedAfter = time.Unix(startedAfter, 0)
+	}
+	if startedBefore != 0 {
+		filter.StartedBefore = time.Unix(startedBefore, 0)
+	}
+	for {
+		jobs, err := c.ingest.history.List(ctx, filter)
+		if err != nil {
+			return status.Errorf(codes.Internal, "Failed to list backup jobs of %s: %v", clientID, err)
+		}
+		if len(jobs) == 0 {
+			return nil
+		}
+		if err := fn(jobs); err != nil {
+			return err
+		}
+		if len(jobs) < filter.Limit {
+			return nil
+		}
+		last := jobs[len(jobs)-1]
+		filter.AfterStart, filter.AfterJobID = last.StartTime, last.JobID
+	}
+}
+
+// jobInfo describes a recorded job, without its statistics
+func jobInfo(job *db.BackupJob) *pb.JobInfo {
+	info := &pb.JobInfo{
+		BackupJobId:    job.JobID,
+		ClientId:       job.ClientID,
+		BackupPolicyId: job.BackupPolicyID,
+		SourceType:     job.SourceType,
+		SourceDetails:  job.SourceDetails,
+		StartTime:      job.StartTime.Unix(),
+		State:          jobStates[job.Status],
+		FailureReason:  failureReasons[job.FailureReason],
+	}
+	if job.EndTime != nil {
+		info.EndTime = job.EndTime.Unix()
+	}
+	return info
+}
+
+// pageSize returns the number of items a page of a listing holds
+func pageSize(requested uint32) int {
+	if requested == 0 {
+		return defaultPageSize
+	}
+	return min(int(requested), maxPageSize)
+}
+
+// encodeJobToken returns the page token of a ListJobs page that continues
+// after a job
+func encodeJobToken(start time.Time, jobID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(start.UnixNano(), 10) + "/" + jobID))
+}
+
+// decodeJobToken returns the job a ListJobs page token continues after
+func decodeJobToken(token string) (time.Time, string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	nanos, jobID, ok := strings.Cut(string(data), "/")
+	if !ok {
+		return time.Time{}, "", errors.New("malformed page token")
+	}
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	return time.Unix(0, unixNano), jobID, nil
+}
+
+// encodePathToken returns the page token of a page of paths that continues
+// after filePath
+func encodePathToken(filePath string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(filePath))
+}
+
+// decodePathToken returns the path a page token of paths continues after
+func decodePathToken(token string) (string, error) {
+	filePath, err := base64.RawURLEncoding.DecodeString(token)
+	return string(filePath), err
+}
+
+// escapeLike escapes the characters of s that are special in a SQL LIKE
+// pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// globLike translates a glob over whole paths into a SQL LIKE pattern: * is
+// any characters, including /, and ? any one character
+func globLike(glob string) string {
+	return strings.NewReplacer("*", "%", "?", "_").Replace(escapeLike(glob))
+}
+
+// stateOf returns the job state of a protocol value
+func stateOf(value pb.JobState) (jobstate.State, bool) {
+	for state, v := range jobStates {
+		if v == value {
+			return state, true
+		}
+	}
+	return "", false
+}
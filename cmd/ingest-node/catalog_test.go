@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+func TestCatalogListJobs(t *testing.T) {
+	ctx := context.Background()
+	server := NewIngestServer("0")
+	catalog := NewCatalogServer(server)
+
+	for i, jobID := range []string{"job-1", "job-2", "job-3", "job-4"} {
+		clientID := "client-a"
+		if i == 1 {
+			clientID = "client-b"
+		}
+		requests := []*pb.BackupRequest{backupStart(clientID, jobID, "", int64(1000*(i+1)))}
+		if i < 3 {
+			requests = append(requests, backupEnd(jobID, "COMPLETED"))
+		}
+		if err := server.StreamBackup(&fakeBackupStream{requests: requests}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Pages follow each other, most recently started first
+	var listed []string
+	token := ""
+	for pages := 0; ; pages++ {
+		resp, err := catalog.ListJobs(ctx, &pb.ListJobsRequest{ClientId: "client-a", PageSize: 2, PageToken: token})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, job := range resp.Jobs {
+			listed = append(listed, job.BackupJobId)
+		}
+		if token = resp.NextPageToken; token == "" {
+			break
+		}
+		if pages > 2 {
+			t.Fatal("Listing does not end")
+		}
+	}
+	if len(listed) != 3 || listed[0] != "job-4" || listed[1] != "job-3" || listed[2] != "job-1" {
+		t.Errorf("Unexpected jobs of client-a: %v", listed)
+	}
+
+	for name, tc := range map[string]struct {
+		req      *pb.ListJobsRequest
+		expected int
+	}{
+		"running":   {&pb.ListJobsRequest{ClientId: "client-a", States: []pb.JobState{pb.JobState_JOB_STATE_RUNNING}}, 1},
+		"completed": {&pb.ListJobsRequest{ClientId: "client-a", States: []pb.JobState{pb.JobState_JOB_STATE_COMPLETED}}, 2},
+		"time":      {&pb.ListJobsRequest{ClientId: "client-a", StartedAfter: 2000, StartedBefore: 4000}, 1},
+		"unknown":   {&pb.ListJobsRequest{ClientId: "client-c"}, 0},
+	} {
+		resp, err := catalog.ListJobs(ctx, tc.req)
+		if err != nil || len(resp.Jobs) != tc.expected || resp.NextPageToken != "" {
+			t.Errorf("%s: expected %d jobs, got %v, %v", name, tc.expected, resp, err)
+		}
+	}
+	if _, err := catalog.ListJobs(ctx, &pb.ListJobsRequest{ClientId: "client-a", PageToken: "!"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected an invalid page token to be rejected, got %v", err)
+	}
+
+	// Jobs are only listed for the client that asks
+	if _, err := catalog.ListJobs(ctx, &pb.ListJobsRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected a listing without a client to be rejected, got %v", err)
+	}
+	resp, err := catalog.ListJobs(ctx, &pb.ListJobsRequest{ClientId: "client-b"})
+	if err != nil || len(resp.Jobs) != 1 || resp.Jobs[0].BackupJobId != "job-2" || resp.Jobs[0].ClientId != "client-b" {
+		t.Errorf("Unexpected jobs of client-b: %v, %v", resp, err)
+	}
+}
+
+func TestCatalogFilesAndDelete(t *testing.T) {
+	ctx := context.Background()
+	server := NewIngestServer("0")
+	catalog := NewCatalogServer(server)
+
+	requests := []*pb.BackupRequest{backupStart("client-a", "job-1", "", 1000)}
+	for _, entry := range []*pb.FileEntry{
+		{FilePath: "/src", Type: pb.FileType_FILE_TYPE_DIRECTORY, Mode: 0o755},
+		{FilePath: "/src/a", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o644, Size: 5},
+		{FilePath: "/src/b", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o644, Size: 3},
+		{FilePath: "/src/sub", Type: pb.FileType_FILE_TYPE_DIRECTORY, Mode: 0o755},
+		{FilePath: "/src/sub/c", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o644, Size: 4},
+	} {
+		requests = append(requests, &pb.BackupRequest{RequestType: &pb.BackupRequest_FileEntry{FileEntry: entry}})
+		if entry.Type == pb.FileType_FILE_TYPE_REGULAR {
+			requests = append(requests, segment(entry.FilePath, []byte("hello")[:entry.Size], 0, true))
+		}
+	}
+	requests = append(requests, backupEnd("job-1", "COMPLETED"))
+	if err := server.StreamBackup(&fakeBackupStream{requests: requests}); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := catalog.GetJob(ctx, &pb.GetJobRequest{ClientId: "client-a", BackupJobId: "job-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != pb.JobState_JOB_STATE_COMPLETED || job.EndTime == 0 || job.Stats.FilesProcessed != 3 ||
+		job.Stats.EntriesProcessed != 2 || job.Stats.BytesProcessed != 12 || job.Stats.ManifestEntries != 5 || job.Stats.ManifestBytes != 12 {
+		t.Errorf("Unexpected job: %v", job)
+	}
+
+	for name, tc := range map[string]struct {
+		req      *pb.ListFilesRequest
+		expected []string
+	}{
+		"all":      {&pb.ListFilesRequest{}, []string{"/src", "/src/a", "/src/b", "/src/sub", "/src/sub/c"}},
+		"prefix":   {&pb.ListFilesRequest{Prefix: "/src/sub"}, []string{"/src/sub", "/src/sub/c"}},
+		"children": {&pb.ListFilesRequest{Prefix: "/src", ChildrenOnly: true}, []string{"/src/a", "/src/b", "/src/sub"}},
+		"paged":    {&pb.ListFilesRequest{Prefix: "/src/", PageSize: 3}, []string{"/src/a", "/src/b", "/src/sub", "/src/sub/c"}},
+	} {
+		req := tc.req
+		req.ClientId, req.BackupJobId = "client-a", "job-1"
+		var listed []string
+		for {
+			resp, err := catalog.ListFiles(ctx, req)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			for _, entry := range resp.Files {
+				listed = append(listed, entry.FilePath)
+			}
+			if resp.NextPageToken == "" {
+				break
+			}
+			req.PageToken = resp.NextPageToken
+		}
+		if len(listed) != len(tc.expected) {
+			t.Errorf("%s: expected %v, got %v", name, tc.expected, listed)
+			continue
+		}
+		for i := range listed {
+			if listed[i] != tc.expected[i] {
+				t.Errorf("%s: expected %v, got %v", name, tc.expected, listed)
+				break
+			}
+		}
+	}
+
+	// Only the owner may read a job
+	for _, clientID := range []string{"", "client-b"} {
+		want := codes.PermissionDenied
+		if clientID == "" {
+			want = codes.InvalidArgument
+		}
+		if _, err := catalog.GetJob(ctx, &pb.GetJobRequest{ClientId: clientID, BackupJobId: "job-1"}); status.Code(err) != want {
+			t.Errorf("GetJob by %q: expected %v, got %v", clientID, want, err)
+		}
+		if _, err := catalog.ListFiles(ctx, &pb.ListFilesRequest{ClientId: clientID, BackupJobId: "job-1"}); status.Code(err) != want {
+			t.Errorf("ListFiles by %q: expected %v, got %v", clientID, want, err)
+		}
+	}
+
+	// Only the owner may delete an ended job
+	_, upload := startJob(t, server, "client-a", "job-2")
+	defer upload.close()
+	for _, tc := range []struct {
+		req  *pb.DeleteJobRequest
+		code codes.Code
+	}{
+		{&pb.DeleteJobRequest{BackupJobId: "job-1"}, codes.InvalidArgument},
+		{&pb.DeleteJobRequest{ClientId: "client-b", BackupJobId: "job-1"}, codes.PermissionDenied},
+		{&pb.DeleteJobRequest{ClientId: "client-a", BackupJobId: "job-2"}, codes.FailedPrecondition},
+		{&pb.DeleteJobRequest{ClientId: "client-a", BackupJobId: "job-9"}, codes.NotFound},
+	} {
+		if _, err := catalog.DeleteJob(ctx, tc.req); status.Code(err) != tc.code {
+			t.Errorf("DeleteJob(%v): expected %v, got %v", tc.req, tc.code, err)
+		}
+	}
+	resp, err := catalog.DeleteJob(ctx, &pb.DeleteJobRequest{ClientId: "client-a", BackupJobId: "job-1"})
+	if err != nil || resp.EntriesDeleted != 5 {
+		t.Fatalf("Unexpected delete result %v, %v", resp, err)
+	}
+	if _, err := catalog.GetJob(ctx, &pb.GetJobRequest{ClientId: "client-a", BackupJobId: "job-1"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected the deleted job to be gone, got %v", err)
+	}
+	if _, err := server.InitiateRestore(ctx, &pb.RestoreRequest{ClientId: "client-a", BackupJobId: "job-1"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected a deleted job not to be restorable, got %v", err)
+	}
+}
+
+func TestCatalogSearchAndVersions(t *testing.T) {
+	ctx := context.Background()
+	server := NewIngestServer("0")
+	catalog := NewCatalogServer(server)
+	catalog.jobBatch = 3 // job-1 is read in a batch of its own
+
+	// The configuration changes in job-3 and changes back in job-4; job-2
+	// only touches it
+	for i, tc := range []struct {
+		jobID, status, content string
+		mtime                  int64
+	}{
+		{"job-1", "COMPLETED", "listen 80", 100},
+		{"job-2", "COMPLETED", "listen 80", 200},
+		{"job-3", "COMPLETED", "listen 8080", 300},
+		{"job-4", "COMPLETED_WITH_ERRORS", "listen 80", 400},
+		{"job-5", "FAILED", "listen 443", 500},
+	} {
+		requests := []*pb.BackupRequest{backupStart("client-a", tc.jobID, "", int64(1000*(i+1)))}
+		for _, filePath := range []string{"/etc/nginx/nginx.conf", "/etc/nginx/conf.d/site_1.conf"} {
+			entry := &pb.FileEntry{FilePath: filePath, Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o644, MtimeNs: tc.mtime * 1e9, Size: uint64(len(tc.content))}
+			requests = append(requests,
+				&pb.BackupRequest{RequestType: &pb.BackupRequest_FileEntry{FileEntry: entry}},
+				segment(filePath, []byte(tc.content), 0, true))
+		}
+		if i == 0 {
+			requests = append(requests, &pb.BackupRequest{RequestType: &pb.BackupRequest_FileEntry{FileEntry: &pb.FileEntry{
+				FilePath: "/etc/hosts", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o644}}}, segment("/etc/hosts", nil, 0, true))
+		}
+		requests = append(requests, backupEnd(tc.jobID, tc.status))
+		if err := server.StreamBackup(&fakeBackupStream{requests: requests}); err != nil {
+			t.Fatalf("%s: %v", tc.jobID, err)
+		}
+	}
+	requests := []*pb.BackupRequest{backupStart("client-b", "job-b", "", 1000),
+		{RequestType: &pb.BackupRequest_FileEntry{FileEntry: &pb.FileEntry{FilePath: "/etc/nginx/nginx.conf", Type: pb.FileType_FILE_TYPE_DIRECTORY}}},
+		backupEnd("job-b", "COMPLETED")}
+	if err := server.StreamBackup(&fakeBackupStream{requests: requests}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := catalog.ListVersions(ctx, &pb.ListVersionsRequest{ClientId: "client-a", FilePath: "/etc/nginx/nginx.conf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		latest, first string
+		backups       uint32
+		mtime         int64
+	}{
+		{"job-4", "job-4", 1, 400},
+		{"job-3", "job-3", 1, 300},
+		{"job-2", "job-1", 2, 200},
+	}
+	if len(resp.Versions) != len(expected) {
+		t.Fatalf("Expected %d versions, got %v", len(expected), resp.Versions)
+	}
+	for i, version := range resp.Versions {
+		e := expected[i]
+		if version.BackupJobId != e.latest || version.FirstBackupJobId != e.first || version.Backups != e.backups ||
+			version.Entry.MtimeNs != e.mtime*1e9 || version.Entry.ContentHash == "" {
+			t.Errorf("Version %d: expected %+v, got %v", i, e, version)
+		}
+	}
+	if resp.Versions[0].Entry.ContentHash != resp.Versions[2].Entry.ContentHash || resp.Versions[0].Entry.ContentHash == resp.Versions[1].Entry.ContentHash {
+		t.Error("Expected versions with the same content to have the same hash")
+	}
+
+	// Time bounds select the versions from before a job
+	resp, err = catalog.ListVersions(ctx, &pb.ListVersionsRequest{ClientId: "client-a", FilePath: "/etc/nginx/nginx.conf", StartedBefore: 3000})
+	if err != nil || len(resp.Versions) != 1 || resp.Versions[0].BackupJobId != "job-2" || resp.Versions[0].BackupTime != 2000 {
+		t.Errorf("Unexpected versions before job-3: %v, %v", resp, err)
+	}
+
+	for name, tc := range map[string]struct {
+		req      *pb.SearchFilesRequest
+		expected []string
+	}{
+		"substring": {&pb.SearchFilesRequest{Pattern: "nginx"}, []string{"/etc/nginx/conf.d/site_1.conf", "/etc/nginx/nginx.conf"}},
+		"literal":   {&pb.SearchFilesRequest{Pattern: "_1."}, []string{"/etc/nginx/conf.d/site_1.conf"}},
+		"glob":      {&pb.SearchFilesRequest{Pattern: "/etc/*.conf", Glob: true}, []string{"/etc/nginx/conf.d/site_1.conf", "/etc/nginx/nginx.conf"}},
+		"anchored":  {&pb.SearchFilesRequest{Pattern: "/etc/h?sts", Glob: true}, []string{"/etc/hosts"}},
+		"unmatched": {&pb.SearchFilesRequest{Pattern: "nginx.conf", Glob: true}, nil},
+		"time":      {&pb.SearchFilesRequest{StartedAfter: 2000}, []string{"/etc/nginx/conf.d/site_1.conf", "/etc/nginx/nginx.conf"}},
+		"paged":     {&pb.SearchFilesRequest{PageSize: 1}, []string{"/etc/hosts", "/etc/nginx/conf.d/site_1.conf", "/etc/nginx/nginx.conf"}},
+	} {
+		req := tc.req
+		req.ClientId = "client-a"
+		var found []string
+		for {
+			resp, err := catalog.SearchFiles(ctx, req)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			for _, match := range resp.Matches {
+				found = append(found, match.FilePath)
+			}
+			if resp.NextPageToken == "" {
+				break
+			}
+			req.PageToken = resp.NextPageToken
+		}
+		if strings.Join(found, ",") != strings.Join(tc.expected, ",") {
+			t.Errorf("%s: expected %v, got %v", name, tc.expected, found)
+		}
+	}
+
+	search, err := catalog.SearchFiles(ctx, &pb.SearchFilesRequest{ClientId: "client-a", Pattern: "nginx.conf"})
+	if err != nil || len(search.Matches) != 1 {
+		t.Fatalf("Unexpected search result %v, %v", search, err)
+	}
+	if match := search.Matches[0]; match.Backups != 4 || match.FirstSeen != 1000 || match.LastSeen != 4000 || match.LatestBackupJobId != "job-4" {
+		t.Errorf("Unexpected match %v", match)
+	}
+	if _, err := catalog.SearchFiles(ctx, &pb.SearchFilesRequest{Pattern: "nginx"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected a search without a client to be rejected, got %v", err)
+	}
+}
+
+func TestCatalogVersionsAcrossProfiles(t *testing.T) {
+	ctx := context.Background()
+	server := NewIngestServer("0")
+	catalog := NewCatalogServer(server)
+	active := server.chunker.Profile()
+	if _, err := server.policies.Set(ctx, policyKey{sourceType: "vm"}, chunking.FixedProfile(4096, active.Hash), active); err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 64*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+	changed := append([]byte("header"), data...)
+
+	// job-2 chunks the same file with another profile; job-3 changes it
+	for i, tc := range []struct {
+		jobID, sourceType string
+		content           []byte
+		mtime             int64
+	}{
+		{"job-1", "filesystem", data, 100},
+		{"job-2", "vm", data, 100},
+		{"job-3", "filesystem", changed, 300},
+	} {
+		entry := &pb.FileEntry{FilePath: "/disk.img", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o600,
+			MtimeNs: tc.mtime * 1e9, CtimeNs: tc.mtime * 1e9, Inode: 12, Size: uint64(len(tc.content))}
+		requests := []*pb.BackupRequest{
+			{RequestType: &pb.BackupRequest_StartBackup{StartBackup: &pb.BackupStart{
+				ClientId: "client-a", BackupJobId: tc.jobID, Timestamp: int64(1000 * (i + 1)), SourceType: tc.sourceType}}},
+			{RequestType: &pb.BackupRequest_FileEntry{FileEntry: entry}},
+			segment(entry.FilePath, tc.content, 0, true),
+			backupEnd(tc.jobID, "COMPLETED"),
+		}
+		if err := server.StreamBackup(&fakeBackupStream{requests: requests}); err != nil {
+			t.Fatalf("%s: %v", tc.jobID, err)
+		}
+	}
+
+	resp, err := catalog.ListFiles(ctx, &pb.ListFilesRequest{ClientId: "client-a", BackupJobId: "job-1"})
+	if err != nil || len(resp.Files) != 1 {
+		t.Fatalf("Unexpected files of job-1: %v, %v", resp, err)
+	}
+	first := resp.Files[0].ContentHash
+	if resp, err = catalog.ListFiles(ctx, &pb.ListFilesRequest{ClientId: "client-a", BackupJobId: "job-2"}); err != nil || len(resp.Files) != 1 {
+		t.Fatalf("Unexpected files of job-2: %v, %v", resp, err)
+	}
+	if resp.Files[0].ContentHash == first {
+		t.Fatal("Expected the content hash to depend on the chunking profile")
+	}
+
+	versions, err := catalog.ListVersions(ctx, &pb.ListVersionsRequest{ClientId: "client-a", FilePath: "/disk.img"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions.Versions) != 2 {
+		t.Fatalf("Expected 2 versions, got %v", versions.Versions)
+	}
+	if v := versions.Versions[0]; v.BackupJobId != "job-3" || v.Backups != 1 {
+		t.Errorf("Expected the changed file in job-3, got %v", v)
+	}
+	if v := versions.Versions[1]; v.BackupJobId != "job-2" || v.FirstBackupJobId != "job-1" || v.Backups != 2 {
+		t.Errorf("Expected job-1 and job-2 to share a version, got %v", v)
+	}
+}
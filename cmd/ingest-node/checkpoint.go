@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/archive"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/db"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/jobstate"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// checkpointStore keeps the checkpoints of unfinished backup jobs, in
+// CockroachDB when it is configured and in memory otherwise
+type checkpointStore struct {
+	dbClient *db.DB
+
+	mutex       sync.Mutex
+	checkpoints map[string]*db.BackupCheckpoint
+}
+
+// newCheckpointStore creates a checkpoint store; dbClient may be nil
+func newCheckpointStore(dbClient *db.DB) *checkpointStore {
+	return &checkpointStore{dbClient: dbClient, checkpoints: make(map[string]*db.BackupCheckpoint)}
+}
+
+// Put replaces the checkpoint of a job
+func (c *checkpointStore) Put(ctx context.Context, checkpoint *db.BackupCheckpoint) error {
+	if c.dbClient != nil {
+		return c.dbClient.UpsertBackupCheckpoint(ctx, checkpoint)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	checkpoint.UpdatedTime = time.Now()
+	c.checkpoints[checkpoint.JobID] = checkpoint
+	return nil
+}
+
+// Get returns the checkpoint of a job, or nil if it has none
+func (c *checkpointStore) Get(ctx context.Context, jobID string) (*db.BackupCheckpoint, error) {
+	if c.dbClient != nil {
+		return c.dbClient.GetBackupCheckpoint(ctx, jobID)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.checkpoints[jobID], nil
+}
+
+// Delete removes the checkpoint of a finished job
+func (c *checkpointStore) Delete(ctx context.Context, jobID string) error {
+	if c.dbClient != nil {
+		return c.dbClient.DeleteBackupCheckpoint(ctx, jobID)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.checkpoints, jobID)
+	return nil
+}
+
+// stats returns the counters of a job; job.mutex must be held
+func (job *BackupJobState) stats() db.JobStats {
+	return db.JobStats{
+		FilesProcessed:    int64(job.FilesProcessed),
+		FilesUnchanged:    int64(job.FilesUnchanged),
+		EntriesProcessed:  int64(job.EntriesProcessed),
+		ChunksProcessed:   int64(job.ChunksProcessed),
+		BytesProcessed:    job.BytesProcessed,
+		BytesDeduplicated: job.BytesDeduplicated,
+		ChunksDelta:       int64(job.ChunksDelta),
+		BytesDeltaSaved:   job.BytesDeltaSaved,
+	}
+}
+
+// checkpoint records the progress of a job: its counters, the last entry
+// recorded in its manifest and, if file is set, the chunks stored so far for
+// the partial file. Open containers are sealed first so every chunk the
+// checkpoint refers to is durable.
+func (s *IngestServer) checkpoint(ctx context.Context, job *BackupJobState, partial string, file *pendingFile) error {
+	if err := s.flushContainer(ctx); err != nil {
+		return status.Errorf(codes.Internal, "Failed to seal container: %v", err)
+	}
+
+	job.mutex.Lock()
+	checkpoint := &db.BackupCheckpoint{
+		JobID:     job.JobID,
+		BaseJobID: job.BaseJobID,
+		LastFile:  job.LastEntry,
+		JobStats:  job.stats(),
+	}
+	job.mutex.Unlock()
+	if file != nil {
+		checkpoint.PartialFile = partial
+		checkpoint.PartialOffset = file.size
+		checkpoint.PartialChunks = append([]string(nil), file.refs...)
+		if file.entry != nil {
+			data, err := proto.Marshal(file.entry)
+			if err != nil {
+				return status.Errorf(codes.Internal, "Failed to encode entry %s: %v", partial, err)
+			}
+			checkpoint.PartialEntry = data
+		}
+		if file.archive != nil {
+			state, err := file.archive.MarshalBinary()
+			if err != nil {
+				return status.Errorf(codes.Internal, "Failed to encode archive state of %s: %v", partial, err)
+			}
+			checkpoint.PartialArchive = state
+		}
+		file.checkpointed = file.size
+	}
+	if err := s.checkpoints.Put(ctx, checkpoint); err != nil {
+		return status.Errorf(codes.Internal, "Failed to checkpoint job %s: %v", job.JobID, err)
+	}
+	job.mutex.Lock()
+	job.lastCheckpoint = time.Now()
+	job.mutex.Unlock()
+	return nil
+}
+
+// entryDone notes that an entry is recorded in the job manifest and
+// checkpoints the job when the checkpoint interval has passed. Jobs uploaded
+// over parallel streams record entries out of walk order and are not
+// checkpointed.
+func (s *IngestServer) entryDone(ctx context.Context, job *BackupJobState, filePath string) error {
+	job.mutex.Lock()
+	job.LastEntry = filePath
+	due := job.streams == 1 && time.Since(job.lastCheckpoint) >= s.checkpointInterval
+	job.mutex.Unlock()
+	if !due {
+		return nil
+	}
+	return s.checkpoint(ctx, job, "", nil)
+}
+
+// checkpointPartial checkpoints a file still being received once enough of
+// it has been stored since its last checkpoint
+func (s *IngestServer) checkpointPartial(ctx context.Context, job *BackupJobState, filePath string, file *pendingFile) error {
+	if file.part != nil || file.size-file.checkpointed < s.checkpointBytes {
+		return nil
+	}
+	job.mutex.Lock()
+	parallel := job.streams > 1
+	job.mutex.Unlock()
+	if parallel {
+		return nil
+	}
+	return s.checkpoint(ctx, job, filePath, file)
+}
+
+// resumeJob restores the state of an interrupted job from its checkpoint
+// and returns the point the client should continue from
+func (s *IngestServer) resumeJob(ctx context.Context, upload *jobUpload, start *pb.BackupStart) (*pb.ResumePoint, error) {
+	job := upload.job
+	recorded, err := s.history.Get(ctx, start.BackupJobId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to look up job %s: %v", start.BackupJobId, err)
+	}
+	if recorded == nil {
+		return nil, status.Errorf(codes.NotFound, "Backup job %s does not exist", start.BackupJobId)
+	}
+	if recorded.ClientID != start.ClientId {
+		return nil, status.Errorf(codes.PermissionDenied, "Backup job %s belongs to another client", start.BackupJobId)
+	}
+	if recorded.Status.Terminal() {
+		return nil, status.Errorf(codes.FailedPrecondition, "Backup job %s has already ended as %s", start.BackupJobId, recorded.Status)
+	}
+	if recorded.Status == jobstate.Finalizing {
+		return nil, status.Errorf(codes.FailedPrecondition, "Backup job %s is already finalizing", start.BackupJobId)
+	}
+	job.StartTime = recorded.StartTime
+	job.State = recorded.Status
+
+	// Keep the reaper off the job while it resumes
+	if err := s.history.Touch(ctx, []string{start.BackupJobId}); err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to record heartbeat of %s: %v", start.BackupJobId, err)
+	}
+
+	point := &pb.ResumePoint{BackupJobId: start.BackupJobId}
+	checkpoint, err := s.checkpoints.Get(ctx, start.BackupJobId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to read checkpoint of %s: %v", start.BackupJobId, err)
+	}
+	if checkpoint == nil {
+		return point, nil // nothing durable yet, start over
+	}
+	if checkpoint.BaseJobID != start.BaseJobId {
+		return nil, status.Errorf(codes.FailedPrecondition, "Backup job %s was started on base job %q, not %q",
+			start.BackupJobId, checkpoint.BaseJobID, start.BaseJobId)
+	}
+
+	job.LastEntry = checkpoint.LastFile
+	job.FilesProcessed = int(checkpoint.FilesProcessed)
+	job.FilesUnchanged = int(checkpoint.FilesUnchanged)
+	job.EntriesProcessed = int(checkpoint.EntriesProcessed)
+	job.ChunksProcessed = int(checkpoint.ChunksProcessed)
+	job.BytesProcessed = checkpoint.BytesProcessed
+	job.BytesDeduplicated = checkpoint.BytesDeduplicated
+	job.ChunksDelta = int(checkpoint.ChunksDelta)
+	job.BytesDeltaSaved = checkpoint.BytesDeltaSaved
+	point.LastFile = checkpoint.LastFile
+
+	if checkpoint.PartialFile != "" {
+		file := &pendingFile{
+			refs:         checkpoint.PartialChunks,
+			size:         checkpoint.PartialOffset,
+			checkpointed: checkpoint.PartialOffset,
+			resumed:      true,
+		}
+		if len(checkpoint.PartialEntry) > 0 {
+			file.entry = &pb.FileEntry{}
+			if err := proto.Unmarshal(checkpoint.PartialEntry, file.entry); err != nil {
+				return nil, status.Errorf(codes.Internal, "Failed to decode entry %s: %v", checkpoint.PartialFile, err)
+			}
+		}
+		// The splitter continues where it stopped, past the buffered data
+		// the client sends again
+		if len(checkpoint.PartialArchive) > 0 && start.Archives {
+			file.archive = &archive.Splitter{}
+			err := file.archive.UnmarshalBinary(checkpoint.PartialArchive)
+			if err == nil && file.archive.Offset() < checkpoint.PartialOffset {
+				err = fmt.Errorf("splitter at offset %d is behind the checkpoint", file.archive.Offset())
+			}
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "Failed to decode archive state of %s: %v", checkpoint.PartialFile, err)
+			}
+			file.archiveSeen = file.archive.Offset() - checkpoint.PartialOffset
+		}
+		upload.files[checkpoint.PartialFile] = file
+		point.PartialFile = checkpoint.PartialFile
+		point.PartialOffset = uint64(checkpoint.PartialOffset)
+		point.PartialEntry = file.entry
+	}
+	return point, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+func TestResumeFromCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	server := NewIngestServer("0")
+	server.checkpointBytes = 1024
+
+	content := make([]byte, 6000)
+	for i := range content {
+		content[i] = byte(i*31%251 + 1)
+	}
+	small := &pb.FileEntry{FilePath: "/src/a", Type: pb.FileType_FILE_TYPE_REGULAR, Size: 2}
+	big := &pb.FileEntry{FilePath: "/src/big", Type: pb.FileType_FILE_TYPE_REGULAR, Size: uint64(len(content))}
+	bigSegment := func(offset int, last bool) *pb.BackupRequest {
+		req := segment(big.FilePath, content[offset:min(offset+1000, len(content))], 0, last)
+		req.GetFileSegment().Offset = uint64(offset)
+		return req
+	}
+
+	// The stream breaks after five segments of the big file
+	interrupted := []*pb.BackupRequest{
+		backupStart("client-a", "job-1", "", 1000),
+		{RequestType: &pb.BackupRequest_FileEntry{FileEntry: small}},
+		segment(small.FilePath, []byte("hi"), 0, true),
+		{RequestType: &pb.BackupRequest_FileEntry{FileEntry: big}},
+	}
+	for offset := 0; offset < 5000; offset += 1000 {
+		interrupted = append(interrupted, bigSegment(offset, false))
+	}
+	if err := server.StreamBackup(&fakeBackupStream{requests: interrupted}); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, _ := server.checkpoints.Get(ctx, "job-1")
+	if checkpoint == nil || checkpoint.LastFile != small.FilePath || checkpoint.PartialFile != big.FilePath || checkpoint.PartialOffset != 4000 {
+		t.Fatalf("Unexpected checkpoint: %+v", checkpoint)
+	}
+
+	// Resuming must continue exactly at the checkpointed offset
+	resume := backupStart("client-a", "job-1", "", 1000)
+	resume.GetStartBackup().Resume = true
+	stream := &fakeBackupStream{requests: []*pb.BackupRequest{resume, bigSegment(5000, true)}}
+	if err := server.StreamBackup(stream); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected a segment past the resume point to be rejected, got %v", err)
+	}
+	point := stream.responses[0].GetResumePoint()
+	if point == nil || point.LastFile != small.FilePath || point.PartialOffset != 4000 || point.PartialEntry.GetSize() != big.Size {
+		t.Fatalf("Unexpected resume point: %v", stream.responses[0])
+	}
+
+	stream = &fakeBackupStream{requests: []*pb.BackupRequest{resume, bigSegment(4000, false), bigSegment(5000, true), backupEnd("job-1", "COMPLETED")}}
+	if err := server.StreamBackup(stream); err != nil {
+		t.Fatalf("Resumed backup failed: %v", err)
+	}
+	manifests, _ := server.manifests.List(ctx, "job-1")
+	if len(manifests) != 2 || manifests[0].Size != 2 || manifests[1].Size != int64(len(content)) {
+		t.Fatalf("Unexpected manifests after resume: %+v", manifests)
+	}
+	if job, _ := server.history.Get(ctx, "job-1"); job.Stats.FilesProcessed != 2 || job.Stats.BytesProcessed != int64(len(content))+2 {
+		t.Errorf("Counters not restored from the checkpoint: %+v", job.Stats)
+	}
+	if checkpoint, _ := server.checkpoints.Get(ctx, "job-1"); checkpoint != nil {
+		t.Error("Expected the checkpoint to be removed once the job finished")
+	}
+
+	// Only unfinished jobs of the same client can be resumed
+	if err := server.StreamBackup(&fakeBackupStream{requests: []*pb.BackupRequest{resume}}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected a finished job to be rejected, got %v", err)
+	}
+	other := backupStart("client-b", "job-1", "", 1000)
+	other.GetStartBackup().Resume = true
+	if err := server.StreamBackup(&fakeBackupStream{requests: []*pb.BackupRequest{other}}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected another client's job to be rejected, got %v", err)
+	}
+}
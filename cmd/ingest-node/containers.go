@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/db"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/erasure"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/placement"
+)
+
+// containerPacker packs new chunks into fixed-size containers and writes
+// each container erasure-coded once it is sealed
+type containerPacker struct {
+	store *erasure.Store
+	size  int
+
+	mutex   sync.Mutex
+	buffer  []byte
+	chunks  []packedChunk
+	pending map[chunking.Fingerprint]bool // fingerprints waiting in the open container
+}
+
+// packedChunk locates a chunk inside a container
+type packedChunk struct {
+	chunk  chunking.Chunk
+	offset int64
+}
+
+// sealedContainer is a container written to its shard targets
+type sealedContainer struct {
+	layout *erasure.ContainerLayout
+	chunks []packedChunk
+}
+
+// newContainerPacker creates a packer sealing containers of about size bytes
+func newContainerPacker(store *erasure.Store, size int) *containerPacker {
+	return &containerPacker{
+		store:   store,
+		size:    size,
+		pending: make(map[chunking.Fingerprint]bool),
+	}
+}
+
+// Add appends chunks to the open container and returns any containers that
+// filled up and were sealed. Chunks already waiting in the open container
+// are skipped.
+func (p *containerPacker) Add(ctx context.Context, chunks []chunking.Chunk) ([]sealedContainer, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var sealed []sealedContainer
+	for _, chunk := range chunks {
+		if p.pending[chunk.Fingerprint] {
+			continue
+		}
+		p.chunks = append(p.chunks, packedChunk{
+			chunk:  chunking.Chunk{Fingerprint: chunk.Fingerprint, Size: chunk.Size},
+			offset: int64(len(p.buffer)),
+		})
+		p.buffer = append(p.buffer, chunk.Data...)
+		p.pending[chunk.Fingerprint] = true
+
+		if len(p.buffer) >= p.size {
+			container, err := p.seal(ctx)
+			if err != nil {
+				return sealed, err
+			}
+			sealed = append(sealed, *container)
+		}
+	}
+	return sealed, nil
+}
+
+// Flush seals the open container, if it holds any chunks
+func (p *containerPacker) Flush(ctx context.Context) (*sealedContainer, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if len(p.chunks) == 0 {
+		return nil, nil
+	}
+	return p.seal(ctx)
+}
+
+// seal writes the open container and starts a new one. On failure the
+// container is dropped so that a bad write cannot block later backups.
+func (p *containerPacker) seal(ctx context.Context) (*sealedContainer, error) {
+	data, chunks := p.buffer, p.chunks
+	p.buffer = make([]byte, 0, p.size)
+	p.chunks = nil
+	p.pending = make(map[chunking.Fingerprint]bool)
+
+	containerID := "ctr-" + chunking.ComputeFingerprint(data).String()[:32]
+	layout, err := p.store.Put(ctx, containerID, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal container %s with %d chunks: %w", containerID, len(chunks), err)
+	}
+	log.Printf("Sealed container %s: %d chunks, %d bytes, %d+%d shards",
+		containerID, len(chunks), len(data), layout.DataShards, layout.ParityShards)
+	return &sealedContainer{layout: layout, chunks: chunks}, nil
+}
+
+// newContainerStore builds the erasure-coded container store from the
+// environment. Shards go to the directories in EC_BACKEND_PATHS when set,
+// and to the Data Storage Nodes of cluster otherwise.
+func newContainerStore(cluster *placement.Cluster) (*erasure.Store, error) {
+	var shards erasure.ShardStore
+	if list := getEnv("EC_BACKEND_PATHS", ""); list != "" {
+		var paths []string
+		for _, path := range strings.Split(list, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				paths = append(paths, filepath.Clean(path))
+			}
+		}
+		dirs, err := erasure.NewDirShardStore(paths)
+		if err != nil {
+			return nil, err
+		}
+		shards = dirs
+		log.Printf("Erasure-coded shards stored under %d backend paths", len(paths))
+	} else {
+		shards = erasure.NewNodeShardStore(cluster)
+		log.Printf("Erasure-coded shards stored on %d Data Storage Nodes", len(cluster.Nodes()))
+	}
+
+	dataShards := getEnvInt("EC_DATA_SHARDS", 6)
+	parityShards := getEnvInt("EC_PARITY_SHARDS", 3)
+	store, err := erasure.NewStore(shards, dataShards, parityShards)
+	if err != nil {
+		return nil, err
+	}
+	if targets := len(shards.Targets()); targets < dataShards+parityShards {
+		log.Printf("Warning: %d shard targets for %d+%d erasure coding; a single target failure can lose several shards",
+			targets, dataShards, parityShards)
+	}
+	return store, nil
+}
+
+// recordContainer stores a sealed container and the chunks packed into it
+func (s *IngestServer) recordContainer(ctx context.Context, container *sealedContainer) error {
+	if s.dbClient != nil {
+		if err := s.dbClient.InsertContainer(ctx, container.layout.ToDB()); err != nil {
+			return fmt.Errorf("failed to record container %s: %w", container.layout.ContainerID, err)
+		}
+	}
+
+	now := time.Now()
+	for _, packed := range container.chunks {
+		s.recordChunkMetadata(&db.ChunkMetadata{
+			Fingerprint:        packed.chunk.Fingerprint,
+			StorageLocation:    fmt.Sprintf("container://%s/%d", container.layout.ContainerID, packed.offset),
+			Size:               int(packed.chunk.Size),
+			CreationTime:       now,
+			LastReferencedTime: now,
+			ContainerID:        container.layout.ContainerID,
+			ContainerOffset:    packed.offset,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/db"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/jobstate"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// snapshotBatchSize is the number of files sent per PreviousSnapshotResponse
+const snapshotBatchSize = 1000
+
+// jobHistory records backup jobs, in CockroachDB when it is configured and
+// in memory otherwise
+type jobHistory struct {
+	dbClient *db.DB
+
+	mutex sync.RWMutex
+	jobs  map[string]*db.BackupJob
+}
+
+// newJobHistory creates a job history; dbClient may be nil
+func newJobHistory(dbClient *db.DB) *jobHistory {
+	return &jobHistory{dbClient: dbClient, jobs: make(map[string]*db.BackupJob)}
+}
+
+// Start records a new job
+func (h *jobHistory) Start(ctx context.Context, job *db.BackupJob) error {
+	if h.dbClient != nil {
+		return h.dbClient.CreateBackupJob(ctx, job)
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	job.HeartbeatTime = time.Now()
+	h.jobs[job.JobID] = job
+	return nil
+}
+
+// Transition moves a job to a state, ending it if the state is terminal. It
+// fails with a *jobstate.TransitionError if the lifecycle does not allow the
+// move from the job's current state.
+func (h *jobHistory) Transition(ctx context.Context, jobID string, to jobstate.State, reason jobstate.Reason) error {
+	var endTime *time.Time
+	if to.Terminal() {
+		now := time.Now()
+		endTime = &now
+	}
+	if h.dbClient != nil {
+		return h.dbClient.TransitionBackupJob(ctx, jobID, to, reason, endTime)
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	job := h.jobs[jobID]
+	if job == nil {
+		return fmt.Errorf("backup job %s does not exist", jobID)
+	}
+	if !jobstate.CanTransition(job.Status, to) {
+		return &jobstate.TransitionError{JobID: jobID, From: job.Status, To: to}
+	}
+	job.Status = to
+	job.FailureReason = reason
+	job.EndTime = endTime
+	job.HeartbeatTime = time.Now()
+	return nil
+}
+
+// Touch records that streams of unfinished jobs are still open
+func (h *jobHistory) Touch(ctx context.Context, jobIDs []string) error {
+	if len(jobIDs) == 0 {
+		return nil
+	}
+	if h.dbClient != nil {
+		return h.dbClient.TouchBackupJobs(ctx, jobIDs)
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for _, jobID := range jobIDs {
+		if job := h.jobs[jobID]; job != nil && !job.Status.Terminal() {
+			job.HeartbeatTime = time.Now()
+		}
+	}
+	return nil
+}
+
+// FailStale fails the unfinished jobs last seen before cutoff, whose streams
+// disappeared, and returns their IDs
+func (h *jobHistory) FailStale(ctx context.Context, cutoff time.Time) ([]string, error) {
+	if h.dbClient != nil {
+		return h.dbClient.FailStaleBackupJobs(ctx, cutoff)
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	var failed []string
+	for _, job := range h.jobs {
+		if job.Status.Terminal() || !job.HeartbeatTime.Before(cutoff) {
+			continue
+		}
+		now := time.Now()
+		job.Status = jobstate.Failed
+		job.FailureReason = jobstate.StreamLost
+		job.EndTime = &now
+		failed = append(failed, job.JobID)
+	}
+	return failed, nil
+}
+
+// RecordStats records the counters of a job
+func (h *jobHistory) RecordStats(ctx context.Context, jobID string, stats db.JobStats) error {
+	if h.dbClient != nil {
+		return h.dbClient.RecordBackupJobStats(ctx, jobID, stats)
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if job := h.jobs[jobID]; job != nil {
+		job.Stats = stats
+	}
+	return nil
+}
+
+// List returns the jobs a filter selects, most recently started first
+func (h *jobHistory) List(ctx context.Context, filter db.BackupJobFilter) ([]db.BackupJob, error) {
+	if h.dbClient != nil {
+		return h.dbClient.ListBackupJobs(ctx, filter)
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	var jobs []db.BackupJob
+	for _, job := range h.jobs {
+		if (filter.ClientID != "" && job.ClientID != filter.ClientID) ||
+			(filter.BackupPolicyID != "" && job.BackupPolicyID != filter.BackupPolicyID) ||
+			(len(filter.States) > 0 && !slices.Contains(filter.States, job.Status)) ||
+			(!filter.StartedAfter.IsZero() && job.StartTime.Before(filter.StartedAfter)) ||
+			(!filter.StartedBefore.IsZero() && !job.StartTime.Before(filter.StartedBefore)) ||
+			(filter.AfterJobID != "" && !startedBefore(job, filter.AfterStart, filter.AfterJobID)) {
+			continue
+		}
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return startedBefore(&jobs[j], jobs[i].StartTime, jobs[i].JobID) })
+	if filter.Limit > 0 && len(jobs) > filter.Limit {
+		jobs = jobs[:filter.Limit]
+	}
+	return jobs, nil
+}
+
+// startedBefore reports whether a job comes after the job jobID, started at
+// start, in the order of List
+func startedBefore(job *db.BackupJob, start time.Time, jobID string) bool {
+	return job.StartTime.Before(start) || (job.StartTime.Equal(start) && job.JobID < jobID)
+}
+
+// Delete forgets an ended job. It fails with db.ErrJobNotEnded if the job has
+// not ended.
+func (h *jobHistory) Delete(ctx context.Context, jobID string) error {
+	if h.dbClient != nil {
+		return h.dbClient.DeleteBackupJob(ctx, jobID)
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if job := h.jobs[jobID]; job != nil && job.EndTime == nil {
+		return fmt.Errorf("backup job %s is %s: %w", jobID, job.Status, db.ErrJobNotEnded)
+	}
+	delete(h.jobs, jobID)
+	return nil
+}
+
+// Get returns a job, or nil if it is not recorded
+func (h *jobHistory) Get(ctx context.Context, jobID string) (*db.BackupJob, error) {
+	if h.dbClient != nil {
+		return h.dbClient.GetBackupJob(ctx, jobID)
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if job := h.jobs[jobID]; job != nil {
+		copied := *job
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+// Latest returns the most recently started successful job of a client and
+// source, or nil if there is none. Files that failed in a job are absent from
+// its manifest, so they are sent in full by the next one.
+func (h *jobHistory) Latest(ctx context.Context, clientID, sourceType, sourceDetails string) (*db.BackupJob, error) {
+	if h.dbClient != nil {
+		return h.dbClient.LatestBackupJob(ctx, clientID, sourceType, sourceDetails, jobstate.Successful)
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	var latest *db.BackupJob
+	for _, job := range h.jobs {
+		if job.ClientID != clientID || job.SourceType != sourceType || job.SourceDetails != sourceDetails ||
+			job.EndTime == nil || !job.Status.Successful() {
+			continue
+		}
+		if latest == nil || job.StartTime.After(latest.StartTime) ||
+			(job.StartTime.Equal(latest.StartTime) && job.EndTime.After(*latest.EndTime)) {
+			latest = job
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+	copied := *latest
+	return &copied, nil
+}
+
+// GetPreviousSnapshot streams the regular files of the last successful
+// backup of a client and source
+func (s *IngestServer) GetPreviousSnapshot(req *pb.PreviousSnapshotRequest, stream pb.BackupService_GetPreviousSnapshotServer) error {
+	if req.ClientId == "" {
+		return status.Error(codes.InvalidArgument, "Client ID is required")
+	}
+	ctx := stream.Context()
+	job, err := s.history.Latest(ctx, req.ClientId, req.SourceType, req.SourceDetails)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to find previous backup: %v", err)
+	}
+	if job == nil {
+		return status.Errorf(codes.NotFound, "No previous backup for client %s and this source", req.ClientId)
+	}
+	manifests, err := s.manifests.List(ctx, job.JobID)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to list files of %s: %v", job.JobID, err)
+	}
+
+	// The first response is sent even when there are no files, so the client
+	// learns the job ID
+	resp := &pb.PreviousSnapshotResponse{BackupJobId: job.JobID}
+	sent := false
+	for i := range manifests {
+		if manifests[i].FileType != "REGULAR" {
+			continue
+		}
+		entry, err := fileEntry(&manifests[i])
+		if err != nil {
+			return status.Errorf(codes.Internal, "Failed to read manifest: %v", err)
+		}
+		resp.Files = append(resp.Files, entry)
+		if len(resp.Files) == snapshotBatchSize {
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+			resp = &pb.PreviousSnapshotResponse{BackupJobId: job.JobID}
+			sent = true
+		}
+	}
+	if len(resp.Files) > 0 || !sent {
+		return stream.Send(resp)
+	}
+	return nil
+}
+
+// loadBaseJob loads the manifest of the job an incremental backup builds
+// on. The base job must be a successful job of the same client, since its
+// files are restored as part of the new job.
+func (s *IngestServer) loadBaseJob(ctx context.Context, job *BackupJobState, baseJobID string) error {
+	base, err := s.history.Get(ctx, baseJobID)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to look up base job %s: %v", baseJobID, err)
+	}
+	if base == nil {
+		return status.Errorf(codes.FailedPrecondition, "Base job %s does not exist", baseJobID)
+	}
+	if base.ClientID != job.ClientID {
+		return status.Errorf(codes.PermissionDenied, "Base job %s belongs to another client", baseJobID)
+	}
+	if base.EndTime == nil || !base.Status.Successful() {
+		return status.Errorf(codes.FailedPrecondition, "Base job %s did not complete successfully", baseJobID)
+	}
+
+	manifests, err := s.manifests.List(ctx, baseJobID)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to list files of base job %s: %v", baseJobID, err)
+	}
+	job.BaseJobID = baseJobID
+	job.base = make(map[string]*db.FileManifest, len(manifests))
+	for i := range manifests {
+		if manifests[i].FileType == "REGULAR" {
+			job.base[manifests[i].FilePath] = &manifests[i]
+		}
+	}
+	return nil
+}
+
+// receiveUnchangedFile records a file unchanged since the base job with the
+// chunks of its manifest entry there and the newly captured metadata
+func (s *IngestServer) receiveUnchangedFile(ctx context.Context, job *BackupJobState, entry *pb.FileEntry) error {
+	if entry == nil {
+		return status.Error(codes.InvalidArgument, "Unchanged file sent without an entry")
+	}
+	if job.base == nil {
+		return status.Errorf(codes.FailedPrecondition, "Unchanged file %s sent without a base job", entry.FilePath)
+	}
+	base := job.base[entry.FilePath]
+	if entry.Type != pb.FileType_FILE_TYPE_REGULAR || base == nil || base.Size != int64(entry.Size) {
+		return status.Errorf(codes.InvalidArgument, "%s is not a regular file of %d bytes in base job %s", entry.FilePath, entry.Size, job.BaseJobID)
+	}
+	entry.ContentHash = contentHash(base.Chunks)
+	if err := s.manifests.Put(ctx, job.JobID, entry, base.Chunks, base.ChunkingProfile); err != nil {
+		return status.Errorf(codes.Internal, "Failed to record %s: %v", entry.FilePath, err)
+	}
+	job.mutex.Lock()
+	job.FilesUnchanged++
+	job.BytesProcessed += base.Size
+	job.BytesDeduplicated += base.Size
+	job.mutex.Unlock()
+	return s.entryDone(ctx, job, entry.FilePath)
+}
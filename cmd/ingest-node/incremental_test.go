@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// fakeSnapshotStream collects the responses of GetPreviousSnapshot
+type fakeSnapshotStream struct {
+	grpc.ServerStream
+	responses []*pb.PreviousSnapshotResponse
+}
+
+func (f *fakeSnapshotStream) Context() context.Context { return context.Background() }
+
+func (f *fakeSnapshotStream) Send(resp *pb.PreviousSnapshotResponse) error {
+	f.responses = append(f.responses, resp)
+	return nil
+}
+
+func backupStart(clientID, jobID, baseJobID string, timestamp int64) *pb.BackupRequest {
+	return &pb.BackupRequest{RequestType: &pb.BackupRequest_StartBackup{StartBackup: &pb.BackupStart{
+		ClientId: clientID, BackupJobId: jobID, BaseJobId: baseJobID, Timestamp: timestamp,
+		SourceType: "filesystem", SourceDetails: `{"paths":["/src"]}`,
+	}}}
+}
+
+func backupEnd(jobID, jobStatus string) *pb.BackupRequest {
+	return &pb.BackupRequest{RequestType: &pb.BackupRequest_EndBackup{EndBackup: &pb.BackupEnd{BackupJobId: jobID, Status: jobStatus}}}
+}
+
+func unchangedFile(entry *pb.FileEntry) *pb.BackupRequest {
+	return &pb.BackupRequest{RequestType: &pb.BackupRequest_UnchangedFile{UnchangedFile: &pb.UnchangedFile{Entry: entry}}}
+}
+
+func TestIncrementalBackup(t *testing.T) {
+	ctx := context.Background()
+	server := NewIngestServer("0")
+	kept := &pb.FileEntry{FilePath: "/src/kept", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o644, Size: 5, Inode: 7, CtimeNs: 100}
+	gone := &pb.FileEntry{FilePath: "/src/gone", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o644, Size: 4, Inode: 8, CtimeNs: 100}
+
+	full := &fakeBackupStream{requests: []*pb.BackupRequest{
+		backupStart("client-a", "job-1", "", 1000),
+		{RequestType: &pb.BackupRequest_FileEntry{FileEntry: kept}},
+		segment(kept.FilePath, []byte("hello"), 0, true),
+		{RequestType: &pb.BackupRequest_FileEntry{FileEntry: gone}},
+		segment(gone.FilePath, []byte("bye!"), 0, true),
+		backupEnd("job-1", "COMPLETED"),
+	}}
+	if err := server.StreamBackup(full); err != nil {
+		t.Fatalf("Full backup failed: %v", err)
+	}
+
+	// An unfinished later job is not a base for incremental backups
+	if err := server.StreamBackup(&fakeBackupStream{requests: []*pb.BackupRequest{backupStart("client-a", "job-broken", "", 2000)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := &fakeSnapshotStream{}
+	req := &pb.PreviousSnapshotRequest{ClientId: "client-a", SourceType: "filesystem", SourceDetails: `{"paths":["/src"]}`}
+	if err := server.GetPreviousSnapshot(req, snapshot); err != nil {
+		t.Fatalf("GetPreviousSnapshot failed: %v", err)
+	}
+	if len(snapshot.responses) != 1 || snapshot.responses[0].BackupJobId != "job-1" || len(snapshot.responses[0].Files) != 2 {
+		t.Fatalf("Unexpected previous snapshot: %v", snapshot.responses)
+	}
+	if files := snapshot.responses[0].Files; files[1].FilePath != kept.FilePath || files[1].Inode != 7 || files[1].CtimeNs != 100 {
+		t.Errorf("Previous snapshot lost inode or ctime: %v", files[1])
+	}
+
+	other := &pb.PreviousSnapshotRequest{ClientId: "client-a", SourceType: "filesystem", SourceDetails: `{"paths":["/other"]}`}
+	if err := server.GetPreviousSnapshot(other, &fakeSnapshotStream{}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for another source, got %v", err)
+	}
+
+	// The incremental job refers to the unchanged file and omits the deleted one
+	added := &pb.FileEntry{FilePath: "/src/added", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o600, Size: 3}
+	keptNow := &pb.FileEntry{FilePath: kept.FilePath, Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o600, Size: 5, Inode: 7, CtimeNs: 100}
+	incremental := &fakeBackupStream{requests: []*pb.BackupRequest{
+		backupStart("client-a", "job-2", "job-1", 3000),
+		unchangedFile(keptNow),
+		{RequestType: &pb.BackupRequest_FileEntry{FileEntry: added}},
+		segment(added.FilePath, []byte("new"), 0, true),
+		backupEnd("job-2", "COMPLETED"),
+	}}
+	if err := server.StreamBackup(incremental); err != nil {
+		t.Fatalf("Incremental backup failed: %v", err)
+	}
+	if job, _ := server.history.Get(ctx, "job-2"); job.Stats.FilesUnchanged != 1 || job.Stats.FilesProcessed != 1 || job.Stats.BytesDeduplicated < 5 {
+		t.Errorf("Unexpected job counters: %+v", job.Stats)
+	}
+
+	base, _ := server.manifests.List(ctx, "job-1")
+	manifests, _ := server.manifests.List(ctx, "job-2")
+	if len(manifests) != 2 || manifests[0].FilePath != added.FilePath || manifests[1].FilePath != kept.FilePath {
+		t.Fatalf("Unexpected synthetic snapshot: %+v", manifests)
+	}
+	if got, want := manifests[1].Chunks, base[1].Chunks; len(got) != len(want) || got[0] != want[0] {
+		t.Errorf("Unchanged file has chunks %v, want %v", got, want)
+	}
+	if entry, _ := fileEntry(&manifests[1]); entry.Mode != 0o600 {
+		t.Errorf("Expected the unchanged file to keep its new metadata, got mode %o", entry.Mode)
+	}
+
+	// Later incrementals build on the synthetic snapshot
+	snapshot = &fakeSnapshotStream{}
+	if err := server.GetPreviousSnapshot(req, snapshot); err != nil || snapshot.responses[0].BackupJobId != "job-2" {
+		t.Errorf("Expected job-2 as the previous snapshot, got %v (%v)", snapshot.responses, err)
+	}
+}
+
+func TestIncrementalBaseValidation(t *testing.T) {
+	server := NewIngestServer("0")
+	if err := server.StreamBackup(&fakeBackupStream{requests: []*pb.BackupRequest{
+		backupStart("client-a", "job-1", "", 1000),
+		backupEnd("job-1", "COMPLETED"),
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		requests []*pb.BackupRequest
+		code     codes.Code
+	}{
+		{"other client's base", []*pb.BackupRequest{backupStart("client-b", "job-b", "job-1", 2000)}, codes.PermissionDenied},
+		{"unknown base", []*pb.BackupRequest{backupStart("client-a", "job-x", "job-missing", 2000)}, codes.FailedPrecondition},
+		{"no base", []*pb.BackupRequest{
+			backupStart("client-a", "job-y", "", 2000),
+			unchangedFile(&pb.FileEntry{FilePath: "/src/f", Type: pb.FileType_FILE_TYPE_REGULAR}),
+		}, codes.FailedPrecondition},
+		{"file not in base", []*pb.BackupRequest{
+			backupStart("client-a", "job-z", "job-1", 2000),
+			unchangedFile(&pb.FileEntry{FilePath: "/src/f", Type: pb.FileType_FILE_TYPE_REGULAR, Size: 1}),
+		}, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		err := server.StreamBackup(&fakeBackupStream{requests: tt.requests})
+		if status.Code(err) != tt.code {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.code, err)
+		}
+	}
+}
+
+func TestUnchangedFilesOnParallelStreams(t *testing.T) {
+	ctx := context.Background()
+	server := NewIngestServer("0")
+	catalog := NewCatalogServer(server)
+	var entries []*pb.FileEntry
+	requests := []*pb.BackupRequest{backupStart("client-a", "job-1", "", 1000)}
+	for i := range 400 {
+		entry := &pb.FileEntry{FilePath: fmt.Sprintf("/src/file-%03d", i), Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o644, Size: 5, Inode: uint64(i + 1), CtimeNs: 100}
+		entries = append(entries, entry)
+		requests = append(requests, &pb.BackupRequest{RequestType: &pb.BackupRequest_FileEntry{FileEntry: entry}},
+			segment(entry.FilePath, []byte("hello"), 0, true))
+	}
+	requests = append(requests, backupEnd("job-1", "COMPLETED"))
+	if err := server.StreamBackup(&fakeBackupStream{requests: requests}); err != nil {
+		t.Fatalf("Full backup failed: %v", err)
+	}
+	if err := server.StreamBackup(&fakeBackupStream{requests: []*pb.BackupRequest{backupStart("client-a", "job-2", "job-1", 2000)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two streams record unchanged files while the job is being read; run
+	// with -race to catch unlocked counters
+	var reading, readers, streams sync.WaitGroup
+	done := make(chan struct{})
+	reading.Add(1)
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		var unchanged uint64
+		for i := 0; ; i++ {
+			info, err := catalog.GetJob(ctx, &pb.GetJobRequest{ClientId: "client-a", BackupJobId: "job-2"})
+			if i == 0 {
+				reading.Done()
+			}
+			if err != nil {
+				t.Errorf("GetJob failed: %v", err)
+				return
+			}
+			if info.Stats.FilesUnchanged < unchanged {
+				t.Errorf("Unchanged files went from %d to %d", unchanged, info.Stats.FilesUnchanged)
+			}
+			unchanged = info.Stats.FilesUnchanged
+			select {
+			case <-done:
+				return
+			default:
+			}
+		}
+	}()
+	reading.Wait()
+	for part := range 2 {
+		streams.Add(1)
+		go func() {
+			defer streams.Done()
+			stream := &fakeBackupStream{requests: []*pb.BackupRequest{joinStart("client-a", "job-2")}}
+			for _, entry := range entries[part*200 : (part+1)*200] {
+				stream.requests = append(stream.requests, unchangedFile(entry))
+			}
+			if err := server.StreamBackup(stream); err != nil {
+				t.Errorf("Stream %d failed: %v", part, err)
+			}
+		}()
+	}
+	streams.Wait()
+	close(done)
+	readers.Wait()
+
+	if err := server.StreamBackup(&fakeBackupStream{requests: []*pb.BackupRequest{joinStart("client-a", "job-2"), backupEnd("job-2", "COMPLETED")}}); err != nil {
+		t.Fatalf("EndBackup failed: %v", err)
+	}
+	job, err := server.history.Get(ctx, "job-2")
+	if err != nil || job == nil {
+		t.Fatalf("Job not recorded: %v", err)
+	}
+	if job.Stats.FilesUnchanged != 400 || job.Stats.BytesProcessed != 2000 || job.Stats.BytesDeduplicated != 2000 {
+		t.Errorf("Unexpected job counters: %+v", job.Stats)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/jobstate"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// jobStates maps job states to their protocol values
+var jobStates = map[jobstate.State]pb.JobState{
+	jobstate.Pending:    pb.JobState_JOB_STATE_PENDING,
+	jobstate.Running:    pb.JobState_JOB_STATE_RUNNING,
+	jobstate.Finalizing: pb.JobState_JOB_STATE_FINALIZING,
+	jobstate.Completed:  pb.JobState_JOB_STATE_COMPLETED,
+	jobstate.Partial:    pb.JobState_JOB_STATE_PARTIAL,
+	jobstate.Failed:     pb.JobState_JOB_STATE_FAILED,
+	jobstate.Cancelled:  pb.JobState_JOB_STATE_CANCELLED,
+}
+
+// failureReasons maps failure reasons to their protocol values; others are
+// unspecified
+var failureReasons = map[jobstate.Reason]pb.FailureReason{
+	jobstate.ClientReported: pb.FailureReason_FAILURE_REASON_CLIENT_REPORTED,
+	jobstate.StreamLost:     pb.FailureReason_FAILURE_REASON_STREAM_LOST,
+	jobstate.StorageError:   pb.FailureReason_FAILURE_REASON_STORAGE_ERROR,
+	jobstate.InternalError:  pb.FailureReason_FAILURE_REASON_INTERNAL_ERROR,
+}
+
+// endState returns the state a BackupEnd asks its job to end in: its state,
+// or else the state its legacy status names
+func endState(end *pb.BackupEnd) (jobstate.State, error) {
+	var state jobstate.State
+	if end.State != pb.JobState_JOB_STATE_UNSPECIFIED {
+		state, _ = stateOf(end.State)
+	} else if end.Status != "" {
+		var err error
+		if state, err = jobstate.Parse(end.Status); err != nil {
+			return "", err
+		}
+	}
+	switch state {
+	case jobstate.Completed, jobstate.Partial, jobstate.Failed, jobstate.Cancelled:
+		return state, nil
+	case "":
+		return "", fmt.Errorf("no end state given")
+	default:
+		return "", fmt.Errorf("a job cannot be ended as %s", state)
+	}
+}
+
+// setJobState moves a job to a state in the job history and then in memory
+func (s *IngestServer) setJobState(ctx context.Context, job *BackupJobState, to jobstate.State, reason jobstate.Reason) error {
+	err := s.history.Transition(ctx, job.JobID, to, reason)
+	var transition *jobstate.TransitionError
+	if errors.As(err, &transition) {
+		return status.Errorf(codes.FailedPrecondition, "Backup job %s is %s and cannot become %s", job.JobID, transition.From, to)
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to record state %s of backup job %s: %v", to, job.JobID, err)
+	}
+
+	job.mutex.Lock()
+	job.State = to
+	job.FailureReason = reason
+	job.mutex.Unlock()
+	return nil
+}
+
+// failJob fails a job after an error on the ingest node, logging if that
+// cannot be recorded either
+func (s *IngestServer) failJob(ctx context.Context, job *BackupJobState, reason jobstate.Reason) {
+	if err := s.setJobState(ctx, job, jobstate.Failed, reason); err != nil {
+		log.Printf("Warning: Failed to fail backup job %s: %v", job.JobID, err)
+		return
+	}
+	s.removeJob(job)
+}
+
+// removeJob forgets a job that has ended, with its base manifest. Streams
+// still holding it keep it until they close, but no stream can join it.
+func (s *IngestServer) removeJob(job *BackupJobState) {
+	s.backupMutex.Lock()
+	if s.backupJobs[job.JobID] == job {
+		delete(s.backupJobs, job.JobID)
+	}
+	s.backupMutex.Unlock()
+}
+
+// abortJob fails a job after the ingest node could not process a request of
+// one of its streams, and returns err for the stream. Errors in the request
+// itself, and errors after the stream broke, leave the job running: the
+// client may resume it, and the job reaper fails it otherwise.
+func (s *IngestServer) abortJob(ctx context.Context, job *BackupJobState, reason jobstate.Reason, err error) error {
+	if code := status.Code(err); (code == codes.Internal || code == codes.Unknown) && ctx.Err() == nil {
+		s.failJob(ctx, job, reason)
+	}
+	return err
+}
+
+// RunJobReaper fails the jobs no stream was seen of for timeout, such as
+// jobs whose stream handler died, until ctx is done. Jobs with a stream open
+// on this node are kept alive every quarter of timeout.
+func (s *IngestServer) RunJobReaper(ctx context.Context, timeout time.Duration) {
+	ticker := time.NewTicker(timeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.reapJobs(ctx, time.Now().Add(-timeout)); err != nil {
+				log.Printf("Warning: Job reaper failed: %v", err)
+			}
+		}
+	}
+}
+
+// reapJobs records a heartbeat for the jobs with a stream open on this node,
+// then fails the unfinished jobs last seen before cutoff
+func (s *IngestServer) reapJobs(ctx context.Context, cutoff time.Time) error {
+	var open []string
+	s.backupMutex.RLock()
+	for jobID, job := range s.backupJobs {
+		job.mutex.Lock()
+		if job.active > 0 && !job.State.Terminal() {
+			open = append(open, jobID)
+		}
+		job.mutex.Unlock()
+	}
+	s.backupMutex.RUnlock()
+	if err := s.history.Touch(ctx, open); err != nil {
+		return fmt.Errorf("failed to record job heartbeats: %w", err)
+	}
+
+	failed, err := s.history.FailStale(ctx, cutoff)
+	if err != nil {
+		return fmt.Errorf("failed to fail stale jobs: %w", err)
+	}
+	for _, jobID := range failed {
+		log.Printf("Backup job %s failed: no stream was seen since %s", jobID, cutoff.Format(time.RFC3339))
+		s.backupMutex.Lock()
+		job := s.backupJobs[jobID]
+		delete(s.backupJobs, jobID)
+		s.backupMutex.Unlock()
+		if job != nil {
+			job.mutex.Lock()
+			job.State = jobstate.Failed
+			job.FailureReason = jobstate.StreamLost
+			job.ended = true
+			job.mutex.Unlock()
+		}
+		if err := s.checkpoints.Delete(ctx, jobID); err != nil {
+			log.Printf("Warning: Failed to delete checkpoint of %s: %v", jobID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/erasure"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/jobstate"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+func TestJobEndStates(t *testing.T) {
+	ctx := context.Background()
+	server := NewIngestServer("0")
+	end := func(jobID string, state pb.JobState) *pb.BackupRequest {
+		return &pb.BackupRequest{RequestType: &pb.BackupRequest_EndBackup{EndBackup: &pb.BackupEnd{BackupJobId: jobID, State: state}}}
+	}
+
+	for _, tc := range []struct {
+		jobID  string
+		end    *pb.BackupRequest
+		state  jobstate.State
+		reason jobstate.Reason
+	}{
+		{"job-done", end("job-done", pb.JobState_JOB_STATE_COMPLETED), jobstate.Completed, jobstate.NoReason},
+		{"job-errors", backupEnd("job-errors", "COMPLETED_WITH_ERRORS"), jobstate.Partial, jobstate.NoReason},
+		{"job-failed", end("job-failed", pb.JobState_JOB_STATE_FAILED), jobstate.Failed, jobstate.ClientReported},
+		{"job-cancelled", end("job-cancelled", pb.JobState_JOB_STATE_CANCELLED), jobstate.Cancelled, jobstate.NoReason},
+	} {
+		stream := &fakeBackupStream{requests: []*pb.BackupRequest{backupStart("client-a", tc.jobID, "", 1000), tc.end}}
+		if err := server.StreamBackup(stream); err != nil {
+			t.Fatalf("%s: %v", tc.jobID, err)
+		}
+		job, _ := server.history.Get(ctx, tc.jobID)
+		if job.Status != tc.state || job.FailureReason != tc.reason || job.EndTime == nil {
+			t.Errorf("%s: expected %s (%q), got %+v", tc.jobID, tc.state, tc.reason, job)
+		}
+		final := stream.responses[len(stream.responses)-1].GetStatusUpdate()
+		if final.GetState() != jobStates[tc.state] || final.GetFailureReason() != failureReasons[tc.reason] {
+			t.Errorf("%s: unexpected final status %v", tc.jobID, final)
+		}
+	}
+
+	// Only successful jobs are bases of incremental backups
+	stream := &fakeBackupStream{requests: []*pb.BackupRequest{backupStart("client-a", "job-next", "job-failed", 2000)}}
+	if err := server.StreamBackup(stream); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected a failed base job to be rejected, got %v", err)
+	}
+	if job, _ := server.history.Get(ctx, "job-next"); job != nil {
+		t.Errorf("A rejected job should not be recorded, got %+v", job)
+	}
+
+	// A job cannot end in a state that does not end it, or twice
+	for jobID, req := range map[string]*pb.BackupRequest{
+		"job-running": end("job-running", pb.JobState_JOB_STATE_RUNNING),
+		"job-unknown": backupEnd("job-unknown", "DONE"),
+		"job-nostate": backupEnd("job-nostate", ""),
+	} {
+		stream := &fakeBackupStream{requests: []*pb.BackupRequest{backupStart("client-a", jobID, "", 1000), req}}
+		if err := server.StreamBackup(stream); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Expected %v to be rejected, got %v", req.GetEndBackup(), err)
+		}
+	}
+	if err := server.history.Transition(ctx, "job-done", jobstate.Failed, jobstate.InternalError); err == nil {
+		t.Error("Expected an ended job to stay ended")
+	}
+}
+
+func TestReaperFailsLostJobs(t *testing.T) {
+	ctx := context.Background()
+	server := NewIngestServer("0")
+
+	// One job's stream breaks; another keeps a stream open
+	lost := &fakeBackupStream{requests: []*pb.BackupRequest{backupStart("client-a", "job-lost", "", 1000)}}
+	if err := server.StreamBackup(lost); err != nil {
+		t.Fatal(err)
+	}
+	_, upload := startJob(t, server, "client-a", "job-open")
+	defer upload.close()
+	if job, _ := server.history.Get(ctx, "job-lost"); job.Status != jobstate.Running {
+		t.Fatalf("Expected a started job to be running, got %+v", job)
+	}
+
+	// The open job's heartbeat is recorded past the cutoff
+	time.Sleep(time.Millisecond)
+	if err := server.reapJobs(ctx, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	job, _ := server.history.Get(ctx, "job-lost")
+	if job.Status != jobstate.Failed || job.FailureReason != jobstate.StreamLost || job.EndTime == nil {
+		t.Errorf("Expected the lost job to fail, got %+v", job)
+	}
+	if server.backupJobs["job-lost"] != nil {
+		t.Error("Expected the lost job to be forgotten")
+	}
+	if job, _ := server.history.Get(ctx, "job-open"); job.Status != jobstate.Running {
+		t.Errorf("Expected the job with an open stream to keep running, got %+v", job)
+	}
+
+	// A reaped job can neither be resumed nor joined
+	resume := backupStart("client-a", "job-lost", "", 1000)
+	resume.GetStartBackup().Resume = true
+	if err := server.StreamBackup(&fakeBackupStream{requests: []*pb.BackupRequest{resume}}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected resuming a failed job to be rejected, got %v", err)
+	}
+	if err := server.StreamBackup(&fakeBackupStream{requests: []*pb.BackupRequest{joinStart("client-a", "job-lost")}}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected joining a failed job to be rejected, got %v", err)
+	}
+}
+
+// failingShardStore rejects every shard, like storage nodes that are down
+type failingShardStore struct{}
+
+func (failingShardStore) Targets() []string { return []string{"node-1", "node-2", "node-3"} }
+
+func (failingShardStore) PutShard(ctx context.Context, target, fingerprint string, shard []byte) error {
+	return errors.New("storage node unreachable")
+}
+
+func (failingShardStore) GetShard(ctx context.Context, target, fingerprint string) ([]byte, error) {
+	return nil, errors.New("storage node unreachable")
+}
+
+func TestEndedJobsAreForgotten(t *testing.T) {
+	server := NewIngestServer("0")
+	file := &pb.FileEntry{FilePath: "/src/file", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o600, Size: 4, Inode: 1, CtimeNs: 100}
+	full := &fakeBackupStream{requests: []*pb.BackupRequest{
+		backupStart("client-a", "job-1", "", 1000),
+		{RequestType: &pb.BackupRequest_FileEntry{FileEntry: file}},
+		segment(file.FilePath, []byte("data"), 0, true),
+		backupEnd("job-1", "COMPLETED"),
+	}}
+	if err := server.StreamBackup(full); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	incremental := &fakeBackupStream{requests: []*pb.BackupRequest{
+		backupStart("client-a", "job-2", "job-1", 2000),
+		unchangedFile(file),
+		backupEnd("job-2", "COMPLETED"),
+	}}
+	if err := server.StreamBackup(incremental); err != nil {
+		t.Fatalf("Incremental backup failed: %v", err)
+	}
+
+	// Neither job, nor the base manifest of the incremental one, is kept
+	if len(server.backupJobs) != 0 {
+		t.Errorf("Expected no jobs in memory after the backups, got %d", len(server.backupJobs))
+	}
+	if job, _ := server.history.Get(context.Background(), "job-2"); job.Status != jobstate.Completed || job.Stats.FilesUnchanged != 1 {
+		t.Errorf("Unexpected incremental job: %+v", job)
+	}
+}
+
+func TestStorageErrorFailsJob(t *testing.T) {
+	ctx := context.Background()
+	server := NewIngestServer("0")
+	store, err := erasure.NewStore(failingShardStore{}, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.containers = newContainerPacker(store, 1024)
+
+	data := bytes.Repeat([]byte("data that fills a container "), 200)
+	stream := &fakeBackupStream{requests: []*pb.BackupRequest{
+		backupStart("client-a", "job-storage", "", 1000),
+		{RequestType: &pb.BackupRequest_FileEntry{FileEntry: &pb.FileEntry{FilePath: "/f", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o644}}},
+		segment("/f", data, 0, true),
+		backupEnd("job-storage", "COMPLETED"),
+	}}
+	if err := server.StreamBackup(stream); status.Code(err) != codes.Internal {
+		t.Fatalf("Expected the backup to fail, got %v", err)
+	}
+	job, _ := server.history.Get(ctx, "job-storage")
+	if job.Status != jobstate.Failed || job.FailureReason != jobstate.StorageError || job.EndTime == nil {
+		t.Errorf("Expected the job to fail with a storage error, got %+v", job)
+	}
+	if server.backupJobs["job-storage"] != nil {
+		t.Error("Expected the failed job to be forgotten")
+	}
+
+	// A request the client got wrong leaves the job to the client
+	stream = &fakeBackupStream{requests: []*pb.BackupRequest{
+		backupStart("client-a", "job-bad", "", 1000),
+		backupEnd("job-bad", "DONE"),
+	}}
+	if err := server.StreamBackup(stream); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected the end to be rejected, got %v", err)
+	}
+	if job, _ := server.history.Get(ctx, "job-bad"); job.Status != jobstate.Running {
+		t.Errorf("Expected the job to keep running, got %+v", job)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,61 +14,189 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/archive"
 	"github.com/radhakrishnan.venkat/dedupe-engine/internal/cache"
 	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
 	"github.com/radhakrishnan.venkat/dedupe-engine/internal/db"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/erasure"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/jobstate"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/placement"
 	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
-	storagepb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
 )
 
+// storeBatchSize is the maximum number of chunks uploaded in one StoreChunks stream
+const storeBatchSize = 256
+
 // IngestServer implements the BackupService
 type IngestServer struct {
 	pb.UnimplementedBackupServiceServer
 
 	// Components
-	chunker       *chunking.Chunker
-	cache         *cache.DeduplicationCache
-	dbClient      *db.DB
-	storageClient *storagepb.StorageServiceClient
+	chunker    *chunking.Chunker
+	cache      *cache.DeduplicationCache
+	dbClient   *db.DB
+	storage    *placement.Cluster
+	containers *containerPacker // set when STORAGE_MODE=erasure
+	manifests  *manifestStore
+	owners     *chunkOwners
+	history    *jobHistory
+	deltas     *deltaCompressor // set when DELTA_COMPRESSION=true
+	policies   *chunkingPolicies
+	pipeline   pipelineConfig
+
+	// Checkpoints of unfinished jobs, taken every checkpointInterval and
+	// every checkpointBytes of a large file
+	checkpoints        *checkpointStore
+	checkpointInterval time.Duration
+	checkpointBytes    int64
 
 	// Backup state
 	backupJobs  map[string]*BackupJobState
 	backupMutex sync.RWMutex
 
+	// Restore state
+	restoreJobs  map[string]*restoreJob
+	restoreMutex sync.Mutex
+
 	// Configuration
-	grpcPort    string
-	storageAddr string
+	grpcPort string
 }
 
-// BackupJobState tracks the state of an active backup job
+// BackupJobState tracks the state of an active backup job. A job may be
+// uploaded over several parallel streams; mutex guards the state they share.
 type BackupJobState struct {
 	JobID             string
 	ClientID          string
 	StartTime         time.Time
-	Status            string
+	State             jobstate.State
+	FailureReason     jobstate.Reason // of a Failed job
 	FilesProcessed    int
+	EntriesProcessed  int // directories, links and special files
 	ChunksProcessed   int
 	BytesProcessed    int64
 	BytesDeduplicated int64
-	FileBuffer        map[string][]byte           // file path -> accumulated data
-	FileChunks        map[string][]chunking.Chunk // file path -> chunks
+	ChunksDelta       int    // new chunks stored as deltas against similar chunks
+	BytesDeltaSaved   int64  // bytes those chunks did not take to store whole
+	FilesUnchanged    int    // files taken from the base job's manifest
+	BaseJobID         string // job an incremental backup builds on
+	LastEntry         string // last entry recorded in the manifest, in stream order
+	Archives          bool   // chunk tar and zip member contents apart from their headers
+
+	chunker        *chunking.Chunker // of the job's chunking policy, or the active one
+	mutex          sync.Mutex
+	base           map[string]*db.FileManifest // regular files of the base job by path
+	lastCheckpoint time.Time
+	streams        int                    // streams that have uploaded to the job
+	active         int                    // streams still open
+	ended          bool                   // EndBackup received; no more streams may join
+	ranges         map[string]*rangedFile // large files uploaded as ranges, by path
+	pipeline       pipelineStats          // ingest pipeline work of every stream
+}
+
+// jobUpload is the part of a backup job uploaded over one stream
+type jobUpload struct {
+	job    *BackupJobState
+	stream pb.BackupService_StreamBackupServer // acknowledges stored chunks; may be nil
+	files  map[string]*pendingFile             // file path -> file whose segments are arriving
+}
+
+// newJobUpload starts a stream's upload to a job
+func newJobUpload(job *BackupJobState, stream pb.BackupService_StreamBackupServer) *jobUpload {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	job.streams++
+	job.active++
+	return &jobUpload{job: job, stream: stream, files: make(map[string]*pendingFile)}
+}
+
+// close ends a stream's upload to its job
+func (u *jobUpload) close() {
+	u.job.mutex.Lock()
+	defer u.job.mutex.Unlock()
+	u.job.active--
+}
+
+// pendingFile is a regular file whose segments are still arriving. Data is
+// buffered until a hole or the last segment, then chunked and stored.
+type pendingFile struct {
+	entry *pb.FileEntry // nil if the stream handler sent no FileEntry
+	data  []byte        // data received since the last hole
+	refs  []string      // chunk fingerprints and holes stored so far
+	size  int64         // bytes covered by refs
+
+	// Source-side deduplication: chunks of the current ChunkRefBatch whose
+	// data has been requested, and the data received for them so far
+	awaiting  map[chunking.Fingerprint]int64 // fingerprint -> size
+	received  []chunking.Chunk
+	batch     []chunking.Fingerprint // chunks of the batch, acknowledged once stored
+	lastBatch bool
+
+	checkpointed int64 // size at the last checkpoint of this file
+	resumed      bool  // restored from a checkpoint; the next segment must start at size
+
+	part *pb.FileRange // set when this is one range of a large file
+
+	// Archive-aware ingest: the splitter following the file as an archive,
+	// the offsets in data where member contents begin or end, and the bytes
+	// of a resumed file the splitter saw before its checkpoint
+	archive     *archive.Splitter
+	cuts        []int
+	archiveSeen int64
+}
+
+// file returns the pending file for a path, starting one if needed
+func (u *jobUpload) file(filePath string) *pendingFile {
+	file := u.files[filePath]
+	if file == nil {
+		file = &pendingFile{}
+		u.files[filePath] = file
+	}
+	return file
+}
+
+// addHole appends a hole, merging it with a preceding one
+func (f *pendingFile) addHole(size int64) {
+	if n := len(f.refs); n > 0 {
+		if prev, ok := parseHoleRef(f.refs[n-1]); ok {
+			f.refs[n-1] = holeRef(prev + size)
+			f.size += size
+			return
+		}
+	}
+	f.refs = append(f.refs, holeRef(size))
+	f.size += size
 }
 
 // NewIngestServer creates a new IngestServer instance
-func NewIngestServer(grpcPort, storageAddr string) *IngestServer {
+func NewIngestServer(grpcPort string) *IngestServer {
 	return &IngestServer{
-		chunker:     chunking.NewChunker(64, 8192),            // 64B min, 8KB max
+		chunker:     chunking.NewDefaultChunker(),
 		cache:       cache.NewDeduplicationCache(1000, 10000), // 1000 cache entries, 10000 filter capacity
+		manifests:   newManifestStore(nil),
+		owners:      newChunkOwners(nil),
+		history:     newJobHistory(nil),
+		checkpoints: newCheckpointStore(nil),
+		policies:    newChunkingPolicies(nil),
+		pipeline:    defaultPipelineConfig(),
 		backupJobs:  make(map[string]*BackupJobState),
+		restoreJobs: make(map[string]*restoreJob),
 		grpcPort:    grpcPort,
-		storageAddr: storageAddr,
+
+		checkpointInterval: 30 * time.Second,
+		checkpointBytes:    64 * 1024 * 1024,
 	}
 }
 
 // StreamBackup handles bidirectional streaming backup requests
 func (s *IngestServer) StreamBackup(stream pb.BackupService_StreamBackupServer) error {
 	var currentJob *BackupJobState
+	var upload *jobUpload // this stream's part of currentJob
 	var currentFile string
+	defer func() {
+		if upload != nil {
+			upload.close()
+		}
+	}()
 
 	for {
 		request, err := stream.Recv()
@@ -84,20 +213,98 @@ func (s *IngestServer) StreamBackup(stream pb.BackupService_StreamBackupServer)
 		case *pb.BackupRequest_StartBackup:
 			// Handle backup start
 			startReq := req.StartBackup
+			if currentJob != nil {
+				return status.Error(codes.FailedPrecondition, "Backup already started on this stream")
+			}
+			if startReq.Join {
+				// Another stream of a running job
+				if currentJob, err = s.joinJob(startReq); err != nil {
+					return err
+				}
+				upload = newJobUpload(currentJob, stream)
+				resp := &pb.BackupResponse{
+					ResponseType: &pb.BackupResponse_StatusUpdate{
+						StatusUpdate: &pb.BackupStatus{
+							BackupJobId:          startReq.BackupJobId,
+							Message:              "Joined backup job",
+							FingerprintAlgorithm: currentJob.chunker.HashAlgorithm().String(),
+							ChunkingProfile:      chunkingProfileMessage(currentJob.chunker.Profile()),
+						},
+					},
+				}
+				if err := stream.Send(resp); err != nil {
+					return status.Errorf(codes.Internal, "Failed to send status: %v", err)
+				}
+				log.Printf("Stream joined backup job: %s", startReq.BackupJobId)
+				continue
+			}
+
 			log.Printf("Starting backup job: %s", startReq.BackupJobId)
 			currentJob = &BackupJobState{
-				JobID:      startReq.BackupJobId,
-				ClientID:   startReq.ClientId,
-				StartTime:  time.Unix(startReq.Timestamp, 0),
-				Status:     "INITIATED",
-				FileBuffer: make(map[string][]byte),
-				FileChunks: make(map[string][]chunking.Chunk),
+				JobID:     startReq.BackupJobId,
+				ClientID:  startReq.ClientId,
+				StartTime: time.Unix(startReq.Timestamp, 0),
+				State:     jobstate.Pending,
+				Archives:  startReq.Archives,
+
+				lastCheckpoint: time.Now(),
+				ranges:         make(map[string]*rangedFile),
+			}
+			if currentJob.chunker, err = s.jobChunker(stream.Context(), startReq); err != nil {
+				return err
+			}
+			if currentJob.chunker != s.chunker {
+				log.Printf("Backup job %s chunks with profile %s by its chunking policy", startReq.BackupJobId, currentJob.chunker.Profile())
+			}
+			upload = newJobUpload(currentJob, stream)
+
+			// A resumed job continues from its checkpoint instead of being
+			// recorded again
+			var resumePoint *pb.ResumePoint
+			if startReq.Resume {
+				if resumePoint, err = s.resumeJob(stream.Context(), upload, startReq); err != nil {
+					return err
+				}
+				log.Printf("Resuming backup job %s after %q, partial file %q at %d",
+					startReq.BackupJobId, resumePoint.LastFile, resumePoint.PartialFile, resumePoint.PartialOffset)
+			}
+			if startReq.BaseJobId != "" {
+				if err := s.loadBaseJob(stream.Context(), currentJob, startReq.BaseJobId); err != nil {
+					return err
+				}
+				log.Printf("Backup job %s is incremental on %s (%d files)", startReq.BackupJobId, startReq.BaseJobId, len(currentJob.base))
+			}
+			if resumePoint == nil {
+				err := s.history.Start(stream.Context(), &db.BackupJob{
+					JobID:          startReq.BackupJobId,
+					ClientID:       startReq.ClientId,
+					BackupPolicyID: startReq.BackupPolicyId,
+					StartTime:      currentJob.StartTime,
+					Status:         currentJob.State,
+					SourceType:     startReq.SourceType,
+					SourceDetails:  startReq.SourceDetails,
+				})
+				if err != nil {
+					return status.Errorf(codes.Internal, "Failed to record backup job: %v", err)
+				}
+			}
+			if currentJob.State == jobstate.Pending {
+				if err := s.setJobState(stream.Context(), currentJob, jobstate.Running, jobstate.NoReason); err != nil {
+					return err
+				}
 			}
 
 			s.backupMutex.Lock()
 			s.backupJobs[startReq.BackupJobId] = currentJob
 			s.backupMutex.Unlock()
 
+			if resumePoint != nil {
+				resp := &pb.BackupResponse{ResponseType: &pb.BackupResponse_ResumePoint{ResumePoint: resumePoint}}
+				if err := stream.Send(resp); err != nil {
+					return status.Errorf(codes.Internal, "Failed to send resume point: %v", err)
+				}
+			}
+
 			// Send status update
 			statusResp := &pb.BackupResponse{
 				ResponseType: &pb.BackupResponse_StatusUpdate{
@@ -106,6 +313,10 @@ func (s *IngestServer) StreamBackup(stream pb.BackupService_StreamBackupServer)
 						Message:           "Backup initiated successfully",
 						BytesProcessed:    uint64(currentJob.BytesProcessed),
 						BytesDeduplicated: uint64(currentJob.BytesDeduplicated),
+						State:             jobStates[currentJob.State],
+
+						FingerprintAlgorithm: currentJob.chunker.HashAlgorithm().String(),
+						ChunkingProfile:      chunkingProfileMessage(currentJob.chunker.Profile()),
 					},
 				},
 			}
@@ -115,31 +326,128 @@ func (s *IngestServer) StreamBackup(stream pb.BackupService_StreamBackupServer)
 
 			log.Printf("Started backup job: %s for client: %s", startReq.BackupJobId, startReq.ClientId)
 
+		case *pb.BackupRequest_FileEntry:
+			// Regular files are recorded once their segments arrive; every
+			// other type is complete on its own
+			entry := req.FileEntry
+			if currentJob == nil {
+				return status.Error(codes.FailedPrecondition, "No active backup job")
+			}
+			if entry.Type == pb.FileType_FILE_TYPE_REGULAR {
+				file := &pendingFile{entry: entry}
+				if currentJob.Archives {
+					file.archive = archive.NewSplitter()
+				}
+				upload.files[entry.FilePath] = file
+				continue
+			}
+			if err := s.manifests.Put(stream.Context(), currentJob.JobID, entry, nil, 0); err != nil {
+				return s.abortJob(stream.Context(), currentJob, jobstate.InternalError, status.Errorf(codes.Internal, "Failed to record %s: %v", entry.FilePath, err))
+			}
+			currentJob.mutex.Lock()
+			currentJob.EntriesProcessed++
+			currentJob.mutex.Unlock()
+			if err := s.entryDone(stream.Context(), currentJob, entry.FilePath); err != nil {
+				return s.abortJob(stream.Context(), currentJob, jobstate.InternalError, err)
+			}
+
 		case *pb.BackupRequest_FileSegment:
 			// Handle file segment
 			segment := req.FileSegment
 			currentFile = segment.FilePath
-			log.Printf("Received file segment: %s, size: %d, offset: %d, isLast: %v",
-				segment.FilePath, len(segment.Data), segment.Offset, segment.IsLastSegment)
+			log.Printf("Received file segment: %s, size: %d, hole: %d, offset: %d, isLast: %v",
+				segment.FilePath, len(segment.Data), segment.HoleSize, segment.Offset, segment.IsLastSegment)
 
 			if currentJob == nil {
 				return status.Error(codes.FailedPrecondition, "No active backup job")
 			}
 
-			// Accumulate file data
-			if currentJob.FileBuffer[currentFile] == nil {
-				currentJob.FileBuffer[currentFile] = make([]byte, 0)
+			file := upload.file(currentFile)
+			if file.resumed {
+				if segment.Offset != uint64(file.size) {
+					return status.Errorf(codes.InvalidArgument, "Resumed file %s must continue at offset %d, not %d", currentFile, file.size, segment.Offset)
+				}
+				file.resumed = false
+			}
+			if file.part != nil && segment.Offset != file.part.Offset+uint64(file.size)+uint64(len(file.data)) {
+				return status.Errorf(codes.InvalidArgument, "Segment of %s at offset %d is out of order in range %d", currentFile, segment.Offset, file.part.Index)
 			}
-			currentJob.FileBuffer[currentFile] = append(currentJob.FileBuffer[currentFile], segment.Data...)
 
-			// Process complete files
+			// Accumulate file data; a hole ends the current data run, which
+			// is stored before the hole is recorded
+			if segment.HoleSize > 0 {
+				if err := s.storeFileData(stream.Context(), upload, currentFile, file); err != nil {
+					return s.abortJob(stream.Context(), currentJob, jobstate.StorageError, status.Errorf(codes.Internal, "Failed to process file: %v", err))
+				}
+				file.skipArchive(segment.HoleSize)
+				file.addHole(int64(segment.HoleSize))
+			} else {
+				file.writeArchive(segment.Data)
+				file.data = append(file.data, segment.Data...)
+				if int64(len(file.data)) >= s.checkpointBytes && !segment.IsLastSegment {
+					if err := s.storeBufferedData(stream.Context(), upload, currentFile, file); err != nil {
+						return s.abortJob(stream.Context(), currentJob, jobstate.StorageError, status.Errorf(codes.Internal, "Failed to process file: %v", err))
+					}
+				}
+			}
+			if !segment.IsLastSegment {
+				if err := s.checkpointPartial(stream.Context(), currentJob, currentFile, file); err != nil {
+					return s.abortJob(stream.Context(), currentJob, jobstate.InternalError, err)
+				}
+			}
+
+			// Process complete files. A source of unknown size is recorded
+			// with the size received; a file that does not end at the size it
+			// was announced with changed while it was read.
 			if segment.IsLastSegment {
+				received := file.size + int64(len(file.data))
+				if segment.SizeUnknown {
+					log.Printf("File %s from a source of unknown size ended at %d bytes", currentFile, received)
+				} else if file.part == nil && segment.FileSize != uint64(received) {
+					log.Printf("Warning: File %s was announced with %d bytes but %d were received", currentFile, segment.FileSize, received)
+				}
 				log.Printf("Processing complete file: %s", currentFile)
-				if err := s.processFile(currentJob, currentFile, stream); err != nil {
-					return status.Errorf(codes.Internal, "Failed to process file: %v", err)
+				if err := s.processFile(upload, currentFile, stream); err != nil {
+					// A range that does not fit the file is the client's error
+					if status.Code(err) != codes.InvalidArgument {
+						err = status.Errorf(codes.Internal, "Failed to process file: %v", err)
+					}
+					return s.abortJob(stream.Context(), currentJob, jobstate.StorageError, err)
 				}
 			}
 
+		case *pb.BackupRequest_FileRange:
+			if currentJob == nil {
+				return status.Error(codes.FailedPrecondition, "No active backup job")
+			}
+			if err := s.receiveFileRange(upload, req.FileRange); err != nil {
+				return s.abortJob(stream.Context(), currentJob, jobstate.StorageError, err)
+			}
+
+		case *pb.BackupRequest_UnchangedFile:
+			if currentJob == nil {
+				return status.Error(codes.FailedPrecondition, "No active backup job")
+			}
+			if err := s.receiveUnchangedFile(stream.Context(), currentJob, req.UnchangedFile.GetEntry()); err != nil {
+				return s.abortJob(stream.Context(), currentJob, jobstate.InternalError, err)
+			}
+
+		case *pb.BackupRequest_ChunkRefs:
+			if currentJob == nil {
+				return status.Error(codes.FailedPrecondition, "No active backup job")
+			}
+			if err := s.receiveChunkRefs(stream, upload, req.ChunkRefs); err != nil {
+				return s.abortJob(stream.Context(), currentJob, jobstate.StorageError, err)
+			}
+
+		case *pb.BackupRequest_ChunkData:
+			if currentJob == nil {
+				return status.Error(codes.FailedPrecondition, "No active backup job")
+			}
+			if err := s.receiveChunkData(stream, upload, req.ChunkData); err != nil {
+				return s.abortJob(stream.Context(), currentJob, jobstate.StorageError, err)
+			}
+
 		case *pb.BackupRequest_EndBackup:
 			// Handle backup end
 			endReq := req.EndBackup
@@ -147,84 +455,111 @@ func (s *IngestServer) StreamBackup(stream pb.BackupService_StreamBackupServer)
 			if currentJob == nil {
 				return status.Error(codes.FailedPrecondition, "No active backup job")
 			}
+			state, err := endState(endReq)
+			if err != nil {
+				return status.Errorf(codes.InvalidArgument, "Invalid end of backup job %s: %v", currentJob.JobID, err)
+			}
 
-			currentJob.Status = endReq.Status
+			// Other streams of the job must have finished their uploads
+			currentJob.mutex.Lock()
+			active, incomplete := currentJob.active, len(currentJob.ranges)
+			if active > 1 {
+				currentJob.mutex.Unlock()
+				return status.Errorf(codes.FailedPrecondition, "Backup job %s ended while %d other streams are uploading", currentJob.JobID, active-1)
+			}
+			currentJob.ended = true
+			currentJob.mutex.Unlock()
+			if incomplete > 0 {
+				log.Printf("Warning: Backup job %s ended with %d files missing ranges; they are not in the snapshot", currentJob.JobID, incomplete)
+				if state == jobstate.Completed {
+					state = jobstate.Partial
+				}
+			}
+
+			// A job that produced a snapshot is finalizing until its data
+			// is durable
+			reason := jobstate.NoReason
+			if state == jobstate.Failed {
+				reason = jobstate.ClientReported
+			}
+			if state.Successful() {
+				if err := s.setJobState(stream.Context(), currentJob, jobstate.Finalizing, jobstate.NoReason); err != nil {
+					return err
+				}
+			}
+
+			// Seal the partly filled container so the backup is durable
+			if err := s.flushContainer(stream.Context()); err != nil {
+				s.failJob(stream.Context(), currentJob, jobstate.StorageError)
+				return status.Errorf(codes.Internal, "Failed to seal container: %v", err)
+			}
+
+			currentJob.mutex.Lock()
+			stats := currentJob.stats()
+			currentJob.mutex.Unlock()
+			if err := s.history.RecordStats(stream.Context(), currentJob.JobID, stats); err != nil {
+				log.Printf("Warning: Failed to record statistics of %s: %v", currentJob.JobID, err)
+			}
+			if err := s.setJobState(stream.Context(), currentJob, state, reason); err != nil {
+				return err
+			}
+			s.removeJob(currentJob)
+			if err := s.checkpoints.Delete(stream.Context(), currentJob.JobID); err != nil {
+				log.Printf("Warning: Failed to delete checkpoint of %s: %v", currentJob.JobID, err)
+			}
 
 			// Send final status
+			currentJob.mutex.Lock()
 			finalStatus := &pb.BackupResponse{
 				ResponseType: &pb.BackupResponse_StatusUpdate{
 					StatusUpdate: &pb.BackupStatus{
 						BackupJobId: endReq.BackupJobId,
-						Message: fmt.Sprintf("Backup completed. Processed %d files, %d chunks",
-							currentJob.FilesProcessed, currentJob.ChunksProcessed),
+						Message: fmt.Sprintf("Backup %s. Processed %d files, %d unchanged files, %d other entries, %d chunks (%d stored as deltas)",
+							strings.ToLower(string(currentJob.State)), currentJob.FilesProcessed, currentJob.FilesUnchanged, currentJob.EntriesProcessed, currentJob.ChunksProcessed, currentJob.ChunksDelta),
 						BytesProcessed:    uint64(currentJob.BytesProcessed),
 						BytesDeduplicated: uint64(currentJob.BytesDeduplicated),
+						BytesDeltaSaved:   uint64(currentJob.BytesDeltaSaved),
+						State:             jobStates[currentJob.State],
+						FailureReason:     failureReasons[currentJob.FailureReason],
 					},
 				},
 			}
+			currentJob.mutex.Unlock()
 			if err := stream.Send(finalStatus); err != nil {
 				return status.Errorf(codes.Internal, "Failed to send final status: %v", err)
 			}
 
-			log.Printf("Completed backup job: %s", endReq.BackupJobId)
+			currentJob.mutex.Lock()
+			log.Printf("Backup job %s ended %s (ingest pipeline: %s)", endReq.BackupJobId, currentJob.State, currentJob.pipeline)
+			currentJob.mutex.Unlock()
 		}
 	}
 
 	return nil
 }
 
-// processFile processes a complete file by chunking and deduplicating
-func (s *IngestServer) processFile(job *BackupJobState, filePath string, stream pb.BackupService_StreamBackupServer) error {
-	fileData := job.FileBuffer[filePath]
-
-	// Chunk the file
-	chunks, err := s.chunker.ChunkData(fileData)
-	if err != nil {
-		return fmt.Errorf("failed to chunk file %s: %w", filePath, err)
+// processFile stores the remaining data of a complete file and records it
+// in the job manifest. A range of a large file is recorded once all ranges
+// of the file are complete.
+func (s *IngestServer) processFile(upload *jobUpload, filePath string, stream pb.BackupService_StreamBackupServer) error {
+	job := upload.job
+	file := upload.files[filePath]
+	if err := s.storeFileData(stream.Context(), upload, filePath, file); err != nil {
+		return err
 	}
 
-	job.FileChunks[filePath] = chunks
-	job.FilesProcessed++
-
-	log.Printf("Processing file: %s (%d bytes, %d chunks)", filePath, len(fileData), len(chunks))
-
-	// Process each chunk
-	for i, chunk := range chunks {
-		job.ChunksProcessed++
-		job.BytesProcessed += chunk.Size
-
-		// Check if chunk already exists (deduplication)
-		if _, exists := s.cache.GetChunkMetadata(chunk.Fingerprint); exists {
-			job.BytesDeduplicated += chunk.Size
-			log.Printf("  Chunk %d: DEDUPLICATED (fingerprint: %s)", i, chunk.Fingerprint[:16])
-			continue
-		}
-
-		// Check database for existing chunk
-		if s.dbClient != nil {
-			if dbMetadata, err := s.dbClient.GetChunkMetadataByFingerprint(context.Background(), chunk.Fingerprint); err == nil && dbMetadata != nil {
-				job.BytesDeduplicated += chunk.Size
-				s.cache.PutChunkMetadata(chunk.Fingerprint, &cache.ChunkMetadata{
-					Fingerprint:        dbMetadata.Fingerprint,
-					StorageLocation:    dbMetadata.StorageLocation,
-					Size:               int64(dbMetadata.Size),
-					CreationTime:       dbMetadata.CreationTime,
-					LastReferencedTime: dbMetadata.LastReferencedTime,
-				})
-				log.Printf("  Chunk %d: DEDUPLICATED (from DB, fingerprint: %s)", i, chunk.Fingerprint[:16])
-				continue
-			}
-		}
-
-		// Store unique chunk
-		if err := s.storeUniqueChunk(chunk); err != nil {
-			return fmt.Errorf("failed to store chunk %d: %w", i, err)
-		}
-
-		log.Printf("  Chunk %d: STORED (fingerprint: %s)", i, chunk.Fingerprint[:16])
+	// The file data is no longer needed once its chunks are stored, and a
+	// session may stream many files
+	delete(upload.files, filePath)
+	if file.part != nil {
+		return s.finishRange(stream.Context(), job, filePath, file)
+	}
+	if err := s.recordFile(stream.Context(), job, filePath, file.entry, file.refs, file.size); err != nil {
+		return err
 	}
 
 	// Send progress update
+	job.mutex.Lock()
 	statusResp := &pb.BackupResponse{
 		ResponseType: &pb.BackupResponse_StatusUpdate{
 			StatusUpdate: &pb.BackupStatus{
@@ -232,10 +567,12 @@ func (s *IngestServer) processFile(job *BackupJobState, filePath string, stream
 				CurrentFile:       filePath,
 				BytesProcessed:    uint64(job.BytesProcessed),
 				BytesDeduplicated: uint64(job.BytesDeduplicated),
+				BytesDeltaSaved:   uint64(job.BytesDeltaSaved),
 				Message:           fmt.Sprintf("Processed file: %s", filePath),
 			},
 		},
 	}
+	job.mutex.Unlock()
 	if err := stream.Send(statusResp); err != nil {
 		return fmt.Errorf("failed to send status: %v", err)
 	}
@@ -243,66 +580,261 @@ func (s *IngestServer) processFile(job *BackupJobState, filePath string, stream
 	return nil
 }
 
-// storeUniqueChunk stores a unique chunk via the Data Storage Node
-func (s *IngestServer) storeUniqueChunk(chunk chunking.Chunk) error {
-	// For now, we'll simulate storage since we don't have a running Data Storage Node
-	// In a real implementation, you'd make a gRPC call to the Data Storage Node
+// recordFile records a complete regular file in the job manifest
+func (s *IngestServer) recordFile(ctx context.Context, job *BackupJobState, filePath string, entry *pb.FileEntry, refs []string, size int64) error {
+	if entry == nil {
+		entry = regularEntry(filePath, size)
+	}
+	entry.Size = uint64(size)
+	entry.ContentHash = contentHash(refs)
+	if err := s.manifests.Put(ctx, job.JobID, entry, refs, job.chunker.Profile().Version); err != nil {
+		return fmt.Errorf("failed to record %s in manifest: %w", filePath, err)
+	}
+	job.mutex.Lock()
+	job.FilesProcessed++
+	job.mutex.Unlock()
+	return s.entryDone(ctx, job, filePath)
+}
+
+// storeFileData chunks and deduplicates the buffered data of a file and
+// stores its new chunks through the ingest pipeline. Chunks holding only
+// zeros are recorded as holes rather than stored.
+func (s *IngestServer) storeFileData(ctx context.Context, upload *jobUpload, filePath string, file *pendingFile) error {
+	job := upload.job
+	if len(file.data) == 0 {
+		return nil
+	}
 
-	// Create metadata for the chunk
-	metadata := &cache.ChunkMetadata{
-		Fingerprint:        chunk.Fingerprint,
-		StorageLocation:    fmt.Sprintf("minio://dedupe-chunks/%s", chunk.Fingerprint),
-		Size:               chunk.Size,
-		CreationTime:       time.Now(),
-		LastReferencedTime: time.Now(),
+	log.Printf("Processing file: %s (%d bytes)", filePath, len(file.data))
+	result, err := s.runPipeline(ctx, upload, filePath, file)
+	file.data, file.cuts = nil, nil
+	if err != nil {
+		return fmt.Errorf("failed to process data of %s: %w", filePath, err)
 	}
+	log.Printf("  Stored %d new chunks, %d as deltas", result.stored, result.deltas)
+	log.Printf("  Pipeline: %s", result.stats)
+	job.mutex.Lock()
+	job.pipeline.add(result.stats)
+	job.mutex.Unlock()
+
+	// The client has shown it holds these chunks, so later source-side
+	// deduplicated backups may refer to them by fingerprint
+	return s.owners.Add(ctx, job.ClientID, result.owned)
+}
 
-	// Add to cache
-	s.cache.PutChunkMetadata(chunk.Fingerprint, metadata)
+// lookupChunk returns the metadata of a stored chunk from the cache or the
+// database, or nil if it is not stored. Chunks found in the database are
+// added to the cache.
+func (s *IngestServer) lookupChunk(ctx context.Context, fingerprint chunking.Fingerprint) (*cache.ChunkMetadata, bool) {
+	if metadata, exists := s.cache.GetChunkMetadata(fingerprint); exists {
+		return metadata, false
+	}
+	if s.dbClient == nil {
+		return nil, false
+	}
+	dbMetadata, err := s.dbClient.GetChunkMetadataByFingerprint(ctx, fingerprint)
+	if err != nil || dbMetadata == nil {
+		return nil, false
+	}
+	return s.cacheChunkMetadata(dbMetadata), true
+}
 
-	// Store in database if available
-	if s.dbClient != nil {
-		dbMetadata := &db.ChunkMetadata{
-			Fingerprint:        metadata.Fingerprint,
-			StorageLocation:    metadata.StorageLocation,
-			Size:               int(metadata.Size),
-			CreationTime:       metadata.CreationTime,
-			LastReferencedTime: metadata.LastReferencedTime,
+// lookupChunks looks up a batch of chunks like lookupChunk, with a single
+// database query for the chunks not in the cache. It returns the metadata of
+// the stored chunks and which of them were found in the database.
+func (s *IngestServer) lookupChunks(ctx context.Context, fingerprints []chunking.Fingerprint) (map[chunking.Fingerprint]*cache.ChunkMetadata, map[chunking.Fingerprint]bool) {
+	found := make(map[chunking.Fingerprint]*cache.ChunkMetadata)
+	fromDB := make(map[chunking.Fingerprint]bool)
+	var misses []chunking.Fingerprint
+	for _, fingerprint := range fingerprints {
+		if metadata, exists := s.cache.GetChunkMetadata(fingerprint); exists {
+			found[fingerprint] = metadata
+		} else {
+			misses = append(misses, fingerprint)
 		}
-		if err := s.dbClient.InsertChunkMetadata(context.Background(), dbMetadata); err != nil {
-			log.Printf("Warning: Failed to store chunk metadata in DB: %v", err)
+	}
+	if s.dbClient == nil || len(misses) == 0 {
+		return found, fromDB
+	}
+	stored, err := s.dbClient.GetChunkMetadataBatch(ctx, misses)
+	if err != nil {
+		log.Printf("Warning: Failed to look up %d chunks in DB: %v", len(misses), err)
+		return found, fromDB
+	}
+	for fingerprint, dbMetadata := range stored {
+		found[fingerprint] = s.cacheChunkMetadata(dbMetadata)
+		fromDB[fingerprint] = true
+	}
+	return found, fromDB
+}
+
+// cacheChunkMetadata adds the metadata of a chunk read from the database to
+// the cache
+func (s *IngestServer) cacheChunkMetadata(dbMetadata *db.ChunkMetadata) *cache.ChunkMetadata {
+	metadata := &cache.ChunkMetadata{
+		Fingerprint:        dbMetadata.Fingerprint,
+		StorageLocation:    dbMetadata.StorageLocation,
+		Size:               int64(dbMetadata.Size),
+		CreationTime:       dbMetadata.CreationTime,
+		LastReferencedTime: dbMetadata.LastReferencedTime,
+		StorageNodes:       dbMetadata.StorageNodes,
+		BaseFingerprint:    dbMetadata.BaseFingerprint,
+		DeltaDepth:         dbMetadata.DeltaDepth,
+	}
+	s.cache.PutChunkMetadata(dbMetadata.Fingerprint, metadata)
+	return metadata
+}
+
+// storeUniqueChunks writes a batch of unique chunks to their replica set of
+// Data Storage Nodes, or packs them into erasure-coded containers, and
+// records their metadata and placement
+func (s *IngestServer) storeUniqueChunks(ctx context.Context, chunks []chunking.Chunk) error {
+	if s.containers != nil {
+		sealed, err := s.containers.Add(ctx, chunks)
+		if err != nil {
+			return err
+		}
+		for i := range sealed {
+			if err := s.recordContainer(ctx, &sealed[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var placements map[chunking.Fingerprint][]string
+	if s.storage != nil {
+		var err error
+		if placements, err = s.storage.StoreChunks(ctx, chunks); err != nil {
+			return err
 		}
 	}
 
+	now := time.Now()
+	for _, chunk := range chunks {
+		s.recordChunkMetadata(&db.ChunkMetadata{
+			Fingerprint:        chunk.Fingerprint,
+			StorageLocation:    fmt.Sprintf("minio://dedupe-chunks/%s", chunk.Fingerprint),
+			Size:               int(chunk.Size),
+			CreationTime:       now,
+			LastReferencedTime: now,
+			StorageNodes:       placements[chunk.Fingerprint],
+		})
+	}
 	return nil
 }
 
-// InitiateRestore handles restore initiation requests
-func (s *IngestServer) InitiateRestore(ctx context.Context, req *pb.RestoreRequest) (*pb.RestoreResponse, error) {
-	// TODO: Implement restore logic
-	return &pb.RestoreResponse{
-		RestoreJobId: fmt.Sprintf("restore-%d", time.Now().Unix()),
-		Status:       "INITIATED",
-		Message:      "Restore initiated (not yet implemented)",
-	}, nil
+// flushContainer seals the open container when erasure coding is enabled
+func (s *IngestServer) flushContainer(ctx context.Context) error {
+	if s.containers == nil {
+		return nil
+	}
+	container, err := s.containers.Flush(ctx)
+	if err != nil || container == nil {
+		return err
+	}
+	return s.recordContainer(ctx, container)
 }
 
-// StreamRestoreData handles restore data streaming
-func (s *IngestServer) StreamRestoreData(stream pb.BackupService_StreamRestoreDataServer) error {
-	// TODO: Implement restore data streaming
-	return status.Error(codes.Unimplemented, "Restore data streaming not yet implemented")
+// recordChunkMetadata adds a stored chunk and its location to the cache and database
+func (s *IngestServer) recordChunkMetadata(metadata *db.ChunkMetadata) {
+	// Add to cache
+	s.cache.PutChunkMetadata(metadata.Fingerprint, &cache.ChunkMetadata{
+		Fingerprint:        metadata.Fingerprint,
+		StorageLocation:    metadata.StorageLocation,
+		Size:               int64(metadata.Size),
+		CreationTime:       metadata.CreationTime,
+		LastReferencedTime: metadata.LastReferencedTime,
+		StorageNodes:       metadata.StorageNodes,
+		BaseFingerprint:    metadata.BaseFingerprint,
+		DeltaDepth:         metadata.DeltaDepth,
+	})
+
+	// Store in database if available
+	if s.dbClient != nil {
+		if err := s.dbClient.InsertChunkMetadata(context.Background(), metadata); err != nil {
+			log.Printf("Warning: Failed to store chunk metadata in DB: %v", err)
+		}
+	}
 }
 
 func main() {
 	// Get configuration from environment variables
 	grpcPort := getEnv("GRPC_PORT", "50051")
-	storageAddr := getEnv("STORAGE_NODE_ADDR", "localhost:50052")
 	cockroachAddr := getEnv("COCKROACHDB_ADDR", "")
 
 	log.Printf("Starting Ingest Node on port %s", grpcPort)
 
 	// Create server
-	server := NewIngestServer(grpcPort, storageAddr)
+	server := NewIngestServer(grpcPort)
+
+	// Connect to the Data Storage Nodes
+	storage, conns, err := newStorageCluster()
+	if err != nil {
+		log.Fatalf("Failed to configure Data Storage Nodes: %v", err)
+	}
+	for _, conn := range conns {
+		defer conn.Close()
+	}
+	server.storage = storage
+
+	checkpointInterval, err := time.ParseDuration(getEnv("CHECKPOINT_INTERVAL", "30s"))
+	if err != nil {
+		log.Fatalf("Invalid CHECKPOINT_INTERVAL: %v", err)
+	}
+	server.checkpointInterval = checkpointInterval
+	server.checkpointBytes = int64(getEnvInt("CHECKPOINT_BYTES", 64*1024*1024))
+
+	// Size the ingest pipeline
+	pipeline := pipelineConfig{
+		hashWorkers: getEnvInt("INGEST_HASH_WORKERS", server.pipeline.hashWorkers),
+		batchSize:   getEnvInt("INGEST_BATCH_SIZE", server.pipeline.batchSize),
+		queueDepth:  getEnvInt("INGEST_QUEUE_DEPTH", server.pipeline.queueDepth),
+	}
+	if pipeline.hashWorkers < 1 || pipeline.batchSize < 1 || pipeline.queueDepth < 1 {
+		log.Fatalf("INGEST_HASH_WORKERS, INGEST_BATCH_SIZE and INGEST_QUEUE_DEPTH must be positive")
+	}
+	server.pipeline = pipeline
+	log.Printf("Ingest pipeline: %d hash workers, %d chunks per batch, %d batches per queue", pipeline.hashWorkers, pipeline.batchSize, pipeline.queueDepth)
+
+	// Track storage node status so writes avoid draining and full nodes
+	statusInterval, err := time.ParseDuration(getEnv("STATUS_POLL_INTERVAL", "30s"))
+	if err != nil {
+		log.Fatalf("Invalid STATUS_POLL_INTERVAL: %v", err)
+	}
+	storage.RefreshStatus(context.Background())
+	go storage.RunStatusPolling(context.Background(), statusInterval)
+
+	// Pack chunks into erasure-coded containers instead of replicating them
+	var containers *erasure.Store
+	switch mode := getEnv("STORAGE_MODE", "replication"); mode {
+	case "replication":
+	case "erasure":
+		containers, err = newContainerStore(storage)
+		if err != nil {
+			log.Fatalf("Failed to configure erasure coding: %v", err)
+		}
+		server.containers = newContainerPacker(containers, getEnvInt("CONTAINER_SIZE", 4*1024*1024))
+	default:
+		log.Fatalf("Invalid STORAGE_MODE %q, expected replication or erasure", mode)
+	}
+
+	// Hash algorithm of chunk fingerprints; a repository in CockroachDB
+	// decides it when it is created
+	configured := getEnv("FINGERPRINT_ALGORITHM", "")
+	algorithm, err := chunking.ParseHashAlgorithm(configured)
+	if err != nil {
+		log.Fatalf("Invalid FINGERPRINT_ALGORITHM: %v", err)
+	}
+	server.chunker.SetHashAlgorithm(algorithm)
+
+	// Chunking profile; without CockroachDB the environment decides it
+	profile := profileFromEnv(server.chunker.Profile())
+	chunker, err := chunking.NewProfileChunker(profile)
+	if err != nil {
+		log.Fatalf("Invalid chunking settings: %v", err)
+	}
+	server.chunker = chunker
 
 	// Initialize database client if address provided
 	if cockroachAddr != "" {
@@ -311,10 +843,102 @@ func main() {
 			log.Printf("Warning: Failed to connect to CockroachDB: %v", err)
 		} else {
 			server.dbClient = dbClient
+			server.manifests = newManifestStore(dbClient)
+			server.owners = newChunkOwners(dbClient)
+			server.history = newJobHistory(dbClient)
+			server.checkpoints = newCheckpointStore(dbClient)
+			server.policies = newChunkingPolicies(dbClient)
 			log.Printf("Connected to CockroachDB at %s", cockroachAddr)
+
+			// The repository keeps the algorithm it was created with
+			stored, err := dbClient.InitRepositorySetting(context.Background(), "fingerprint_algorithm", algorithm.String())
+			if err != nil {
+				log.Fatalf("Failed to read the repository's fingerprint algorithm: %v", err)
+			}
+			if algorithm, err = chunking.ParseHashAlgorithm(stored); err != nil {
+				log.Fatalf("Invalid fingerprint algorithm of the repository: %v", err)
+			}
+			if configured != "" && !strings.EqualFold(configured, stored) {
+				log.Fatalf("FINGERPRINT_ALGORITHM is %s but the repository was created with %s; fingerprints of different algorithms cannot be mixed", configured, stored)
+			}
+			server.chunker.SetHashAlgorithm(algorithm)
+
+			// The repository keeps its chunking profile too, until it is
+			// explicitly migrated to a new one
+			profile, err := repositoryProfile(context.Background(), dbClient, algorithm, getEnv("CHUNKING_PROFILE_MIGRATE", "false") == "true")
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			if server.chunker, err = chunking.NewProfileChunker(profile); err != nil {
+				log.Fatalf("Invalid chunking profile %d of the repository: %v", profile.Version, err)
+			}
+
+			// Move chunks to their current replica set in case storage
+			// nodes joined or left since the last run
+			if containers == nil && getEnv("REBALANCE_ON_START", "true") == "true" {
+				go func() {
+					report, err := storage.Rebalance(context.Background(), dbClient)
+					if err != nil {
+						log.Printf("Warning: Rebalance failed: %v", err)
+						return
+					}
+					log.Printf("Rebalance completed: %d chunks scanned, %d moved, %d replicas copied, %d freed, %d failures",
+						report.ChunksScanned, report.ChunksMoved, report.ReplicasCopied, report.ReplicasFreed, report.Failures)
+				}()
+			}
+
+			// Rebuild lost container shards in the background
+			if containers != nil {
+				if interval := getEnv("REPAIR_INTERVAL", ""); interval != "" {
+					d, err := time.ParseDuration(interval)
+					if err != nil {
+						log.Fatalf("Invalid REPAIR_INTERVAL %q: %v", interval, err)
+					}
+					go containers.RunRepairPeriodically(context.Background(), dbClient, d)
+					log.Printf("Container repair scheduled every %s", d)
+				}
+			}
 		}
 	}
 
+	log.Printf("Chunking profile: %s", server.chunker.Profile())
+
+	// Chunking policies of backup policies and source types
+	policies, err := parseChunkingPolicies(getEnv("CHUNKING_POLICIES", ""), server.chunker.HashAlgorithm())
+	if err != nil {
+		log.Fatalf("Invalid CHUNKING_POLICIES: %v", err)
+	}
+	for key, profile := range policies {
+		profile, err := server.policies.Set(context.Background(), key, profile, server.chunker.Profile())
+		if err != nil {
+			log.Fatalf("Failed to set the chunking policy of %q/%q: %v", key.policyID, key.sourceType, err)
+		}
+		log.Printf("Chunking policy: backup policy %q, source type %q chunk with profile %s", key.policyID, key.sourceType, profile)
+	}
+
+	// Store near-duplicate chunks as deltas against similar stored chunks
+	if getEnv("DELTA_COMPRESSION", "false") == "true" {
+		server.deltas = newDeltaCompressor(server.dbClient, getEnvInt("DELTA_MAX_DEPTH", 3), getEnvInt("DELTA_CACHE_BYTES", 64*1024*1024))
+		log.Printf("Delta compression enabled, chains up to %d deltas deep", server.deltas.maxDepth)
+	}
+
+	log.Printf("Chunk fingerprints use %s", server.chunker.HashAlgorithm())
+
+	// Fail jobs whose streams disappeared without ending them
+	staleTimeout, err := time.ParseDuration(getEnv("JOB_STALE_TIMEOUT", "30m"))
+	if err != nil || staleTimeout <= 0 {
+		log.Fatalf("Invalid JOB_STALE_TIMEOUT: must be a positive duration")
+	}
+	go server.RunJobReaper(context.Background(), staleTimeout)
+	log.Printf("Backup jobs with no stream for %s are failed", staleTimeout)
+
+	// Drop restores whose client never opened all of their streams
+	restoreTimeout, err := time.ParseDuration(getEnv("RESTORE_JOB_TIMEOUT", "15m"))
+	if err != nil || restoreTimeout <= 0 {
+		log.Fatalf("Invalid RESTORE_JOB_TIMEOUT: must be a positive duration")
+	}
+	go server.RunRestoreReaper(context.Background(), restoreTimeout)
+
 	// Create gRPC server
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", grpcPort))
 	if err != nil {
@@ -323,6 +947,7 @@ func main() {
 
 	grpcServer := grpc.NewServer()
 	pb.RegisterBackupServiceServer(grpcServer, server)
+	pb.RegisterCatalogServiceServer(grpcServer, NewCatalogServer(server))
 
 	log.Printf("Ingest Node ready to accept connections")
 	if err := grpcServer.Serve(lis); err != nil {
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/db"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// holePrefix marks a chunk reference in a manifest that is a hole of the
+// given number of zero bytes rather than a chunk fingerprint
+const holePrefix = "hole:"
+
+// holeRef returns the chunk reference for a hole of size bytes
+func holeRef(size int64) string {
+	return holePrefix + strconv.FormatInt(size, 10)
+}
+
+// parseHoleRef returns the size of a hole reference
+func parseHoleRef(ref string) (int64, bool) {
+	text, ok := strings.CutPrefix(ref, holePrefix)
+	if !ok {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(text, 10, 64)
+	return size, err == nil
+}
+
+// contentHash returns the whole-file hash of a regular file from its chunk
+// references
+func contentHash(refs []string) string {
+	hash := chunking.NewFileHash()
+	for _, ref := range refs {
+		if size, ok := parseHoleRef(ref); ok {
+			hash.AddHole(uint64(size))
+		} else {
+			hash.AddChunk(ref)
+		}
+	}
+	return hash.Sum()
+}
+
+// manifestStore keeps the per-file manifests of backup jobs, in CockroachDB
+// when it is configured and in memory otherwise
+type manifestStore struct {
+	dbClient *db.DB
+
+	mutex sync.RWMutex
+	jobs  map[string]map[string]*db.FileManifest // job ID -> file path -> manifest
+}
+
+// newManifestStore creates a manifest store; dbClient may be nil
+func newManifestStore(dbClient *db.DB) *manifestStore {
+	return &manifestStore{dbClient: dbClient, jobs: make(map[string]map[string]*db.FileManifest)}
+}
+
+// Put records an entry of a backup job together with its chunk references:
+// fingerprints of its chunks and holes, in file order, and the version of the
+// chunking profile they were made with
+func (m *manifestStore) Put(ctx context.Context, jobID string, entry *pb.FileEntry, chunks []string, profile int) error {
+	manifest, err := newFileManifest(jobID, entry, chunks, profile)
+	if err != nil {
+		return err
+	}
+	if m.dbClient != nil {
+		return m.dbClient.InsertFileManifest(ctx, manifest)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.jobs[jobID] == nil {
+		m.jobs[jobID] = make(map[string]*db.FileManifest)
+	}
+	m.jobs[jobID][entry.FilePath] = manifest
+	return nil
+}
+
+// List returns the manifest of a backup job ordered by path
+func (m *manifestStore) List(ctx context.Context, jobID string) ([]db.FileManifest, error) {
+	if m.dbClient != nil {
+		return m.dbClient.ListFileManifests(ctx, jobID)
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	manifests := make([]db.FileManifest, 0, len(m.jobs[jobID]))
+	for _, manifest := range m.jobs[jobID] {
+		manifests = append(manifests, *manifest)
+	}
+	sort.Slice(manifests, func(i, j int) bool { return manifests[i].FilePath < manifests[j].FilePath })
+	return manifests, nil
+}
+
+// Page returns a page of the manifest of a backup job, ordered by path
+func (m *manifestStore) Page(ctx context.Context, jobID string, page db.FileManifestPage) ([]db.FileManifest, error) {
+	if m.dbClient != nil {
+		return m.dbClient.PageFileManifests(ctx, jobID, page)
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	var manifests []db.FileManifest
+	for filePath, manifest := range m.jobs[jobID] {
+		rest, ok := strings.CutPrefix(filePath, page.Prefix)
+		if !ok || filePath <= page.AfterPath || (page.ChildrenOnly && strings.Contains(rest, "/")) {
+			continue
+		}
+		manifests = append(manifests, *manifest)
+	}
+	sort.Slice(manifests, func(i, j int) bool { return manifests[i].FilePath < manifests[j].FilePath })
+	if len(manifests) > page.Limit {
+		manifests = manifests[:page.Limit]
+	}
+	return manifests, nil
+}
+
+// Versions returns the entries recorded for a path in any of a set of backup
+// jobs, in no particular order
+func (m *manifestStore) Versions(ctx context.Context, jobIDs []string, filePath string) ([]db.FileManifest, error) {
+	if m.dbClient != nil {
+		return m.dbClient.GetFileVersions(ctx, jobIDs, filePath)
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	var manifests []db.FileManifest
+	for _, jobID := range jobIDs {
+		if manifest := m.jobs[jobID][filePath]; manifest != nil {
+			manifests = append(manifests, *manifest)
+		}
+	}
+	return manifests, nil
+}
+
+// Search returns the paths a search matches in a set of backup jobs, ordered
+// by path, with the jobs recording each
+func (m *manifestStore) Search(ctx context.Context, search db.FileSearch) ([]db.FileMatch, error) {
+	if m.dbClient != nil {
+		return m.dbClient.SearchFileManifests(ctx, search)
+	}
+
+	like, err := likeRegexp(search.Like)
+	if err != nil {
+		return nil, err
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	jobIDs := make(map[string][]string)
+	for _, jobID := range search.JobIDs {
+		for filePath := range m.jobs[jobID] {
+			if filePath > search.AfterPath && like.MatchString(filePath) {
+				jobIDs[filePath] = append(jobIDs[filePath], jobID)
+			}
+		}
+	}
+	matches := make([]db.FileMatch, 0, len(jobIDs))
+	for filePath, ids := range jobIDs {
+		matches = append(matches, db.FileMatch{FilePath: filePath, JobIDs: ids})
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].FilePath < matches[j].FilePath })
+	if len(matches) > search.Limit {
+		matches = matches[:search.Limit]
+	}
+	return matches, nil
+}
+
+// likeRegexp compiles a SQL LIKE pattern, escaped with backslashes, into the
+// equivalent regular expression
+func likeRegexp(like string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^(?s:")
+	escaped := false
+	for _, c := range like {
+		switch {
+		case escaped:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '%':
+			expr.WriteString(".*")
+		case c == '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString(")$")
+	return regexp.Compile(expr.String())
+}
+
+// Summary returns the number of entries in the manifest of a backup job and
+// the total size of its regular files
+func (m *manifestStore) Summary(ctx context.Context, jobID string) (entries, bytes int64, err error) {
+	if m.dbClient != nil {
+		return m.dbClient.SummarizeFileManifests(ctx, jobID)
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for _, manifest := range m.jobs[jobID] {
+		entries++
+		if manifest.FileType == "REGULAR" {
+			bytes += manifest.Size
+		}
+	}
+	return entries, bytes, nil
+}
+
+// Delete deletes the manifest of a backup job and returns the number of
+// entries deleted
+func (m *manifestStore) Delete(ctx context.Context, jobID string) (int64, error) {
+	if m.dbClient != nil {
+		return m.dbClient.DeleteFileManifests(ctx, jobID)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	entries := int64(len(m.jobs[jobID]))
+	delete(m.jobs, jobID)
+	return entries, nil
+}
+
+// newFileManifest serializes an entry into its manifest row
+func newFileManifest(jobID string, entry *pb.FileEntry, chunks []string, profile int) (*db.FileManifest, error) {
+	data, err := proto.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode entry %s: %w", entry.FilePath, err)
+	}
+	return &db.FileManifest{
+		JobID:    jobID,
+		FilePath: entry.FilePath,
+		FileType: strings.TrimPrefix(entry.Type.String(), "FILE_TYPE_"),
+		Size:     int64(entry.Size),
+		ModTime:  time.Unix(0, entry.MtimeNs),
+		Entry:    data,
+		Chunks:   chunks,
+
+		ChunkingProfile: profile,
+	}, nil
+}
+
+// fileEntry decodes the entry stored in a manifest row
+func fileEntry(manifest *db.FileManifest) (*pb.FileEntry, error) {
+	entry := &pb.FileEntry{}
+	if err := proto.Unmarshal(manifest.Entry, entry); err != nil {
+		return nil, fmt.Errorf("failed to decode entry %s: %w", manifest.FilePath, err)
+	}
+	return entry, nil
+}
+
+// regularEntry describes a file sent without a FileEntry, as older stream
+// handlers do
+func regularEntry(filePath string, size int64) *pb.FileEntry {
+	return &pb.FileEntry{
+		FilePath: filePath,
+		Type:     pb.FileType_FILE_TYPE_REGULAR,
+		Mode:     0o644,
+		MtimeNs:  time.Now().UnixNano(),
+		Size:     uint64(size),
+	}
+}
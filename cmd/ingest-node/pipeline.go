@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/sparse"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// The ingest pipeline stores the buffered data of a file in four stages that
+// run concurrently, joined by bounded queues:
+//
+//	split   finds chunk boundaries and groups the chunks into batches
+//	hash    fingerprints the chunks on a pool of workers
+//	lookup  takes the batches in file order, records the file's chunk
+//	        references and looks the new fingerprints up in one query
+//	store   uploads the new chunks of each batch, as deltas where they
+//	        resemble stored chunks
+//
+// A batch is queued for lookup as soon as it is split and lookup waits for it
+// to be hashed, so chunks keep their file order however the workers finish.
+
+// pipelineConfig sizes the ingest pipeline
+type pipelineConfig struct {
+	hashWorkers int // goroutines hashing chunks
+	batchSize   int // chunks per batch, and per index lookup
+	queueDepth  int // batches queued between two stages
+}
+
+// defaultPipelineConfig hashes on every CPU
+func defaultPipelineConfig() pipelineConfig {
+	return pipelineConfig{hashWorkers: runtime.NumCPU(), batchSize: storeBatchSize, queueDepth: 4}
+}
+
+// pipelineBatch is a run of consecutive chunks of a file
+type pipelineBatch struct {
+	chunks []chunking.Chunk
+	holes  []bool           // chunks holding only zeros, which are not hashed
+	hashed sync.WaitGroup   // done once every chunk is hashed
+	fresh  []chunking.Chunk // chunks lookup found not to be stored
+}
+
+// hashTask is a chunk of a batch for a hash worker
+type hashTask struct {
+	batch *pipelineBatch
+	index int
+}
+
+// pipelineStats measures the ingest pipeline. Stage times are summed over the
+// goroutines of the stage, and queue depths are the deepest each queue got.
+type pipelineStats struct {
+	Chunks  int64
+	Bytes   int64
+	Elapsed time.Duration
+
+	SplitTime  time.Duration
+	HashTime   time.Duration
+	LookupTime time.Duration
+	StoreTime  time.Duration
+
+	HashQueue   int // chunks waiting for a hash worker
+	LookupQueue int // batches waiting for lookup
+	StoreQueue  int // batches waiting for store
+}
+
+// add accumulates the stats of another run
+func (p *pipelineStats) add(other pipelineStats) {
+	p.Chunks += other.Chunks
+	p.Bytes += other.Bytes
+	p.Elapsed += other.Elapsed
+	p.SplitTime += other.SplitTime
+	p.HashTime += other.HashTime
+	p.LookupTime += other.LookupTime
+	p.StoreTime += other.StoreTime
+	p.HashQueue = max(p.HashQueue, other.HashQueue)
+	p.LookupQueue = max(p.LookupQueue, other.LookupQueue)
+	p.StoreQueue = max(p.StoreQueue, other.StoreQueue)
+}
+
+// Throughput returns the bytes ingested per second of pipeline time
+func (p pipelineStats) Throughput() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Bytes) / p.Elapsed.Seconds()
+}
+
+func (p pipelineStats) String() string {
+	return fmt.Sprintf("%d chunks, %d bytes in %v (%.1f MB/s); split %v, hash %v, lookup %v, store %v; peak queues: hash %d chunks, lookup %d, store %d batches",
+		p.Chunks, p.Bytes, p.Elapsed.Round(time.Microsecond), p.Throughput()/(1<<20),
+		p.SplitTime.Round(time.Microsecond), p.HashTime.Round(time.Microsecond), p.LookupTime.Round(time.Microsecond), p.StoreTime.Round(time.Microsecond),
+		p.HashQueue, p.LookupQueue, p.StoreQueue)
+}
+
+// pipelineResult is what the pipeline did with a file's data
+type pipelineResult struct {
+	stored int                    // new chunks stored
+	deltas int                    // of which stored as deltas
+	owned  []chunking.Fingerprint // chunks of the data, which the client holds
+	stats  pipelineStats
+}
+
+// runPipeline chunks, deduplicates and stores the buffered data of a file,
+// appending its chunk references and holes to the file and acknowledging each
+// batch to the upload's stream once it is stored. The caller must not touch
+// the file until it returns.
+func (s *IngestServer) runPipeline(ctx context.Context, upload *jobUpload, filePath string, file *pendingFile) (*pipelineResult, error) {
+	job := upload.job
+	config := s.pipeline
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var once sync.Once
+	var firstErr error
+	fail := func(err error) {
+		once.Do(func() { firstErr = err })
+		cancel()
+	}
+
+	hashQueue := make(chan hashTask, config.queueDepth*config.batchSize)
+	lookupQueue := make(chan *pipelineBatch, config.queueDepth)
+	storeQueue := make(chan *pipelineBatch, config.queueDepth)
+	result := &pipelineResult{}
+	stats := &result.stats
+	start := time.Now()
+	var wg sync.WaitGroup
+
+	// Split: batches go to lookup first, so lookup sees them in file order
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(hashQueue)
+		defer close(lookupQueue)
+		var blocked time.Duration
+		batch := &pipelineBatch{}
+		send := func() bool {
+			defer func(sent time.Time) { blocked += time.Since(sent) }(time.Now())
+			batch.holes = make([]bool, len(batch.chunks))
+			batch.hashed.Add(len(batch.chunks))
+			select {
+			case lookupQueue <- batch:
+				stats.LookupQueue = max(stats.LookupQueue, len(lookupQueue))
+			case <-ctx.Done():
+				return false
+			}
+			for i := range batch.chunks {
+				select {
+				case hashQueue <- hashTask{batch: batch, index: i}:
+					stats.HashQueue = max(stats.HashQueue, len(hashQueue))
+				case <-ctx.Done():
+					batch.hashed.Add(i - len(batch.chunks)) // never to be hashed
+					return false
+				}
+			}
+			batch = &pipelineBatch{}
+			return true
+		}
+		splitFileData(job.chunker, file, func(chunk chunking.Chunk) bool {
+			batch.chunks = append(batch.chunks, chunk)
+			return len(batch.chunks) < config.batchSize || send()
+		})
+		if len(batch.chunks) > 0 && ctx.Err() == nil {
+			send()
+		}
+		stats.SplitTime = time.Since(start) - blocked
+	}()
+
+	// Hash: zero runs become holes and cost no hashing
+	algorithm := job.chunker.HashAlgorithm()
+	var hashNanos atomic.Int64
+	for w := 0; w < config.hashWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range hashQueue {
+				if ctx.Err() == nil {
+					began := time.Now()
+					chunk := &task.batch.chunks[task.index]
+					if sparse.IsZero(chunk.Data) {
+						task.batch.holes[task.index] = true
+					} else {
+						chunk.Fingerprint = algorithm.Sum(chunk.Data)
+					}
+					hashNanos.Add(int64(time.Since(began)))
+				}
+				task.batch.hashed.Done()
+			}
+		}()
+	}
+
+	// Lookup: the only stage that touches the file
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(storeQueue)
+		seen := make(map[chunking.Fingerprint]bool)
+		n := 0 // index of the chunk in the data
+		for batch := range lookupQueue {
+			batch.hashed.Wait()
+			if ctx.Err() != nil {
+				continue
+			}
+			began := time.Now()
+			var claimed []chunking.Chunk
+			var positions []int // of the claimed chunks in the data
+			var processed, deduplicated int64
+			for i, chunk := range batch.chunks {
+				processed += chunk.Size
+				if batch.holes[i] {
+					file.addHole(chunk.Size)
+					log.Printf("  Chunk %d: HOLE (%d zero bytes)", n+i, chunk.Size)
+					continue
+				}
+				file.refs = append(file.refs, chunk.Fingerprint.String())
+				file.size += chunk.Size
+
+				// Repeated chunk within this file
+				if seen[chunk.Fingerprint] {
+					deduplicated += chunk.Size
+					log.Printf("  Chunk %d: DEDUPLICATED (within file, fingerprint: %s)", n+i, chunk.Fingerprint.Short())
+					continue
+				}
+				seen[chunk.Fingerprint] = true
+				result.owned = append(result.owned, chunk.Fingerprint)
+				claimed = append(claimed, chunk)
+				positions = append(positions, n+i)
+			}
+
+			// Check which chunks already exist (deduplication)
+			fingerprints := make([]chunking.Fingerprint, len(claimed))
+			for i, chunk := range claimed {
+				fingerprints[i] = chunk.Fingerprint
+			}
+			found, fromDB := s.lookupChunks(ctx, fingerprints)
+			for i, chunk := range claimed {
+				if found[chunk.Fingerprint] == nil {
+					batch.fresh = append(batch.fresh, chunk)
+					continue
+				}
+				deduplicated += chunk.Size
+				if fromDB[chunk.Fingerprint] {
+					log.Printf("  Chunk %d: DEDUPLICATED (from DB, fingerprint: %s)", positions[i], chunk.Fingerprint.Short())
+				} else {
+					log.Printf("  Chunk %d: DEDUPLICATED (fingerprint: %s)", positions[i], chunk.Fingerprint.Short())
+				}
+			}
+			n += len(batch.chunks)
+			stats.Chunks += int64(len(batch.chunks))
+			stats.Bytes += processed
+
+			job.mutex.Lock()
+			job.ChunksProcessed += len(batch.chunks)
+			job.BytesProcessed += processed
+			job.BytesDeduplicated += deduplicated
+			job.mutex.Unlock()
+			stats.LookupTime += time.Since(began)
+
+			select {
+			case storeQueue <- batch:
+				stats.StoreQueue = max(stats.StoreQueue, len(storeQueue))
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	// Store: unique chunks in batches, near-duplicates as deltas. Every
+	// batch is acknowledged once stored, including batches with nothing new.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for batch := range storeQueue {
+			if ctx.Err() != nil {
+				continue
+			}
+			if len(batch.fresh) > 0 {
+				began := time.Now()
+				objects, deltas := s.encodeDeltas(ctx, job, batch.fresh)
+				for first := 0; first < len(objects); first += storeBatchSize {
+					last := min(first+storeBatchSize, len(objects))
+					if err := s.storeUniqueChunks(ctx, objects[first:last]); err != nil {
+						fail(fmt.Errorf("failed to store chunks: %w", err))
+						break
+					}
+				}
+				if ctx.Err() != nil {
+					continue
+				}
+				s.recordDeltas(deltas)
+				s.indexChunks(ctx, objects, batch.fresh)
+				result.stored += len(batch.fresh)
+				result.deltas += len(deltas)
+				stats.StoreTime += time.Since(began)
+			}
+			var stored []chunking.Fingerprint
+			for i, chunk := range batch.chunks {
+				if !batch.holes[i] {
+					stored = append(stored, chunk.Fingerprint)
+				}
+			}
+			if err := upload.acknowledge(filePath, stored, len(batch.fresh)); err != nil {
+				fail(fmt.Errorf("failed to acknowledge stored chunks: %w", err))
+			}
+		}
+	}()
+
+	wg.Wait()
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	stats.HashTime = time.Duration(hashNanos.Load())
+	stats.Elapsed = time.Since(start)
+	return result, nil
+}
+
+// acknowledge tells the stream handler that chunks of a file are stored, of
+// which fresh were new. Nothing is sent without a stream.
+func (u *jobUpload) acknowledge(filePath string, stored []chunking.Fingerprint, fresh int) error {
+	if u.stream == nil || len(stored) == 0 {
+		return nil
+	}
+	fingerprints := make([][]byte, len(stored))
+	for i, fingerprint := range stored {
+		fingerprints[i] = fingerprint.Bytes()
+	}
+	resp := &pb.BackupResponse{
+		ResponseType: &pb.BackupResponse_ChunksStored{
+			ChunksStored: &pb.ChunksStored{FilePath: filePath, Fingerprints: fingerprints, NewChunks: uint32(fresh)},
+		},
+	}
+	return u.stream.Send(resp)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+)
+
+func TestPipelineKeepsChunkOrder(t *testing.T) {
+	server := NewIngestServer("0")
+	server.pipeline = pipelineConfig{hashWorkers: 3, batchSize: 4, queueDepth: 2}
+
+	data := make([]byte, 2<<20)
+	rand.New(rand.NewSource(44)).Read(data)
+	copy(data[1<<20:], data[:256<<10]) // repeated within the file
+	expected, err := server.chunker.ChunkData(data)
+	if err != nil {
+		t.Fatalf("ChunkData failed: %v", err)
+	}
+	if len(expected) <= 2*server.pipeline.batchSize {
+		t.Fatalf("Test data makes only %d chunks", len(expected))
+	}
+
+	job := &BackupJobState{JobID: "job-pipeline", chunker: server.chunker}
+	stream := &fakeBackupStream{}
+	file := &pendingFile{data: append([]byte(nil), data...)}
+	if err := server.storeFileData(context.Background(), newJobUpload(job, stream), "/data/random", file); err != nil {
+		t.Fatalf("storeFileData failed: %v", err)
+	}
+	if len(file.refs) != len(expected) {
+		t.Fatalf("Expected %d chunk references, got %d", len(expected), len(file.refs))
+	}
+	unique := make(map[string]bool)
+	for i, chunk := range expected {
+		if file.refs[i] != chunk.Fingerprint.String() {
+			t.Fatalf("Chunk %d: expected %s, got %s", i, chunk.Fingerprint.Short(), file.refs[i])
+		}
+		unique[file.refs[i]] = true
+	}
+	if file.size != int64(len(data)) || file.data != nil {
+		t.Errorf("Expected %d bytes covered and no data left, got %d and %d", len(data), file.size, len(file.data))
+	}
+	if len(unique) == len(expected) {
+		t.Fatal("Expected the repeated data to share chunks")
+	}
+
+	// Every batch is acknowledged in file order once stored
+	var acknowledged []string
+	var fresh int
+	for _, resp := range stream.responses {
+		stored := resp.GetChunksStored()
+		if stored == nil || stored.FilePath != "/data/random" {
+			t.Fatalf("Unexpected response %v", resp)
+		}
+		for _, fingerprint := range stored.Fingerprints {
+			parsed, err := chunking.FingerprintFromBytes(fingerprint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			acknowledged = append(acknowledged, parsed.String())
+		}
+		fresh += int(stored.NewChunks)
+	}
+	if len(stream.responses) != (len(expected)+3)/4 || strings.Join(acknowledged, ",") != strings.Join(file.refs, ",") || fresh != len(unique) {
+		t.Errorf("Expected %d acknowledgements of %d chunks, %d new; got %d of %d, %d new",
+			(len(expected)+3)/4, len(expected), len(unique), len(stream.responses), len(acknowledged), fresh)
+	}
+
+	stats := job.pipeline
+	if stats.Chunks != int64(len(expected)) || stats.Bytes != int64(len(data)) {
+		t.Errorf("Expected stats of %d chunks and %d bytes, got %d and %d", len(expected), len(data), stats.Chunks, stats.Bytes)
+	}
+	if stats.LookupQueue > server.pipeline.queueDepth || stats.StoreQueue > server.pipeline.queueDepth {
+		t.Errorf("Queues grew past their depth: %s", stats)
+	}
+	if job.ChunksProcessed != len(expected) || job.BytesProcessed != int64(len(data)) || job.BytesDeduplicated == 0 {
+		t.Errorf("Unexpected job counters: %d chunks, %d bytes, %d deduplicated", job.ChunksProcessed, job.BytesProcessed, job.BytesDeduplicated)
+	}
+
+	// A second copy is deduplicated entirely
+	again := &BackupJobState{JobID: "job-pipeline-again", chunker: server.chunker}
+	if err := server.storeFileData(context.Background(), newJobUpload(again, nil), "/data/copy", &pendingFile{data: data}); err != nil {
+		t.Fatalf("storeFileData failed: %v", err)
+	}
+	if again.BytesDeduplicated != int64(len(data)) {
+		t.Errorf("Expected all %d bytes deduplicated, got %d", len(data), again.BytesDeduplicated)
+	}
+}
+
+func TestPipelineStopsOnCancel(t *testing.T) {
+	server := NewIngestServer("0")
+	server.pipeline = pipelineConfig{hashWorkers: 2, batchSize: 2, queueDepth: 1}
+
+	data := make([]byte, 1<<20)
+	rand.New(rand.NewSource(7)).Read(data)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	job := &BackupJobState{JobID: "job-cancelled", chunker: server.chunker}
+	if err := server.storeFileData(ctx, newJobUpload(job, nil), "/data/random", &pendingFile{data: data}); err == nil {
+		t.Fatal("Expected a cancelled pipeline to fail")
+	}
+}
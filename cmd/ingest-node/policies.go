@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/db"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// policyKey names the backups a chunking policy applies to; an empty field
+// matches any
+type policyKey struct {
+	policyID   string
+	sourceType string
+}
+
+// chunkingPolicies picks the chunking profile of each backup job from its
+// backup policy and source type, in CockroachDB when it is configured and in
+// memory otherwise. Jobs no policy matches chunk with the active profile.
+type chunkingPolicies struct {
+	dbClient *db.DB
+
+	mutex    sync.Mutex
+	chunkers map[int]*chunking.Chunker // profile version -> chunker
+	rules    map[policyKey]chunking.Profile
+}
+
+// newChunkingPolicies creates a policy store; dbClient may be nil
+func newChunkingPolicies(dbClient *db.DB) *chunkingPolicies {
+	return &chunkingPolicies{
+		dbClient: dbClient,
+		chunkers: make(map[int]*chunking.Chunker),
+		rules:    make(map[policyKey]chunking.Profile),
+	}
+}
+
+// Set makes backups of a backup policy and source type chunk with profile,
+// reusing the version of active or another known profile that makes the same
+// chunks. It returns the profile with its version.
+func (c *chunkingPolicies) Set(ctx context.Context, key policyKey, profile, active chunking.Profile) (chunking.Profile, error) {
+	if key == (policyKey{}) {
+		return chunking.Profile{}, fmt.Errorf("a chunking policy must name a backup policy or a source type")
+	}
+	if err := profile.Validate(); err != nil {
+		return chunking.Profile{}, err
+	}
+	if c.dbClient != nil {
+		return c.dbClient.SetChunkingPolicy(ctx, key.policyID, key.sourceType, profile)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	version := active.Version
+	if active.SameChunks(profile) {
+		profile.Version = active.Version
+	} else {
+		profile.Version = 0
+		for _, known := range c.rules {
+			if known.SameChunks(profile) {
+				profile.Version = known.Version
+			}
+			version = max(version, known.Version)
+		}
+		if profile.Version == 0 {
+			profile.Version = version + 1
+		}
+	}
+	c.rules[key] = profile
+	return profile, nil
+}
+
+// Chunker returns the chunker of the profile a policy picks for a backup, or
+// nil if none matches
+func (c *chunkingPolicies) Chunker(ctx context.Context, key policyKey) (*chunking.Chunker, error) {
+	profile, found, err := c.find(ctx, key)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if chunker := c.chunkers[profile.Version]; chunker != nil && chunker.Profile() == profile {
+		return chunker, nil
+	}
+	chunker, err := chunking.NewProfileChunker(profile)
+	if err != nil {
+		return nil, fmt.Errorf("chunking profile %d: %w", profile.Version, err)
+	}
+	c.chunkers[profile.Version] = chunker
+	return chunker, nil
+}
+
+// find returns the profile of the most specific policy matching a backup
+func (c *chunkingPolicies) find(ctx context.Context, key policyKey) (chunking.Profile, bool, error) {
+	if c.dbClient != nil {
+		return c.dbClient.FindChunkingPolicy(ctx, key.policyID, key.sourceType)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, candidate := range []policyKey{key, {policyID: key.policyID}, {sourceType: key.sourceType}} {
+		if candidate == (policyKey{}) {
+			continue
+		}
+		if profile, ok := c.rules[candidate]; ok {
+			return profile, true, nil
+		}
+	}
+	return chunking.Profile{}, false, nil
+}
+
+// parseChunkingPolicies parses CHUNKING_POLICIES: comma-separated
+// [backup_policy_id/]source_type=strategy rules, where "*" matches any backup
+// policy or source type and strategy is as chunking.ParseStrategy takes it.
+// Fingerprints are computed with hash.
+func parseChunkingPolicies(value string, hash chunking.HashAlgorithm) (map[policyKey]chunking.Profile, error) {
+	policies := make(map[policyKey]chunking.Profile)
+	for _, rule := range strings.Split(value, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		target, strategy, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid chunking policy %q, expected [policy/]source_type=strategy", rule)
+		}
+		var key policyKey
+		if policyID, sourceType, ok := strings.Cut(target, "/"); ok {
+			key = policyKey{policyID: policyID, sourceType: sourceType}
+		} else {
+			key = policyKey{sourceType: target}
+		}
+		if key.policyID == "*" {
+			key.policyID = ""
+		}
+		if key.sourceType == "*" {
+			key.sourceType = ""
+		}
+		if key == (policyKey{}) {
+			return nil, fmt.Errorf("chunking policy %q must name a backup policy or a source type", rule)
+		}
+		profile, err := chunking.ParseStrategy(strategy, hash)
+		if err != nil {
+			return nil, fmt.Errorf("chunking policy %q: %w", rule, err)
+		}
+		policies[key] = profile
+	}
+	return policies, nil
+}
+
+// jobChunker returns the chunker of a backup job: that of the chunking policy
+// matching its backup policy and source type, or else the active one
+func (s *IngestServer) jobChunker(ctx context.Context, start *pb.BackupStart) (*chunking.Chunker, error) {
+	chunker, err := s.policies.Chunker(ctx, policyKey{policyID: start.BackupPolicyId, sourceType: start.SourceType})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to read chunking policies: %v", err)
+	}
+	if chunker == nil {
+		return s.chunker, nil
+	}
+	if algorithm := chunker.HashAlgorithm(); algorithm != s.chunker.HashAlgorithm() {
+		return nil, status.Errorf(codes.FailedPrecondition, "Chunking profile %d fingerprints with %s, but the repository uses %s",
+			chunker.Profile().Version, algorithm, s.chunker.HashAlgorithm())
+	}
+	return chunker, nil
+}
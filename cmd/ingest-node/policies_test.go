@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+func TestParseChunkingPolicies(t *testing.T) {
+	policies, err := parseChunkingPolicies("vm=fixed:4096, database=fixed:8192, nightly/*=rabin:512:2048:16384, nightly/vm=rabin", chunking.SHA256)
+	if err != nil {
+		t.Fatalf("parseChunkingPolicies failed: %v", err)
+	}
+	if len(policies) != 4 {
+		t.Fatalf("Expected 4 policies, got %d", len(policies))
+	}
+	if vm := policies[policyKey{sourceType: "vm"}]; vm.Algorithm != chunking.AlgorithmFixed || vm.MaxSize != 4096 || vm.Hash != chunking.SHA256 {
+		t.Errorf("Unexpected vm policy %s", vm)
+	}
+	if nightly := policies[policyKey{policyID: "nightly"}]; nightly.MinSize != 512 || nightly.AvgSize != 2048 || nightly.MaxSize != 16384 {
+		t.Errorf("Unexpected nightly policy %s", nightly)
+	}
+	if _, ok := policies[policyKey{policyID: "nightly", sourceType: "vm"}]; !ok {
+		t.Error("Expected a policy for nightly vm backups")
+	}
+
+	for _, invalid := range []string{"vm", "*=fixed:4096", "*/*=rabin", "vm=fixed", "vm=fixed:0", "vm=rabin:4096:1024:8192", "vm=gear:4096"} {
+		if _, err := parseChunkingPolicies(invalid, chunking.Blake3); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestChunkingPolicyPerJob(t *testing.T) {
+	server := NewIngestServer("0")
+	ctx := context.Background()
+	active := server.chunker.Profile()
+	vm, err := server.policies.Set(ctx, policyKey{sourceType: "vm"}, chunking.FixedProfile(4096, active.Hash), active)
+	if err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	same, err := server.policies.Set(ctx, policyKey{policyID: "docs"}, active, active)
+	if err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if vm.Version != 2 || same.Version != active.Version {
+		t.Errorf("Expected versions 2 and %d, got %d and %d", active.Version, vm.Version, same.Version)
+	}
+
+	data := make([]byte, 10000)
+	rand.New(rand.NewSource(46)).Read(data)
+	backup := func(jobID, policyID, sourceType string) (*pb.BackupStatus, int, int) {
+		t.Helper()
+		stream := &fakeBackupStream{requests: []*pb.BackupRequest{
+			{RequestType: &pb.BackupRequest_StartBackup{StartBackup: &pb.BackupStart{BackupJobId: jobID, BackupPolicyId: policyID, SourceType: sourceType}}},
+			segment("/disk.img", data, 0, true),
+			{RequestType: &pb.BackupRequest_EndBackup{EndBackup: &pb.BackupEnd{BackupJobId: jobID, Status: "COMPLETED"}}},
+		}}
+		if err := server.StreamBackup(stream); err != nil {
+			t.Fatalf("StreamBackup failed: %v", err)
+		}
+		manifests, err := server.manifests.List(ctx, jobID)
+		if err != nil || len(manifests) != 1 {
+			t.Fatalf("Expected 1 manifest, got %d (%v)", len(manifests), err)
+		}
+		return stream.responses[0].GetStatusUpdate(), len(manifests[0].Chunks), manifests[0].ChunkingProfile
+	}
+
+	// VM images chunk in 4 KiB blocks, whatever their backup policy
+	started, chunks, profile := backup("job-vm", "weekly", "vm")
+	if chunks != 3 || profile != vm.Version {
+		t.Errorf("Expected 3 chunks of profile %d, got %d of profile %d", vm.Version, chunks, profile)
+	}
+	if got := started.GetChunkingProfile(); got.GetAlgorithm() != chunking.AlgorithmFixed || got.GetMaxSize() != 4096 {
+		t.Errorf("Expected the start status to carry the fixed profile, got %v", got)
+	}
+
+	// Other backups chunk with the active profile
+	started, _, profile = backup("job-fs", "weekly", "filesystem")
+	if profile != active.Version || started.GetChunkingProfile().GetAlgorithm() != chunking.AlgorithmRabin {
+		t.Errorf("Expected the active profile %d, got %d", active.Version, profile)
+	}
+
+	// A policy naming the backup policy wins over one naming the source type
+	if _, _, profile = backup("job-docs", "docs", "vm"); profile != active.Version {
+		t.Errorf("Expected the docs policy's profile %d, got %d", active.Version, profile)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/db"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// profileFromEnv returns base with the chunking settings of the environment
+// applied
+func profileFromEnv(base chunking.Profile) chunking.Profile {
+	profile := base
+	profile.Algorithm = getEnv("CHUNKING_ALGORITHM", base.Algorithm)
+	profile.MinSize = getEnvInt("CHUNK_MIN_SIZE", base.MinSize)
+	profile.AvgSize = getEnvInt("CHUNK_AVG_SIZE", base.AvgSize)
+	profile.MaxSize = getEnvInt("CHUNK_MAX_SIZE", base.MaxSize)
+	return profile
+}
+
+// repositoryProfile returns the chunking profile the ingest node must chunk
+// with. The repository keeps the profile it was created with: a node set up
+// for a different one refuses to start unless migrate is set, in which case
+// its profile becomes the repository's next version.
+func repositoryProfile(ctx context.Context, dbClient *db.DB, hash chunking.HashAlgorithm, migrate bool) (chunking.Profile, error) {
+	initial := profileFromEnv(chunking.DefaultProfile())
+	initial.Hash = hash
+	if err := initial.Validate(); err != nil {
+		return chunking.Profile{}, fmt.Errorf("invalid chunking settings: %w", err)
+	}
+	active, err := dbClient.InitChunkingProfile(ctx, initial)
+	if err != nil {
+		return chunking.Profile{}, fmt.Errorf("failed to read the repository's chunking profile: %w", err)
+	}
+	if active.Hash != hash {
+		return chunking.Profile{}, fmt.Errorf("chunking profile %d fingerprints with %s but the repository uses %s", active.Version, active.Hash, hash)
+	}
+
+	configured := profileFromEnv(active)
+	if configured.SameChunks(active) {
+		return active, nil
+	}
+	if err := configured.Validate(); err != nil {
+		return chunking.Profile{}, fmt.Errorf("invalid chunking settings: %w", err)
+	}
+	if !migrate {
+		return chunking.Profile{}, fmt.Errorf("the chunking settings (%s) differ from the repository's profile (%s); set CHUNKING_PROFILE_MIGRATE=true to migrate the repository to them, or unset them", configured, active)
+	}
+	migrated, err := dbClient.MigrateChunkingProfile(ctx, configured)
+	if err != nil {
+		return chunking.Profile{}, fmt.Errorf("failed to migrate the chunking profile: %w", err)
+	}
+	log.Printf("Migrated the repository from chunking profile %d to %d; new data no longer deduplicates against chunks of earlier profiles", active.Version, migrated.Version)
+	return migrated, nil
+}
+
+// chunkingProfileMessage describes a chunking profile to a stream handler
+func chunkingProfileMessage(p chunking.Profile) *pb.ChunkingProfile {
+	return &pb.ChunkingProfile{
+		Version:    uint32(p.Version),
+		Algorithm:  p.Algorithm,
+		MinSize:    uint32(p.MinSize),
+		AvgSize:    uint32(p.AvgSize),
+		MaxSize:    uint32(p.MaxSize),
+		WindowSize: uint32(p.WindowSize),
+		Polynomial: p.Polynomial,
+	}
+}
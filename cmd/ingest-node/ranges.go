@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// rangedFile is a large file uploaded as byte ranges, possibly over several
+// streams of a job. It is recorded once every range is complete and the
+// ranges, in index order, cover the file exactly.
+type rangedFile struct {
+	entry   *pb.FileEntry
+	started []*pb.FileRange // ranges some stream has begun to upload, by index
+	parts   [][]string      // chunk fingerprints and holes of each complete range
+	done    int             // number of complete ranges
+}
+
+// covers reports whether the ranges follow each other without gaps or
+// overlaps from the start of the file to its end
+func (f *rangedFile) covers() bool {
+	var end uint64
+	for _, r := range f.started {
+		if r == nil || r.Offset != end {
+			return false
+		}
+		end += r.Length
+	}
+	return end == f.entry.Size
+}
+
+// joinJob returns the running job a BackupStart with join set adds a stream to
+func (s *IngestServer) joinJob(start *pb.BackupStart) (*BackupJobState, error) {
+	s.backupMutex.RLock()
+	job := s.backupJobs[start.BackupJobId]
+	s.backupMutex.RUnlock()
+	if job == nil {
+		return nil, status.Errorf(codes.NotFound, "Backup job %s is not running", start.BackupJobId)
+	}
+	if job.ClientID != start.ClientId {
+		return nil, status.Errorf(codes.PermissionDenied, "Backup job %s belongs to another client", start.BackupJobId)
+	}
+
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	if job.ended {
+		return nil, status.Errorf(codes.FailedPrecondition, "Backup job %s has already ended", start.BackupJobId)
+	}
+	return job, nil
+}
+
+// receiveFileRange starts the upload of one range of a large file on a stream
+func (s *IngestServer) receiveFileRange(upload *jobUpload, r *pb.FileRange) error {
+	entry := r.Entry
+	if entry == nil || entry.Type != pb.FileType_FILE_TYPE_REGULAR {
+		return status.Error(codes.InvalidArgument, "File range sent without a regular file entry")
+	}
+	if r.Count == 0 || r.Index >= r.Count || entry.Size > math.MaxInt64 || r.Length > entry.Size || r.Offset > entry.Size-r.Length {
+		return status.Errorf(codes.InvalidArgument, "Invalid range %d/%d [%d, +%d) of %s (%d bytes)",
+			r.Index, r.Count, r.Offset, r.Length, entry.FilePath, entry.Size)
+	}
+	if upload.files[entry.FilePath] != nil {
+		return status.Errorf(codes.InvalidArgument, "Range of %s sent before the previous one is complete", entry.FilePath)
+	}
+
+	job := upload.job
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	ranged := job.ranges[entry.FilePath]
+	if ranged == nil {
+		ranged = &rangedFile{entry: entry, started: make([]*pb.FileRange, r.Count), parts: make([][]string, r.Count)}
+		job.ranges[entry.FilePath] = ranged
+	}
+	if int(r.Count) != len(ranged.parts) || entry.Size != ranged.entry.Size {
+		return status.Errorf(codes.InvalidArgument, "Range %d of %s does not match its other ranges", r.Index, entry.FilePath)
+	}
+	if ranged.started[r.Index] != nil {
+		return status.Errorf(codes.InvalidArgument, "Range %d of %s sent twice", r.Index, entry.FilePath)
+	}
+	ranged.started[r.Index] = r
+
+	upload.files[entry.FilePath] = &pendingFile{entry: entry, part: r}
+	return nil
+}
+
+// finishRange keeps the chunks of a complete range and records the file
+// once all of its ranges are complete
+func (s *IngestServer) finishRange(ctx context.Context, job *BackupJobState, filePath string, file *pendingFile) error {
+	part := file.part
+	if file.size != int64(part.Length) {
+		return status.Errorf(codes.InvalidArgument, "Range %d of %s has %d bytes, expected %d", part.Index, filePath, file.size, part.Length)
+	}
+
+	job.mutex.Lock()
+	ranged := job.ranges[filePath]
+	ranged.parts[part.Index] = file.refs
+	ranged.done++
+	complete := ranged.done == len(ranged.parts)
+	if complete {
+		delete(job.ranges, filePath)
+	}
+	job.mutex.Unlock()
+	if !complete {
+		return nil
+	}
+	if !ranged.covers() {
+		return status.Errorf(codes.InvalidArgument, "Ranges of %s do not cover its %d bytes exactly", filePath, ranged.entry.Size)
+	}
+
+	// Holes at the end of one range and the start of the next are merged
+	merged := &pendingFile{}
+	for _, refs := range ranged.parts {
+		for _, ref := range refs {
+			if size, ok := parseHoleRef(ref); ok {
+				merged.addHole(size)
+				continue
+			}
+			merged.refs = append(merged.refs, ref)
+		}
+	}
+	log.Printf("Recording %s from %d ranges", filePath, len(ranged.parts))
+	return s.recordFile(ctx, job, filePath, ranged.entry, merged.refs, int64(ranged.entry.Size))
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+func joinStart(clientID, jobID string) *pb.BackupRequest {
+	return &pb.BackupRequest{RequestType: &pb.BackupRequest_StartBackup{StartBackup: &pb.BackupStart{ClientId: clientID, BackupJobId: jobID, Join: true}}}
+}
+
+func fileRange(entry *pb.FileEntry, index, count uint32, offset, length uint64) *pb.BackupRequest {
+	return &pb.BackupRequest{RequestType: &pb.BackupRequest_FileRange{FileRange: &pb.FileRange{
+		Entry: entry, Index: index, Count: count, Offset: offset, Length: length,
+	}}}
+}
+
+func segmentAt(path string, data []byte, offset, hole uint64, last bool) *pb.BackupRequest {
+	return &pb.BackupRequest{RequestType: &pb.BackupRequest_FileSegment{FileSegment: &pb.FileSegment{
+		FilePath: path, Data: data, Offset: offset, HoleSize: hole, IsLastSegment: last,
+	}}}
+}
+
+func TestParallelRangedBackup(t *testing.T) {
+	server := NewIngestServer("0")
+	big := &pb.FileEntry{FilePath: "/src/big", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o644, Size: 300}
+	small := &pb.FileEntry{FilePath: "/src/small", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o644, Size: 5}
+	data := bytes.Repeat([]byte("x"), 100)
+
+	// The ranges arrive out of order on two streams, and the hole spanning
+	// them is split between both
+	primary := &fakeBackupStream{requests: []*pb.BackupRequest{
+		backupStart("client-a", "job-par", "", 1000),
+		fileRange(big, 1, 2, 150, 150),
+		segmentAt(big.FilePath, nil, 150, 50, false),
+		segmentAt(big.FilePath, data, 200, 0, true),
+		{RequestType: &pb.BackupRequest_FileEntry{FileEntry: small}},
+		segment(small.FilePath, []byte("hello"), 0, true),
+	}}
+	if err := server.StreamBackup(primary); err != nil {
+		t.Fatalf("Primary stream failed: %v", err)
+	}
+	joined := &fakeBackupStream{requests: []*pb.BackupRequest{
+		joinStart("client-a", "job-par"),
+		fileRange(big, 0, 2, 0, 150),
+		segmentAt(big.FilePath, data, 0, 0, false),
+		segmentAt(big.FilePath, nil, 100, 50, true),
+	}}
+	if err := server.StreamBackup(joined); err != nil {
+		t.Fatalf("Joined stream failed: %v", err)
+	}
+
+	// A job cannot end while another of its streams is uploading
+	job := server.backupJobs["job-par"]
+	other := newJobUpload(job, nil)
+	end := &fakeBackupStream{requests: []*pb.BackupRequest{joinStart("client-a", "job-par"), backupEnd("job-par", "COMPLETED")}}
+	if err := server.StreamBackup(end); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition while a stream is open, got %v", err)
+	}
+	other.close()
+	end = &fakeBackupStream{requests: []*pb.BackupRequest{joinStart("client-a", "job-par"), backupEnd("job-par", "COMPLETED")}}
+	if err := server.StreamBackup(end); err != nil {
+		t.Fatalf("EndBackup failed: %v", err)
+	}
+	if job.FilesProcessed != 2 || job.BytesProcessed != 205 {
+		t.Errorf("Unexpected job counters: %+v", job)
+	}
+
+	manifests, _ := server.manifests.List(context.Background(), "job-par")
+	if len(manifests) != 2 || manifests[0].FilePath != big.FilePath || manifests[0].Size != 300 {
+		t.Fatalf("Unexpected manifests: %+v", manifests)
+	}
+	chunks := manifests[0].Chunks
+	if len(chunks) != 3 {
+		t.Fatalf("Expected data, one merged hole and data, got %v", chunks)
+	}
+	if size, ok := parseHoleRef(chunks[1]); !ok || size != 100 {
+		t.Errorf("Expected a merged hole of 100 bytes, got %s", chunks[1])
+	}
+	if chunks[0] != chunks[2] {
+		t.Errorf("Expected both ranges' data to deduplicate, got %v", chunks)
+	}
+
+	// No stream may join an ended job
+	late := &fakeBackupStream{requests: []*pb.BackupRequest{joinStart("client-a", "job-par")}}
+	if err := server.StreamBackup(late); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound joining an ended job, got %v", err)
+	}
+}
+
+func TestFileRangeValidation(t *testing.T) {
+	server := NewIngestServer("0")
+	if err := server.StreamBackup(&fakeBackupStream{requests: []*pb.BackupRequest{backupStart("client-a", "job-1", "", 1000)}}); err != nil {
+		t.Fatal(err)
+	}
+	big := &pb.FileEntry{FilePath: "/src/big", Type: pb.FileType_FILE_TYPE_REGULAR, Size: 100}
+	wrap := &pb.FileEntry{FilePath: "/src/wrap", Type: pb.FileType_FILE_TYPE_REGULAR, Size: 100}
+	gap := &pb.FileEntry{FilePath: "/src/gap", Type: pb.FileType_FILE_TYPE_REGULAR, Size: 100}
+	overlap := &pb.FileEntry{FilePath: "/src/overlap", Type: pb.FileType_FILE_TYPE_REGULAR, Size: 100}
+
+	tests := []struct {
+		name     string
+		requests []*pb.BackupRequest
+		code     codes.Code
+	}{
+		{"unknown job", []*pb.BackupRequest{joinStart("client-a", "job-missing")}, codes.NotFound},
+		{"other client", []*pb.BackupRequest{joinStart("client-b", "job-1")}, codes.PermissionDenied},
+		{"range past the end", []*pb.BackupRequest{joinStart("client-a", "job-1"), fileRange(big, 0, 2, 50, 60)}, codes.InvalidArgument},
+		{"index out of range", []*pb.BackupRequest{joinStart("client-a", "job-1"), fileRange(big, 2, 2, 0, 50)}, codes.InvalidArgument},
+		{"range sent twice", []*pb.BackupRequest{
+			joinStart("client-a", "job-1"),
+			fileRange(big, 0, 2, 0, 50),
+			segmentAt(big.FilePath, make([]byte, 50), 0, 0, true),
+			fileRange(big, 0, 2, 0, 50),
+		}, codes.InvalidArgument},
+		{"segment out of order", []*pb.BackupRequest{
+			joinStart("client-a", "job-1"),
+			fileRange(big, 1, 2, 50, 50),
+			segmentAt(big.FilePath, make([]byte, 50), 0, 0, true),
+		}, codes.InvalidArgument},
+		{"offset overflows", []*pb.BackupRequest{joinStart("client-a", "job-1"), fileRange(wrap, 0, 2, math.MaxUint64-10, 20)}, codes.InvalidArgument},
+		{"size overflows", []*pb.BackupRequest{
+			joinStart("client-a", "job-1"),
+			fileRange(&pb.FileEntry{FilePath: "/src/huge", Type: pb.FileType_FILE_TYPE_REGULAR, Size: math.MaxUint64}, 0, 1, 0, 1),
+		}, codes.InvalidArgument},
+		{"gap between ranges", []*pb.BackupRequest{
+			joinStart("client-a", "job-1"),
+			fileRange(gap, 0, 2, 0, 40),
+			segmentAt(gap.FilePath, make([]byte, 40), 0, 0, true),
+			fileRange(gap, 1, 2, 50, 50),
+			segmentAt(gap.FilePath, make([]byte, 50), 50, 0, true),
+		}, codes.InvalidArgument},
+		{"overlapping ranges", []*pb.BackupRequest{
+			joinStart("client-a", "job-1"),
+			fileRange(overlap, 0, 2, 0, 60),
+			segmentAt(overlap.FilePath, make([]byte, 60), 0, 0, true),
+			fileRange(overlap, 1, 2, 40, 60),
+			segmentAt(overlap.FilePath, make([]byte, 60), 40, 0, true),
+		}, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		err := server.StreamBackup(&fakeBackupStream{requests: tt.requests})
+		if status.Code(err) != tt.code {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.code, err)
+		}
+	}
+	// Ranges that do not cover their file are not recorded, and the job stays
+	// running for the client to send them again
+	if manifests, _ := server.manifests.List(context.Background(), "job-1"); len(manifests) != 0 {
+		t.Errorf("Expected no files recorded, got %v", manifests)
+	}
+	if job := server.backupJobs["job-1"]; job == nil || job.ended {
+		t.Error("Expected job-1 to keep running")
+	}
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/db"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/delta"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/erasure"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// restoreJob is a restore waiting for its data streams. Its entries are
+// split across streams by partitionEntries.
+type restoreJob struct {
+	id          string
+	clientID    string // client that initiated the restore, the only one that may stream it
+	backupJobID string
+	entries     []restoreEntry
+	claimed     []bool    // streams that have started, by index
+	created     time.Time // the job is dropped if its streams have not all started within RESTORE_JOB_TIMEOUT
+}
+
+// restoreEntry is an entry to restore and the chunks of its contents
+type restoreEntry struct {
+	entry  *pb.FileEntry
+	chunks []string
+}
+
+// InitiateRestore selects the entries of a backup job to restore. The data
+// is sent by StreamRestoreData with the returned restore job ID. Only the
+// client a job belongs to may restore it, and only once it has completed.
+func (s *IngestServer) InitiateRestore(ctx context.Context, req *pb.RestoreRequest) (*pb.RestoreResponse, error) {
+	if req.BackupJobId == "" {
+		return nil, status.Error(codes.InvalidArgument, "Backup job ID is required")
+	}
+	if req.ClientId == "" {
+		return nil, status.Error(codes.InvalidArgument, "Client ID is required")
+	}
+	backup, err := s.history.Get(ctx, req.BackupJobId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to look up job %s: %v", req.BackupJobId, err)
+	}
+	if backup == nil {
+		return nil, status.Errorf(codes.NotFound, "Backup job %s does not exist", req.BackupJobId)
+	}
+	if backup.ClientID != req.ClientId {
+		return nil, status.Errorf(codes.PermissionDenied, "Backup job %s belongs to another client", req.BackupJobId)
+	}
+	if !backup.Status.Successful() {
+		return nil, status.Errorf(codes.FailedPrecondition, "Backup job %s is %s; only completed or partial jobs can be restored", req.BackupJobId, backup.Status)
+	}
+	manifests, err := s.manifests.List(ctx, req.BackupJobId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to load manifest: %v", err)
+	}
+	if len(manifests) == 0 {
+		return nil, status.Errorf(codes.NotFound, "Backup job %s has no files", req.BackupJobId)
+	}
+	entries, err := selectEntries(manifests, req.FilesToRestore)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to read manifest: %v", err)
+	}
+	if len(entries) == 0 {
+		return nil, status.Errorf(codes.NotFound, "No files in backup job %s match %v", req.BackupJobId, req.FilesToRestore)
+	}
+
+	id, err := newRestoreJobID()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to create restore job ID: %v", err)
+	}
+	streams := max(int(req.Streams), 1)
+	job := &restoreJob{
+		id:          id,
+		clientID:    req.ClientId,
+		backupJobID: req.BackupJobId,
+		entries:     entries,
+		claimed:     make([]bool, streams),
+		created:     time.Now(),
+	}
+	s.restoreMutex.Lock()
+	s.restoreJobs[job.id] = job
+	s.restoreMutex.Unlock()
+
+	log.Printf("Initiated restore job %s: %d entries from backup job %s over %d streams", job.id, len(entries), req.BackupJobId, streams)
+	return &pb.RestoreResponse{
+		RestoreJobId: job.id,
+		Status:       "INITIATED",
+		Message:      fmt.Sprintf("Restoring %d entries from backup job %s", len(entries), req.BackupJobId),
+
+		FingerprintAlgorithm: s.chunker.HashAlgorithm().String(),
+	}, nil
+}
+
+// newRestoreJobID returns a random restore job ID, which cannot be guessed
+// from the IDs of other restores
+func newRestoreJobID() (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+	return "restore-" + hex.EncodeToString(id[:]), nil
+}
+
+// StreamRestoreData sends the entries of a restore job assigned to one of
+// its streams. The client sends one request naming the job, stream and the
+// client that initiated the job; each
+// entry starts with a message carrying its FileEntry, and regular files
+// continue with one message per chunk or hole. A job is removed once all of
+// its streams have started.
+func (s *IngestServer) StreamRestoreData(stream pb.BackupService_StreamRestoreDataServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "Failed to receive restore request: %v", err)
+	}
+
+	if req.ClientId == "" {
+		return status.Error(codes.InvalidArgument, "Client ID is required")
+	}
+
+	s.restoreMutex.Lock()
+	job := s.restoreJobs[req.RestoreJobId]
+	if job == nil {
+		s.restoreMutex.Unlock()
+		return status.Errorf(codes.NotFound, "Restore job %s not found", req.RestoreJobId)
+	}
+	if job.clientID != req.ClientId {
+		s.restoreMutex.Unlock()
+		return status.Errorf(codes.PermissionDenied, "Restore job %s belongs to another client", req.RestoreJobId)
+	}
+	index := int(req.StreamIndex)
+	if index >= len(job.claimed) || job.claimed[index] {
+		s.restoreMutex.Unlock()
+		return status.Errorf(codes.InvalidArgument, "Restore job %s has no unclaimed stream %d", req.RestoreJobId, index)
+	}
+	job.claimed[index] = true
+	if !slices.Contains(job.claimed, false) {
+		delete(s.restoreJobs, req.RestoreJobId)
+	}
+	s.restoreMutex.Unlock()
+
+	entries := partitionEntries(job.entries, len(job.claimed))[index]
+	for _, e := range entries {
+		if err := s.sendRestoreEntry(stream, job.id, e); err != nil {
+			return status.Errorf(codes.Internal, "Failed to restore %s: %v", e.entry.FilePath, err)
+		}
+	}
+	log.Printf("Completed stream %d of restore job %s: %d entries from backup job %s", index, job.id, len(entries), job.backupJobID)
+	return nil
+}
+
+// RunRestoreReaper drops the restore jobs whose streams have not all started
+// within timeout, such as those of a client that disconnected, until ctx is
+// done
+func (s *IngestServer) RunRestoreReaper(ctx context.Context, timeout time.Duration) {
+	ticker := time.NewTicker(timeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reapRestores(time.Now().Add(-timeout))
+		}
+	}
+}
+
+// reapRestores drops the restore jobs initiated before cutoff
+func (s *IngestServer) reapRestores(cutoff time.Time) {
+	s.restoreMutex.Lock()
+	defer s.restoreMutex.Unlock()
+	for id, job := range s.restoreJobs {
+		if !job.created.Before(cutoff) {
+			continue
+		}
+		delete(s.restoreJobs, id)
+		started := 0
+		for _, claimed := range job.claimed {
+			if claimed {
+				started++
+			}
+		}
+		log.Printf("Restore job %s of backup job %s expired with %d of %d streams started", id, job.backupJobID, started, len(job.claimed))
+	}
+}
+
+// partitionEntries splits the entries of a restore job across streams,
+// giving each entry to the stream with the fewest bytes so far. Entries keep
+// their order within a stream, so hard links still follow their targets
+// when there is one stream.
+func partitionEntries(entries []restoreEntry, streams int) [][]restoreEntry {
+	parts := make([][]restoreEntry, streams)
+	sizes := make([]uint64, streams)
+	for _, e := range entries {
+		least := 0
+		for i := range sizes {
+			if sizes[i] < sizes[least] {
+				least = i
+			}
+		}
+		parts[least] = append(parts[least], e)
+		sizes[least] += e.entry.Size
+	}
+	return parts
+}
+
+// sendRestoreEntry streams one entry, reading the chunks of a regular file
+func (s *IngestServer) sendRestoreEntry(stream pb.BackupService_StreamRestoreDataServer, jobID string, e restoreEntry) error {
+	if len(e.chunks) == 0 {
+		return stream.Send(&pb.RestoreDataResponse{
+			RestoreJobId:  jobID,
+			FilePath:      e.entry.FilePath,
+			IsLastSegment: true,
+			FileEntry:     e.entry,
+		})
+	}
+
+	offset := uint64(0)
+	for i, ref := range e.chunks {
+		msg := &pb.RestoreDataResponse{
+			RestoreJobId:  jobID,
+			FilePath:      e.entry.FilePath,
+			Offset:        offset,
+			IsLastSegment: i == len(e.chunks)-1,
+		}
+		if i == 0 {
+			msg.FileEntry = e.entry
+		}
+		if size, ok := parseHoleRef(ref); ok {
+			msg.HoleSize = uint64(size)
+		} else {
+			fingerprint, err := chunking.ParseFingerprint(ref)
+			if err != nil {
+				return fmt.Errorf("manifest of %s: %w", e.entry.FilePath, err)
+			}
+			data, err := s.readChunk(stream.Context(), fingerprint)
+			if err != nil {
+				return err
+			}
+			msg.Data = data
+		}
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+		offset += uint64(len(msg.Data)) + msg.HoleSize
+	}
+	return nil
+}
+
+// readChunk reads a chunk from its replicas or from the erasure-coded
+// container holding it, or rebuilds it from its delta and base chunk
+func (s *IngestServer) readChunk(ctx context.Context, fingerprint chunking.Fingerprint) ([]byte, error) {
+	return s.readChunkAt(ctx, fingerprint, 0)
+}
+
+// readChunkAt reads a chunk reached through depth deltas
+func (s *IngestServer) readChunkAt(ctx context.Context, fingerprint chunking.Fingerprint, depth int) ([]byte, error) {
+	if s.deltas != nil {
+		if data, ok := s.deltas.recent.Get(fingerprint); ok {
+			return data, nil
+		}
+	}
+
+	var nodes []string
+	var location string
+	var base chunking.Fingerprint
+	var size int64
+	if meta, ok := s.cache.GetChunkMetadata(fingerprint); ok {
+		nodes, location, size, base = meta.StorageNodes, meta.StorageLocation, meta.Size, meta.BaseFingerprint
+	} else if s.dbClient != nil {
+		meta, err := s.dbClient.GetChunkMetadataByFingerprint(ctx, fingerprint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up chunk %s: %w", fingerprint, err)
+		}
+		if meta != nil {
+			nodes, location, size, base = meta.StorageNodes, meta.StorageLocation, int64(meta.Size), meta.BaseFingerprint
+		}
+	}
+
+	if deltaKey, ok := strings.CutPrefix(location, "delta://"); ok {
+		if depth >= maxDeltaChainDepth {
+			return nil, fmt.Errorf("chunk %s is more than %d deltas from a whole chunk", fingerprint, maxDeltaChainDepth)
+		}
+		key, err := chunking.ParseFingerprint(deltaKey)
+		if err != nil {
+			return nil, fmt.Errorf("chunk %s has a malformed delta location: %w", fingerprint, err)
+		}
+		d, err := s.readChunkAt(ctx, key, depth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read delta of chunk %s: %w", fingerprint, err)
+		}
+		baseData, err := s.readChunkAt(ctx, base, depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read base of chunk %s: %w", fingerprint, err)
+		}
+		data, err := delta.Apply(baseData, d)
+		if err != nil || !chunking.VerifyFingerprint(data, fingerprint) {
+			return nil, fmt.Errorf("chunk %s rebuilt from its delta does not match its fingerprint (%v)", fingerprint, err)
+		}
+		return data, nil
+	}
+
+	if containerID, offset, ok := parseContainerLocation(location); ok {
+		if s.containers == nil || s.dbClient == nil {
+			return nil, fmt.Errorf("chunk %s is in container %s but container layouts are unavailable", fingerprint, containerID)
+		}
+		container, err := s.dbClient.GetContainer(ctx, containerID)
+		if err != nil || container == nil {
+			return nil, fmt.Errorf("failed to look up container %s: %v", containerID, err)
+		}
+		data, err := s.containers.store.ReadRange(ctx, erasure.LayoutFromDB(container), offset, size)
+		if err != nil {
+			return nil, err
+		}
+		if !chunking.VerifyFingerprint(data, fingerprint) {
+			return nil, fmt.Errorf("chunk %s read from container %s does not match its fingerprint", fingerprint, containerID)
+		}
+		return data, nil
+	}
+
+	if s.storage == nil {
+		return nil, fmt.Errorf("no Data Storage Nodes configured")
+	}
+	return s.storage.GetChunk(ctx, fingerprint, nodes)
+}
+
+// parseContainerLocation splits a container://id/offset storage location
+func parseContainerLocation(location string) (string, int64, bool) {
+	rest, ok := strings.CutPrefix(location, "container://")
+	if !ok {
+		return "", 0, false
+	}
+	containerID, offsetText, ok := strings.Cut(rest, "/")
+	if !ok {
+		return "", 0, false
+	}
+	offset, err := strconv.ParseInt(offsetText, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return containerID, offset, true
+}
+
+// selectEntries picks the entries under any of paths, or every entry when
+// paths is empty. Hard links follow every other entry so their targets are
+// restored first; a hard link whose target is not selected is restored as a
+// copy of the target.
+func selectEntries(manifests []db.FileManifest, paths []string) ([]restoreEntry, error) {
+	byPath := make(map[string]*db.FileManifest, len(manifests))
+	for i := range manifests {
+		byPath[manifests[i].FilePath] = &manifests[i]
+	}
+
+	var entries, links []restoreEntry
+	selected := make(map[string]bool)
+	for i := range manifests {
+		if !underAny(manifests[i].FilePath, paths) {
+			continue
+		}
+		entry, err := fileEntry(&manifests[i])
+		if err != nil {
+			return nil, err
+		}
+		selected[entry.FilePath] = true
+		if entry.Type == pb.FileType_FILE_TYPE_HARDLINK {
+			links = append(links, restoreEntry{entry: entry})
+			continue
+		}
+		entries = append(entries, restoreEntry{entry: entry, chunks: manifests[i].Chunks})
+	}
+
+	for _, link := range links {
+		if selected[link.entry.LinkTarget] {
+			entries = append(entries, link)
+			continue
+		}
+		target := byPath[link.entry.LinkTarget]
+		if target == nil {
+			log.Printf("Warning: Hard link %s refers to %s, which is not in the backup", link.entry.FilePath, link.entry.LinkTarget)
+			continue
+		}
+		link.entry.Type = pb.FileType_FILE_TYPE_REGULAR
+		link.entry.LinkTarget = ""
+		link.entry.Size = uint64(target.Size)
+		link.entry.ContentHash = contentHash(target.Chunks)
+		link.chunks = target.Chunks
+		entries = append(entries, link)
+	}
+	return entries, nil
+}
+
+// underAny reports whether path is one of paths or below one of them
+func underAny(path string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	for _, p := range paths {
+		p = strings.TrimSuffix(p, "/")
+		if path == p || strings.HasPrefix(path, p+"/") || p == "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+func TestManifestSelection(t *testing.T) {
+	ctx := context.Background()
+	store := newManifestStore(nil)
+	put := func(entry *pb.FileEntry, chunks ...string) {
+		t.Helper()
+		if err := store.Put(ctx, "job-1", entry, chunks, 1); err != nil {
+			t.Fatalf("Put(%s) failed: %v", entry.FilePath, err)
+		}
+	}
+	put(&pb.FileEntry{FilePath: "/data", Type: pb.FileType_FILE_TYPE_DIRECTORY, Mode: 0o750})
+	put(&pb.FileEntry{FilePath: "/data/a.txt", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o640, Size: 10}, "fp-1", "fp-2")
+	put(&pb.FileEntry{FilePath: "/data/link", Type: pb.FileType_FILE_TYPE_SYMLINK, LinkTarget: "a.txt"})
+	put(&pb.FileEntry{FilePath: "/data/b-same", Type: pb.FileType_FILE_TYPE_HARDLINK, LinkTarget: "/data/a.txt"})
+	put(&pb.FileEntry{FilePath: "/other/copy", Type: pb.FileType_FILE_TYPE_HARDLINK, LinkTarget: "/data/a.txt"})
+
+	manifests, err := store.List(ctx, "job-1")
+	if err != nil || len(manifests) != 5 {
+		t.Fatalf("Expected 5 manifests, got %d (%v)", len(manifests), err)
+	}
+	if manifests[0].FilePath != "/data" || manifests[1].FileType != "REGULAR" {
+		t.Errorf("Unexpected manifest order or type: %+v", manifests[:2])
+	}
+
+	// Hard links come after the file they refer to, even when they sort first
+	entries, err := selectEntries(manifests, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, e := range entries {
+		paths = append(paths, e.entry.FilePath)
+	}
+	want := []string{"/data", "/data/a.txt", "/data/link", "/data/b-same", "/other/copy"}
+	if len(paths) != len(want) {
+		t.Fatalf("Got %v, want %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Fatalf("Got %v, want %v", paths, want)
+		}
+	}
+	if entries[1].entry.Mode != 0o640 || len(entries[1].chunks) != 2 {
+		t.Errorf("Regular file lost its metadata or chunks: %+v", entries[1])
+	}
+
+	// A hard link restored without its target becomes a copy of it
+	entries, err = selectEntries(manifests, []string{"/other/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected only /other/copy, got %d entries", len(entries))
+	}
+	restored := entries[0]
+	if restored.entry.Type != pb.FileType_FILE_TYPE_REGULAR || restored.entry.Size != 10 || len(restored.chunks) != 2 {
+		t.Errorf("Expected a regular copy of /data/a.txt, got %+v with chunks %v", restored.entry, restored.chunks)
+	}
+	if restored.entry.ContentHash != contentHash([]string{"fp-1", "fp-2"}) {
+		t.Errorf("Expected the copy to carry the content hash of /data/a.txt, got %q", restored.entry.ContentHash)
+	}
+
+	// Path selection matches whole components
+	entries, _ = selectEntries(manifests, []string{"/data/a"})
+	if len(entries) != 0 {
+		t.Errorf("Expected /data/a to match nothing, got %d entries", len(entries))
+	}
+}
+
+func TestParseContainerLocation(t *testing.T) {
+	id, offset, ok := parseContainerLocation("container://ctr-abc/4096")
+	if !ok || id != "ctr-abc" || offset != 4096 {
+		t.Errorf("Got %q %d %v", id, offset, ok)
+	}
+	for _, location := range []string{"minio://dedupe-chunks/abc", "container://ctr-abc", "container://ctr-abc/x"} {
+		if _, _, ok := parseContainerLocation(location); ok {
+			t.Errorf("Expected %q to be rejected", location)
+		}
+	}
+}
+
+func TestRestoreOverStreams(t *testing.T) {
+	ctx := context.Background()
+	server := NewIngestServer("0")
+	requests := []*pb.BackupRequest{backupStart("client-a", "job-1", "", 1000)}
+	sizes := map[string]uint64{"/src/a": 4 << 20, "/src/b": 3 << 20, "/src/c": 2 << 20, "/src/d": 1 << 20}
+	for path, size := range sizes {
+		requests = append(requests,
+			&pb.BackupRequest{RequestType: &pb.BackupRequest_FileEntry{FileEntry: &pb.FileEntry{FilePath: path, Type: pb.FileType_FILE_TYPE_REGULAR}}},
+			segment(path, nil, size, true))
+	}
+	requests = append(requests, backupEnd("job-1", "COMPLETED"))
+	if err := server.StreamBackup(&fakeBackupStream{requests: requests}); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	// Every file is recorded with the hash of its contents
+	manifests, _ := server.manifests.List(ctx, "job-1")
+	for i := range manifests {
+		entry, _ := fileEntry(&manifests[i])
+		if entry.ContentHash == "" || entry.ContentHash != contentHash(manifests[i].Chunks) {
+			t.Errorf("%s has content hash %q", entry.FilePath, entry.ContentHash)
+		}
+	}
+
+	resp, err := server.InitiateRestore(ctx, &pb.RestoreRequest{ClientId: "client-a", BackupJobId: "job-1", Streams: 2})
+	if err != nil {
+		t.Fatalf("InitiateRestore failed: %v", err)
+	}
+	if err := server.StreamRestoreData(&fakeRestoreStream{clientID: "client-a", jobID: resp.RestoreJobId, index: 2}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for a stream past the last, got %v", err)
+	}
+	// Only the client that initiated the restore may stream it
+	if err := server.StreamRestoreData(&fakeRestoreStream{clientID: "client-b", jobID: resp.RestoreJobId}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied for another client, got %v", err)
+	}
+	if err := server.StreamRestoreData(&fakeRestoreStream{jobID: resp.RestoreJobId}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument without a client ID, got %v", err)
+	}
+	if other, err := server.InitiateRestore(ctx, &pb.RestoreRequest{ClientId: "client-a", BackupJobId: "job-1"}); err != nil || other.RestoreJobId == resp.RestoreJobId {
+		t.Errorf("Expected a new restore job ID, got %v, %v", other, err)
+	}
+
+	// The streams split the files evenly by size and together send all of them
+	restored := make(map[string]uint64)
+	for index, want := range []uint64{5 << 20, 5 << 20} {
+		stream := &fakeRestoreStream{clientID: "client-a", jobID: resp.RestoreJobId, index: uint32(index)}
+		if err := server.StreamRestoreData(stream); err != nil {
+			t.Fatalf("Stream %d failed: %v", index, err)
+		}
+		var total uint64
+		for _, msg := range stream.responses {
+			restored[msg.FilePath] += msg.HoleSize
+			total += msg.HoleSize
+		}
+		if total != want {
+			t.Errorf("Stream %d sent %d bytes, expected %d", index, total, want)
+		}
+	}
+	for path, size := range sizes {
+		if restored[path] != size {
+			t.Errorf("%s restored with %d bytes, expected %d", path, restored[path], size)
+		}
+	}
+
+	// The job is gone once all of its streams have started
+	if err := server.StreamRestoreData(&fakeRestoreStream{clientID: "client-a", jobID: resp.RestoreJobId}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound after every stream started, got %v", err)
+	}
+}
+
+func TestRestoreChecksBackupJob(t *testing.T) {
+	ctx := context.Background()
+	server := NewIngestServer("0")
+	for jobID, end := range map[string]string{"job-done": "COMPLETED", "job-failed": "FAILED", "job-running": ""} {
+		requests := []*pb.BackupRequest{backupStart("client-a", jobID, "", 1000),
+			{RequestType: &pb.BackupRequest_FileEntry{FileEntry: &pb.FileEntry{FilePath: "/src", Type: pb.FileType_FILE_TYPE_DIRECTORY}}}}
+		if end != "" {
+			requests = append(requests, backupEnd(jobID, end))
+		}
+		if err := server.StreamBackup(&fakeBackupStream{requests: requests}); err != nil {
+			t.Fatalf("%s: %v", jobID, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		req  *pb.RestoreRequest
+		code codes.Code
+	}{
+		{&pb.RestoreRequest{BackupJobId: "job-done"}, codes.InvalidArgument},
+		{&pb.RestoreRequest{ClientId: "client-b", BackupJobId: "job-done"}, codes.PermissionDenied},
+		{&pb.RestoreRequest{ClientId: "client-a", BackupJobId: "job-failed"}, codes.FailedPrecondition},
+		{&pb.RestoreRequest{ClientId: "client-a", BackupJobId: "job-running"}, codes.FailedPrecondition},
+		{&pb.RestoreRequest{ClientId: "client-a", BackupJobId: "job-missing"}, codes.NotFound},
+	} {
+		if _, err := server.InitiateRestore(ctx, tc.req); status.Code(err) != tc.code {
+			t.Errorf("InitiateRestore(%v): expected %v, got %v", tc.req, tc.code, err)
+		}
+	}
+
+	// A restore whose client never opens all of its streams expires
+	stale, err := server.InitiateRestore(ctx, &pb.RestoreRequest{ClientId: "client-a", BackupJobId: "job-done", Streams: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.StreamRestoreData(&fakeRestoreStream{clientID: "client-a", jobID: stale.RestoreJobId}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	cutoff := time.Now()
+	fresh, err := server.InitiateRestore(ctx, &pb.RestoreRequest{ClientId: "client-a", BackupJobId: "job-done"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.reapRestores(cutoff)
+	if err := server.StreamRestoreData(&fakeRestoreStream{clientID: "client-a", jobID: stale.RestoreJobId, index: 1}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected the expired restore to be dropped, got %v", err)
+	}
+	if err := server.StreamRestoreData(&fakeRestoreStream{clientID: "client-a", jobID: fresh.RestoreJobId}); err != nil {
+		t.Errorf("Expected the recent restore to be kept, got %v", err)
+	}
+}
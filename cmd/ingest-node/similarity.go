@@ -0,0 +1,292 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/db"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/delta"
+)
+
+// Delta compression stores a new chunk that resembles a stored one as the
+// differences from it. Chunks are matched by their super-features; the delta
+// is stored as an object of its own, keyed by its fingerprint like any chunk,
+// and the chunk's location points at it as delta://<key>. Restoring the chunk
+// applies the delta to its base, which may itself be a delta.
+const (
+	// maxDeltaChainDepth bounds the deltas followed to rebuild a chunk on
+	// restore; DELTA_MAX_DEPTH may only lower it for new chunks
+	maxDeltaChainDepth = 8
+
+	// A delta is only stored if it is at most 1/minDeltaRatio of the chunk
+	minDeltaRatio = 2
+)
+
+// deltaCompressor finds similar base chunks for new chunks and keeps the data
+// of recently stored objects, so bases are seldom read back from storage
+type deltaCompressor struct {
+	index    *similarityIndex
+	recent   *chunkDataCache
+	maxDepth int
+}
+
+// newDeltaCompressor creates a delta compressor; dbClient may be nil
+func newDeltaCompressor(dbClient *db.DB, maxDepth, cacheBytes int) *deltaCompressor {
+	if maxDepth < 1 || maxDepth > maxDeltaChainDepth {
+		log.Printf("Warning: Delta chain depth %d out of range, using %d", maxDepth, maxDeltaChainDepth)
+		maxDepth = maxDeltaChainDepth
+	}
+	return &deltaCompressor{
+		index:    newSimilarityIndex(dbClient),
+		recent:   newChunkDataCache(int64(cacheBytes)),
+		maxDepth: maxDepth,
+	}
+}
+
+// similarityIndex maps the super-features of stored chunks to the chunks, in
+// CockroachDB when it is configured and in memory otherwise. A super-feature
+// shared by several chunks maps to the most recently stored one.
+type similarityIndex struct {
+	dbClient *db.DB
+
+	mutex    sync.RWMutex
+	features map[uint64]chunking.Fingerprint // super-feature -> fingerprint
+}
+
+// newSimilarityIndex creates a similarity index; dbClient may be nil
+func newSimilarityIndex(dbClient *db.DB) *similarityIndex {
+	return &similarityIndex{dbClient: dbClient, features: make(map[uint64]chunking.Fingerprint)}
+}
+
+// Add indexes the super-features of a stored chunk
+func (x *similarityIndex) Add(ctx context.Context, fingerprint chunking.Fingerprint, features chunking.SuperFeatures) error {
+	if x.dbClient != nil {
+		return x.dbClient.AddChunkFeatures(ctx, fingerprint, featureKeys(features))
+	}
+
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	for _, feature := range features {
+		x.features[feature] = fingerprint
+	}
+	return nil
+}
+
+// Find returns the stored chunk sharing the most super-features, or the zero
+// fingerprint if none shares any
+func (x *similarityIndex) Find(ctx context.Context, features chunking.SuperFeatures) (chunking.Fingerprint, error) {
+	if x.dbClient != nil {
+		return x.dbClient.FindSimilarChunk(ctx, featureKeys(features))
+	}
+
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+	matches := make(map[chunking.Fingerprint]int)
+	var best chunking.Fingerprint
+	for _, feature := range features {
+		fingerprint, ok := x.features[feature]
+		if !ok {
+			continue
+		}
+		matches[fingerprint]++
+		if best.IsZero() || matches[fingerprint] > matches[best] {
+			best = fingerprint
+		}
+	}
+	return best, nil
+}
+
+// featureKeys converts super-features to the INT8 keys of the database
+func featureKeys(features chunking.SuperFeatures) []int64 {
+	keys := make([]int64, len(features))
+	for i, feature := range features {
+		keys[i] = int64(feature)
+	}
+	return keys
+}
+
+// chunkDataCache is an LRU cache of object data bounded by its total size
+type chunkDataCache struct {
+	capacity int64
+	size     int64
+
+	mutex   sync.Mutex
+	entries map[chunking.Fingerprint]*list.Element
+	order   *list.List // most recently used first
+}
+
+type cachedData struct {
+	fingerprint chunking.Fingerprint
+	data        []byte
+}
+
+func newChunkDataCache(capacity int64) *chunkDataCache {
+	return &chunkDataCache{capacity: capacity, entries: make(map[chunking.Fingerprint]*list.Element), order: list.New()}
+}
+
+// Get returns the cached data of an object
+func (c *chunkDataCache) Get(fingerprint chunking.Fingerprint) ([]byte, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.entries[fingerprint]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cachedData).data, true
+}
+
+// Put caches a copy of the data of an object, evicting the least recently
+// used objects to stay within capacity
+func (c *chunkDataCache) Put(fingerprint chunking.Fingerprint, data []byte) {
+	if int64(len(data)) > c.capacity {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[fingerprint]; ok {
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[fingerprint] = c.order.PushFront(&cachedData{fingerprint: fingerprint, data: append([]byte(nil), data...)})
+	c.size += int64(len(data))
+	for c.size > c.capacity {
+		oldest := c.order.Remove(c.order.Back()).(*cachedData)
+		delete(c.entries, oldest.fingerprint)
+		c.size -= int64(len(oldest.data))
+	}
+}
+
+// deltaChunk is a new chunk to be stored as a delta against a base chunk
+type deltaChunk struct {
+	chunk    chunking.Chunk
+	base     chunking.Fingerprint
+	depth    int
+	deltaKey chunking.Fingerprint // fingerprint of the delta object
+}
+
+// encodeDeltas replaces the new chunks that resemble stored chunks with
+// deltas against them. It returns the objects to store, whole chunks and
+// deltas, and the chunks to record as deltas once those are stored.
+func (s *IngestServer) encodeDeltas(ctx context.Context, job *BackupJobState, chunks []chunking.Chunk) ([]chunking.Chunk, []deltaChunk) {
+	if s.deltas == nil {
+		return chunks, nil
+	}
+
+	var objects []chunking.Chunk
+	var deltas []deltaChunk
+	var saved int64
+	added := make(map[chunking.Fingerprint]bool)
+	for _, chunk := range chunks {
+		d, ok := s.encodeDelta(ctx, chunk)
+		if !ok {
+			objects = append(objects, chunk)
+			continue
+		}
+		deltas = append(deltas, deltaChunk{chunk: chunk, base: d.base, depth: d.depth, deltaKey: d.Fingerprint})
+		saved += chunk.Size - d.Size
+		log.Printf("  Chunk %s: DELTA against %s (%d of %d bytes, depth %d)", chunk.Fingerprint.Short(), d.base.Short(), d.Size, chunk.Size, d.depth)
+
+		// The same delta may already be stored, or be part of this batch
+		if added[d.Fingerprint] {
+			continue
+		}
+		added[d.Fingerprint] = true
+		if metadata, _ := s.lookupChunk(ctx, d.Fingerprint); metadata == nil {
+			objects = append(objects, d.Chunk)
+		}
+	}
+
+	job.mutex.Lock()
+	job.ChunksDelta += len(deltas)
+	job.BytesDeltaSaved += saved
+	job.mutex.Unlock()
+	return objects, deltas
+}
+
+// encodedDelta is the delta object of a chunk and the base it applies to
+type encodedDelta struct {
+	chunking.Chunk
+	base  chunking.Fingerprint
+	depth int
+}
+
+// encodeDelta encodes a chunk as a delta against the most similar stored
+// chunk. It fails if there is none, if the chain would grow too deep or if
+// the delta saves too little.
+func (s *IngestServer) encodeDelta(ctx context.Context, chunk chunking.Chunk) (encodedDelta, bool) {
+	features, ok := chunking.ComputeSuperFeatures(chunk.Data)
+	if !ok {
+		return encodedDelta{}, false
+	}
+	base, err := s.deltas.index.Find(ctx, features)
+	if err != nil {
+		log.Printf("Warning: Failed to look up chunks similar to %s: %v", chunk.Fingerprint.Short(), err)
+		return encodedDelta{}, false
+	}
+	if base.IsZero() || base == chunk.Fingerprint {
+		return encodedDelta{}, false
+	}
+	metadata, _ := s.lookupChunk(ctx, base)
+	if metadata == nil || metadata.DeltaDepth+1 > s.deltas.maxDepth {
+		return encodedDelta{}, false
+	}
+	baseData, err := s.readChunk(ctx, base)
+	if err != nil {
+		log.Printf("Warning: Failed to read base chunk %s: %v", base.Short(), err)
+		return encodedDelta{}, false
+	}
+
+	data := delta.Encode(baseData, chunk.Data)
+	if int64(len(data))*minDeltaRatio > chunk.Size {
+		return encodedDelta{}, false
+	}
+	return encodedDelta{
+		Chunk: chunking.Chunk{Fingerprint: s.chunker.HashAlgorithm().Sum(data), Data: data, Size: int64(len(data))},
+		base:  base,
+		depth: metadata.DeltaDepth + 1,
+	}, true
+}
+
+// recordDeltas records the chunks stored as deltas once their delta objects
+// are stored
+func (s *IngestServer) recordDeltas(deltas []deltaChunk) {
+	now := time.Now()
+	for _, d := range deltas {
+		s.recordChunkMetadata(&db.ChunkMetadata{
+			Fingerprint:        d.chunk.Fingerprint,
+			StorageLocation:    fmt.Sprintf("delta://%s", d.deltaKey),
+			Size:               int(d.chunk.Size),
+			CreationTime:       now,
+			LastReferencedTime: now,
+			BaseFingerprint:    d.base,
+			DeltaDepth:         d.depth,
+		})
+	}
+}
+
+// indexChunks caches the data of stored objects and indexes the
+// super-features of new chunks, so later chunks may be stored as deltas
+// against them
+func (s *IngestServer) indexChunks(ctx context.Context, objects []chunking.Chunk, chunks []chunking.Chunk) {
+	if s.deltas == nil {
+		return
+	}
+	for _, object := range objects {
+		s.deltas.recent.Put(object.Fingerprint, object.Data)
+	}
+	for _, chunk := range chunks {
+		features, ok := chunking.ComputeSuperFeatures(chunk.Data)
+		if !ok {
+			continue
+		}
+		if err := s.deltas.index.Add(ctx, chunk.Fingerprint, features); err != nil {
+			log.Printf("Warning: Failed to index features of chunk %s: %v", chunk.Fingerprint.Short(), err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+)
+
+// parseRefs parses the chunk references of a file without holes
+func parseRefs(t *testing.T, refs []string) []chunking.Fingerprint {
+	t.Helper()
+	fingerprints := make([]chunking.Fingerprint, len(refs))
+	for i, ref := range refs {
+		fingerprint, err := chunking.ParseFingerprint(ref)
+		if err != nil {
+			t.Fatalf("Unexpected chunk reference: %v", err)
+		}
+		fingerprints[i] = fingerprint
+	}
+	return fingerprints
+}
+
+func TestNearDuplicateChunksStoredAsDeltas(t *testing.T) {
+	server := NewIngestServer("0")
+	server.deltas = newDeltaCompressor(nil, 3, 1<<20)
+
+	original := make([]byte, 60000)
+	rand.New(rand.NewSource(1)).Read(original)
+	edited := append([]byte(nil), original...)
+	for _, offset := range []int{5000, 30000, 52000} {
+		copy(edited[offset:], "an edit of the file")
+	}
+
+	backupFile(t, server, "job-original", "/data/file.bin", original, false, 4096)
+	refs := parseRefs(t, backupFile(t, server, "job-edited", "/data/file.bin", edited, false, 4096))
+
+	job, _ := server.history.Get(context.Background(), "job-edited")
+	if job.Stats.ChunksDelta == 0 || job.Stats.BytesDeltaSaved == 0 {
+		t.Fatalf("Expected the edited chunks to be stored as deltas, got %d saving %d bytes", job.Stats.ChunksDelta, job.Stats.BytesDeltaSaved)
+	}
+
+	// Chunks stored as deltas are rebuilt from their bases
+	var restored []byte
+	deltas := 0
+	for _, ref := range refs {
+		if meta, ok := server.cache.GetChunkMetadata(ref); ok && strings.HasPrefix(meta.StorageLocation, "delta://") {
+			deltas++
+		}
+		data, err := server.readChunk(context.Background(), ref)
+		if err != nil {
+			t.Fatalf("Failed to read chunk %s: %v", ref.Short(), err)
+		}
+		restored = append(restored, data...)
+	}
+	if int64(deltas) != job.Stats.ChunksDelta {
+		t.Errorf("Expected %d chunks located in deltas, found %d", job.Stats.ChunksDelta, deltas)
+	}
+	if !bytes.Equal(restored, edited) {
+		t.Error("Restored chunks do not match the edited file")
+	}
+}
+
+func TestDeltaChainDepth(t *testing.T) {
+	server := NewIngestServer("0")
+	server.deltas = newDeltaCompressor(nil, 2, 1<<20)
+
+	// Each version edits the last, so its chunks would chain deltas on it
+	data := make([]byte, 8000)
+	rand.New(rand.NewSource(2)).Read(data)
+	deepest := 0
+	for version := 0; version < 5; version++ {
+		copy(data[100*version:], "edit")
+		refs := parseRefs(t, backupFile(t, server, "job-"+string(rune('a'+version)), "/data/file.bin", data, false, len(data)))
+		for _, ref := range refs {
+			meta, _ := server.cache.GetChunkMetadata(ref)
+			deepest = max(deepest, meta.DeltaDepth)
+			if meta.DeltaDepth > 2 {
+				t.Errorf("Version %d: chunk %s is %d deltas deep, expected at most 2", version, ref.Short(), meta.DeltaDepth)
+			}
+		}
+		restored, err := server.readChunk(context.Background(), refs[0])
+		if err != nil || !bytes.Equal(restored, data[:len(restored)]) {
+			t.Fatalf("Version %d: failed to restore the first chunk: %v", version, err)
+		}
+	}
+	if deepest != 2 {
+		t.Errorf("Expected chains to reach the depth limit, deepest was %d", deepest)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/db"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// chunkOwners records which clients have sent the data of which chunks, in
+// CockroachDB when it is configured and in memory otherwise. A client may
+// only skip sending a chunk it owns: knowing a fingerprint is not proof of
+// holding the data, and would otherwise let a client restore data it never had.
+type chunkOwners struct {
+	dbClient *db.DB
+
+	mutex  sync.RWMutex
+	owners map[string]map[chunking.Fingerprint]bool // client ID -> fingerprints
+}
+
+// newChunkOwners creates an ownership store; dbClient may be nil
+func newChunkOwners(dbClient *db.DB) *chunkOwners {
+	return &chunkOwners{dbClient: dbClient, owners: make(map[string]map[chunking.Fingerprint]bool)}
+}
+
+// Add records that a client has sent the data of fingerprints
+func (o *chunkOwners) Add(ctx context.Context, clientID string, fingerprints []chunking.Fingerprint) error {
+	if o.dbClient != nil {
+		return o.dbClient.AddChunkOwners(ctx, clientID, fingerprints)
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.owners[clientID] == nil {
+		o.owners[clientID] = make(map[chunking.Fingerprint]bool)
+	}
+	for _, fingerprint := range fingerprints {
+		o.owners[clientID][fingerprint] = true
+	}
+	return nil
+}
+
+// Owned returns which of fingerprints the client has sent the data of
+func (o *chunkOwners) Owned(ctx context.Context, clientID string, fingerprints []chunking.Fingerprint) (map[chunking.Fingerprint]bool, error) {
+	owned := make(map[chunking.Fingerprint]bool)
+	if o.dbClient != nil {
+		list, err := o.dbClient.ListOwnedChunks(ctx, clientID, fingerprints)
+		if err != nil {
+			return nil, err
+		}
+		for _, fingerprint := range list {
+			owned[fingerprint] = true
+		}
+		return owned, nil
+	}
+
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	for _, fingerprint := range fingerprints {
+		if o.owners[clientID][fingerprint] {
+			owned[fingerprint] = true
+		}
+	}
+	return owned, nil
+}
+
+// receiveChunkRefs adds a batch of chunk fingerprints to a file and asks the
+// client for the data of every chunk that is not stored or that the client
+// has not proven it holds
+func (s *IngestServer) receiveChunkRefs(stream pb.BackupService_StreamBackupServer, upload *jobUpload, batch *pb.ChunkRefBatch) error {
+	ctx := stream.Context()
+	job := upload.job
+	file := upload.file(batch.FilePath)
+	file.resumed = false
+	if len(file.awaiting) > 0 {
+		return status.Errorf(codes.InvalidArgument, "Chunk batch for %s sent before the missing chunks of the previous batch", batch.FilePath)
+	}
+	if err := s.storeFileData(ctx, upload, batch.FilePath, file); err != nil {
+		return status.Errorf(codes.Internal, "Failed to process file: %v", err)
+	}
+
+	var claimed []chunking.Fingerprint
+	var chunks int
+	var processed, deduplicated int64
+	sizes := make(map[chunking.Fingerprint]int64)
+	fingerprints := make([]chunking.Fingerprint, len(batch.Chunks)) // zero for holes
+	for i, ref := range batch.Chunks {
+		size := int64(ref.Size)
+		if len(ref.Fingerprint) == 0 {
+			file.addHole(size)
+			continue
+		}
+		fingerprint, err := chunking.FingerprintFromBytes(ref.Fingerprint)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "Invalid chunk fingerprint %x", ref.Fingerprint)
+		}
+		if algorithm := fingerprint.Algorithm(); algorithm != s.chunker.HashAlgorithm() {
+			return status.Errorf(codes.InvalidArgument, "Chunk fingerprint %s is %s, but the repository uses %s", fingerprint, algorithm, s.chunker.HashAlgorithm())
+		}
+		if known, ok := sizes[fingerprint]; ok && known != size {
+			return status.Errorf(codes.InvalidArgument, "Chunk %s sent with sizes %d and %d", fingerprint, known, size)
+		}
+		fingerprints[i] = fingerprint
+		file.batch = append(file.batch, fingerprint)
+		file.refs = append(file.refs, fingerprint.String())
+		file.size += size
+		chunks++
+		processed += size
+		if _, ok := sizes[fingerprint]; !ok {
+			claimed = append(claimed, fingerprint)
+		}
+		sizes[fingerprint] = size
+	}
+
+	owned, err := s.owners.Owned(ctx, job.ClientID, claimed)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to check chunk ownership: %v", err)
+	}
+	found, _ := s.lookupChunks(ctx, claimed)
+	var missing [][]byte
+	file.awaiting = make(map[chunking.Fingerprint]int64)
+	for _, fingerprint := range claimed {
+		if metadata := found[fingerprint]; owned[fingerprint] && metadata != nil && metadata.Size == sizes[fingerprint] {
+			continue
+		}
+		missing = append(missing, fingerprint.Bytes())
+		file.awaiting[fingerprint] = sizes[fingerprint]
+	}
+	requested := make(map[chunking.Fingerprint]bool)
+	for i, ref := range batch.Chunks {
+		fingerprint := fingerprints[i]
+		if _, ok := file.awaiting[fingerprint]; ok && !requested[fingerprint] {
+			requested[fingerprint] = true
+			continue
+		}
+		if !fingerprint.IsZero() {
+			deduplicated += int64(ref.Size)
+		}
+	}
+	file.lastBatch = batch.IsLastBatch
+
+	job.mutex.Lock()
+	job.ChunksProcessed += chunks
+	job.BytesProcessed += processed
+	job.BytesDeduplicated += deduplicated
+	job.mutex.Unlock()
+
+	log.Printf("Received %d chunk fingerprints for %s: %d missing", len(batch.Chunks), batch.FilePath, len(missing))
+	resp := &pb.BackupResponse{
+		ResponseType: &pb.BackupResponse_MissingChunks{
+			MissingChunks: &pb.MissingChunks{FilePath: batch.FilePath, Fingerprints: missing},
+		},
+	}
+	if err := stream.Send(resp); err != nil {
+		return status.Errorf(codes.Internal, "Failed to send missing chunks: %v", err)
+	}
+	return s.completeChunkBatch(stream, upload, batch.FilePath, file)
+}
+
+// receiveChunkData accepts the data of a requested chunk after checking it
+// matches its fingerprint
+func (s *IngestServer) receiveChunkData(stream pb.BackupService_StreamBackupServer, upload *jobUpload, chunk *pb.ChunkData) error {
+	file := upload.files[chunk.FilePath]
+	if file == nil {
+		return status.Errorf(codes.InvalidArgument, "Chunk data for %s, which has no pending chunks", chunk.FilePath)
+	}
+	fingerprint, err := chunking.FingerprintFromBytes(chunk.Fingerprint)
+	size, ok := file.awaiting[fingerprint]
+	if err != nil || !ok {
+		return status.Errorf(codes.InvalidArgument, "Chunk %x of %s was not requested", chunk.Fingerprint, chunk.FilePath)
+	}
+	if int64(len(chunk.Data)) != size || !chunking.VerifyFingerprint(chunk.Data, fingerprint) {
+		return status.Errorf(codes.InvalidArgument, "Data sent for chunk %s of %s does not match its fingerprint", fingerprint, chunk.FilePath)
+	}
+
+	delete(file.awaiting, fingerprint)
+	file.received = append(file.received, chunking.Chunk{Data: chunk.Data, Fingerprint: fingerprint, Size: size})
+	return s.completeChunkBatch(stream, upload, chunk.FilePath, file)
+}
+
+// completeChunkBatch stores the received chunks once every requested chunk
+// of a batch has arrived, as the store stage of the ingest pipeline does, and
+// finishes the file after its last batch
+func (s *IngestServer) completeChunkBatch(stream pb.BackupService_StreamBackupServer, upload *jobUpload, filePath string, file *pendingFile) error {
+	if len(file.awaiting) > 0 {
+		return nil
+	}
+	ctx := stream.Context()
+	job := upload.job
+
+	// Another client may have stored a chunk since the batch was checked
+	received := make([]chunking.Fingerprint, len(file.received))
+	for i, chunk := range file.received {
+		received[i] = chunk.Fingerprint
+	}
+	found, _ := s.lookupChunks(ctx, received)
+	var newChunks []chunking.Chunk
+	for _, chunk := range file.received {
+		if found[chunk.Fingerprint] == nil {
+			newChunks = append(newChunks, chunk)
+		}
+	}
+
+	// New chunks that resemble stored ones are stored as deltas, and all are
+	// indexed so later chunks may be stored as deltas against them
+	objects, deltas := s.encodeDeltas(ctx, job, newChunks)
+	for start := 0; start < len(objects); start += storeBatchSize {
+		end := min(start+storeBatchSize, len(objects))
+		if err := s.storeUniqueChunks(ctx, objects[start:end]); err != nil {
+			return status.Errorf(codes.Internal, "Failed to store chunks: %v", err)
+		}
+	}
+	s.recordDeltas(deltas)
+	s.indexChunks(ctx, objects, newChunks)
+	if err := s.owners.Add(ctx, job.ClientID, received); err != nil {
+		return status.Errorf(codes.Internal, "Failed to record chunk ownership: %v", err)
+	}
+	if err := upload.acknowledge(filePath, file.batch, len(newChunks)); err != nil {
+		return status.Errorf(codes.Internal, "Failed to acknowledge stored chunks: %v", err)
+	}
+	file.received, file.batch = nil, nil
+
+	if file.lastBatch {
+		if err := s.processFile(upload, filePath, stream); err != nil {
+			return status.Errorf(codes.Internal, "Failed to process file: %v", err)
+		}
+		return nil
+	}
+	return s.checkpointPartial(ctx, job, filePath, file)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"math/rand"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// startJob begins a backup job for a client on a fresh stream and returns
+// that stream's upload to it
+func startJob(t *testing.T, server *IngestServer, clientID, jobID string) (*fakeBackupStream, *jobUpload) {
+	t.Helper()
+	stream := &fakeBackupStream{requests: []*pb.BackupRequest{
+		{RequestType: &pb.BackupRequest_StartBackup{StartBackup: &pb.BackupStart{ClientId: clientID, BackupJobId: jobID}}},
+	}}
+	if err := server.StreamBackup(stream); err != nil {
+		t.Fatal(err)
+	}
+	return stream, newJobUpload(server.backupJobs[jobID], stream)
+}
+
+// lastMissing returns the fingerprints of the most recent MissingChunks response
+func lastMissing(t *testing.T, stream *fakeBackupStream) [][]byte {
+	t.Helper()
+	for i := len(stream.responses) - 1; i >= 0; i-- {
+		if missing := stream.responses[i].GetMissingChunks(); missing != nil {
+			return missing.Fingerprints
+		}
+	}
+	t.Fatal("No MissingChunks response")
+	return nil
+}
+
+func chunkRefs(chunks []chunking.Chunk) []*pb.ChunkRef {
+	refs := make([]*pb.ChunkRef, len(chunks))
+	for i, c := range chunks {
+		refs[i] = &pb.ChunkRef{Fingerprint: c.Fingerprint.Bytes(), Size: uint64(c.Size)}
+	}
+	return refs
+}
+
+func TestSourceDedupe(t *testing.T) {
+	server := NewIngestServer("0")
+	data := make([]byte, 64*1024)
+	for i := range data {
+		data[i] = byte(i * 7 % 251)
+	}
+	chunks, err := chunking.NewChunker(64, 8192).ChunkData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs := chunkRefs(chunks)
+
+	// First backup: every chunk is requested, uploaded and verified
+	stream, job := startJob(t, server, "client-a", "job-1")
+	batch := &pb.ChunkRefBatch{FilePath: "/f", Chunks: append(refs, &pb.ChunkRef{Size: 4096}), IsLastBatch: true}
+	if err := server.receiveChunkRefs(stream, job, batch); err != nil {
+		t.Fatalf("receiveChunkRefs failed: %v", err)
+	}
+	missing := lastMissing(t, stream)
+	if len(missing) != len(chunks) {
+		t.Fatalf("Expected all %d chunks missing, got %d", len(chunks), len(missing))
+	}
+	for _, c := range chunks {
+		msg := &pb.ChunkData{FilePath: "/f", Fingerprint: c.Fingerprint.Bytes(), Data: c.Data}
+		if err := server.receiveChunkData(stream, job, msg); err != nil {
+			t.Fatalf("receiveChunkData failed: %v", err)
+		}
+	}
+	var acks []*pb.ChunksStored
+	for _, resp := range stream.responses {
+		if stored := resp.GetChunksStored(); stored != nil {
+			acks = append(acks, stored)
+		}
+	}
+	if len(acks) != 1 || len(acks[0].Fingerprints) != len(chunks) || int(acks[0].NewChunks) != len(chunks) {
+		t.Errorf("Expected the uploaded batch to be acknowledged once stored, got %v", acks)
+	}
+	manifests, _ := server.manifests.List(context.Background(), "job-1")
+	if len(manifests) != 1 || manifests[0].Size != int64(len(data))+4096 || len(manifests[0].Chunks) != len(chunks)+1 {
+		t.Fatalf("Unexpected manifest: %+v", manifests)
+	}
+
+	// Second backup by the same client: nothing needs uploading
+	stream, job = startJob(t, server, "client-a", "job-2")
+	if err := server.receiveChunkRefs(stream, job, &pb.ChunkRefBatch{FilePath: "/f", Chunks: refs, IsLastBatch: true}); err != nil {
+		t.Fatal(err)
+	}
+	if missing := lastMissing(t, stream); len(missing) != 0 {
+		t.Errorf("Expected no missing chunks for the owning client, got %d", len(missing))
+	}
+	if manifests, _ := server.manifests.List(context.Background(), "job-2"); len(manifests) != 1 {
+		t.Errorf("Expected the file to be recorded without uploads")
+	}
+
+	// Another client must prove it holds the data even though it is stored
+	stream, job = startJob(t, server, "client-b", "job-3")
+	if err := server.receiveChunkRefs(stream, job, &pb.ChunkRefBatch{FilePath: "/f", Chunks: refs[:1], IsLastBatch: true}); err != nil {
+		t.Fatal(err)
+	}
+	if missing := lastMissing(t, stream); len(missing) != 1 {
+		t.Fatalf("Expected the unowned chunk to be requested, got %v", missing)
+	}
+	forged := &pb.ChunkData{FilePath: "/f", Fingerprint: chunks[0].Fingerprint.Bytes(), Data: bytes.Repeat([]byte{1}, int(chunks[0].Size))}
+	if err := server.receiveChunkData(stream, job, forged); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected forged data to be rejected, got %v", err)
+	}
+	unrequested := &pb.ChunkData{FilePath: "/f", Fingerprint: chunks[1].Fingerprint.Bytes(), Data: chunks[1].Data}
+	if err := server.receiveChunkData(stream, job, unrequested); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected unrequested chunk to be rejected, got %v", err)
+	}
+
+	bad := &pb.ChunkRefBatch{FilePath: "/g", Chunks: []*pb.ChunkRef{{Fingerprint: []byte("not-a-fingerprint"), Size: 1}}}
+	if err := server.receiveChunkRefs(stream, job, bad); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected invalid fingerprint to be rejected, got %v", err)
+	}
+}
+
+func TestSourceDedupeHashAlgorithm(t *testing.T) {
+	server := NewIngestServer("0")
+	server.chunker.SetHashAlgorithm(chunking.SHA256)
+	chunker := chunking.NewChunker(64, 8192)
+	chunker.SetHashAlgorithm(chunking.SHA256)
+	chunks, err := chunker.ChunkData(bytes.Repeat([]byte("sha-256 repository "), 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, job := startJob(t, server, "client-a", "job-1")
+	if got := stream.responses[0].GetStatusUpdate().GetFingerprintAlgorithm(); got != "sha256" {
+		t.Errorf("Expected the start status to name sha256, got %q", got)
+	}
+	if got := stream.responses[0].GetStatusUpdate().GetChunkingProfile(); got.GetVersion() != 1 || got.GetMinSize() != 64 || got.GetMaxSize() != 8192 {
+		t.Errorf("Expected the start status to carry chunking profile 1, got %v", got)
+	}
+	if err := server.receiveChunkRefs(stream, job, &pb.ChunkRefBatch{FilePath: "/f", Chunks: chunkRefs(chunks), IsLastBatch: true}); err != nil {
+		t.Fatalf("receiveChunkRefs failed: %v", err)
+	}
+	for _, c := range chunks {
+		if err := server.receiveChunkData(stream, job, &pb.ChunkData{FilePath: "/f", Fingerprint: c.Fingerprint.Bytes(), Data: c.Data}); err != nil {
+			t.Fatalf("receiveChunkData failed: %v", err)
+		}
+	}
+
+	// Blake3 fingerprints cannot be mixed into the repository
+	blake3 := &pb.ChunkRefBatch{FilePath: "/g", Chunks: []*pb.ChunkRef{{Fingerprint: chunking.ComputeFingerprint([]byte("x")).Bytes(), Size: 1}}}
+	if err := server.receiveChunkRefs(stream, job, blake3); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected a Blake3 fingerprint to be rejected, got %v", err)
+	}
+}
+
+func TestSourceDedupeStoresDeltas(t *testing.T) {
+	server := NewIngestServer("0")
+	server.deltas = newDeltaCompressor(nil, 3, 1<<20)
+	chunker := chunking.NewChunker(64, 8192)
+	upload := func(jobID string, data []byte) *BackupJobState {
+		chunks, err := chunker.ChunkData(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream, job := startJob(t, server, "client-a", jobID)
+		if err := server.receiveChunkRefs(stream, job, &pb.ChunkRefBatch{FilePath: "/f", Chunks: chunkRefs(chunks), IsLastBatch: true}); err != nil {
+			t.Fatal(err)
+		}
+		awaiting := maps.Clone(job.files["/f"].awaiting)
+		for _, c := range chunks {
+			if _, ok := awaiting[c.Fingerprint]; !ok {
+				continue
+			}
+			if err := server.receiveChunkData(stream, job, &pb.ChunkData{FilePath: "/f", Fingerprint: c.Fingerprint.Bytes(), Data: c.Data}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return job.job
+	}
+
+	// Uploaded chunks are indexed, so an edited copy is stored as deltas
+	original := make([]byte, 8000)
+	rand.New(rand.NewSource(3)).Read(original)
+	edited := append([]byte(nil), original...)
+	copy(edited[100:], "an edit of the file")
+	upload("job-1", original)
+	job := upload("job-2", edited)
+	if job.ChunksDelta == 0 || job.BytesDeltaSaved == 0 {
+		t.Errorf("Expected the edited chunk to be stored as a delta, got %d saving %d bytes", job.ChunksDelta, job.BytesDeltaSaved)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// fakeBackupStream replays requests to StreamBackup and collects responses
+type fakeBackupStream struct {
+	grpc.ServerStream
+	requests  []*pb.BackupRequest
+	responses []*pb.BackupResponse
+}
+
+func (f *fakeBackupStream) Context() context.Context { return context.Background() }
+
+func (f *fakeBackupStream) Send(resp *pb.BackupResponse) error {
+	f.responses = append(f.responses, resp)
+	return nil
+}
+
+func (f *fakeBackupStream) Recv() (*pb.BackupRequest, error) {
+	if len(f.requests) == 0 {
+		return nil, io.EOF
+	}
+	req := f.requests[0]
+	f.requests = f.requests[1:]
+	return req, nil
+}
+
+// fakeRestoreStream names a restore job, stream and client and collects the
+// responses
+type fakeRestoreStream struct {
+	grpc.ServerStream
+	clientID  string
+	jobID     string
+	index     uint32
+	received  bool
+	responses []*pb.RestoreDataResponse
+}
+
+func (f *fakeRestoreStream) Context() context.Context { return context.Background() }
+
+func (f *fakeRestoreStream) Recv() (*pb.RestoreDataRequest, error) {
+	if f.received {
+		return nil, io.EOF
+	}
+	f.received = true
+	return &pb.RestoreDataRequest{RestoreJobId: f.jobID, StreamIndex: f.index, ClientId: f.clientID}, nil
+}
+
+func (f *fakeRestoreStream) Send(resp *pb.RestoreDataResponse) error {
+	f.responses = append(f.responses, resp)
+	return nil
+}
+
+func segment(path string, data []byte, hole uint64, last bool) *pb.BackupRequest {
+	return &pb.BackupRequest{RequestType: &pb.BackupRequest_FileSegment{FileSegment: &pb.FileSegment{
+		FilePath: path, Data: data, HoleSize: hole, IsLastSegment: last,
+	}}}
+}
+
+func TestSparseBackupAndRestore(t *testing.T) {
+	server := NewIngestServer("0")
+	const gib = 1 << 30
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1024)
+	stream := &fakeBackupStream{requests: []*pb.BackupRequest{
+		{RequestType: &pb.BackupRequest_StartBackup{StartBackup: &pb.BackupStart{ClientId: "client-a", BackupJobId: "job-sparse"}}},
+		{RequestType: &pb.BackupRequest_FileEntry{FileEntry: &pb.FileEntry{FilePath: "/vm/disk.img", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o600}}},
+		segment("/vm/disk.img", data, 0, false),
+		segment("/vm/disk.img", nil, 100*gib, false),
+		segment("/vm/disk.img", data, 0, false),
+		segment("/vm/disk.img", nil, gib, true),
+		// Zeros sent as data by an older stream handler still become a hole
+		segment("/vm/zeros", make([]byte, 1<<20), 0, true),
+		segment("/vm/empty.img", nil, 8*gib, true),
+		{RequestType: &pb.BackupRequest_EndBackup{EndBackup: &pb.BackupEnd{BackupJobId: "job-sparse", Status: "COMPLETED"}}},
+	}}
+	if err := server.StreamBackup(stream); err != nil {
+		t.Fatalf("StreamBackup failed: %v", err)
+	}
+
+	manifests, err := server.manifests.List(context.Background(), "job-sparse")
+	if err != nil || len(manifests) != 3 {
+		t.Fatalf("Expected 3 manifests, got %d (%v)", len(manifests), err)
+	}
+	disk, empty, zeros := manifests[0], manifests[1], manifests[2]
+
+	wantSize := int64(2*len(data)) + 101*gib
+	if disk.Size != wantSize {
+		t.Errorf("Expected disk size %d, got %d", wantSize, disk.Size)
+	}
+	var holes, chunks int
+	var covered int64
+	for _, ref := range disk.Chunks {
+		if size, ok := parseHoleRef(ref); ok {
+			holes++
+			covered += size
+		} else {
+			chunks++
+		}
+	}
+	if holes != 2 || covered != 101*gib || chunks == 0 {
+		t.Errorf("Expected 2 holes covering 101 GiB and some chunks, got %v", disk.Chunks)
+	}
+
+	if len(zeros.Chunks) != 1 || zeros.Chunks[0] != holeRef(1<<20) || zeros.Size != 1<<20 {
+		t.Errorf("Expected zeros to be one 1 MiB hole, got %v (size %d)", zeros.Chunks, zeros.Size)
+	}
+
+	// A fully sparse file restores as hole messages only
+	resp, err := server.InitiateRestore(context.Background(), &pb.RestoreRequest{ClientId: "client-a", BackupJobId: "job-sparse", FilesToRestore: []string{"/vm/empty.img"}})
+	if err != nil {
+		t.Fatalf("InitiateRestore failed: %v", err)
+	}
+	restore := &fakeRestoreStream{clientID: "client-a", jobID: resp.RestoreJobId}
+	if err := server.StreamRestoreData(restore); err != nil {
+		t.Fatalf("StreamRestoreData failed: %v", err)
+	}
+	if len(restore.responses) != 1 {
+		t.Fatalf("Expected one message, got %d", len(restore.responses))
+	}
+	msg := restore.responses[0]
+	if msg.HoleSize != 8*gib || len(msg.Data) != 0 || !msg.IsLastSegment || msg.FileEntry.GetSize() != uint64(empty.Size) {
+		t.Errorf("Unexpected restore message: %+v", msg)
+	}
+}
+
+func TestUnknownSizeFile(t *testing.T) {
+	server := NewIngestServer("0")
+	data := bytes.Repeat([]byte("INSERT INTO t VALUES (1);\n"), 1000)
+	unknown := func(data []byte, offset, hole uint64, last bool) *pb.BackupRequest {
+		return &pb.BackupRequest{RequestType: &pb.BackupRequest_FileSegment{FileSegment: &pb.FileSegment{
+			FilePath: "db.sql", Data: data, Offset: offset, HoleSize: hole, IsLastSegment: last, SizeUnknown: true,
+		}}}
+	}
+	stream := &fakeBackupStream{requests: []*pb.BackupRequest{
+		backupStart("client-a", "job-stdin", "", 1000),
+		{RequestType: &pb.BackupRequest_FileEntry{FileEntry: &pb.FileEntry{FilePath: "db.sql", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o644}}},
+		unknown(data, 0, 0, false),
+		unknown(nil, uint64(len(data)), 4096, false),
+		unknown(data, uint64(len(data))+4096, 0, true),
+		backupEnd("job-stdin", "COMPLETED"),
+	}}
+	if err := server.StreamBackup(stream); err != nil {
+		t.Fatalf("StreamBackup failed: %v", err)
+	}
+
+	manifests, err := server.manifests.List(context.Background(), "job-stdin")
+	if err != nil || len(manifests) != 1 {
+		t.Fatalf("Expected 1 manifest, got %d (%v)", len(manifests), err)
+	}
+	wantSize := int64(2*len(data)) + 4096
+	if manifests[0].Size != wantSize {
+		t.Errorf("Expected the size received, %d, got %d", wantSize, manifests[0].Size)
+	}
+	entry, err := fileEntry(&manifests[0])
+	if err != nil || entry.Size != uint64(wantSize) {
+		t.Errorf("Expected the recorded entry to have size %d, got %v (%v)", wantSize, entry, err)
+	}
+}
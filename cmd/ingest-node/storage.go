@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/placement"
+	storagepb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// newStorageCluster connects to the Data Storage Nodes listed in
+// STORAGE_NODES ("id=host:port,id=host:port"), or to the single node at
+// STORAGE_NODE_ADDR, and places them on a consistent hash ring
+func newStorageCluster() (*placement.Cluster, []*grpc.ClientConn, error) {
+	nodes := make(map[string]string) // node ID -> address
+	var order []string
+	if list := getEnv("STORAGE_NODES", ""); list != "" {
+		for _, entry := range strings.Split(list, ",") {
+			id, addr, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if !ok || id == "" || addr == "" {
+				return nil, nil, fmt.Errorf("invalid STORAGE_NODES entry %q, expected id=host:port", entry)
+			}
+			if _, dup := nodes[id]; dup {
+				return nil, nil, fmt.Errorf("duplicate storage node ID %q", id)
+			}
+			nodes[id] = addr
+			order = append(order, id)
+		}
+	} else {
+		id := getEnv("STORAGE_NODE_ID", "data-storage-node-1")
+		nodes[id] = getEnv("STORAGE_NODE_ADDR", "localhost:50052")
+		order = append(order, id)
+	}
+
+	replicas := getEnvInt("REPLICATION_FACTOR", min(3, len(nodes)))
+	if replicas > len(nodes) {
+		return nil, nil, fmt.Errorf("REPLICATION_FACTOR %d exceeds the %d configured storage nodes", replicas, len(nodes))
+	}
+	writeQuorum := getEnvInt("WRITE_QUORUM", replicas/2+1)
+	if writeQuorum < 1 || writeQuorum > replicas {
+		return nil, nil, fmt.Errorf("WRITE_QUORUM must be between 1 and %d", replicas)
+	}
+
+	cluster := placement.NewCluster(replicas, writeQuorum, getEnvInt("VIRTUAL_NODES", 128))
+	var conns []*grpc.ClientConn
+	for _, id := range order {
+		conn, err := grpc.Dial(nodes[id], grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			for _, c := range conns {
+				c.Close()
+			}
+			return nil, nil, fmt.Errorf("failed to connect to storage node %s: %w", id, err)
+		}
+		conns = append(conns, conn)
+		cluster.AddNode(id, storagepb.NewStorageServiceClient(conn))
+		log.Printf("Using Data Storage Node %s at %s", id, nodes[id])
+	}
+
+	log.Printf("Storage cluster: %d nodes, %d replicas, write quorum %d", len(nodes), replicas, writeQuorum)
+	return cluster, conns, nil
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := getEnv(key, ""); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+		log.Printf("Warning: Ignoring invalid %s=%q", key, value)
+	}
+	return defaultValue
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/sparse"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// Source-side deduplication batching
+const (
+	chunkBatchSize   = 256     // chunk fingerprints per ChunkRefBatch
+	chunkBufferBytes = 1 << 20 // file data buffered before it is chunked
+)
+
+// chunkSender sends files as chunk fingerprints and uploads only the chunks
+// the Ingest Node reports missing. It chunks with the repository's chunking
+// profile, so a file yields the same chunks on both sides.
+type chunkSender struct {
+	send    func(*pb.BackupRequest) error
+	missing <-chan *pb.MissingChunks
+	chunker *chunking.Chunker
+	verbose bool
+
+	bytesUploaded int64 // chunk data sent
+	bytesSkipped  int64 // chunk data the Ingest Node already held
+}
+
+// newChunkSender creates a sender chunking with profile; missing receives the
+// Ingest Node's answer to every batch and is closed when the response stream
+// ends
+func newChunkSender(send func(*pb.BackupRequest) error, missing <-chan *pb.MissingChunks, profile chunking.Profile, verbose bool) (*chunkSender, error) {
+	chunker, err := chunking.NewProfileChunker(profile)
+	if err != nil {
+		return nil, err
+	}
+	return &chunkSender{
+		send:    send,
+		missing: missing,
+		chunker: chunker,
+		verbose: verbose,
+	}, nil
+}
+
+// chunkingProfile returns the chunking profile of the repository an Ingest
+// Node accepted a stream for. Ingest Nodes from before chunking profiles send
+// none and chunk with the legacy profile.
+func chunkingProfile(started *pb.BackupStatus) (chunking.Profile, error) {
+	algorithm, err := chunking.ParseHashAlgorithm(started.FingerprintAlgorithm)
+	if err != nil {
+		return chunking.Profile{}, err
+	}
+	profile := chunking.LegacyProfile()
+	if m := started.ChunkingProfile; m != nil {
+		profile = chunking.Profile{
+			Version:    int(m.Version),
+			Algorithm:  m.Algorithm,
+			MinSize:    int(m.MinSize),
+			AvgSize:    int(m.AvgSize),
+			MaxSize:    int(m.MaxSize),
+			WindowSize: int(m.WindowSize),
+			Polynomial: m.Polynomial,
+		}
+	}
+	profile.Hash = algorithm
+	return profile, nil
+}
+
+// fileChunker chunks the segments of one file and sends their fingerprints
+type fileChunker struct {
+	sender *chunkSender
+	path   string
+	buffer []byte                          // data not yet chunked
+	refs   []*pb.ChunkRef                  // batch being collected
+	data   map[chunking.Fingerprint][]byte // fingerprint -> data of the chunks in refs
+}
+
+// file returns a segment sink for one file
+func (c *chunkSender) file(path string) *fileChunker {
+	return &fileChunker{sender: c, path: path, data: make(map[chunking.Fingerprint][]byte)}
+}
+
+// add consumes a segment produced by readFile
+func (f *fileChunker) add(segment *pb.FileSegment) error {
+	if segment.HoleSize > 0 {
+		if err := f.chunk(true); err != nil {
+			return err
+		}
+		f.addHole(segment.HoleSize)
+	} else {
+		f.buffer = append(f.buffer, segment.Data...)
+		if len(f.buffer) >= chunkBufferBytes {
+			if err := f.chunk(false); err != nil {
+				return err
+			}
+		}
+	}
+	if segment.IsLastSegment {
+		if err := f.chunk(true); err != nil {
+			return err
+		}
+		return f.flush(true)
+	}
+	return nil
+}
+
+// chunk splits the buffered data into chunks. Unless all is set the last
+// chunk is kept back, since more data may move its boundary.
+func (f *fileChunker) chunk(all bool) error {
+	chunks, err := f.sender.chunker.ChunkData(f.buffer)
+	if err != nil {
+		return err
+	}
+	keep := 0
+	if !all && len(chunks) > 0 {
+		keep = int(chunks[len(chunks)-1].Size)
+		chunks = chunks[:len(chunks)-1]
+	}
+
+	for _, chunk := range chunks {
+		if sparse.IsZero(chunk.Data) {
+			f.addHole(uint64(chunk.Size))
+		} else {
+			f.refs = append(f.refs, &pb.ChunkRef{Fingerprint: chunk.Fingerprint.Bytes(), Size: uint64(chunk.Size)})
+			if _, ok := f.data[chunk.Fingerprint]; !ok {
+				f.data[chunk.Fingerprint] = append([]byte(nil), chunk.Data...)
+			}
+		}
+		if len(f.refs) >= chunkBatchSize {
+			if err := f.flush(false); err != nil {
+				return err
+			}
+		}
+	}
+	f.buffer = append(f.buffer[:0], f.buffer[len(f.buffer)-keep:]...)
+	return nil
+}
+
+// addHole appends a hole, merging it with a preceding one
+func (f *fileChunker) addHole(size uint64) {
+	if n := len(f.refs); n > 0 && len(f.refs[n-1].Fingerprint) == 0 {
+		f.refs[n-1].Size += size
+		return
+	}
+	f.refs = append(f.refs, &pb.ChunkRef{Size: size})
+}
+
+// flush sends the collected fingerprints, waits for the Ingest Node's answer
+// and uploads the chunks it is missing
+func (f *fileChunker) flush(last bool) error {
+	if len(f.refs) == 0 && !last {
+		return nil
+	}
+	batch := &pb.ChunkRefBatch{FilePath: f.path, Chunks: f.refs, IsLastBatch: last}
+	if err := f.sender.send(&pb.BackupRequest{RequestType: &pb.BackupRequest_ChunkRefs{ChunkRefs: batch}}); err != nil {
+		return fmt.Errorf("failed to send chunk fingerprints: %w", err)
+	}
+
+	missing, ok := <-f.sender.missing
+	if !ok {
+		return fmt.Errorf("response stream closed while waiting for missing chunks")
+	}
+	if missing.FilePath != f.path {
+		return fmt.Errorf("missing chunks reported for %s while sending %s", missing.FilePath, f.path)
+	}
+
+	uploaded := make(map[chunking.Fingerprint]bool)
+	for _, key := range missing.Fingerprints {
+		fingerprint, err := chunking.FingerprintFromBytes(key)
+		if err != nil {
+			return fmt.Errorf("Ingest Node requested a chunk by a %w", err)
+		}
+		data, ok := f.data[fingerprint]
+		if !ok {
+			return fmt.Errorf("Ingest Node requested chunk %s, which is not in the batch", fingerprint)
+		}
+		if f.sender.verbose {
+			log.Printf("Uploading chunk: file=%s, fingerprint=%s, size=%d", f.path, fingerprint.Short(), len(data))
+		}
+		chunkMsg := &pb.BackupRequest{
+			RequestType: &pb.BackupRequest_ChunkData{
+				ChunkData: &pb.ChunkData{FilePath: f.path, Fingerprint: key, Data: data},
+			},
+		}
+		if err := f.sender.send(chunkMsg); err != nil {
+			return fmt.Errorf("failed to send chunk data: %w", err)
+		}
+		uploaded[fingerprint] = true
+		f.sender.bytesUploaded += int64(len(data))
+	}
+	for _, ref := range f.refs {
+		fingerprint, err := chunking.FingerprintFromBytes(ref.Fingerprint)
+		if err != nil {
+			continue // a hole
+		}
+		if !uploaded[fingerprint] {
+			f.sender.bytesSkipped += int64(ref.Size)
+		}
+		delete(uploaded, fingerprint) // repeats in the batch were not sent again
+	}
+
+	f.refs = nil
+	f.data = make(map[chunking.Fingerprint][]byte)
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// previousSnapshot holds the regular files of the last successful backup of
+// the same client and source. Files whose size, modification time, inode and
+// status change time all match are sent as unchanged instead of being read.
+type previousSnapshot struct {
+	jobID string
+	files map[string]*pb.FileEntry
+
+	mutex          sync.Mutex // guards the counters while several streams send
+	unchangedFiles int
+	unchangedBytes int64
+}
+
+// fetchPreviousSnapshot asks the Ingest Node for the previous backup of the
+// client and source in start. It returns nil if there is none.
+func fetchPreviousSnapshot(ctx context.Context, client pb.BackupServiceClient, start *pb.BackupStart) (*previousSnapshot, error) {
+	stream, err := client.GetPreviousSnapshot(ctx, &pb.PreviousSnapshotRequest{
+		ClientId:      start.ClientId,
+		SourceType:    start.SourceType,
+		SourceDetails: start.SourceDetails,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &previousSnapshot{files: make(map[string]*pb.FileEntry)}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if snapshot.jobID == "" {
+			snapshot.jobID = resp.BackupJobId
+		} else if resp.BackupJobId != snapshot.jobID {
+			return nil, fmt.Errorf("previous snapshot changed from %s to %s while listing", snapshot.jobID, resp.BackupJobId)
+		}
+		for _, entry := range resp.Files {
+			snapshot.files[entry.FilePath] = entry
+		}
+	}
+	if snapshot.jobID == "" {
+		return nil, nil
+	}
+	return snapshot, nil
+}
+
+// unchanged reports whether a regular file is unchanged since the previous
+// backup. Entries without an inode or status change time, as recorded by
+// older stream handlers, never match.
+func (p *previousSnapshot) unchanged(entry *pb.FileEntry) bool {
+	if p == nil || entry.Type != pb.FileType_FILE_TYPE_REGULAR {
+		return false
+	}
+	previous := p.files[entry.FilePath]
+	return previous != nil &&
+		previous.Inode != 0 && previous.CtimeNs != 0 &&
+		previous.Size == entry.Size &&
+		previous.MtimeNs == entry.MtimeNs &&
+		previous.Inode == entry.Inode &&
+		previous.CtimeNs == entry.CtimeNs
+}
+
+// sendUnchanged sends an entry as unchanged since the previous backup
+func (p *previousSnapshot) sendUnchanged(stream pb.BackupService_StreamBackupClient, entry *pb.FileEntry) error {
+	msg := &pb.BackupRequest{
+		RequestType: &pb.BackupRequest_UnchangedFile{UnchangedFile: &pb.UnchangedFile{Entry: entry}},
+	}
+	if err := stream.Send(msg); err != nil {
+		return fmt.Errorf("failed to send unchanged file: %w", err)
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.unchangedFiles++
+	p.unchangedBytes += int64(entry.Size)
+	return nil
+}
@@ -1,45 +1,144 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/fsmeta"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/fswalk"
 	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
 )
 
+// segmentSize is the amount of file data sent per FileSegment
+const segmentSize = 64 * 1024
+
+// patternList is a repeatable flag collecting patterns
+type patternList []string
+
+func (p *patternList) String() string { return strings.Join(*p, ",") }
+
+func (p *patternList) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "restore" {
+		runRestore(os.Args[2:])
+		return
+	}
+
 	// Parse command line flags
 	ingestAddr := flag.String("ingest-addr", "localhost:50051", "Address of the Ingest Node")
-	filePath := flag.String("file", "", "Path to the file to backup")
+	filePath := flag.String("file", "", "Path to a file or directory to backup (paths may also be given as arguments)")
 	clientID := flag.String("client-id", "test-client", "Client ID for the backup")
+	var includes, excludes patternList
+	flag.Var(&includes, "include", "Only back up files matching this gitignore-style pattern (repeatable)")
+	flag.Var(&excludes, "exclude", "Skip files and directories matching this gitignore-style pattern (repeatable)")
+	excludeFrom := flag.String("exclude-from", "", "Read exclude patterns from a file, one per line")
+	oneFileSystem := flag.Bool("one-file-system", false, "Do not cross file system boundaries")
+	maxFileSize := flag.Int64("max-file-size", 0, "Skip files larger than this many bytes (0 for no limit)")
+	timeout := flag.Duration("timeout", 30*time.Minute, "Timeout for the whole backup")
+	resumeJobID := flag.String("resume", "", "Resume the interrupted backup job with this ID from its last checkpoint")
+	incremental := flag.Bool("incremental", false, "Send files unchanged since the previous backup of this client and source as references")
+	sourceDedupe := flag.Bool("source-dedupe", false, "Chunk files locally and send only chunks the Ingest Node does not have")
+	archives := flag.Bool("archives", false, "Have the Ingest Node chunk the members of tar and zip files apart from their headers, so they share chunks with unarchived copies")
+	streams := flag.Int("streams", 1, "Number of parallel streams uploading the backup")
+	rangeSize := flag.Int64("range-size", 256*1024*1024, "With several streams, split files larger than this many bytes into ranges uploaded in parallel")
+	fromStdin := flag.Bool("stdin", false, "Back up standard input as a single file, e.g. the output of pg_dump")
+	pipePath := flag.String("pipe", "", "Back up what is written to this named pipe as a single file")
+	name := flag.String("name", "", "Path to record the contents of -stdin or -pipe under (default stdin, or the pipe's path)")
+	verbose := flag.Bool("verbose", false, "Log every segment sent")
 	flag.Parse()
 
-	if *filePath == "" {
-		log.Fatal("Please specify a file path with -file")
+	roots := flag.Args()
+	if *filePath != "" {
+		roots = append([]string{*filePath}, roots...)
+	}
+	streamInput := *fromStdin || *pipePath != ""
+	if streamInput {
+		// Sources of unknown size are read once, from start to end
+		if *fromStdin && *pipePath != "" {
+			log.Fatal("-stdin cannot be combined with -pipe")
+		}
+		if len(roots) > 0 || *resumeJobID != "" || *incremental || *streams > 1 {
+			log.Fatal("-stdin and -pipe cannot be combined with paths, -resume, -incremental or -streams")
+		}
+		if *name == "" {
+			*name = "stdin"
+			if *pipePath != "" {
+				*name = *pipePath
+			}
+		}
+	} else if len(roots) == 0 {
+		log.Fatal("Please specify paths to backup as arguments, with -file, or use -stdin")
+	}
+	if *streams < 1 || *rangeSize < 1 {
+		log.Fatal("-streams and -range-size must be positive")
+	}
+	if *archives && *sourceDedupe {
+		// Files are chunked by the stream handler then
+		log.Fatal("-archives cannot be combined with -source-dedupe")
+	}
+	if *resumeJobID != "" && *streams > 1 {
+		// Parallel streams record entries out of walk order, so the Ingest
+		// Node does not checkpoint them
+		log.Fatal("-resume cannot be combined with -streams")
 	}
 
-	// Open the file
-	file, err := os.Open(*filePath)
-	if err != nil {
-		log.Fatalf("Failed to open file %s: %v", *filePath, err)
+	if *excludeFrom != "" {
+		patterns, err := readPatterns(*excludeFrom)
+		if err != nil {
+			log.Fatalf("Failed to read exclude patterns: %v", err)
+		}
+		excludes = append(excludes, patterns...)
 	}
-	defer file.Close()
 
-	// Get file info
-	fileInfo, err := file.Stat()
+	walker, err := fswalk.NewWalker(fswalk.Options{
+		Include:       includes,
+		Exclude:       excludes,
+		OneFileSystem: *oneFileSystem,
+		MaxFileSize:   *maxFileSize,
+		AllTypes:      true,
+	})
 	if err != nil {
-		log.Fatalf("Failed to get file info: %v", err)
+		log.Fatalf("Invalid pattern: %v", err)
 	}
 
-	log.Printf("Starting backup of file: %s (size: %d bytes)", *filePath, fileInfo.Size())
+	var input io.Reader
+	sourceType := "filesystem"
+	sourceDetails, _ := json.Marshal(map[string]interface{}{"paths": roots, "include": includes, "exclude": excludes})
+	switch {
+	case *fromStdin:
+		input = os.Stdin
+	case *pipePath != "":
+		// Opening a named pipe waits for its writer
+		pipe, err := os.Open(*pipePath)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", *pipePath, err)
+		}
+		defer pipe.Close()
+		input = pipe
+	}
+	if streamInput {
+		sourceType = "stream"
+		sourceDetails, _ = json.Marshal(map[string]interface{}{"name": *name})
+		log.Printf("Starting backup of: %s", *name)
+	} else {
+		log.Printf("Starting backup of: %s", strings.Join(roots, ", "))
+	}
 
 	// Connect to Ingest Node
 	conn, err := grpc.Dial(*ingestAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
@@ -51,132 +150,319 @@ func main() {
 	client := pb.NewBackupServiceClient(conn)
 
 	// Create backup stream
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
 	defer cancel()
 
-	stream, err := client.StreamBackup(ctx)
-	if err != nil {
-		log.Fatalf("Failed to create backup stream: %v", err)
+	backupJobID := fmt.Sprintf("backup-%d", time.Now().Unix())
+	if *resumeJobID != "" {
+		backupJobID = *resumeJobID
+	}
+	start := &pb.BackupStart{
+		ClientId:        *clientID,
+		BackupJobId:     backupJobID,
+		BackupPolicyId:  "default-policy",
+		EncryptionKeyId: "",
+		Timestamp:       time.Now().Unix(),
+		SourceType:      sourceType,
+		SourceDetails:   string(sourceDetails),
+		Resume:          *resumeJobID != "",
+		Archives:        *archives,
 	}
 
-	// Send backup start message
-	backupJobID := fmt.Sprintf("backup-%d", time.Now().Unix())
-	startMsg := &pb.BackupRequest{
-		RequestType: &pb.BackupRequest_StartBackup{
-			StartBackup: &pb.BackupStart{
-				ClientId:        *clientID,
-				BackupJobId:     backupJobID,
-				BackupPolicyId:  "default-policy",
-				EncryptionKeyId: "",
-				Timestamp:       time.Now().Unix(),
-				SourceType:      "filesystem",
-				SourceDetails:   fmt.Sprintf(`{"path": "%s"}`, *filePath),
-			},
-		},
+	// An incremental backup builds on the previous backup of the same
+	// client and source, or is a full backup if there is none
+	var previous *previousSnapshot
+	if *incremental {
+		if previous, err = fetchPreviousSnapshot(ctx, client, start); err != nil {
+			log.Fatalf("Failed to fetch previous backup: %v", err)
+		}
+		if previous == nil {
+			log.Printf("No previous backup of this source, running a full backup")
+		} else {
+			start.BaseJobId = previous.jobID
+			log.Printf("Incremental backup on %s (%d files)", previous.jobID, len(previous.files))
+		}
 	}
 
-	if err := stream.Send(startMsg); err != nil {
-		log.Fatalf("Failed to send backup start message: %v", err)
+	primary, point, err := openStream(ctx, client, start, *sourceDedupe, *verbose)
+	if err != nil {
+		log.Fatalf("Failed to start backup job %s: %v", backupJobID, err)
 	}
 
 	log.Printf("Started backup job: %s", backupJobID)
 
-	// Read file in chunks and send
-	buffer := make([]byte, 64*1024) // 64KB chunks
-	offset := int64(0)
-	segmentCount := 0
-
-	for {
-		n, err := file.Read(buffer)
-		log.Printf("Read %d bytes, err: %v", n, err)
-		if n == 0 {
-			break
-		}
-
-		chunkData := buffer[:n]
-		// Check if this will be the last segment
-		isLastSegment := err == io.EOF || (offset+int64(n)) >= fileInfo.Size()
-		log.Printf("Sending segment %d: size=%d, offset=%d, isLast=%v", segmentCount+1, len(chunkData), offset, isLastSegment)
-
-		segmentMsg := &pb.BackupRequest{
-			RequestType: &pb.BackupRequest_FileSegment{
-				FileSegment: &pb.FileSegment{
-					FilePath:      *filePath,
-					FileSize:      uint64(fileInfo.Size()),
-					Data:          chunkData,
-					Offset:        uint64(offset),
-					IsLastSegment: isLastSegment,
-				},
-			},
+	// A resumed job skips what the Ingest Node recorded before the
+	// interruption
+	var resume *resumePoint
+	if start.Resume {
+		if point == nil {
+			log.Fatalf("Failed to resume backup job %s: no resume point received", backupJobID)
 		}
+		resume = newResumePoint(roots, point)
+		log.Printf("Resuming after %q, partial file %q at offset %d", point.LastFile, point.PartialFile, point.PartialOffset)
+	}
 
-		if err := stream.Send(segmentMsg); err != nil {
-			log.Fatalf("Failed to send file segment: %v", err)
+	// Further streams join the job started on the first
+	uploads := []*uploadStream{primary}
+	for len(uploads) < *streams {
+		join := &pb.BackupStart{ClientId: *clientID, BackupJobId: backupJobID, Join: true}
+		upload, _, err := openStream(ctx, client, join, *sourceDedupe, *verbose)
+		if err != nil {
+			log.Fatalf("Failed to join backup job %s: %v", backupJobID, err)
 		}
+		uploads = append(uploads, upload)
+	}
+	if len(uploads) > 1 {
+		log.Printf("Uploading over %d streams, files over %d bytes in ranges", len(uploads), *rangeSize)
+	}
 
-		offset += int64(n)
-		segmentCount++
-
-		if segmentCount%100 == 0 {
-			log.Printf("Sent %d segments, offset: %d", segmentCount, offset)
+	// Walk the roots and send every entry with its metadata, or send the
+	// input as a single file
+	report := &fswalk.Report{}
+	if input != nil {
+		err = sendInput(primary, input, *name, report, *verbose)
+	} else {
+		session := &backupSession{
+			streams:   uploads,
+			links:     fsmeta.NewHardLinks(),
+			previous:  previous,
+			resume:    resume,
+			report:    report,
+			rangeSize: *rangeSize,
+			verbose:   *verbose,
 		}
-
-		// If we've read the entire file, break
-		if offset >= fileInfo.Size() {
-			break
+		if len(uploads) > 1 {
+			session.startWorkers()
 		}
-
-		if err == io.EOF {
-			break
+		err = walker.Walk(roots, report, session.sendEntry)
+		if waitErr := session.wait(); err == nil {
+			err = waitErr
 		}
-		if err != nil {
-			log.Fatalf("Error reading file: %v", err)
+	}
+	if err != nil {
+		log.Fatalf("Backup aborted: %v", err)
+	}
+
+	// The job may only end once the other streams are closed
+	for _, upload := range uploads[1:] {
+		if err := upload.close(); err != nil {
+			log.Fatalf("Failed to receive response: %v", err)
 		}
 	}
 
 	// Send backup end message
+	jobState := pb.JobState_JOB_STATE_COMPLETED
+	if len(report.Errors) > 0 {
+		jobState = pb.JobState_JOB_STATE_PARTIAL
+	}
 	endMsg := &pb.BackupRequest{
 		RequestType: &pb.BackupRequest_EndBackup{
 			EndBackup: &pb.BackupEnd{
 				BackupJobId: backupJobID,
-				Status:      "COMPLETED",
-				Summary:     fmt.Sprintf("Backed up %s in %d segments", *filePath, segmentCount),
+				State:       jobState,
+				Summary: fmt.Sprintf("Backed up %d files (%d bytes) and %d other entries; %d excluded, %d skipped, %d errors",
+					report.Files, report.Bytes, report.Others, report.Excluded, len(report.Skipped), len(report.Errors)),
 			},
 		},
 	}
 
-	if err := stream.Send(endMsg); err != nil {
+	if err := primary.stream.Send(endMsg); err != nil {
 		log.Fatalf("Failed to send backup end message: %v", err)
 	}
 
-	// Close the send stream
-	if err := stream.CloseSend(); err != nil {
-		log.Fatalf("Failed to close send stream: %v", err)
+	log.Printf("Finished sending file data, waiting for responses...")
+	if err := primary.close(); err != nil {
+		log.Fatalf("Failed to receive response: %v", err)
 	}
 
-	log.Printf("Finished sending file data, waiting for responses...")
+	for _, skipped := range report.Skipped {
+		log.Printf("Skipped %s", skipped)
+	}
+	for _, failed := range report.Errors {
+		log.Printf("Error: %s", failed)
+	}
+	log.Printf("Backed up %d files (%d bytes) and %d other entries; %d excluded, %d skipped, %d errors",
+		report.Files, report.Bytes, report.Others, report.Excluded, len(report.Skipped), len(report.Errors))
+	if resume != nil {
+		log.Printf("Resumed: %d entries recorded before the interruption were skipped", resume.skipped)
+	}
+	if previous != nil {
+		log.Printf("Incremental: %d files (%d bytes) unchanged since %s were not read",
+			previous.unchangedFiles, previous.unchangedBytes, previous.jobID)
+	}
+	if *sourceDedupe {
+		var uploaded, skipped int64
+		for _, upload := range uploads {
+			uploaded += upload.chunks.bytesUploaded
+			skipped += upload.chunks.bytesSkipped
+		}
+		log.Printf("Source dedupe: uploaded %d bytes of chunk data, skipped %d bytes already stored", uploaded, skipped)
+	}
+
+	if len(report.Errors) > 0 {
+		log.Printf("Backup completed with errors")
+		os.Exit(1)
+	}
+	log.Printf("Backup completed successfully!")
+}
+
+// backupSession sends the entries of a walk over the streams of a job. With
+// one stream entries are sent in walk order as they are visited; with
+// several, workers send them in parallel.
+type backupSession struct {
+	streams   []*uploadStream
+	links     *fsmeta.HardLinks
+	previous  *previousSnapshot // set for incremental backups
+	resume    *resumePoint      // set when resuming an interrupted job
+	report    *fswalk.Report
+	rangeSize int64 // files larger than this are sent in ranges by the workers
+	verbose   bool
+
+	queue   chan *upload // entries for the workers, nil with one stream
+	workers sync.WaitGroup
+	mutex   sync.Mutex
+	failed  error // first error of a worker
+}
+
+// sendEntry sends the metadata of an entry, followed by the contents of a
+// regular file. Files unchanged since the previous backup are sent as
+// references to it without their contents, and entries recorded before an
+// interrupted job was resumed are skipped. Entries that cannot be opened or
+// whose metadata cannot be read are recorded in the report and skipped.
+func (s *backupSession) sendEntry(file fswalk.File) error {
+	if s.resume.done(file) {
+		s.resume.skipped++
+		return nil
+	}
+
+	// Open regular files first, so an unreadable file is never announced
+	// or recorded as the target of later hard links
+	var f *os.File
+	if file.Info.Mode().IsRegular() {
+		var err error
+		if f, err = os.Open(file.Path); err != nil {
+			s.report.Fail(file.Path, err)
+			return nil
+		}
+	}
+	closeFile := func() {
+		if f != nil {
+			f.Close()
+		}
+	}
+
+	entry, err := fsmeta.Capture(file.Path, file.Info, s.links)
+	if err != nil {
+		closeFile()
+		s.report.Fail(file.Path, err)
+		return nil
+	}
+	item := &upload{file: file, entry: entry, f: f, done: closeFile}
+	if s.previous.unchanged(entry) {
+		item.unchanged = true
+	} else if offset, ok := s.resume.offset(entry); ok {
+		item.start, item.resumed = offset, true
+	}
+	if s.queue != nil {
+		return s.dispatch(item)
+	}
+	defer item.done()
+	return s.send(s.streams[0], item)
+}
 
-	// Receive responses from Ingest Node
+// send sends an entry or range over one stream
+func (s *backupSession) send(u *uploadStream, item *upload) error {
+	entry := item.entry
+	switch {
+	case item.unchanged:
+		if s.verbose {
+			log.Printf("Unchanged: %s", item.file.Path)
+		}
+		return s.previous.sendUnchanged(u.stream, entry)
+	case item.resumed:
+		log.Printf("Resuming %s at offset %d", item.file.Path, item.start)
+		return s.sendContents(u, item)
+	case item.part != nil:
+		rangeMsg := &pb.BackupRequest{
+			RequestType: &pb.BackupRequest_FileRange{FileRange: item.part},
+		}
+		if err := u.stream.Send(rangeMsg); err != nil {
+			return fmt.Errorf("failed to send file range: %w", err)
+		}
+		return s.sendContents(u, item)
+	}
+
+	if s.verbose {
+		log.Printf("Sending entry: %s (%s, mode %o)", item.file.Path, entry.Type, entry.Mode)
+	}
+	entryMsg := &pb.BackupRequest{
+		RequestType: &pb.BackupRequest_FileEntry{FileEntry: entry},
+	}
+	if err := u.stream.Send(entryMsg); err != nil {
+		return fmt.Errorf("failed to send file entry: %w", err)
+	}
+	if entry.Type != pb.FileType_FILE_TYPE_REGULAR {
+		return nil
+	}
+	return s.sendContents(u, item)
+}
+
+// sendContents sends a regular file or one range of it, as fingerprints and
+// missing chunks when source-side deduplication is on
+func (s *backupSession) sendContents(u *uploadStream, item *upload) error {
+	send := segmentSender(u.stream, item.file, s.verbose)
+	if u.chunks != nil {
+		send = u.chunks.file(item.file.Path).add
+	}
+	if item.part != nil {
+		return readRange(item.f, item.file, item.part, s.report, send)
+	}
+	return readFile(item.f, item.file, item.start, s.report, send)
+}
+
+// receiveResponses logs responses from the Ingest Node until the stream
+// ends, passing answers to chunk fingerprint batches on to missing
+func receiveResponses(stream pb.BackupService_StreamBackupClient, missing chan<- *pb.MissingChunks) error {
+	defer close(missing)
 	for {
 		response, err := stream.Recv()
 		if err == io.EOF {
-			break
+			return nil
 		}
 		if err != nil {
-			log.Fatalf("Failed to receive response: %v", err)
+			return err
 		}
 
 		switch response.ResponseType.(type) {
 		case *pb.BackupResponse_StatusUpdate:
-			status := response.GetStatusUpdate()
-			log.Printf("Status: %s - %s (processed: %d, deduplicated: %d bytes)",
-				status.BackupJobId, status.Message, status.BytesProcessed, status.BytesDeduplicated)
+			logStatus(response.GetStatusUpdate())
+
+		case *pb.BackupResponse_MissingChunks:
+			missing <- response.GetMissingChunks()
+
+		case *pb.BackupResponse_ChunksStored:
+			stored := response.GetChunksStored()
+			log.Printf("Stored %d chunks of %s (%d new)", len(stored.Fingerprints), stored.FilePath, stored.NewChunks)
 
 		case *pb.BackupResponse_ErrorMessage:
 			error := response.GetErrorMessage()
 			log.Printf("Error: %s - %s", error.ErrorCode, error.ErrorMessage)
 		}
 	}
+}
+
+// readPatterns reads gitignore-style patterns from a file
+func readPatterns(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
 
-	log.Printf("Backup completed successfully!")
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+	return patterns, scanner.Err()
 }
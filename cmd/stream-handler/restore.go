@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/fsmeta"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// existingPolicies maps -existing values to restore policies
+var existingPolicies = map[string]fsmeta.ExistingPolicy{
+	"overwrite": fsmeta.Overwrite,
+	"skip":      fsmeta.SkipExisting,
+	"rename":    fsmeta.RenameNew,
+}
+
+// runRestore implements the restore subcommand: it restores the entries of
+// a backup job under the paths given as arguments, or all of them
+func runRestore(args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	ingestAddr := flags.String("ingest-addr", "localhost:50051", "Address of the Ingest Node")
+	clientID := flags.String("client-id", "test-client", "Client ID for the restore")
+	jobID := flags.String("job", "", "Backup job to restore from")
+	dest := flags.String("dest", ".", "Directory to restore into; entries keep their original paths below it")
+	existing := flags.String("existing", "overwrite", "What to do with entries that already exist: overwrite, skip or rename")
+	toStdout := flags.Bool("stdout", false, "Write the contents of a single restored file to standard output")
+	streams := flags.Int("streams", 1, "Number of parallel streams receiving the restore")
+	timeout := flags.Duration("timeout", 30*time.Minute, "Timeout for the whole restore")
+	verbose := flags.Bool("verbose", false, "Log every entry restored")
+	flags.Parse(args)
+
+	if *jobID == "" {
+		log.Fatal("Please specify the backup job to restore with -job")
+	}
+	policy, ok := existingPolicies[*existing]
+	if !ok {
+		log.Fatalf("Unknown -existing policy %q, expected overwrite, skip or rename", *existing)
+	}
+	if *streams < 1 {
+		log.Fatal("-streams must be positive")
+	}
+	if *toStdout && *streams > 1 {
+		log.Fatal("-stdout cannot be combined with -streams")
+	}
+
+	conn, err := grpc.Dial(*ingestAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("Failed to connect to Ingest Node: %v", err)
+	}
+	defer conn.Close()
+	client := pb.NewBackupServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	resp, err := client.InitiateRestore(ctx, &pb.RestoreRequest{
+		ClientId:               *clientID,
+		BackupJobId:            *jobID,
+		FilesToRestore:         flags.Args(),
+		RestoreDestinationPath: *dest,
+		Streams:                uint32(*streams),
+	})
+	if err != nil {
+		log.Fatalf("Failed to initiate restore: %v", err)
+	}
+	log.Printf("Restore job %s: %s", resp.RestoreJobId, resp.Message)
+	algorithm, err := chunking.ParseHashAlgorithm(resp.FingerprintAlgorithm)
+	if err != nil {
+		log.Fatalf("Cannot verify restored files: %v", err)
+	}
+
+	if *toStdout {
+		stream, err := openRestoreStream(ctx, client, *clientID, resp.RestoreJobId, 0)
+		if err == nil {
+			err = restoreToWriter(stream, os.Stdout, algorithm)
+		}
+		if err != nil {
+			log.Fatalf("Restore failed: %v", err)
+		}
+		return
+	}
+
+	session := &restoreSession{restorer: fsmeta.NewRestorerWithPolicy(*dest, policy), algorithm: algorithm, verbose: *verbose}
+	var wg sync.WaitGroup
+	errs := make([]error, *streams)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stream, err := openRestoreStream(ctx, client, *clientID, resp.RestoreJobId, i)
+			if err == nil {
+				err = session.receive(stream)
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		log.Fatalf("Restore failed: %v", err)
+	}
+	session.finish()
+
+	for _, failed := range session.failed {
+		log.Printf("Error: %s", failed)
+	}
+	log.Printf("Restored %d files (%d bytes) and %d other entries to %s; %d skipped, %d errors",
+		session.files, session.bytes, session.others, *dest, session.skipped, len(session.failed))
+	if len(session.failed) > 0 {
+		log.Printf("Restore completed with errors")
+		os.Exit(1)
+	}
+	log.Printf("Restore completed successfully!")
+}
+
+// openRestoreStream opens one of the data streams of a restore job the
+// client initiated
+func openRestoreStream(ctx context.Context, client pb.BackupServiceClient, clientID, restoreJobID string, index int) (pb.BackupService_StreamRestoreDataClient, error) {
+	stream, err := client.StreamRestoreData(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create restore stream: %w", err)
+	}
+	if err := stream.Send(&pb.RestoreDataRequest{RestoreJobId: restoreJobID, StreamIndex: uint32(index), ClientId: clientID}); err != nil {
+		return nil, fmt.Errorf("failed to send restore request: %w", err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, fmt.Errorf("failed to close send stream: %w", err)
+	}
+	return stream, nil
+}
+
+// restoreSession writes the entries received over the streams of a restore
+// job. Entries that cannot be written or fail verification are recorded
+// and skipped; only stream errors abort.
+type restoreSession struct {
+	restorer  *fsmeta.Restorer
+	algorithm chunking.HashAlgorithm // of the repository's chunk fingerprints
+	verbose   bool
+
+	mutex   sync.Mutex
+	links   []*pb.FileEntry // hard links, created once every target is restored
+	files   int
+	others  int
+	skipped int
+	bytes   int64
+	failed  []string
+}
+
+// restoredFile is an entry whose messages are being received
+type restoredFile struct {
+	entry     *pb.FileEntry
+	file      *os.File // set while a regular file is written
+	hash      *chunking.FileHash
+	algorithm chunking.HashAlgorithm
+	skipped   bool
+	err       error
+}
+
+// receive writes the entries of one restore data stream
+func (s *restoreSession) receive(stream pb.BackupService_StreamRestoreDataClient) error {
+	var current *restoredFile
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			if current != nil {
+				return fmt.Errorf("stream ended inside %s", current.entry.FilePath)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.FileEntry != nil {
+			if current != nil {
+				return fmt.Errorf("%s started before %s ended", msg.FilePath, current.entry.FilePath)
+			}
+			current = s.begin(msg.FileEntry)
+		} else if current == nil || msg.FilePath != current.entry.FilePath {
+			return fmt.Errorf("data for %s received without its entry", msg.FilePath)
+		}
+		current.add(msg)
+		if msg.IsLastSegment {
+			s.end(current)
+			current = nil
+		}
+	}
+}
+
+// begin starts restoring an entry
+func (s *restoreSession) begin(entry *pb.FileEntry) *restoredFile {
+	restored := &restoredFile{entry: entry, algorithm: s.algorithm}
+	if entry.Type == pb.FileType_FILE_TYPE_HARDLINK {
+		// The target may be restored by another stream
+		s.mutex.Lock()
+		s.links = append(s.links, entry)
+		s.mutex.Unlock()
+		restored.skipped = true
+		return restored
+	}
+	file, err := s.restorer.Begin(entry)
+	switch {
+	case errors.Is(err, fsmeta.ErrSkipped):
+		s.skip(entry)
+		restored.skipped = true
+	case err != nil:
+		restored.err = err
+	case file != nil:
+		restored.file = file
+		restored.hash = chunking.NewFileHash()
+	}
+	return restored
+}
+
+// add writes a message's data, seeking over holes so they stay sparse
+func (f *restoredFile) add(msg *pb.RestoreDataResponse) {
+	if f.file == nil || f.err != nil {
+		return
+	}
+	if msg.HoleSize > 0 {
+		f.hash.AddHole(msg.HoleSize)
+		return
+	}
+	if len(msg.Data) == 0 {
+		return
+	}
+	if _, err := f.file.WriteAt(msg.Data, int64(msg.Offset)); err != nil {
+		f.err = err
+		return
+	}
+	f.hash.AddChunk(f.algorithm.Sum(msg.Data).String())
+}
+
+// end finishes an entry, verifying the contents of a regular file against
+// its recorded hash before it replaces anything at the target
+func (s *restoreSession) end(f *restoredFile) {
+	if f.skipped {
+		return
+	}
+	if f.file != nil {
+		if f.err == nil && f.entry.ContentHash != "" && f.hash.Sum() != f.entry.ContentHash {
+			f.err = fmt.Errorf("contents do not match the recorded hash")
+		}
+		if f.err != nil {
+			s.restorer.Abort(f.file)
+		} else {
+			f.err = s.restorer.End(f.entry, f.file)
+		}
+	}
+	s.record(f.entry, f.err)
+}
+
+// finish creates the hard links and applies directory metadata once every
+// stream has ended
+func (s *restoreSession) finish() {
+	for _, link := range s.links {
+		_, err := s.restorer.Begin(link)
+		if errors.Is(err, fsmeta.ErrSkipped) {
+			s.skip(link)
+			continue
+		}
+		s.record(link, err)
+	}
+	if err := s.restorer.Close(); err != nil {
+		s.failed = append(s.failed, fmt.Sprintf("failed to apply directory metadata: %v", err))
+	}
+}
+
+func (s *restoreSession) skip(entry *pb.FileEntry) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.skipped++
+	if s.verbose {
+		log.Printf("Skipped existing %s", entry.FilePath)
+	}
+}
+
+func (s *restoreSession) record(entry *pb.FileEntry, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err != nil {
+		s.failed = append(s.failed, fmt.Sprintf("%s: %v", entry.FilePath, err))
+		return
+	}
+	if entry.Type == pb.FileType_FILE_TYPE_REGULAR {
+		s.files++
+		s.bytes += int64(entry.Size)
+	} else {
+		s.others++
+	}
+	if s.verbose {
+		log.Printf("Restored %s (%s)", entry.FilePath, entry.Type)
+	}
+}
+
+// restoreToWriter writes the contents of the single regular file of a
+// restore stream to w, with holes as zeros. The contents are verified once
+// written, so a mismatch is reported after the data has gone out. Chunk
+// fingerprints are recomputed with algorithm.
+func restoreToWriter(stream pb.BackupService_StreamRestoreDataClient, w io.Writer, algorithm chunking.HashAlgorithm) error {
+	var entry *pb.FileEntry
+	hash := chunking.NewFileHash()
+	var written uint64
+	zeros := make([]byte, segmentSize)
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if msg.FileEntry != nil {
+			if entry != nil {
+				return fmt.Errorf("more than one entry selected; restore a single file to standard output")
+			}
+			if msg.FileEntry.Type != pb.FileType_FILE_TYPE_REGULAR {
+				return fmt.Errorf("%s is a %s, not a regular file", msg.FilePath, msg.FileEntry.Type)
+			}
+			entry = msg.FileEntry
+		}
+		if entry == nil || msg.Offset != written {
+			return fmt.Errorf("unexpected data for %s at offset %d", msg.FilePath, msg.Offset)
+		}
+
+		if msg.HoleSize > 0 {
+			hash.AddHole(msg.HoleSize)
+			for remaining := msg.HoleSize; remaining > 0; {
+				n := min(remaining, uint64(len(zeros)))
+				if _, err := w.Write(zeros[:n]); err != nil {
+					return err
+				}
+				remaining -= n
+			}
+			written += msg.HoleSize
+		} else if len(msg.Data) > 0 {
+			hash.AddChunk(algorithm.Sum(msg.Data).String())
+			if _, err := w.Write(msg.Data); err != nil {
+				return err
+			}
+			written += uint64(len(msg.Data))
+		}
+	}
+
+	if entry == nil {
+		return fmt.Errorf("no file received")
+	}
+	if written != entry.Size {
+		return fmt.Errorf("%s: received %d of %d bytes", entry.FilePath, written, entry.Size)
+	}
+	if entry.ContentHash != "" && hash.Sum() != entry.ContentHash {
+		return fmt.Errorf("%s: contents do not match the recorded hash", entry.FilePath)
+	}
+	return nil
+}
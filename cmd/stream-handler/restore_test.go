@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/fsmeta"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// fakeRestoreClient replays restore data messages
+type fakeRestoreClient struct {
+	grpc.ClientStream
+	messages []*pb.RestoreDataResponse
+}
+
+func (f *fakeRestoreClient) Send(*pb.RestoreDataRequest) error { return nil }
+
+func (f *fakeRestoreClient) Recv() (*pb.RestoreDataResponse, error) {
+	if len(f.messages) == 0 {
+		return nil, io.EOF
+	}
+	msg := f.messages[0]
+	f.messages = f.messages[1:]
+	return msg, nil
+}
+
+// restoreMessages returns the messages restoring a regular file made of
+// data chunks and holes, with its content hash recorded
+func restoreMessages(path string, parts ...interface{}) []*pb.RestoreDataResponse {
+	entry := &pb.FileEntry{FilePath: path, Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o644}
+	hash := chunking.NewFileHash()
+	var messages []*pb.RestoreDataResponse
+	for i, part := range parts {
+		msg := &pb.RestoreDataResponse{FilePath: path, Offset: entry.Size, IsLastSegment: i == len(parts)-1}
+		switch part := part.(type) {
+		case string:
+			msg.Data = []byte(part)
+			hash.AddChunk(chunking.ComputeFingerprint(msg.Data).String())
+		case int:
+			msg.HoleSize = uint64(part)
+			hash.AddHole(msg.HoleSize)
+		}
+		entry.Size += uint64(len(msg.Data)) + msg.HoleSize
+		messages = append(messages, msg)
+	}
+	entry.ContentHash = hash.Sum()
+	messages[0].FileEntry = entry
+	return messages
+}
+
+func TestRestoreSession(t *testing.T) {
+	dest := t.TempDir()
+	session := &restoreSession{restorer: fsmeta.NewRestorer(dest)}
+
+	var messages []*pb.RestoreDataResponse
+	messages = append(messages, &pb.RestoreDataResponse{FilePath: "/data", IsLastSegment: true,
+		FileEntry: &pb.FileEntry{FilePath: "/data", Type: pb.FileType_FILE_TYPE_DIRECTORY, Mode: 0o755}})
+	// The hard link arrives before its target, as it may over parallel streams
+	messages = append(messages, &pb.RestoreDataResponse{FilePath: "/data/link", IsLastSegment: true,
+		FileEntry: &pb.FileEntry{FilePath: "/data/link", Type: pb.FileType_FILE_TYPE_HARDLINK, LinkTarget: "/data/file"}})
+	messages = append(messages, restoreMessages("/data/file", "hello", 4096, "world")...)
+	corrupt := restoreMessages("/data/corrupt", "good data")
+	corrupt[0].Data = []byte("evil data")
+	messages = append(messages, corrupt...)
+	os.MkdirAll(filepath.Join(dest, "data"), 0o755)
+	os.WriteFile(filepath.Join(dest, "data/corrupt"), []byte("previous"), 0o644)
+
+	if err := session.receive(&fakeRestoreClient{messages: messages}); err != nil {
+		t.Fatalf("receive failed: %v", err)
+	}
+	session.finish()
+
+	if session.files != 1 || session.others != 2 || len(session.failed) != 1 {
+		t.Errorf("Expected 1 file, 2 other entries and 1 error, got %d, %d and %v", session.files, session.others, session.failed)
+	}
+	want := append(append([]byte("hello"), make([]byte, 4096)...), "world"...)
+	if got, _ := os.ReadFile(filepath.Join(dest, "data/file")); !bytes.Equal(got, want) {
+		t.Errorf("Restored file has %d bytes, expected %d", len(got), len(want))
+	}
+	if got, _ := os.ReadFile(filepath.Join(dest, "data/link")); !bytes.Equal(got, want) {
+		t.Error("Expected the hard link to be created after its target")
+	}
+	// A file failing verification leaves the existing file alone
+	if got, _ := os.ReadFile(filepath.Join(dest, "data/corrupt")); string(got) != "previous" {
+		t.Errorf("Expected the existing file to be kept, got %q", got)
+	}
+
+	// Data for an entry that was never started aborts the stream
+	stray := &fakeRestoreClient{messages: []*pb.RestoreDataResponse{{FilePath: "/data/x", Data: []byte("x"), IsLastSegment: true}}}
+	if err := session.receive(stray); err == nil {
+		t.Error("Expected an error for data without an entry")
+	}
+}
+
+func TestRestoreToWriter(t *testing.T) {
+	var out bytes.Buffer
+	stream := &fakeRestoreClient{messages: restoreMessages("/data/file", "head", segmentSize+10, "tail")}
+	if err := restoreToWriter(stream, &out, chunking.Blake3); err != nil {
+		t.Fatalf("restoreToWriter failed: %v", err)
+	}
+	want := append(append([]byte("head"), make([]byte, segmentSize+10)...), "tail"...)
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("Wrote %d bytes, expected %d", out.Len(), len(want))
+	}
+
+	two := append(restoreMessages("/a", "a"), restoreMessages("/b", "b")...)
+	if err := restoreToWriter(&fakeRestoreClient{messages: two}, io.Discard, chunking.Blake3); err == nil {
+		t.Error("Expected an error for more than one file")
+	}
+	corrupt := restoreMessages("/a", "good")
+	corrupt[0].Data = []byte("evil")
+	if err := restoreToWriter(&fakeRestoreClient{messages: corrupt}, io.Discard, chunking.Blake3); err == nil {
+		t.Error("Expected an error for contents not matching the hash")
+	}
+}
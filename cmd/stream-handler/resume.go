@@ -0,0 +1,90 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/fswalk"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// resumePoint skips the entries an interrupted job already recorded. The
+// walk visits roots in order and each directory in lexical order, so an
+// entry was recorded if it comes before the last recorded one in that order.
+type resumePoint struct {
+	point *pb.ResumePoint
+	roots []string
+
+	lastRoot  int      // root index of the last recorded entry, -1 if none
+	lastParts []string // its path components below the root
+
+	skipped int
+}
+
+// newResumePoint creates a filter for a walk of roots from the Ingest Node's
+// resume point
+func newResumePoint(roots []string, point *pb.ResumePoint) *resumePoint {
+	r := &resumePoint{point: point, lastRoot: -1}
+	for _, root := range roots {
+		r.roots = append(r.roots, filepath.Clean(root))
+	}
+	if point.LastFile != "" {
+		if root, parts, ok := r.position(point.LastFile); ok {
+			r.lastRoot, r.lastParts = root, parts
+		} else {
+			log.Printf("Warning: Last recorded entry %s is outside the backup paths, starting over", point.LastFile)
+		}
+	}
+	return r
+}
+
+// position returns the root a path lies under and its components below it
+func (r *resumePoint) position(path string) (int, []string, bool) {
+	for i, root := range r.roots {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if rel == "." {
+			return i, nil, true
+		}
+		return i, strings.Split(filepath.ToSlash(rel), "/"), true
+	}
+	return 0, nil, false
+}
+
+// done reports whether an entry was recorded before the job was interrupted
+func (r *resumePoint) done(file fswalk.File) bool {
+	if r == nil || r.lastRoot < 0 {
+		return false
+	}
+	root, parts, ok := r.position(file.Path)
+	if !ok {
+		return false
+	}
+	if root != r.lastRoot {
+		return root < r.lastRoot
+	}
+	for i := 0; i < len(parts) && i < len(r.lastParts); i++ {
+		if parts[i] != r.lastParts[i] {
+			return parts[i] < r.lastParts[i]
+		}
+	}
+	// A directory comes before its contents
+	return len(parts) <= len(r.lastParts)
+}
+
+// offset returns where to continue sending a regular file that was partly
+// recorded, if it is unchanged since
+func (r *resumePoint) offset(entry *pb.FileEntry) (int64, bool) {
+	if r == nil || entry.Type != pb.FileType_FILE_TYPE_REGULAR || entry.FilePath != r.point.PartialFile {
+		return 0, false
+	}
+	partial := r.point.PartialEntry
+	if partial == nil || partial.Size != entry.Size || partial.MtimeNs != entry.MtimeNs ||
+		partial.Inode != entry.Inode || partial.CtimeNs != entry.CtimeNs {
+		return 0, false
+	}
+	return int64(r.point.PartialOffset), true
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/fswalk"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+func TestResumeSkipsRecordedEntries(t *testing.T) {
+	dir := t.TempDir()
+	// Names chosen so lexical walk order differs from plain string order
+	for _, name := range []string{"a/b/c", "a/b-x", "a-z", "b/d", "other/e"} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte(name), 0o644)
+	}
+	roots := []string{filepath.Join(dir, "b"), filepath.Join(dir, "a"), filepath.Join(dir, "a-z")}
+
+	walker, err := fswalk.NewWalker(fswalk.Options{AllTypes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []fswalk.File
+	walker.Walk(roots, &fswalk.Report{}, func(file fswalk.File) error {
+		files = append(files, file)
+		return nil
+	})
+
+	for last := range files {
+		resume := newResumePoint(roots, &pb.ResumePoint{LastFile: files[last].Path})
+		for i, file := range files {
+			if got := resume.done(file); got != (i <= last) {
+				t.Errorf("Last recorded %s: done(%s) = %v", files[last].Path, file.Path, got)
+			}
+		}
+	}
+
+	if resume := newResumePoint(roots, &pb.ResumePoint{}); resume.done(files[0]) {
+		t.Error("Expected nothing to be skipped without a last entry")
+	}
+}
+
+func TestResumePartialFile(t *testing.T) {
+	partial := &pb.FileEntry{FilePath: "/src/big", Type: pb.FileType_FILE_TYPE_REGULAR, Size: 100, MtimeNs: 5, Inode: 9, CtimeNs: 6}
+	resume := newResumePoint([]string{"/src"}, &pb.ResumePoint{PartialFile: "/src/big", PartialOffset: 40, PartialEntry: partial})
+
+	same := &pb.FileEntry{FilePath: "/src/big", Type: pb.FileType_FILE_TYPE_REGULAR, Size: 100, MtimeNs: 5, Inode: 9, CtimeNs: 6}
+	if offset, ok := resume.offset(same); !ok || offset != 40 {
+		t.Errorf("Expected to resume at 40, got %d %v", offset, ok)
+	}
+	changed := &pb.FileEntry{FilePath: "/src/big", Type: pb.FileType_FILE_TYPE_REGULAR, Size: 100, MtimeNs: 7, Inode: 9, CtimeNs: 8}
+	if _, ok := resume.offset(changed); ok {
+		t.Error("Expected a changed file to be sent again from the start")
+	}
+	var none *resumePoint
+	if _, ok := none.offset(same); ok {
+		t.Error("Expected no offset without a resume point")
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/fswalk"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/sparse"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// segmentSender returns a function streaming the segments of a file read by
+// readFile or readRange as FileSegments. Holes reported by the file system
+// and segments holding only zeros are sent as hole segments without data.
+func segmentSender(stream pb.BackupService_StreamBackupClient, file fswalk.File, verbose bool) func(*pb.FileSegment) error {
+	return func(segment *pb.FileSegment) error {
+		if verbose {
+			log.Printf("Sending segment: file=%s, size=%d, hole=%d, offset=%d, isLast=%v",
+				file.Path, len(segment.Data), segment.HoleSize, segment.Offset, segment.IsLastSegment)
+		}
+		if err := stream.Send(&pb.BackupRequest{RequestType: &pb.BackupRequest_FileSegment{FileSegment: segment}}); err != nil {
+			return fmt.Errorf("failed to send file segment: %w", err)
+		}
+		return nil
+	}
+}
+
+// readFile passes the segments of an open file from offset start to send,
+// ending with one marked as the last. Read errors are recorded in the report
+// and end the file early; errors from send abort.
+func readFile(f *os.File, file fswalk.File, start int64, report *fswalk.Report, send func(*pb.FileSegment) error) error {
+	size := file.Info.Size()
+	log.Printf("Sending file: %s (size: %d bytes, from offset %d)", file.Path, size, start)
+
+	segments := fileSegmenter(file, start, send)
+	if err := readSegments(f, size, start, size, segments); err != nil {
+		if segments.failed != nil {
+			return segments.failed
+		}
+		// The segments sent so far are closed off below; the file is
+		// reported so it can be backed up again
+		report.Fail(file.Path, err)
+	}
+	return segments.finish()
+}
+
+// readRange passes the segments of one range of an open file to send,
+// ending with one marked as the last. The Ingest Node expects a range to
+// have the length it was announced with, so data that could not be read is
+// sent as a hole and the file is recorded in the report.
+func readRange(f *os.File, file fswalk.File, r *pb.FileRange, report *fswalk.Report, send func(*pb.FileSegment) error) error {
+	start, end := int64(r.Offset), int64(r.Offset+r.Length)
+	log.Printf("Sending range %d/%d of %s (bytes %d-%d)", r.Index+1, r.Count, file.Path, start, end)
+
+	segments := fileSegmenter(file, start, send)
+	if err := readSegments(f, file.Info.Size(), start, end, segments); err != nil {
+		if segments.failed != nil {
+			return segments.failed
+		}
+		report.Fail(file.Path, err)
+	} else if segments.offset < end {
+		report.Fail(file.Path, fmt.Errorf("file shrank to %d bytes while it was read", segments.offset))
+	}
+	if err := segments.hole(end); err != nil {
+		return err
+	}
+	return segments.finish()
+}
+
+// fileSegmenter creates a segmenter for a file from offset start
+func fileSegmenter(file fswalk.File, start int64, send func(*pb.FileSegment) error) *segmenter {
+	return &segmenter{
+		offset: start,
+		send: func(segment *pb.FileSegment) error {
+			segment.FilePath = file.Path
+			segment.FileSize = uint64(file.Info.Size())
+			return send(segment)
+		},
+	}
+}
+
+// readSegments feeds the data extents of f between offsets start and end to
+// segments, with the gaps between them as holes
+func readSegments(f *os.File, size, start, end int64, segments *segmenter) error {
+	extents, err := sparse.DataExtents(f, size)
+	if err != nil {
+		extents = []sparse.Extent{{Offset: 0, Length: size}}
+	}
+
+	buffer := make([]byte, segmentSize)
+	for _, extent := range extents {
+		if extent.End() <= start {
+			continue
+		}
+		if extent.Offset >= end {
+			break
+		}
+		if extent.Offset < start {
+			extent = sparse.Extent{Offset: start, Length: extent.End() - start}
+		}
+		if extent.End() > end {
+			extent.Length = end - extent.Offset
+		}
+		if err := segments.hole(extent.Offset); err != nil {
+			return err
+		}
+		for offset := extent.Offset; offset < extent.End(); {
+			n, readErr := f.ReadAt(buffer[:min(int64(segmentSize), extent.End()-offset)], offset)
+			if n > 0 {
+				var err error
+				if sparse.IsZero(buffer[:n]) {
+					err = segments.hole(offset + int64(n))
+				} else {
+					err = segments.data(buffer[:n])
+				}
+				if err != nil {
+					return err
+				}
+			}
+			if readErr == io.EOF {
+				return nil // the file shrank while it was read
+			}
+			if readErr != nil {
+				return fmt.Errorf("read failed after %d bytes: %w", offset+int64(n), readErr)
+			}
+			offset += int64(n)
+		}
+	}
+	return segments.hole(end)
+}
+
+// segmenter turns a file's data and holes into FileSegments. Adjacent holes
+// are merged, and one segment is held back so the last can be marked.
+type segmenter struct {
+	send    func(*pb.FileSegment) error
+	offset  int64 // end of the data and holes seen so far
+	pending *pb.FileSegment
+	failed  error // error from send, after which nothing more is sent
+}
+
+// data appends a data segment; the bytes are copied
+func (s *segmenter) data(b []byte) error {
+	if err := s.flush(); err != nil {
+		return err
+	}
+	s.pending = &pb.FileSegment{Data: append([]byte(nil), b...), Offset: uint64(s.offset)}
+	s.offset += int64(len(b))
+	return nil
+}
+
+// hole extends the file with zeros up to end
+func (s *segmenter) hole(end int64) error {
+	if end <= s.offset {
+		return nil
+	}
+	size := uint64(end - s.offset)
+	s.offset = end
+	if s.pending != nil && s.pending.HoleSize > 0 {
+		s.pending.HoleSize += size
+		return nil
+	}
+	if err := s.flush(); err != nil {
+		return err
+	}
+	s.pending = &pb.FileSegment{Offset: uint64(end) - size, HoleSize: size}
+	return nil
+}
+
+// finish sends the held back segment as the last one; an empty file is
+// sent as a single empty segment
+func (s *segmenter) finish() error {
+	if s.pending == nil {
+		s.pending = &pb.FileSegment{Offset: uint64(s.offset)}
+	}
+	s.pending.IsLastSegment = true
+	return s.flush()
+}
+
+func (s *segmenter) flush() error {
+	if s.pending == nil {
+		return nil
+	}
+	if s.failed != nil {
+		return s.failed
+	}
+	segment := s.pending
+	s.pending = nil
+	s.failed = s.send(segment)
+	return s.failed
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/fswalk"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// collectSegments reads a file through a segmenter and returns what was sent
+func collectSegments(t *testing.T, path string) []*pb.FileSegment {
+	t.Helper()
+	return collectSegmentsFrom(t, path, 0)
+}
+
+// collectSegmentsFrom reads a file from offset start through a segmenter
+func collectSegmentsFrom(t *testing.T, path string, start int64) []*pb.FileSegment {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sent []*pb.FileSegment
+	segments := &segmenter{offset: start, send: func(s *pb.FileSegment) error {
+		sent = append(sent, s)
+		return nil
+	}}
+	if err := readSegments(f, info.Size(), start, info.Size(), segments); err != nil {
+		t.Fatalf("readSegments failed: %v", err)
+	}
+	if err := segments.finish(); err != nil {
+		t.Fatal(err)
+	}
+	return sent
+}
+
+// reassemble rebuilds file contents from segments, checking they are contiguous
+func reassemble(t *testing.T, segments []*pb.FileSegment) []byte {
+	t.Helper()
+	var out []byte
+	for i, s := range segments {
+		if s.Offset != uint64(len(out)) {
+			t.Fatalf("Segment %d at offset %d, expected %d", i, s.Offset, len(out))
+		}
+		if s.IsLastSegment != (i == len(segments)-1) {
+			t.Fatalf("Segment %d has isLast=%v", i, s.IsLastSegment)
+		}
+		out = append(out, s.Data...)
+		out = append(out, make([]byte, s.HoleSize)...)
+	}
+	return out
+}
+
+func TestSegmentsSendHolesWithoutData(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "disk.img")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat([]byte("data"), 1000)
+	f.WriteAt(data, 1<<20)
+	f.Truncate(64 << 20)
+	f.Close()
+
+	segments := collectSegments(t, path)
+	content := reassemble(t, segments)
+	if len(content) != 64<<20 || !bytes.Equal(content[1<<20:1<<20+len(data)], data) {
+		t.Fatalf("Reassembled %d bytes do not match the file", len(content))
+	}
+
+	var sentData int
+	for _, s := range segments {
+		sentData += len(s.Data)
+		if len(s.Data) > 0 && s.HoleSize > 0 {
+			t.Errorf("Segment at %d carries both data and a hole", s.Offset)
+		}
+	}
+	if sentData > 2*segmentSize {
+		t.Errorf("Expected only the data region to be sent, sent %d bytes", sentData)
+	}
+	if !segments[len(segments)-1].IsLastSegment || segments[len(segments)-1].HoleSize == 0 {
+		t.Errorf("Expected the file to end with a hole segment")
+	}
+}
+
+func TestSegmentsZeroRunsAndSmallFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	// Zeros written out in full are sent as holes too
+	zeros := filepath.Join(dir, "zeros")
+	content := append(make([]byte, 3*segmentSize), []byte("tail")...)
+	os.WriteFile(zeros, content, 0o644)
+	segments := collectSegments(t, zeros)
+	if !bytes.Equal(reassemble(t, segments), content) {
+		t.Fatal("Zero-filled file did not reassemble")
+	}
+	if len(segments) != 2 || segments[0].HoleSize != 3*segmentSize || string(segments[1].Data) != "tail" {
+		t.Errorf("Expected one merged hole then the tail, got %v", segments)
+	}
+
+	empty := filepath.Join(dir, "empty")
+	os.WriteFile(empty, nil, 0o644)
+	segments = collectSegments(t, empty)
+	if len(segments) != 1 || !segments[0].IsLastSegment || len(segments[0].Data) != 0 || segments[0].HoleSize != 0 {
+		t.Errorf("Expected a single empty last segment, got %v", segments)
+	}
+
+	small := filepath.Join(dir, "small")
+	os.WriteFile(small, []byte("hello"), 0o644)
+	if got := reassemble(t, collectSegments(t, small)); string(got) != "hello" {
+		t.Errorf("Got %q", got)
+	}
+}
+
+func TestSegmentsFromOffset(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "resumed")
+	content := bytes.Repeat([]byte("0123456789abcdef"), 3*segmentSize/16)
+	copy(content[segmentSize:], make([]byte, segmentSize)) // a zero run in the middle
+	os.WriteFile(path, content, 0o644)
+
+	for _, start := range []int64{0, 100, segmentSize + 10, int64(len(content))} {
+		segments := collectSegmentsFrom(t, path, start)
+		if segments[0].Offset != uint64(start) {
+			t.Errorf("Start %d: first segment at %d", start, segments[0].Offset)
+		}
+		var got []byte
+		for _, s := range segments {
+			got = append(got, s.Data...)
+			got = append(got, make([]byte, s.HoleSize)...)
+		}
+		if !bytes.Equal(got, content[start:]) || !segments[len(segments)-1].IsLastSegment {
+			t.Errorf("Start %d: segments do not cover the rest of the file", start)
+		}
+	}
+}
+
+func TestSegmentsOfRanges(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "large")
+	content := bytes.Repeat([]byte("0123456789abcdef"), 3*segmentSize/16)
+	copy(content[segmentSize:], make([]byte, segmentSize)) // a zero run across a range boundary
+	os.WriteFile(path, content, 0o644)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	info, _ := f.Stat()
+	file := fswalk.File{Path: path, Info: info}
+
+	// Every range covers exactly its bytes, and together they cover the file
+	const rangeSize = segmentSize + 100
+	var got []byte
+	for offset := int64(0); offset < info.Size(); offset += rangeSize {
+		r := &pb.FileRange{Offset: uint64(offset), Length: uint64(min(rangeSize, info.Size()-offset))}
+		var sent []*pb.FileSegment
+		err := readRange(f, file, r, &fswalk.Report{}, func(s *pb.FileSegment) error {
+			sent = append(sent, s)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("readRange failed: %v", err)
+		}
+		var data []byte
+		for i, s := range sent {
+			if s.Offset != r.Offset+uint64(len(data)) || s.IsLastSegment != (i == len(sent)-1) {
+				t.Fatalf("Range at %d: segment %d at offset %d, isLast=%v", offset, i, s.Offset, s.IsLastSegment)
+			}
+			data = append(data, s.Data...)
+			data = append(data, make([]byte, s.HoleSize)...)
+		}
+		if uint64(len(data)) != r.Length {
+			t.Fatalf("Range at %d has %d bytes, expected %d", offset, len(data), r.Length)
+		}
+		got = append(got, data...)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("Ranges do not reassemble the file")
+	}
+}
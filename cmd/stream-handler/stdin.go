@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/fsmeta"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/fswalk"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/sparse"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// sendInput backs up a source of unknown size, such as standard input or a
+// named pipe, as a single regular file recorded under name. The source
+// cannot be read again, so unlike a file that fails to read it aborts the
+// backup rather than being recorded truncated.
+func sendInput(u *uploadStream, r io.Reader, name string, report *fswalk.Report, verbose bool) error {
+	entry := fsmeta.StreamEntry(name)
+	entryMsg := &pb.BackupRequest{
+		RequestType: &pb.BackupRequest_FileEntry{FileEntry: entry},
+	}
+	if err := u.stream.Send(entryMsg); err != nil {
+		return fmt.Errorf("failed to send file entry: %w", err)
+	}
+
+	send := segmentSender(u.stream, fswalk.File{Path: name}, verbose)
+	if u.chunks != nil {
+		send = u.chunks.file(name).add
+	}
+	log.Printf("Sending %s from a source of unknown size", name)
+	size, err := readStream(r, name, send)
+	if err != nil {
+		return err
+	}
+	log.Printf("Sent %s (size: %d bytes)", name, size)
+	report.Files++
+	report.Bytes += size
+	return nil
+}
+
+// readStream passes the segments of r to send until it ends, then marks the
+// last one. The segments carry no file size; runs of zeros are sent as holes
+// as they are for files. It returns the number of bytes read.
+func readStream(r io.Reader, path string, send func(*pb.FileSegment) error) (int64, error) {
+	segments := &segmenter{send: func(segment *pb.FileSegment) error {
+		segment.FilePath = path
+		segment.SizeUnknown = true
+		return send(segment)
+	}}
+
+	// Pipes return short reads, so each segment is filled before it is sent
+	buffer := make([]byte, segmentSize)
+	for {
+		n, readErr := io.ReadFull(r, buffer)
+		if n > 0 {
+			var err error
+			if sparse.IsZero(buffer[:n]) {
+				err = segments.hole(segments.offset + int64(n))
+			} else {
+				err = segments.data(buffer[:n])
+			}
+			if err != nil {
+				return segments.offset, err
+			}
+		}
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			break
+		}
+		if readErr != nil {
+			return segments.offset, fmt.Errorf("read of %s failed after %d bytes: %w", path, segments.offset, readErr)
+		}
+	}
+	return segments.offset, segments.finish()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+func TestReadStream(t *testing.T) {
+	// A dump with a run of zeros in the middle, read with short reads as
+	// from a pipe
+	var content []byte
+	content = append(content, bytes.Repeat([]byte("COPY t FROM stdin;\n"), 5000)...)
+	content = append(content, make([]byte, 3*segmentSize)...)
+	content = append(content, "\\.\n"...)
+
+	var sent []*pb.FileSegment
+	size, err := readStream(iotest.HalfReader(bytes.NewReader(content)), "db.sql", func(s *pb.FileSegment) error {
+		sent = append(sent, s)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("readStream failed: %v", err)
+	}
+	if size != int64(len(content)) {
+		t.Errorf("Expected %d bytes read, got %d", len(content), size)
+	}
+	if !bytes.Equal(reassemble(t, sent), content) {
+		t.Fatal("Reassembled segments do not match the input")
+	}
+	var holes int
+	for _, s := range sent {
+		if s.FilePath != "db.sql" || !s.SizeUnknown || s.FileSize != 0 {
+			t.Fatalf("Unexpected segment: %+v", s)
+		}
+		if s.HoleSize > 0 {
+			holes++
+		}
+		if len(s.Data) > segmentSize {
+			t.Fatalf("Segment of %d bytes is larger than %d", len(s.Data), segmentSize)
+		}
+	}
+	if holes != 1 {
+		t.Errorf("Expected the zero run as one hole, got %d holes", holes)
+	}
+
+	// An empty input is one empty last segment
+	sent = nil
+	if _, err := readStream(bytes.NewReader(nil), "empty", func(s *pb.FileSegment) error {
+		sent = append(sent, s)
+		return nil
+	}); err != nil || len(sent) != 1 || !sent[0].IsLastSegment {
+		t.Errorf("Expected a single last segment for empty input, got %v (%v)", sent, err)
+	}
+
+	// A failing read aborts instead of ending the file
+	failing := io.MultiReader(bytes.NewReader([]byte("partial")), iotest.ErrReader(errors.New("broken pipe")))
+	sent = nil
+	if _, err := readStream(failing, "db.sql", func(s *pb.FileSegment) error {
+		sent = append(sent, s)
+		return nil
+	}); err == nil {
+		t.Error("Expected an error for a failing read")
+	}
+	for _, s := range sent {
+		if s.IsLastSegment {
+			t.Error("Expected no last segment after a failed read")
+		}
+	}
+}
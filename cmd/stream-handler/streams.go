@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/fswalk"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// uploadStream is one backup stream of a job. The first stream starts the
+// job; with -streams the others join it, and all of them upload entries of
+// the same walk.
+type uploadStream struct {
+	stream   pb.BackupService_StreamBackupClient
+	chunks   *chunkSender // set for source-side deduplication
+	received chan error   // result of receiving responses
+}
+
+// openStream opens a backup stream and sends start on it. It returns the
+// resume point the Ingest Node sent for a resumed job, if any. Responses are
+// received in the background from then on, so the Ingest Node never blocks
+// on a full response stream during a long walk.
+func openStream(ctx context.Context, client pb.BackupServiceClient, start *pb.BackupStart, sourceDedupe, verbose bool) (*uploadStream, *pb.ResumePoint, error) {
+	stream, err := client.StreamBackup(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create backup stream: %w", err)
+	}
+	startMsg := &pb.BackupRequest{
+		RequestType: &pb.BackupRequest_StartBackup{StartBackup: start},
+	}
+	if err := stream.Send(startMsg); err != nil {
+		return nil, nil, fmt.Errorf("failed to send backup start message: %w", err)
+	}
+	point, started, err := awaitStart(stream)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u := &uploadStream{stream: stream, received: make(chan error, 1)}
+	missing := make(chan *pb.MissingChunks, 1)
+	go func() {
+		u.received <- receiveResponses(stream, missing)
+	}()
+	if sourceDedupe {
+		// Chunks must be made with the repository's chunking profile
+		profile, err := chunkingProfile(started)
+		if err == nil {
+			u.chunks, err = newChunkSender(stream.Send, missing, profile, verbose)
+		}
+		if err != nil {
+			stream.CloseSend()
+			return nil, nil, err
+		}
+	}
+	return u, point, nil
+}
+
+// awaitStart reads responses until the Ingest Node has accepted a
+// BackupStart, returning the resume point it sent for a resumed job and the
+// status that accepted it
+func awaitStart(stream pb.BackupService_StreamBackupClient) (*pb.ResumePoint, *pb.BackupStatus, error) {
+	var point *pb.ResumePoint
+	for {
+		response, err := stream.Recv()
+		if err != nil {
+			return nil, nil, err
+		}
+		switch response.ResponseType.(type) {
+		case *pb.BackupResponse_ResumePoint:
+			point = response.GetResumePoint()
+		case *pb.BackupResponse_StatusUpdate:
+			logStatus(response.GetStatusUpdate())
+			return point, response.GetStatusUpdate(), nil
+		case *pb.BackupResponse_ErrorMessage:
+			error := response.GetErrorMessage()
+			return nil, nil, fmt.Errorf("%s - %s", error.ErrorCode, error.ErrorMessage)
+		}
+	}
+}
+
+// close ends the requests of the stream and waits until the Ingest Node has
+// answered all of them
+func (u *uploadStream) close() error {
+	if err := u.stream.CloseSend(); err != nil {
+		return fmt.Errorf("failed to close send stream: %w", err)
+	}
+	return <-u.received
+}
+
+// upload is an entry to send over one stream, or one range of a large file
+type upload struct {
+	file      fswalk.File
+	entry     *pb.FileEntry
+	f         *os.File      // open regular file
+	start     int64         // offset a partly recorded file continues from
+	resumed   bool          // continue the partial file of a resumed job
+	unchanged bool          // send as unchanged since the previous backup
+	part      *pb.FileRange // set for one range of a large file
+	done      func()        // called once the upload is sent or abandoned
+}
+
+// startWorkers sends the entries of the walk over all streams in parallel.
+// Regular files larger than rangeSize are split into ranges, which may be
+// sent over different streams.
+func (s *backupSession) startWorkers() {
+	s.queue = make(chan *upload, len(s.streams))
+	for _, u := range s.streams {
+		s.workers.Add(1)
+		go func(u *uploadStream) {
+			defer s.workers.Done()
+			for item := range s.queue {
+				if s.err() == nil {
+					if err := s.send(u, item); err != nil {
+						s.fail(err)
+					}
+				}
+				item.done()
+			}
+		}(u)
+	}
+}
+
+// dispatch queues an entry for the workers, splitting large files into ranges
+func (s *backupSession) dispatch(item *upload) error {
+	if err := s.err(); err != nil {
+		item.done()
+		return err
+	}
+	size := int64(item.entry.Size)
+	if item.unchanged || item.entry.Type != pb.FileType_FILE_TYPE_REGULAR || size <= s.rangeSize {
+		s.queue <- item
+		return nil
+	}
+
+	// The ranges share the open file, which is closed after the last of
+	// them is sent
+	count := (size + s.rangeSize - 1) / s.rangeSize
+	var sent sync.WaitGroup
+	sent.Add(int(count))
+	go func() {
+		sent.Wait()
+		item.done()
+	}()
+	for i := int64(0); i < count; i++ {
+		offset := i * s.rangeSize
+		s.queue <- &upload{
+			file:  item.file,
+			entry: item.entry,
+			f:     item.f,
+			part: &pb.FileRange{
+				Entry:  item.entry,
+				Index:  uint32(i),
+				Count:  uint32(count),
+				Offset: uint64(offset),
+				Length: uint64(min(s.rangeSize, size-offset)),
+			},
+			done: sent.Done,
+		}
+	}
+	return nil
+}
+
+// wait waits until the workers have sent every queued entry and returns the
+// first error that stopped them
+func (s *backupSession) wait() error {
+	if s.queue != nil {
+		close(s.queue)
+		s.workers.Wait()
+	}
+	return s.err()
+}
+
+// fail records the first error of a worker; later entries are abandoned
+func (s *backupSession) fail(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.failed == nil {
+		s.failed = err
+	}
+}
+
+func (s *backupSession) err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.failed
+}
+
+// logStatus logs a status update from the Ingest Node
+func logStatus(status *pb.BackupStatus) {
+	log.Printf("Status: %s - %s (processed: %d, deduplicated: %d, delta saved: %d bytes)",
+		status.BackupJobId, status.Message, status.BytesProcessed, status.BytesDeduplicated, status.BytesDeltaSaved)
+}
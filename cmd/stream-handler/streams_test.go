@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/fswalk"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+func TestDispatchSplitsLargeFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "large")
+	os.WriteFile(path, make([]byte, 250), 0o644)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	info, _ := f.Stat()
+	closed := make(chan bool)
+	session := &backupSession{rangeSize: 100, queue: make(chan *upload, 10)}
+
+	entry := &pb.FileEntry{FilePath: path, Type: pb.FileType_FILE_TYPE_REGULAR, Size: 250}
+	item := &upload{file: fswalk.File{Path: path, Info: info}, entry: entry, f: f, done: func() { close(closed) }}
+	if err := session.dispatch(item); err != nil {
+		t.Fatal(err)
+	}
+	small := &upload{entry: &pb.FileEntry{FilePath: "/small", Type: pb.FileType_FILE_TYPE_REGULAR, Size: 100}, done: func() {}}
+	if err := session.dispatch(small); err != nil {
+		t.Fatal(err)
+	}
+	close(session.queue)
+
+	var parts []*upload
+	var whole []*upload
+	for queued := range session.queue {
+		if queued.part == nil {
+			whole = append(whole, queued)
+			continue
+		}
+		parts = append(parts, queued)
+		if queued.f != f {
+			t.Error("Expected the ranges to share the open file")
+		}
+	}
+	if len(whole) != 1 || whole[0] != small {
+		t.Errorf("Expected the small file to be sent whole, got %d whole uploads", len(whole))
+	}
+	want := [][2]uint64{{0, 100}, {100, 100}, {200, 50}}
+	if len(parts) != len(want) {
+		t.Fatalf("Expected %d ranges, got %d", len(want), len(parts))
+	}
+	for i, queued := range parts {
+		part := queued.part
+		if part.Index != uint32(i) || part.Count != 3 || part.Offset != want[i][0] || part.Length != want[i][1] || part.Entry != entry {
+			t.Errorf("Range %d: %v", i, part)
+		}
+	}
+
+	// The file is closed once every range is done, not before
+	parts[0].done()
+	parts[2].done()
+	select {
+	case <-closed:
+		t.Fatal("File closed before its ranges were sent")
+	default:
+	}
+	parts[1].done()
+	<-closed
+}
+
+func TestChunkingProfileOfStart(t *testing.T) {
+	// Ingest Nodes from before chunking profiles chunk with the legacy
+	// pattern chunker
+	profile, err := chunkingProfile(&pb.BackupStatus{FingerprintAlgorithm: "sha256"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := chunking.LegacyProfile()
+	want.Hash = chunking.SHA256
+	if profile != want {
+		t.Errorf("Expected the legacy profile with sha256, got %s", profile)
+	}
+
+	profile, err = chunkingProfile(&pb.BackupStatus{ChunkingProfile: &pb.ChunkingProfile{
+		Version: 2, Algorithm: "rabin", MinSize: 512, AvgSize: 2048, MaxSize: 16384, WindowSize: 48, Polynomial: 0x3DA3358B4DC173,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Version != 2 || profile.MinSize != 512 || profile.MaxSize != 16384 || profile.WindowSize != 48 || profile.Hash != chunking.Blake3 {
+		t.Errorf("Unexpected profile %s", profile)
+	}
+	if _, err := newChunkSender(nil, nil, chunking.Profile{Version: 3, Algorithm: "unknown"}, false); err == nil {
+		t.Error("Expected a sender for an unknown chunking algorithm to fail")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/radhakrishnan.venkat/dedupe-engine/internal/cache"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
 )
 
 func main() {
@@ -17,18 +18,18 @@ func main() {
 
 	// Add some test data
 	metadata1 := &cache.ChunkMetadata{
-		Fingerprint:        "fingerprint1",
+		Fingerprint:        fingerprint("fingerprint1"),
 		StorageLocation:    "location1",
 		Size:               1024,
 		CreationTime:       time.Now(),
 		LastReferencedTime: time.Now(),
 	}
 
-	lruCache.Put("key1", metadata1)
+	lruCache.Put(fingerprint("key1"), metadata1)
 	fmt.Printf("  Added key1, cache size: %d\n", lruCache.Size())
 
 	// Test retrieval
-	retrieved, exists := lruCache.Get("key1")
+	retrieved, exists := lruCache.Get(fingerprint("key1"))
 	if exists {
 		fmt.Printf("  Retrieved key1: %s\n", retrieved.Fingerprint)
 	} else {
@@ -36,13 +37,13 @@ func main() {
 	}
 
 	// Test capacity
-	lruCache.Put("key2", &cache.ChunkMetadata{Fingerprint: "fingerprint2"})
-	lruCache.Put("key3", &cache.ChunkMetadata{Fingerprint: "fingerprint3"})
-	lruCache.Put("key4", &cache.ChunkMetadata{Fingerprint: "fingerprint4"})
+	lruCache.Put(fingerprint("key2"), &cache.ChunkMetadata{Fingerprint: fingerprint("fingerprint2")})
+	lruCache.Put(fingerprint("key3"), &cache.ChunkMetadata{Fingerprint: fingerprint("fingerprint3")})
+	lruCache.Put(fingerprint("key4"), &cache.ChunkMetadata{Fingerprint: fingerprint("fingerprint4")})
 	fmt.Printf("  After adding 4 items, cache size: %d\n", lruCache.Size())
 
 	// Test eviction
-	_, exists = lruCache.Get("key1")
+	_, exists = lruCache.Get(fingerprint("key1"))
 	if !exists {
 		fmt.Println("  ✓ key1 was correctly evicted (LRU working)")
 	} else {
@@ -54,11 +55,11 @@ func main() {
 	dc := cache.NewDeduplicationCache(10, 100)
 
 	// Add metadata
-	dc.PutChunkMetadata("test-fingerprint", metadata1)
+	dc.PutChunkMetadata(fingerprint("test-fingerprint"), metadata1)
 	fmt.Printf("  Added metadata, cache size: %d\n", dc.Size())
 
 	// Test retrieval
-	retrieved, exists = dc.GetChunkMetadata("test-fingerprint")
+	retrieved, exists = dc.GetChunkMetadata(fingerprint("test-fingerprint"))
 	if exists {
 		fmt.Printf("  Retrieved metadata: %s\n", retrieved.Fingerprint)
 	} else {
@@ -66,28 +67,28 @@ func main() {
 	}
 
 	// Test Cuckoo filter
-	if dc.MightContain("test-fingerprint") {
+	if dc.MightContain(fingerprint("test-fingerprint")) {
 		fmt.Println("  ✓ Cuckoo filter correctly identifies fingerprint")
 	} else {
 		fmt.Println("  ✗ Cuckoo filter failed to identify fingerprint")
 	}
 
 	// Test non-existent fingerprint
-	if !dc.MightContain("non-existent-fingerprint") {
+	if !dc.MightContain(fingerprint("non-existent-fingerprint")) {
 		fmt.Println("  ✓ Cuckoo filter correctly identifies non-existent fingerprint")
 	} else {
 		fmt.Println("  ✗ Cuckoo filter incorrectly identified non-existent fingerprint")
 	}
 
 	// Test removal
-	if dc.RemoveChunkMetadata("test-fingerprint") {
+	if dc.RemoveChunkMetadata(fingerprint("test-fingerprint")) {
 		fmt.Println("  ✓ Successfully removed metadata")
 	} else {
 		fmt.Println("  ✗ Failed to remove metadata")
 	}
 
 	// Verify it's gone
-	_, exists = dc.GetChunkMetadata("test-fingerprint")
+	_, exists = dc.GetChunkMetadata(fingerprint("test-fingerprint"))
 	if !exists {
 		fmt.Println("  ✓ Metadata correctly removed")
 	} else {
@@ -96,3 +97,8 @@ func main() {
 
 	fmt.Println("\nCache testing completed!")
 }
+
+// fingerprint returns the fingerprint of a test string
+func fingerprint(s string) chunking.Fingerprint {
+	return chunking.ComputeFingerprint([]byte(s))
+}
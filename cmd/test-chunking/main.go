@@ -38,7 +38,7 @@ func main() {
 
 	fmt.Println("\nChunk details:")
 	for i, chunk := range chunks {
-		fmt.Printf("  Chunk %d: size=%d, fingerprint=%s\n", i, chunk.Size, chunk.Fingerprint[:16]+"...")
+		fmt.Printf("  Chunk %d: size=%d, fingerprint=%s\n", i, chunk.Size, chunk.Fingerprint.Short()+"...")
 	}
 }
 
@@ -55,7 +55,7 @@ func calculateDeduplicationRatio(chunks []chunking.Chunk) float64 {
 		return 0
 	}
 
-	uniqueFingerprints := make(map[string]bool)
+	uniqueFingerprints := make(map[chunking.Fingerprint]bool)
 	for _, chunk := range chunks {
 		uniqueFingerprints[chunk.Fingerprint] = true
 	}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
 	"github.com/radhakrishnan.venkat/dedupe-engine/internal/db"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/jobstate"
 )
 
 func main() {
@@ -25,9 +27,10 @@ func main() {
 	// Test data structures
 	fmt.Println("\n2. Testing data structures:")
 
+	fingerprint := chunking.ComputeFingerprint([]byte("test chunk 123"))
 	metadata := &db.ChunkMetadata{
-		Fingerprint:        "test-fingerprint-123",
-		StorageLocation:    "minio://bucket/test-fingerprint-123",
+		Fingerprint:        fingerprint,
+		StorageLocation:    "minio://bucket/" + fingerprint.String(),
 		Size:               1024,
 		CreationTime:       time.Now(),
 		LastReferencedTime: time.Now(),
@@ -38,7 +41,7 @@ func main() {
 		ClientID:       "test-client",
 		BackupPolicyID: "default-policy",
 		StartTime:      time.Now(),
-		Status:         "INITIATED",
+		Status:         jobstate.Pending,
 		SourceType:     "filesystem",
 		SourceDetails:  `{"path": "/test/path"}`,
 	}
@@ -53,7 +56,7 @@ func main() {
 	schemaContent := `
 -- Chunks table: stores unique data chunks
 CREATE TABLE IF NOT EXISTS chunks (
-    fingerprint STRING PRIMARY KEY, -- Blake3 hash of chunk
+    fingerprint BYTES PRIMARY KEY, -- binary Blake3 fingerprint of chunk
     storage_location STRING NOT NULL, -- MinIO object key
     size INT NOT NULL,
     creation_time TIMESTAMPTZ NOT NULL DEFAULT now(),
@@ -0,0 +1,300 @@
+// Package archive follows tar and uncompressed zip streams to find where the
+// contents of their members begin and end, so the contents can be chunked
+// apart from the headers between them
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// Format is the kind of archive a stream holds
+type Format int
+
+const (
+	Unknown Format = iota // too little of the stream seen to tell
+	Tar
+	Zip
+	None // not an archive this package can follow
+)
+
+func (f Format) String() string {
+	switch f {
+	case Tar:
+		return "tar"
+	case Zip:
+		return "zip"
+	case None:
+		return "none"
+	}
+	return "unknown"
+}
+
+const (
+	blockSize = 512 // tar header and padding unit
+
+	// maxExtendedHeader bounds the PAX extended headers gathered for the
+	// sizes of large tar members
+	maxExtendedHeader = 1 << 20
+
+	zipHeaderSize = 30 // fixed part of a zip local file header
+)
+
+var zipLocalHeader = []byte("PK\x03\x04")
+
+// Splitter follows an archive stream and reports the offsets at which the
+// contents of its members begin and end. The stream is passed in order, its
+// data with Write and runs of zeros that were not sent as data with Skip.
+// Once the end of the archive is reached, or the stream turns out not to be
+// one, no more offsets are reported.
+type Splitter struct {
+	format  Format
+	pos     int64   // stream offset of the next byte
+	next    int64   // stream offset of the next header
+	header  []byte  // header bytes gathered so far
+	need    int     // header bytes needed before it can be parsed
+	cuts    []int64 // stream offsets not yet reported
+	done    bool
+	pax     bool  // header holds the contents of a PAX extended header
+	paxSize int64 // member size from a PAX extended header, or -1
+}
+
+// NewSplitter creates a splitter for a stream whose format is not known yet
+func NewSplitter() *Splitter {
+	return &Splitter{need: len(zipLocalHeader), paxSize: -1}
+}
+
+// Format returns the format of the stream once it is known
+func (s *Splitter) Format() Format {
+	return s.format
+}
+
+// Offset returns the stream offset of the next byte the splitter expects
+func (s *Splitter) Offset() int64 {
+	return s.pos
+}
+
+// stateVersion is the first byte of an encoded splitter state
+const stateVersion = 1
+
+// MarshalBinary encodes the state of the splitter, so that splitting can
+// continue from the same offset in another process
+func (s *Splitter) MarshalBinary() ([]byte, error) {
+	b := []byte{stateVersion, byte(s.format)}
+	b = binary.AppendVarint(b, s.pos)
+	b = binary.AppendVarint(b, s.next)
+	b = binary.AppendVarint(b, int64(s.need))
+	b = binary.AppendVarint(b, s.paxSize)
+	b = append(b, boolByte(s.done), boolByte(s.pax))
+	b = binary.AppendUvarint(b, uint64(len(s.header)))
+	b = append(b, s.header...)
+	b = binary.AppendUvarint(b, uint64(len(s.cuts)))
+	for _, cut := range s.cuts {
+		b = binary.AppendVarint(b, cut)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary restores a state encoded by MarshalBinary
+func (s *Splitter) UnmarshalBinary(b []byte) error {
+	d := stateDecoder{b: b}
+	if d.byte() != stateVersion {
+		return errMalformedState
+	}
+	restored := Splitter{format: Format(d.byte())}
+	restored.pos = d.varint()
+	restored.next = d.varint()
+	restored.need = int(d.varint())
+	restored.paxSize = d.varint()
+	restored.done = d.byte() == 1
+	restored.pax = d.byte() == 1
+	restored.header = d.bytes()
+	for count := d.uvarint(); count > 0 && !d.failed; count-- {
+		restored.cuts = append(restored.cuts, d.varint())
+	}
+	if d.failed || len(d.b) != 0 || restored.format > None || restored.need < len(restored.header) {
+		return errMalformedState
+	}
+	*s = restored
+	return nil
+}
+
+var errMalformedState = errors.New("malformed splitter state")
+
+// stateDecoder reads the fields of an encoded splitter state, noting when
+// one is missing or malformed
+type stateDecoder struct {
+	b      []byte
+	failed bool
+}
+
+func (d *stateDecoder) byte() byte {
+	if len(d.b) == 0 {
+		d.failed = true
+		return 0
+	}
+	v := d.b[0]
+	d.b = d.b[1:]
+	return v
+}
+
+func (d *stateDecoder) varint() int64 {
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.failed = true
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *stateDecoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.failed = true
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *stateDecoder) bytes() []byte {
+	size := d.uvarint()
+	if size > uint64(len(d.b)) {
+		d.failed = true
+		return nil
+	}
+	v := append([]byte(nil), d.b[:size]...)
+	d.b = d.b[size:]
+	return v
+}
+
+func boolByte(v bool) byte {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// Write consumes the next data of the stream and returns the offsets within
+// data at which member contents begin or end, in order. An offset may equal
+// len(data) when contents begin right after it.
+func (s *Splitter) Write(data []byte) []int {
+	base := s.pos
+	s.advance(data, int64(len(data)))
+	var offsets []int
+	for _, cut := range s.take() {
+		offsets = append(offsets, int(cut-base))
+	}
+	return offsets
+}
+
+// Skip consumes n zero bytes of the stream. Offsets inside them are dropped,
+// since zeros are not chunked; one at their end is reported by the next Write.
+func (s *Splitter) Skip(n int64) {
+	s.advance(nil, n)
+	for len(s.cuts) > 0 && s.cuts[0] < s.pos {
+		s.cuts = s.cuts[1:]
+	}
+}
+
+// take removes and returns the cuts up to the current position
+func (s *Splitter) take() []int64 {
+	n := 0
+	for n < len(s.cuts) && s.cuts[n] <= s.pos {
+		n++
+	}
+	taken := s.cuts[:n:n]
+	s.cuts = s.cuts[n:]
+	return taken
+}
+
+// advance moves over n bytes of the stream, which are data or zeros if data
+// is nil, parsing the headers among them
+func (s *Splitter) advance(data []byte, n int64) {
+	for n > 0 && !s.done {
+		if s.pos < s.next {
+			k := min(n, s.next-s.pos)
+			if data != nil {
+				data = data[k:]
+			}
+			s.pos += k
+			n -= k
+			continue
+		}
+
+		k := min(n, int64(s.need-len(s.header)))
+		if data != nil {
+			s.header = append(s.header, data[:k]...)
+			data = data[k:]
+		} else {
+			s.header = append(s.header, make([]byte, k)...)
+		}
+		s.pos += k
+		n -= k
+		if len(s.header) == s.need {
+			s.parse()
+		}
+	}
+	s.pos += n
+}
+
+// parse handles a complete header
+func (s *Splitter) parse() {
+	switch s.format {
+	case Unknown:
+		s.sniff()
+	case Tar:
+		s.parseTar()
+	case Zip:
+		s.parseZip()
+	}
+}
+
+// sniff tells the format from the first header. Zip streams are known by
+// their first four bytes, tar streams by a valid first header block.
+func (s *Splitter) sniff() {
+	if len(s.header) == len(zipLocalHeader) {
+		if bytes.Equal(s.header, zipLocalHeader) {
+			s.format = Zip
+			s.need = zipHeaderSize
+		} else {
+			s.need = blockSize
+		}
+		return
+	}
+	if isZero(s.header) || !validChecksum(s.header) {
+		s.format = None
+		s.done = true
+		return
+	}
+	s.format = Tar
+	s.parseTar()
+}
+
+// member records the contents of a member starting at the current position
+func (s *Splitter) member(size int64) {
+	if size > 0 {
+		s.cuts = append(s.cuts, s.pos, s.pos+size)
+	}
+}
+
+// stop ends the splitting; the rest of the stream is one region
+func (s *Splitter) stop() {
+	s.done = true
+	s.header = nil
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func le16(b []byte) int64  { return int64(binary.LittleEndian.Uint16(b)) }
+func le32(b []byte) int64  { return int64(binary.LittleEndian.Uint32(b)) }
+func le64(b []byte) uint64 { return binary.LittleEndian.Uint64(b) }
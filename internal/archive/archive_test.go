@@ -0,0 +1,208 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"hash/crc32"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// members are the contents archived by the tests, in order
+var members = [][]byte{
+	[]byte("hello world\n"),
+	bytes.Repeat([]byte("0123456789"), 1000),
+	append(append(bytes.Repeat([]byte("z"), 700), make([]byte, 5000)...), "end"...), // a run of zeros inside
+}
+
+func tarStream(t *testing.T, format tar.Format) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	w.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0o755, Format: format})
+	for i, data := range members {
+		name := "dir/" + strings.Repeat("sub/", i*20) + "file" // long names need extra headers
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: time.Unix(1700000000, 0), Format: format}
+		if err := w.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	w.WriteHeader(&tar.Header{Name: "dir/link", Typeflag: tar.TypeSymlink, Linkname: "file", Format: format})
+	w.Close()
+	return buf.Bytes()
+}
+
+func zipStream(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for i, data := range members {
+		hdr := &zip.FileHeader{
+			Name:               "file" + strings.Repeat("x", i),
+			Method:             zip.Store,
+			CRC32:              crc32.ChecksumIEEE(data),
+			CompressedSize64:   uint64(len(data)),
+			UncompressedSize64: uint64(len(data)),
+			Extra:              []byte{0xfe, 0xca, 2, 0, 1, 2}, // unknown extra field
+		}
+		f, err := w.CreateRaw(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+	}
+	w.Close()
+	return buf.Bytes()
+}
+
+// split feeds a stream to a splitter in pieces of size n, skipping zero
+// pieces like a sender turning them into holes, and returns the stream
+// offsets reported
+func split(s *Splitter, stream []byte, n int) []int64 {
+	var cuts []int64
+	for offset := 0; offset < len(stream); offset += n {
+		piece := stream[offset:min(offset+n, len(stream))]
+		if isZero(piece) {
+			s.Skip(int64(len(piece)))
+			continue
+		}
+		for _, cut := range s.Write(piece) {
+			cuts = append(cuts, int64(offset+cut))
+		}
+	}
+	return cuts
+}
+
+// checkMembers checks that each pair of offsets encloses a member's contents
+func checkMembers(t *testing.T, name string, stream []byte, cuts []int64) {
+	t.Helper()
+	if len(cuts)%2 != 0 || len(cuts) > 2*len(members) {
+		t.Fatalf("%s: unexpected offsets %v", name, cuts)
+	}
+	for i := 0; i < len(cuts); i += 2 {
+		got := stream[cuts[i]:cuts[i+1]]
+		if !bytes.Equal(got, members[i/2]) {
+			t.Errorf("%s: member %d at [%d, %d) does not match its contents", name, i/2, cuts[i], cuts[i+1])
+		}
+	}
+}
+
+func TestSplitTar(t *testing.T) {
+	for _, format := range []tar.Format{tar.FormatUSTAR, tar.FormatPAX, tar.FormatGNU} {
+		stream := tarStream(t, format)
+		for _, n := range []int{1, 7, 512, 4096, len(stream)} {
+			s := NewSplitter()
+			cuts := split(s, stream, n)
+			if s.Format() != Tar {
+				t.Fatalf("%v: detected %v", format, s.Format())
+			}
+			if n < 512 && len(cuts) != 2*len(members) {
+				t.Errorf("%v in pieces of %d: expected offsets for %d members, got %v", format, n, len(members), cuts)
+			}
+			checkMembers(t, format.String(), stream, cuts)
+		}
+	}
+}
+
+func TestSplitTarPaxSize(t *testing.T) {
+	// A PAX record overrides the size in the member's header block
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	w.WriteHeader(&tar.Header{Name: "big", Size: int64(len(members[1])), PAXRecords: map[string]string{"comment": "x"}, Format: tar.FormatPAX})
+	w.Write(members[1])
+	w.Close()
+	stream := buf.Bytes()
+
+	if size := paxSize([]byte("30 mtime=1700000000.123456789\n16 size=12345678\n"), -1); size != 12345678 {
+		t.Errorf("Expected size 12345678 from the records, got %d", size)
+	}
+	cuts := split(NewSplitter(), stream, 100)
+	if len(cuts) != 2 || !bytes.Equal(stream[cuts[0]:cuts[1]], members[1]) {
+		t.Errorf("Unexpected offsets %v", cuts)
+	}
+}
+
+func TestSplitZip(t *testing.T) {
+	stream := zipStream(t)
+	for _, n := range []int{1, 13, 4096, len(stream)} {
+		s := NewSplitter()
+		cuts := split(s, stream, n)
+		if s.Format() != Zip {
+			t.Fatalf("Detected %v", s.Format())
+		}
+		if n < 30 && len(cuts) != 2*len(members) {
+			t.Errorf("In pieces of %d: expected offsets for %d members, got %v", n, len(members), cuts)
+		}
+		checkMembers(t, "zip", stream, cuts)
+	}
+
+	// Members written with data descriptors cannot be followed
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, _ := w.Create("streamed")
+	f.Write(members[1])
+	w.Close()
+	if cuts := split(NewSplitter(), buf.Bytes(), 512); len(cuts) != 0 {
+		t.Errorf("Expected no offsets for a zip with data descriptors, got %v", cuts)
+	}
+}
+
+func TestSplitOtherData(t *testing.T) {
+	tests := map[string][]byte{
+		"text":     bytes.Repeat([]byte("not an archive\n"), 100),
+		"zeros":    make([]byte, 2048),
+		"short":    []byte("PK"),
+		"bad tar":  append(bytes.Repeat([]byte("a"), 512), make([]byte, 512)...),
+		"gzip tar": {0x1f, 0x8b, 8, 0, 0, 0, 0, 0, 0, 3},
+	}
+	for name, stream := range tests {
+		s := NewSplitter()
+		if cuts := split(s, stream, 64); len(cuts) != 0 || s.Format() == Tar || s.Format() == Zip {
+			t.Errorf("%s: expected no archive, got %v with offsets %v", name, s.Format(), cuts)
+		}
+	}
+}
+
+func TestSplitterStateRoundTrip(t *testing.T) {
+	streams := map[string][]byte{
+		"pax": tarStream(t, tar.FormatPAX),
+		"zip": zipStream(t),
+	}
+	for name, stream := range streams {
+		want := split(NewSplitter(), stream, 100)
+		for _, at := range []int{300, 700, 1100, 6000, len(stream) - 100} {
+			// Splitting stops after the piece holding at and continues on
+			// a splitter restored from its state
+			first := NewSplitter()
+			cuts := split(first, stream[:at], 100)
+			state, err := first.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var restored Splitter
+			if err := restored.UnmarshalBinary(state); err != nil {
+				t.Fatalf("%s at %d: %v", name, at, err)
+			}
+			if restored.Offset() != int64(at) {
+				t.Errorf("%s at %d: restored at offset %d", name, at, restored.Offset())
+			}
+			for _, cut := range split(&restored, stream[at:], 100) {
+				cuts = append(cuts, int64(at)+cut)
+			}
+			if !slices.Equal(cuts, want) {
+				t.Errorf("%s at %d: got offsets %v, expected %v", name, at, cuts, want)
+			}
+		}
+	}
+
+	state, _ := NewSplitter().MarshalBinary()
+	for _, b := range [][]byte{nil, {2}, state[:len(state)-1], append(state, 0)} {
+		if err := new(Splitter).UnmarshalBinary(b); err == nil {
+			t.Errorf("Expected state %x to be rejected", b)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package archive
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// Tar header fields
+const (
+	tarSizeOffset     = 124
+	tarSizeLength     = 12
+	tarChecksumOffset = 148
+	tarTypeOffset     = 156
+)
+
+// parseTar handles a tar header block, or the contents of a PAX extended
+// header gathered after one
+func (s *Splitter) parseTar() {
+	if s.pax {
+		s.paxSize = paxSize(s.header, s.paxSize)
+		s.pax = false
+		s.next = s.pos + padding(int64(len(s.header))) - int64(len(s.header))
+		s.header = s.header[:0]
+		s.need = blockSize
+		return
+	}
+
+	block := s.header
+	if isZero(block) {
+		s.stop() // end of archive
+		return
+	}
+	size, ok := parseNumber(block[tarSizeOffset : tarSizeOffset+tarSizeLength])
+	if !ok || !validChecksum(block) {
+		s.stop()
+		return
+	}
+	s.header = s.header[:0]
+	s.need = blockSize
+
+	switch block[tarTypeOffset] {
+	case 'x':
+		// The contents hold records that may override the next member's size
+		if size > maxExtendedHeader {
+			s.stop()
+			return
+		}
+		if size > 0 {
+			s.pax = true
+			s.need = int(size)
+		}
+		return
+	case 'g':
+		// Global extended header
+		s.next = s.pos + padding(size)
+		return
+	case '0', 0, '7':
+		if s.paxSize >= 0 {
+			size = s.paxSize
+		}
+		s.member(size)
+	case '1', '2', '3', '4', '5', '6':
+		size = 0 // these types have no contents whatever their size says
+	case 'S':
+		// Old GNU sparse members may continue their header in further
+		// blocks, which are not followed
+		s.stop()
+		return
+	}
+	// Long names of GNU tar and unknown types are skipped by their size
+	s.paxSize = -1
+	s.next = s.pos + padding(size)
+}
+
+// padding rounds size up to whole blocks
+func padding(size int64) int64 {
+	return (size + blockSize - 1) / blockSize * blockSize
+}
+
+// validChecksum reports whether a header block has the checksum it records,
+// computed over unsigned or, as some old tars did, signed bytes
+func validChecksum(block []byte) bool {
+	recorded, ok := parseNumber(block[tarChecksumOffset:tarTypeOffset])
+	if !ok {
+		return false
+	}
+	var unsigned, signed int64
+	for i, c := range block {
+		if i >= tarChecksumOffset && i < tarTypeOffset {
+			c = ' '
+		}
+		unsigned += int64(c)
+		signed += int64(int8(c))
+	}
+	return recorded == unsigned || recorded == signed
+}
+
+// parseNumber parses a numeric header field, octal or in the base-256 form
+// GNU tar uses for large values
+func parseNumber(field []byte) (int64, bool) {
+	if len(field) > 0 && field[0]&0x80 != 0 {
+		if field[0]&0x40 != 0 {
+			return 0, false // negative
+		}
+		var n int64
+		for i, c := range field {
+			if i == 0 {
+				c &= 0x7f
+			}
+			if n > (1<<63-1)>>8 {
+				return 0, false
+			}
+			n = n<<8 | int64(c)
+		}
+		return n, true
+	}
+	text := string(bytes.Trim(field, " \x00"))
+	if text == "" {
+		return 0, true
+	}
+	n, err := strconv.ParseInt(text, 8, 64)
+	return n, err == nil && n >= 0
+}
+
+// paxSize returns the size recorded in the contents of a PAX extended
+// header, or size if it records none. Records have the form
+// "<length> <key>=<value>\n".
+func paxSize(records []byte, size int64) int64 {
+	for len(records) > 0 {
+		space := bytes.IndexByte(records, ' ')
+		if space < 0 {
+			break
+		}
+		length, err := strconv.Atoi(string(records[:space]))
+		if err != nil || length <= space || length > len(records) {
+			break
+		}
+		record := records[space+1 : length]
+		records = records[length:]
+		key, value, ok := bytes.Cut(bytes.TrimSuffix(record, []byte("\n")), []byte("="))
+		if !ok || string(key) != "size" {
+			continue
+		}
+		if n, err := strconv.ParseInt(string(value), 10, 64); err == nil && n >= 0 {
+			size = n
+		}
+	}
+	return size
+}
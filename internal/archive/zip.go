@@ -0,0 +1,82 @@
+package archive
+
+import "bytes"
+
+// Zip local file header fields
+const (
+	zipFlagsOffset          = 6
+	zipCompressedOffset     = 18
+	zipUncompressedOffset   = 22
+	zipNameLengthOffset     = 26
+	zipExtraLengthOffset    = 28
+	zipDataDescriptorFlag   = 0x8
+	zip64ExtraID            = 0x0001
+	zipUnknownSize          = 0xffffffff
+	zipExtraRecordHeaderLen = 4
+)
+
+// parseZip handles a zip local file header. The stream is followed until a
+// member whose size is only given after its contents, in a data descriptor,
+// or until the central directory. Compressed members are split out too, but
+// only stored ones can share chunks with copies outside the archive.
+func (s *Splitter) parseZip() {
+	header := s.header
+	if !bytes.Equal(header[:len(zipLocalHeader)], zipLocalHeader) {
+		s.stop() // central directory
+		return
+	}
+	if le16(header[zipFlagsOffset:])&zipDataDescriptorFlag != 0 {
+		s.stop()
+		return
+	}
+	variable := int(le16(header[zipNameLengthOffset:]) + le16(header[zipExtraLengthOffset:]))
+	if s.need == zipHeaderSize && variable > 0 {
+		s.need += variable // gather the name and extra fields too
+		return
+	}
+
+	size := le32(header[zipCompressedOffset:])
+	if size == zipUnknownSize {
+		var ok bool
+		if size, ok = zip64Size(header); !ok {
+			s.stop()
+			return
+		}
+	}
+	s.header = s.header[:0]
+	s.need = zipHeaderSize
+	s.member(size)
+	s.next = s.pos + size
+}
+
+// zip64Size returns the compressed size from the ZIP64 extra field of a
+// local file header. The field holds the uncompressed size first when the
+// header does not.
+func zip64Size(header []byte) (int64, bool) {
+	nameLength := le16(header[zipNameLengthOffset:])
+	extra := header[zipHeaderSize+nameLength:]
+	for len(extra) >= zipExtraRecordHeaderLen {
+		id, length := le16(extra), int(le16(extra[2:]))
+		data := extra[zipExtraRecordHeaderLen:]
+		if length > len(data) {
+			return 0, false
+		}
+		data = data[:length]
+		extra = extra[zipExtraRecordHeaderLen+length:]
+		if id != zip64ExtraID {
+			continue
+		}
+		if le32(header[zipUncompressedOffset:]) == zipUnknownSize {
+			if len(data) < 8 {
+				return 0, false
+			}
+			data = data[8:]
+		}
+		if len(data) < 8 {
+			return 0, false
+		}
+		size := int64(le64(data))
+		return size, size >= 0
+	}
+	return 0, false
+}
@@ -2,30 +2,36 @@ package cache
 
 import (
 	"container/list"
+	"encoding/binary"
 	"sync"
 	"time"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
 )
 
 // ChunkMetadata represents metadata for a chunk
 type ChunkMetadata struct {
-	Fingerprint        string
+	Fingerprint        chunking.Fingerprint
 	StorageLocation    string
 	Size               int64
 	CreationTime       time.Time
 	LastReferencedTime time.Time
+	StorageNodes       []string             // Storage nodes holding a replica of the chunk
+	BaseFingerprint    chunking.Fingerprint // Chunk this one is stored as a delta against, if any
+	DeltaDepth         int                  // Deltas applied to rebuild the chunk; 0 if stored whole
 }
 
 // LRUCache implements a thread-safe LRU cache for chunk metadata
 type LRUCache struct {
 	capacity int
-	cache    map[string]*list.Element
+	cache    map[chunking.Fingerprint]*list.Element
 	list     *list.List
 	mutex    sync.RWMutex
 }
 
 // cacheEntry represents an entry in the LRU cache
 type cacheEntry struct {
-	key   string
+	key   chunking.Fingerprint
 	value *ChunkMetadata
 }
 
@@ -33,13 +39,13 @@ type cacheEntry struct {
 func NewLRUCache(capacity int) *LRUCache {
 	return &LRUCache{
 		capacity: capacity,
-		cache:    make(map[string]*list.Element),
+		cache:    make(map[chunking.Fingerprint]*list.Element),
 		list:     list.New(),
 	}
 }
 
 // Get retrieves a value from the cache
-func (c *LRUCache) Get(key string) (*ChunkMetadata, bool) {
+func (c *LRUCache) Get(key chunking.Fingerprint) (*ChunkMetadata, bool) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
@@ -53,7 +59,7 @@ func (c *LRUCache) Get(key string) (*ChunkMetadata, bool) {
 }
 
 // Put adds a value to the cache
-func (c *LRUCache) Put(key string, value *ChunkMetadata) {
+func (c *LRUCache) Put(key chunking.Fingerprint, value *ChunkMetadata) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
@@ -83,7 +89,7 @@ func (c *LRUCache) Put(key string, value *ChunkMetadata) {
 }
 
 // Remove removes a key from the cache
-func (c *LRUCache) Remove(key string) bool {
+func (c *LRUCache) Remove(key chunking.Fingerprint) bool {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
@@ -106,7 +112,7 @@ func (c *LRUCache) Size() int {
 func (c *LRUCache) Clear() {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	c.cache = make(map[string]*list.Element)
+	c.cache = make(map[chunking.Fingerprint]*list.Element)
 	c.list.Init()
 }
 
@@ -134,7 +140,7 @@ func NewSimpleCuckooFilter(capacity int) *SimpleCuckooFilter {
 }
 
 // Add adds a fingerprint to the filter
-func (cf *SimpleCuckooFilter) Add(fingerprint string) bool {
+func (cf *SimpleCuckooFilter) Add(fingerprint chunking.Fingerprint) bool {
 	cf.mutex.Lock()
 	defer cf.mutex.Unlock()
 
@@ -148,7 +154,7 @@ func (cf *SimpleCuckooFilter) Add(fingerprint string) bool {
 }
 
 // Contains checks if a fingerprint might be in the filter
-func (cf *SimpleCuckooFilter) Contains(fingerprint string) bool {
+func (cf *SimpleCuckooFilter) Contains(fingerprint chunking.Fingerprint) bool {
 	cf.mutex.RLock()
 	defer cf.mutex.RUnlock()
 
@@ -159,7 +165,7 @@ func (cf *SimpleCuckooFilter) Contains(fingerprint string) bool {
 }
 
 // Remove removes a fingerprint from the filter
-func (cf *SimpleCuckooFilter) Remove(fingerprint string) bool {
+func (cf *SimpleCuckooFilter) Remove(fingerprint chunking.Fingerprint) bool {
 	cf.mutex.Lock()
 	defer cf.mutex.Unlock()
 
@@ -173,13 +179,11 @@ func (cf *SimpleCuckooFilter) Remove(fingerprint string) bool {
 	return false
 }
 
-// hashFingerprint creates a simple hash from a fingerprint string
-func (cf *SimpleCuckooFilter) hashFingerprint(fingerprint string) uint64 {
-	var hash uint64
-	for i, char := range fingerprint {
-		hash += uint64(char) * uint64(i+1)
-	}
-	return hash
+// hashFingerprint takes the first 8 bytes of a fingerprint's digest, which
+// are already uniformly distributed
+func (cf *SimpleCuckooFilter) hashFingerprint(fingerprint chunking.Fingerprint) uint64 {
+	digest := fingerprint.Digest()
+	return binary.LittleEndian.Uint64(digest[:8])
 }
 
 // DeduplicationCache combines LRU cache and Cuckoo filter for efficient deduplication
@@ -198,23 +202,23 @@ func NewDeduplicationCache(cacheCapacity, filterCapacity int) *DeduplicationCach
 }
 
 // GetChunkMetadata retrieves chunk metadata from the cache
-func (dc *DeduplicationCache) GetChunkMetadata(fingerprint string) (*ChunkMetadata, bool) {
+func (dc *DeduplicationCache) GetChunkMetadata(fingerprint chunking.Fingerprint) (*ChunkMetadata, bool) {
 	return dc.lruCache.Get(fingerprint)
 }
 
 // PutChunkMetadata adds chunk metadata to the cache
-func (dc *DeduplicationCache) PutChunkMetadata(fingerprint string, metadata *ChunkMetadata) {
+func (dc *DeduplicationCache) PutChunkMetadata(fingerprint chunking.Fingerprint, metadata *ChunkMetadata) {
 	dc.lruCache.Put(fingerprint, metadata)
 	dc.cuckooFilter.Add(fingerprint)
 }
 
 // MightContain checks if a fingerprint might be in the cache (fast check)
-func (dc *DeduplicationCache) MightContain(fingerprint string) bool {
+func (dc *DeduplicationCache) MightContain(fingerprint chunking.Fingerprint) bool {
 	return dc.cuckooFilter.Contains(fingerprint)
 }
 
 // RemoveChunkMetadata removes chunk metadata from the cache
-func (dc *DeduplicationCache) RemoveChunkMetadata(fingerprint string) bool {
+func (dc *DeduplicationCache) RemoveChunkMetadata(fingerprint chunking.Fingerprint) bool {
 	dc.cuckooFilter.Remove(fingerprint)
 	return dc.lruCache.Remove(fingerprint)
 }
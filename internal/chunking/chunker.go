@@ -3,136 +3,178 @@ package chunking
 import (
 	"crypto/rand"
 	"fmt"
-	"hash"
 	"io"
-
-	"github.com/zeebo/blake3"
+	"math/bits"
 )
 
 // Chunk represents a data chunk with its fingerprint and metadata
 type Chunk struct {
 	Data        []byte
-	Fingerprint string
+	Fingerprint Fingerprint
 	Offset      int64
 	Size        int64
 }
 
-// Chunker implements variable-block chunking using Rabin fingerprinting
+// Chunker implements variable-block chunking using Rabin fingerprinting, or
+// fixed-size or legacy pattern chunking when its profile says so. It keeps no state between
+// calls, so once configured one Chunker may split and hash data on several
+// goroutines at once.
 type Chunker struct {
-	minSize    int
-	maxSize    int
-	windowSize int
-	polynomial uint64
-	hasher     hash.Hash
+	profile Profile
+	rabin   *rabinTables // nil for fixed-size chunking
+	mask    uint64       // fingerprint bits that must all be set at a boundary
 }
 
-// NewChunker creates a new chunker with the specified parameters
+// NewChunker creates a new chunker with the specified parameters and the
+// rest of the default profile. Its profile has no version.
 func NewChunker(minSize, maxSize int) *Chunker {
-	return &Chunker{
-		minSize:    minSize,
-		maxSize:    maxSize,
-		windowSize: 64,                 // Rabin window size
-		polynomial: 0x3A335D566E6B7E5B, // Common irreducible polynomial
-		hasher:     blake3.New(),
+	profile := DefaultProfile()
+	profile.Version = 0
+	profile.MinSize = minSize
+	profile.MaxSize = maxSize
+	profile.AvgSize = max(minSize, min(profile.AvgSize, maxSize))
+	profile.WindowSize = max(1, min(profile.WindowSize, minSize))
+	return newChunker(profile)
+}
+
+// NewDefaultChunker creates a chunker following the default profile
+func NewDefaultChunker() *Chunker {
+	return newChunker(DefaultProfile())
+}
+
+// NewProfileChunker creates a chunker that splits and fingerprints data as a
+// profile says
+func NewProfileChunker(profile Profile) (*Chunker, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid chunking profile: %w", err)
+	}
+	return newChunker(profile), nil
+}
+
+// newChunker creates a chunker for a valid profile. Rabin boundaries are
+// expected every AvgSize-MinSize bytes past the minimum, rounded up to a
+// power of two.
+func newChunker(profile Profile) *Chunker {
+	c := &Chunker{profile: profile}
+	if profile.Algorithm == AlgorithmRabin {
+		c.rabin = newRabinTables(profile.Polynomial, profile.WindowSize)
+		if spread := profile.AvgSize - profile.MinSize; spread > 1 {
+			c.mask = 1<<bits.Len(uint(spread-1)) - 1
+		}
 	}
+	return c
+}
+
+// Profile returns the profile the chunker follows
+func (c *Chunker) Profile() Profile {
+	return c.profile
+}
+
+// SetHashAlgorithm sets the algorithm chunk fingerprints are computed with
+func (c *Chunker) SetHashAlgorithm(algorithm HashAlgorithm) {
+	c.profile.Hash = algorithm
+}
+
+// HashAlgorithm returns the algorithm chunk fingerprints are computed with
+func (c *Chunker) HashAlgorithm() HashAlgorithm {
+	return c.profile.Hash
 }
 
 // ChunkData splits data into chunks using Rabin fingerprinting
 func (c *Chunker) ChunkData(data []byte) ([]Chunk, error) {
+	chunks := c.Split(data)
+	for i := range chunks {
+		chunks[i].Fingerprint = c.profile.Hash.Sum(chunks[i].Data)
+	}
+	return chunks, nil
+}
+
+// Split finds the chunk boundaries of data without hashing the chunks, whose
+// fingerprints are left zero. It lets callers hash chunks elsewhere, such as
+// on a pool of workers.
+func (c *Chunker) Split(data []byte) []Chunk {
 	var chunks []Chunk
 	offset := int64(0)
 
 	for len(data) > 0 {
-		chunkSize := c.findChunkBoundary(data)
-		if chunkSize == 0 {
-			chunkSize = len(data) // Use remaining data
-		}
-
-		chunkData := data[:chunkSize]
-		fingerprint, err := c.computeFingerprint(chunkData)
-		if err != nil {
-			return nil, err
-		}
-
+		chunkSize := c.Boundary(data)
 		chunks = append(chunks, Chunk{
-			Data:        chunkData,
-			Fingerprint: fingerprint,
-			Offset:      offset,
-			Size:        int64(chunkSize),
+			Data:   data[:chunkSize],
+			Offset: offset,
+			Size:   int64(chunkSize),
 		})
 
 		data = data[chunkSize:]
 		offset += int64(chunkSize)
 	}
 
-	return chunks, nil
+	return chunks
+}
+
+// Boundary returns the size of the first chunk of data. A chunk boundary
+// depends only on the data before it, so chunks can be handed on one at a
+// time as they are found.
+func (c *Chunker) Boundary(data []byte) int {
+	if c.profile.Algorithm == AlgorithmFixed {
+		return min(len(data), c.profile.AvgSize)
+	}
+	size := 0
+	if c.profile.Algorithm == AlgorithmPattern {
+		size = c.findPatternBoundary(data)
+	} else {
+		size = c.findChunkBoundary(data)
+	}
+	if size > 0 {
+		return size
+	}
+	return len(data) // Use remaining data
 }
 
-// findChunkBoundary finds the optimal chunk boundary using Rabin fingerprinting
+// findChunkBoundary finds the first position past the minimum chunk size
+// where the Rabin fingerprint of the window before it has all mask bits set,
+// or the maximum chunk size if there is none
 func (c *Chunker) findChunkBoundary(data []byte) int {
-	if len(data) < c.minSize {
+	if len(data) < c.profile.MinSize {
 		return 0 // Not enough data
 	}
+	limit := min(len(data), c.profile.MaxSize)
+	window := c.profile.WindowSize
 
-	// Use a simple approach for now - find boundary based on content
-	// In a real implementation, you'd use Rabin fingerprinting
-	maxSize := c.maxSize
-	if len(data) < maxSize {
-		maxSize = len(data)
+	var hash uint64
+	for _, b := range data[c.profile.MinSize-window : c.profile.MinSize] {
+		hash = c.rabin.append(hash, b)
 	}
-
-	// Simple boundary detection - look for patterns
-	for i := c.minSize; i < maxSize; i++ {
-		if c.isBoundary(data[:i]) {
+	for i := c.profile.MinSize; i < limit; i++ {
+		if hash&c.mask == c.mask {
 			return i
 		}
+		hash = c.rabin.append(hash^c.rabin.out[data[i-window]], data[i])
 	}
-
-	return maxSize
+	return limit
 }
 
-// isBoundary determines if a position is a good chunk boundary
-func (c *Chunker) isBoundary(data []byte) bool {
-	if len(data) < 4 {
-		return false
+// findPatternBoundary finds the first position past the minimum chunk size
+// that follows four zero, 0xFF or newline bytes, or the maximum chunk size if
+// there is none
+func (c *Chunker) findPatternBoundary(data []byte) int {
+	if len(data) < c.profile.MinSize {
+		return 0 // Not enough data
 	}
-
-	// Simple boundary detection - look for specific patterns
-	// This is a simplified version; real Rabin fingerprinting would be more sophisticated
-	lastBytes := data[len(data)-4:]
-
-	// Check for common patterns that indicate good boundaries
-	for _, pattern := range [][]byte{
-		{0x00, 0x00, 0x00, 0x00}, // Null bytes
-		{0xFF, 0xFF, 0xFF, 0xFF}, // All ones
-		{0x0A, 0x0A, 0x0A, 0x0A}, // Newlines
-	} {
-		if bytesEqual(lastBytes, pattern) {
-			return true
+	limit := min(len(data), c.profile.MaxSize)
+	for i := max(c.profile.MinSize, 4); i < limit; i++ {
+		if b := data[i-1]; (b == 0x00 || b == 0xFF || b == 0x0A) &&
+			data[i-2] == b && data[i-3] == b && data[i-4] == b {
+			return i
 		}
 	}
-
-	return false
-}
-
-// computeFingerprint computes the Blake3 hash of the chunk data
-func (c *Chunker) computeFingerprint(data []byte) (string, error) {
-	c.hasher.Reset()
-	_, err := c.hasher.Write(data)
-	if err != nil {
-		return "", err
-	}
-
-	// Get the hash as a hex string
-	hash := c.hasher.Sum(nil)
-	return fmt.Sprintf("%x", hash), nil
+	return limit
 }
 
 // ChunkFile chunks a file by reading it in blocks
 func (c *Chunker) ChunkFile(reader io.Reader) ([]Chunk, error) {
 	var chunks []Chunk
 	offset := int64(0)
-	buffer := make([]byte, c.maxSize)
+	buffer := make([]byte, c.profile.MaxSize)
 
 	for {
 		n, err := reader.Read(buffer)
@@ -165,19 +207,6 @@ func (c *Chunker) ChunkFile(reader io.Reader) ([]Chunk, error) {
 	return chunks, nil
 }
 
-// bytesEqual compares two byte slices for equality
-func bytesEqual(a, b []byte) bool {
-	if len(a) != len(b) {
-		return false
-	}
-	for i := range a {
-		if a[i] != b[i] {
-			return false
-		}
-	}
-	return true
-}
-
 // GenerateRandomChunk generates a random chunk for testing
 func GenerateRandomChunk(size int) ([]byte, error) {
 	data := make([]byte, size)
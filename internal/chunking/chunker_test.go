@@ -1,8 +1,11 @@
 package chunking
 
 import (
+	"math/rand"
 	"strings"
+	"sync"
 	"testing"
+	"unsafe"
 )
 
 func TestChunker(t *testing.T) {
@@ -22,7 +25,7 @@ func TestChunker(t *testing.T) {
 
 	// Verify each chunk has a fingerprint
 	for i, chunk := range chunks {
-		if chunk.Fingerprint == "" {
+		if chunk.Fingerprint.IsZero() {
 			t.Errorf("Chunk %d has empty fingerprint", i)
 		}
 		if len(chunk.Data) == 0 {
@@ -32,6 +35,40 @@ func TestChunker(t *testing.T) {
 	}
 }
 
+func TestSplitConcurrently(t *testing.T) {
+	chunker := NewChunker(64, 1024)
+	chunker.SetHashAlgorithm(SHA256)
+	data := make([]byte, 64*1024)
+	rand.New(rand.NewSource(3)).Read(data)
+	want, err := chunker.ChunkData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One chunker splits and hashes on several goroutines at once
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			chunks := chunker.Split(data)
+			if len(chunks) != len(want) {
+				t.Errorf("Split found %d chunks, ChunkData %d", len(chunks), len(want))
+				return
+			}
+			for i, chunk := range chunks {
+				if !chunk.Fingerprint.IsZero() {
+					t.Errorf("Split hashed chunk %d", i)
+				}
+				if chunk.Offset != want[i].Offset || chunker.HashAlgorithm().Sum(chunk.Data) != want[i].Fingerprint {
+					t.Errorf("Chunk %d differs from ChunkData", i)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 func TestChunkFile(t *testing.T) {
 	chunker := NewChunker(1024, 8192)
 
@@ -51,6 +88,30 @@ func TestChunkFile(t *testing.T) {
 	t.Logf("Created %d chunks from test data", len(chunks))
 }
 
+func TestComputeFingerprint(t *testing.T) {
+	chunker := NewChunker(1024, 8192)
+
+	data, err := GenerateRandomChunk(4096)
+	if err != nil {
+		t.Fatalf("Failed to generate random chunk: %v", err)
+	}
+
+	chunks, err := chunker.ChunkData(data)
+	if err != nil {
+		t.Fatalf("Failed to chunk data: %v", err)
+	}
+
+	for i, chunk := range chunks {
+		if got := ComputeFingerprint(chunk.Data); got != chunk.Fingerprint {
+			t.Errorf("Chunk %d: expected fingerprint %s, got %s", i, chunk.Fingerprint, got)
+		}
+	}
+
+	if ComputeFingerprint([]byte("a")) == ComputeFingerprint([]byte("b")) {
+		t.Error("Expected different data to have different fingerprints")
+	}
+}
+
 func TestGenerateRandomChunk(t *testing.T) {
 	size := 1024
 	data, err := GenerateRandomChunk(size)
@@ -74,3 +135,163 @@ func TestGenerateRandomChunk(t *testing.T) {
 		t.Error("Generated chunk is all zeros")
 	}
 }
+
+func TestFileHash(t *testing.T) {
+	sum := func(add func(h *FileHash)) string {
+		h := NewFileHash()
+		add(h)
+		return h.Sum()
+	}
+	a, b := ComputeFingerprint([]byte("a")).String(), ComputeFingerprint([]byte("b")).String()
+
+	whole := sum(func(h *FileHash) { h.AddChunk(a); h.AddHole(100); h.AddChunk(b) })
+	split := sum(func(h *FileHash) { h.AddChunk(a); h.AddHole(40); h.AddHole(60); h.AddChunk(b) })
+	if whole != split {
+		t.Error("Expected adjacent holes to hash as one")
+	}
+	if whole == sum(func(h *FileHash) { h.AddChunk(b); h.AddHole(100); h.AddChunk(a) }) {
+		t.Error("Expected chunk order to change the hash")
+	}
+	if whole == sum(func(h *FileHash) { h.AddChunk(a); h.AddHole(101); h.AddChunk(b) }) {
+		t.Error("Expected the hole size to change the hash")
+	}
+	if sum(func(h *FileHash) { h.AddChunk(a) }) == sum(func(h *FileHash) { h.AddChunk(a); h.AddHole(1) }) {
+		t.Error("Expected a trailing hole to change the hash")
+	}
+}
+
+func TestSuperFeatures(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	data := make([]byte, 8192)
+	rng.Read(data)
+
+	// A few bytes changed keep at least one super-feature
+	edited := append([]byte(nil), data...)
+	copy(edited[3000:], "a small edit")
+	other := make([]byte, 8192)
+	rng.Read(other)
+
+	features, ok := ComputeSuperFeatures(data)
+	if !ok {
+		t.Fatal("Expected super-features for an 8KB chunk")
+	}
+	editedFeatures, _ := ComputeSuperFeatures(edited)
+	otherFeatures, _ := ComputeSuperFeatures(other)
+
+	shared := func(a, b SuperFeatures) int {
+		n := 0
+		for i := range a {
+			if a[i] == b[i] {
+				n++
+			}
+		}
+		return n
+	}
+	if shared(features, editedFeatures) == 0 {
+		t.Errorf("Expected a near-duplicate to share a super-feature: %x and %x", features, editedFeatures)
+	}
+	if shared(features, otherFeatures) != 0 {
+		t.Errorf("Expected unrelated chunks to share no super-feature: %x and %x", features, otherFeatures)
+	}
+	if _, ok := ComputeSuperFeatures(data[:MinSimilarChunkSize-1]); ok {
+		t.Error("Expected no super-features for a chunk below the minimum size")
+	}
+}
+
+func TestHashAlgorithms(t *testing.T) {
+	data := []byte("fingerprint me")
+	tests := []struct {
+		name   string
+		prefix string
+		length int
+	}{
+		{"blake3", "", 64},
+		{"sha256", "1220", 68},
+		{"blake3-512", "1e40", 132},
+	}
+	for _, tt := range tests {
+		algorithm, err := ParseHashAlgorithm(tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if algorithm.String() != tt.name {
+			t.Errorf("%s: parsed as %s", tt.name, algorithm)
+		}
+		fingerprint := algorithm.Sum(data)
+		text := fingerprint.String()
+		if len(text) != tt.length || !strings.HasPrefix(text, tt.prefix) {
+			t.Errorf("%s: unexpected fingerprint %s", tt.name, text)
+		}
+		if parsed, err := ParseFingerprint(text); err != nil || parsed != fingerprint || parsed.Algorithm() != algorithm {
+			t.Errorf("%s: fingerprint parsed as %v (%v)", tt.name, parsed, err)
+		}
+		if parsed, err := FingerprintFromBytes(fingerprint.Bytes()); err != nil || parsed != fingerprint {
+			t.Errorf("%s: binary form parsed as %v (%v)", tt.name, parsed, err)
+		}
+		if !VerifyFingerprint(data, fingerprint) || VerifyFingerprint([]byte("other data"), fingerprint) {
+			t.Errorf("%s: fingerprint does not verify its data alone", tt.name)
+		}
+
+		chunker := NewChunker(1024, 8192)
+		chunker.SetHashAlgorithm(algorithm)
+		chunks, _ := chunker.ChunkData(data)
+		if chunks[0].Fingerprint != fingerprint {
+			t.Errorf("%s: chunker assigned %s", tt.name, chunks[0].Fingerprint)
+		}
+	}
+
+	if ComputeFingerprint(data) != Blake3.Sum(data) {
+		t.Error("Expected ComputeFingerprint to use Blake3")
+	}
+	if a, _ := ParseHashAlgorithm(""); a != Blake3 {
+		t.Errorf("Expected an unset algorithm to be Blake3, got %v", a)
+	}
+	if _, err := ParseHashAlgorithm("md5"); err == nil {
+		t.Error("Expected an unknown algorithm to be rejected")
+	}
+	for _, s := range []string{"", "1220", "xyz", strings.Repeat("A", 64), "1230" + strings.Repeat("a", 64), "1e40" + strings.Repeat("a", 64), "1220" + strings.Repeat("a", 128)} {
+		if _, err := ParseFingerprint(s); err == nil {
+			t.Errorf("Expected %q not to be a fingerprint", s)
+		}
+	}
+}
+
+func TestFingerprintText(t *testing.T) {
+	fingerprint := SHA256.Sum([]byte("text"))
+	text, err := fingerprint.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed Fingerprint
+	if err := parsed.UnmarshalText(text); err != nil || parsed != fingerprint {
+		t.Errorf("Round trip of %s gave %s (%v)", text, parsed, err)
+	}
+	if len(fingerprint.Short()) != 16 || !strings.Contains(fingerprint.String(), fingerprint.Short()) {
+		t.Errorf("Unexpected short form %s of %s", fingerprint.Short(), fingerprint)
+	}
+	if !(Fingerprint{}).IsZero() || fingerprint.IsZero() {
+		t.Error("Expected only the zero value to be zero")
+	}
+}
+
+func TestFingerprintSize(t *testing.T) {
+	if size := unsafe.Sizeof(Fingerprint{}); size > 40 {
+		t.Errorf("Expected a fingerprint to take at most 40 bytes, got %d", size)
+	}
+
+	// 512-bit fingerprints sharing the first half of their digest differ
+	var low, high [32]byte
+	a := newFingerprint(Blake3_512, low, high)
+	high[31] = 1
+	b := newFingerprint(Blake3_512, low, high)
+	if a == b || a.String() == b.String() {
+		t.Errorf("Expected %s and %s to differ", a, b)
+	}
+	if c := newFingerprint(Blake3_512, low, high); c != b {
+		t.Errorf("Expected equal digests to give equal fingerprints, got %s and %s", b, c)
+	}
+	// As do fingerprints of different algorithms with the same digest
+	if newFingerprint(SHA256, low, [32]byte{}) == (Fingerprint{digest: low}) {
+		t.Error("Expected a SHA-256 fingerprint to differ from a Blake3 one with the same digest")
+	}
+}
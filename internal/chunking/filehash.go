@@ -0,0 +1,53 @@
+package chunking
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/zeebo/blake3"
+)
+
+// FileHash computes the whole-file hash recorded in manifests: a Blake3 hash
+// over the fingerprint of every chunk and the size of every hole of a file,
+// in file order. Each fingerprint is a hash of its chunk's data, so the
+// result covers every byte of the file, yet the Ingest Node can compute it
+// from a manifest without reading any chunks. Adjacent holes are hashed as
+// one, so it does not depend on how zero runs were split.
+type FileHash struct {
+	hasher *blake3.Hasher
+	hole   uint64 // size of the hole being added
+}
+
+// NewFileHash creates an empty file hash
+func NewFileHash() *FileHash {
+	return &FileHash{hasher: blake3.New()}
+}
+
+// AddChunk adds the next chunk of the file by the text form of its fingerprint
+func (h *FileHash) AddChunk(fingerprint string) {
+	h.flushHole()
+	h.hasher.Write([]byte{'c'})
+	h.hasher.Write([]byte(fingerprint))
+}
+
+// AddHole adds a run of size zero bytes stored as a hole
+func (h *FileHash) AddHole(size uint64) {
+	h.hole += size
+}
+
+func (h *FileHash) flushHole() {
+	if h.hole == 0 {
+		return
+	}
+	var record [9]byte
+	record[0] = 'h'
+	binary.BigEndian.PutUint64(record[1:], h.hole)
+	h.hasher.Write(record[:])
+	h.hole = 0
+}
+
+// Sum returns the hash in hex
+func (h *FileHash) Sum() string {
+	h.flushHole()
+	return fmt.Sprintf("%x", h.hasher.Sum(nil))
+}
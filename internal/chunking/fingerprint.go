@@ -0,0 +1,208 @@
+package chunking
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unique"
+
+	"github.com/zeebo/blake3"
+)
+
+// HashAlgorithm is the hash chunk fingerprints are computed with. A
+// repository uses one algorithm for all of its chunks.
+//
+// Fingerprints other than Blake3 carry a multihash prefix: the algorithm's
+// multihash code and the digest length in bytes, each one byte, before the
+// digest. Blake3 fingerprints are the bare digest that repositories have
+// always used, so their chunks keep their keys.
+type HashAlgorithm uint8
+
+const (
+	Blake3     HashAlgorithm = iota // 256-bit Blake3, unprefixed
+	SHA256                          // SHA-256, prefix 1220
+	Blake3_512                      // 512-bit Blake3, prefix 1e40
+)
+
+// Multihash codes of the hash functions
+const (
+	multihashSHA256 = 0x12
+	multihashBlake3 = 0x1e
+)
+
+var hashAlgorithms = []struct {
+	name   string
+	code   byte // multihash code, 0 for no prefix
+	length int  // digest bytes
+}{
+	Blake3:     {"blake3", 0, 32},
+	SHA256:     {"sha256", multihashSHA256, 32},
+	Blake3_512: {"blake3-512", multihashBlake3, 64},
+}
+
+// ParseHashAlgorithm returns the algorithm with a name, as printed by String.
+// An empty name is Blake3, the algorithm of repositories created before the
+// choice existed.
+func ParseHashAlgorithm(name string) (HashAlgorithm, error) {
+	if name == "" {
+		return Blake3, nil
+	}
+	for a, algorithm := range hashAlgorithms {
+		if algorithm.name == strings.ToLower(name) {
+			return HashAlgorithm(a), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown hash algorithm %q, expected blake3, sha256 or blake3-512", name)
+}
+
+func (a HashAlgorithm) String() string {
+	if int(a) >= len(hashAlgorithms) {
+		return fmt.Sprintf("HashAlgorithm(%d)", int(a))
+	}
+	return hashAlgorithms[a].name
+}
+
+// Sum returns the fingerprint of data
+func (a HashAlgorithm) Sum(data []byte) Fingerprint {
+	switch a {
+	case SHA256:
+		return newFingerprint(SHA256, sha256.Sum256(data), [32]byte{})
+	case Blake3_512:
+		var digest [64]byte
+		h := blake3.New()
+		h.Write(data)
+		h.Digest().Read(digest[:])
+		return newFingerprint(Blake3_512, [32]byte(digest[:32]), [32]byte(digest[32:]))
+	default:
+		return Fingerprint{digest: blake3.Sum256(data)}
+	}
+}
+
+// Fingerprint identifies a chunk by the digest of its data and the algorithm
+// that computed it. It is a fixed-size value, so it can be compared and used
+// as a map key without allocating. A Blake3 fingerprint is its 32-byte digest
+// alone; other algorithms add a handle to an interned tag naming the
+// algorithm, which also holds the second half of a 512-bit digest. Its text
+// form is the hex of its binary form, which is the digest after the
+// algorithm's multihash prefix, if any.
+type Fingerprint struct {
+	digest [32]byte                      // the digest, or the first half of a 512-bit one
+	tag    unique.Handle[fingerprintTag] // zero for Blake3
+}
+
+// fingerprintTag is the part of a fingerprint other than Blake3 beyond its
+// first 32 digest bytes. Fingerprints of one 256-bit algorithm share a tag.
+type fingerprintTag struct {
+	algorithm HashAlgorithm
+	high      [32]byte // second half of a 512-bit digest
+}
+
+// newFingerprint returns a fingerprint of an algorithm whose digest is low
+// followed, for 512-bit digests, by high
+func newFingerprint(algorithm HashAlgorithm, low, high [32]byte) Fingerprint {
+	if algorithm == Blake3 {
+		return Fingerprint{digest: low}
+	}
+	return Fingerprint{digest: low, tag: unique.Make(fingerprintTag{algorithm: algorithm, high: high})}
+}
+
+// ComputeFingerprint returns the Blake3 fingerprint of data, as ChunkData
+// assigns to Chunk.Fingerprint by default
+func ComputeFingerprint(data []byte) Fingerprint {
+	return Blake3.Sum(data)
+}
+
+// VerifyFingerprint reports whether fingerprint is the fingerprint of data,
+// computed with the algorithm the fingerprint names
+func VerifyFingerprint(data []byte, fingerprint Fingerprint) bool {
+	return fingerprint.Algorithm().Sum(data) == fingerprint
+}
+
+// ParseFingerprint parses the text form of a fingerprint. Only the lowercase
+// form String returns is accepted, so a chunk has a single object key.
+func ParseFingerprint(s string) (Fingerprint, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return Fingerprint{}, fmt.Errorf("malformed fingerprint %q", s)
+	}
+	f, err := FingerprintFromBytes(b)
+	if err != nil || f.String() != s {
+		return Fingerprint{}, fmt.Errorf("malformed fingerprint %q", s)
+	}
+	return f, nil
+}
+
+// FingerprintFromBytes parses the binary form of a fingerprint
+func FingerprintFromBytes(b []byte) (Fingerprint, error) {
+	for a, algorithm := range hashAlgorithms {
+		digest := b
+		if algorithm.code != 0 {
+			if len(b) < 2 || b[0] != algorithm.code || int(b[1]) != algorithm.length {
+				continue
+			}
+			digest = b[2:]
+		}
+		if len(digest) == algorithm.length {
+			var high [32]byte
+			copy(high[:], digest[32:])
+			return newFingerprint(HashAlgorithm(a), [32]byte(digest[:32]), high), nil
+		}
+	}
+	return Fingerprint{}, fmt.Errorf("malformed %d-byte fingerprint", len(b))
+}
+
+// Algorithm returns the hash algorithm that computed the fingerprint
+func (f Fingerprint) Algorithm() HashAlgorithm {
+	if f.tag == (unique.Handle[fingerprintTag]{}) {
+		return Blake3
+	}
+	return f.tag.Value().algorithm
+}
+
+// Digest returns the digest of the fingerprint, as long as its algorithm's
+func (f Fingerprint) Digest() []byte {
+	digest := append([]byte(nil), f.digest[:]...)
+	if f.Algorithm() == Blake3_512 {
+		high := f.tag.Value().high
+		digest = append(digest, high[:]...)
+	}
+	return digest
+}
+
+// IsZero reports whether f is the zero value, which no chunk has
+func (f Fingerprint) IsZero() bool {
+	return f == Fingerprint{}
+}
+
+// Bytes returns the binary form of the fingerprint
+func (f Fingerprint) Bytes() []byte {
+	algorithm := hashAlgorithms[f.Algorithm()]
+	if algorithm.code != 0 {
+		return append([]byte{algorithm.code, byte(algorithm.length)}, f.Digest()...)
+	}
+	return f.Digest()
+}
+
+// String returns the text form of the fingerprint
+func (f Fingerprint) String() string {
+	return hex.EncodeToString(f.Bytes())
+}
+
+// Short returns the first 16 hex digits of the digest, for logs
+func (f Fingerprint) Short() string {
+	return hex.EncodeToString(f.digest[:8])
+}
+
+func (f Fingerprint) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+func (f *Fingerprint) UnmarshalText(text []byte) error {
+	parsed, err := ParseFingerprint(string(text))
+	if err != nil {
+		return err
+	}
+	*f = parsed
+	return nil
+}
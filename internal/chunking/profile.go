@@ -0,0 +1,173 @@
+package chunking
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Chunk boundary algorithms a profile may name
+const (
+	AlgorithmRabin = "rabin" // content-defined, by a Rabin fingerprint rolling over WindowSize bytes
+	AlgorithmFixed = "fixed" // blocks of one size, aligned to the start of the data
+
+	// AlgorithmPattern is the chunker of repositories from before profiles
+	// were recorded, kept so that they still deduplicate: past the minimum a
+	// chunk ends after four zero, 0xFF or newline bytes in a row
+	AlgorithmPattern = "pattern"
+)
+
+// Profile fixes how a repository splits data into chunks and fingerprints
+// them. Data only deduplicates against chunks made with the same profile, so
+// a repository keeps the profile it was created with until it is explicitly
+// migrated to a new one; chunking policies may pick other profiles for some
+// backups. Profiles are numbered by version, from 1.
+type Profile struct {
+	Version    int
+	Algorithm  string // chunk boundary algorithm
+	MinSize    int
+	AvgSize    int // chunk size the boundaries aim for on random data
+	MaxSize    int
+	WindowSize int           // bytes the rolling hash covers
+	Polynomial uint64        // Rabin polynomial, below an implicit x^64 term
+	Hash       HashAlgorithm // fingerprint hash
+}
+
+// DefaultProfile returns the profile a new repository records as version 1
+// unless its first ingest node is configured otherwise
+func DefaultProfile() Profile {
+	return Profile{
+		Version:    1,
+		Algorithm:  AlgorithmRabin,
+		MinSize:    64,
+		AvgSize:    4096,
+		MaxSize:    8192,
+		WindowSize: 64,
+		Polynomial: 0x3A335D566E6B7E5B,
+		Hash:       Blake3,
+	}
+}
+
+// LegacyProfile returns version 1 of a repository that stored chunks before
+// profiles were recorded, which were all cut by the pattern chunker
+func LegacyProfile() Profile {
+	return Profile{
+		Version:   1,
+		Algorithm: AlgorithmPattern,
+		MinSize:   64,
+		AvgSize:   8192, // runs of a byte are rare in random data
+		MaxSize:   8192,
+		Hash:      Blake3,
+	}
+}
+
+// Validate checks that a chunker can be built from the profile
+func (p Profile) Validate() error {
+	switch p.Algorithm {
+	case AlgorithmRabin:
+		if p.Polynomial == 0 {
+			return fmt.Errorf("rabin chunking needs a polynomial")
+		}
+		if p.WindowSize < 1 || p.WindowSize > p.MinSize {
+			return fmt.Errorf("window size must be between 1 and the minimum chunk size, got %d", p.WindowSize)
+		}
+	case AlgorithmFixed:
+		if p.MinSize != p.AvgSize || p.AvgSize != p.MaxSize {
+			return fmt.Errorf("fixed-size chunks have one size, got %d, %d, %d", p.MinSize, p.AvgSize, p.MaxSize)
+		}
+		if p.WindowSize != 0 || p.Polynomial != 0 {
+			return fmt.Errorf("fixed-size chunking takes no window or polynomial")
+		}
+	case AlgorithmPattern:
+		if p.WindowSize != 0 || p.Polynomial != 0 {
+			return fmt.Errorf("pattern chunking takes no window or polynomial")
+		}
+	default:
+		return fmt.Errorf("unknown chunking algorithm %q, expected %s or %s", p.Algorithm, AlgorithmRabin, AlgorithmFixed)
+	}
+	if p.MinSize < 1 || p.MinSize > p.AvgSize || p.AvgSize > p.MaxSize {
+		return fmt.Errorf("chunk sizes must satisfy 1 <= min <= avg <= max, got %d, %d, %d", p.MinSize, p.AvgSize, p.MaxSize)
+	}
+	if int(p.Hash) >= len(hashAlgorithms) {
+		return fmt.Errorf("unknown hash algorithm %v", p.Hash)
+	}
+	return nil
+}
+
+// SameChunks reports whether two profiles split and fingerprint data alike,
+// whatever their versions
+func (p Profile) SameChunks(other Profile) bool {
+	p.Version, other.Version = 0, 0
+	return p == other
+}
+
+// FixedProfile returns a profile of fixed-size chunks of size bytes
+func FixedProfile(size int, hash HashAlgorithm) Profile {
+	return Profile{Algorithm: AlgorithmFixed, MinSize: size, AvgSize: size, MaxSize: size, Hash: hash}
+}
+
+// RabinProfile returns a profile of content-defined chunks with the default
+// window and polynomial, narrowing the window to minSize if it is smaller
+func RabinProfile(minSize, avgSize, maxSize int, hash HashAlgorithm) Profile {
+	profile := DefaultProfile()
+	profile.Version = 0
+	profile.MinSize, profile.AvgSize, profile.MaxSize = minSize, avgSize, maxSize
+	profile.WindowSize = max(1, min(profile.WindowSize, minSize))
+	profile.Hash = hash
+	return profile
+}
+
+// ParseStrategy parses a chunking strategy, fixed:<size> or
+// rabin[:<min>:<avg>:<max>], into a profile without a version. A bare rabin
+// has the default sizes.
+func ParseStrategy(text string, hash HashAlgorithm) (Profile, error) {
+	fields := strings.Split(text, ":")
+	sizes := make([]int, len(fields)-1)
+	for i, field := range fields[1:] {
+		size, err := strconv.Atoi(field)
+		if err != nil {
+			return Profile{}, fmt.Errorf("invalid chunk size %q", field)
+		}
+		sizes[i] = size
+	}
+
+	var profile Profile
+	switch {
+	case fields[0] == AlgorithmFixed && len(sizes) == 1:
+		profile = FixedProfile(sizes[0], hash)
+	case fields[0] == AlgorithmRabin && len(sizes) == 0:
+		defaults := DefaultProfile()
+		profile = RabinProfile(defaults.MinSize, defaults.AvgSize, defaults.MaxSize, hash)
+	case fields[0] == AlgorithmRabin && len(sizes) == 3:
+		profile = RabinProfile(sizes[0], sizes[1], sizes[2], hash)
+	default:
+		return Profile{}, fmt.Errorf("invalid chunking strategy %q, expected fixed:<size> or rabin[:<min>:<avg>:<max>]", text)
+	}
+	return profile, profile.Validate()
+}
+
+// Strategy names the profile's algorithm and sizes as ParseStrategy takes
+// them
+func (p Profile) Strategy() string {
+	if p.Algorithm == AlgorithmFixed {
+		return fmt.Sprintf("%s:%d", p.Algorithm, p.AvgSize)
+	}
+	return fmt.Sprintf("%s:%d:%d:%d", p.Algorithm, p.MinSize, p.AvgSize, p.MaxSize)
+}
+
+func (p Profile) String() string {
+	var b strings.Builder
+	switch p.Algorithm {
+	case AlgorithmFixed:
+		fmt.Fprintf(&b, "v%d %s size=%d", p.Version, p.Algorithm, p.AvgSize)
+	case AlgorithmPattern:
+		fmt.Fprintf(&b, "v%d %s min=%d max=%d", p.Version, p.Algorithm, p.MinSize, p.MaxSize)
+	default:
+		fmt.Fprintf(&b, "v%d %s min=%d avg=%d max=%d window=%d", p.Version, p.Algorithm, p.MinSize, p.AvgSize, p.MaxSize, p.WindowSize)
+	}
+	if p.Polynomial != 0 {
+		fmt.Fprintf(&b, " polynomial=%#x", p.Polynomial)
+	}
+	fmt.Fprintf(&b, " hash=%s", p.Hash)
+	return b.String()
+}
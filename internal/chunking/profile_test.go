@@ -0,0 +1,168 @@
+package chunking
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestProfileValidate(t *testing.T) {
+	if err := DefaultProfile().Validate(); err != nil {
+		t.Fatalf("Default profile is invalid: %v", err)
+	}
+	for name, change := range map[string]func(*Profile){
+		"unknown algorithm": func(p *Profile) { p.Algorithm = "buzhash" },
+		"min above avg":     func(p *Profile) { p.MinSize = p.AvgSize + 1 },
+		"avg above max":     func(p *Profile) { p.AvgSize = p.MaxSize + 1 },
+		"zero min":          func(p *Profile) { p.MinSize = 0 },
+		"window above min":  func(p *Profile) { p.WindowSize = p.MinSize + 1 },
+		"no polynomial":     func(p *Profile) { p.Polynomial = 0 },
+	} {
+		profile := DefaultProfile()
+		change(&profile)
+		if err := profile.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if _, err := NewProfileChunker(profile); err == nil {
+			t.Errorf("%s: expected NewProfileChunker to fail", name)
+		}
+	}
+}
+
+func TestDefaultProfileChunks(t *testing.T) {
+	// Version 1 has the sizes of the default chunker
+	data := make([]byte, 256*1024)
+	rand.New(rand.NewSource(45)).Read(data)
+	want, _ := NewChunker(64, 8192).ChunkData(data)
+	got, _ := NewDefaultChunker().ChunkData(data)
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d chunks, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i].Fingerprint != want[i].Fingerprint {
+			t.Fatalf("Chunk %d differs", i)
+		}
+	}
+
+	profile := DefaultProfile()
+	other := profile
+	other.Version = 7
+	if !profile.SameChunks(other) {
+		t.Error("Expected profiles differing only in version to make the same chunks")
+	}
+	other.MaxSize = 4096
+	if profile.SameChunks(other) {
+		t.Error("Expected profiles with different sizes to differ")
+	}
+}
+
+func TestLegacyProfileChunks(t *testing.T) {
+	if err := LegacyProfile().Validate(); err != nil {
+		t.Fatalf("Legacy profile is invalid: %v", err)
+	}
+	// Chunks end after runs of four zero, 0xFF or newline bytes past the
+	// minimum, and at the maximum otherwise
+	data := make([]byte, 20000)
+	rand.New(rand.NewSource(45)).Read(data)
+	for i := range data {
+		if data[i] == 0x00 || data[i] == 0xFF || data[i] == 0x0A {
+			data[i] = 'x'
+		}
+	}
+	copy(data[10:], "\n\n\n\n")       // before the minimum
+	copy(data[300:], "\n\n\n\n")      // ends the first chunk at 304
+	copy(data[1000:], "\xff\xff\xff") // too short a run
+	copy(data[2000:], "\x00\x00\x00\x00")
+	chunker, err := NewProfileChunker(LegacyProfile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var offsets []int64
+	for _, chunk := range chunker.Split(data) {
+		offsets = append(offsets, chunk.Offset)
+	}
+	if expected := []int64{0, 304, 2004, 2004 + 8192, 2004 + 2*8192}; !slices.Equal(offsets, expected) {
+		t.Errorf("Expected chunks at %v, got %v", expected, offsets)
+	}
+}
+
+func TestRabinProfileParameters(t *testing.T) {
+	data := make([]byte, 1<<20)
+	rand.New(rand.NewSource(47)).Read(data)
+	boundaries := func(profile Profile) []int64 {
+		chunker, err := NewProfileChunker(profile)
+		if err != nil {
+			t.Fatalf("NewProfileChunker failed: %v", err)
+		}
+		var offsets []int64
+		for _, chunk := range chunker.Split(data) {
+			offsets = append(offsets, chunk.Offset)
+		}
+		return offsets
+	}
+
+	// The average chunk size follows AvgSize
+	for _, avg := range []int{2048, 8192} {
+		offsets := boundaries(RabinProfile(avg/4, avg, avg*4, Blake3))
+		if mean := len(data) / len(offsets); mean < avg/2 || mean > avg*2 {
+			t.Errorf("Average %d: chunks average %d bytes", avg, mean)
+		}
+	}
+
+	// The window and polynomial pick the boundaries
+	base := boundaries(DefaultProfile())
+	for name, change := range map[string]func(*Profile){
+		"window":     func(p *Profile) { p.WindowSize = 48 },
+		"polynomial": func(p *Profile) { p.Polynomial = 0x2F0F9A1C3B7D4E65 },
+	} {
+		profile := DefaultProfile()
+		change(&profile)
+		if slices.Equal(boundaries(profile), base) {
+			t.Errorf("Expected a different %s to move the boundaries", name)
+		}
+	}
+
+	// Boundaries depend on content, so they realign after an insertion
+	inserted := append(append(append([]byte(nil), data[:1000]...), "inserted bytes"...), data[1000:]...)
+	chunker := NewDefaultChunker()
+	shared := make(map[Fingerprint]bool)
+	chunks, _ := chunker.ChunkData(data)
+	for _, chunk := range chunks {
+		shared[chunk.Fingerprint] = true
+	}
+	changed, _ := chunker.ChunkData(inserted)
+	var unshared int
+	for _, chunk := range changed {
+		if !shared[chunk.Fingerprint] {
+			unshared++
+		}
+	}
+	if unshared > 2 {
+		t.Errorf("Expected an insertion to change at most 2 chunks, changed %d of %d", unshared, len(changed))
+	}
+}
+
+func TestFixedProfile(t *testing.T) {
+	chunker, err := NewProfileChunker(FixedProfile(4096, Blake3))
+	if err != nil {
+		t.Fatalf("NewProfileChunker failed: %v", err)
+	}
+	data := make([]byte, 10000)
+	rand.New(rand.NewSource(46)).Read(data)
+	chunks, _ := chunker.ChunkData(data)
+	if len(chunks) != 3 || chunks[0].Size != 4096 || chunks[1].Offset != 4096 || chunks[2].Size != 10000-2*4096 {
+		t.Errorf("Expected two 4096-byte blocks and the rest, got %d chunks", len(chunks))
+	}
+
+	for name, change := range map[string]func(*Profile){
+		"sizes differ":    func(p *Profile) { p.MaxSize = 8192 },
+		"with window":     func(p *Profile) { p.WindowSize = 64 },
+		"with polynomial": func(p *Profile) { p.Polynomial = 0x3A335D566E6B7E5B },
+	} {
+		profile := FixedProfile(4096, Blake3)
+		change(&profile)
+		if err := profile.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package chunking
+
+// rabinTables hold the precomputed steps of a Rabin fingerprint rolling over
+// a window of bytes. Fingerprints are polynomials over GF(2) reduced modulo
+// x^64 plus the profile's polynomial, so every 64-bit polynomial names one.
+type rabinTables struct {
+	polynomial uint64
+	mod        [256]uint64 // top byte t -> t * x^64, reduced
+	out        [256]uint64 // byte b -> b * x^(8*(window-1)), reduced
+}
+
+func newRabinTables(polynomial uint64, window int) *rabinTables {
+	t := &rabinTables{polynomial: polynomial}
+	for b := range 256 {
+		var r uint64
+		for bit := 7; bit >= 0; bit-- {
+			r = t.shift(r)
+			if b>>bit&1 == 1 {
+				r ^= polynomial // x^64 reduced
+			}
+		}
+		t.mod[b] = r
+	}
+	for b := range 256 {
+		r := uint64(b)
+		for range 8 * (window - 1) {
+			r = t.shift(r)
+		}
+		t.out[b] = r
+	}
+	return t
+}
+
+// shift multiplies a fingerprint by x
+func (t *rabinTables) shift(r uint64) uint64 {
+	if r>>63 == 1 {
+		return r<<1 ^ t.polynomial
+	}
+	return r << 1
+}
+
+// append returns the fingerprint of the bytes of hash followed by b
+func (t *rabinTables) append(hash uint64, b byte) uint64 {
+	return (hash<<8 | uint64(b)) ^ t.mod[hash>>56]
+}
@@ -0,0 +1,89 @@
+package chunking
+
+import (
+	"encoding/binary"
+
+	"github.com/zeebo/blake3"
+)
+
+// Resemblance detection follows the N-transform super-feature scheme. A gear
+// rolling hash is taken at every position of a chunk; at a sample of those
+// positions it is put through featureCount linear transforms, and the maximum
+// of each transform over the chunk is one feature. Chunks differing in a few
+// bytes keep most of their maxima. Features are grouped into super-features
+// by hashing, so two chunks sharing a super-feature very likely share all of
+// its features and are near-duplicates.
+const (
+	SuperFeatureCount       = 3 // super-features per chunk
+	featuresPerSuperFeature = 4
+	featureCount            = SuperFeatureCount * featuresPerSuperFeature
+
+	// MinSimilarChunkSize is the smallest chunk super-features are computed
+	// for; smaller chunks save too little when stored as deltas
+	MinSimilarChunkSize = 512
+
+	// sampleShift keeps one position in eight, selected by the top bits of
+	// the rolling hash, which depend on the last 64 bytes
+	sampleShift = 61
+)
+
+// SuperFeatures are the resemblance sketch of a chunk
+type SuperFeatures [SuperFeatureCount]uint64
+
+var (
+	gearTable  [256]uint64
+	transformA [featureCount]uint64
+	transformB [featureCount]uint64
+)
+
+func init() {
+	// Fixed pseudo-random tables, so features are stable across processes
+	state := uint64(0x9E3779B97F4A7C15)
+	next := func() uint64 {
+		state += 0x9E3779B97F4A7C15
+		z := state
+		z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+		z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+		return z ^ (z >> 31)
+	}
+	for i := range gearTable {
+		gearTable[i] = next()
+	}
+	for i := range transformA {
+		transformA[i] = next() | 1 // odd, so the transform is a bijection
+		transformB[i] = next()
+	}
+}
+
+// ComputeSuperFeatures returns the super-features of a chunk, or false if it
+// is too small to be stored as a delta
+func ComputeSuperFeatures(data []byte) (SuperFeatures, bool) {
+	var superFeatures SuperFeatures
+	if len(data) < MinSimilarChunkSize {
+		return superFeatures, false
+	}
+
+	var features [featureCount]uint64
+	var hash uint64
+	for _, b := range data {
+		hash = hash<<1 + gearTable[b]
+		if hash>>sampleShift != 0 {
+			continue
+		}
+		for i := range features {
+			if v := transformA[i]*hash + transformB[i]; v > features[i] {
+				features[i] = v
+			}
+		}
+	}
+
+	var group [featuresPerSuperFeature * 8]byte
+	for i := range superFeatures {
+		for j := 0; j < featuresPerSuperFeature; j++ {
+			binary.LittleEndian.PutUint64(group[j*8:], features[i*featuresPerSuperFeature+j])
+		}
+		sum := blake3.Sum256(group[:])
+		superFeatures[i] = binary.LittleEndian.Uint64(sum[:8])
+	}
+	return superFeatures, true
+}
@@ -1,14 +1,20 @@
 package db
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
+	"strconv"
+	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/jobstate"
 )
 
 //go:embed schema.sql
@@ -31,7 +37,11 @@ func NewDB(connStr string) (*DB, error) {
 	if err := initializeSchema(db); err != nil {
 		return nil, err
 	}
-	return &DB{conn: db}, nil
+	client := &DB{conn: db}
+	if _, err := client.MigrateChunkFingerprints(context.Background()); err != nil {
+		return nil, err
+	}
+	return client, nil
 }
 
 // initializeSchema runs the schema.sql file to ensure tables exist
@@ -47,56 +57,1105 @@ func initializeSchema(db *sql.DB) error {
 	return nil
 }
 
+// --- Repository Settings ---
+
+// InitRepositorySetting sets a repository setting unless it is already set,
+// and returns the setting's value
+func (db *DB) InitRepositorySetting(ctx context.Context, key, value string) (string, error) {
+	if _, err := db.conn.ExecContext(ctx, `INSERT INTO repository_settings (key, value) VALUES ($1, $2) ON CONFLICT (key) DO NOTHING`, key, value); err != nil {
+		return "", err
+	}
+	var current string
+	err := db.conn.QueryRowContext(ctx, `SELECT value FROM repository_settings WHERE key = $1`, key).Scan(&current)
+	return current, err
+}
+
+// --- Chunking Profiles ---
+
+// chunkingProfileColumns are the columns scanChunkingProfile reads, in order
+const chunkingProfileColumns = `version, algorithm, min_size, avg_size, max_size, window_size, polynomial, hash_algorithm`
+
+// InitChunkingProfile returns the repository's active chunking profile. A new
+// repository adopts profile as version 1, while one that stored chunks before
+// profiles were recorded adopts the legacy profile they were made with.
+func (db *DB) InitChunkingProfile(ctx context.Context, profile chunking.Profile) (chunking.Profile, error) {
+	version, err := db.activeChunkingProfile(ctx)
+	if err != nil {
+		return chunking.Profile{}, err
+	}
+	if version > 0 {
+		return db.GetChunkingProfile(ctx, version)
+	}
+
+	var chunked bool
+	if err := db.conn.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM chunks)`).Scan(&chunked); err != nil {
+		return chunking.Profile{}, err
+	}
+	if chunked {
+		hash := profile.Hash
+		profile = chunking.LegacyProfile()
+		profile.Hash = hash
+	}
+	profile.Version = 1
+	// Ingest nodes starting together insert the same version; one wins
+	if _, err := db.conn.ExecContext(ctx, `INSERT INTO chunking_profiles (`+chunkingProfileColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT (version) DO NOTHING`,
+		chunkingProfileArgs(profile)...); err != nil {
+		return chunking.Profile{}, err
+	}
+	if _, err := db.InitRepositorySetting(ctx, "chunking_profile", "1"); err != nil {
+		return chunking.Profile{}, err
+	}
+	if version, err = db.activeChunkingProfile(ctx); err != nil {
+		return chunking.Profile{}, err
+	}
+	return db.GetChunkingProfile(ctx, version)
+}
+
+// MigrateChunkingProfile makes profile the active profile, recording it as
+// the next version unless a recorded profile makes the same chunks. Chunks
+// already stored keep the profile they were made with; new data only
+// deduplicates against chunks of the new profile.
+func (db *DB) MigrateChunkingProfile(ctx context.Context, profile chunking.Profile) (chunking.Profile, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return chunking.Profile{}, err
+	}
+	defer tx.Rollback()
+
+	if profile, err = recordChunkingProfile(ctx, tx, profile); err != nil {
+		return chunking.Profile{}, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPSERT INTO repository_settings (key, value) VALUES ('chunking_profile', $1)`, strconv.Itoa(profile.Version)); err != nil {
+		return chunking.Profile{}, err
+	}
+	return profile, tx.Commit()
+}
+
+// SetChunkingPolicy makes backups of a backup policy and source type chunk
+// with profile; an empty policy ID or source type matches any. A recorded
+// profile that makes the same chunks is reused, or else profile is recorded
+// as the next version. It returns the profile with its version.
+func (db *DB) SetChunkingPolicy(ctx context.Context, policyID, sourceType string, profile chunking.Profile) (chunking.Profile, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return chunking.Profile{}, err
+	}
+	defer tx.Rollback()
+
+	if profile, err = recordChunkingProfile(ctx, tx, profile); err != nil {
+		return chunking.Profile{}, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPSERT INTO chunking_policies (backup_policy_id, source_type, chunking_profile) VALUES ($1, $2, $3)`,
+		policyID, sourceType, profile.Version); err != nil {
+		return chunking.Profile{}, err
+	}
+	return profile, tx.Commit()
+}
+
+// FindChunkingPolicy returns the profile the chunking policies pick for a
+// backup of a backup policy and source type, preferring a row naming both,
+// then one naming the policy, then one naming the source type. It returns
+// false if no policy matches.
+func (db *DB) FindChunkingPolicy(ctx context.Context, policyID, sourceType string) (chunking.Profile, bool, error) {
+	row := db.conn.QueryRowContext(ctx, `SELECT p.version, p.algorithm, p.min_size, p.avg_size, p.max_size, p.window_size, p.polynomial, p.hash_algorithm
+		FROM chunking_policies c JOIN chunking_profiles p ON p.version = c.chunking_profile
+		WHERE c.backup_policy_id IN ($1, '') AND c.source_type IN ($2, '') AND (c.backup_policy_id != '' OR c.source_type != '')
+		ORDER BY c.backup_policy_id = '', c.source_type = '' LIMIT 1`, policyID, sourceType)
+	profile, err := scanChunkingProfile(row)
+	if err == sql.ErrNoRows {
+		return chunking.Profile{}, false, nil
+	}
+	return profile, err == nil, err
+}
+
+// GetChunkingProfile returns a chunking profile by version
+func (db *DB) GetChunkingProfile(ctx context.Context, version int) (chunking.Profile, error) {
+	row := db.conn.QueryRowContext(ctx, `SELECT `+chunkingProfileColumns+` FROM chunking_profiles WHERE version = $1`, version)
+	profile, err := scanChunkingProfile(row)
+	if err == sql.ErrNoRows {
+		return chunking.Profile{}, fmt.Errorf("chunking profile %d not found", version)
+	}
+	return profile, err
+}
+
+// activeChunkingProfile returns the version of the active chunking profile,
+// or 0 if none is recorded yet
+func (db *DB) activeChunkingProfile(ctx context.Context) (int, error) {
+	var value string
+	err := db.conn.QueryRowContext(ctx, `SELECT value FROM repository_settings WHERE key = 'chunking_profile'`).Scan(&value)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid chunking_profile setting %q", value)
+	}
+	return version, nil
+}
+
+// recordChunkingProfile returns the recorded profile that makes the same
+// chunks as profile, recording profile as the next version if there is none
+func recordChunkingProfile(ctx context.Context, tx *sql.Tx, profile chunking.Profile) (chunking.Profile, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT `+chunkingProfileColumns+` FROM chunking_profiles ORDER BY version`)
+	if err != nil {
+		return chunking.Profile{}, err
+	}
+	defer rows.Close()
+	version := 0
+	for rows.Next() {
+		recorded, err := scanChunkingProfile(rows)
+		if err != nil {
+			return chunking.Profile{}, err
+		}
+		if recorded.SameChunks(profile) {
+			return recorded, nil
+		}
+		version = recorded.Version
+	}
+	if err := rows.Err(); err != nil {
+		return chunking.Profile{}, err
+	}
+	rows.Close()
+
+	profile.Version = version + 1
+	_, err = tx.ExecContext(ctx, `INSERT INTO chunking_profiles (`+chunkingProfileColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		chunkingProfileArgs(profile)...)
+	return profile, err
+}
+
+// chunkingProfileArgs returns the values of chunkingProfileColumns
+func chunkingProfileArgs(p chunking.Profile) []interface{} {
+	return []interface{}{p.Version, p.Algorithm, p.MinSize, p.AvgSize, p.MaxSize, p.WindowSize, int64(p.Polynomial), p.Hash.String()}
+}
+
+func scanChunkingProfile(row interface{ Scan(...any) error }) (chunking.Profile, error) {
+	var p chunking.Profile
+	var polynomial int64
+	var hash string
+	if err := row.Scan(&p.Version, &p.Algorithm, &p.MinSize, &p.AvgSize, &p.MaxSize, &p.WindowSize, &polynomial, &hash); err != nil {
+		return chunking.Profile{}, err
+	}
+	p.Polynomial = uint64(polynomial)
+	algorithm, err := chunking.ParseHashAlgorithm(hash)
+	if err != nil {
+		return chunking.Profile{}, fmt.Errorf("chunking profile %d: %w", p.Version, err)
+	}
+	p.Hash = algorithm
+	return p, nil
+}
+
+// fingerprintMigrationBatch is the number of rows MigrateChunkFingerprints
+// converts per statement
+const fingerprintMigrationBatch = 10000
+
 // --- Chunks CRUD ---
-func (db *DB) GetChunkMetadataByFingerprint(ctx context.Context, fingerprint string) (*ChunkMetadata, error) {
-	row := db.conn.QueryRowContext(ctx, `SELECT fingerprint, storage_location, size, creation_time, last_referenced_time FROM chunks WHERE fingerprint = $1`, fingerprint)
-	var meta ChunkMetadata
-	err := row.Scan(&meta.Fingerprint, &meta.StorageLocation, &meta.Size, &meta.CreationTime, &meta.LastReferencedTime)
+
+// chunkColumns are the columns scanChunkMetadata reads, in order
+const chunkColumns = `fingerprint, storage_location, size, creation_time, last_referenced_time, storage_nodes, container_id, container_offset, base_fingerprint, delta_depth`
+
+func (db *DB) GetChunkMetadataByFingerprint(ctx context.Context, fingerprint chunking.Fingerprint) (*ChunkMetadata, error) {
+	row := db.conn.QueryRowContext(ctx, `SELECT `+chunkColumns+` FROM chunks WHERE fingerprint = $1`, fingerprint.Bytes())
+	meta, err := scanChunkMetadata(row)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
+	return meta, err
+}
+
+// GetChunkMetadataBatch looks up many chunks in one query and returns the
+// metadata of those that are stored
+func (db *DB) GetChunkMetadataBatch(ctx context.Context, fingerprints []chunking.Fingerprint) (map[chunking.Fingerprint]*ChunkMetadata, error) {
+	found := make(map[chunking.Fingerprint]*ChunkMetadata)
+	if len(fingerprints) == 0 {
+		return found, nil
+	}
+	rows, err := db.conn.QueryContext(ctx, `SELECT `+chunkColumns+` FROM chunks WHERE fingerprint = ANY($1)`, pq.Array(fingerprintBytes(fingerprints)))
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	for rows.Next() {
+		meta, err := scanChunkMetadata(rows)
+		if err != nil {
+			return nil, err
+		}
+		found[meta.Fingerprint] = meta
+	}
+	return found, rows.Err()
+}
+
+// scanChunkMetadata reads a row of chunkColumns
+func scanChunkMetadata(row interface{ Scan(...any) error }) (*ChunkMetadata, error) {
+	var meta ChunkMetadata
+	var key, baseKey []byte
+	var containerID sql.NullString
+	var containerOffset sql.NullInt64
+	err := row.Scan(&key, &meta.StorageLocation, &meta.Size, &meta.CreationTime, &meta.LastReferencedTime, pq.Array(&meta.StorageNodes), &containerID, &containerOffset, &baseKey, &meta.DeltaDepth)
+	if err != nil {
+		return nil, err
+	}
+	if meta.Fingerprint, err = chunking.FingerprintFromBytes(key); err != nil {
+		return nil, err
+	}
+	if baseKey != nil {
+		if meta.BaseFingerprint, err = chunking.FingerprintFromBytes(baseKey); err != nil {
+			return nil, err
+		}
+	}
+	meta.ContainerID = containerID.String
+	meta.ContainerOffset = containerOffset.Int64
 	return &meta, nil
 }
 
 func (db *DB) InsertChunkMetadata(ctx context.Context, meta *ChunkMetadata) error {
-	_, err := db.conn.ExecContext(ctx, `INSERT INTO chunks (fingerprint, storage_location, size, creation_time, last_referenced_time) VALUES ($1, $2, $3, $4, $5)`,
-		meta.Fingerprint, meta.StorageLocation, meta.Size, meta.CreationTime, meta.LastReferencedTime)
+	_, err := db.conn.ExecContext(ctx, `INSERT INTO chunks (fingerprint, storage_location, size, creation_time, last_referenced_time, storage_nodes, container_id, container_offset, base_fingerprint, delta_depth)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		meta.Fingerprint.Bytes(), meta.StorageLocation, meta.Size, meta.CreationTime, meta.LastReferencedTime, pq.Array(meta.StorageNodes), nullString(meta.ContainerID), meta.ContainerOffset,
+		nullFingerprint(meta.BaseFingerprint), meta.DeltaDepth)
 	return err
 }
 
 func (db *DB) UpdateChunkMetadata(ctx context.Context, meta *ChunkMetadata) error {
-	_, err := db.conn.ExecContext(ctx, `UPDATE chunks SET storage_location = $2, size = $3, creation_time = $4, last_referenced_time = $5 WHERE fingerprint = $1`,
-		meta.Fingerprint, meta.StorageLocation, meta.Size, meta.CreationTime, meta.LastReferencedTime)
+	_, err := db.conn.ExecContext(ctx, `UPDATE chunks SET storage_location = $2, size = $3, creation_time = $4, last_referenced_time = $5, storage_nodes = $6 WHERE fingerprint = $1`,
+		meta.Fingerprint.Bytes(), meta.StorageLocation, meta.Size, meta.CreationTime, meta.LastReferencedTime, pq.Array(meta.StorageNodes))
+	return err
+}
+
+func (db *DB) DeleteChunkMetadata(ctx context.Context, fingerprint chunking.Fingerprint) error {
+	_, err := db.conn.ExecContext(ctx, `DELETE FROM chunks WHERE fingerprint = $1`, fingerprint.Bytes())
 	return err
 }
 
+// ListChunkFingerprints returns up to limit object keys greater than after,
+// in order: replicated chunks and erasure-coded shards. When storageNodeID is
+// set only chunks placed on that node (or stored before replication, with no
+// recorded placement) and shards targeting it are returned. Chunks stored as
+// deltas have no object of their own and are not returned.
+func (db *DB) ListChunkFingerprints(ctx context.Context, storageNodeID, after string, limit int) ([]string, error) {
+	// Object keys are the text form of fingerprints, which sorts like the
+	// binary form both tables hold
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT encode(fingerprint, 'hex') FROM (
+			SELECT fingerprint FROM chunks WHERE fingerprint > decode($1, 'hex') AND container_id IS NULL AND base_fingerprint IS NULL AND ($2 = '' OR storage_nodes IS NULL OR $2 = ANY(storage_nodes))
+			UNION
+			SELECT fingerprint FROM container_shards WHERE fingerprint > decode($1, 'hex') AND ($2 = '' OR target = $2)
+		) ORDER BY fingerprint LIMIT $3`, after, storageNodeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fingerprints []string
+	for rows.Next() {
+		var fingerprint string
+		if err := rows.Scan(&fingerprint); err != nil {
+			return nil, err
+		}
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	return fingerprints, rows.Err()
+}
+
+// MigrateChunkFingerprints converts the fingerprint column of a chunks table
+// created when fingerprints were stored as hex strings to BYTES, decoding the
+// rows in batches. Tables added since were created with BYTES columns. It
+// returns the number of values converted; an interrupted migration resumes
+// where it stopped when run again.
+func (db *DB) MigrateChunkFingerprints(ctx context.Context) (int64, error) {
+	converted, err := db.migrateFingerprintColumn(ctx, "chunks", "fingerprint")
+	if err != nil {
+		return converted, fmt.Errorf("failed to migrate chunks.fingerprint: %w", err)
+	}
+	return converted, nil
+}
+
+// migrateFingerprintColumn converts a STRING fingerprint column that is the
+// primary key of its table to BYTES through a staging column that replaces it
+func (db *DB) migrateFingerprintColumn(ctx context.Context, table, column string) (int64, error) {
+	staging := column + "_bytes"
+	columnType, err := db.columnType(ctx, table, column)
+	if err != nil {
+		return 0, err
+	}
+	stagingType, err := db.columnType(ctx, table, staging)
+	if err != nil {
+		return 0, err
+	}
+	if columnType != "text" {
+		// Either already converted, or interrupted after the old column
+		// was dropped
+		if columnType == "" && stagingType != "" {
+			if _, err := db.conn.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s RENAME COLUMN %s TO %s`, table, staging, column)); err != nil {
+				return 0, err
+			}
+		}
+		return 0, nil
+	}
+
+	if stagingType == "" {
+		if _, err := db.conn.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s BYTES`, table, staging)); err != nil {
+			return 0, err
+		}
+	}
+	var converted int64
+	for {
+		result, err := db.conn.ExecContext(ctx, fmt.Sprintf(`UPDATE %[1]s SET %[3]s = decode(%[2]s, 'hex') WHERE %[2]s IS NOT NULL AND %[3]s IS NULL LIMIT %[4]d`,
+			table, column, staging, fingerprintMigrationBatch))
+		if err != nil {
+			return converted, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return converted, err
+		}
+		converted += n
+		if n == 0 {
+			break
+		}
+	}
+
+	statements := []string{
+		fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN %s SET NOT NULL`, table, staging),
+		fmt.Sprintf(`ALTER TABLE %s ALTER PRIMARY KEY USING COLUMNS (%s)`, table, staging),
+		fmt.Sprintf(`ALTER TABLE %s DROP COLUMN %s CASCADE`, table, column),
+		fmt.Sprintf(`ALTER TABLE %s RENAME COLUMN %s TO %s`, table, staging, column),
+	}
+	for _, statement := range statements {
+		if _, err := db.conn.ExecContext(ctx, statement); err != nil {
+			return converted, err
+		}
+	}
+	return converted, nil
+}
+
+// columnType returns the data type of a column of a table, or "" if the
+// table has no such column
+func (db *DB) columnType(ctx context.Context, table, column string) (string, error) {
+	var dataType string
+	err := db.conn.QueryRowContext(ctx, `SELECT data_type FROM information_schema.columns WHERE table_name = $1 AND column_name = $2`, table, column).Scan(&dataType)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return dataType, err
+}
+
+// --- Chunk Placement ---
+
+// ListChunkPlacements returns up to limit placements of replicated chunks
+// with fingerprints greater than after, in order. A zero after starts from
+// the first chunk.
+func (db *DB) ListChunkPlacements(ctx context.Context, after chunking.Fingerprint, limit int) ([]ChunkPlacement, error) {
+	start := []byte{}
+	if !after.IsZero() {
+		start = after.Bytes()
+	}
+	rows, err := db.conn.QueryContext(ctx, `SELECT fingerprint, storage_nodes FROM chunks WHERE container_id IS NULL AND base_fingerprint IS NULL AND fingerprint > $1 ORDER BY fingerprint LIMIT $2`, start, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var placements []ChunkPlacement
+	for rows.Next() {
+		var p ChunkPlacement
+		var key []byte
+		if err := rows.Scan(&key, pq.Array(&p.StorageNodes)); err != nil {
+			return nil, err
+		}
+		if p.Fingerprint, err = chunking.FingerprintFromBytes(key); err != nil {
+			return nil, err
+		}
+		placements = append(placements, p)
+	}
+	return placements, rows.Err()
+}
+
+func (db *DB) UpdateChunkPlacement(ctx context.Context, fingerprint chunking.Fingerprint, storageNodes []string) error {
+	_, err := db.conn.ExecContext(ctx, `UPDATE chunks SET storage_nodes = $2 WHERE fingerprint = $1`, fingerprint.Bytes(), pq.Array(storageNodes))
+	return err
+}
+
+// RemoveChunkReplica drops storageNodeID from the placement of the chunk with
+// an object key, and deletes the chunk row once no replica is left
+func (db *DB) RemoveChunkReplica(ctx context.Context, key, storageNodeID string) error {
+	fingerprint, err := chunking.ParseFingerprint(key)
+	if err != nil {
+		return err
+	}
+	if _, err := db.conn.ExecContext(ctx, `UPDATE chunks SET storage_nodes = array_remove(storage_nodes, $2) WHERE fingerprint = $1`, fingerprint.Bytes(), storageNodeID); err != nil {
+		return err
+	}
+	_, err = db.conn.ExecContext(ctx, `DELETE FROM chunks WHERE fingerprint = $1 AND (storage_nodes IS NULL OR array_length(storage_nodes, 1) IS NULL)`, fingerprint.Bytes())
+	return err
+}
+
+// --- Containers ---
+func (db *DB) InsertContainer(ctx context.Context, c *Container) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO containers (container_id, data_shards, parity_shards, size, shard_size, sealed_time) VALUES ($1, $2, $3, $4, $5, $6)`,
+		c.ContainerID, c.DataShards, c.ParityShards, c.Size, c.ShardSize, c.SealedTime); err != nil {
+		return err
+	}
+	for _, shard := range c.Shards {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO container_shards (container_id, shard_index, target, fingerprint) VALUES ($1, $2, $3, decode($4, 'hex'))`,
+			c.ContainerID, shard.Index, shard.Target, shard.Fingerprint); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (db *DB) GetContainer(ctx context.Context, containerID string) (*Container, error) {
+	containers, err := db.listContainers(ctx, `WHERE container_id = $1`, containerID)
+	if err != nil || len(containers) == 0 {
+		return nil, err
+	}
+	return &containers[0], nil
+}
+
+// ListContainers returns up to limit containers with IDs greater than after, in order
+func (db *DB) ListContainers(ctx context.Context, after string, limit int) ([]Container, error) {
+	return db.listContainers(ctx, `WHERE container_id > $1 ORDER BY container_id LIMIT $2`, after, limit)
+}
+
+// listContainers loads containers matching a WHERE clause together with their shards
+func (db *DB) listContainers(ctx context.Context, where string, args ...interface{}) ([]Container, error) {
+	rows, err := db.conn.QueryContext(ctx, `SELECT container_id, data_shards, parity_shards, size, shard_size, sealed_time FROM containers `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	var containers []Container
+	for rows.Next() {
+		var c Container
+		if err := rows.Scan(&c.ContainerID, &c.DataShards, &c.ParityShards, &c.Size, &c.ShardSize, &c.SealedTime); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		containers = append(containers, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range containers {
+		shardRows, err := db.conn.QueryContext(ctx, `SELECT shard_index, target, encode(fingerprint, 'hex') FROM container_shards WHERE container_id = $1 ORDER BY shard_index`, containers[i].ContainerID)
+		if err != nil {
+			return nil, err
+		}
+		for shardRows.Next() {
+			var shard ContainerShard
+			if err := shardRows.Scan(&shard.Index, &shard.Target, &shard.Fingerprint); err != nil {
+				shardRows.Close()
+				return nil, err
+			}
+			containers[i].Shards = append(containers[i].Shards, shard)
+		}
+		shardRows.Close()
+		if err := shardRows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return containers, nil
+}
+
+// UpdateContainerShards records new locations for rebuilt shards
+func (db *DB) UpdateContainerShards(ctx context.Context, containerID string, shards []ContainerShard) error {
+	for _, shard := range shards {
+		if _, err := db.conn.ExecContext(ctx, `UPSERT INTO container_shards (container_id, shard_index, target, fingerprint) VALUES ($1, $2, $3, decode($4, 'hex'))`,
+			containerID, shard.Index, shard.Target, shard.Fingerprint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// --- Quarantined Chunks ---
+func (db *DB) QuarantineChunk(ctx context.Context, q *QuarantinedChunk) error {
+	_, err := db.conn.ExecContext(ctx, `UPSERT INTO quarantined_chunks (fingerprint, storage_node_id, reason, detected_time) VALUES (decode($1, 'hex'), $2, $3, $4)`,
+		q.Fingerprint, q.StorageNodeID, q.Reason, q.DetectedTime)
+	return err
+}
+
+func (db *DB) ListQuarantinedChunks(ctx context.Context, storageNodeID string) ([]QuarantinedChunk, error) {
+	rows, err := db.conn.QueryContext(ctx, `SELECT encode(fingerprint, 'hex'), storage_node_id, reason, detected_time FROM quarantined_chunks WHERE storage_node_id = $1 ORDER BY detected_time`, storageNodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chunks []QuarantinedChunk
+	for rows.Next() {
+		var q QuarantinedChunk
+		if err := rows.Scan(&q.Fingerprint, &q.StorageNodeID, &q.Reason, &q.DetectedTime); err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, q)
+	}
+	return chunks, rows.Err()
+}
+
 // --- Backup Jobs CRUD ---
+
+// ErrJobNotEnded is returned for an operation only ended jobs allow
+var ErrJobNotEnded = errors.New("backup job has not ended")
+
 func (db *DB) CreateBackupJob(ctx context.Context, job *BackupJob) error {
-	_, err := db.conn.ExecContext(ctx, `INSERT INTO backup_jobs (job_id, client_id, backup_policy_id, start_time, end_time, status, source_type, source_details, files_metadata) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
-		job.JobID, job.ClientID, job.BackupPolicyID, job.StartTime, job.EndTime, job.Status, job.SourceType, job.SourceDetails, job.FilesMetadata)
+	_, err := db.conn.ExecContext(ctx, `INSERT INTO backup_jobs (job_id, client_id, backup_policy_id, start_time, end_time, status, failure_reason, source_type, source_details, files_metadata, heartbeat_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, now())`,
+		job.JobID, job.ClientID, job.BackupPolicyID, job.StartTime, job.EndTime, string(job.Status), nullString(string(job.FailureReason)), job.SourceType, job.SourceDetails, job.FilesMetadata)
+	return err
+}
+
+// TransitionBackupJob moves a job to a state, which ends it if the state is
+// terminal. It fails with a *jobstate.TransitionError if the job's current
+// state may not move to it.
+func (db *DB) TransitionBackupJob(ctx context.Context, jobID string, to jobstate.State, reason jobstate.Reason, endTime *time.Time) error {
+	result, err := db.conn.ExecContext(ctx, `UPDATE backup_jobs SET status = $2, failure_reason = $3, end_time = $4, heartbeat_time = now()
+		WHERE job_id = $1 AND status = ANY($5)`,
+		jobID, string(to), nullString(string(reason)), endTime, pq.Array(stateNames(jobstate.Predecessors(to))))
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	job, err := db.GetBackupJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return fmt.Errorf("backup job %s does not exist", jobID)
+	}
+	return &jobstate.TransitionError{JobID: jobID, From: job.Status, To: to}
+}
+
+// TouchBackupJobs records that streams of unfinished jobs are still open
+func (db *DB) TouchBackupJobs(ctx context.Context, jobIDs []string) error {
+	_, err := db.conn.ExecContext(ctx, `UPDATE backup_jobs SET heartbeat_time = now() WHERE job_id = ANY($1) AND status = ANY($2)`,
+		pq.Array(jobIDs), pq.Array(stateNames(jobstate.Active)))
 	return err
 }
 
-func (db *DB) UpdateBackupJobStatus(ctx context.Context, jobID, status string, endTime *time.Time) error {
-	_, err := db.conn.ExecContext(ctx, `UPDATE backup_jobs SET status = $2, end_time = $3 WHERE job_id = $1`, jobID, status, endTime)
+// FailStaleBackupJobs fails the unfinished jobs last seen before cutoff with
+// reason StreamLost, and returns their IDs
+func (db *DB) FailStaleBackupJobs(ctx context.Context, cutoff time.Time) ([]string, error) {
+	rows, err := db.conn.QueryContext(ctx, `UPDATE backup_jobs SET status = $1, failure_reason = $2, end_time = now()
+		WHERE status = ANY($3) AND heartbeat_time < $4 RETURNING job_id`,
+		string(jobstate.Failed), string(jobstate.StreamLost), pq.Array(stateNames(jobstate.Active)), cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobIDs []string
+	for rows.Next() {
+		var jobID string
+		if err := rows.Scan(&jobID); err != nil {
+			return nil, err
+		}
+		jobIDs = append(jobIDs, jobID)
+	}
+	return jobIDs, rows.Err()
+}
+
+// GetBackupJob returns a backup job, or nil if it does not exist
+func (db *DB) GetBackupJob(ctx context.Context, jobID string) (*BackupJob, error) {
+	row := db.conn.QueryRowContext(ctx, `SELECT `+backupJobColumns+` FROM backup_jobs WHERE job_id = $1`, jobID)
+	job, err := scanBackupJob(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+// LatestBackupJob returns the most recently started finished job of a client
+// and source in one of states, or nil if there is none
+func (db *DB) LatestBackupJob(ctx context.Context, clientID, sourceType, sourceDetails string, states []jobstate.State) (*BackupJob, error) {
+	row := db.conn.QueryRowContext(ctx, `SELECT `+backupJobColumns+` FROM backup_jobs
+		WHERE client_id = $1 AND source_type = $2 AND source_details = $3 AND status = ANY($4) AND end_time IS NOT NULL
+		ORDER BY start_time DESC LIMIT 1`, clientID, sourceType, sourceDetails, pq.Array(stateNames(states)))
+	job, err := scanBackupJob(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+// backupJobColumns are the columns scanBackupJob reads
+const backupJobColumns = `job_id, client_id, backup_policy_id, start_time, end_time, status, failure_reason, source_type, source_details, heartbeat_time,
+	files_processed, files_unchanged, entries_processed, chunks_processed, bytes_processed, bytes_deduplicated, chunks_delta, bytes_delta_saved`
+
+func scanBackupJob(row interface{ Scan(...any) error }) (*BackupJob, error) {
+	var job BackupJob
+	var jobStatus string
+	var policyID, reason, sourceType, sourceDetails sql.NullString
+	var endTime sql.NullTime
+	stats := &job.Stats
+	if err := row.Scan(&job.JobID, &job.ClientID, &policyID, &job.StartTime, &endTime, &jobStatus, &reason, &sourceType, &sourceDetails, &job.HeartbeatTime,
+		&stats.FilesProcessed, &stats.FilesUnchanged, &stats.EntriesProcessed, &stats.ChunksProcessed,
+		&stats.BytesProcessed, &stats.BytesDeduplicated, &stats.ChunksDelta, &stats.BytesDeltaSaved); err != nil {
+		return nil, err
+	}
+	job.BackupPolicyID = policyID.String
+	job.Status = jobstate.State(jobStatus)
+	job.FailureReason = jobstate.Reason(reason.String)
+	job.SourceType = sourceType.String
+	job.SourceDetails = sourceDetails.String
+	if endTime.Valid {
+		job.EndTime = &endTime.Time
+	}
+	return &job, nil
+}
+
+// RecordBackupJobStats records the counters of a job
+func (db *DB) RecordBackupJobStats(ctx context.Context, jobID string, stats JobStats) error {
+	_, err := db.conn.ExecContext(ctx, `UPDATE backup_jobs SET files_processed = $2, files_unchanged = $3, entries_processed = $4, chunks_processed = $5,
+		bytes_processed = $6, bytes_deduplicated = $7, chunks_delta = $8, bytes_delta_saved = $9 WHERE job_id = $1`,
+		jobID, stats.FilesProcessed, stats.FilesUnchanged, stats.EntriesProcessed, stats.ChunksProcessed,
+		stats.BytesProcessed, stats.BytesDeduplicated, stats.ChunksDelta, stats.BytesDeltaSaved)
 	return err
 }
 
+// ListBackupJobs returns the jobs a filter selects, most recently started
+// first
+func (db *DB) ListBackupJobs(ctx context.Context, filter BackupJobFilter) ([]BackupJob, error) {
+	conditions := []string{"true"}
+	var args []any
+	where := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.ClientID != "" {
+		where("client_id = $%d", filter.ClientID)
+	}
+	if filter.BackupPolicyID != "" {
+		where("backup_policy_id = $%d", filter.BackupPolicyID)
+	}
+	if len(filter.States) > 0 {
+		where("status = ANY($%d)", pq.Array(stateNames(filter.States)))
+	}
+	if !filter.StartedAfter.IsZero() {
+		where("start_time >= $%d", filter.StartedAfter)
+	}
+	if !filter.StartedBefore.IsZero() {
+		where("start_time < $%d", filter.StartedBefore)
+	}
+	if filter.AfterJobID != "" {
+		args = append(args, filter.AfterStart, filter.AfterJobID)
+		conditions = append(conditions, fmt.Sprintf("(start_time, job_id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+	query := fmt.Sprintf(`SELECT %s FROM backup_jobs WHERE %s ORDER BY start_time DESC, job_id DESC`,
+		backupJobColumns, strings.Join(conditions, " AND "))
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []BackupJob
+	for rows.Next() {
+		job, err := scanBackupJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+// DeleteBackupJob deletes an ended job. It fails with ErrJobNotEnded if the
+// job has not ended.
+func (db *DB) DeleteBackupJob(ctx context.Context, jobID string) error {
+	result, err := db.conn.ExecContext(ctx, `DELETE FROM backup_jobs WHERE job_id = $1 AND end_time IS NOT NULL`, jobID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	job, err := db.GetBackupJob(ctx, jobID)
+	if err != nil || job == nil {
+		return err
+	}
+	return fmt.Errorf("backup job %s is %s: %w", jobID, job.Status, ErrJobNotEnded)
+}
+
+// stateNames converts states for a STRING[] parameter
+func stateNames(states []jobstate.State) []string {
+	names := make([]string, len(states))
+	for i, state := range states {
+		names[i] = string(state)
+	}
+	return names
+}
+
 func (db *DB) AddFileMetadataToJob(ctx context.Context, jobID string, filesMeta interface{}) error {
 	_, err := db.conn.ExecContext(ctx, `UPDATE backup_jobs SET files_metadata = $2 WHERE job_id = $1`, jobID, filesMeta)
 	return err
 }
 
+// --- Backup Checkpoints ---
+func (db *DB) UpsertBackupCheckpoint(ctx context.Context, c *BackupCheckpoint) error {
+	partialChunks, err := chunkRefBytes(c.PartialChunks)
+	if err != nil {
+		return err
+	}
+	_, err = db.conn.ExecContext(ctx, `UPSERT INTO backup_checkpoints (job_id, base_job_id, last_file, partial_file, partial_offset, partial_entry, partial_chunks, partial_archive,
+		files_processed, files_unchanged, entries_processed, chunks_processed, bytes_processed, bytes_deduplicated, chunks_delta, bytes_delta_saved, updated_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, now())`,
+		c.JobID, nullString(c.BaseJobID), nullString(c.LastFile), nullString(c.PartialFile), c.PartialOffset, c.PartialEntry, pq.Array(partialChunks), c.PartialArchive,
+		c.FilesProcessed, c.FilesUnchanged, c.EntriesProcessed, c.ChunksProcessed, c.BytesProcessed, c.BytesDeduplicated, c.ChunksDelta, c.BytesDeltaSaved)
+	return err
+}
+
+// GetBackupCheckpoint returns the checkpoint of a job, or nil if it has none
+func (db *DB) GetBackupCheckpoint(ctx context.Context, jobID string) (*BackupCheckpoint, error) {
+	c := BackupCheckpoint{JobID: jobID}
+	var baseJobID, lastFile, partialFile sql.NullString
+	var partialChunks [][]byte
+	err := db.conn.QueryRowContext(ctx, `SELECT base_job_id, last_file, partial_file, partial_offset, partial_entry, partial_chunks, partial_archive,
+		files_processed, files_unchanged, entries_processed, chunks_processed, bytes_processed, bytes_deduplicated, chunks_delta, bytes_delta_saved, updated_time
+		FROM backup_checkpoints WHERE job_id = $1`, jobID).Scan(
+		&baseJobID, &lastFile, &partialFile, &c.PartialOffset, &c.PartialEntry, pq.Array(&partialChunks), &c.PartialArchive,
+		&c.FilesProcessed, &c.FilesUnchanged, &c.EntriesProcessed, &c.ChunksProcessed, &c.BytesProcessed, &c.BytesDeduplicated, &c.ChunksDelta, &c.BytesDeltaSaved, &c.UpdatedTime)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.BaseJobID = baseJobID.String
+	c.LastFile = lastFile.String
+	c.PartialFile = partialFile.String
+	if c.PartialChunks, err = chunkRefStrings(partialChunks); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (db *DB) DeleteBackupCheckpoint(ctx context.Context, jobID string) error {
+	_, err := db.conn.ExecContext(ctx, `DELETE FROM backup_checkpoints WHERE job_id = $1`, jobID)
+	return err
+}
+
+// --- Chunk Owners ---
+
+// AddChunkOwners records that a client has sent the data of fingerprints
+func (db *DB) AddChunkOwners(ctx context.Context, clientID string, fingerprints []chunking.Fingerprint) error {
+	if len(fingerprints) == 0 {
+		return nil
+	}
+	_, err := db.conn.ExecContext(ctx, `UPSERT INTO chunk_owners (client_id, fingerprint) SELECT $1, unnest($2::BYTES[])`, clientID, pq.Array(fingerprintBytes(fingerprints)))
+	return err
+}
+
+// ListOwnedChunks returns which of fingerprints the client has sent the data of
+func (db *DB) ListOwnedChunks(ctx context.Context, clientID string, fingerprints []chunking.Fingerprint) ([]chunking.Fingerprint, error) {
+	rows, err := db.conn.QueryContext(ctx, `SELECT fingerprint FROM chunk_owners WHERE client_id = $1 AND fingerprint = ANY($2)`, clientID, pq.Array(fingerprintBytes(fingerprints)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var owned []chunking.Fingerprint
+	for rows.Next() {
+		var key []byte
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		fingerprint, err := chunking.FingerprintFromBytes(key)
+		if err != nil {
+			return nil, err
+		}
+		owned = append(owned, fingerprint)
+	}
+	return owned, rows.Err()
+}
+
+// --- Chunk Features ---
+
+// AddChunkFeatures indexes the super-features of a stored chunk. A feature
+// shared by several chunks points at the most recently stored one.
+func (db *DB) AddChunkFeatures(ctx context.Context, fingerprint chunking.Fingerprint, features []int64) error {
+	if len(features) == 0 {
+		return nil
+	}
+	_, err := db.conn.ExecContext(ctx, `UPSERT INTO chunk_features (feature, fingerprint) SELECT unnest($1::INT8[]), $2`, pq.Array(features), fingerprint.Bytes())
+	return err
+}
+
+// FindSimilarChunk returns the chunk sharing the most of features, or the
+// zero fingerprint if none shares any
+func (db *DB) FindSimilarChunk(ctx context.Context, features []int64) (chunking.Fingerprint, error) {
+	var fingerprint []byte
+	err := db.conn.QueryRowContext(ctx, `SELECT fingerprint FROM chunk_features WHERE feature = ANY($1)
+		GROUP BY fingerprint ORDER BY count(*) DESC, fingerprint LIMIT 1`, pq.Array(features)).Scan(&fingerprint)
+	if err == sql.ErrNoRows {
+		return chunking.Fingerprint{}, nil
+	}
+	if err != nil {
+		return chunking.Fingerprint{}, err
+	}
+	return chunking.FingerprintFromBytes(fingerprint)
+}
+
+// --- File Manifests ---
+func (db *DB) InsertFileManifest(ctx context.Context, m *FileManifest) error {
+	chunks, err := chunkRefBytes(m.Chunks)
+	if err != nil {
+		return err
+	}
+	_, err = db.conn.ExecContext(ctx, `UPSERT INTO file_manifests (job_id, file_path, file_type, size, mtime, entry, chunks, chunking_profile) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		m.JobID, m.FilePath, m.FileType, m.Size, m.ModTime, m.Entry, pq.Array(chunks), m.ChunkingProfile)
+	return err
+}
+
+// holeRefPrefix starts the chunk reference of a hole in a file
+const holeRefPrefix = "hole:"
+
+// scanFileManifest scans a row of file_manifests selected as job_id,
+// file_path, file_type, size, mtime, entry, chunks, chunking_profile
+func scanFileManifest(row interface{ Scan(...any) error }) (FileManifest, error) {
+	var m FileManifest
+	var mtime sql.NullTime
+	var chunks [][]byte
+	if err := row.Scan(&m.JobID, &m.FilePath, &m.FileType, &m.Size, &mtime, &m.Entry, pq.Array(&chunks), &m.ChunkingProfile); err != nil {
+		return FileManifest{}, err
+	}
+	m.ModTime = mtime.Time
+	var err error
+	m.Chunks, err = chunkRefStrings(chunks)
+	return m, err
+}
+
+// chunkRefBytes returns the binary form of a file's chunk references, as
+// manifests and checkpoints store them: the binary form of fingerprints, and
+// holes as the text hole:<bytes>, which is shorter than any fingerprint
+func chunkRefBytes(refs []string) ([][]byte, error) {
+	if refs == nil {
+		return nil, nil
+	}
+	b := make([][]byte, len(refs))
+	for i, ref := range refs {
+		if strings.HasPrefix(ref, holeRefPrefix) {
+			b[i] = []byte(ref)
+			continue
+		}
+		fingerprint, err := chunking.ParseFingerprint(ref)
+		if err != nil {
+			return nil, err
+		}
+		b[i] = fingerprint.Bytes()
+	}
+	return b, nil
+}
+
+// chunkRefStrings returns the chunk references chunkRefBytes stored
+func chunkRefStrings(b [][]byte) ([]string, error) {
+	if b == nil {
+		return nil, nil
+	}
+	refs := make([]string, len(b))
+	for i, ref := range b {
+		if len(ref) < 32 && bytes.HasPrefix(ref, []byte(holeRefPrefix)) {
+			refs[i] = string(ref)
+			continue
+		}
+		fingerprint, err := chunking.FingerprintFromBytes(ref)
+		if err != nil {
+			return nil, err
+		}
+		refs[i] = fingerprint.String()
+	}
+	return refs, nil
+}
+
+// ListFileManifests returns the manifest of a backup job ordered by path
+func (db *DB) ListFileManifests(ctx context.Context, jobID string) ([]FileManifest, error) {
+	rows, err := db.conn.QueryContext(ctx, `SELECT job_id, file_path, file_type, size, mtime, entry, chunks, chunking_profile FROM file_manifests WHERE job_id = $1 ORDER BY file_path`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var manifests []FileManifest
+	for rows.Next() {
+		m, err := scanFileManifest(rows)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, m)
+	}
+	return manifests, rows.Err()
+}
+
+// PageFileManifests returns a page of the manifest of a backup job, ordered
+// by path
+func (db *DB) PageFileManifests(ctx context.Context, jobID string, page FileManifestPage) ([]FileManifest, error) {
+	conditions := []string{"job_id = $1", "file_path > $2"}
+	args := []any{jobID, page.AfterPath}
+	if page.Prefix != "" {
+		args = append(args, page.Prefix)
+		conditions = append(conditions, fmt.Sprintf("file_path >= $%d", len(args)))
+		if end, ok := prefixEnd(page.Prefix); ok {
+			args = append(args, end)
+			conditions = append(conditions, fmt.Sprintf("file_path < $%d", len(args)))
+		}
+	}
+	if page.ChildrenOnly {
+		args = append(args, page.Prefix)
+		conditions = append(conditions, fmt.Sprintf("strpos(substr(file_path, char_length($%d::STRING) + 1), '/') = 0", len(args)))
+	}
+	args = append(args, page.Limit)
+	query := fmt.Sprintf(`SELECT job_id, file_path, file_type, size, mtime, entry, chunks, chunking_profile FROM file_manifests
+		WHERE %s ORDER BY file_path LIMIT $%d`, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var manifests []FileManifest
+	for rows.Next() {
+		m, err := scanFileManifest(rows)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, m)
+	}
+	return manifests, rows.Err()
+}
+
+// DeleteFileManifests deletes the manifest of a backup job and returns the
+// number of entries deleted
+func (db *DB) DeleteFileManifests(ctx context.Context, jobID string) (int64, error) {
+	result, err := db.conn.ExecContext(ctx, `DELETE FROM file_manifests WHERE job_id = $1`, jobID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetFileVersions returns the entries recorded for a path in any of a set of
+// backup jobs
+func (db *DB) GetFileVersions(ctx context.Context, jobIDs []string, filePath string) ([]FileManifest, error) {
+	rows, err := db.conn.QueryContext(ctx, `SELECT job_id, file_path, file_type, size, mtime, entry, chunks, chunking_profile FROM file_manifests
+		WHERE job_id = ANY($1) AND file_path = $2`, pq.Array(jobIDs), filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var manifests []FileManifest
+	for rows.Next() {
+		m, err := scanFileManifest(rows)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, m)
+	}
+	return manifests, rows.Err()
+}
+
+// SearchFileManifests returns the paths a search matches in a set of backup
+// jobs, ordered by path, with the jobs recording each
+func (db *DB) SearchFileManifests(ctx context.Context, search FileSearch) ([]FileMatch, error) {
+	rows, err := db.conn.QueryContext(ctx, `SELECT file_path, array_agg(job_id) FROM file_manifests
+		WHERE job_id = ANY($1) AND file_path LIKE $2 AND file_path > $3
+		GROUP BY file_path ORDER BY file_path LIMIT $4`, pq.Array(search.JobIDs), search.Like, search.AfterPath, search.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []FileMatch
+	for rows.Next() {
+		var m FileMatch
+		if err := rows.Scan(&m.FilePath, pq.Array(&m.JobIDs)); err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// SummarizeFileManifests returns the number of entries in the manifest of a
+// backup job and the total size of its regular files
+func (db *DB) SummarizeFileManifests(ctx context.Context, jobID string) (entries, bytes int64, err error) {
+	err = db.conn.QueryRowContext(ctx, `SELECT count(*), COALESCE(sum(size) FILTER (WHERE file_type = 'REGULAR'), 0)::INT8
+		FROM file_manifests WHERE job_id = $1`, jobID).Scan(&entries, &bytes)
+	return entries, bytes, err
+}
+
+// prefixEnd returns the smallest string greater than every string starting
+// with prefix, or false if there is none
+func prefixEnd(prefix string) (string, bool) {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1]), true
+		}
+	}
+	return "", false
+}
+
 // --- Data Types ---
 type ChunkMetadata struct {
-	Fingerprint        string
+	Fingerprint        chunking.Fingerprint
 	StorageLocation    string
 	Size               int
 	CreationTime       time.Time
 	LastReferencedTime time.Time
+	StorageNodes       []string // Nodes holding a replica; nil if unknown
+	ContainerID        string   // Erasure-coded container holding the chunk, if any
+	ContainerOffset    int64
+	BaseFingerprint    chunking.Fingerprint // Chunk this one is stored as a delta against, if any
+	DeltaDepth         int                  // Deltas applied to rebuild the chunk; 0 if stored whole
+}
+
+type ChunkPlacement struct {
+	Fingerprint  chunking.Fingerprint
+	StorageNodes []string
+}
+
+type Container struct {
+	ContainerID  string
+	DataShards   int
+	ParityShards int
+	Size         int64
+	ShardSize    int
+	SealedTime   time.Time
+	Shards       []ContainerShard
+}
+
+type ContainerShard struct {
+	Index       int
+	Target      string
+	Fingerprint string
+}
+
+type QuarantinedChunk struct {
+	Fingerprint   string
+	StorageNodeID string
+	Reason        string
+	DetectedTime  time.Time
 }
 
 type BackupJob struct {
@@ -105,8 +1164,108 @@ type BackupJob struct {
 	BackupPolicyID string
 	StartTime      time.Time
 	EndTime        *time.Time
-	Status         string
+	Status         jobstate.State
+	FailureReason  jobstate.Reason // of a Failed job
 	SourceType     string
 	SourceDetails  string
 	FilesMetadata  interface{} // Use a struct or map for real implementation
+	HeartbeatTime  time.Time   // when an ingest node last saw a stream of the job
+	Stats          JobStats    // recorded when the job ends
+}
+
+type FileManifest struct {
+	JobID    string
+	FilePath string
+	FileType string
+	Size     int64
+	ModTime  time.Time
+	Entry    []byte   // Serialized FileEntry
+	Chunks   []string // Chunk fingerprints in file order, with holes as "hole:<bytes>"; nil for other types
+
+	ChunkingProfile int // version of the chunking profile Chunks were made with; 0 for other types
+}
+
+type BackupCheckpoint struct {
+	JobID          string
+	BaseJobID      string
+	LastFile       string
+	PartialFile    string
+	PartialOffset  int64
+	PartialEntry   []byte   // Serialized FileEntry of PartialFile
+	PartialChunks  []string // Chunk references of PartialFile up to PartialOffset
+	PartialArchive []byte   // State of the archive splitter following PartialFile, if any
+	JobStats
+	UpdatedTime time.Time
+}
+
+// JobStats are the counters of a backup job
+type JobStats struct {
+	FilesProcessed    int64
+	FilesUnchanged    int64
+	EntriesProcessed  int64
+	ChunksProcessed   int64
+	BytesProcessed    int64
+	BytesDeduplicated int64
+	ChunksDelta       int64
+	BytesDeltaSaved   int64
+}
+
+// BackupJobFilter selects backup jobs to list, most recently started first.
+// Empty fields match any job. A page continues after the job AfterJobID,
+// started at AfterStart.
+type BackupJobFilter struct {
+	ClientID       string
+	BackupPolicyID string
+	States         []jobstate.State
+	StartedAfter   time.Time // inclusive
+	StartedBefore  time.Time // exclusive
+	AfterStart     time.Time
+	AfterJobID     string
+	Limit          int // 0 means no limit
+}
+
+// FileManifestPage selects entries of a job's manifest to list, ordered by
+// path. A page continues after the path AfterPath.
+type FileManifestPage struct {
+	Prefix       string
+	ChildrenOnly bool // only entries directly in the directory Prefix names
+	AfterPath    string
+	Limit        int
+}
+
+// FileSearch selects the paths matching a SQL LIKE pattern in the manifests
+// of a set of jobs. A page continues after the path AfterPath.
+type FileSearch struct {
+	JobIDs    []string
+	Like      string // backslash escapes %, _ and itself
+	AfterPath string
+	Limit     int
+}
+
+// FileMatch is a path a FileSearch matched and the jobs that recorded it
+type FileMatch struct {
+	FilePath string
+	JobIDs   []string
+}
+
+// nullFingerprint maps the zero fingerprint to SQL NULL
+func nullFingerprint(f chunking.Fingerprint) []byte {
+	if f.IsZero() {
+		return nil
+	}
+	return f.Bytes()
+}
+
+// fingerprintBytes returns the binary forms of fingerprints
+func fingerprintBytes(fingerprints []chunking.Fingerprint) [][]byte {
+	keys := make([][]byte, len(fingerprints))
+	for i, fingerprint := range fingerprints {
+		keys[i] = fingerprint.Bytes()
+	}
+	return keys
+}
+
+// nullString maps an empty string to SQL NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
 }
@@ -1,12 +1,62 @@
--- Chunks table: stores unique data chunks
+-- Chunks table: stores unique data chunks. A table created when fingerprints
+-- were hex strings is converted on startup.
 CREATE TABLE IF NOT EXISTS chunks (
-    fingerprint STRING PRIMARY KEY, -- Blake3 hash of chunk
+    fingerprint BYTES PRIMARY KEY, -- binary fingerprint of the chunk, with the repository's fingerprint_algorithm
     storage_location STRING NOT NULL, -- MinIO object key
     size INT NOT NULL,
     creation_time TIMESTAMPTZ NOT NULL DEFAULT now(),
     last_referenced_time TIMESTAMPTZ NOT NULL DEFAULT now()
 );
 
+-- Storage nodes holding a replica of each chunk (NULL for chunks stored before replication)
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS storage_nodes STRING[];
+
+-- Erasure-coded container holding the chunk (NULL for replicated chunks)
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS container_id STRING;
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS container_offset INT8;
+
+-- Near-duplicate chunks stored as a delta against a similar base chunk; their
+-- storage_location is delta://<object key of the delta>
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS base_fingerprint BYTES;
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS delta_depth INT NOT NULL DEFAULT 0;
+
+-- Repository settings fixed when the repository is created, such as the
+-- fingerprint_algorithm every chunk fingerprint is computed with
+CREATE TABLE IF NOT EXISTS repository_settings (
+    key STRING PRIMARY KEY,
+    value STRING NOT NULL
+);
+
+-- Repositories that stored chunks before the algorithm could be chosen use Blake3
+INSERT INTO repository_settings (key, value)
+    SELECT 'fingerprint_algorithm', 'blake3' WHERE EXISTS (SELECT 1 FROM chunks)
+    ON CONFLICT (key) DO NOTHING;
+
+-- Chunking profiles the repository has used, by version. The chunking_profile
+-- repository setting names the active one, which every ingest node chunks
+-- with; a repository moves to a new profile only by an explicit migration.
+CREATE TABLE IF NOT EXISTS chunking_profiles (
+    version INT8 PRIMARY KEY,
+    algorithm STRING NOT NULL, -- chunk boundary algorithm, e.g. rabin
+    min_size INT8 NOT NULL,
+    avg_size INT8 NOT NULL,
+    max_size INT8 NOT NULL,
+    window_size INT8 NOT NULL,
+    polynomial INT8 NOT NULL DEFAULT 0, -- 64-bit polynomial as a signed integer
+    hash_algorithm STRING NOT NULL,
+    created_time TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Chunking policies: the chunking profile backups of a backup policy or a
+-- source type are chunked with instead of the active profile. An empty
+-- backup_policy_id or source_type matches any; the most specific row wins.
+CREATE TABLE IF NOT EXISTS chunking_policies (
+    backup_policy_id STRING NOT NULL DEFAULT '',
+    source_type STRING NOT NULL DEFAULT '', -- e.g. filesystem, database, vm, cloud_storage
+    chunking_profile INT8 NOT NULL REFERENCES chunking_profiles (version),
+    PRIMARY KEY (backup_policy_id, source_type)
+);
+
 -- Index for quick lookup by last referenced time (for GC/eviction)
 CREATE INDEX IF NOT EXISTS idx_chunks_last_referenced_time ON chunks (last_referenced_time);
 
@@ -17,12 +67,136 @@ CREATE TABLE IF NOT EXISTS backup_jobs (
     backup_policy_id STRING,
     start_time TIMESTAMPTZ NOT NULL DEFAULT now(),
     end_time TIMESTAMPTZ,
-    status STRING NOT NULL, -- e.g., INITIATED, COMPLETED, FAILED
+    status STRING NOT NULL, -- PENDING, RUNNING, FINALIZING, COMPLETED, PARTIAL, FAILED or CANCELLED
     source_type STRING,
     source_details STRING,
     files_metadata JSONB -- List of files, chunk fingerprints, etc.
 );
 
+-- Why a FAILED job failed, and when an ingest node last saw a stream of an
+-- unfinished job; jobs not seen within the stale timeout are failed
+ALTER TABLE backup_jobs ADD COLUMN IF NOT EXISTS failure_reason STRING;
+ALTER TABLE backup_jobs ADD COLUMN IF NOT EXISTS heartbeat_time TIMESTAMPTZ NOT NULL DEFAULT now();
+
+-- Jobs recorded when the status was a free-form string the client sent
+UPDATE backup_jobs SET status = 'RUNNING' WHERE status = 'INITIATED';
+UPDATE backup_jobs SET status = 'PARTIAL' WHERE status = 'COMPLETED_WITH_ERRORS';
+UPDATE backup_jobs SET status = 'FAILED', failure_reason = 'UNKNOWN', end_time = COALESCE(end_time, now())
+    WHERE status NOT IN ('PENDING', 'RUNNING', 'FINALIZING', 'COMPLETED', 'PARTIAL', 'FAILED', 'CANCELLED');
+UPDATE backup_jobs SET failure_reason = 'UNKNOWN' WHERE status = 'FAILED' AND failure_reason IS NULL;
+
+ALTER TABLE backup_jobs ADD CONSTRAINT IF NOT EXISTS check_backup_job_status
+    CHECK (status IN ('PENDING', 'RUNNING', 'FINALIZING', 'COMPLETED', 'PARTIAL', 'FAILED', 'CANCELLED'));
+ALTER TABLE backup_jobs ADD CONSTRAINT IF NOT EXISTS check_backup_job_failure_reason
+    CHECK ((status = 'FAILED') = (failure_reason IS NOT NULL)
+        AND (failure_reason IS NULL OR failure_reason IN ('CLIENT_REPORTED', 'STREAM_LOST', 'STORAGE_ERROR', 'INTERNAL_ERROR', 'UNKNOWN')));
+
+-- Counters of a job, recorded when it ends
+ALTER TABLE backup_jobs ADD COLUMN IF NOT EXISTS files_processed INT8 NOT NULL DEFAULT 0;
+ALTER TABLE backup_jobs ADD COLUMN IF NOT EXISTS files_unchanged INT8 NOT NULL DEFAULT 0;
+ALTER TABLE backup_jobs ADD COLUMN IF NOT EXISTS entries_processed INT8 NOT NULL DEFAULT 0;
+ALTER TABLE backup_jobs ADD COLUMN IF NOT EXISTS chunks_processed INT8 NOT NULL DEFAULT 0;
+ALTER TABLE backup_jobs ADD COLUMN IF NOT EXISTS bytes_processed INT8 NOT NULL DEFAULT 0;
+ALTER TABLE backup_jobs ADD COLUMN IF NOT EXISTS bytes_deduplicated INT8 NOT NULL DEFAULT 0;
+ALTER TABLE backup_jobs ADD COLUMN IF NOT EXISTS chunks_delta INT8 NOT NULL DEFAULT 0;
+ALTER TABLE backup_jobs ADD COLUMN IF NOT EXISTS bytes_delta_saved INT8 NOT NULL DEFAULT 0;
+
 -- Indexes for efficient queries
 CREATE INDEX IF NOT EXISTS idx_backup_jobs_client_id ON backup_jobs (client_id);
-CREATE INDEX IF NOT EXISTS idx_backup_jobs_status ON backup_jobs (status); 
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_backup_jobs_start ON backup_jobs (start_time DESC, job_id DESC);
+CREATE INDEX IF NOT EXISTS idx_backup_jobs_client_start ON backup_jobs (client_id, start_time DESC, job_id DESC);
+CREATE INDEX IF NOT EXISTS idx_backup_jobs_status ON backup_jobs (status);
+CREATE INDEX IF NOT EXISTS idx_backup_jobs_source ON backup_jobs (client_id, source_type, start_time DESC);
+
+-- Quarantined chunks table: chunks whose stored bytes no longer match their fingerprint
+CREATE TABLE IF NOT EXISTS quarantined_chunks (
+    fingerprint BYTES NOT NULL,
+    storage_node_id STRING NOT NULL,
+    reason STRING NOT NULL,
+    detected_time TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (fingerprint, storage_node_id)
+);
+
+-- Containers table: sealed groups of chunks stored as erasure-coded shards
+CREATE TABLE IF NOT EXISTS containers (
+    container_id STRING PRIMARY KEY,
+    data_shards INT NOT NULL,
+    parity_shards INT NOT NULL,
+    size INT8 NOT NULL, -- container size before padding
+    shard_size INT NOT NULL,
+    sealed_time TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Container shards table: where each data or parity shard of a container lives
+CREATE TABLE IF NOT EXISTS container_shards (
+    container_id STRING NOT NULL REFERENCES containers (container_id) ON DELETE CASCADE,
+    shard_index INT NOT NULL,
+    target STRING NOT NULL, -- storage node ID or backend path
+    fingerprint BYTES NOT NULL, -- binary Blake3 fingerprint of the shard, whose text form is its object key
+    PRIMARY KEY (container_id, shard_index)
+);
+
+CREATE INDEX IF NOT EXISTS idx_container_shards_target ON container_shards (target, fingerprint);
+
+-- File manifests table: every entry of a backup job with its POSIX metadata and chunks
+CREATE TABLE IF NOT EXISTS file_manifests (
+    job_id STRING NOT NULL,
+    file_path STRING NOT NULL,
+    file_type STRING NOT NULL, -- e.g., REGULAR, DIRECTORY, SYMLINK
+    size INT8 NOT NULL DEFAULT 0,
+    mtime TIMESTAMPTZ,
+    entry BYTES NOT NULL, -- serialized FileEntry: mode, owner, times, link target, xattrs
+    chunks BYTES[], -- binary chunk fingerprints of a regular file in order, with holes as the text hole:<bytes>
+    PRIMARY KEY (job_id, file_path)
+);
+
+-- Version of the chunking profile a file's chunks were made with. Manifests
+-- from before profiles were recorded were all made with version 1, which such
+-- repositories record as the legacy pattern profile.
+ALTER TABLE file_manifests ADD COLUMN IF NOT EXISTS chunking_profile INT8 NOT NULL DEFAULT 1;
+
+-- Trigram index for searching the paths of a client's jobs by substring or
+-- glob; searches name the jobs, which the index is prefixed by
+CREATE INVERTED INDEX IF NOT EXISTS idx_file_manifests_path_trgm ON file_manifests (job_id, file_path gin_trgm_ops);
+
+-- Chunk owners table: clients that have sent the data of a chunk, and so may
+-- reference it by fingerprint alone in source-side deduplicated backups
+CREATE TABLE IF NOT EXISTS chunk_owners (
+    client_id STRING NOT NULL,
+    fingerprint BYTES NOT NULL,
+    PRIMARY KEY (client_id, fingerprint)
+);
+
+-- Chunk features table: super-features of stored chunks, used to find a
+-- similar base for a new chunk
+CREATE TABLE IF NOT EXISTS chunk_features (
+    feature INT8 PRIMARY KEY,
+    fingerprint BYTES NOT NULL
+);
+
+-- Backup checkpoints table: durable progress of an unfinished backup job, from
+-- which a stream that broke can resume
+CREATE TABLE IF NOT EXISTS backup_checkpoints (
+    job_id STRING PRIMARY KEY,
+    base_job_id STRING,
+    last_file STRING, -- last entry recorded in file_manifests, in walk order
+    partial_file STRING, -- regular file whose chunks are stored up to partial_offset
+    partial_offset INT8 NOT NULL DEFAULT 0,
+    partial_entry BYTES, -- serialized FileEntry of partial_file
+    partial_chunks BYTES[], -- chunk references of partial_file up to partial_offset, stored like file_manifests.chunks
+    files_processed INT8 NOT NULL DEFAULT 0,
+    files_unchanged INT8 NOT NULL DEFAULT 0,
+    entries_processed INT8 NOT NULL DEFAULT 0,
+    chunks_processed INT8 NOT NULL DEFAULT 0,
+    bytes_processed INT8 NOT NULL DEFAULT 0,
+    bytes_deduplicated INT8 NOT NULL DEFAULT 0,
+    updated_time TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Chunks of the job stored as deltas, and the bytes that saved
+ALTER TABLE backup_checkpoints ADD COLUMN IF NOT EXISTS chunks_delta INT8 NOT NULL DEFAULT 0;
+ALTER TABLE backup_checkpoints ADD COLUMN IF NOT EXISTS bytes_delta_saved INT8 NOT NULL DEFAULT 0;
+
+-- State of the archive splitter following partial_file in an archive-aware
+-- job, so a resumed job splits the rest of the archive as before
+ALTER TABLE backup_checkpoints ADD COLUMN IF NOT EXISTS partial_archive BYTES;
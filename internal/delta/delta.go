@@ -0,0 +1,121 @@
+// Package delta encodes a chunk as the differences from a similar base chunk
+package delta
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// A delta is the target length followed by instructions that rebuild the
+// target from the base, each an opcode byte and uvarint operands:
+//
+//	opCopy offset length   copy length bytes of the base from offset
+//	opInsert length bytes  insert the next length bytes of the delta
+const (
+	opCopy   = 1
+	opInsert = 2
+
+	// matchSize is the shortest run of the base worth a copy instruction
+	matchSize = 16
+)
+
+// ErrCorrupt is returned for a delta that does not apply to its base
+var ErrCorrupt = errors.New("corrupt delta")
+
+// Encode returns a delta that rebuilds target from base
+func Encode(base, target []byte) []byte {
+	// Index every matchSize window of the base by its content; later
+	// positions overwrite earlier ones, which is as good for near-duplicates
+	index := make(map[uint64]int, len(base))
+	for i := 0; i+matchSize <= len(base); i++ {
+		index[windowKey(base[i:])] = i
+	}
+
+	out := binary.AppendUvarint(nil, uint64(len(target)))
+	literal := 0 // start of the bytes not yet emitted
+	for i := 0; i+matchSize <= len(target); {
+		offset, ok := index[windowKey(target[i:])]
+		if !ok || string(base[offset:offset+matchSize]) != string(target[i:i+matchSize]) {
+			i++
+			continue
+		}
+		// Extend the match backwards over pending literals and forwards
+		start := i
+		for start > literal && offset > 0 && base[offset-1] == target[start-1] {
+			start--
+			offset--
+		}
+		end, baseEnd := i+matchSize, offset+(i+matchSize-start)
+		for end < len(target) && baseEnd < len(base) && base[baseEnd] == target[end] {
+			end++
+			baseEnd++
+		}
+
+		out = appendInsert(out, target[literal:start])
+		out = append(out, opCopy)
+		out = binary.AppendUvarint(out, uint64(offset))
+		out = binary.AppendUvarint(out, uint64(end-start))
+		literal, i = end, end
+	}
+	return appendInsert(out, target[literal:])
+}
+
+// Apply rebuilds the target of a delta from its base
+func Apply(base, delta []byte) ([]byte, error) {
+	size, n := binary.Uvarint(delta)
+	if n <= 0 || size > uint64(len(base))+uint64(len(delta))*1<<16 {
+		return nil, ErrCorrupt
+	}
+	delta = delta[n:]
+	target := make([]byte, 0, size)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+		switch op {
+		case opCopy:
+			offset, n := binary.Uvarint(delta)
+			if n <= 0 {
+				return nil, ErrCorrupt
+			}
+			delta = delta[n:]
+			length, n := binary.Uvarint(delta)
+			if n <= 0 || offset > uint64(len(base)) || length > uint64(len(base))-offset {
+				return nil, ErrCorrupt
+			}
+			delta = delta[n:]
+			target = append(target, base[offset:offset+length]...)
+		case opInsert:
+			length, n := binary.Uvarint(delta)
+			if n <= 0 || length > uint64(len(delta)-n) {
+				return nil, ErrCorrupt
+			}
+			delta = delta[n:]
+			target = append(target, delta[:length]...)
+			delta = delta[length:]
+		default:
+			return nil, fmt.Errorf("%w: unknown instruction %d", ErrCorrupt, op)
+		}
+		if uint64(len(target)) > size {
+			return nil, ErrCorrupt
+		}
+	}
+	if uint64(len(target)) != size {
+		return nil, ErrCorrupt
+	}
+	return target, nil
+}
+
+func appendInsert(out, literal []byte) []byte {
+	if len(literal) == 0 {
+		return out
+	}
+	out = append(out, opInsert)
+	out = binary.AppendUvarint(out, uint64(len(literal)))
+	return append(out, literal...)
+}
+
+// windowKey hashes the first matchSize bytes of b
+func windowKey(b []byte) uint64 {
+	return binary.LittleEndian.Uint64(b)*0x9E3779B97F4A7C15 ^ binary.LittleEndian.Uint64(b[8:])
+}
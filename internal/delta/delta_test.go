@@ -0,0 +1,63 @@
+package delta
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestEncodeApply(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	base := make([]byte, 16384)
+	rng.Read(base)
+
+	edited := append([]byte(nil), base...)
+	copy(edited[100:], "an edit near the start")
+	edited = append(edited[:8000], append([]byte("inserted text"), edited[8000:]...)...)
+	edited = append(edited[:12000], edited[12500:]...)
+	unrelated := make([]byte, 4096)
+	rng.Read(unrelated)
+
+	tests := map[string][]byte{
+		"identical": base,
+		"edited":    edited,
+		"unrelated": unrelated,
+		"empty":     {},
+		"short":     []byte("tiny"),
+	}
+	for name, target := range tests {
+		delta := Encode(base, target)
+		got, err := Apply(base, delta)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(got, target) {
+			t.Errorf("%s: delta does not rebuild the target", name)
+		}
+	}
+
+	if delta := Encode(base, edited); len(delta) > 200 {
+		t.Errorf("Expected a small delta for a near-duplicate, got %d bytes", len(delta))
+	}
+}
+
+func TestApplyCorrupt(t *testing.T) {
+	base := bytes.Repeat([]byte("base data "), 100)
+	delta := Encode(base, append(base[:500:500], "changed"...))
+
+	tests := map[string][]byte{
+		"empty":      {},
+		"truncated":  delta[:len(delta)-3],
+		"bad opcode": append(append([]byte(nil), delta...), 9),
+		"past base":  {10, opCopy, 0xe8, 0x07, 10},
+		"long size":  {0xff, 0xff, 0xff, 0xff, 0x0f, opInsert, 1, 'a'},
+	}
+	for name, delta := range tests {
+		if _, err := Apply(base, delta); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := Apply(base[:100], delta); err == nil {
+		t.Error("Expected an error applying a delta to the wrong base")
+	}
+}
@@ -0,0 +1,302 @@
+package erasure
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/klauspost/reedsolomon"
+	"github.com/zeebo/blake3"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+)
+
+// ShardStore stores erasure-coded shards on a set of independent targets,
+// such as Data Storage Nodes or backend paths on separate disks
+type ShardStore interface {
+	// Targets returns the targets currently accepting new shards
+	Targets() []string
+	// PutShard stores a shard on target under its fingerprint
+	PutShard(ctx context.Context, target, fingerprint string, shard []byte) error
+	// GetShard reads a shard back from target
+	GetShard(ctx context.Context, target, fingerprint string) ([]byte, error)
+}
+
+// ShardLocation records where one shard of a container is stored
+type ShardLocation struct {
+	Index       int
+	Target      string
+	Fingerprint string // object key of the shard: the text form of its fingerprint
+}
+
+// ContainerLayout describes a sealed, erasure-coded container
+type ContainerLayout struct {
+	ContainerID  string
+	DataShards   int
+	ParityShards int
+	Size         int64 // container size before padding
+	ShardSize    int
+	Shards       []ShardLocation // one per shard, ordered by index
+}
+
+// Store writes sealed containers as k data shards plus m parity shards
+type Store struct {
+	shards       ShardStore
+	dataShards   int
+	parityShards int
+	encoder      reedsolomon.Encoder
+}
+
+// NewStore creates an erasure-coded container store with dataShards data
+// shards and parityShards parity shards per container
+func NewStore(shards ShardStore, dataShards, parityShards int) (*Store, error) {
+	encoder, err := reedsolomon.New(dataShards, parityShards)
+	if err != nil {
+		return nil, fmt.Errorf("invalid erasure coding %d+%d: %w", dataShards, parityShards, err)
+	}
+	return &Store{
+		shards:       shards,
+		dataShards:   dataShards,
+		parityShards: parityShards,
+		encoder:      encoder,
+	}, nil
+}
+
+// Put encodes a sealed container and writes every shard to its own target.
+// All shards must be written for the container to be accepted.
+func (s *Store) Put(ctx context.Context, containerID string, data []byte) (*ContainerLayout, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("container %s is empty", containerID)
+	}
+
+	shards, err := s.encoder.Split(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split container %s: %w", containerID, err)
+	}
+	if err := s.encoder.Encode(shards); err != nil {
+		return nil, fmt.Errorf("failed to encode container %s: %w", containerID, err)
+	}
+
+	targets, err := s.pickTargets(containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	layout := &ContainerLayout{
+		ContainerID:  containerID,
+		DataShards:   s.dataShards,
+		ParityShards: s.parityShards,
+		Size:         int64(len(data)),
+		ShardSize:    len(shards[0]),
+		Shards:       make([]ShardLocation, len(shards)),
+	}
+	for i, shard := range shards {
+		layout.Shards[i] = ShardLocation{Index: i, Target: targets[i], Fingerprint: chunking.ComputeFingerprint(shard).String()}
+	}
+
+	if err := s.writeShards(ctx, layout, shards, allIndexes(len(shards))); err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+// Get reads a container, reconstructing it if up to ParityShards shards are
+// missing or corrupt
+func (s *Store) Get(ctx context.Context, layout *ContainerLayout) ([]byte, error) {
+	shards, missing := s.readShards(ctx, layout)
+	if len(missing) > 0 {
+		if len(missing) > layout.ParityShards {
+			return nil, fmt.Errorf("container %s: %d shards missing, at most %d can be recovered", layout.ContainerID, len(missing), layout.ParityShards)
+		}
+		if err := s.encoder.ReconstructData(shards); err != nil {
+			return nil, fmt.Errorf("failed to reconstruct container %s: %w", layout.ContainerID, err)
+		}
+	}
+
+	data := make([]byte, 0, layout.Size)
+	for i := 0; i < layout.DataShards; i++ {
+		data = append(data, shards[i]...)
+	}
+	return data[:layout.Size], nil
+}
+
+// ReadRange reads length bytes at offset from a container
+func (s *Store) ReadRange(ctx context.Context, layout *ContainerLayout, offset, length int64) ([]byte, error) {
+	if offset < 0 || length < 0 || offset+length > layout.Size {
+		return nil, fmt.Errorf("range %d+%d outside container %s of %d bytes", offset, length, layout.ContainerID, layout.Size)
+	}
+	data, err := s.Get(ctx, layout)
+	if err != nil {
+		return nil, err
+	}
+	return data[offset : offset+length], nil
+}
+
+// Repair rebuilds missing or corrupt shards and returns the updated layout
+// along with the number of shards rewritten. A rebuilt shard goes back to
+// its original target when that target still accepts writes.
+func (s *Store) Repair(ctx context.Context, layout *ContainerLayout) (*ContainerLayout, int, error) {
+	shards, missing := s.readShards(ctx, layout)
+	if len(missing) == 0 {
+		return layout, 0, nil
+	}
+	if len(missing) > layout.ParityShards {
+		return layout, 0, fmt.Errorf("container %s: %d shards missing, at most %d can be recovered", layout.ContainerID, len(missing), layout.ParityShards)
+	}
+	if err := s.encoder.Reconstruct(shards); err != nil {
+		return layout, 0, fmt.Errorf("failed to reconstruct container %s: %w", layout.ContainerID, err)
+	}
+
+	repaired := *layout
+	repaired.Shards = append([]ShardLocation(nil), layout.Shards...)
+
+	available := make(map[string]bool)
+	for _, target := range s.shards.Targets() {
+		available[target] = true
+	}
+	// Targets keeping a shard, including shards rebuilt in place, are taken
+	used := make(map[string]bool)
+	for i, loc := range repaired.Shards {
+		if !containsIndex(missing, i) || available[loc.Target] {
+			used[loc.Target] = true
+		}
+	}
+	for _, i := range missing {
+		if available[repaired.Shards[i].Target] {
+			continue
+		}
+		target, err := s.replacementTarget(layout.ContainerID, used)
+		if err != nil {
+			return layout, 0, err
+		}
+		repaired.Shards[i].Target = target
+		used[target] = true
+	}
+
+	if err := s.writeShards(ctx, &repaired, shards, missing); err != nil {
+		return layout, 0, err
+	}
+	return &repaired, len(missing), nil
+}
+
+// readShards fetches every shard in parallel. Shards that cannot be read or
+// whose data does not match the recorded fingerprint are left nil.
+func (s *Store) readShards(ctx context.Context, layout *ContainerLayout) ([][]byte, []int) {
+	shards := make([][]byte, len(layout.Shards))
+	var wg sync.WaitGroup
+	for i, loc := range layout.Shards {
+		wg.Add(1)
+		go func(i int, loc ShardLocation) {
+			defer wg.Done()
+			shard, err := s.shards.GetShard(ctx, loc.Target, loc.Fingerprint)
+			if err != nil {
+				log.Printf("Warning: Shard %d of container %s unavailable on %s: %v", i, layout.ContainerID, loc.Target, err)
+				return
+			}
+			fingerprint, err := chunking.ParseFingerprint(loc.Fingerprint)
+			if err != nil || len(shard) != layout.ShardSize || !chunking.VerifyFingerprint(shard, fingerprint) {
+				log.Printf("Warning: Shard %d of container %s on %s is corrupt", i, layout.ContainerID, loc.Target)
+				return
+			}
+			shards[i] = shard
+		}(i, loc)
+	}
+	wg.Wait()
+
+	var missing []int
+	for i, shard := range shards {
+		if shard == nil {
+			missing = append(missing, i)
+		}
+	}
+	return shards, missing
+}
+
+// writeShards writes the shards at the given indexes in parallel
+func (s *Store) writeShards(ctx context.Context, layout *ContainerLayout, shards [][]byte, indexes []int) error {
+	errs := make([]error, len(indexes))
+	var wg sync.WaitGroup
+	for n, i := range indexes {
+		wg.Add(1)
+		go func(n, i int) {
+			defer wg.Done()
+			loc := layout.Shards[i]
+			errs[n] = s.shards.PutShard(ctx, loc.Target, loc.Fingerprint, shards[i])
+		}(n, i)
+	}
+	wg.Wait()
+
+	for n, err := range errs {
+		if err != nil {
+			i := indexes[n]
+			return fmt.Errorf("failed to write shard %d of container %s to %s: %w", i, layout.ContainerID, layout.Shards[i].Target, err)
+		}
+	}
+	return nil
+}
+
+// pickTargets assigns a target to each shard using rendezvous hashing, so
+// shards of one container land on distinct targets whenever there are at
+// least k+m of them. With fewer targets shards wrap around and a single
+// target failure can cost more than one shard.
+func (s *Store) pickTargets(containerID string) ([]string, error) {
+	ranked := rankTargets(containerID, s.shards.Targets())
+	if len(ranked) == 0 {
+		return nil, fmt.Errorf("no shard targets available")
+	}
+	total := s.dataShards + s.parityShards
+	if len(ranked) < total {
+		log.Printf("Warning: Only %d shard targets for %d shards; container %s tolerates fewer failures", len(ranked), total, containerID)
+	}
+	targets := make([]string, total)
+	for i := range targets {
+		targets[i] = ranked[i%len(ranked)]
+	}
+	return targets, nil
+}
+
+// replacementTarget picks the highest-ranked available target not already
+// holding a shard of the container, falling back to any available target
+func (s *Store) replacementTarget(containerID string, used map[string]bool) (string, error) {
+	ranked := rankTargets(containerID, s.shards.Targets())
+	for _, target := range ranked {
+		if !used[target] {
+			return target, nil
+		}
+	}
+	if len(ranked) > 0 {
+		return ranked[0], nil
+	}
+	return "", fmt.Errorf("no shard targets available")
+}
+
+// rankTargets orders targets by their rendezvous hash weight for key
+func rankTargets(key string, targets []string) []string {
+	ranked := append([]string(nil), targets...)
+	weight := func(target string) uint64 {
+		sum := blake3.Sum256([]byte(key + "/" + target))
+		return binary.BigEndian.Uint64(sum[:8])
+	}
+	sort.Slice(ranked, func(i, j int) bool { return weight(ranked[i]) > weight(ranked[j]) })
+	return ranked
+}
+
+func allIndexes(n int) []int {
+	indexes := make([]int, n)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return indexes
+}
+
+func containsIndex(indexes []int, i int) bool {
+	for _, index := range indexes {
+		if index == i {
+			return true
+		}
+	}
+	return false
+}
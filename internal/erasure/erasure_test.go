@@ -0,0 +1,224 @@
+package erasure
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/db"
+)
+
+// newTestStore creates a 6+3 store over nine temporary backend paths
+func newTestStore(t *testing.T) (*Store, []string) {
+	t.Helper()
+	root := t.TempDir()
+	paths := make([]string, 9)
+	for i := range paths {
+		paths[i] = filepath.Join(root, fmt.Sprintf("disk%d", i))
+	}
+	shards, err := NewDirShardStore(paths)
+	if err != nil {
+		t.Fatalf("Failed to create shard store: %v", err)
+	}
+	store, err := NewStore(shards, 6, 3)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	return store, paths
+}
+
+func putTestContainer(t *testing.T, store *Store, size int) ([]byte, *ContainerLayout) {
+	t.Helper()
+	data, err := chunking.GenerateRandomChunk(size)
+	if err != nil {
+		t.Fatalf("Failed to generate data: %v", err)
+	}
+	layout, err := store.Put(context.Background(), "container-1", append([]byte(nil), data...))
+	if err != nil {
+		t.Fatalf("Failed to put container: %v", err)
+	}
+	return data, layout
+}
+
+func removeShard(t *testing.T, loc ShardLocation) {
+	t.Helper()
+	if err := os.Remove(filepath.Join(loc.Target, loc.Fingerprint)); err != nil {
+		t.Fatalf("Failed to remove shard %d: %v", loc.Index, err)
+	}
+}
+
+func TestPutSpreadsShardsAcrossTargets(t *testing.T) {
+	store, _ := newTestStore(t)
+	data, layout := putTestContainer(t, store, 100000)
+
+	if len(layout.Shards) != 9 {
+		t.Fatalf("Expected 9 shards, got %d", len(layout.Shards))
+	}
+	targets := make(map[string]bool)
+	for _, loc := range layout.Shards {
+		targets[loc.Target] = true
+	}
+	if len(targets) != 9 {
+		t.Errorf("Expected shards on 9 distinct targets, got %d", len(targets))
+	}
+
+	got, err := store.Get(context.Background(), layout)
+	if err != nil {
+		t.Fatalf("Failed to get container: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Container data does not round-trip")
+	}
+
+	part, err := store.ReadRange(context.Background(), layout, 1000, 500)
+	if err != nil {
+		t.Fatalf("Failed to read range: %v", err)
+	}
+	if !bytes.Equal(part, data[1000:1500]) {
+		t.Error("Range read returned wrong data")
+	}
+}
+
+func TestGetReconstructsMissingShards(t *testing.T) {
+	store, _ := newTestStore(t)
+	data, layout := putTestContainer(t, store, 65537)
+
+	// Lose two data shards and corrupt a parity shard: three failures for m=3
+	removeShard(t, layout.Shards[0])
+	removeShard(t, layout.Shards[4])
+	corrupt := layout.Shards[7]
+	if err := os.WriteFile(filepath.Join(corrupt.Target, corrupt.Fingerprint), bytes.Repeat([]byte{1}, layout.ShardSize), 0o644); err != nil {
+		t.Fatalf("Failed to corrupt shard: %v", err)
+	}
+
+	got, err := store.Get(context.Background(), layout)
+	if err != nil {
+		t.Fatalf("Expected reconstruction to succeed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Reconstructed data does not match")
+	}
+
+	// A fourth failure is beyond the parity budget
+	removeShard(t, layout.Shards[2])
+	if _, err := store.Get(context.Background(), layout); err == nil {
+		t.Fatal("Expected read to fail with four shards missing")
+	}
+}
+
+func TestRepairRebuildsLostShards(t *testing.T) {
+	store, paths := newTestStore(t)
+	data, layout := putTestContainer(t, store, 50000)
+
+	// One shard is lost on a healthy disk, another disk disappears entirely
+	removeShard(t, layout.Shards[1])
+	lostDisk := layout.Shards[5].Target
+	if err := os.RemoveAll(lostDisk); err != nil {
+		t.Fatalf("Failed to remove disk: %v", err)
+	}
+
+	// Replace the lost disk with a spare so shards stay on distinct targets
+	spare := filepath.Join(filepath.Dir(paths[0]), "spare")
+	remaining := []string{spare}
+	for _, path := range paths {
+		if path != lostDisk {
+			remaining = append(remaining, path)
+		}
+	}
+	spareStore, err := NewDirShardStore(remaining)
+	if err != nil {
+		t.Fatalf("Failed to create shard store: %v", err)
+	}
+	store.shards = spareStore
+
+	repaired, rebuilt, err := store.Repair(context.Background(), layout)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if rebuilt != 2 {
+		t.Errorf("Expected 2 shards rebuilt, got %d", rebuilt)
+	}
+	if repaired.Shards[1].Target != layout.Shards[1].Target {
+		t.Errorf("Expected shard 1 to be rebuilt in place, moved to %s", repaired.Shards[1].Target)
+	}
+	if repaired.Shards[5].Target != spare {
+		t.Errorf("Expected shard 5 to move to the spare disk, got %s", repaired.Shards[5].Target)
+	}
+
+	// The repaired container survives losing three more shards
+	removeShard(t, repaired.Shards[0])
+	removeShard(t, repaired.Shards[3])
+	removeShard(t, repaired.Shards[8])
+	got, err := store.Get(context.Background(), repaired)
+	if err != nil {
+		t.Fatalf("Failed to read repaired container: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Repaired container data does not match")
+	}
+}
+
+// memContainerIndex is an in-memory ContainerIndex
+type memContainerIndex struct {
+	containers map[string]*db.Container
+}
+
+func (m *memContainerIndex) ListContainers(ctx context.Context, after string, limit int) ([]db.Container, error) {
+	var ids []string
+	for id := range m.containers {
+		if id > after {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	page := make([]db.Container, len(ids))
+	for i, id := range ids {
+		page[i] = *m.containers[id]
+	}
+	return page, nil
+}
+
+func (m *memContainerIndex) UpdateContainerShards(ctx context.Context, containerID string, shards []db.ContainerShard) error {
+	m.containers[containerID].Shards = shards
+	return nil
+}
+
+func TestRepairAll(t *testing.T) {
+	store, _ := newTestStore(t)
+	index := &memContainerIndex{containers: make(map[string]*db.Container)}
+
+	var layouts []*ContainerLayout
+	for i := 0; i < 3; i++ {
+		data, _ := chunking.GenerateRandomChunk(20000)
+		layout, err := store.Put(context.Background(), fmt.Sprintf("container-%d", i), data)
+		if err != nil {
+			t.Fatalf("Failed to put container: %v", err)
+		}
+		layouts = append(layouts, layout)
+		index.containers[layout.ContainerID] = layout.ToDB()
+	}
+
+	removeShard(t, layouts[0].Shards[2])
+	for _, i := range []int{0, 1, 2, 3} {
+		removeShard(t, layouts[2].Shards[i])
+	}
+
+	report, err := store.RepairAll(context.Background(), index)
+	if err != nil {
+		t.Fatalf("RepairAll failed: %v", err)
+	}
+	if report.ContainersChecked != 3 || report.ContainersRepaired != 1 || report.ShardsRebuilt != 1 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if len(report.Unrecoverable) != 1 || report.Unrecoverable[0] != "container-2" {
+		t.Errorf("Expected container-2 to be unrecoverable, got %v", report.Unrecoverable)
+	}
+}
@@ -0,0 +1,118 @@
+package erasure
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/db"
+)
+
+// repairPageSize is the number of containers checked at a time
+const repairPageSize = 100
+
+// ContainerIndex is the container metadata store walked by the repair job
+type ContainerIndex interface {
+	ListContainers(ctx context.Context, after string, limit int) ([]db.Container, error)
+	UpdateContainerShards(ctx context.Context, containerID string, shards []db.ContainerShard) error
+}
+
+// RepairReport summarises a repair pass
+type RepairReport struct {
+	ContainersChecked  int
+	ContainersRepaired int
+	ShardsRebuilt      int
+	Unrecoverable      []string // containers with more than ParityShards shards lost
+}
+
+// RepairAll checks every container in index and rebuilds lost or corrupt shards
+func (s *Store) RepairAll(ctx context.Context, index ContainerIndex) (*RepairReport, error) {
+	report := &RepairReport{}
+	after := ""
+
+	for {
+		page, err := index.ListContainers(ctx, after, repairPageSize)
+		if err != nil {
+			return report, fmt.Errorf("failed to list containers: %w", err)
+		}
+		for i := range page {
+			container := &page[i]
+			report.ContainersChecked++
+
+			layout := LayoutFromDB(container)
+			repaired, rebuilt, err := s.Repair(ctx, layout)
+			if err != nil {
+				log.Printf("Warning: Failed to repair container %s: %v", container.ContainerID, err)
+				report.Unrecoverable = append(report.Unrecoverable, container.ContainerID)
+				continue
+			}
+			if rebuilt == 0 {
+				continue
+			}
+			if err := index.UpdateContainerShards(ctx, container.ContainerID, repaired.ToDB().Shards); err != nil {
+				return report, fmt.Errorf("failed to record repaired shards of container %s: %w", container.ContainerID, err)
+			}
+			report.ContainersRepaired++
+			report.ShardsRebuilt += rebuilt
+		}
+		if len(page) < repairPageSize {
+			return report, nil
+		}
+		after = page[len(page)-1].ContainerID
+	}
+}
+
+// RunRepairPeriodically runs RepairAll every interval until ctx is cancelled
+func (s *Store) RunRepairPeriodically(ctx context.Context, index ContainerIndex, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := s.RepairAll(ctx, index)
+			if err != nil {
+				log.Printf("Container repair failed: %v", err)
+				continue
+			}
+			log.Printf("Container repair completed: %d checked, %d repaired, %d shards rebuilt, %d unrecoverable",
+				report.ContainersChecked, report.ContainersRepaired, report.ShardsRebuilt, len(report.Unrecoverable))
+		}
+	}
+}
+
+// LayoutFromDB converts a stored container into a layout
+func LayoutFromDB(c *db.Container) *ContainerLayout {
+	layout := &ContainerLayout{
+		ContainerID:  c.ContainerID,
+		DataShards:   c.DataShards,
+		ParityShards: c.ParityShards,
+		Size:         c.Size,
+		ShardSize:    c.ShardSize,
+		Shards:       make([]ShardLocation, len(c.Shards)),
+	}
+	for i, shard := range c.Shards {
+		layout.Shards[i] = ShardLocation{Index: shard.Index, Target: shard.Target, Fingerprint: shard.Fingerprint}
+	}
+	return layout
+}
+
+// ToDB converts a layout into its stored form
+func (l *ContainerLayout) ToDB() *db.Container {
+	c := &db.Container{
+		ContainerID:  l.ContainerID,
+		DataShards:   l.DataShards,
+		ParityShards: l.ParityShards,
+		Size:         l.Size,
+		ShardSize:    l.ShardSize,
+		SealedTime:   time.Now(),
+		Shards:       make([]db.ContainerShard, len(l.Shards)),
+	}
+	for i, shard := range l.Shards {
+		c.Shards[i] = db.ContainerShard{Index: shard.Index, Target: shard.Target, Fingerprint: shard.Fingerprint}
+	}
+	return c
+}
@@ -0,0 +1,118 @@
+package erasure
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/placement"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// DirShardStore keeps shards as files under a set of backend paths, each
+// expected to live on a separate disk. The path itself is the target name.
+type DirShardStore struct {
+	paths []string
+}
+
+// NewDirShardStore creates the backend directories if needed
+func NewDirShardStore(paths []string) (*DirShardStore, error) {
+	for _, path := range paths {
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create shard directory %s: %w", path, err)
+		}
+	}
+	return &DirShardStore{paths: append([]string(nil), paths...)}, nil
+}
+
+// Targets returns the backend paths that are still present
+func (d *DirShardStore) Targets() []string {
+	var targets []string
+	for _, path := range d.paths {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			targets = append(targets, path)
+		}
+	}
+	return targets
+}
+
+// PutShard writes a shard file atomically
+func (d *DirShardStore) PutShard(ctx context.Context, target, fingerprint string, shard []byte) error {
+	tmp, err := os.CreateTemp(target, ".shard-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(shard); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(target, fingerprint))
+}
+
+// GetShard reads a shard file
+func (d *DirShardStore) GetShard(ctx context.Context, target, fingerprint string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(target, fingerprint))
+}
+
+// NodeShardStore keeps shards on Data Storage Nodes, one node per target.
+// Shards are stored like chunks under their Blake3 fingerprint, so the
+// storage node verifies each shard as it is written.
+type NodeShardStore struct {
+	cluster *placement.Cluster
+}
+
+// NewNodeShardStore stores shards on the nodes of cluster
+func NewNodeShardStore(cluster *placement.Cluster) *NodeShardStore {
+	return &NodeShardStore{cluster: cluster}
+}
+
+// Targets returns the IDs of the storage nodes accepting writes
+func (n *NodeShardStore) Targets() []string {
+	return n.cluster.WritableNodes()
+}
+
+// PutShard stores a shard on a storage node
+func (n *NodeShardStore) PutShard(ctx context.Context, target, fingerprint string, shard []byte) error {
+	client := n.cluster.Client(target)
+	if client == nil {
+		return fmt.Errorf("unknown storage node %s", target)
+	}
+	resp, err := client.StoreChunk(ctx, &pb.StoreChunkRequest{
+		Fingerprint: fingerprint,
+		ChunkData:   shard,
+		Size:        int64(len(shard)),
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("%s", resp.ErrorMessage)
+	}
+	return nil
+}
+
+// GetShard reads a shard from a storage node
+func (n *NodeShardStore) GetShard(ctx context.Context, target, fingerprint string) ([]byte, error) {
+	client := n.cluster.Client(target)
+	if client == nil {
+		return nil, fmt.Errorf("unknown storage node %s", target)
+	}
+	resp, err := client.GetChunk(ctx, &pb.GetChunkRequest{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Found {
+		return nil, fmt.Errorf("shard %s not found", fingerprint)
+	}
+	return resp.ChunkData, nil
+}
@@ -0,0 +1,491 @@
+// Package fsmeta captures POSIX file metadata for backup and reapplies it on restore
+package fsmeta
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// Unix permission bits beyond the plain rwx bits
+const (
+	modeSetuid = 0o4000
+	modeSetgid = 0o2000
+	modeSticky = 0o1000
+)
+
+// HardLinks remembers the first path seen for every multiply-linked file, so
+// later names of the same file are captured as hard links rather than copies
+type HardLinks struct {
+	first map[fileID]string
+	mutex sync.Mutex
+}
+
+type fileID struct {
+	device uint64
+	inode  uint64
+}
+
+// NewHardLinks creates an empty hard link tracker
+func NewHardLinks() *HardLinks {
+	return &HardLinks{first: make(map[fileID]string)}
+}
+
+// seen returns the first path recorded for id, recording path if there is none
+func (h *HardLinks) seen(id fileID, path string) (string, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if first, ok := h.first[id]; ok {
+		return first, true
+	}
+	h.first[id] = path
+	return "", false
+}
+
+// Capture describes the entry at path. info must come from os.Lstat so that
+// symlinks are captured rather than followed. links may be nil to capture
+// every name of a multiply-linked file as a separate regular file.
+func Capture(path string, info fs.FileInfo, links *HardLinks) (*pb.FileEntry, error) {
+	entry := &pb.FileEntry{
+		FilePath: path,
+		Type:     fileType(info.Mode()),
+		Mode:     unixMode(info.Mode()),
+		MtimeNs:  info.ModTime().UnixNano(),
+	}
+	if entry.Type == pb.FileType_FILE_TYPE_UNSPECIFIED {
+		return nil, fmt.Errorf("%s: unsupported file type %s", path, info.Mode().Type())
+	}
+	if entry.Type == pb.FileType_FILE_TYPE_REGULAR {
+		entry.Size = uint64(info.Size())
+	}
+	if entry.Type == pb.FileType_FILE_TYPE_SYMLINK {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		entry.LinkTarget = target
+	}
+
+	stat, ok := statOf(info)
+	if ok {
+		entry.Uid = stat.uid
+		entry.Gid = stat.gid
+		entry.AtimeNs = stat.atimeNs
+		entry.Inode = stat.inode
+		entry.CtimeNs = stat.ctimeNs
+		entry.Owner = userName(stat.uid)
+		entry.Group = groupName(stat.gid)
+		if entry.Type == pb.FileType_FILE_TYPE_CHAR_DEVICE || entry.Type == pb.FileType_FILE_TYPE_BLOCK_DEVICE {
+			entry.Rdev = stat.rdev
+		}
+		if links != nil && entry.Type == pb.FileType_FILE_TYPE_REGULAR && stat.nlink > 1 {
+			if first, dup := links.seen(fileID{device: stat.device, inode: stat.inode}, path); dup {
+				entry.Type = pb.FileType_FILE_TYPE_HARDLINK
+				entry.LinkTarget = first
+			}
+		}
+	}
+
+	xattrs, err := listXattrs(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to read extended attributes: %w", path, err)
+	}
+	entry.Xattrs = xattrs
+	return entry, nil
+}
+
+// StreamEntry returns the metadata of a regular file read from a source of
+// unknown size, such as standard input: owned by the current user and
+// modified now. Its size is recorded once the source ends.
+func StreamEntry(path string) *pb.FileEntry {
+	uid, gid := uint32(os.Getuid()), uint32(os.Getgid())
+	now := time.Now().UnixNano()
+	return &pb.FileEntry{
+		FilePath: path,
+		Type:     pb.FileType_FILE_TYPE_REGULAR,
+		Mode:     0o644,
+		Uid:      uid,
+		Gid:      gid,
+		Owner:    userName(uid),
+		Group:    groupName(gid),
+		MtimeNs:  now,
+		AtimeNs:  now,
+	}
+}
+
+// fileType maps a Go file mode to a FileType
+func fileType(mode fs.FileMode) pb.FileType {
+	switch {
+	case mode.IsRegular():
+		return pb.FileType_FILE_TYPE_REGULAR
+	case mode.IsDir():
+		return pb.FileType_FILE_TYPE_DIRECTORY
+	case mode&fs.ModeSymlink != 0:
+		return pb.FileType_FILE_TYPE_SYMLINK
+	case mode&fs.ModeNamedPipe != 0:
+		return pb.FileType_FILE_TYPE_FIFO
+	case mode&fs.ModeDevice != 0 && mode&fs.ModeCharDevice != 0:
+		return pb.FileType_FILE_TYPE_CHAR_DEVICE
+	case mode&fs.ModeDevice != 0:
+		return pb.FileType_FILE_TYPE_BLOCK_DEVICE
+	}
+	return pb.FileType_FILE_TYPE_UNSPECIFIED // sockets and unknown types
+}
+
+// unixMode converts Go permission and special bits to Unix mode bits
+func unixMode(mode fs.FileMode) uint32 {
+	bits := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		bits |= modeSetuid
+	}
+	if mode&fs.ModeSetgid != 0 {
+		bits |= modeSetgid
+	}
+	if mode&fs.ModeSticky != 0 {
+		bits |= modeSticky
+	}
+	return bits
+}
+
+// goMode converts Unix mode bits back to a Go file mode
+func goMode(bits uint32) fs.FileMode {
+	mode := fs.FileMode(bits & 0o777)
+	if bits&modeSetuid != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if bits&modeSetgid != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if bits&modeSticky != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode
+}
+
+// Name lookups are cached since a tree usually has few distinct owners
+var (
+	namesMutex sync.Mutex
+	userNames  = make(map[uint32]string)
+	groupNames = make(map[uint32]string)
+)
+
+func userName(uid uint32) string {
+	namesMutex.Lock()
+	defer namesMutex.Unlock()
+	name, ok := userNames[uid]
+	if !ok {
+		if u, err := user.LookupId(strconv.FormatUint(uint64(uid), 10)); err == nil {
+			name = u.Username
+		}
+		userNames[uid] = name
+	}
+	return name
+}
+
+func groupName(gid uint32) string {
+	namesMutex.Lock()
+	defer namesMutex.Unlock()
+	name, ok := groupNames[gid]
+	if !ok {
+		if g, err := user.LookupGroupId(strconv.FormatUint(uint64(gid), 10)); err == nil {
+			name = g.Name
+		}
+		groupNames[gid] = name
+	}
+	return name
+}
+
+// resolveOwner returns the uid and gid to restore, preferring the recorded
+// names when they exist on this system
+func resolveOwner(entry *pb.FileEntry) (int, int) {
+	uid, gid := int(entry.Uid), int(entry.Gid)
+	if entry.Owner != "" {
+		if u, err := user.Lookup(entry.Owner); err == nil {
+			if id, err := strconv.Atoi(u.Uid); err == nil {
+				uid = id
+			}
+		}
+	}
+	if entry.Group != "" {
+		if g, err := user.LookupGroup(entry.Group); err == nil {
+			if id, err := strconv.Atoi(g.Gid); err == nil {
+				gid = id
+			}
+		}
+	}
+	return uid, gid
+}
+
+// ExistingPolicy decides what a Restorer does with an entry whose target
+// already exists. Directories are always merged into an existing directory.
+type ExistingPolicy int
+
+const (
+	Overwrite    ExistingPolicy = iota // replace the existing entry
+	SkipExisting                       // keep the existing entry and skip the restored one
+	RenameNew                          // restore next to the existing entry under a new name
+)
+
+// ErrSkipped is returned by Begin for an entry skipped under SkipExisting
+var ErrSkipped = errors.New("target exists")
+
+// Restorer recreates entries under a destination directory and reapplies
+// their metadata. Directory metadata is applied by Close, after everything
+// inside the directories has been written. Regular files are written to a
+// temporary file that End renames into place, so a failed restore never
+// leaves a partial file under the target name. A Restorer may be used from
+// several goroutines.
+type Restorer struct {
+	root     string
+	existing ExistingPolicy
+
+	mutex    sync.Mutex
+	restored map[string]string // original path -> restored path, for hard links
+	temps    map[string]string // temporary file -> target, for files being written
+	dirs     []*pb.FileEntry
+}
+
+// NewRestorer restores entries below root, replacing existing entries
+func NewRestorer(root string) *Restorer {
+	return NewRestorerWithPolicy(root, Overwrite)
+}
+
+// NewRestorerWithPolicy restores entries below root, handling existing
+// entries as policy says
+func NewRestorerWithPolicy(root string, policy ExistingPolicy) *Restorer {
+	return &Restorer{
+		root:     root,
+		existing: policy,
+		restored: make(map[string]string),
+		temps:    make(map[string]string),
+	}
+}
+
+// Target returns where an entry's original path is restored to. A path
+// whose .. elements would lead out of the restorer's root is rejected.
+func (r *Restorer) Target(originalPath string) (string, error) {
+	rel := strings.TrimPrefix(filepath.ToSlash(originalPath), filepath.VolumeName(originalPath))
+	target := filepath.Join(r.root, filepath.FromSlash(strings.TrimLeft(rel, "/")))
+	inside, err := filepath.Rel(r.root, target)
+	if err != nil || inside == ".." || strings.HasPrefix(inside, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: path leads outside the restore destination", originalPath)
+	}
+	return target, nil
+}
+
+// mkdirs creates the directories of path below the restorer's root. Unlike
+// os.MkdirAll it follows no symbolic links, so that a link restored earlier
+// cannot lead later entries outside the root.
+func (r *Restorer) mkdirs(path string, perm fs.FileMode) error {
+	rel, err := filepath.Rel(r.root, path)
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return nil
+	}
+	dir := r.root
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		dir = filepath.Join(dir, name)
+		info, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			if err := os.Mkdir(dir, perm); err != nil && !os.IsExist(err) {
+				return err
+			}
+			if info, err = os.Lstat(dir); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s: not a directory", dir)
+		}
+	}
+	return nil
+}
+
+// Begin creates an entry. For a regular file it returns the file to write
+// the contents to, which must be passed to End; every other type is
+// complete when Begin returns.
+func (r *Restorer) Begin(entry *pb.FileEntry) (*os.File, error) {
+	target, err := r.place(entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.mkdirs(filepath.Dir(target), 0o755); err != nil {
+		return nil, err
+	}
+
+	switch entry.Type {
+	case pb.FileType_FILE_TYPE_REGULAR:
+		file, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".restore-*")
+		if err != nil {
+			return nil, err
+		}
+		r.mutex.Lock()
+		r.temps[file.Name()] = target
+		r.mutex.Unlock()
+		return file, nil
+
+	case pb.FileType_FILE_TYPE_DIRECTORY:
+		// Owner write access is needed until the contents are restored
+		if err := r.mkdirs(target, 0o700); err != nil {
+			return nil, err
+		}
+		r.mutex.Lock()
+		r.dirs = append(r.dirs, entry)
+		r.mutex.Unlock()
+		return nil, nil
+
+	case pb.FileType_FILE_TYPE_HARDLINK:
+		r.mutex.Lock()
+		first, ok := r.restored[entry.LinkTarget]
+		r.mutex.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("%s: hard link target %s was not restored", entry.FilePath, entry.LinkTarget)
+		}
+		if err := removeExisting(target); err != nil {
+			return nil, err
+		}
+		return nil, os.Link(first, target)
+
+	case pb.FileType_FILE_TYPE_SYMLINK:
+		if err := removeExisting(target); err != nil {
+			return nil, err
+		}
+		if err := os.Symlink(entry.LinkTarget, target); err != nil {
+			return nil, err
+		}
+		return nil, applyMetadata(target, entry)
+
+	case pb.FileType_FILE_TYPE_FIFO, pb.FileType_FILE_TYPE_CHAR_DEVICE, pb.FileType_FILE_TYPE_BLOCK_DEVICE:
+		if err := removeExisting(target); err != nil {
+			return nil, err
+		}
+		if err := makeSpecial(target, entry); err != nil {
+			return nil, err
+		}
+		return nil, applyMetadata(target, entry)
+	}
+	return nil, fmt.Errorf("%s: unsupported file type %s", entry.FilePath, entry.Type)
+}
+
+// place returns where an entry is restored to under the restorer's policy,
+// or ErrSkipped
+func (r *Restorer) place(entry *pb.FileEntry) (string, error) {
+	target, err := r.Target(entry.FilePath)
+	if err != nil {
+		return "", err
+	}
+	if entry.Type == pb.FileType_FILE_TYPE_DIRECTORY || r.existing == Overwrite {
+		return target, nil
+	}
+	if _, err := os.Lstat(target); os.IsNotExist(err) {
+		return target, nil
+	} else if err != nil {
+		return "", err
+	}
+	if r.existing == SkipExisting {
+		return "", ErrSkipped
+	}
+	for n := 1; ; n++ {
+		renamed := fmt.Sprintf("%s.restored-%d", target, n)
+		if _, err := os.Lstat(renamed); os.IsNotExist(err) {
+			return renamed, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+}
+
+// End closes a regular file opened by Begin, applies its metadata and
+// renames it into place. The file is extended to its recorded size, so holes
+// skipped over by seeking, including a trailing one, stay sparse.
+func (r *Restorer) End(entry *pb.FileEntry, file *os.File) error {
+	r.mutex.Lock()
+	target, ok := r.temps[file.Name()]
+	delete(r.temps, file.Name())
+	r.mutex.Unlock()
+	if !ok {
+		file.Close()
+		return fmt.Errorf("%s: file was not begun by this restorer", entry.FilePath)
+	}
+
+	err := file.Truncate(int64(entry.Size))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = applyMetadata(file.Name(), entry)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), target)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	r.mutex.Lock()
+	r.restored[entry.FilePath] = target
+	r.mutex.Unlock()
+	return nil
+}
+
+// Abort discards a regular file opened by Begin, for example one whose
+// contents failed verification. An existing file at the target is kept.
+func (r *Restorer) Abort(file *os.File) error {
+	r.mutex.Lock()
+	delete(r.temps, file.Name())
+	r.mutex.Unlock()
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// Close applies directory metadata, deepest directories first so that
+// setting a parent's times is not undone by changes to its children
+func (r *Restorer) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	sort.SliceStable(r.dirs, func(i, j int) bool {
+		return strings.Count(r.dirs[i].FilePath, "/") > strings.Count(r.dirs[j].FilePath, "/")
+	})
+	var firstErr error
+	for _, entry := range r.dirs {
+		target, err := r.Target(entry.FilePath)
+		if err == nil {
+			err = applyMetadata(target, entry)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	r.dirs = nil
+	return firstErr
+}
+
+// removeExisting removes a non-directory entry in the way of a restore
+func removeExisting(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s: a directory is in the way", path)
+	}
+	return os.Remove(path)
+}
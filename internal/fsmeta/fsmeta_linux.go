@@ -0,0 +1,134 @@
+//go:build linux
+
+package fsmeta
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// statInfo holds the platform-specific fields of a file's status
+type statInfo struct {
+	uid     uint32
+	gid     uint32
+	atimeNs int64
+	ctimeNs int64
+	rdev    uint64
+	device  uint64
+	inode   uint64
+	nlink   uint64
+}
+
+func statOf(info fs.FileInfo) (statInfo, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return statInfo{}, false
+	}
+	return statInfo{
+		uid:     stat.Uid,
+		gid:     stat.Gid,
+		atimeNs: stat.Atim.Nano(),
+		ctimeNs: stat.Ctim.Nano(),
+		rdev:    uint64(stat.Rdev),
+		device:  uint64(stat.Dev),
+		inode:   stat.Ino,
+		nlink:   uint64(stat.Nlink),
+	}, true
+}
+
+// listXattrs reads the extended attributes of path without following symlinks
+func listXattrs(path string) ([]*pb.ExtendedAttribute, error) {
+	names, err := readXattr(func(buf []byte) (int, error) { return unix.Llistxattr(path, buf) })
+	if err != nil || len(names) == 0 {
+		if errors.Is(err, unix.ENOTSUP) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var xattrs []*pb.ExtendedAttribute
+	for _, name := range bytes.Split(bytes.TrimRight(names, "\x00"), []byte{0}) {
+		value, err := readXattr(func(buf []byte) (int, error) { return unix.Lgetxattr(path, string(name), buf) })
+		if errors.Is(err, unix.ENODATA) {
+			continue // removed since it was listed
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		xattrs = append(xattrs, &pb.ExtendedAttribute{Name: string(name), Value: value})
+	}
+	sort.Slice(xattrs, func(i, j int) bool { return xattrs[i].Name < xattrs[j].Name })
+	return xattrs, nil
+}
+
+// readXattr calls an xattr syscall with a buffer of the size it reports,
+// retrying if the attribute grows in between
+func readXattr(call func(buf []byte) (int, error)) ([]byte, error) {
+	for {
+		size, err := call(nil)
+		if err != nil || size == 0 {
+			return nil, err
+		}
+		buf := make([]byte, size)
+		n, err := call(buf)
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
+
+// makeSpecial creates a FIFO or device node
+func makeSpecial(path string, entry *pb.FileEntry) error {
+	perm := entry.Mode & 0o7777
+	switch entry.Type {
+	case pb.FileType_FILE_TYPE_FIFO:
+		return unix.Mkfifo(path, perm)
+	case pb.FileType_FILE_TYPE_CHAR_DEVICE:
+		return unix.Mknod(path, unix.S_IFCHR|perm, int(entry.Rdev))
+	case pb.FileType_FILE_TYPE_BLOCK_DEVICE:
+		return unix.Mknod(path, unix.S_IFBLK|perm, int(entry.Rdev))
+	}
+	return fmt.Errorf("%s: not a special file", path)
+}
+
+// applyMetadata sets ownership, mode, extended attributes and times on path.
+// Ownership is skipped when the process lacks permission to change it.
+func applyMetadata(path string, entry *pb.FileEntry) error {
+	symlink := entry.Type == pb.FileType_FILE_TYPE_SYMLINK
+
+	// chown clears setuid and setgid, so it runs before chmod
+	uid, gid := resolveOwner(entry)
+	if err := os.Lchown(path, uid, gid); err != nil && !errors.Is(err, fs.ErrPermission) {
+		return err
+	}
+	if !symlink {
+		if err := os.Chmod(path, goMode(entry.Mode)); err != nil {
+			return err
+		}
+	}
+	for _, xattr := range entry.Xattrs {
+		if err := unix.Lsetxattr(path, xattr.Name, xattr.Value, 0); err != nil {
+			return fmt.Errorf("%s: failed to set extended attribute %s: %w", path, xattr.Name, err)
+		}
+	}
+
+	atime := entry.AtimeNs
+	if atime == 0 {
+		atime = entry.MtimeNs
+	}
+	times := []unix.Timespec{unix.NsecToTimespec(atime), unix.NsecToTimespec(entry.MtimeNs)}
+	return unix.UtimesNanoAt(unix.AT_FDCWD, path, times, unix.AT_SYMLINK_NOFOLLOW)
+}
@@ -0,0 +1,50 @@
+//go:build !linux
+
+package fsmeta
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// statInfo holds the platform-specific fields of a file's status
+type statInfo struct {
+	uid     uint32
+	gid     uint32
+	atimeNs int64
+	ctimeNs int64
+	rdev    uint64
+	device  uint64
+	inode   uint64
+	nlink   uint64
+}
+
+// statOf is not available on this platform; ownership, access times and
+// hard links are not captured
+func statOf(info fs.FileInfo) (statInfo, bool) {
+	return statInfo{}, false
+}
+
+func listXattrs(path string) ([]*pb.ExtendedAttribute, error) {
+	return nil, nil
+}
+
+func makeSpecial(path string, entry *pb.FileEntry) error {
+	return fmt.Errorf("%s: restoring %s is not supported on this platform", path, entry.Type)
+}
+
+// applyMetadata sets the mode and modification time of path
+func applyMetadata(path string, entry *pb.FileEntry) error {
+	if entry.Type == pb.FileType_FILE_TYPE_SYMLINK {
+		return nil
+	}
+	if err := os.Chmod(path, goMode(entry.Mode)); err != nil {
+		return err
+	}
+	mtime := time.Unix(0, entry.MtimeNs)
+	return os.Chtimes(path, mtime, mtime)
+}
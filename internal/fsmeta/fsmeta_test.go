@@ -0,0 +1,371 @@
+//go:build linux
+
+package fsmeta
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// captureTree captures every entry below root in walk order, sending each
+// through a proto round trip as the backup stream would
+func captureTree(t *testing.T, root string) []*pb.FileEntry {
+	t.Helper()
+	links := NewHardLinks()
+	var entries []*pb.FileEntry
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		entry, err := Capture(path, info, links)
+		if err != nil {
+			return err
+		}
+		data, err := proto.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		decoded := &pb.FileEntry{}
+		if err := proto.Unmarshal(data, decoded); err != nil {
+			return err
+		}
+		entries = append(entries, decoded)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+	return entries
+}
+
+// restoreTree restores entries below dest, copying regular file contents
+// from the source tree
+func restoreTree(t *testing.T, entries []*pb.FileEntry, dest string) *Restorer {
+	t.Helper()
+	restorer := NewRestorer(dest)
+	for _, entry := range entries {
+		file, err := restorer.Begin(entry)
+		if err != nil {
+			t.Fatalf("Begin(%s) failed: %v", entry.FilePath, err)
+		}
+		if file == nil {
+			continue
+		}
+		source, err := os.Open(entry.FilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = io.Copy(file, source)
+		source.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := restorer.End(entry, file); err != nil {
+			t.Fatalf("End(%s) failed: %v", entry.FilePath, err)
+		}
+	}
+	if err := restorer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return restorer
+}
+
+func TestRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	mtime := time.Date(2021, 3, 4, 5, 6, 7, 123456789, time.UTC)
+
+	mustWrite := func(name, content string, mode os.FileMode) {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustWrite("bin/tool", "#!/bin/sh\n", 0o755|os.ModeSetuid)
+	mustWrite("docs/readme.txt", "hello", 0o640)
+	if err := os.Symlink("../docs/readme.txt", filepath.Join(src, "bin/readme")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(src, "docs/readme.txt"), filepath.Join(src, "docs/same.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(src, "empty"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.Mkfifo(filepath.Join(src, "pipe"), 0o620); err != nil {
+		t.Fatal(err)
+	}
+	xattrs := unix.Setxattr(filepath.Join(src, "docs/readme.txt"), "user.origin", []byte("test"), 0) == nil
+
+	for _, name := range []string{"bin/tool", "docs/readme.txt", "empty", "docs", "pipe"} {
+		if err := os.Chtimes(filepath.Join(src, name), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries := captureTree(t, src)
+	byPath := make(map[string]*pb.FileEntry)
+	for _, entry := range entries {
+		byPath[entry.FilePath] = entry
+	}
+	if got := byPath[filepath.Join(src, "docs/same.txt")]; got == nil || got.Type != pb.FileType_FILE_TYPE_HARDLINK {
+		t.Fatalf("Expected second name to be captured as a hard link, got %v", got)
+	}
+	if got := byPath[filepath.Join(src, "bin/tool")]; got.Mode != 0o4755 {
+		t.Errorf("Expected mode 4755, got %o", got.Mode)
+	}
+	if got := byPath[filepath.Join(src, "bin/tool")]; got.Inode == 0 || got.CtimeNs == 0 {
+		t.Errorf("Expected inode and ctime to be captured, got %d and %d", got.Inode, got.CtimeNs)
+	}
+
+	dest := t.TempDir()
+	restorer := restoreTree(t, entries, dest)
+	target := func(name string) string {
+		path, err := restorer.Target(filepath.Join(src, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	info, err := os.Lstat(target("bin/tool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode() != 0o755|os.ModeSetuid || !info.ModTime().Equal(mtime) {
+		t.Errorf("bin/tool restored with mode %v mtime %v", info.Mode(), info.ModTime())
+	}
+
+	if link, err := os.Readlink(target("bin/readme")); err != nil || link != "../docs/readme.txt" {
+		t.Errorf("Expected symlink to ../docs/readme.txt, got %q (%v)", link, err)
+	}
+
+	first, _ := os.Stat(target("docs/readme.txt"))
+	second, _ := os.Stat(target("docs/same.txt"))
+	if !os.SameFile(first, second) {
+		t.Error("Expected hard links to share an inode after restore")
+	}
+	if first.Mode().Perm() != 0o640 {
+		t.Errorf("Expected readme mode 0640, got %v", first.Mode())
+	}
+
+	for _, name := range []string{"empty", "docs"} {
+		info, err := os.Stat(target(name))
+		if err != nil || !info.IsDir() || !info.ModTime().Equal(mtime) {
+			t.Errorf("Directory %s not restored with its mtime: %v %v", name, info, err)
+		}
+	}
+	if info, err := os.Stat(target("empty")); err == nil && info.Mode().Perm() != 0o750 {
+		t.Errorf("Expected empty dir mode 0750, got %v", info.Mode())
+	}
+
+	if info, err := os.Lstat(target("pipe")); err != nil || info.Mode()&os.ModeNamedPipe == 0 {
+		t.Errorf("Expected a FIFO, got %v (%v)", info, err)
+	}
+
+	if xattrs {
+		buf := make([]byte, 64)
+		n, err := unix.Getxattr(target("docs/readme.txt"), "user.origin", buf)
+		if err != nil || string(buf[:n]) != "test" {
+			t.Errorf("Expected xattr user.origin=test, got %q (%v)", buf[:n], err)
+		}
+	}
+
+	// Ownership is restored as the superuser; otherwise it stays with the caller
+	stat := info.Sys().(*syscall.Stat_t)
+	if os.Geteuid() == 0 && stat.Uid != byPath[filepath.Join(src, "bin/tool")].Uid {
+		t.Errorf("Expected uid %d, got %d", byPath[filepath.Join(src, "bin/tool")].Uid, stat.Uid)
+	}
+}
+
+func TestRestoreOwnerAsSuperuser(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing ownership requires root")
+	}
+	dest := t.TempDir()
+	restorer := NewRestorer(dest)
+	entry := &pb.FileEntry{FilePath: "/data/owned", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o644, Uid: 1234, Gid: 5678}
+	file, err := restorer.Begin(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restorer.End(entry, file); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(dest, "data/owned"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat := info.Sys().(*syscall.Stat_t)
+	if stat.Uid != 1234 || stat.Gid != 5678 {
+		t.Errorf("Expected owner 1234:5678, got %d:%d", stat.Uid, stat.Gid)
+	}
+}
+
+func TestHardLinkWithoutTarget(t *testing.T) {
+	restorer := NewRestorer(t.TempDir())
+	_, err := restorer.Begin(&pb.FileEntry{FilePath: "/a/b", Type: pb.FileType_FILE_TYPE_HARDLINK, LinkTarget: "/a/missing"})
+	if err == nil {
+		t.Error("Expected an error for a hard link to an unrestored file")
+	}
+
+	if _, err := Capture("/", fakeSocket{}, nil); err == nil || errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected sockets to be rejected, got %v", err)
+	}
+}
+
+// fakeSocket is a FileInfo describing a socket
+type fakeSocket struct{ os.FileInfo }
+
+func (fakeSocket) Mode() os.FileMode  { return os.ModeSocket | 0o600 }
+func (fakeSocket) ModTime() time.Time { return time.Time{} }
+
+func TestRestoreSparseFile(t *testing.T) {
+	dest := t.TempDir()
+	restorer := NewRestorer(dest)
+	entry := &pb.FileEntry{FilePath: "/vm/disk.img", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o600, Size: 1 << 30}
+	file, err := restorer.Begin(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Holes are skipped by writing at offsets; the trailing hole comes from End
+	if _, err := file.WriteAt([]byte("boot"), 512<<20); err != nil {
+		t.Fatal(err)
+	}
+	if err := restorer.End(entry, file); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := restorer.Target(entry.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 1<<30 {
+		t.Errorf("Expected size 1 GiB, got %d", info.Size())
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Blocks*512 > 1<<20 {
+		t.Errorf("Expected a sparse file, %d bytes are allocated", stat.Blocks*512)
+	}
+}
+
+func TestRestoreExistingPolicies(t *testing.T) {
+	entry := &pb.FileEntry{FilePath: "/data/file", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o644, Size: 3}
+	restore := func(restorer *Restorer) error {
+		file, err := restorer.Begin(entry)
+		if err != nil {
+			return err
+		}
+		file.WriteString("new")
+		return restorer.End(entry, file)
+	}
+
+	tests := []struct {
+		policy ExistingPolicy
+		want   map[string]string // file name -> contents
+	}{
+		{Overwrite, map[string]string{"file": "new"}},
+		{SkipExisting, map[string]string{"file": "old"}},
+		{RenameNew, map[string]string{"file": "old", "file.restored-1": "new"}},
+	}
+	for _, tt := range tests {
+		dest := t.TempDir()
+		os.MkdirAll(filepath.Join(dest, "data"), 0o755)
+		os.WriteFile(filepath.Join(dest, "data/file"), []byte("old"), 0o644)
+
+		err := restore(NewRestorerWithPolicy(dest, tt.policy))
+		if tt.policy == SkipExisting {
+			if !errors.Is(err, ErrSkipped) {
+				t.Errorf("Policy %d: expected ErrSkipped, got %v", tt.policy, err)
+			}
+		} else if err != nil {
+			t.Fatalf("Policy %d: restore failed: %v", tt.policy, err)
+		}
+		names, _ := os.ReadDir(filepath.Join(dest, "data"))
+		if len(names) != len(tt.want) {
+			t.Errorf("Policy %d: expected %d files, got %v", tt.policy, len(tt.want), names)
+		}
+		for name, contents := range tt.want {
+			if got, _ := os.ReadFile(filepath.Join(dest, "data", name)); string(got) != contents {
+				t.Errorf("Policy %d: %s has %q, expected %q", tt.policy, name, got, contents)
+			}
+		}
+	}
+}
+
+func TestRestoreAbortKeepsExistingFile(t *testing.T) {
+	dest := t.TempDir()
+	target := filepath.Join(dest, "data/file")
+	os.MkdirAll(filepath.Dir(target), 0o755)
+	os.WriteFile(target, []byte("old"), 0o644)
+
+	restorer := NewRestorer(dest)
+	entry := &pb.FileEntry{FilePath: "/data/file", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o644, Size: 7}
+	file, err := restorer.Begin(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("partial")
+	if got, _ := os.ReadFile(target); string(got) != "old" {
+		t.Errorf("Expected the target to be untouched while writing, got %q", got)
+	}
+	if err := restorer.Abort(file); err != nil {
+		t.Fatal(err)
+	}
+	names, _ := os.ReadDir(filepath.Dir(target))
+	if got, _ := os.ReadFile(target); string(got) != "old" || len(names) != 1 {
+		t.Errorf("Expected only the old file after Abort, got %q and %v", got, names)
+	}
+}
+
+func TestRestoreStaysInsideRoot(t *testing.T) {
+	dest := t.TempDir()
+	outside := t.TempDir()
+	restorer := NewRestorer(dest)
+
+	// .. elements may not lead out of the destination
+	for _, path := range []string{"/a/../../../etc/x", "../x", "/.."} {
+		if _, err := restorer.Begin(&pb.FileEntry{FilePath: path, Type: pb.FileType_FILE_TYPE_DIRECTORY, Mode: 0o755}); err == nil {
+			t.Errorf("%s: expected the path to be rejected", path)
+		}
+	}
+	if target, err := restorer.Target("/a/../b"); err != nil || target != filepath.Join(dest, "b") {
+		t.Errorf("Expected /a/../b to restore to b, got %q, %v", target, err)
+	}
+
+	// Nor may a symlink restored earlier
+	if _, err := restorer.Begin(&pb.FileEntry{FilePath: "/link", Type: pb.FileType_FILE_TYPE_SYMLINK, LinkTarget: outside}); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range []*pb.FileEntry{
+		{FilePath: "/link/file", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o644},
+		{FilePath: "/link/dir/file", Type: pb.FileType_FILE_TYPE_REGULAR, Mode: 0o644},
+		{FilePath: "/link/dir", Type: pb.FileType_FILE_TYPE_DIRECTORY, Mode: 0o755},
+	} {
+		if file, err := restorer.Begin(entry); err == nil {
+			restorer.Abort(file)
+			t.Errorf("%s: expected writing through the symlink to fail", entry.FilePath)
+		}
+	}
+	if names, _ := os.ReadDir(outside); len(names) != 0 {
+		t.Errorf("Expected nothing written outside the destination, got %v", names)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package fswalk
+
+import "io/fs"
+
+// deviceID is not available on this platform, so OneFileSystem has no effect
+func deviceID(info fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
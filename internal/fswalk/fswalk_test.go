@@ -0,0 +1,215 @@
+package fswalk
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestMatcher(t *testing.T) {
+	m, err := NewMatcher([]string{
+		"# comment",
+		"*.log",
+		"!keep.log",
+		"/build",
+		"cache/",
+		"src/**/gen",
+		`\#literal`,
+	})
+	if err != nil {
+		t.Fatalf("Failed to compile patterns: %v", err)
+	}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"app.log", false, true},
+		{"deep/nested/app.log", false, true},
+		{"keep.log", false, false},
+		{"logs/keep.log", false, false},
+		{"build", true, true},
+		{"sub/build", true, false}, // anchored to the root
+		{"cache", true, true},
+		{"cache", false, false}, // directory-only pattern
+		{"a/cache", true, true},
+		{"src/gen", true, true},
+		{"src/x/y/gen", true, true},
+		{"other/gen", true, false},
+		{"#literal", false, true},
+		{"main.go", false, false},
+	}
+	for _, tt := range tests {
+		if got := m.Match(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Match(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+
+	include, _ := NewMatcher([]string{"docs/"})
+	if !include.MatchOrParent("docs/guide/intro.md", false) {
+		t.Error("Expected directory pattern to cover files below it")
+	}
+
+	if _, err := NewMatcher([]string{"[unterminated"}); err == nil {
+		t.Error("Expected invalid pattern to be rejected")
+	}
+}
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func walkPaths(t *testing.T, options Options, roots ...string) ([]string, *Report) {
+	t.Helper()
+	walker, err := NewWalker(options)
+	if err != nil {
+		t.Fatalf("Failed to create walker: %v", err)
+	}
+	var paths []string
+	report := &Report{}
+	err = walker.Walk(roots, report, func(f File) error {
+		paths = append(paths, f.RelPath)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	sort.Strings(paths)
+	return paths, report
+}
+
+func TestWalkIncludeExclude(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"README.md":           "readme",
+		"src/main.go":         "package main",
+		"src/main_test.go":    "package main",
+		"src/vendor/lib.go":   "package lib",
+		"node_modules/x.js":   "x",
+		"logs/app.log":        "log",
+		"logs/big.bin":        string(make([]byte, 2048)),
+		"docs/guide/intro.md": "intro",
+	})
+
+	paths, report := walkPaths(t, Options{Exclude: []string{"node_modules/", "vendor/", "*.log"}}, root)
+	want := []string{"README.md", "docs/guide/intro.md", "logs/big.bin", "src/main.go", "src/main_test.go"}
+	if !equal(paths, want) {
+		t.Errorf("Got %v, want %v", paths, want)
+	}
+	if report.Files != 5 || report.Excluded != 3 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+
+	paths, _ = walkPaths(t, Options{Include: []string{"*.go", "docs/"}, Exclude: []string{"*_test.go"}}, root)
+	want = []string{"docs/guide/intro.md", "src/main.go", "src/vendor/lib.go"}
+	if !equal(paths, want) {
+		t.Errorf("Got %v, want %v", paths, want)
+	}
+
+	paths, report = walkPaths(t, Options{MaxFileSize: 1024}, filepath.Join(root, "logs"))
+	if !equal(paths, []string{"app.log"}) {
+		t.Errorf("Got %v, want only app.log", paths)
+	}
+	if len(report.Skipped) != 1 || report.Skipped[0].Reason != SkipTooLarge {
+		t.Errorf("Expected big.bin skipped as too large, got %v", report.Skipped)
+	}
+}
+
+func TestWalkReportsErrorsAndContinues(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"a.txt":         "a",
+		"locked/secret": "s",
+		"z/after.txt":   "z",
+	})
+	if err := os.Symlink("a.txt", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	locked := filepath.Join(root, "locked")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(locked, 0o755) })
+
+	missing := filepath.Join(root, "does-not-exist")
+	paths, report := walkPaths(t, Options{}, missing, root)
+
+	// The superuser can still read the locked directory
+	want := []string{"a.txt", "z/after.txt"}
+	wantErrors := 2
+	if os.Geteuid() == 0 {
+		want = []string{"a.txt", "locked/secret", "z/after.txt"}
+		wantErrors = 1
+	}
+	if !equal(paths, want) {
+		t.Errorf("Got %v, want %v", paths, want)
+	}
+	if len(report.Errors) != wantErrors {
+		t.Errorf("Expected %d errors, got %v", wantErrors, report.Errors)
+	}
+	if len(report.Skipped) != 1 || report.Skipped[0].Reason != SkipNotRegular {
+		t.Errorf("Expected the symlink skipped, got %v", report.Skipped)
+	}
+
+	// Errors from the callback stop the walk
+	walker, _ := NewWalker(Options{})
+	stop := errors.New("stop")
+	if err := walker.Walk([]string{root}, &Report{}, func(File) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("Expected callback error, got %v", err)
+	}
+}
+
+func TestWalkAllTypes(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"a.txt":       "a",
+		"sub/b.txt":   "b",
+		"skip/c.tmp":  "c",
+		"empty/.keep": "",
+	})
+	if err := os.Symlink("a.txt", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(root, "empty/.keep")); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, report := walkPaths(t, Options{AllTypes: true, Exclude: []string{"skip/"}}, root)
+	want := []string{".", "a.txt", "empty", "link", "sub", "sub/b.txt"}
+	if !equal(paths, want) {
+		t.Errorf("Got %v, want %v", paths, want)
+	}
+	if report.Files != 2 || report.Others != 1 || len(report.Skipped) != 0 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+
+	paths, _ = walkPaths(t, Options{AllTypes: true, Include: []string{"sub/"}}, root)
+	if !equal(paths, []string{"sub", "sub/b.txt"}) {
+		t.Errorf("Got %v, want only sub and its contents", paths)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package fswalk
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Matcher matches slash-separated relative paths against gitignore-style
+// patterns. Patterns are checked in order and the last match wins:
+//
+//   - a pattern without a slash matches a name at any depth ("*.log")
+//   - a pattern containing a slash is anchored to the walk root ("/build", "src/gen")
+//   - a trailing slash matches directories only ("cache/")
+//   - "**" matches any number of directories ("logs/**/*.gz")
+//   - a leading "!" re-includes paths matched by an earlier pattern
+//   - blank lines and lines starting with "#" are ignored
+type Matcher struct {
+	patterns []pattern
+}
+
+type pattern struct {
+	text     string
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// NewMatcher compiles a list of patterns
+func NewMatcher(lines []string) (*Matcher, error) {
+	m := &Matcher{}
+	for _, line := range lines {
+		p, ok, err := parsePattern(line)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	return m, nil
+}
+
+// Empty reports whether the matcher has no patterns
+func (m *Matcher) Empty() bool {
+	return m == nil || len(m.patterns) == 0
+}
+
+// Match reports whether relPath is matched. isDir tells whether relPath is a directory.
+func (m *Matcher) Match(relPath string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	segments := strings.Split(strings.Trim(relPath, "/"), "/")
+	matched := false
+	for _, p := range m.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(p.segments, segments) {
+			matched = !p.negate
+		}
+	}
+	return matched
+}
+
+// MatchOrParent reports whether relPath or any of its parent directories is
+// matched, so that a directory pattern covers everything below it
+func (m *Matcher) MatchOrParent(relPath string, isDir bool) bool {
+	segments := strings.Split(strings.Trim(relPath, "/"), "/")
+	for i := 1; i < len(segments); i++ {
+		if m.Match(strings.Join(segments[:i], "/"), true) {
+			return true
+		}
+	}
+	return m.Match(relPath, isDir)
+}
+
+func parsePattern(line string) (pattern, bool, error) {
+	text := strings.TrimRight(line, " \t\r")
+	if text == "" || strings.HasPrefix(text, "#") {
+		return pattern{}, false, nil
+	}
+
+	p := pattern{text: text}
+	if strings.HasPrefix(text, "!") {
+		p.negate = true
+		text = text[1:]
+	} else if strings.HasPrefix(text, `\`) {
+		text = text[1:] // escaped leading "!" or "#"
+	}
+	if strings.HasSuffix(text, "/") {
+		p.dirOnly = true
+		text = strings.TrimRight(text, "/")
+	}
+	if text == "" {
+		return pattern{}, false, fmt.Errorf("invalid pattern %q", line)
+	}
+
+	if strings.Contains(text, "/") {
+		p.segments = strings.Split(strings.TrimPrefix(text, "/"), "/")
+	} else {
+		p.segments = []string{"**", text}
+	}
+	for _, segment := range p.segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return pattern{}, false, fmt.Errorf("invalid pattern %q: %w", line, err)
+		}
+	}
+	return p, true, nil
+}
+
+// matchSegments matches path segments against pattern segments, where a
+// "**" pattern segment matches zero or more path segments
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(rest, segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
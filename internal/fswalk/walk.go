@@ -0,0 +1,187 @@
+package fswalk
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// SkipReason explains why a path was not backed up
+type SkipReason string
+
+const (
+	SkipTooLarge        SkipReason = "exceeds max file size"
+	SkipOtherFileSystem SkipReason = "on another file system"
+	SkipNotRegular      SkipReason = "not a regular file"
+	SkipError           SkipReason = "error"
+)
+
+// Options control which files a Walker visits
+type Options struct {
+	Include       []string // gitignore-style patterns; when set only matching files are visited
+	Exclude       []string // gitignore-style patterns; matching files and directories are skipped
+	OneFileSystem bool     // do not cross into other mounted file systems
+	MaxFileSize   int64    // skip larger files; 0 means no limit
+	AllTypes      bool     // also visit directories, symlinks, FIFOs and devices
+}
+
+// File is an entry found by the walk; only a regular file unless
+// Options.AllTypes is set
+type File struct {
+	Path    string // path on disk
+	Root    string // root the file was found under
+	RelPath string // slash-separated path relative to Root
+	Info    fs.FileInfo
+}
+
+// Skipped records a path that was not visited
+type Skipped struct {
+	Path   string
+	Reason SkipReason
+	Err    error // set for SkipError
+}
+
+func (s Skipped) String() string {
+	if s.Err != nil {
+		return fmt.Sprintf("%s: %v", s.Path, s.Err)
+	}
+	return fmt.Sprintf("%s: %s", s.Path, s.Reason)
+}
+
+// Report summarises a walk
+type Report struct {
+	Files    int
+	Bytes    int64
+	Others   int       // symlinks, FIFOs and devices visited with AllTypes
+	Excluded int       // paths matched by exclude patterns or missed by include patterns
+	Skipped  []Skipped // too large, other file system or not a regular file
+	Errors   []Skipped // unreadable paths, e.g. permission denied
+
+	mutex sync.Mutex // guards Errors while callers process files concurrently
+}
+
+// Fail records an error for a file the caller could not process. It may be
+// called from several goroutines.
+func (r *Report) Fail(path string, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Errors = append(r.Errors, Skipped{Path: path, Reason: SkipError, Err: err})
+}
+
+// Walker walks directory trees applying include and exclude rules
+type Walker struct {
+	options Options
+	include *Matcher
+	exclude *Matcher
+}
+
+// NewWalker compiles the patterns in options
+func NewWalker(options Options) (*Walker, error) {
+	include, err := NewMatcher(options.Include)
+	if err != nil {
+		return nil, fmt.Errorf("include: %w", err)
+	}
+	exclude, err := NewMatcher(options.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("exclude: %w", err)
+	}
+	return &Walker{options: options, include: include, exclude: exclude}, nil
+}
+
+// Walk visits every regular file under roots in lexical order and calls fn
+// for each. With AllTypes, directories are visited before their contents and
+// other non-regular entries except sockets are visited as well. Unreadable paths are recorded in report and the walk continues;
+// only an error returned by fn stops it. fn may record its own failures in
+// report with Fail.
+func (w *Walker) Walk(roots []string, report *Report, fn func(File) error) error {
+	for _, root := range roots {
+		if err := w.walkRoot(filepath.Clean(root), report, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Walker) walkRoot(root string, report *Report, fn func(File) error) error {
+	rootInfo, err := os.Lstat(root)
+	if err != nil {
+		report.Fail(root, err)
+		return nil
+	}
+	rootDevice, hasDevice := deviceID(rootInfo)
+
+	// A root naming a file is matched by its base name
+	base := root
+	if !rootInfo.IsDir() {
+		base = filepath.Dir(root)
+	}
+
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			report.Fail(path, err)
+			if entry != nil && entry.IsDir() && path != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		relPath := "."
+		if rel, err := filepath.Rel(base, path); err == nil {
+			relPath = filepath.ToSlash(rel)
+		}
+		isDir := entry.IsDir()
+
+		if relPath != "." && w.exclude.Match(relPath, isDir) {
+			report.Excluded++
+			if isDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			report.Fail(path, err)
+			return nil
+		}
+		if w.options.OneFileSystem && hasDevice {
+			if device, ok := deviceID(info); ok && device != rootDevice {
+				report.Skipped = append(report.Skipped, Skipped{Path: path, Reason: SkipOtherFileSystem})
+				if isDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		file := File{Path: path, Root: root, RelPath: relPath, Info: info}
+		if isDir {
+			if w.options.AllTypes && (w.include.Empty() || w.include.MatchOrParent(relPath, true)) {
+				return fn(file)
+			}
+			return nil
+		}
+
+		if !w.include.Empty() && !w.include.MatchOrParent(relPath, false) {
+			report.Excluded++
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			if !w.options.AllTypes || info.Mode()&(fs.ModeSocket|fs.ModeIrregular) != 0 {
+				report.Skipped = append(report.Skipped, Skipped{Path: path, Reason: SkipNotRegular})
+				return nil
+			}
+			report.Others++
+			return fn(file)
+		}
+		if w.options.MaxFileSize > 0 && info.Size() > w.options.MaxFileSize {
+			report.Skipped = append(report.Skipped, Skipped{Path: path, Reason: SkipTooLarge})
+			return nil
+		}
+
+		report.Files++
+		report.Bytes += info.Size()
+		return fn(file)
+	})
+}
@@ -0,0 +1,127 @@
+// Package jobstate defines the lifecycle of a backup job. A job is recorded
+// as Pending, runs while its streams upload, is Finalizing while the ingest
+// node seals its data, and ends in one of the terminal states. Failed jobs
+// carry a Reason.
+package jobstate
+
+import "fmt"
+
+// State is the state of a backup job
+type State string
+
+// States of a backup job
+const (
+	Pending    State = "PENDING"    // recorded, not yet accepting data
+	Running    State = "RUNNING"    // streams are uploading
+	Finalizing State = "FINALIZING" // all data received; sealing and recording the snapshot
+	Completed  State = "COMPLETED"
+	Partial    State = "PARTIAL" // completed, but some files failed and are not in the snapshot
+	Failed     State = "FAILED"
+	Cancelled  State = "CANCELLED"
+)
+
+// Reason explains why a job failed
+type Reason string
+
+// Reasons a job may fail for
+const (
+	NoReason       Reason = ""
+	ClientReported Reason = "CLIENT_REPORTED" // the client ended the job as failed
+	StreamLost     Reason = "STREAM_LOST"     // no stream of the job was seen within the stale timeout
+	StorageError   Reason = "STORAGE_ERROR"   // data of the job could not be made durable
+	InternalError  Reason = "INTERNAL_ERROR"  // the job could not be recorded
+	Unknown        Reason = "UNKNOWN"         // ended with a status from before states were typed
+)
+
+// transitions lists the states each state may move to; terminal states have
+// none
+var transitions = map[State][]State{
+	Pending:    {Running, Failed, Cancelled},
+	Running:    {Finalizing, Failed, Cancelled},
+	Finalizing: {Completed, Partial, Failed},
+	Completed:  nil,
+	Partial:    nil,
+	Failed:     nil,
+	Cancelled:  nil,
+}
+
+// Active are the states of jobs that have not ended
+var Active = []State{Pending, Running, Finalizing}
+
+// Successful are the end states of jobs whose snapshot may be restored and
+// built on by incremental backups
+var Successful = []State{Completed, Partial}
+
+// legacyNames maps the free-form statuses clients sent before states were
+// typed
+var legacyNames = map[string]State{
+	"INITIATED":             Running,
+	"COMPLETED_WITH_ERRORS": Partial,
+}
+
+// Parse returns the state a name stands for, accepting legacy names
+func Parse(name string) (State, error) {
+	if state := State(name); state.Valid() {
+		return state, nil
+	}
+	if state, ok := legacyNames[name]; ok {
+		return state, nil
+	}
+	return "", fmt.Errorf("unknown job state %q", name)
+}
+
+// Valid reports whether s is a known state
+func (s State) Valid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+// Terminal reports whether a job in state s has ended
+func (s State) Terminal() bool {
+	return s.Valid() && len(transitions[s]) == 0
+}
+
+// Successful reports whether s is one of Successful
+func (s State) Successful() bool {
+	return s == Completed || s == Partial
+}
+
+// CanTransition reports whether a job may move from one state to another
+func CanTransition(from, to State) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Predecessors returns the states a job may move to s from
+func Predecessors(s State) []State {
+	var from []State
+	for _, state := range []State{Pending, Running, Finalizing} {
+		if CanTransition(state, s) {
+			from = append(from, state)
+		}
+	}
+	return from
+}
+
+// Valid reports whether r is a known reason
+func (r Reason) Valid() bool {
+	switch r {
+	case NoReason, ClientReported, StreamLost, StorageError, InternalError, Unknown:
+		return true
+	}
+	return false
+}
+
+// TransitionError is returned for a transition the lifecycle does not allow
+type TransitionError struct {
+	JobID    string
+	From, To State
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("backup job %s cannot move from %s to %s", e.JobID, e.From, e.To)
+}
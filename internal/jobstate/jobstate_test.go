@@ -0,0 +1,65 @@
+package jobstate
+
+import "testing"
+
+func TestTransitions(t *testing.T) {
+	for _, tc := range []struct {
+		from, to State
+		allowed  bool
+	}{
+		{Pending, Running, true},
+		{Running, Finalizing, true},
+		{Finalizing, Completed, true},
+		{Finalizing, Partial, true},
+		{Running, Failed, true},
+		{Running, Cancelled, true},
+		{Pending, Completed, false},
+		{Running, Completed, false},
+		{Finalizing, Cancelled, false},
+		{Completed, Failed, false},
+		{Failed, Running, false},
+		{Running, Running, false},
+		{State("DONE"), Completed, false},
+	} {
+		if got := CanTransition(tc.from, tc.to); got != tc.allowed {
+			t.Errorf("CanTransition(%s, %s) = %v, expected %v", tc.from, tc.to, got, tc.allowed)
+		}
+	}
+
+	from := Predecessors(Failed)
+	if len(from) != 3 {
+		t.Errorf("Expected every active state to precede FAILED, got %v", from)
+	}
+	if from := Predecessors(Completed); len(from) != 1 || from[0] != Finalizing {
+		t.Errorf("Expected only FINALIZING to precede COMPLETED, got %v", from)
+	}
+	for _, state := range Active {
+		if state.Terminal() {
+			t.Errorf("%s should not be terminal", state)
+		}
+	}
+	for _, state := range []State{Completed, Partial, Failed, Cancelled} {
+		if !state.Terminal() {
+			t.Errorf("%s should be terminal", state)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for name, expected := range map[string]State{
+		"COMPLETED":             Completed,
+		"CANCELLED":             Cancelled,
+		"INITIATED":             Running,
+		"COMPLETED_WITH_ERRORS": Partial,
+	} {
+		state, err := Parse(name)
+		if err != nil || state != expected {
+			t.Errorf("Parse(%q) = %s, %v, expected %s", name, state, err, expected)
+		}
+	}
+	for _, name := range []string{"", "completed", "DONE"} {
+		if _, err := Parse(name); err == nil {
+			t.Errorf("Parse(%q): expected an error", name)
+		}
+	}
+}
@@ -5,11 +5,15 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
+// QuarantinePrefix is the key prefix under which quarantined chunks are kept
+const QuarantinePrefix = "quarantine/"
+
 // Client wraps a MinIO client
 type Client struct {
 	client *minio.Client
@@ -69,6 +73,14 @@ func (c *Client) GetChunk(ctx context.Context, fingerprint string) ([]byte, erro
 	return data, nil
 }
 
+// DeleteChunk removes a chunk from MinIO
+func (c *Client) DeleteChunk(ctx context.Context, fingerprint string) error {
+	if err := c.client.RemoveObject(ctx, c.bucket, fingerprint, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete chunk %s: %w", fingerprint, err)
+	}
+	return nil
+}
+
 // ChunkExists checks if a chunk exists in MinIO
 func (c *Client) ChunkExists(ctx context.Context, fingerprint string) (bool, error) {
 	_, err := c.client.StatObject(ctx, c.bucket, fingerprint, minio.StatObjectOptions{})
@@ -89,3 +101,38 @@ func (c *Client) GetChunkSize(ctx context.Context, fingerprint string) (int64, e
 	}
 	return info.Size, nil
 }
+
+// ListChunks calls fn for every stored chunk in key (fingerprint) order,
+// skipping quarantined objects. Listing stops at the first error fn returns.
+func (c *Client) ListChunks(ctx context.Context, fn func(fingerprint string, size int64) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for obj := range c.client.ListObjects(ctx, c.bucket, minio.ListObjectsOptions{Recursive: true}) {
+		if obj.Err != nil {
+			return fmt.Errorf("failed to list chunks: %w", obj.Err)
+		}
+		if strings.HasPrefix(obj.Key, QuarantinePrefix) {
+			continue
+		}
+		if err := fn(obj.Key, obj.Size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QuarantineChunk moves a chunk out of the live key space so that it is no
+// longer served or reported as present
+func (c *Client) QuarantineChunk(ctx context.Context, fingerprint string) error {
+	_, err := c.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: c.bucket, Object: QuarantinePrefix + fingerprint},
+		minio.CopySrcOptions{Bucket: c.bucket, Object: fingerprint})
+	if err != nil {
+		return fmt.Errorf("failed to copy chunk %s to quarantine: %w", fingerprint, err)
+	}
+	if err := c.client.RemoveObject(ctx, c.bucket, fingerprint, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to remove quarantined chunk %s: %w", fingerprint, err)
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package placement
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// Cluster routes chunks to replicated Data Storage Nodes
+type Cluster struct {
+	ring        *Ring
+	clients     map[string]pb.StorageServiceClient // node ID -> client
+	health      map[string]nodeHealth              // node ID -> last polled status
+	replicas    int
+	writeQuorum int
+	mutex       sync.RWMutex
+}
+
+// NewCluster creates a cluster that writes every chunk to replicas nodes and
+// requires writeQuorum of those writes to succeed
+func NewCluster(replicas, writeQuorum, virtualNodes int) *Cluster {
+	if replicas < 1 {
+		replicas = 1
+	}
+	if writeQuorum < 1 || writeQuorum > replicas {
+		writeQuorum = replicas/2 + 1
+	}
+	return &Cluster{
+		ring:        NewRing(virtualNodes),
+		clients:     make(map[string]pb.StorageServiceClient),
+		health:      make(map[string]nodeHealth),
+		replicas:    replicas,
+		writeQuorum: writeQuorum,
+	}
+}
+
+// AddNode adds a storage node to the cluster
+func (c *Cluster) AddNode(nodeID string, client pb.StorageServiceClient) {
+	c.mutex.Lock()
+	c.clients[nodeID] = client
+	c.mutex.Unlock()
+	c.ring.AddNode(nodeID)
+}
+
+// RemoveNode removes a storage node from the cluster. Chunks placed on it
+// are served by their remaining replicas until the rebalancer restores the
+// replica count.
+func (c *Cluster) RemoveNode(nodeID string) {
+	c.ring.RemoveNode(nodeID)
+	c.mutex.Lock()
+	delete(c.clients, nodeID)
+	delete(c.health, nodeID)
+	c.mutex.Unlock()
+}
+
+// Nodes returns the IDs of the nodes in the cluster
+func (c *Cluster) Nodes() []string {
+	return c.ring.Nodes()
+}
+
+// Replicas returns the nodes that should hold fingerprint, primary first.
+// Draining nodes are skipped so the rebalancer moves their chunks away.
+func (c *Cluster) Replicas(fingerprint chunking.Fingerprint) []string {
+	return c.ring.LookupFunc(fingerprint.String(), c.replicas, func(nodeID string) bool { return !c.draining(nodeID) })
+}
+
+// writeTargets returns the nodes a new chunk is written to: its replica set
+// with full or unreachable nodes replaced by the next writable ones
+func (c *Cluster) writeTargets(fingerprint chunking.Fingerprint) []string {
+	return c.ring.LookupFunc(fingerprint.String(), c.replicas, c.Writable)
+}
+
+// Client returns the client for a node, or nil if it is not in the cluster
+func (c *Cluster) Client(nodeID string) pb.StorageServiceClient {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.clients[nodeID]
+}
+
+// StoreChunks writes each chunk to its replica set and returns, per
+// fingerprint, the nodes that acknowledged it. Each node receives one
+// HasChunks call and one StoreChunks stream for its share of the batch. An
+// error is returned if any chunk reached fewer than writeQuorum nodes.
+func (c *Cluster) StoreChunks(ctx context.Context, chunks []chunking.Chunk) (map[chunking.Fingerprint][]string, error) {
+	byNode := make(map[string][]chunking.Chunk)
+	seen := make(map[chunking.Fingerprint]bool, len(chunks))
+	for _, chunk := range chunks {
+		if seen[chunk.Fingerprint] {
+			continue
+		}
+		seen[chunk.Fingerprint] = true
+		for _, nodeID := range c.writeTargets(chunk.Fingerprint) {
+			byNode[nodeID] = append(byNode[nodeID], chunk)
+		}
+	}
+	if len(byNode) == 0 && len(chunks) > 0 {
+		return nil, fmt.Errorf("no storage nodes accepting writes")
+	}
+
+	placements := make(map[chunking.Fingerprint][]string, len(chunks))
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for nodeID, nodeChunks := range byNode {
+		wg.Add(1)
+		go func(nodeID string, nodeChunks []chunking.Chunk) {
+			defer wg.Done()
+			stored, err := c.storeOnNode(ctx, nodeID, nodeChunks)
+			if err != nil {
+				log.Printf("Warning: Failed to store chunks on %s: %v", nodeID, err)
+			}
+			mutex.Lock()
+			for _, fingerprint := range stored {
+				placements[fingerprint] = append(placements[fingerprint], nodeID)
+			}
+			mutex.Unlock()
+		}(nodeID, nodeChunks)
+	}
+	wg.Wait()
+
+	for _, chunk := range chunks {
+		nodes := placements[chunk.Fingerprint]
+		sort.Strings(nodes)
+		if len(nodes) < c.writeQuorum {
+			return placements, fmt.Errorf("chunk %s stored on %d of %d required nodes", chunk.Fingerprint, len(nodes), c.writeQuorum)
+		}
+	}
+	return placements, nil
+}
+
+// storeOnNode uploads the chunks a node does not already hold and returns
+// the fingerprints the node now has
+func (c *Cluster) storeOnNode(ctx context.Context, nodeID string, chunks []chunking.Chunk) ([]chunking.Fingerprint, error) {
+	client := c.Client(nodeID)
+	if client == nil {
+		return nil, fmt.Errorf("unknown storage node %s", nodeID)
+	}
+
+	// Storage nodes know chunks by their object keys
+	keys := make([]string, len(chunks))
+	byKey := make(map[string]chunking.Fingerprint, len(chunks))
+	for i, chunk := range chunks {
+		keys[i] = chunk.Fingerprint.String()
+		byKey[keys[i]] = chunk.Fingerprint
+	}
+	hasResp, err := client.HasChunks(ctx, &pb.HasChunksRequest{Fingerprints: keys})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query storage node: %w", err)
+	}
+	var stored []chunking.Fingerprint
+	present := make(map[chunking.Fingerprint]bool, len(hasResp.PresentFingerprints))
+	for _, key := range hasResp.PresentFingerprints {
+		fingerprint, ok := byKey[key]
+		if !ok || present[fingerprint] {
+			continue
+		}
+		present[fingerprint] = true
+		stored = append(stored, fingerprint)
+	}
+	if len(present) == len(chunks) {
+		return stored, nil
+	}
+
+	storeStream, err := client.StoreChunks(ctx)
+	if err != nil {
+		return stored, fmt.Errorf("failed to open store stream: %w", err)
+	}
+	for _, chunk := range chunks {
+		if present[chunk.Fingerprint] {
+			continue
+		}
+		if err := storeStream.Send(&pb.StoreChunkRequest{
+			Fingerprint: chunk.Fingerprint.String(),
+			ChunkData:   chunk.Data,
+			Size:        chunk.Size,
+		}); err != nil {
+			return stored, fmt.Errorf("failed to send chunk %s: %w", chunk.Fingerprint, err)
+		}
+	}
+
+	resp, err := storeStream.CloseAndRecv()
+	if err != nil {
+		return stored, fmt.Errorf("failed to store chunks: %w", err)
+	}
+	for _, ack := range resp.Acks {
+		if !ack.Success {
+			log.Printf("Warning: Storage node %s rejected chunk %s: %s", nodeID, ack.Fingerprint, ack.ErrorMessage)
+			continue
+		}
+		if fingerprint, ok := byKey[ack.Fingerprint]; ok {
+			stored = append(stored, fingerprint)
+		}
+	}
+	return stored, nil
+}
+
+// GetChunk reads a chunk from the first replica that returns data matching
+// its fingerprint. The recorded placement is tried first, then the nodes
+// the ring currently assigns, so reads keep working while a rebalance is in
+// progress.
+func (c *Cluster) GetChunk(ctx context.Context, fingerprint chunking.Fingerprint, nodes []string) ([]byte, error) {
+	candidates := append([]string(nil), nodes...)
+	for _, nodeID := range c.Replicas(fingerprint) {
+		if !contains(candidates, nodeID) {
+			candidates = append(candidates, nodeID)
+		}
+	}
+
+	var lastErr error
+	for _, nodeID := range candidates {
+		client := c.Client(nodeID)
+		if client == nil {
+			continue
+		}
+		resp, err := client.GetChunk(ctx, &pb.GetChunkRequest{Fingerprint: fingerprint.String()})
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", nodeID, err)
+			continue
+		}
+		if !resp.Found {
+			lastErr = fmt.Errorf("%s: chunk not found", nodeID)
+			continue
+		}
+		if !chunking.VerifyFingerprint(resp.ChunkData, fingerprint) {
+			lastErr = fmt.Errorf("%s: chunk data does not match fingerprint", nodeID)
+			continue
+		}
+		return resp.ChunkData, nil
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no replicas available")
+	}
+	return nil, fmt.Errorf("failed to read chunk %s: %w", fingerprint, lastErr)
+}
+
+// contains reports whether nodes includes nodeID
+func contains(nodes []string, nodeID string) bool {
+	for _, n := range nodes {
+		if n == nodeID {
+			return true
+		}
+	}
+	return false
+}
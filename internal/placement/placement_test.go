@@ -0,0 +1,464 @@
+package placement
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/db"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// memNode is an in-process storage node keeping chunks in memory
+type memNode struct {
+	pb.UnimplementedStorageServiceServer
+	id       string
+	chunks   map[string][]byte
+	down     bool // reject every request
+	draining bool // reject writes
+	full     bool // reject writes
+	mutex    sync.Mutex
+}
+
+func (n *memNode) StoreChunk(ctx context.Context, req *pb.StoreChunkRequest) (*pb.StoreChunkResponse, error) {
+	if err := n.put(req.Fingerprint, req.ChunkData); err != nil {
+		return nil, err
+	}
+	return &pb.StoreChunkResponse{StorageLocation: req.Fingerprint, StorageNodeId: n.id, Success: true}, nil
+}
+
+func (n *memNode) StoreChunks(stream pb.StorageService_StoreChunksServer) error {
+	resp := &pb.StoreChunksResponse{StorageNodeId: n.id}
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(resp)
+		}
+		if err != nil {
+			return err
+		}
+		ack := &pb.StoreChunkAck{Fingerprint: req.Fingerprint, Success: true}
+		if err := n.put(req.Fingerprint, req.ChunkData); err != nil {
+			ack.Success = false
+			ack.ErrorMessage = err.Error()
+		}
+		resp.Acks = append(resp.Acks, ack)
+	}
+}
+
+func (n *memNode) GetChunk(ctx context.Context, req *pb.GetChunkRequest) (*pb.GetChunkResponse, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.down {
+		return nil, status.Error(codes.Unavailable, "node down")
+	}
+	data, ok := n.chunks[req.Fingerprint]
+	return &pb.GetChunkResponse{ChunkData: data, Size: int64(len(data)), Found: ok}, nil
+}
+
+func (n *memNode) HasChunks(ctx context.Context, req *pb.HasChunksRequest) (*pb.HasChunksResponse, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.down {
+		return nil, status.Error(codes.Unavailable, "node down")
+	}
+	resp := &pb.HasChunksResponse{}
+	for _, fingerprint := range req.Fingerprints {
+		if _, ok := n.chunks[fingerprint]; ok {
+			resp.PresentFingerprints = append(resp.PresentFingerprints, fingerprint)
+		}
+	}
+	return resp, nil
+}
+
+func (n *memNode) DeleteChunk(ctx context.Context, req *pb.DeleteChunkRequest) (*pb.DeleteChunkResponse, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	delete(n.chunks, req.Fingerprint)
+	return &pb.DeleteChunkResponse{Success: true}, nil
+}
+
+func (n *memNode) NodeStatus(ctx context.Context, req *pb.NodeStatusRequest) (*pb.NodeStatusResponse, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.down {
+		return nil, status.Error(codes.Unavailable, "node down")
+	}
+	return &pb.NodeStatusResponse{
+		StorageNodeId:   n.id,
+		ChunkCount:      int64(len(n.chunks)),
+		Draining:        n.draining,
+		Full:            n.full,
+		AcceptingWrites: !n.draining && !n.full,
+	}, nil
+}
+
+func (n *memNode) put(fingerprint string, data []byte) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.down {
+		return status.Error(codes.Unavailable, "node down")
+	}
+	if n.draining || n.full {
+		return status.Error(codes.FailedPrecondition, "node not accepting writes")
+	}
+	if chunking.ComputeFingerprint(data).String() != fingerprint {
+		return status.Error(codes.InvalidArgument, "fingerprint mismatch")
+	}
+	n.chunks[fingerprint] = data
+	return nil
+}
+
+func (n *memNode) has(fingerprint chunking.Fingerprint) bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	_, ok := n.chunks[fingerprint.String()]
+	return ok
+}
+
+// startNode serves a memNode over an in-memory listener
+func startNode(t *testing.T, id string) (*memNode, pb.StorageServiceClient) {
+	t.Helper()
+	node := &memNode{id: id, chunks: make(map[string][]byte)}
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	pb.RegisterStorageServiceServer(server, node)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///"+id,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to connect to %s: %v", id, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return node, pb.NewStorageServiceClient(conn)
+}
+
+// startCluster starts count in-process nodes and adds them to a new cluster
+func startCluster(t *testing.T, count, replicas, writeQuorum int) (*Cluster, map[string]*memNode) {
+	t.Helper()
+	cluster := NewCluster(replicas, writeQuorum, 64)
+	nodes := make(map[string]*memNode)
+	for i := 1; i <= count; i++ {
+		id := fmt.Sprintf("node-%d", i)
+		node, client := startNode(t, id)
+		nodes[id] = node
+		cluster.AddNode(id, client)
+	}
+	return cluster, nodes
+}
+
+func testChunks(count int) []chunking.Chunk {
+	chunks := make([]chunking.Chunk, count)
+	for i := range chunks {
+		data := []byte(fmt.Sprintf("chunk payload %d", i))
+		chunks[i] = chunking.Chunk{Data: data, Fingerprint: chunking.ComputeFingerprint(data), Size: int64(len(data))}
+	}
+	return chunks
+}
+
+// memPlacementIndex is an in-memory PlacementIndex
+type memPlacementIndex struct {
+	placements map[chunking.Fingerprint][]string
+}
+
+func (m *memPlacementIndex) ListChunkPlacements(ctx context.Context, after chunking.Fingerprint, limit int) ([]db.ChunkPlacement, error) {
+	var fingerprints []chunking.Fingerprint
+	for fingerprint := range m.placements {
+		if after.IsZero() || fingerprint.String() > after.String() {
+			fingerprints = append(fingerprints, fingerprint)
+		}
+	}
+	sort.Slice(fingerprints, func(i, j int) bool { return fingerprints[i].String() < fingerprints[j].String() })
+	if len(fingerprints) > limit {
+		fingerprints = fingerprints[:limit]
+	}
+	page := make([]db.ChunkPlacement, len(fingerprints))
+	for i, fingerprint := range fingerprints {
+		page[i] = db.ChunkPlacement{Fingerprint: fingerprint, StorageNodes: m.placements[fingerprint]}
+	}
+	return page, nil
+}
+
+func (m *memPlacementIndex) UpdateChunkPlacement(ctx context.Context, fingerprint chunking.Fingerprint, storageNodes []string) error {
+	m.placements[fingerprint] = storageNodes
+	return nil
+}
+
+func TestRingLookup(t *testing.T) {
+	ring := NewRing(128)
+	for i := 1; i <= 4; i++ {
+		ring.AddNode(fmt.Sprintf("node-%d", i))
+	}
+
+	replicas := ring.Lookup("some-fingerprint", 3)
+	if len(replicas) != 3 {
+		t.Fatalf("Expected 3 replicas, got %v", replicas)
+	}
+	seen := make(map[string]bool)
+	for _, nodeID := range replicas {
+		if seen[nodeID] {
+			t.Errorf("Replica %s returned twice", nodeID)
+		}
+		seen[nodeID] = true
+	}
+
+	// Asking for more replicas than nodes returns every node once
+	if all := ring.Lookup("some-fingerprint", 10); len(all) != 4 {
+		t.Errorf("Expected 4 nodes, got %v", all)
+	}
+
+	// Adding a node moves roughly 1/5 of the primaries, and only onto the new node
+	keys := make([]string, 2000)
+	before := make([]string, len(keys))
+	for i := range keys {
+		keys[i] = chunking.ComputeFingerprint([]byte(fmt.Sprintf("key %d", i))).String()
+		before[i] = ring.Lookup(keys[i], 1)[0]
+	}
+	ring.AddNode("node-5")
+	moved := 0
+	for i, key := range keys {
+		after := ring.Lookup(key, 1)[0]
+		if after != before[i] {
+			moved++
+			if after != "node-5" {
+				t.Errorf("Key %s moved from %s to %s instead of the new node", key, before[i], after)
+			}
+		}
+	}
+	if moved < len(keys)/10 || moved > len(keys)*3/10 {
+		t.Errorf("Expected about %d keys to move, %d moved", len(keys)/5, moved)
+	}
+
+	// Filtered lookups skip rejected nodes
+	filtered := ring.LookupFunc("some-fingerprint", 3, func(nodeID string) bool { return nodeID != replicas[0] })
+	for _, nodeID := range filtered {
+		if nodeID == replicas[0] {
+			t.Errorf("Filtered lookup returned rejected node %s", nodeID)
+		}
+	}
+}
+
+func TestClusterStoreChunksReplicates(t *testing.T) {
+	cluster, nodes := startCluster(t, 5, 3, 2)
+	chunks := testChunks(50)
+
+	placements, err := cluster.StoreChunks(context.Background(), chunks)
+	if err != nil {
+		t.Fatalf("Failed to store chunks: %v", err)
+	}
+
+	for _, chunk := range chunks {
+		placed := placements[chunk.Fingerprint]
+		if len(placed) != 3 {
+			t.Errorf("Chunk %s placed on %v, expected 3 nodes", chunk.Fingerprint.Short(), placed)
+		}
+		for _, nodeID := range placed {
+			if !nodes[nodeID].has(chunk.Fingerprint) {
+				t.Errorf("Chunk %s reported on %s but not stored there", chunk.Fingerprint.Short(), nodeID)
+			}
+		}
+	}
+}
+
+func TestClusterWriteQuorum(t *testing.T) {
+	cluster, nodes := startCluster(t, 3, 3, 2)
+	nodes["node-2"].down = true
+
+	// Two of three replicas satisfy a quorum of two
+	placements, err := cluster.StoreChunks(context.Background(), testChunks(10))
+	if err != nil {
+		t.Fatalf("Expected quorum write to succeed with one node down: %v", err)
+	}
+	for fingerprint, placed := range placements {
+		if contains(placed, "node-2") {
+			t.Errorf("Chunk %s placed on down node", fingerprint.Short())
+		}
+	}
+
+	// A quorum of three cannot be met
+	strict, _ := startCluster(t, 3, 3, 3)
+	strict.RemoveNode("node-3")
+	if _, err := strict.StoreChunks(context.Background(), testChunks(1)); err == nil {
+		t.Fatal("Expected write to fail without a full quorum")
+	}
+}
+
+func TestClusterGetChunkFailsOver(t *testing.T) {
+	cluster, nodes := startCluster(t, 3, 3, 3)
+	chunks := testChunks(1)
+	placements, err := cluster.StoreChunks(context.Background(), chunks)
+	if err != nil {
+		t.Fatalf("Failed to store chunk: %v", err)
+	}
+	fingerprint := chunks[0].Fingerprint
+	replicas := cluster.Replicas(fingerprint)
+
+	// Primary lost the chunk, second replica is corrupt, third is good
+	delete(nodes[replicas[0]].chunks, fingerprint.String())
+	nodes[replicas[1]].chunks[fingerprint.String()] = []byte("corrupt")
+
+	data, err := cluster.GetChunk(context.Background(), fingerprint, placements[fingerprint])
+	if err != nil {
+		t.Fatalf("Expected read to fail over: %v", err)
+	}
+	if string(data) != string(chunks[0].Data) {
+		t.Errorf("Read wrong data: %q", data)
+	}
+
+	nodes[replicas[2]].down = true
+	if _, err := cluster.GetChunk(context.Background(), fingerprint, placements[fingerprint]); err == nil {
+		t.Fatal("Expected read to fail with no good replica")
+	}
+}
+
+func TestClusterRebalance(t *testing.T) {
+	cluster, nodes := startCluster(t, 3, 2, 2)
+	chunks := testChunks(300)
+	placements, err := cluster.StoreChunks(context.Background(), chunks)
+	if err != nil {
+		t.Fatalf("Failed to store chunks: %v", err)
+	}
+	index := &memPlacementIndex{placements: placements}
+
+	// A node joins and another leaves
+	joined, client := startNode(t, "node-4")
+	nodes["node-4"] = joined
+	cluster.AddNode("node-4", client)
+	cluster.RemoveNode("node-1")
+
+	report, err := cluster.Rebalance(context.Background(), index)
+	if err != nil {
+		t.Fatalf("Rebalance failed: %v", err)
+	}
+	if report.ChunksScanned != len(chunks) || report.Failures != 0 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if report.ReplicasCopied == 0 || report.ChunksMoved == 0 {
+		t.Errorf("Expected replicas to move, got %+v", report)
+	}
+
+	for _, chunk := range chunks {
+		desired := cluster.Replicas(chunk.Fingerprint)
+		sort.Strings(desired)
+		placed := index.placements[chunk.Fingerprint]
+		if !samePlacement(desired, placed) {
+			t.Errorf("Chunk %s placed on %v, expected %v", chunk.Fingerprint.Short(), placed, desired)
+		}
+		for _, nodeID := range []string{"node-2", "node-3", "node-4"} {
+			if nodes[nodeID].has(chunk.Fingerprint) != contains(desired, nodeID) {
+				t.Errorf("Chunk %s on %s does not match placement %v", chunk.Fingerprint.Short(), nodeID, desired)
+			}
+		}
+	}
+
+	// A second pass has nothing to do
+	report, err = cluster.Rebalance(context.Background(), index)
+	if err != nil {
+		t.Fatalf("Rebalance failed: %v", err)
+	}
+	if report.ChunksMoved != 0 || report.ReplicasCopied != 0 || report.ReplicasFreed != 0 {
+		t.Errorf("Expected idempotent rebalance, got %+v", report)
+	}
+}
+
+func TestRebalanceResolvesUnknownPlacement(t *testing.T) {
+	cluster, nodes := startCluster(t, 2, 2, 2)
+	chunks := testChunks(5)
+
+	// Chunks stored on one node before replication was configured
+	index := &memPlacementIndex{placements: make(map[chunking.Fingerprint][]string)}
+	for _, chunk := range chunks {
+		nodes["node-1"].chunks[chunk.Fingerprint.String()] = chunk.Data
+		index.placements[chunk.Fingerprint] = nil
+	}
+
+	if _, err := cluster.Rebalance(context.Background(), index); err != nil {
+		t.Fatalf("Rebalance failed: %v", err)
+	}
+	for _, chunk := range chunks {
+		if !nodes["node-2"].has(chunk.Fingerprint) {
+			t.Errorf("Chunk %s not replicated to node-2", chunk.Fingerprint.Short())
+		}
+		if len(index.placements[chunk.Fingerprint]) != 2 {
+			t.Errorf("Chunk %s placement %v, expected both nodes", chunk.Fingerprint.Short(), index.placements[chunk.Fingerprint])
+		}
+	}
+}
+
+func TestClusterAvoidsDrainingAndFullNodes(t *testing.T) {
+	cluster, nodes := startCluster(t, 5, 3, 3)
+	nodes["node-1"].draining = true
+	nodes["node-2"].full = true
+	nodes["node-3"].down = true
+	cluster.RefreshStatus(context.Background())
+
+	if writable := cluster.WritableNodes(); !samePlacement(writable, []string{"node-4", "node-5"}) {
+		t.Errorf("Expected node-4 and node-5 writable, got %v", writable)
+	}
+	if status := cluster.Status("node-1"); status == nil || !status.Draining {
+		t.Errorf("Expected node-1 reported as draining, got %v", status)
+	}
+
+	// Only two nodes accept writes, so a full quorum of three fails
+	if _, err := cluster.StoreChunks(context.Background(), testChunks(1)); err == nil {
+		t.Fatal("Expected write to fail with two writable nodes")
+	}
+
+	// Once the node is back, writes skip only the draining and full nodes
+	nodes["node-3"].down = false
+	cluster.RefreshStatus(context.Background())
+	placements, err := cluster.StoreChunks(context.Background(), testChunks(20))
+	if err != nil {
+		t.Fatalf("Failed to store chunks: %v", err)
+	}
+	for fingerprint, placed := range placements {
+		if !samePlacement(placed, []string{"node-3", "node-4", "node-5"}) {
+			t.Errorf("Chunk %s placed on %v", fingerprint.Short(), placed)
+		}
+	}
+}
+
+func TestRebalanceDrainsNode(t *testing.T) {
+	cluster, nodes := startCluster(t, 4, 2, 2)
+	chunks := testChunks(100)
+	placements, err := cluster.StoreChunks(context.Background(), chunks)
+	if err != nil {
+		t.Fatalf("Failed to store chunks: %v", err)
+	}
+	index := &memPlacementIndex{placements: placements}
+
+	nodes["node-2"].draining = true
+	cluster.RefreshStatus(context.Background())
+
+	if _, err := cluster.Rebalance(context.Background(), index); err != nil {
+		t.Fatalf("Rebalance failed: %v", err)
+	}
+	if len(nodes["node-2"].chunks) != 0 {
+		t.Errorf("Expected draining node to be empty, %d chunks left", len(nodes["node-2"].chunks))
+	}
+	for _, chunk := range chunks {
+		placed := index.placements[chunk.Fingerprint]
+		if len(placed) != 2 || contains(placed, "node-2") {
+			t.Errorf("Chunk %s placed on %v", chunk.Fingerprint.Short(), placed)
+		}
+		// Reads still work from the remaining replicas
+		if _, err := cluster.GetChunk(context.Background(), chunk.Fingerprint, placed); err != nil {
+			t.Errorf("Failed to read chunk %s: %v", chunk.Fingerprint.Short(), err)
+		}
+	}
+}
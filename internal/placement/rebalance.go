@@ -0,0 +1,227 @@
+package placement
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/chunking"
+	"github.com/radhakrishnan.venkat/dedupe-engine/internal/db"
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// rebalancePageSize is the number of chunk placements processed at a time
+const rebalancePageSize = 256
+
+// PlacementIndex is the chunk metadata store walked by the rebalancer
+type PlacementIndex interface {
+	ListChunkPlacements(ctx context.Context, after chunking.Fingerprint, limit int) ([]db.ChunkPlacement, error)
+	UpdateChunkPlacement(ctx context.Context, fingerprint chunking.Fingerprint, storageNodes []string) error
+}
+
+// RebalanceReport summarises a rebalance pass
+type RebalanceReport struct {
+	ChunksScanned  int
+	ChunksMoved    int // chunks whose placement changed
+	ReplicasCopied int
+	ReplicasFreed  int
+	Failures       int
+}
+
+// Rebalance walks every chunk in index and moves replicas so that each chunk
+// is held by the nodes the ring currently assigns to it. New replicas are
+// written before surplus ones are deleted, and a chunk's recorded placement
+// is updated after every change, so an interrupted pass can simply be rerun.
+func (c *Cluster) Rebalance(ctx context.Context, index PlacementIndex) (*RebalanceReport, error) {
+	report := &RebalanceReport{}
+	var after chunking.Fingerprint
+
+	for {
+		page, err := index.ListChunkPlacements(ctx, after, rebalancePageSize)
+		if err != nil {
+			return report, fmt.Errorf("failed to list chunk placements: %w", err)
+		}
+		if len(page) == 0 {
+			return report, nil
+		}
+		after = page[len(page)-1].Fingerprint
+		report.ChunksScanned += len(page)
+
+		if err := c.rebalancePage(ctx, index, page, report); err != nil {
+			return report, err
+		}
+		if len(page) < rebalancePageSize {
+			return report, nil
+		}
+	}
+}
+
+// rebalancePage copies missing replicas for one page of placements in one
+// batch per target node, then frees surplus replicas
+func (c *Cluster) rebalancePage(ctx context.Context, index PlacementIndex, page []db.ChunkPlacement, report *RebalanceReport) error {
+	if err := c.resolveUnknownPlacements(ctx, page); err != nil {
+		return err
+	}
+
+	// Read every chunk that is short of replicas and group it by target node
+	current := make(map[chunking.Fingerprint][]string, len(page))
+	wanted := make(map[chunking.Fingerprint][]string, len(page))
+	byNode := make(map[string][]chunking.Chunk)
+	for _, p := range page {
+		have := c.liveNodes(p.StorageNodes)
+		desired := c.Replicas(p.Fingerprint)
+		current[p.Fingerprint] = have
+		wanted[p.Fingerprint] = desired
+
+		var missing []string
+		for _, nodeID := range desired {
+			if !contains(have, nodeID) && c.Writable(nodeID) {
+				missing = append(missing, nodeID)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		data, err := c.GetChunk(ctx, p.Fingerprint, have)
+		if err != nil {
+			log.Printf("Warning: Rebalance cannot read chunk %s: %v", p.Fingerprint, err)
+			report.Failures++
+			continue
+		}
+		chunk := chunking.Chunk{Data: data, Fingerprint: p.Fingerprint, Size: int64(len(data))}
+		for _, nodeID := range missing {
+			byNode[nodeID] = append(byNode[nodeID], chunk)
+		}
+	}
+
+	for nodeID, chunks := range byNode {
+		stored, err := c.storeOnNode(ctx, nodeID, chunks)
+		if err != nil {
+			log.Printf("Warning: Rebalance failed to copy chunks to %s: %v", nodeID, err)
+		}
+		for _, fingerprint := range stored {
+			if !contains(current[fingerprint], nodeID) {
+				current[fingerprint] = append(current[fingerprint], nodeID)
+				report.ReplicasCopied++
+			}
+		}
+	}
+
+	// Free surplus replicas once every desired replica exists. While too few
+	// nodes are left to hold a full replica set, surplus replicas are kept.
+	for _, p := range page {
+		nodes := current[p.Fingerprint]
+		desired := wanted[p.Fingerprint]
+		complete := len(desired) == c.replicas
+		for _, nodeID := range desired {
+			if !contains(nodes, nodeID) {
+				complete = false
+			}
+		}
+		if complete {
+			kept := append([]string(nil), desired...)
+			for _, nodeID := range nodes {
+				if contains(desired, nodeID) {
+					continue
+				}
+				if err := c.deleteFromNode(ctx, nodeID, p.Fingerprint); err != nil {
+					log.Printf("Warning: Rebalance failed to free chunk %s on %s: %v", p.Fingerprint, nodeID, err)
+					kept = append(kept, nodeID)
+					continue
+				}
+				report.ReplicasFreed++
+			}
+			nodes = kept
+		}
+
+		sort.Strings(nodes)
+		if samePlacement(nodes, p.StorageNodes) {
+			continue
+		}
+		if err := index.UpdateChunkPlacement(ctx, p.Fingerprint, nodes); err != nil {
+			return fmt.Errorf("failed to update placement of chunk %s: %w", p.Fingerprint, err)
+		}
+		report.ChunksMoved++
+	}
+	return nil
+}
+
+// resolveUnknownPlacements fills in the placement of chunks stored before
+// replication by asking every node which of them it holds
+func (c *Cluster) resolveUnknownPlacements(ctx context.Context, page []db.ChunkPlacement) error {
+	var unknown []string
+	positions := make(map[string]int)
+	for i, p := range page {
+		if p.StorageNodes == nil {
+			key := p.Fingerprint.String()
+			unknown = append(unknown, key)
+			positions[key] = i
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	for _, nodeID := range c.Nodes() {
+		client := c.Client(nodeID)
+		if client == nil {
+			continue
+		}
+		resp, err := client.HasChunks(ctx, &pb.HasChunksRequest{Fingerprints: unknown})
+		if err != nil {
+			return fmt.Errorf("failed to query storage node %s: %w", nodeID, err)
+		}
+		for _, key := range resp.PresentFingerprints {
+			i, ok := positions[key]
+			if !ok {
+				continue
+			}
+			page[i].StorageNodes = append(page[i].StorageNodes, nodeID)
+		}
+	}
+	return nil
+}
+
+// liveNodes returns the nodes in the list that are still part of the cluster
+func (c *Cluster) liveNodes(nodes []string) []string {
+	var live []string
+	for _, nodeID := range nodes {
+		if c.Client(nodeID) != nil {
+			live = append(live, nodeID)
+		}
+	}
+	return live
+}
+
+// deleteFromNode removes a chunk from a single node
+func (c *Cluster) deleteFromNode(ctx context.Context, nodeID string, fingerprint chunking.Fingerprint) error {
+	client := c.Client(nodeID)
+	if client == nil {
+		return fmt.Errorf("unknown storage node %s", nodeID)
+	}
+	resp, err := client.DeleteChunk(ctx, &pb.DeleteChunkRequest{Fingerprint: fingerprint.String()})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("%s", resp.ErrorMessage)
+	}
+	return nil
+}
+
+// samePlacement reports whether two sorted node lists are equal
+func samePlacement(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	b = append([]string(nil), b...)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
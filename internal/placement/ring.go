@@ -0,0 +1,124 @@
+package placement
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/zeebo/blake3"
+)
+
+// Ring implements consistent hashing with virtual nodes
+type Ring struct {
+	virtualNodes int
+	points       []uint64          // sorted hash positions
+	owners       map[uint64]string // hash position -> node ID
+	nodes        map[string]bool
+	mutex        sync.RWMutex
+}
+
+// NewRing creates an empty ring placing virtualNodes points per node
+func NewRing(virtualNodes int) *Ring {
+	if virtualNodes < 1 {
+		virtualNodes = 1
+	}
+	return &Ring{
+		virtualNodes: virtualNodes,
+		owners:       make(map[uint64]string),
+		nodes:        make(map[string]bool),
+	}
+}
+
+// AddNode adds a node and its virtual nodes to the ring
+func (r *Ring) AddNode(nodeID string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.nodes[nodeID] {
+		return
+	}
+	r.nodes[nodeID] = true
+	for i := 0; i < r.virtualNodes; i++ {
+		point := hashKey(fmt.Sprintf("%s#%d", nodeID, i))
+		if _, taken := r.owners[point]; taken {
+			continue // astronomically unlikely; keep the first owner
+		}
+		r.owners[point] = nodeID
+		r.points = append(r.points, point)
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+}
+
+// RemoveNode removes a node and its virtual nodes from the ring
+func (r *Ring) RemoveNode(nodeID string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.nodes[nodeID] {
+		return
+	}
+	delete(r.nodes, nodeID)
+	points := r.points[:0]
+	for _, point := range r.points {
+		if r.owners[point] == nodeID {
+			delete(r.owners, point)
+			continue
+		}
+		points = append(points, point)
+	}
+	r.points = points
+}
+
+// Nodes returns the IDs of all nodes on the ring, sorted
+func (r *Ring) Nodes() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	nodes := make([]string, 0, len(r.nodes))
+	for nodeID := range r.nodes {
+		nodes = append(nodes, nodeID)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// Lookup returns up to n distinct nodes responsible for key, walking the ring
+// clockwise from the key's position. The first node is the primary replica.
+func (r *Ring) Lookup(key string, n int) []string {
+	return r.LookupFunc(key, n, nil)
+}
+
+// LookupFunc is like Lookup but skips nodes for which accept returns false
+func (r *Ring) LookupFunc(key string, n int, accept func(nodeID string) bool) []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if len(r.points) == 0 || n <= 0 {
+		return nil
+	}
+
+	point := hashKey(key)
+	start := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= point })
+
+	var result []string
+	seen := make(map[string]bool)
+	for i := 0; i < len(r.points) && len(result) < n; i++ {
+		nodeID := r.owners[r.points[(start+i)%len(r.points)]]
+		if seen[nodeID] {
+			continue
+		}
+		seen[nodeID] = true
+		if accept != nil && !accept(nodeID) {
+			continue
+		}
+		result = append(result, nodeID)
+	}
+	return result
+}
+
+// hashKey maps a key to a position on the ring
+func hashKey(key string) uint64 {
+	sum := blake3.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
@@ -0,0 +1,115 @@
+package placement
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/radhakrishnan.venkat/dedupe-engine/pkg/api"
+)
+
+// nodeHealth is the last known state of a storage node
+type nodeHealth struct {
+	status      *pb.NodeStatusResponse // nil if the node does not report status
+	unreachable bool
+}
+
+// RefreshStatus polls NodeStatus on every node. Nodes that are draining, full
+// or unreachable stop receiving new chunks until a later poll clears them.
+func (c *Cluster) RefreshStatus(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, nodeID := range c.Nodes() {
+		client := c.Client(nodeID)
+		if client == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(nodeID string, client pb.StorageServiceClient) {
+			defer wg.Done()
+			resp, err := client.NodeStatus(ctx, &pb.NodeStatusRequest{})
+
+			health := nodeHealth{status: resp}
+			if err != nil {
+				health.status = nil
+				// Nodes predating NodeStatus are assumed to accept writes
+				health.unreachable = status.Code(err) != codes.Unimplemented
+				if health.unreachable {
+					log.Printf("Warning: Storage node %s status unavailable: %v", nodeID, err)
+				}
+			}
+			c.setHealth(nodeID, health)
+		}(nodeID, client)
+	}
+	wg.Wait()
+}
+
+// RunStatusPolling refreshes node status every interval until ctx is cancelled
+func (c *Cluster) RunStatusPolling(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		c.RefreshStatus(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the last status reported by a node, or nil if unknown
+func (c *Cluster) Status(nodeID string) *pb.NodeStatusResponse {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.health[nodeID].status
+}
+
+// Writable reports whether new chunks may be written to a node
+func (c *Cluster) Writable(nodeID string) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.clients[nodeID] == nil {
+		return false
+	}
+	health := c.health[nodeID]
+	if health.unreachable {
+		return false
+	}
+	return health.status == nil || health.status.AcceptingWrites
+}
+
+// WritableNodes returns the nodes currently accepting writes, sorted
+func (c *Cluster) WritableNodes() []string {
+	var nodes []string
+	for _, nodeID := range c.Nodes() {
+		if c.Writable(nodeID) {
+			nodes = append(nodes, nodeID)
+		}
+	}
+	return nodes
+}
+
+// draining reports whether a node asked to have its chunks moved elsewhere
+func (c *Cluster) draining(nodeID string) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	health := c.health[nodeID]
+	return health.status != nil && health.status.Draining
+}
+
+func (c *Cluster) setHealth(nodeID string, health nodeHealth) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	previous := c.health[nodeID]
+	if health.status != nil && (previous.status == nil || previous.status.AcceptingWrites != health.status.AcceptingWrites) {
+		log.Printf("Storage node %s accepting writes: %v (draining: %v, full: %v)",
+			nodeID, health.status.AcceptingWrites, health.status.Draining, health.status.Full)
+	}
+	c.health[nodeID] = health
+}
@@ -0,0 +1,46 @@
+//go:build linux
+
+package sparse
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// seekExtents walks the file with SEEK_DATA and SEEK_HOLE
+func seekExtents(f *os.File, size int64) ([]Extent, bool, error) {
+	fd := int(f.Fd())
+	var extents []Extent
+	for offset := int64(0); offset < size; {
+		start, err := unix.Seek(fd, offset, unix.SEEK_DATA)
+		if errors.Is(err, unix.ENXIO) {
+			break // only a hole remains
+		}
+		if errors.Is(err, unix.EINVAL) || errors.Is(err, unix.EOPNOTSUPP) {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if start >= size {
+			break
+		}
+		end, err := unix.Seek(fd, start, unix.SEEK_HOLE)
+		if err != nil {
+			return nil, false, err
+		}
+		if end > size {
+			end = size
+		}
+		extents = append(extents, Extent{Offset: start, Length: end - start})
+		offset = end
+	}
+
+	// Leave the file offset where a sequential reader expects it
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, false, err
+	}
+	return extents, true, nil
+}
@@ -0,0 +1,48 @@
+// Package sparse finds the data regions of sparse files and runs of zeros
+package sparse
+
+import "os"
+
+// Extent is a byte range of a file
+type Extent struct {
+	Offset int64
+	Length int64
+}
+
+// End returns the offset just past the extent
+func (e Extent) End() int64 {
+	return e.Offset + e.Length
+}
+
+// DataExtents returns the regions of f below size that hold data, in order.
+// Everything between them is a hole. Where the file system cannot report
+// holes the whole file is a single extent.
+func DataExtents(f *os.File, size int64) ([]Extent, error) {
+	if size == 0 {
+		return nil, nil
+	}
+	extents, ok, err := seekExtents(f, size)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return []Extent{{Offset: 0, Length: size}}, nil
+	}
+	return extents, nil
+}
+
+// IsZero reports whether b holds only zero bytes
+func IsZero(b []byte) bool {
+	for len(b) >= 8 {
+		if b[0]|b[1]|b[2]|b[3]|b[4]|b[5]|b[6]|b[7] != 0 {
+			return false
+		}
+		b = b[8:]
+	}
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
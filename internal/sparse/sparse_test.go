@@ -0,0 +1,72 @@
+package sparse
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDataExtents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sparse.img")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	const size = 64 << 20
+	block := make([]byte, 4096)
+	for i := range block {
+		block[i] = 0xAB
+	}
+	for _, offset := range []int64{0, 32 << 20} {
+		if _, err := f.WriteAt(block, offset); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+
+	extents, err := DataExtents(f, size)
+	if err != nil {
+		t.Fatalf("DataExtents failed: %v", err)
+	}
+	if len(extents) == 1 && extents[0].Length == size {
+		t.Skip("file system does not report holes")
+	}
+	if len(extents) != 2 {
+		t.Fatalf("Expected 2 data extents, got %v", extents)
+	}
+	var data int64
+	for _, e := range extents {
+		data += e.Length
+		if e.End() > size {
+			t.Errorf("Extent %v runs past the end of the file", e)
+		}
+	}
+	if extents[0].Offset != 0 || extents[1].Offset > 32<<20 || extents[1].End() < 32<<20+4096 {
+		t.Errorf("Extents %v do not cover the written blocks", extents)
+	}
+	if data > 1<<20 {
+		t.Errorf("Expected little data in a mostly sparse file, got %d bytes", data)
+	}
+
+	if empty, err := DataExtents(f, 0); err != nil || len(empty) != 0 {
+		t.Errorf("Expected no extents for an empty file, got %v (%v)", empty, err)
+	}
+}
+
+func TestIsZero(t *testing.T) {
+	buf := make([]byte, 1001)
+	if !IsZero(buf) || !IsZero(nil) {
+		t.Error("Expected zero buffers to be reported as zero")
+	}
+	for _, i := range []int{0, 7, 8, 999, 1000} {
+		buf[i] = 1
+		if IsZero(buf) {
+			t.Errorf("Expected nonzero byte at %d to be found", i)
+		}
+		buf[i] = 0
+	}
+}
@@ -21,6 +21,194 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// Type of a file system entry
+type FileType int32
+
+const (
+	FileType_FILE_TYPE_UNSPECIFIED  FileType = 0
+	FileType_FILE_TYPE_REGULAR      FileType = 1
+	FileType_FILE_TYPE_DIRECTORY    FileType = 2
+	FileType_FILE_TYPE_SYMLINK      FileType = 3
+	FileType_FILE_TYPE_HARDLINK     FileType = 4 // Additional name for a regular file sent earlier in the job
+	FileType_FILE_TYPE_FIFO         FileType = 5
+	FileType_FILE_TYPE_CHAR_DEVICE  FileType = 6
+	FileType_FILE_TYPE_BLOCK_DEVICE FileType = 7
+)
+
+// Enum value maps for FileType.
+var (
+	FileType_name = map[int32]string{
+		0: "FILE_TYPE_UNSPECIFIED",
+		1: "FILE_TYPE_REGULAR",
+		2: "FILE_TYPE_DIRECTORY",
+		3: "FILE_TYPE_SYMLINK",
+		4: "FILE_TYPE_HARDLINK",
+		5: "FILE_TYPE_FIFO",
+		6: "FILE_TYPE_CHAR_DEVICE",
+		7: "FILE_TYPE_BLOCK_DEVICE",
+	}
+	FileType_value = map[string]int32{
+		"FILE_TYPE_UNSPECIFIED":  0,
+		"FILE_TYPE_REGULAR":      1,
+		"FILE_TYPE_DIRECTORY":    2,
+		"FILE_TYPE_SYMLINK":      3,
+		"FILE_TYPE_HARDLINK":     4,
+		"FILE_TYPE_FIFO":         5,
+		"FILE_TYPE_CHAR_DEVICE":  6,
+		"FILE_TYPE_BLOCK_DEVICE": 7,
+	}
+)
+
+func (x FileType) Enum() *FileType {
+	p := new(FileType)
+	*p = x
+	return p
+}
+
+func (x FileType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (FileType) Descriptor() protoreflect.EnumDescriptor {
+	return file_pkg_api_dedupe_engine_proto_enumTypes[0].Descriptor()
+}
+
+func (FileType) Type() protoreflect.EnumType {
+	return &file_pkg_api_dedupe_engine_proto_enumTypes[0]
+}
+
+func (x FileType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use FileType.Descriptor instead.
+func (FileType) EnumDescriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{0}
+}
+
+// State of a backup job. A job is PENDING once recorded, RUNNING while its
+// streams upload and FINALIZING while the ingest node seals its data; the
+// other states end it.
+type JobState int32
+
+const (
+	JobState_JOB_STATE_UNSPECIFIED JobState = 0
+	JobState_JOB_STATE_PENDING     JobState = 1
+	JobState_JOB_STATE_RUNNING     JobState = 2
+	JobState_JOB_STATE_FINALIZING  JobState = 3
+	JobState_JOB_STATE_COMPLETED   JobState = 4
+	JobState_JOB_STATE_PARTIAL     JobState = 5 // Some files failed and are not in the snapshot
+	JobState_JOB_STATE_FAILED      JobState = 6
+	JobState_JOB_STATE_CANCELLED   JobState = 7
+)
+
+// Enum value maps for JobState.
+var (
+	JobState_name = map[int32]string{
+		0: "JOB_STATE_UNSPECIFIED",
+		1: "JOB_STATE_PENDING",
+		2: "JOB_STATE_RUNNING",
+		3: "JOB_STATE_FINALIZING",
+		4: "JOB_STATE_COMPLETED",
+		5: "JOB_STATE_PARTIAL",
+		6: "JOB_STATE_FAILED",
+		7: "JOB_STATE_CANCELLED",
+	}
+	JobState_value = map[string]int32{
+		"JOB_STATE_UNSPECIFIED": 0,
+		"JOB_STATE_PENDING":     1,
+		"JOB_STATE_RUNNING":     2,
+		"JOB_STATE_FINALIZING":  3,
+		"JOB_STATE_COMPLETED":   4,
+		"JOB_STATE_PARTIAL":     5,
+		"JOB_STATE_FAILED":      6,
+		"JOB_STATE_CANCELLED":   7,
+	}
+)
+
+func (x JobState) Enum() *JobState {
+	p := new(JobState)
+	*p = x
+	return p
+}
+
+func (x JobState) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (JobState) Descriptor() protoreflect.EnumDescriptor {
+	return file_pkg_api_dedupe_engine_proto_enumTypes[1].Descriptor()
+}
+
+func (JobState) Type() protoreflect.EnumType {
+	return &file_pkg_api_dedupe_engine_proto_enumTypes[1]
+}
+
+func (x JobState) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use JobState.Descriptor instead.
+func (JobState) EnumDescriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{1}
+}
+
+// Why a backup job failed
+type FailureReason int32
+
+const (
+	FailureReason_FAILURE_REASON_UNSPECIFIED     FailureReason = 0
+	FailureReason_FAILURE_REASON_CLIENT_REPORTED FailureReason = 1 // The stream handler ended the job as failed
+	FailureReason_FAILURE_REASON_STREAM_LOST     FailureReason = 2 // No stream of the job was seen within the stale timeout
+	FailureReason_FAILURE_REASON_STORAGE_ERROR   FailureReason = 3 // The job's data could not be made durable
+	FailureReason_FAILURE_REASON_INTERNAL_ERROR  FailureReason = 4 // The job's result could not be recorded
+)
+
+// Enum value maps for FailureReason.
+var (
+	FailureReason_name = map[int32]string{
+		0: "FAILURE_REASON_UNSPECIFIED",
+		1: "FAILURE_REASON_CLIENT_REPORTED",
+		2: "FAILURE_REASON_STREAM_LOST",
+		3: "FAILURE_REASON_STORAGE_ERROR",
+		4: "FAILURE_REASON_INTERNAL_ERROR",
+	}
+	FailureReason_value = map[string]int32{
+		"FAILURE_REASON_UNSPECIFIED":     0,
+		"FAILURE_REASON_CLIENT_REPORTED": 1,
+		"FAILURE_REASON_STREAM_LOST":     2,
+		"FAILURE_REASON_STORAGE_ERROR":   3,
+		"FAILURE_REASON_INTERNAL_ERROR":  4,
+	}
+)
+
+func (x FailureReason) Enum() *FailureReason {
+	p := new(FailureReason)
+	*p = x
+	return p
+}
+
+func (x FailureReason) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (FailureReason) Descriptor() protoreflect.EnumDescriptor {
+	return file_pkg_api_dedupe_engine_proto_enumTypes[2].Descriptor()
+}
+
+func (FailureReason) Type() protoreflect.EnumType {
+	return &file_pkg_api_dedupe_engine_proto_enumTypes[2]
+}
+
+func (x FailureReason) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use FailureReason.Descriptor instead.
+func (FailureReason) EnumDescriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{2}
+}
+
 // Initial message from stream handler to start a backup session
 type BackupStart struct {
 	state           protoimpl.MessageState `protogen:"open.v1"`
@@ -31,6 +219,10 @@ type BackupStart struct {
 	Timestamp       int64                  `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`                                     // Unix timestamp of backup start
 	SourceType      string                 `protobuf:"bytes,6,opt,name=source_type,json=sourceType,proto3" json:"source_type,omitempty"`                  // e.g., "filesystem", "database", "vm", "cloud_storage"
 	SourceDetails   string                 `protobuf:"bytes,7,opt,name=source_details,json=sourceDetails,proto3" json:"source_details,omitempty"`         // JSON or specific format for source-specific config
+	BaseJobId       string                 `protobuf:"bytes,8,opt,name=base_job_id,json=baseJobId,proto3" json:"base_job_id,omitempty"`                   // Previous job that unchanged files refer to, for incremental backups
+	Resume          bool                   `protobuf:"varint,9,opt,name=resume,proto3" json:"resume,omitempty"`                                           // Continue an interrupted job with the same backup_job_id from its last checkpoint
+	Join            bool                   `protobuf:"varint,10,opt,name=join,proto3" json:"join,omitempty"`                                              // Add this stream to the running job with the same backup_job_id
+	Archives        bool                   `protobuf:"varint,11,opt,name=archives,proto3" json:"archives,omitempty"`                                      // Chunk the member contents of tar and zip files apart from their headers
 	unknownFields   protoimpl.UnknownFields
 	sizeCache       protoimpl.SizeCache
 }
@@ -114,6 +306,34 @@ func (x *BackupStart) GetSourceDetails() string {
 	return ""
 }
 
+func (x *BackupStart) GetBaseJobId() string {
+	if x != nil {
+		return x.BaseJobId
+	}
+	return ""
+}
+
+func (x *BackupStart) GetResume() bool {
+	if x != nil {
+		return x.Resume
+	}
+	return false
+}
+
+func (x *BackupStart) GetJoin() bool {
+	if x != nil {
+		return x.Join
+	}
+	return false
+}
+
+func (x *BackupStart) GetArchives() bool {
+	if x != nil {
+		return x.Archives
+	}
+	return false
+}
+
 // Message for sending file/data object metadata and data segments
 type FileSegment struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -123,6 +343,8 @@ type FileSegment struct {
 	Offset        uint64                 `protobuf:"varint,4,opt,name=offset,proto3" json:"offset,omitempty"`                                      // Offset of this segment within the file/object
 	IsLastSegment bool                   `protobuf:"varint,5,opt,name=is_last_segment,json=isLastSegment,proto3" json:"is_last_segment,omitempty"` // True if this is the last segment for the file/object
 	FileHash      string                 `protobuf:"bytes,6,opt,name=file_hash,json=fileHash,proto3" json:"file_hash,omitempty"`                   // Optional: Hash of the entire file/object (e.g., Blake3)
+	HoleSize      uint64                 `protobuf:"varint,7,opt,name=hole_size,json=holeSize,proto3" json:"hole_size,omitempty"`                  // When set, the segment is a run of hole_size zero bytes at offset and data is empty
+	SizeUnknown   bool                   `protobuf:"varint,8,opt,name=size_unknown,json=sizeUnknown,proto3" json:"size_unknown,omitempty"`         // The source's size is not known in advance, e.g. a pipe; file_size is 0 and the size is what is received
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -199,33 +421,42 @@ func (x *FileSegment) GetFileHash() string {
 	return ""
 }
 
-// Stream Handler sends a stream of these messages
-type BackupRequest struct {
-	state protoimpl.MessageState `protogen:"open.v1"`
-	// Types that are valid to be assigned to RequestType:
-	//
-	//	*BackupRequest_StartBackup
-	//	*BackupRequest_FileSegment
-	//	*BackupRequest_EndBackup
-	RequestType   isBackupRequest_RequestType `protobuf_oneof:"request_type"`
+func (x *FileSegment) GetHoleSize() uint64 {
+	if x != nil {
+		return x.HoleSize
+	}
+	return 0
+}
+
+func (x *FileSegment) GetSizeUnknown() bool {
+	if x != nil {
+		return x.SizeUnknown
+	}
+	return false
+}
+
+type ExtendedAttribute struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Value         []byte                 `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *BackupRequest) Reset() {
-	*x = BackupRequest{}
+func (x *ExtendedAttribute) Reset() {
+	*x = ExtendedAttribute{}
 	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *BackupRequest) String() string {
+func (x *ExtendedAttribute) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*BackupRequest) ProtoMessage() {}
+func (*ExtendedAttribute) ProtoMessage() {}
 
-func (x *BackupRequest) ProtoReflect() protoreflect.Message {
+func (x *ExtendedAttribute) ProtoReflect() protoreflect.Message {
 	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -237,155 +468,2342 @@ func (x *BackupRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use BackupRequest.ProtoReflect.Descriptor instead.
-func (*BackupRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use ExtendedAttribute.ProtoReflect.Descriptor instead.
+func (*ExtendedAttribute) Descriptor() ([]byte, []int) {
 	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{2}
 }
 
-func (x *BackupRequest) GetRequestType() isBackupRequest_RequestType {
+func (x *ExtendedAttribute) GetName() string {
 	if x != nil {
-		return x.RequestType
+		return x.Name
 	}
-	return nil
+	return ""
 }
 
-func (x *BackupRequest) GetStartBackup() *BackupStart {
+func (x *ExtendedAttribute) GetValue() []byte {
 	if x != nil {
-		if x, ok := x.RequestType.(*BackupRequest_StartBackup); ok {
-			return x.StartBackup
-		}
+		return x.Value
 	}
 	return nil
 }
 
-func (x *BackupRequest) GetFileSegment() *FileSegment {
+// POSIX metadata of a file system entry. Sent before the segments of a
+// regular file, and on its own for every other type.
+type FileEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FilePath      string                 `protobuf:"bytes,1,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"` // Matches FileSegment.file_path
+	Type          FileType               `protobuf:"varint,2,opt,name=type,proto3,enum=dedupe_engine.FileType" json:"type,omitempty"`
+	Mode          uint32                 `protobuf:"varint,3,opt,name=mode,proto3" json:"mode,omitempty"` // Permission bits including setuid, setgid and sticky
+	Uid           uint32                 `protobuf:"varint,4,opt,name=uid,proto3" json:"uid,omitempty"`
+	Gid           uint32                 `protobuf:"varint,5,opt,name=gid,proto3" json:"gid,omitempty"`
+	Owner         string                 `protobuf:"bytes,6,opt,name=owner,proto3" json:"owner,omitempty"` // User name, used in preference to uid when it exists on restore
+	Group         string                 `protobuf:"bytes,7,opt,name=group,proto3" json:"group,omitempty"`
+	MtimeNs       int64                  `protobuf:"varint,8,opt,name=mtime_ns,json=mtimeNs,proto3" json:"mtime_ns,omitempty"` // Modification time, Unix nanoseconds
+	AtimeNs       int64                  `protobuf:"varint,9,opt,name=atime_ns,json=atimeNs,proto3" json:"atime_ns,omitempty"`
+	Size          uint64                 `protobuf:"varint,10,opt,name=size,proto3" json:"size,omitempty"`
+	LinkTarget    string                 `protobuf:"bytes,11,opt,name=link_target,json=linkTarget,proto3" json:"link_target,omitempty"` // Symlink target, or the file_path a hard link refers to
+	Xattrs        []*ExtendedAttribute   `protobuf:"bytes,12,rep,name=xattrs,proto3" json:"xattrs,omitempty"`
+	Rdev          uint64                 `protobuf:"varint,13,opt,name=rdev,proto3" json:"rdev,omitempty"`                                 // Device number for character and block devices
+	Inode         uint64                 `protobuf:"varint,14,opt,name=inode,proto3" json:"inode,omitempty"`                               // Used with ctime_ns to detect unchanged files in incremental backups
+	CtimeNs       int64                  `protobuf:"varint,15,opt,name=ctime_ns,json=ctimeNs,proto3" json:"ctime_ns,omitempty"`            // Status change time, Unix nanoseconds
+	ContentHash   string                 `protobuf:"bytes,16,opt,name=content_hash,json=contentHash,proto3" json:"content_hash,omitempty"` // Whole-file hash of a regular file in a manifest, see chunking.FileHash
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileEntry) Reset() {
+	*x = FileEntry{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileEntry) ProtoMessage() {}
+
+func (x *FileEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[3]
 	if x != nil {
-		if x, ok := x.RequestType.(*BackupRequest_FileSegment); ok {
-			return x.FileSegment
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
+		return ms
 	}
-	return nil
+	return mi.MessageOf(x)
 }
 
-func (x *BackupRequest) GetEndBackup() *BackupEnd {
+// Deprecated: Use FileEntry.ProtoReflect.Descriptor instead.
+func (*FileEntry) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *FileEntry) GetFilePath() string {
 	if x != nil {
-		if x, ok := x.RequestType.(*BackupRequest_EndBackup); ok {
-			return x.EndBackup
-		}
+		return x.FilePath
 	}
-	return nil
+	return ""
 }
 
-type isBackupRequest_RequestType interface {
-	isBackupRequest_RequestType()
+func (x *FileEntry) GetType() FileType {
+	if x != nil {
+		return x.Type
+	}
+	return FileType_FILE_TYPE_UNSPECIFIED
 }
 
-type BackupRequest_StartBackup struct {
-	StartBackup *BackupStart `protobuf:"bytes,1,opt,name=start_backup,json=startBackup,proto3,oneof"`
+func (x *FileEntry) GetMode() uint32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
 }
 
-type BackupRequest_FileSegment struct {
-	FileSegment *FileSegment `protobuf:"bytes,2,opt,name=file_segment,json=fileSegment,proto3,oneof"`
+func (x *FileEntry) GetUid() uint32 {
+	if x != nil {
+		return x.Uid
+	}
+	return 0
 }
 
-type BackupRequest_EndBackup struct {
-	EndBackup *BackupEnd `protobuf:"bytes,3,opt,name=end_backup,json=endBackup,proto3,oneof"`
+func (x *FileEntry) GetGid() uint32 {
+	if x != nil {
+		return x.Gid
+	}
+	return 0
 }
 
-func (*BackupRequest_StartBackup) isBackupRequest_RequestType() {}
-
-func (*BackupRequest_FileSegment) isBackupRequest_RequestType() {}
+func (x *FileEntry) GetOwner() string {
+	if x != nil {
+		return x.Owner
+	}
+	return ""
+}
 
-func (*BackupRequest_EndBackup) isBackupRequest_RequestType() {}
+func (x *FileEntry) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
 
-// Message from stream handler to signal end of backup session
-type BackupEnd struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	BackupJobId   string                 `protobuf:"bytes,1,opt,name=backup_job_id,json=backupJobId,proto3" json:"backup_job_id,omitempty"`
-	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`   // e.g., "COMPLETED", "FAILED"
-	Summary       string                 `protobuf:"bytes,3,opt,name=summary,proto3" json:"summary,omitempty"` // Optional: summary message
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *FileEntry) GetMtimeNs() int64 {
+	if x != nil {
+		return x.MtimeNs
+	}
+	return 0
 }
 
-func (x *BackupEnd) Reset() {
-	*x = BackupEnd{}
-	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[3]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+func (x *FileEntry) GetAtimeNs() int64 {
+	if x != nil {
+		return x.AtimeNs
+	}
+	return 0
 }
 
-func (x *BackupEnd) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *FileEntry) GetSize() uint64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
 }
 
-func (*BackupEnd) ProtoMessage() {}
+func (x *FileEntry) GetLinkTarget() string {
+	if x != nil {
+		return x.LinkTarget
+	}
+	return ""
+}
 
-func (x *BackupEnd) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[3]
+func (x *FileEntry) GetXattrs() []*ExtendedAttribute {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.Xattrs
 	}
-	return mi.MessageOf(x)
+	return nil
 }
 
-// Deprecated: Use BackupEnd.ProtoReflect.Descriptor instead.
-func (*BackupEnd) Descriptor() ([]byte, []int) {
-	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{3}
+func (x *FileEntry) GetRdev() uint64 {
+	if x != nil {
+		return x.Rdev
+	}
+	return 0
 }
 
-func (x *BackupEnd) GetBackupJobId() string {
+func (x *FileEntry) GetInode() uint64 {
 	if x != nil {
-		return x.BackupJobId
+		return x.Inode
 	}
-	return ""
+	return 0
 }
 
-func (x *BackupEnd) GetStatus() string {
+func (x *FileEntry) GetCtimeNs() int64 {
 	if x != nil {
-		return x.Status
+		return x.CtimeNs
 	}
-	return ""
+	return 0
 }
 
-func (x *BackupEnd) GetSummary() string {
+func (x *FileEntry) GetContentHash() string {
 	if x != nil {
-		return x.Summary
+		return x.ContentHash
 	}
 	return ""
 }
 
-// Server sends a stream of these messages back to the stream handler
-type BackupResponse struct {
-	state protoimpl.MessageState `protogen:"open.v1"`
-	// Types that are valid to be assigned to ResponseType:
-	//
-	//	*BackupResponse_StatusUpdate
-	//	*BackupResponse_ErrorMessage
-	ResponseType  isBackupResponse_ResponseType `protobuf_oneof:"response_type"`
+// Chunk of a file identified by its fingerprint, for source-side deduplication
+type ChunkRef struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Fingerprint   []byte                 `protobuf:"bytes,1,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"` // binary form of the chunk fingerprint; empty for a hole
+	Size          uint64                 `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *BackupResponse) Reset() {
-	*x = BackupResponse{}
+func (x *ChunkRef) Reset() {
+	*x = ChunkRef{}
 	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *BackupResponse) String() string {
+func (x *ChunkRef) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChunkRef) ProtoMessage() {}
+
+func (x *ChunkRef) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChunkRef.ProtoReflect.Descriptor instead.
+func (*ChunkRef) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ChunkRef) GetFingerprint() []byte {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return nil
+}
+
+func (x *ChunkRef) GetSize() uint64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+// Fingerprints of a file's chunks in file order, sent instead of its data.
+// The Ingest Node answers each batch with MissingChunks.
+type ChunkRefBatch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FilePath      string                 `protobuf:"bytes,1,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`
+	Chunks        []*ChunkRef            `protobuf:"bytes,2,rep,name=chunks,proto3" json:"chunks,omitempty"`
+	IsLastBatch   bool                   `protobuf:"varint,3,opt,name=is_last_batch,json=isLastBatch,proto3" json:"is_last_batch,omitempty"` // True if this batch ends the file
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChunkRefBatch) Reset() {
+	*x = ChunkRefBatch{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChunkRefBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChunkRefBatch) ProtoMessage() {}
+
+func (x *ChunkRefBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChunkRefBatch.ProtoReflect.Descriptor instead.
+func (*ChunkRefBatch) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ChunkRefBatch) GetFilePath() string {
+	if x != nil {
+		return x.FilePath
+	}
+	return ""
+}
+
+func (x *ChunkRefBatch) GetChunks() []*ChunkRef {
+	if x != nil {
+		return x.Chunks
+	}
+	return nil
+}
+
+func (x *ChunkRefBatch) GetIsLastBatch() bool {
+	if x != nil {
+		return x.IsLastBatch
+	}
+	return false
+}
+
+// Data of a chunk the Ingest Node reported missing
+type ChunkData struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FilePath      string                 `protobuf:"bytes,1,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`
+	Fingerprint   []byte                 `protobuf:"bytes,2,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"` // binary form, as in ChunkRef
+	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChunkData) Reset() {
+	*x = ChunkData{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChunkData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChunkData) ProtoMessage() {}
+
+func (x *ChunkData) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChunkData.ProtoReflect.Descriptor instead.
+func (*ChunkData) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ChunkData) GetFilePath() string {
+	if x != nil {
+		return x.FilePath
+	}
+	return ""
+}
+
+func (x *ChunkData) GetFingerprint() []byte {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return nil
+}
+
+func (x *ChunkData) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+// Stream Handler sends a stream of these messages
+type BackupRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to RequestType:
+	//
+	//	*BackupRequest_StartBackup
+	//	*BackupRequest_FileSegment
+	//	*BackupRequest_EndBackup
+	//	*BackupRequest_FileEntry
+	//	*BackupRequest_ChunkRefs
+	//	*BackupRequest_ChunkData
+	//	*BackupRequest_UnchangedFile
+	//	*BackupRequest_FileRange
+	RequestType   isBackupRequest_RequestType `protobuf_oneof:"request_type"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BackupRequest) Reset() {
+	*x = BackupRequest{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BackupRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BackupRequest) ProtoMessage() {}
+
+func (x *BackupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BackupRequest.ProtoReflect.Descriptor instead.
+func (*BackupRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *BackupRequest) GetRequestType() isBackupRequest_RequestType {
+	if x != nil {
+		return x.RequestType
+	}
+	return nil
+}
+
+func (x *BackupRequest) GetStartBackup() *BackupStart {
+	if x != nil {
+		if x, ok := x.RequestType.(*BackupRequest_StartBackup); ok {
+			return x.StartBackup
+		}
+	}
+	return nil
+}
+
+func (x *BackupRequest) GetFileSegment() *FileSegment {
+	if x != nil {
+		if x, ok := x.RequestType.(*BackupRequest_FileSegment); ok {
+			return x.FileSegment
+		}
+	}
+	return nil
+}
+
+func (x *BackupRequest) GetEndBackup() *BackupEnd {
+	if x != nil {
+		if x, ok := x.RequestType.(*BackupRequest_EndBackup); ok {
+			return x.EndBackup
+		}
+	}
+	return nil
+}
+
+func (x *BackupRequest) GetFileEntry() *FileEntry {
+	if x != nil {
+		if x, ok := x.RequestType.(*BackupRequest_FileEntry); ok {
+			return x.FileEntry
+		}
+	}
+	return nil
+}
+
+func (x *BackupRequest) GetChunkRefs() *ChunkRefBatch {
+	if x != nil {
+		if x, ok := x.RequestType.(*BackupRequest_ChunkRefs); ok {
+			return x.ChunkRefs
+		}
+	}
+	return nil
+}
+
+func (x *BackupRequest) GetChunkData() *ChunkData {
+	if x != nil {
+		if x, ok := x.RequestType.(*BackupRequest_ChunkData); ok {
+			return x.ChunkData
+		}
+	}
+	return nil
+}
+
+func (x *BackupRequest) GetUnchangedFile() *UnchangedFile {
+	if x != nil {
+		if x, ok := x.RequestType.(*BackupRequest_UnchangedFile); ok {
+			return x.UnchangedFile
+		}
+	}
+	return nil
+}
+
+func (x *BackupRequest) GetFileRange() *FileRange {
+	if x != nil {
+		if x, ok := x.RequestType.(*BackupRequest_FileRange); ok {
+			return x.FileRange
+		}
+	}
+	return nil
+}
+
+type isBackupRequest_RequestType interface {
+	isBackupRequest_RequestType()
+}
+
+type BackupRequest_StartBackup struct {
+	StartBackup *BackupStart `protobuf:"bytes,1,opt,name=start_backup,json=startBackup,proto3,oneof"`
+}
+
+type BackupRequest_FileSegment struct {
+	FileSegment *FileSegment `protobuf:"bytes,2,opt,name=file_segment,json=fileSegment,proto3,oneof"`
+}
+
+type BackupRequest_EndBackup struct {
+	EndBackup *BackupEnd `protobuf:"bytes,3,opt,name=end_backup,json=endBackup,proto3,oneof"`
+}
+
+type BackupRequest_FileEntry struct {
+	FileEntry *FileEntry `protobuf:"bytes,4,opt,name=file_entry,json=fileEntry,proto3,oneof"`
+}
+
+type BackupRequest_ChunkRefs struct {
+	ChunkRefs *ChunkRefBatch `protobuf:"bytes,5,opt,name=chunk_refs,json=chunkRefs,proto3,oneof"`
+}
+
+type BackupRequest_ChunkData struct {
+	ChunkData *ChunkData `protobuf:"bytes,6,opt,name=chunk_data,json=chunkData,proto3,oneof"`
+}
+
+type BackupRequest_UnchangedFile struct {
+	UnchangedFile *UnchangedFile `protobuf:"bytes,7,opt,name=unchanged_file,json=unchangedFile,proto3,oneof"`
+}
+
+type BackupRequest_FileRange struct {
+	FileRange *FileRange `protobuf:"bytes,8,opt,name=file_range,json=fileRange,proto3,oneof"`
+}
+
+func (*BackupRequest_StartBackup) isBackupRequest_RequestType() {}
+
+func (*BackupRequest_FileSegment) isBackupRequest_RequestType() {}
+
+func (*BackupRequest_EndBackup) isBackupRequest_RequestType() {}
+
+func (*BackupRequest_FileEntry) isBackupRequest_RequestType() {}
+
+func (*BackupRequest_ChunkRefs) isBackupRequest_RequestType() {}
+
+func (*BackupRequest_ChunkData) isBackupRequest_RequestType() {}
+
+func (*BackupRequest_UnchangedFile) isBackupRequest_RequestType() {}
+
+func (*BackupRequest_FileRange) isBackupRequest_RequestType() {}
+
+// Regular file that is unchanged since the base job. Its contents are taken
+// from the base job's manifest instead of being sent again.
+type UnchangedFile struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entry         *FileEntry             `protobuf:"bytes,1,opt,name=entry,proto3" json:"entry,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnchangedFile) Reset() {
+	*x = UnchangedFile{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnchangedFile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnchangedFile) ProtoMessage() {}
+
+func (x *UnchangedFile) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnchangedFile.ProtoReflect.Descriptor instead.
+func (*UnchangedFile) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *UnchangedFile) GetEntry() *FileEntry {
+	if x != nil {
+		return x.Entry
+	}
+	return nil
+}
+
+// Byte range of a large file uploaded separately, possibly on another stream
+// of the same job. The FileSegments or ChunkRefBatches of the range follow on
+// this stream, and the file is recorded once all of its ranges are complete.
+type FileRange struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entry         *FileEntry             `protobuf:"bytes,1,opt,name=entry,proto3" json:"entry,omitempty"`
+	Index         uint32                 `protobuf:"varint,2,opt,name=index,proto3" json:"index,omitempty"` // Position of the range in the file
+	Count         uint32                 `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"` // Number of ranges the file is split into
+	Offset        uint64                 `protobuf:"varint,4,opt,name=offset,proto3" json:"offset,omitempty"`
+	Length        uint64                 `protobuf:"varint,5,opt,name=length,proto3" json:"length,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileRange) Reset() {
+	*x = FileRange{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileRange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileRange) ProtoMessage() {}
+
+func (x *FileRange) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileRange.ProtoReflect.Descriptor instead.
+func (*FileRange) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *FileRange) GetEntry() *FileEntry {
+	if x != nil {
+		return x.Entry
+	}
+	return nil
+}
+
+func (x *FileRange) GetIndex() uint32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *FileRange) GetCount() uint32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *FileRange) GetOffset() uint64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *FileRange) GetLength() uint64 {
+	if x != nil {
+		return x.Length
+	}
+	return 0
+}
+
+// Message from stream handler to signal end of backup session
+type BackupEnd struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BackupJobId   string                 `protobuf:"bytes,1,opt,name=backup_job_id,json=backupJobId,proto3" json:"backup_job_id,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`                            // Deprecated: state by name, used when state is unset; COMPLETED_WITH_ERRORS means PARTIAL
+	Summary       string                 `protobuf:"bytes,3,opt,name=summary,proto3" json:"summary,omitempty"`                          // Optional: summary message
+	State         JobState               `protobuf:"varint,4,opt,name=state,proto3,enum=dedupe_engine.JobState" json:"state,omitempty"` // COMPLETED, PARTIAL, FAILED or CANCELLED
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BackupEnd) Reset() {
+	*x = BackupEnd{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BackupEnd) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BackupEnd) ProtoMessage() {}
+
+func (x *BackupEnd) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BackupEnd.ProtoReflect.Descriptor instead.
+func (*BackupEnd) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *BackupEnd) GetBackupJobId() string {
+	if x != nil {
+		return x.BackupJobId
+	}
+	return ""
+}
+
+func (x *BackupEnd) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *BackupEnd) GetSummary() string {
+	if x != nil {
+		return x.Summary
+	}
+	return ""
+}
+
+func (x *BackupEnd) GetState() JobState {
+	if x != nil {
+		return x.State
+	}
+	return JobState_JOB_STATE_UNSPECIFIED
+}
+
+// Server sends a stream of these messages back to the stream handler
+type BackupResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to ResponseType:
+	//
+	//	*BackupResponse_StatusUpdate
+	//	*BackupResponse_ErrorMessage
+	//	*BackupResponse_MissingChunks
+	//	*BackupResponse_ResumePoint
+	//	*BackupResponse_ChunksStored
+	ResponseType  isBackupResponse_ResponseType `protobuf_oneof:"response_type"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BackupResponse) Reset() {
+	*x = BackupResponse{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BackupResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BackupResponse) ProtoMessage() {}
+
+func (x *BackupResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BackupResponse.ProtoReflect.Descriptor instead.
+func (*BackupResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *BackupResponse) GetResponseType() isBackupResponse_ResponseType {
+	if x != nil {
+		return x.ResponseType
+	}
+	return nil
+}
+
+func (x *BackupResponse) GetStatusUpdate() *BackupStatus {
+	if x != nil {
+		if x, ok := x.ResponseType.(*BackupResponse_StatusUpdate); ok {
+			return x.StatusUpdate
+		}
+	}
+	return nil
+}
+
+func (x *BackupResponse) GetErrorMessage() *BackupError {
+	if x != nil {
+		if x, ok := x.ResponseType.(*BackupResponse_ErrorMessage); ok {
+			return x.ErrorMessage
+		}
+	}
+	return nil
+}
+
+func (x *BackupResponse) GetMissingChunks() *MissingChunks {
+	if x != nil {
+		if x, ok := x.ResponseType.(*BackupResponse_MissingChunks); ok {
+			return x.MissingChunks
+		}
+	}
+	return nil
+}
+
+func (x *BackupResponse) GetResumePoint() *ResumePoint {
+	if x != nil {
+		if x, ok := x.ResponseType.(*BackupResponse_ResumePoint); ok {
+			return x.ResumePoint
+		}
+	}
+	return nil
+}
+
+func (x *BackupResponse) GetChunksStored() *ChunksStored {
+	if x != nil {
+		if x, ok := x.ResponseType.(*BackupResponse_ChunksStored); ok {
+			return x.ChunksStored
+		}
+	}
+	return nil
+}
+
+type isBackupResponse_ResponseType interface {
+	isBackupResponse_ResponseType()
+}
+
+type BackupResponse_StatusUpdate struct {
+	StatusUpdate *BackupStatus `protobuf:"bytes,1,opt,name=status_update,json=statusUpdate,proto3,oneof"`
+}
+
+type BackupResponse_ErrorMessage struct {
+	ErrorMessage *BackupError `protobuf:"bytes,2,opt,name=error_message,json=errorMessage,proto3,oneof"`
+}
+
+type BackupResponse_MissingChunks struct {
+	MissingChunks *MissingChunks `protobuf:"bytes,3,opt,name=missing_chunks,json=missingChunks,proto3,oneof"`
+}
+
+type BackupResponse_ResumePoint struct {
+	ResumePoint *ResumePoint `protobuf:"bytes,4,opt,name=resume_point,json=resumePoint,proto3,oneof"`
+}
+
+type BackupResponse_ChunksStored struct {
+	ChunksStored *ChunksStored `protobuf:"bytes,5,opt,name=chunks_stored,json=chunksStored,proto3,oneof"`
+}
+
+func (*BackupResponse_StatusUpdate) isBackupResponse_ResponseType() {}
+
+func (*BackupResponse_ErrorMessage) isBackupResponse_ResponseType() {}
+
+func (*BackupResponse_MissingChunks) isBackupResponse_ResponseType() {}
+
+func (*BackupResponse_ResumePoint) isBackupResponse_ResponseType() {}
+
+func (*BackupResponse_ChunksStored) isBackupResponse_ResponseType() {}
+
+// Acknowledges a batch of chunks of a file once they are stored. Chunks the
+// batch found already stored are acknowledged with it, so everything up to
+// the last acknowledged chunk survives a broken stream. With erasure coding,
+// new chunks are durable once their container is sealed.
+type ChunksStored struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FilePath      string                 `protobuf:"bytes,1,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`
+	Fingerprints  [][]byte               `protobuf:"bytes,2,rep,name=fingerprints,proto3" json:"fingerprints,omitempty"`             // binary form, as in ChunkRef; in file order, without holes
+	NewChunks     uint32                 `protobuf:"varint,3,opt,name=new_chunks,json=newChunks,proto3" json:"new_chunks,omitempty"` // of which were stored by this batch
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChunksStored) Reset() {
+	*x = ChunksStored{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChunksStored) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChunksStored) ProtoMessage() {}
+
+func (x *ChunksStored) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChunksStored.ProtoReflect.Descriptor instead.
+func (*ChunksStored) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ChunksStored) GetFilePath() string {
+	if x != nil {
+		return x.FilePath
+	}
+	return ""
+}
+
+func (x *ChunksStored) GetFingerprints() [][]byte {
+	if x != nil {
+		return x.Fingerprints
+	}
+	return nil
+}
+
+func (x *ChunksStored) GetNewChunks() uint32 {
+	if x != nil {
+		return x.NewChunks
+	}
+	return 0
+}
+
+// Where a resumed backup continues: after last_file in walk order, with
+// partial_file sent from partial_offset if it still matches partial_entry.
+// Empty fields mean the job starts from the beginning.
+type ResumePoint struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BackupJobId   string                 `protobuf:"bytes,1,opt,name=backup_job_id,json=backupJobId,proto3" json:"backup_job_id,omitempty"`
+	LastFile      string                 `protobuf:"bytes,2,opt,name=last_file,json=lastFile,proto3" json:"last_file,omitempty"`          // Last entry recorded durably
+	PartialFile   string                 `protobuf:"bytes,3,opt,name=partial_file,json=partialFile,proto3" json:"partial_file,omitempty"` // Regular file recorded durably up to partial_offset
+	PartialOffset uint64                 `protobuf:"varint,4,opt,name=partial_offset,json=partialOffset,proto3" json:"partial_offset,omitempty"`
+	PartialEntry  *FileEntry             `protobuf:"bytes,5,opt,name=partial_entry,json=partialEntry,proto3" json:"partial_entry,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResumePoint) Reset() {
+	*x = ResumePoint{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumePoint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumePoint) ProtoMessage() {}
+
+func (x *ResumePoint) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumePoint.ProtoReflect.Descriptor instead.
+func (*ResumePoint) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ResumePoint) GetBackupJobId() string {
+	if x != nil {
+		return x.BackupJobId
+	}
+	return ""
+}
+
+func (x *ResumePoint) GetLastFile() string {
+	if x != nil {
+		return x.LastFile
+	}
+	return ""
+}
+
+func (x *ResumePoint) GetPartialFile() string {
+	if x != nil {
+		return x.PartialFile
+	}
+	return ""
+}
+
+func (x *ResumePoint) GetPartialOffset() uint64 {
+	if x != nil {
+		return x.PartialOffset
+	}
+	return 0
+}
+
+func (x *ResumePoint) GetPartialEntry() *FileEntry {
+	if x != nil {
+		return x.PartialEntry
+	}
+	return nil
+}
+
+// Chunks of a ChunkRefBatch whose data the Stream Handler must send, in the
+// order they should be sent
+type MissingChunks struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FilePath      string                 `protobuf:"bytes,1,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`
+	Fingerprints  [][]byte               `protobuf:"bytes,2,rep,name=fingerprints,proto3" json:"fingerprints,omitempty"` // binary form, as in ChunkRef
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MissingChunks) Reset() {
+	*x = MissingChunks{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MissingChunks) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MissingChunks) ProtoMessage() {}
+
+func (x *MissingChunks) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MissingChunks.ProtoReflect.Descriptor instead.
+func (*MissingChunks) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *MissingChunks) GetFilePath() string {
+	if x != nil {
+		return x.FilePath
+	}
+	return ""
+}
+
+func (x *MissingChunks) GetFingerprints() [][]byte {
+	if x != nil {
+		return x.Fingerprints
+	}
+	return nil
+}
+
+// Status update from server to stream handler during backup
+type BackupStatus struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	BackupJobId          string                 `protobuf:"bytes,1,opt,name=backup_job_id,json=backupJobId,proto3" json:"backup_job_id,omitempty"`
+	CurrentFile          string                 `protobuf:"bytes,2,opt,name=current_file,json=currentFile,proto3" json:"current_file,omitempty"`
+	BytesProcessed       uint64                 `protobuf:"varint,3,opt,name=bytes_processed,json=bytesProcessed,proto3" json:"bytes_processed,omitempty"`
+	BytesDeduplicated    uint64                 `protobuf:"varint,4,opt,name=bytes_deduplicated,json=bytesDeduplicated,proto3" json:"bytes_deduplicated,omitempty"`
+	Message              string                 `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+	BytesDeltaSaved      uint64                 `protobuf:"varint,6,opt,name=bytes_delta_saved,json=bytesDeltaSaved,proto3" json:"bytes_delta_saved,omitempty"`                           // bytes saved storing near-duplicate chunks as deltas
+	FingerprintAlgorithm string                 `protobuf:"bytes,7,opt,name=fingerprint_algorithm,json=fingerprintAlgorithm,proto3" json:"fingerprint_algorithm,omitempty"`               // hash of the repository's chunk fingerprints, set when a stream starts
+	ChunkingProfile      *ChunkingProfile       `protobuf:"bytes,8,opt,name=chunking_profile,json=chunkingProfile,proto3" json:"chunking_profile,omitempty"`                              // how the repository chunks data, set when a stream starts
+	State                JobState               `protobuf:"varint,9,opt,name=state,proto3,enum=dedupe_engine.JobState" json:"state,omitempty"`                                            // state of the job, set when a stream starts and when the job ends
+	FailureReason        FailureReason          `protobuf:"varint,10,opt,name=failure_reason,json=failureReason,proto3,enum=dedupe_engine.FailureReason" json:"failure_reason,omitempty"` // set when state is FAILED
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *BackupStatus) Reset() {
+	*x = BackupStatus{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BackupStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BackupStatus) ProtoMessage() {}
+
+func (x *BackupStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BackupStatus.ProtoReflect.Descriptor instead.
+func (*BackupStatus) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *BackupStatus) GetBackupJobId() string {
+	if x != nil {
+		return x.BackupJobId
+	}
+	return ""
+}
+
+func (x *BackupStatus) GetCurrentFile() string {
+	if x != nil {
+		return x.CurrentFile
+	}
+	return ""
+}
+
+func (x *BackupStatus) GetBytesProcessed() uint64 {
+	if x != nil {
+		return x.BytesProcessed
+	}
+	return 0
+}
+
+func (x *BackupStatus) GetBytesDeduplicated() uint64 {
+	if x != nil {
+		return x.BytesDeduplicated
+	}
+	return 0
+}
+
+func (x *BackupStatus) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *BackupStatus) GetBytesDeltaSaved() uint64 {
+	if x != nil {
+		return x.BytesDeltaSaved
+	}
+	return 0
+}
+
+func (x *BackupStatus) GetFingerprintAlgorithm() string {
+	if x != nil {
+		return x.FingerprintAlgorithm
+	}
+	return ""
+}
+
+func (x *BackupStatus) GetChunkingProfile() *ChunkingProfile {
+	if x != nil {
+		return x.ChunkingProfile
+	}
+	return nil
+}
+
+func (x *BackupStatus) GetState() JobState {
+	if x != nil {
+		return x.State
+	}
+	return JobState_JOB_STATE_UNSPECIFIED
+}
+
+func (x *BackupStatus) GetFailureReason() FailureReason {
+	if x != nil {
+		return x.FailureReason
+	}
+	return FailureReason_FAILURE_REASON_UNSPECIFIED
+}
+
+// Chunking profile of a repository, which source-side deduplication must
+// chunk with for its chunks to match the repository's. Fingerprints are
+// computed with the BackupStatus's fingerprint_algorithm.
+type ChunkingProfile struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       uint32                 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Algorithm     string                 `protobuf:"bytes,2,opt,name=algorithm,proto3" json:"algorithm,omitempty"` // chunk boundary algorithm, e.g. "rabin"
+	MinSize       uint32                 `protobuf:"varint,3,opt,name=min_size,json=minSize,proto3" json:"min_size,omitempty"`
+	AvgSize       uint32                 `protobuf:"varint,4,opt,name=avg_size,json=avgSize,proto3" json:"avg_size,omitempty"`
+	MaxSize       uint32                 `protobuf:"varint,5,opt,name=max_size,json=maxSize,proto3" json:"max_size,omitempty"`
+	WindowSize    uint32                 `protobuf:"varint,6,opt,name=window_size,json=windowSize,proto3" json:"window_size,omitempty"`
+	Polynomial    uint64                 `protobuf:"varint,7,opt,name=polynomial,proto3" json:"polynomial,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChunkingProfile) Reset() {
+	*x = ChunkingProfile{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChunkingProfile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChunkingProfile) ProtoMessage() {}
+
+func (x *ChunkingProfile) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChunkingProfile.ProtoReflect.Descriptor instead.
+func (*ChunkingProfile) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ChunkingProfile) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *ChunkingProfile) GetAlgorithm() string {
+	if x != nil {
+		return x.Algorithm
+	}
+	return ""
+}
+
+func (x *ChunkingProfile) GetMinSize() uint32 {
+	if x != nil {
+		return x.MinSize
+	}
+	return 0
+}
+
+func (x *ChunkingProfile) GetAvgSize() uint32 {
+	if x != nil {
+		return x.AvgSize
+	}
+	return 0
+}
+
+func (x *ChunkingProfile) GetMaxSize() uint32 {
+	if x != nil {
+		return x.MaxSize
+	}
+	return 0
+}
+
+func (x *ChunkingProfile) GetWindowSize() uint32 {
+	if x != nil {
+		return x.WindowSize
+	}
+	return 0
+}
+
+func (x *ChunkingProfile) GetPolynomial() uint64 {
+	if x != nil {
+		return x.Polynomial
+	}
+	return 0
+}
+
+// Error message from server to stream handler
+type BackupError struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BackupJobId   string                 `protobuf:"bytes,1,opt,name=backup_job_id,json=backupJobId,proto3" json:"backup_job_id,omitempty"`
+	ErrorCode     string                 `protobuf:"bytes,2,opt,name=error_code,json=errorCode,proto3" json:"error_code,omitempty"`
+	ErrorMessage  string                 `protobuf:"bytes,3,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BackupError) Reset() {
+	*x = BackupError{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BackupError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BackupError) ProtoMessage() {}
+
+func (x *BackupError) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BackupError.ProtoReflect.Descriptor instead.
+func (*BackupError) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *BackupError) GetBackupJobId() string {
+	if x != nil {
+		return x.BackupJobId
+	}
+	return ""
+}
+
+func (x *BackupError) GetErrorCode() string {
+	if x != nil {
+		return x.ErrorCode
+	}
+	return ""
+}
+
+func (x *BackupError) GetErrorMessage() string {
+	if x != nil {
+		return x.ErrorMessage
+	}
+	return ""
+}
+
+// Request for the last successful backup of a client and source
+type PreviousSnapshotRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ClientId      string                 `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	SourceType    string                 `protobuf:"bytes,2,opt,name=source_type,json=sourceType,proto3" json:"source_type,omitempty"`
+	SourceDetails string                 `protobuf:"bytes,3,opt,name=source_details,json=sourceDetails,proto3" json:"source_details,omitempty"` // Must match the BackupStart of the previous job exactly
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PreviousSnapshotRequest) Reset() {
+	*x = PreviousSnapshotRequest{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PreviousSnapshotRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PreviousSnapshotRequest) ProtoMessage() {}
+
+func (x *PreviousSnapshotRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PreviousSnapshotRequest.ProtoReflect.Descriptor instead.
+func (*PreviousSnapshotRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *PreviousSnapshotRequest) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *PreviousSnapshotRequest) GetSourceType() string {
+	if x != nil {
+		return x.SourceType
+	}
+	return ""
+}
+
+func (x *PreviousSnapshotRequest) GetSourceDetails() string {
+	if x != nil {
+		return x.SourceDetails
+	}
+	return ""
+}
+
+// Batch of regular files of the previous backup
+type PreviousSnapshotResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BackupJobId   string                 `protobuf:"bytes,1,opt,name=backup_job_id,json=backupJobId,proto3" json:"backup_job_id,omitempty"`
+	Files         []*FileEntry           `protobuf:"bytes,2,rep,name=files,proto3" json:"files,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PreviousSnapshotResponse) Reset() {
+	*x = PreviousSnapshotResponse{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PreviousSnapshotResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PreviousSnapshotResponse) ProtoMessage() {}
+
+func (x *PreviousSnapshotResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PreviousSnapshotResponse.ProtoReflect.Descriptor instead.
+func (*PreviousSnapshotResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *PreviousSnapshotResponse) GetBackupJobId() string {
+	if x != nil {
+		return x.BackupJobId
+	}
+	return ""
+}
+
+func (x *PreviousSnapshotResponse) GetFiles() []*FileEntry {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+type RestoreRequest struct {
+	state                  protoimpl.MessageState `protogen:"open.v1"`
+	ClientId               string                 `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	BackupJobId            string                 `protobuf:"bytes,2,opt,name=backup_job_id,json=backupJobId,proto3" json:"backup_job_id,omitempty"`                                  // The backup job to restore from
+	FilesToRestore         []string               `protobuf:"bytes,3,rep,name=files_to_restore,json=filesToRestore,proto3" json:"files_to_restore,omitempty"`                         // List of specific files/paths to restore
+	RestoreDestinationPath string                 `protobuf:"bytes,4,opt,name=restore_destination_path,json=restoreDestinationPath,proto3" json:"restore_destination_path,omitempty"` // Path on client/source to restore to
+	Streams                uint32                 `protobuf:"varint,5,opt,name=streams,proto3" json:"streams,omitempty"`                                                              // Number of StreamRestoreData streams the entries are split across; 0 means 1
+	unknownFields          protoimpl.UnknownFields
+	sizeCache              protoimpl.SizeCache
+}
+
+func (x *RestoreRequest) Reset() {
+	*x = RestoreRequest{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestoreRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestoreRequest) ProtoMessage() {}
+
+func (x *RestoreRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestoreRequest.ProtoReflect.Descriptor instead.
+func (*RestoreRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *RestoreRequest) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *RestoreRequest) GetBackupJobId() string {
+	if x != nil {
+		return x.BackupJobId
+	}
+	return ""
+}
+
+func (x *RestoreRequest) GetFilesToRestore() []string {
+	if x != nil {
+		return x.FilesToRestore
+	}
+	return nil
+}
+
+func (x *RestoreRequest) GetRestoreDestinationPath() string {
+	if x != nil {
+		return x.RestoreDestinationPath
+	}
+	return ""
+}
+
+func (x *RestoreRequest) GetStreams() uint32 {
+	if x != nil {
+		return x.Streams
+	}
+	return 0
+}
+
+type RestoreResponse struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	RestoreJobId         string                 `protobuf:"bytes,1,opt,name=restore_job_id,json=restoreJobId,proto3" json:"restore_job_id,omitempty"` // Unique ID for the restore operation
+	Status               string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`                                   // e.g., "INITIATED", "FAILED"
+	Message              string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	FingerprintAlgorithm string                 `protobuf:"bytes,4,opt,name=fingerprint_algorithm,json=fingerprintAlgorithm,proto3" json:"fingerprint_algorithm,omitempty"` // hash of the repository's chunk fingerprints
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *RestoreResponse) Reset() {
+	*x = RestoreResponse{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestoreResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestoreResponse) ProtoMessage() {}
+
+func (x *RestoreResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestoreResponse.ProtoReflect.Descriptor instead.
+func (*RestoreResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *RestoreResponse) GetRestoreJobId() string {
+	if x != nil {
+		return x.RestoreJobId
+	}
+	return ""
+}
+
+func (x *RestoreResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *RestoreResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *RestoreResponse) GetFingerprintAlgorithm() string {
+	if x != nil {
+		return x.FingerprintAlgorithm
+	}
+	return ""
+}
+
+type RestoreDataRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RestoreJobId  string                 `protobuf:"bytes,1,opt,name=restore_job_id,json=restoreJobId,proto3" json:"restore_job_id,omitempty"`
+	StreamIndex   uint32                 `protobuf:"varint,2,opt,name=stream_index,json=streamIndex,proto3" json:"stream_index,omitempty"` // Which of the restore job's streams this is, from 0
+	ClientId      string                 `protobuf:"bytes,3,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`           // Client that initiated the restore job
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestoreDataRequest) Reset() {
+	*x = RestoreDataRequest{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestoreDataRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestoreDataRequest) ProtoMessage() {}
+
+func (x *RestoreDataRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestoreDataRequest.ProtoReflect.Descriptor instead.
+func (*RestoreDataRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *RestoreDataRequest) GetRestoreJobId() string {
+	if x != nil {
+		return x.RestoreJobId
+	}
+	return ""
+}
+
+func (x *RestoreDataRequest) GetStreamIndex() uint32 {
+	if x != nil {
+		return x.StreamIndex
+	}
+	return 0
+}
+
+func (x *RestoreDataRequest) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+type RestoreDataResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RestoreJobId  string                 `protobuf:"bytes,1,opt,name=restore_job_id,json=restoreJobId,proto3" json:"restore_job_id,omitempty"`
+	FilePath      string                 `protobuf:"bytes,2,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`                   // Original full path of the file/object
+	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`                                           // Raw data segment of the file/object
+	Offset        uint64                 `protobuf:"varint,4,opt,name=offset,proto3" json:"offset,omitempty"`                                      // Offset of this segment within the file/object
+	IsLastSegment bool                   `protobuf:"varint,5,opt,name=is_last_segment,json=isLastSegment,proto3" json:"is_last_segment,omitempty"` // True if this is the last segment for the file/object
+	FileEntry     *FileEntry             `protobuf:"bytes,6,opt,name=file_entry,json=fileEntry,proto3" json:"file_entry,omitempty"`                // Set on the first message of each entry
+	HoleSize      uint64                 `protobuf:"varint,7,opt,name=hole_size,json=holeSize,proto3" json:"hole_size,omitempty"`                  // When set, hole_size zero bytes follow offset; data is empty
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestoreDataResponse) Reset() {
+	*x = RestoreDataResponse{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestoreDataResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestoreDataResponse) ProtoMessage() {}
+
+func (x *RestoreDataResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestoreDataResponse.ProtoReflect.Descriptor instead.
+func (*RestoreDataResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *RestoreDataResponse) GetRestoreJobId() string {
+	if x != nil {
+		return x.RestoreJobId
+	}
+	return ""
+}
+
+func (x *RestoreDataResponse) GetFilePath() string {
+	if x != nil {
+		return x.FilePath
+	}
+	return ""
+}
+
+func (x *RestoreDataResponse) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *RestoreDataResponse) GetOffset() uint64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *RestoreDataResponse) GetIsLastSegment() bool {
+	if x != nil {
+		return x.IsLastSegment
+	}
+	return false
+}
+
+func (x *RestoreDataResponse) GetFileEntry() *FileEntry {
+	if x != nil {
+		return x.FileEntry
+	}
+	return nil
+}
+
+func (x *RestoreDataResponse) GetHoleSize() uint64 {
+	if x != nil {
+		return x.HoleSize
+	}
+	return 0
+}
+
+type ListJobsRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ClientId       string                 `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`                     // Client whose jobs are listed; required
+	BackupPolicyId string                 `protobuf:"bytes,2,opt,name=backup_policy_id,json=backupPolicyId,proto3" json:"backup_policy_id,omitempty"` // Only jobs of this policy; all policies if empty
+	States         []JobState             `protobuf:"varint,3,rep,packed,name=states,proto3,enum=dedupe_engine.JobState" json:"states,omitempty"`     // Only jobs in one of these states; any state if empty
+	StartedAfter   int64                  `protobuf:"varint,4,opt,name=started_after,json=startedAfter,proto3" json:"started_after,omitempty"`        // Only jobs started at or after this Unix time; 0 means no bound
+	StartedBefore  int64                  `protobuf:"varint,5,opt,name=started_before,json=startedBefore,proto3" json:"started_before,omitempty"`     // Only jobs started before this Unix time; 0 means no bound
+	PageSize       uint32                 `protobuf:"varint,6,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`                    // Jobs per page; 0 means 100, and at most 1000 are returned
+	PageToken      string                 `protobuf:"bytes,7,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`                  // next_page_token of the previous page; empty for the first page
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ListJobsRequest) Reset() {
+	*x = ListJobsRequest{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListJobsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListJobsRequest) ProtoMessage() {}
+
+func (x *ListJobsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListJobsRequest.ProtoReflect.Descriptor instead.
+func (*ListJobsRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *ListJobsRequest) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *ListJobsRequest) GetBackupPolicyId() string {
+	if x != nil {
+		return x.BackupPolicyId
+	}
+	return ""
+}
+
+func (x *ListJobsRequest) GetStates() []JobState {
+	if x != nil {
+		return x.States
+	}
+	return nil
+}
+
+func (x *ListJobsRequest) GetStartedAfter() int64 {
+	if x != nil {
+		return x.StartedAfter
+	}
+	return 0
+}
+
+func (x *ListJobsRequest) GetStartedBefore() int64 {
+	if x != nil {
+		return x.StartedBefore
+	}
+	return 0
+}
+
+func (x *ListJobsRequest) GetPageSize() uint32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListJobsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type ListJobsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Jobs          []*JobInfo             `protobuf:"bytes,1,rep,name=jobs,proto3" json:"jobs,omitempty"`                                          // Without stats
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"` // Empty on the last page
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListJobsResponse) Reset() {
+	*x = ListJobsResponse{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListJobsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListJobsResponse) ProtoMessage() {}
+
+func (x *ListJobsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListJobsResponse.ProtoReflect.Descriptor instead.
+func (*ListJobsResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *ListJobsResponse) GetJobs() []*JobInfo {
+	if x != nil {
+		return x.Jobs
+	}
+	return nil
+}
+
+func (x *ListJobsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type GetJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BackupJobId   string                 `protobuf:"bytes,1,opt,name=backup_job_id,json=backupJobId,proto3" json:"backup_job_id,omitempty"`
+	ClientId      string                 `protobuf:"bytes,2,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"` // Must be the client the job belongs to
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetJobRequest) Reset() {
+	*x = GetJobRequest{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetJobRequest) ProtoMessage() {}
+
+func (x *GetJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetJobRequest.ProtoReflect.Descriptor instead.
+func (*GetJobRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *GetJobRequest) GetBackupJobId() string {
+	if x != nil {
+		return x.BackupJobId
+	}
+	return ""
+}
+
+func (x *GetJobRequest) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+// A backup job as recorded in the catalog
+type JobInfo struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	BackupJobId    string                 `protobuf:"bytes,1,opt,name=backup_job_id,json=backupJobId,proto3" json:"backup_job_id,omitempty"`
+	ClientId       string                 `protobuf:"bytes,2,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	BackupPolicyId string                 `protobuf:"bytes,3,opt,name=backup_policy_id,json=backupPolicyId,proto3" json:"backup_policy_id,omitempty"`
+	SourceType     string                 `protobuf:"bytes,4,opt,name=source_type,json=sourceType,proto3" json:"source_type,omitempty"`
+	SourceDetails  string                 `protobuf:"bytes,5,opt,name=source_details,json=sourceDetails,proto3" json:"source_details,omitempty"`
+	StartTime      int64                  `protobuf:"varint,6,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"` // Unix time
+	EndTime        int64                  `protobuf:"varint,7,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`       // Unix time; 0 until the job ends
+	State          JobState               `protobuf:"varint,8,opt,name=state,proto3,enum=dedupe_engine.JobState" json:"state,omitempty"`
+	FailureReason  FailureReason          `protobuf:"varint,9,opt,name=failure_reason,json=failureReason,proto3,enum=dedupe_engine.FailureReason" json:"failure_reason,omitempty"` // set when state is FAILED
+	Stats          *JobStats              `protobuf:"bytes,10,opt,name=stats,proto3" json:"stats,omitempty"`                                                                       // set by GetJob
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *JobInfo) Reset() {
+	*x = JobInfo{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobInfo) ProtoMessage() {}
+
+func (x *JobInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobInfo.ProtoReflect.Descriptor instead.
+func (*JobInfo) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *JobInfo) GetBackupJobId() string {
+	if x != nil {
+		return x.BackupJobId
+	}
+	return ""
+}
+
+func (x *JobInfo) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *JobInfo) GetBackupPolicyId() string {
+	if x != nil {
+		return x.BackupPolicyId
+	}
+	return ""
+}
+
+func (x *JobInfo) GetSourceType() string {
+	if x != nil {
+		return x.SourceType
+	}
+	return ""
+}
+
+func (x *JobInfo) GetSourceDetails() string {
+	if x != nil {
+		return x.SourceDetails
+	}
+	return ""
+}
+
+func (x *JobInfo) GetStartTime() int64 {
+	if x != nil {
+		return x.StartTime
+	}
+	return 0
+}
+
+func (x *JobInfo) GetEndTime() int64 {
+	if x != nil {
+		return x.EndTime
+	}
+	return 0
+}
+
+func (x *JobInfo) GetState() JobState {
+	if x != nil {
+		return x.State
+	}
+	return JobState_JOB_STATE_UNSPECIFIED
+}
+
+func (x *JobInfo) GetFailureReason() FailureReason {
+	if x != nil {
+		return x.FailureReason
+	}
+	return FailureReason_FAILURE_REASON_UNSPECIFIED
+}
+
+func (x *JobInfo) GetStats() *JobStats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+// What a backup job processed, and what its manifest holds. Counters of an
+// unfinished job are those of its last checkpoint, or live if the job runs on
+// the ingest node answering.
+type JobStats struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	FilesProcessed    uint64                 `protobuf:"varint,1,opt,name=files_processed,json=filesProcessed,proto3" json:"files_processed,omitempty"`
+	FilesUnchanged    uint64                 `protobuf:"varint,2,opt,name=files_unchanged,json=filesUnchanged,proto3" json:"files_unchanged,omitempty"`       // taken from the base job's manifest
+	EntriesProcessed  uint64                 `protobuf:"varint,3,opt,name=entries_processed,json=entriesProcessed,proto3" json:"entries_processed,omitempty"` // directories, links and special files
+	ChunksProcessed   uint64                 `protobuf:"varint,4,opt,name=chunks_processed,json=chunksProcessed,proto3" json:"chunks_processed,omitempty"`
+	BytesProcessed    uint64                 `protobuf:"varint,5,opt,name=bytes_processed,json=bytesProcessed,proto3" json:"bytes_processed,omitempty"`
+	BytesDeduplicated uint64                 `protobuf:"varint,6,opt,name=bytes_deduplicated,json=bytesDeduplicated,proto3" json:"bytes_deduplicated,omitempty"`
+	ChunksDelta       uint64                 `protobuf:"varint,7,opt,name=chunks_delta,json=chunksDelta,proto3" json:"chunks_delta,omitempty"`
+	BytesDeltaSaved   uint64                 `protobuf:"varint,8,opt,name=bytes_delta_saved,json=bytesDeltaSaved,proto3" json:"bytes_delta_saved,omitempty"`
+	ManifestEntries   uint64                 `protobuf:"varint,9,opt,name=manifest_entries,json=manifestEntries,proto3" json:"manifest_entries,omitempty"` // entries recorded in the manifest
+	ManifestBytes     uint64                 `protobuf:"varint,10,opt,name=manifest_bytes,json=manifestBytes,proto3" json:"manifest_bytes,omitempty"`      // total size of the regular files in the manifest
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *JobStats) Reset() {
+	*x = JobStats{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobStats) ProtoMessage() {}
+
+func (x *JobStats) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobStats.ProtoReflect.Descriptor instead.
+func (*JobStats) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *JobStats) GetFilesProcessed() uint64 {
+	if x != nil {
+		return x.FilesProcessed
+	}
+	return 0
+}
+
+func (x *JobStats) GetFilesUnchanged() uint64 {
+	if x != nil {
+		return x.FilesUnchanged
+	}
+	return 0
+}
+
+func (x *JobStats) GetEntriesProcessed() uint64 {
+	if x != nil {
+		return x.EntriesProcessed
+	}
+	return 0
+}
+
+func (x *JobStats) GetChunksProcessed() uint64 {
+	if x != nil {
+		return x.ChunksProcessed
+	}
+	return 0
+}
+
+func (x *JobStats) GetBytesProcessed() uint64 {
+	if x != nil {
+		return x.BytesProcessed
+	}
+	return 0
+}
+
+func (x *JobStats) GetBytesDeduplicated() uint64 {
+	if x != nil {
+		return x.BytesDeduplicated
+	}
+	return 0
+}
+
+func (x *JobStats) GetChunksDelta() uint64 {
+	if x != nil {
+		return x.ChunksDelta
+	}
+	return 0
+}
+
+func (x *JobStats) GetBytesDeltaSaved() uint64 {
+	if x != nil {
+		return x.BytesDeltaSaved
+	}
+	return 0
+}
+
+func (x *JobStats) GetManifestEntries() uint64 {
+	if x != nil {
+		return x.ManifestEntries
+	}
+	return 0
+}
+
+func (x *JobStats) GetManifestBytes() uint64 {
+	if x != nil {
+		return x.ManifestBytes
+	}
+	return 0
+}
+
+type ListFilesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BackupJobId   string                 `protobuf:"bytes,1,opt,name=backup_job_id,json=backupJobId,proto3" json:"backup_job_id,omitempty"`
+	Prefix        string                 `protobuf:"bytes,2,opt,name=prefix,proto3" json:"prefix,omitempty"`                                  // Only entries whose path starts with this; all entries if empty
+	ChildrenOnly  bool                   `protobuf:"varint,3,opt,name=children_only,json=childrenOnly,proto3" json:"children_only,omitempty"` // Only entries directly in the directory prefix names, e.g. "/src/"
+	PageSize      uint32                 `protobuf:"varint,4,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`             // Entries per page; 0 means 100, and at most 1000 are returned
+	PageToken     string                 `protobuf:"bytes,5,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`           // next_page_token of the previous page; empty for the first page
+	ClientId      string                 `protobuf:"bytes,6,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`              // Must be the client the job belongs to
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListFilesRequest) Reset() {
+	*x = ListFilesRequest{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListFilesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListFilesRequest) ProtoMessage() {}
+
+func (x *ListFilesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListFilesRequest.ProtoReflect.Descriptor instead.
+func (*ListFilesRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *ListFilesRequest) GetBackupJobId() string {
+	if x != nil {
+		return x.BackupJobId
+	}
+	return ""
+}
+
+func (x *ListFilesRequest) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+func (x *ListFilesRequest) GetChildrenOnly() bool {
+	if x != nil {
+		return x.ChildrenOnly
+	}
+	return false
+}
+
+func (x *ListFilesRequest) GetPageSize() uint32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListFilesRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *ListFilesRequest) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+type ListFilesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Files         []*FileEntry           `protobuf:"bytes,1,rep,name=files,proto3" json:"files,omitempty"`
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"` // Empty on the last page
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListFilesResponse) Reset() {
+	*x = ListFilesResponse{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListFilesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListFilesResponse) ProtoMessage() {}
+
+func (x *ListFilesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListFilesResponse.ProtoReflect.Descriptor instead.
+func (*ListFilesResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *ListFilesResponse) GetFiles() []*FileEntry {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *ListFilesResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type DeleteJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ClientId      string                 `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"` // Must be the client the job belongs to
+	BackupJobId   string                 `protobuf:"bytes,2,opt,name=backup_job_id,json=backupJobId,proto3" json:"backup_job_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteJobRequest) Reset() {
+	*x = DeleteJobRequest{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteJobRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*BackupResponse) ProtoMessage() {}
+func (*DeleteJobRequest) ProtoMessage() {}
 
-func (x *BackupResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[4]
+func (x *DeleteJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[31]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -396,79 +2814,97 @@ func (x *BackupResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use BackupResponse.ProtoReflect.Descriptor instead.
-func (*BackupResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{4}
+// Deprecated: Use DeleteJobRequest.ProtoReflect.Descriptor instead.
+func (*DeleteJobRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{31}
 }
 
-func (x *BackupResponse) GetResponseType() isBackupResponse_ResponseType {
+func (x *DeleteJobRequest) GetClientId() string {
 	if x != nil {
-		return x.ResponseType
+		return x.ClientId
 	}
-	return nil
+	return ""
 }
 
-func (x *BackupResponse) GetStatusUpdate() *BackupStatus {
+func (x *DeleteJobRequest) GetBackupJobId() string {
 	if x != nil {
-		if x, ok := x.ResponseType.(*BackupResponse_StatusUpdate); ok {
-			return x.StatusUpdate
-		}
+		return x.BackupJobId
 	}
-	return nil
+	return ""
 }
 
-func (x *BackupResponse) GetErrorMessage() *BackupError {
-	if x != nil {
-		if x, ok := x.ResponseType.(*BackupResponse_ErrorMessage); ok {
-			return x.ErrorMessage
-		}
-	}
-	return nil
+type DeleteJobResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	EntriesDeleted uint64                 `protobuf:"varint,1,opt,name=entries_deleted,json=entriesDeleted,proto3" json:"entries_deleted,omitempty"` // manifest entries removed with the job
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
-type isBackupResponse_ResponseType interface {
-	isBackupResponse_ResponseType()
+func (x *DeleteJobResponse) Reset() {
+	*x = DeleteJobResponse{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-type BackupResponse_StatusUpdate struct {
-	StatusUpdate *BackupStatus `protobuf:"bytes,1,opt,name=status_update,json=statusUpdate,proto3,oneof"`
+func (x *DeleteJobResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-type BackupResponse_ErrorMessage struct {
-	ErrorMessage *BackupError `protobuf:"bytes,2,opt,name=error_message,json=errorMessage,proto3,oneof"`
+func (*DeleteJobResponse) ProtoMessage() {}
+
+func (x *DeleteJobResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
 
-func (*BackupResponse_StatusUpdate) isBackupResponse_ResponseType() {}
+// Deprecated: Use DeleteJobResponse.ProtoReflect.Descriptor instead.
+func (*DeleteJobResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{32}
+}
 
-func (*BackupResponse_ErrorMessage) isBackupResponse_ResponseType() {}
+func (x *DeleteJobResponse) GetEntriesDeleted() uint64 {
+	if x != nil {
+		return x.EntriesDeleted
+	}
+	return 0
+}
 
-// Status update from server to stream handler during backup
-type BackupStatus struct {
-	state             protoimpl.MessageState `protogen:"open.v1"`
-	BackupJobId       string                 `protobuf:"bytes,1,opt,name=backup_job_id,json=backupJobId,proto3" json:"backup_job_id,omitempty"`
-	CurrentFile       string                 `protobuf:"bytes,2,opt,name=current_file,json=currentFile,proto3" json:"current_file,omitempty"`
-	BytesProcessed    uint64                 `protobuf:"varint,3,opt,name=bytes_processed,json=bytesProcessed,proto3" json:"bytes_processed,omitempty"`
-	BytesDeduplicated uint64                 `protobuf:"varint,4,opt,name=bytes_deduplicated,json=bytesDeduplicated,proto3" json:"bytes_deduplicated,omitempty"`
-	Message           string                 `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
-	unknownFields     protoimpl.UnknownFields
-	sizeCache         protoimpl.SizeCache
+type SearchFilesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ClientId      string                 `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	Pattern       string                 `protobuf:"bytes,2,opt,name=pattern,proto3" json:"pattern,omitempty"`                                   // Substring of the paths to find; all paths if empty
+	Glob          bool                   `protobuf:"varint,3,opt,name=glob,proto3" json:"glob,omitempty"`                                        // Match pattern as a glob over whole paths: * matches any characters, including /, and ? one
+	StartedAfter  int64                  `protobuf:"varint,4,opt,name=started_after,json=startedAfter,proto3" json:"started_after,omitempty"`    // Only jobs started at or after this Unix time; 0 means no bound
+	StartedBefore int64                  `protobuf:"varint,5,opt,name=started_before,json=startedBefore,proto3" json:"started_before,omitempty"` // Only jobs started before this Unix time; 0 means no bound
+	PageSize      uint32                 `protobuf:"varint,6,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`                // Paths per page; 0 means 100, and at most 1000 are returned
+	PageToken     string                 `protobuf:"bytes,7,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`              // next_page_token of the previous page; empty for the first page
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *BackupStatus) Reset() {
-	*x = BackupStatus{}
-	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[5]
+func (x *SearchFilesRequest) Reset() {
+	*x = SearchFilesRequest{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[33]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *BackupStatus) String() string {
+func (x *SearchFilesRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*BackupStatus) ProtoMessage() {}
+func (*SearchFilesRequest) ProtoMessage() {}
 
-func (x *BackupStatus) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[5]
+func (x *SearchFilesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[33]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -479,71 +2915,83 @@ func (x *BackupStatus) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use BackupStatus.ProtoReflect.Descriptor instead.
-func (*BackupStatus) Descriptor() ([]byte, []int) {
-	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{5}
+// Deprecated: Use SearchFilesRequest.ProtoReflect.Descriptor instead.
+func (*SearchFilesRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{33}
 }
 
-func (x *BackupStatus) GetBackupJobId() string {
+func (x *SearchFilesRequest) GetClientId() string {
 	if x != nil {
-		return x.BackupJobId
+		return x.ClientId
 	}
 	return ""
 }
 
-func (x *BackupStatus) GetCurrentFile() string {
+func (x *SearchFilesRequest) GetPattern() string {
 	if x != nil {
-		return x.CurrentFile
+		return x.Pattern
 	}
 	return ""
 }
 
-func (x *BackupStatus) GetBytesProcessed() uint64 {
+func (x *SearchFilesRequest) GetGlob() bool {
 	if x != nil {
-		return x.BytesProcessed
+		return x.Glob
+	}
+	return false
+}
+
+func (x *SearchFilesRequest) GetStartedAfter() int64 {
+	if x != nil {
+		return x.StartedAfter
 	}
 	return 0
 }
 
-func (x *BackupStatus) GetBytesDeduplicated() uint64 {
+func (x *SearchFilesRequest) GetStartedBefore() int64 {
 	if x != nil {
-		return x.BytesDeduplicated
+		return x.StartedBefore
 	}
 	return 0
 }
 
-func (x *BackupStatus) GetMessage() string {
+func (x *SearchFilesRequest) GetPageSize() uint32 {
 	if x != nil {
-		return x.Message
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *SearchFilesRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
 	}
 	return ""
 }
 
-// Error message from server to stream handler
-type BackupError struct {
+type SearchFilesResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	BackupJobId   string                 `protobuf:"bytes,1,opt,name=backup_job_id,json=backupJobId,proto3" json:"backup_job_id,omitempty"`
-	ErrorCode     string                 `protobuf:"bytes,2,opt,name=error_code,json=errorCode,proto3" json:"error_code,omitempty"`
-	ErrorMessage  string                 `protobuf:"bytes,3,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
+	Matches       []*FileMatch           `protobuf:"bytes,1,rep,name=matches,proto3" json:"matches,omitempty"`                                    // Ordered by path
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"` // Empty on the last page
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *BackupError) Reset() {
-	*x = BackupError{}
-	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[6]
+func (x *SearchFilesResponse) Reset() {
+	*x = SearchFilesResponse{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[34]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *BackupError) String() string {
+func (x *SearchFilesResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*BackupError) ProtoMessage() {}
+func (*SearchFilesResponse) ProtoMessage() {}
 
-func (x *BackupError) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[6]
+func (x *SearchFilesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[34]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -554,57 +3002,52 @@ func (x *BackupError) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use BackupError.ProtoReflect.Descriptor instead.
-func (*BackupError) Descriptor() ([]byte, []int) {
-	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{6}
-}
-
-func (x *BackupError) GetBackupJobId() string {
-	if x != nil {
-		return x.BackupJobId
-	}
-	return ""
+// Deprecated: Use SearchFilesResponse.ProtoReflect.Descriptor instead.
+func (*SearchFilesResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{34}
 }
 
-func (x *BackupError) GetErrorCode() string {
+func (x *SearchFilesResponse) GetMatches() []*FileMatch {
 	if x != nil {
-		return x.ErrorCode
+		return x.Matches
 	}
-	return ""
+	return nil
 }
 
-func (x *BackupError) GetErrorMessage() string {
+func (x *SearchFilesResponse) GetNextPageToken() string {
 	if x != nil {
-		return x.ErrorMessage
+		return x.NextPageToken
 	}
 	return ""
 }
 
-type RestoreRequest struct {
-	state                  protoimpl.MessageState `protogen:"open.v1"`
-	ClientId               string                 `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
-	BackupJobId            string                 `protobuf:"bytes,2,opt,name=backup_job_id,json=backupJobId,proto3" json:"backup_job_id,omitempty"`                                  // The backup job to restore from
-	FilesToRestore         []string               `protobuf:"bytes,3,rep,name=files_to_restore,json=filesToRestore,proto3" json:"files_to_restore,omitempty"`                         // List of specific files/paths to restore
-	RestoreDestinationPath string                 `protobuf:"bytes,4,opt,name=restore_destination_path,json=restoreDestinationPath,proto3" json:"restore_destination_path,omitempty"` // Path on client/source to restore to
-	unknownFields          protoimpl.UnknownFields
-	sizeCache              protoimpl.SizeCache
+// A path found by SearchFiles and the jobs that backed it up
+type FileMatch struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	FilePath          string                 `protobuf:"bytes,1,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`
+	Backups           uint32                 `protobuf:"varint,2,opt,name=backups,proto3" json:"backups,omitempty"`                      // jobs that recorded the path
+	FirstSeen         int64                  `protobuf:"varint,3,opt,name=first_seen,json=firstSeen,proto3" json:"first_seen,omitempty"` // Unix start time of the first of them
+	LastSeen          int64                  `protobuf:"varint,4,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`    // Unix start time of the last of them
+	LatestBackupJobId string                 `protobuf:"bytes,5,opt,name=latest_backup_job_id,json=latestBackupJobId,proto3" json:"latest_backup_job_id,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
 }
 
-func (x *RestoreRequest) Reset() {
-	*x = RestoreRequest{}
-	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[7]
+func (x *FileMatch) Reset() {
+	*x = FileMatch{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[35]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *RestoreRequest) String() string {
+func (x *FileMatch) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*RestoreRequest) ProtoMessage() {}
+func (*FileMatch) ProtoMessage() {}
 
-func (x *RestoreRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[7]
+func (x *FileMatch) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[35]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -615,63 +3058,71 @@ func (x *RestoreRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use RestoreRequest.ProtoReflect.Descriptor instead.
-func (*RestoreRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{7}
+// Deprecated: Use FileMatch.ProtoReflect.Descriptor instead.
+func (*FileMatch) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{35}
 }
 
-func (x *RestoreRequest) GetClientId() string {
+func (x *FileMatch) GetFilePath() string {
 	if x != nil {
-		return x.ClientId
+		return x.FilePath
 	}
 	return ""
 }
 
-func (x *RestoreRequest) GetBackupJobId() string {
+func (x *FileMatch) GetBackups() uint32 {
 	if x != nil {
-		return x.BackupJobId
+		return x.Backups
 	}
-	return ""
+	return 0
 }
 
-func (x *RestoreRequest) GetFilesToRestore() []string {
+func (x *FileMatch) GetFirstSeen() int64 {
 	if x != nil {
-		return x.FilesToRestore
+		return x.FirstSeen
 	}
-	return nil
+	return 0
 }
 
-func (x *RestoreRequest) GetRestoreDestinationPath() string {
+func (x *FileMatch) GetLastSeen() int64 {
 	if x != nil {
-		return x.RestoreDestinationPath
+		return x.LastSeen
+	}
+	return 0
+}
+
+func (x *FileMatch) GetLatestBackupJobId() string {
+	if x != nil {
+		return x.LatestBackupJobId
 	}
 	return ""
 }
 
-type RestoreResponse struct {
+type ListVersionsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	RestoreJobId  string                 `protobuf:"bytes,1,opt,name=restore_job_id,json=restoreJobId,proto3" json:"restore_job_id,omitempty"` // Unique ID for the restore operation
-	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`                                   // e.g., "INITIATED", "FAILED"
-	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	ClientId      string                 `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	FilePath      string                 `protobuf:"bytes,2,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`
+	StartedAfter  int64                  `protobuf:"varint,3,opt,name=started_after,json=startedAfter,proto3" json:"started_after,omitempty"`    // Only jobs started at or after this Unix time; 0 means no bound
+	StartedBefore int64                  `protobuf:"varint,4,opt,name=started_before,json=startedBefore,proto3" json:"started_before,omitempty"` // Only jobs started before this Unix time; 0 means no bound
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *RestoreResponse) Reset() {
-	*x = RestoreResponse{}
-	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[8]
+func (x *ListVersionsRequest) Reset() {
+	*x = ListVersionsRequest{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[36]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *RestoreResponse) String() string {
+func (x *ListVersionsRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*RestoreResponse) ProtoMessage() {}
+func (*ListVersionsRequest) ProtoMessage() {}
 
-func (x *RestoreResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[8]
+func (x *ListVersionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[36]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -682,54 +3133,61 @@ func (x *RestoreResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use RestoreResponse.ProtoReflect.Descriptor instead.
-func (*RestoreResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{8}
+// Deprecated: Use ListVersionsRequest.ProtoReflect.Descriptor instead.
+func (*ListVersionsRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{36}
 }
 
-func (x *RestoreResponse) GetRestoreJobId() string {
+func (x *ListVersionsRequest) GetClientId() string {
 	if x != nil {
-		return x.RestoreJobId
+		return x.ClientId
 	}
 	return ""
 }
 
-func (x *RestoreResponse) GetStatus() string {
+func (x *ListVersionsRequest) GetFilePath() string {
 	if x != nil {
-		return x.Status
+		return x.FilePath
 	}
 	return ""
 }
 
-func (x *RestoreResponse) GetMessage() string {
+func (x *ListVersionsRequest) GetStartedAfter() int64 {
 	if x != nil {
-		return x.Message
+		return x.StartedAfter
 	}
-	return ""
+	return 0
 }
 
-type RestoreDataRequest struct {
+func (x *ListVersionsRequest) GetStartedBefore() int64 {
+	if x != nil {
+		return x.StartedBefore
+	}
+	return 0
+}
+
+type ListVersionsResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	RestoreJobId  string                 `protobuf:"bytes,1,opt,name=restore_job_id,json=restoreJobId,proto3" json:"restore_job_id,omitempty"` // No specific data needed from stream handler during stream, it's mostly for flow control
+	Versions      []*FileVersion         `protobuf:"bytes,1,rep,name=versions,proto3" json:"versions,omitempty"` // Newest first
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *RestoreDataRequest) Reset() {
-	*x = RestoreDataRequest{}
-	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[9]
+func (x *ListVersionsResponse) Reset() {
+	*x = ListVersionsResponse{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[37]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *RestoreDataRequest) String() string {
+func (x *ListVersionsResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*RestoreDataRequest) ProtoMessage() {}
+func (*ListVersionsResponse) ProtoMessage() {}
 
-func (x *RestoreDataRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[9]
+func (x *ListVersionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[37]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -740,44 +3198,48 @@ func (x *RestoreDataRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use RestoreDataRequest.ProtoReflect.Descriptor instead.
-func (*RestoreDataRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{9}
+// Deprecated: Use ListVersionsResponse.ProtoReflect.Descriptor instead.
+func (*ListVersionsResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{37}
 }
 
-func (x *RestoreDataRequest) GetRestoreJobId() string {
+func (x *ListVersionsResponse) GetVersions() []*FileVersion {
 	if x != nil {
-		return x.RestoreJobId
+		return x.Versions
 	}
-	return ""
-}
-
-type RestoreDataResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	RestoreJobId  string                 `protobuf:"bytes,1,opt,name=restore_job_id,json=restoreJobId,proto3" json:"restore_job_id,omitempty"`
-	FilePath      string                 `protobuf:"bytes,2,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`                   // Original full path of the file/object
-	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`                                           // Raw data segment of the file/object
-	Offset        uint64                 `protobuf:"varint,4,opt,name=offset,proto3" json:"offset,omitempty"`                                      // Offset of this segment within the file/object
-	IsLastSegment bool                   `protobuf:"varint,5,opt,name=is_last_segment,json=isLastSegment,proto3" json:"is_last_segment,omitempty"` // True if this is the last segment for the file/object
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	return nil
 }
 
-func (x *RestoreDataResponse) Reset() {
-	*x = RestoreDataResponse{}
-	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[10]
+// A version of a path: what consecutive jobs recorded with the same content.
+// Jobs that recorded the same content with a new mtime or owner share the
+// version.
+type FileVersion struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Entry            *FileEntry             `protobuf:"bytes,1,opt,name=entry,proto3" json:"entry,omitempty"`                                                   // As the latest job recorded it; content_hash is set for regular files
+	BackupJobId      string                 `protobuf:"bytes,2,opt,name=backup_job_id,json=backupJobId,proto3" json:"backup_job_id,omitempty"`                  // latest job that recorded this version, to restore it from
+	BackupTime       int64                  `protobuf:"varint,3,opt,name=backup_time,json=backupTime,proto3" json:"backup_time,omitempty"`                      // Unix start time of that job
+	FirstBackupJobId string                 `protobuf:"bytes,4,opt,name=first_backup_job_id,json=firstBackupJobId,proto3" json:"first_backup_job_id,omitempty"` // first job that recorded this version
+	FirstBackupTime  int64                  `protobuf:"varint,5,opt,name=first_backup_time,json=firstBackupTime,proto3" json:"first_backup_time,omitempty"`
+	Backups          uint32                 `protobuf:"varint,6,opt,name=backups,proto3" json:"backups,omitempty"` // jobs that recorded this version
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *FileVersion) Reset() {
+	*x = FileVersion{}
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[38]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *RestoreDataResponse) String() string {
+func (x *FileVersion) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*RestoreDataResponse) ProtoMessage() {}
+func (*FileVersion) ProtoMessage() {}
 
-func (x *RestoreDataResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[10]
+func (x *FileVersion) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_dedupe_engine_proto_msgTypes[38]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -788,51 +3250,58 @@ func (x *RestoreDataResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use RestoreDataResponse.ProtoReflect.Descriptor instead.
-func (*RestoreDataResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{10}
+// Deprecated: Use FileVersion.ProtoReflect.Descriptor instead.
+func (*FileVersion) Descriptor() ([]byte, []int) {
+	return file_pkg_api_dedupe_engine_proto_rawDescGZIP(), []int{38}
 }
 
-func (x *RestoreDataResponse) GetRestoreJobId() string {
+func (x *FileVersion) GetEntry() *FileEntry {
 	if x != nil {
-		return x.RestoreJobId
+		return x.Entry
 	}
-	return ""
+	return nil
 }
 
-func (x *RestoreDataResponse) GetFilePath() string {
+func (x *FileVersion) GetBackupJobId() string {
 	if x != nil {
-		return x.FilePath
+		return x.BackupJobId
 	}
 	return ""
 }
 
-func (x *RestoreDataResponse) GetData() []byte {
+func (x *FileVersion) GetBackupTime() int64 {
 	if x != nil {
-		return x.Data
+		return x.BackupTime
 	}
-	return nil
+	return 0
 }
 
-func (x *RestoreDataResponse) GetOffset() uint64 {
+func (x *FileVersion) GetFirstBackupJobId() string {
 	if x != nil {
-		return x.Offset
+		return x.FirstBackupJobId
+	}
+	return ""
+}
+
+func (x *FileVersion) GetFirstBackupTime() int64 {
+	if x != nil {
+		return x.FirstBackupTime
 	}
 	return 0
 }
 
-func (x *RestoreDataResponse) GetIsLastSegment() bool {
+func (x *FileVersion) GetBackups() uint32 {
 	if x != nil {
-		return x.IsLastSegment
+		return x.Backups
 	}
-	return false
+	return 0
 }
 
 var File_pkg_api_dedupe_engine_proto protoreflect.FileDescriptor
 
 const file_pkg_api_dedupe_engine_proto_rawDesc = "" +
 	"\n" +
-	"\x1bpkg/api/dedupe_engine.proto\x12\rdedupe_engine\"\x8a\x02\n" +
+	"\x1bpkg/api/dedupe_engine.proto\x12\rdedupe_engine\"\xf2\x02\n" +
 	"\vBackupStart\x12\x1b\n" +
 	"\tclient_id\x18\x01 \x01(\tR\bclientId\x12\"\n" +
 	"\rbackup_job_id\x18\x02 \x01(\tR\vbackupJobId\x12(\n" +
@@ -841,60 +3310,290 @@ const file_pkg_api_dedupe_engine_proto_rawDesc = "" +
 	"\ttimestamp\x18\x05 \x01(\x03R\ttimestamp\x12\x1f\n" +
 	"\vsource_type\x18\x06 \x01(\tR\n" +
 	"sourceType\x12%\n" +
-	"\x0esource_details\x18\a \x01(\tR\rsourceDetails\"\xb8\x01\n" +
+	"\x0esource_details\x18\a \x01(\tR\rsourceDetails\x12\x1e\n" +
+	"\vbase_job_id\x18\b \x01(\tR\tbaseJobId\x12\x16\n" +
+	"\x06resume\x18\t \x01(\bR\x06resume\x12\x12\n" +
+	"\x04join\x18\n" +
+	" \x01(\bR\x04join\x12\x1a\n" +
+	"\barchives\x18\v \x01(\bR\barchives\"\xf8\x01\n" +
 	"\vFileSegment\x12\x1b\n" +
 	"\tfile_path\x18\x01 \x01(\tR\bfilePath\x12\x1b\n" +
 	"\tfile_size\x18\x02 \x01(\x04R\bfileSize\x12\x12\n" +
 	"\x04data\x18\x03 \x01(\fR\x04data\x12\x16\n" +
 	"\x06offset\x18\x04 \x01(\x04R\x06offset\x12&\n" +
 	"\x0fis_last_segment\x18\x05 \x01(\bR\risLastSegment\x12\x1b\n" +
-	"\tfile_hash\x18\x06 \x01(\tR\bfileHash\"\xdc\x01\n" +
+	"\tfile_hash\x18\x06 \x01(\tR\bfileHash\x12\x1b\n" +
+	"\thole_size\x18\a \x01(\x04R\bholeSize\x12!\n" +
+	"\fsize_unknown\x18\b \x01(\bR\vsizeUnknown\"=\n" +
+	"\x11ExtendedAttribute\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\fR\x05value\"\xc6\x03\n" +
+	"\tFileEntry\x12\x1b\n" +
+	"\tfile_path\x18\x01 \x01(\tR\bfilePath\x12+\n" +
+	"\x04type\x18\x02 \x01(\x0e2\x17.dedupe_engine.FileTypeR\x04type\x12\x12\n" +
+	"\x04mode\x18\x03 \x01(\rR\x04mode\x12\x10\n" +
+	"\x03uid\x18\x04 \x01(\rR\x03uid\x12\x10\n" +
+	"\x03gid\x18\x05 \x01(\rR\x03gid\x12\x14\n" +
+	"\x05owner\x18\x06 \x01(\tR\x05owner\x12\x14\n" +
+	"\x05group\x18\a \x01(\tR\x05group\x12\x19\n" +
+	"\bmtime_ns\x18\b \x01(\x03R\amtimeNs\x12\x19\n" +
+	"\batime_ns\x18\t \x01(\x03R\aatimeNs\x12\x12\n" +
+	"\x04size\x18\n" +
+	" \x01(\x04R\x04size\x12\x1f\n" +
+	"\vlink_target\x18\v \x01(\tR\n" +
+	"linkTarget\x128\n" +
+	"\x06xattrs\x18\f \x03(\v2 .dedupe_engine.ExtendedAttributeR\x06xattrs\x12\x12\n" +
+	"\x04rdev\x18\r \x01(\x04R\x04rdev\x12\x14\n" +
+	"\x05inode\x18\x0e \x01(\x04R\x05inode\x12\x19\n" +
+	"\bctime_ns\x18\x0f \x01(\x03R\actimeNs\x12!\n" +
+	"\fcontent_hash\x18\x10 \x01(\tR\vcontentHash\"@\n" +
+	"\bChunkRef\x12 \n" +
+	"\vfingerprint\x18\x01 \x01(\fR\vfingerprint\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x04R\x04size\"\x81\x01\n" +
+	"\rChunkRefBatch\x12\x1b\n" +
+	"\tfile_path\x18\x01 \x01(\tR\bfilePath\x12/\n" +
+	"\x06chunks\x18\x02 \x03(\v2\x17.dedupe_engine.ChunkRefR\x06chunks\x12\"\n" +
+	"\ris_last_batch\x18\x03 \x01(\bR\visLastBatch\"^\n" +
+	"\tChunkData\x12\x1b\n" +
+	"\tfile_path\x18\x01 \x01(\tR\bfilePath\x12 \n" +
+	"\vfingerprint\x18\x02 \x01(\fR\vfingerprint\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\"\x93\x04\n" +
 	"\rBackupRequest\x12?\n" +
 	"\fstart_backup\x18\x01 \x01(\v2\x1a.dedupe_engine.BackupStartH\x00R\vstartBackup\x12?\n" +
 	"\ffile_segment\x18\x02 \x01(\v2\x1a.dedupe_engine.FileSegmentH\x00R\vfileSegment\x129\n" +
 	"\n" +
-	"end_backup\x18\x03 \x01(\v2\x18.dedupe_engine.BackupEndH\x00R\tendBackupB\x0e\n" +
-	"\frequest_type\"a\n" +
+	"end_backup\x18\x03 \x01(\v2\x18.dedupe_engine.BackupEndH\x00R\tendBackup\x129\n" +
+	"\n" +
+	"file_entry\x18\x04 \x01(\v2\x18.dedupe_engine.FileEntryH\x00R\tfileEntry\x12=\n" +
+	"\n" +
+	"chunk_refs\x18\x05 \x01(\v2\x1c.dedupe_engine.ChunkRefBatchH\x00R\tchunkRefs\x129\n" +
+	"\n" +
+	"chunk_data\x18\x06 \x01(\v2\x18.dedupe_engine.ChunkDataH\x00R\tchunkData\x12E\n" +
+	"\x0eunchanged_file\x18\a \x01(\v2\x1c.dedupe_engine.UnchangedFileH\x00R\runchangedFile\x129\n" +
+	"\n" +
+	"file_range\x18\b \x01(\v2\x18.dedupe_engine.FileRangeH\x00R\tfileRangeB\x0e\n" +
+	"\frequest_type\"?\n" +
+	"\rUnchangedFile\x12.\n" +
+	"\x05entry\x18\x01 \x01(\v2\x18.dedupe_engine.FileEntryR\x05entry\"\x97\x01\n" +
+	"\tFileRange\x12.\n" +
+	"\x05entry\x18\x01 \x01(\v2\x18.dedupe_engine.FileEntryR\x05entry\x12\x14\n" +
+	"\x05index\x18\x02 \x01(\rR\x05index\x12\x14\n" +
+	"\x05count\x18\x03 \x01(\rR\x05count\x12\x16\n" +
+	"\x06offset\x18\x04 \x01(\x04R\x06offset\x12\x16\n" +
+	"\x06length\x18\x05 \x01(\x04R\x06length\"\x90\x01\n" +
 	"\tBackupEnd\x12\"\n" +
 	"\rbackup_job_id\x18\x01 \x01(\tR\vbackupJobId\x12\x16\n" +
 	"\x06status\x18\x02 \x01(\tR\x06status\x12\x18\n" +
-	"\asummary\x18\x03 \x01(\tR\asummary\"\xa8\x01\n" +
+	"\asummary\x18\x03 \x01(\tR\asummary\x12-\n" +
+	"\x05state\x18\x04 \x01(\x0e2\x17.dedupe_engine.JobStateR\x05state\"\xf4\x02\n" +
 	"\x0eBackupResponse\x12B\n" +
 	"\rstatus_update\x18\x01 \x01(\v2\x1b.dedupe_engine.BackupStatusH\x00R\fstatusUpdate\x12A\n" +
-	"\rerror_message\x18\x02 \x01(\v2\x1a.dedupe_engine.BackupErrorH\x00R\ferrorMessageB\x0f\n" +
-	"\rresponse_type\"\xc7\x01\n" +
+	"\rerror_message\x18\x02 \x01(\v2\x1a.dedupe_engine.BackupErrorH\x00R\ferrorMessage\x12E\n" +
+	"\x0emissing_chunks\x18\x03 \x01(\v2\x1c.dedupe_engine.MissingChunksH\x00R\rmissingChunks\x12?\n" +
+	"\fresume_point\x18\x04 \x01(\v2\x1a.dedupe_engine.ResumePointH\x00R\vresumePoint\x12B\n" +
+	"\rchunks_stored\x18\x05 \x01(\v2\x1b.dedupe_engine.ChunksStoredH\x00R\fchunksStoredB\x0f\n" +
+	"\rresponse_type\"n\n" +
+	"\fChunksStored\x12\x1b\n" +
+	"\tfile_path\x18\x01 \x01(\tR\bfilePath\x12\"\n" +
+	"\ffingerprints\x18\x02 \x03(\fR\ffingerprints\x12\x1d\n" +
+	"\n" +
+	"new_chunks\x18\x03 \x01(\rR\tnewChunks\"\xd7\x01\n" +
+	"\vResumePoint\x12\"\n" +
+	"\rbackup_job_id\x18\x01 \x01(\tR\vbackupJobId\x12\x1b\n" +
+	"\tlast_file\x18\x02 \x01(\tR\blastFile\x12!\n" +
+	"\fpartial_file\x18\x03 \x01(\tR\vpartialFile\x12%\n" +
+	"\x0epartial_offset\x18\x04 \x01(\x04R\rpartialOffset\x12=\n" +
+	"\rpartial_entry\x18\x05 \x01(\v2\x18.dedupe_engine.FileEntryR\fpartialEntry\"P\n" +
+	"\rMissingChunks\x12\x1b\n" +
+	"\tfile_path\x18\x01 \x01(\tR\bfilePath\x12\"\n" +
+	"\ffingerprints\x18\x02 \x03(\fR\ffingerprints\"\xe7\x03\n" +
 	"\fBackupStatus\x12\"\n" +
 	"\rbackup_job_id\x18\x01 \x01(\tR\vbackupJobId\x12!\n" +
 	"\fcurrent_file\x18\x02 \x01(\tR\vcurrentFile\x12'\n" +
 	"\x0fbytes_processed\x18\x03 \x01(\x04R\x0ebytesProcessed\x12-\n" +
 	"\x12bytes_deduplicated\x18\x04 \x01(\x04R\x11bytesDeduplicated\x12\x18\n" +
-	"\amessage\x18\x05 \x01(\tR\amessage\"u\n" +
+	"\amessage\x18\x05 \x01(\tR\amessage\x12*\n" +
+	"\x11bytes_delta_saved\x18\x06 \x01(\x04R\x0fbytesDeltaSaved\x123\n" +
+	"\x15fingerprint_algorithm\x18\a \x01(\tR\x14fingerprintAlgorithm\x12I\n" +
+	"\x10chunking_profile\x18\b \x01(\v2\x1e.dedupe_engine.ChunkingProfileR\x0fchunkingProfile\x12-\n" +
+	"\x05state\x18\t \x01(\x0e2\x17.dedupe_engine.JobStateR\x05state\x12C\n" +
+	"\x0efailure_reason\x18\n" +
+	" \x01(\x0e2\x1c.dedupe_engine.FailureReasonR\rfailureReason\"\xe1\x01\n" +
+	"\x0fChunkingProfile\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\rR\aversion\x12\x1c\n" +
+	"\talgorithm\x18\x02 \x01(\tR\talgorithm\x12\x19\n" +
+	"\bmin_size\x18\x03 \x01(\rR\aminSize\x12\x19\n" +
+	"\bavg_size\x18\x04 \x01(\rR\aavgSize\x12\x19\n" +
+	"\bmax_size\x18\x05 \x01(\rR\amaxSize\x12\x1f\n" +
+	"\vwindow_size\x18\x06 \x01(\rR\n" +
+	"windowSize\x12\x1e\n" +
+	"\n" +
+	"polynomial\x18\a \x01(\x04R\n" +
+	"polynomialJ\x04\b\b\x10\t\"u\n" +
 	"\vBackupError\x12\"\n" +
 	"\rbackup_job_id\x18\x01 \x01(\tR\vbackupJobId\x12\x1d\n" +
 	"\n" +
 	"error_code\x18\x02 \x01(\tR\terrorCode\x12#\n" +
-	"\rerror_message\x18\x03 \x01(\tR\ferrorMessage\"\xb5\x01\n" +
+	"\rerror_message\x18\x03 \x01(\tR\ferrorMessage\"~\n" +
+	"\x17PreviousSnapshotRequest\x12\x1b\n" +
+	"\tclient_id\x18\x01 \x01(\tR\bclientId\x12\x1f\n" +
+	"\vsource_type\x18\x02 \x01(\tR\n" +
+	"sourceType\x12%\n" +
+	"\x0esource_details\x18\x03 \x01(\tR\rsourceDetails\"n\n" +
+	"\x18PreviousSnapshotResponse\x12\"\n" +
+	"\rbackup_job_id\x18\x01 \x01(\tR\vbackupJobId\x12.\n" +
+	"\x05files\x18\x02 \x03(\v2\x18.dedupe_engine.FileEntryR\x05files\"\xcf\x01\n" +
 	"\x0eRestoreRequest\x12\x1b\n" +
 	"\tclient_id\x18\x01 \x01(\tR\bclientId\x12\"\n" +
 	"\rbackup_job_id\x18\x02 \x01(\tR\vbackupJobId\x12(\n" +
 	"\x10files_to_restore\x18\x03 \x03(\tR\x0efilesToRestore\x128\n" +
-	"\x18restore_destination_path\x18\x04 \x01(\tR\x16restoreDestinationPath\"i\n" +
+	"\x18restore_destination_path\x18\x04 \x01(\tR\x16restoreDestinationPath\x12\x18\n" +
+	"\astreams\x18\x05 \x01(\rR\astreams\"\x9e\x01\n" +
 	"\x0fRestoreResponse\x12$\n" +
 	"\x0erestore_job_id\x18\x01 \x01(\tR\frestoreJobId\x12\x16\n" +
 	"\x06status\x18\x02 \x01(\tR\x06status\x12\x18\n" +
-	"\amessage\x18\x03 \x01(\tR\amessage\":\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\x123\n" +
+	"\x15fingerprint_algorithm\x18\x04 \x01(\tR\x14fingerprintAlgorithm\"z\n" +
 	"\x12RestoreDataRequest\x12$\n" +
-	"\x0erestore_job_id\x18\x01 \x01(\tR\frestoreJobId\"\xac\x01\n" +
+	"\x0erestore_job_id\x18\x01 \x01(\tR\frestoreJobId\x12!\n" +
+	"\fstream_index\x18\x02 \x01(\rR\vstreamIndex\x12\x1b\n" +
+	"\tclient_id\x18\x03 \x01(\tR\bclientId\"\x82\x02\n" +
 	"\x13RestoreDataResponse\x12$\n" +
 	"\x0erestore_job_id\x18\x01 \x01(\tR\frestoreJobId\x12\x1b\n" +
 	"\tfile_path\x18\x02 \x01(\tR\bfilePath\x12\x12\n" +
 	"\x04data\x18\x03 \x01(\fR\x04data\x12\x16\n" +
 	"\x06offset\x18\x04 \x01(\x04R\x06offset\x12&\n" +
-	"\x0fis_last_segment\x18\x05 \x01(\bR\risLastSegment2\x92\x02\n" +
+	"\x0fis_last_segment\x18\x05 \x01(\bR\risLastSegment\x127\n" +
+	"\n" +
+	"file_entry\x18\x06 \x01(\v2\x18.dedupe_engine.FileEntryR\tfileEntry\x12\x1b\n" +
+	"\thole_size\x18\a \x01(\x04R\bholeSize\"\x91\x02\n" +
+	"\x0fListJobsRequest\x12\x1b\n" +
+	"\tclient_id\x18\x01 \x01(\tR\bclientId\x12(\n" +
+	"\x10backup_policy_id\x18\x02 \x01(\tR\x0ebackupPolicyId\x12/\n" +
+	"\x06states\x18\x03 \x03(\x0e2\x17.dedupe_engine.JobStateR\x06states\x12#\n" +
+	"\rstarted_after\x18\x04 \x01(\x03R\fstartedAfter\x12%\n" +
+	"\x0estarted_before\x18\x05 \x01(\x03R\rstartedBefore\x12\x1b\n" +
+	"\tpage_size\x18\x06 \x01(\rR\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\a \x01(\tR\tpageToken\"f\n" +
+	"\x10ListJobsResponse\x12*\n" +
+	"\x04jobs\x18\x01 \x03(\v2\x16.dedupe_engine.JobInfoR\x04jobs\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"P\n" +
+	"\rGetJobRequest\x12\"\n" +
+	"\rbackup_job_id\x18\x01 \x01(\tR\vbackupJobId\x12\x1b\n" +
+	"\tclient_id\x18\x02 \x01(\tR\bclientId\"\x99\x03\n" +
+	"\aJobInfo\x12\"\n" +
+	"\rbackup_job_id\x18\x01 \x01(\tR\vbackupJobId\x12\x1b\n" +
+	"\tclient_id\x18\x02 \x01(\tR\bclientId\x12(\n" +
+	"\x10backup_policy_id\x18\x03 \x01(\tR\x0ebackupPolicyId\x12\x1f\n" +
+	"\vsource_type\x18\x04 \x01(\tR\n" +
+	"sourceType\x12%\n" +
+	"\x0esource_details\x18\x05 \x01(\tR\rsourceDetails\x12\x1d\n" +
+	"\n" +
+	"start_time\x18\x06 \x01(\x03R\tstartTime\x12\x19\n" +
+	"\bend_time\x18\a \x01(\x03R\aendTime\x12-\n" +
+	"\x05state\x18\b \x01(\x0e2\x17.dedupe_engine.JobStateR\x05state\x12C\n" +
+	"\x0efailure_reason\x18\t \x01(\x0e2\x1c.dedupe_engine.FailureReasonR\rfailureReason\x12-\n" +
+	"\x05stats\x18\n" +
+	" \x01(\v2\x17.dedupe_engine.JobStatsR\x05stats\"\xad\x03\n" +
+	"\bJobStats\x12'\n" +
+	"\x0ffiles_processed\x18\x01 \x01(\x04R\x0efilesProcessed\x12'\n" +
+	"\x0ffiles_unchanged\x18\x02 \x01(\x04R\x0efilesUnchanged\x12+\n" +
+	"\x11entries_processed\x18\x03 \x01(\x04R\x10entriesProcessed\x12)\n" +
+	"\x10chunks_processed\x18\x04 \x01(\x04R\x0fchunksProcessed\x12'\n" +
+	"\x0fbytes_processed\x18\x05 \x01(\x04R\x0ebytesProcessed\x12-\n" +
+	"\x12bytes_deduplicated\x18\x06 \x01(\x04R\x11bytesDeduplicated\x12!\n" +
+	"\fchunks_delta\x18\a \x01(\x04R\vchunksDelta\x12*\n" +
+	"\x11bytes_delta_saved\x18\b \x01(\x04R\x0fbytesDeltaSaved\x12)\n" +
+	"\x10manifest_entries\x18\t \x01(\x04R\x0fmanifestEntries\x12%\n" +
+	"\x0emanifest_bytes\x18\n" +
+	" \x01(\x04R\rmanifestBytes\"\xcc\x01\n" +
+	"\x10ListFilesRequest\x12\"\n" +
+	"\rbackup_job_id\x18\x01 \x01(\tR\vbackupJobId\x12\x16\n" +
+	"\x06prefix\x18\x02 \x01(\tR\x06prefix\x12#\n" +
+	"\rchildren_only\x18\x03 \x01(\bR\fchildrenOnly\x12\x1b\n" +
+	"\tpage_size\x18\x04 \x01(\rR\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x05 \x01(\tR\tpageToken\x12\x1b\n" +
+	"\tclient_id\x18\x06 \x01(\tR\bclientId\"k\n" +
+	"\x11ListFilesResponse\x12.\n" +
+	"\x05files\x18\x01 \x03(\v2\x18.dedupe_engine.FileEntryR\x05files\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"S\n" +
+	"\x10DeleteJobRequest\x12\x1b\n" +
+	"\tclient_id\x18\x01 \x01(\tR\bclientId\x12\"\n" +
+	"\rbackup_job_id\x18\x02 \x01(\tR\vbackupJobId\"<\n" +
+	"\x11DeleteJobResponse\x12'\n" +
+	"\x0fentries_deleted\x18\x01 \x01(\x04R\x0eentriesDeleted\"\xe7\x01\n" +
+	"\x12SearchFilesRequest\x12\x1b\n" +
+	"\tclient_id\x18\x01 \x01(\tR\bclientId\x12\x18\n" +
+	"\apattern\x18\x02 \x01(\tR\apattern\x12\x12\n" +
+	"\x04glob\x18\x03 \x01(\bR\x04glob\x12#\n" +
+	"\rstarted_after\x18\x04 \x01(\x03R\fstartedAfter\x12%\n" +
+	"\x0estarted_before\x18\x05 \x01(\x03R\rstartedBefore\x12\x1b\n" +
+	"\tpage_size\x18\x06 \x01(\rR\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\a \x01(\tR\tpageToken\"q\n" +
+	"\x13SearchFilesResponse\x122\n" +
+	"\amatches\x18\x01 \x03(\v2\x18.dedupe_engine.FileMatchR\amatches\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"\xaf\x01\n" +
+	"\tFileMatch\x12\x1b\n" +
+	"\tfile_path\x18\x01 \x01(\tR\bfilePath\x12\x18\n" +
+	"\abackups\x18\x02 \x01(\rR\abackups\x12\x1d\n" +
+	"\n" +
+	"first_seen\x18\x03 \x01(\x03R\tfirstSeen\x12\x1b\n" +
+	"\tlast_seen\x18\x04 \x01(\x03R\blastSeen\x12/\n" +
+	"\x14latest_backup_job_id\x18\x05 \x01(\tR\x11latestBackupJobId\"\x9b\x01\n" +
+	"\x13ListVersionsRequest\x12\x1b\n" +
+	"\tclient_id\x18\x01 \x01(\tR\bclientId\x12\x1b\n" +
+	"\tfile_path\x18\x02 \x01(\tR\bfilePath\x12#\n" +
+	"\rstarted_after\x18\x03 \x01(\x03R\fstartedAfter\x12%\n" +
+	"\x0estarted_before\x18\x04 \x01(\x03R\rstartedBefore\"N\n" +
+	"\x14ListVersionsResponse\x126\n" +
+	"\bversions\x18\x01 \x03(\v2\x1a.dedupe_engine.FileVersionR\bversions\"\xf7\x01\n" +
+	"\vFileVersion\x12.\n" +
+	"\x05entry\x18\x01 \x01(\v2\x18.dedupe_engine.FileEntryR\x05entry\x12\"\n" +
+	"\rbackup_job_id\x18\x02 \x01(\tR\vbackupJobId\x12\x1f\n" +
+	"\vbackup_time\x18\x03 \x01(\x03R\n" +
+	"backupTime\x12-\n" +
+	"\x13first_backup_job_id\x18\x04 \x01(\tR\x10firstBackupJobId\x12*\n" +
+	"\x11first_backup_time\x18\x05 \x01(\x03R\x0ffirstBackupTime\x12\x18\n" +
+	"\abackups\x18\x06 \x01(\rR\abackups*\xcf\x01\n" +
+	"\bFileType\x12\x19\n" +
+	"\x15FILE_TYPE_UNSPECIFIED\x10\x00\x12\x15\n" +
+	"\x11FILE_TYPE_REGULAR\x10\x01\x12\x17\n" +
+	"\x13FILE_TYPE_DIRECTORY\x10\x02\x12\x15\n" +
+	"\x11FILE_TYPE_SYMLINK\x10\x03\x12\x16\n" +
+	"\x12FILE_TYPE_HARDLINK\x10\x04\x12\x12\n" +
+	"\x0eFILE_TYPE_FIFO\x10\x05\x12\x19\n" +
+	"\x15FILE_TYPE_CHAR_DEVICE\x10\x06\x12\x1a\n" +
+	"\x16FILE_TYPE_BLOCK_DEVICE\x10\a*\xcc\x01\n" +
+	"\bJobState\x12\x19\n" +
+	"\x15JOB_STATE_UNSPECIFIED\x10\x00\x12\x15\n" +
+	"\x11JOB_STATE_PENDING\x10\x01\x12\x15\n" +
+	"\x11JOB_STATE_RUNNING\x10\x02\x12\x18\n" +
+	"\x14JOB_STATE_FINALIZING\x10\x03\x12\x17\n" +
+	"\x13JOB_STATE_COMPLETED\x10\x04\x12\x15\n" +
+	"\x11JOB_STATE_PARTIAL\x10\x05\x12\x14\n" +
+	"\x10JOB_STATE_FAILED\x10\x06\x12\x17\n" +
+	"\x13JOB_STATE_CANCELLED\x10\a*\xb8\x01\n" +
+	"\rFailureReason\x12\x1e\n" +
+	"\x1aFAILURE_REASON_UNSPECIFIED\x10\x00\x12\"\n" +
+	"\x1eFAILURE_REASON_CLIENT_REPORTED\x10\x01\x12\x1e\n" +
+	"\x1aFAILURE_REASON_STREAM_LOST\x10\x02\x12 \n" +
+	"\x1cFAILURE_REASON_STORAGE_ERROR\x10\x03\x12!\n" +
+	"\x1dFAILURE_REASON_INTERNAL_ERROR\x10\x042\xfc\x02\n" +
 	"\rBackupService\x12O\n" +
 	"\fStreamBackup\x12\x1c.dedupe_engine.BackupRequest\x1a\x1d.dedupe_engine.BackupResponse(\x010\x01\x12P\n" +
 	"\x0fInitiateRestore\x12\x1d.dedupe_engine.RestoreRequest\x1a\x1e.dedupe_engine.RestoreResponse\x12^\n" +
-	"\x11StreamRestoreData\x12!.dedupe_engine.RestoreDataRequest\x1a\".dedupe_engine.RestoreDataResponse(\x010\x01B7Z5github.com/radhakrishnan.venkat/dedupe-engine/pkg/apib\x06proto3"
+	"\x11StreamRestoreData\x12!.dedupe_engine.RestoreDataRequest\x1a\".dedupe_engine.RestoreDataResponse(\x010\x01\x12h\n" +
+	"\x13GetPreviousSnapshot\x12&.dedupe_engine.PreviousSnapshotRequest\x1a'.dedupe_engine.PreviousSnapshotResponse0\x012\xec\x03\n" +
+	"\x0eCatalogService\x12K\n" +
+	"\bListJobs\x12\x1e.dedupe_engine.ListJobsRequest\x1a\x1f.dedupe_engine.ListJobsResponse\x12>\n" +
+	"\x06GetJob\x12\x1c.dedupe_engine.GetJobRequest\x1a\x16.dedupe_engine.JobInfo\x12N\n" +
+	"\tListFiles\x12\x1f.dedupe_engine.ListFilesRequest\x1a .dedupe_engine.ListFilesResponse\x12T\n" +
+	"\vSearchFiles\x12!.dedupe_engine.SearchFilesRequest\x1a\".dedupe_engine.SearchFilesResponse\x12W\n" +
+	"\fListVersions\x12\".dedupe_engine.ListVersionsRequest\x1a#.dedupe_engine.ListVersionsResponse\x12N\n" +
+	"\tDeleteJob\x12\x1f.dedupe_engine.DeleteJobRequest\x1a .dedupe_engine.DeleteJobResponseB7Z5github.com/radhakrishnan.venkat/dedupe-engine/pkg/apib\x06proto3"
 
 var (
 	file_pkg_api_dedupe_engine_proto_rawDescOnce sync.Once
@@ -908,37 +3607,112 @@ func file_pkg_api_dedupe_engine_proto_rawDescGZIP() []byte {
 	return file_pkg_api_dedupe_engine_proto_rawDescData
 }
 
-var file_pkg_api_dedupe_engine_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_pkg_api_dedupe_engine_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_pkg_api_dedupe_engine_proto_msgTypes = make([]protoimpl.MessageInfo, 39)
 var file_pkg_api_dedupe_engine_proto_goTypes = []any{
-	(*BackupStart)(nil),         // 0: dedupe_engine.BackupStart
-	(*FileSegment)(nil),         // 1: dedupe_engine.FileSegment
-	(*BackupRequest)(nil),       // 2: dedupe_engine.BackupRequest
-	(*BackupEnd)(nil),           // 3: dedupe_engine.BackupEnd
-	(*BackupResponse)(nil),      // 4: dedupe_engine.BackupResponse
-	(*BackupStatus)(nil),        // 5: dedupe_engine.BackupStatus
-	(*BackupError)(nil),         // 6: dedupe_engine.BackupError
-	(*RestoreRequest)(nil),      // 7: dedupe_engine.RestoreRequest
-	(*RestoreResponse)(nil),     // 8: dedupe_engine.RestoreResponse
-	(*RestoreDataRequest)(nil),  // 9: dedupe_engine.RestoreDataRequest
-	(*RestoreDataResponse)(nil), // 10: dedupe_engine.RestoreDataResponse
+	(FileType)(0),                    // 0: dedupe_engine.FileType
+	(JobState)(0),                    // 1: dedupe_engine.JobState
+	(FailureReason)(0),               // 2: dedupe_engine.FailureReason
+	(*BackupStart)(nil),              // 3: dedupe_engine.BackupStart
+	(*FileSegment)(nil),              // 4: dedupe_engine.FileSegment
+	(*ExtendedAttribute)(nil),        // 5: dedupe_engine.ExtendedAttribute
+	(*FileEntry)(nil),                // 6: dedupe_engine.FileEntry
+	(*ChunkRef)(nil),                 // 7: dedupe_engine.ChunkRef
+	(*ChunkRefBatch)(nil),            // 8: dedupe_engine.ChunkRefBatch
+	(*ChunkData)(nil),                // 9: dedupe_engine.ChunkData
+	(*BackupRequest)(nil),            // 10: dedupe_engine.BackupRequest
+	(*UnchangedFile)(nil),            // 11: dedupe_engine.UnchangedFile
+	(*FileRange)(nil),                // 12: dedupe_engine.FileRange
+	(*BackupEnd)(nil),                // 13: dedupe_engine.BackupEnd
+	(*BackupResponse)(nil),           // 14: dedupe_engine.BackupResponse
+	(*ChunksStored)(nil),             // 15: dedupe_engine.ChunksStored
+	(*ResumePoint)(nil),              // 16: dedupe_engine.ResumePoint
+	(*MissingChunks)(nil),            // 17: dedupe_engine.MissingChunks
+	(*BackupStatus)(nil),             // 18: dedupe_engine.BackupStatus
+	(*ChunkingProfile)(nil),          // 19: dedupe_engine.ChunkingProfile
+	(*BackupError)(nil),              // 20: dedupe_engine.BackupError
+	(*PreviousSnapshotRequest)(nil),  // 21: dedupe_engine.PreviousSnapshotRequest
+	(*PreviousSnapshotResponse)(nil), // 22: dedupe_engine.PreviousSnapshotResponse
+	(*RestoreRequest)(nil),           // 23: dedupe_engine.RestoreRequest
+	(*RestoreResponse)(nil),          // 24: dedupe_engine.RestoreResponse
+	(*RestoreDataRequest)(nil),       // 25: dedupe_engine.RestoreDataRequest
+	(*RestoreDataResponse)(nil),      // 26: dedupe_engine.RestoreDataResponse
+	(*ListJobsRequest)(nil),          // 27: dedupe_engine.ListJobsRequest
+	(*ListJobsResponse)(nil),         // 28: dedupe_engine.ListJobsResponse
+	(*GetJobRequest)(nil),            // 29: dedupe_engine.GetJobRequest
+	(*JobInfo)(nil),                  // 30: dedupe_engine.JobInfo
+	(*JobStats)(nil),                 // 31: dedupe_engine.JobStats
+	(*ListFilesRequest)(nil),         // 32: dedupe_engine.ListFilesRequest
+	(*ListFilesResponse)(nil),        // 33: dedupe_engine.ListFilesResponse
+	(*DeleteJobRequest)(nil),         // 34: dedupe_engine.DeleteJobRequest
+	(*DeleteJobResponse)(nil),        // 35: dedupe_engine.DeleteJobResponse
+	(*SearchFilesRequest)(nil),       // 36: dedupe_engine.SearchFilesRequest
+	(*SearchFilesResponse)(nil),      // 37: dedupe_engine.SearchFilesResponse
+	(*FileMatch)(nil),                // 38: dedupe_engine.FileMatch
+	(*ListVersionsRequest)(nil),      // 39: dedupe_engine.ListVersionsRequest
+	(*ListVersionsResponse)(nil),     // 40: dedupe_engine.ListVersionsResponse
+	(*FileVersion)(nil),              // 41: dedupe_engine.FileVersion
 }
 var file_pkg_api_dedupe_engine_proto_depIdxs = []int32{
-	0,  // 0: dedupe_engine.BackupRequest.start_backup:type_name -> dedupe_engine.BackupStart
-	1,  // 1: dedupe_engine.BackupRequest.file_segment:type_name -> dedupe_engine.FileSegment
-	3,  // 2: dedupe_engine.BackupRequest.end_backup:type_name -> dedupe_engine.BackupEnd
-	5,  // 3: dedupe_engine.BackupResponse.status_update:type_name -> dedupe_engine.BackupStatus
-	6,  // 4: dedupe_engine.BackupResponse.error_message:type_name -> dedupe_engine.BackupError
-	2,  // 5: dedupe_engine.BackupService.StreamBackup:input_type -> dedupe_engine.BackupRequest
-	7,  // 6: dedupe_engine.BackupService.InitiateRestore:input_type -> dedupe_engine.RestoreRequest
-	9,  // 7: dedupe_engine.BackupService.StreamRestoreData:input_type -> dedupe_engine.RestoreDataRequest
-	4,  // 8: dedupe_engine.BackupService.StreamBackup:output_type -> dedupe_engine.BackupResponse
-	8,  // 9: dedupe_engine.BackupService.InitiateRestore:output_type -> dedupe_engine.RestoreResponse
-	10, // 10: dedupe_engine.BackupService.StreamRestoreData:output_type -> dedupe_engine.RestoreDataResponse
-	8,  // [8:11] is the sub-list for method output_type
-	5,  // [5:8] is the sub-list for method input_type
-	5,  // [5:5] is the sub-list for extension type_name
-	5,  // [5:5] is the sub-list for extension extendee
-	0,  // [0:5] is the sub-list for field type_name
+	0,  // 0: dedupe_engine.FileEntry.type:type_name -> dedupe_engine.FileType
+	5,  // 1: dedupe_engine.FileEntry.xattrs:type_name -> dedupe_engine.ExtendedAttribute
+	7,  // 2: dedupe_engine.ChunkRefBatch.chunks:type_name -> dedupe_engine.ChunkRef
+	3,  // 3: dedupe_engine.BackupRequest.start_backup:type_name -> dedupe_engine.BackupStart
+	4,  // 4: dedupe_engine.BackupRequest.file_segment:type_name -> dedupe_engine.FileSegment
+	13, // 5: dedupe_engine.BackupRequest.end_backup:type_name -> dedupe_engine.BackupEnd
+	6,  // 6: dedupe_engine.BackupRequest.file_entry:type_name -> dedupe_engine.FileEntry
+	8,  // 7: dedupe_engine.BackupRequest.chunk_refs:type_name -> dedupe_engine.ChunkRefBatch
+	9,  // 8: dedupe_engine.BackupRequest.chunk_data:type_name -> dedupe_engine.ChunkData
+	11, // 9: dedupe_engine.BackupRequest.unchanged_file:type_name -> dedupe_engine.UnchangedFile
+	12, // 10: dedupe_engine.BackupRequest.file_range:type_name -> dedupe_engine.FileRange
+	6,  // 11: dedupe_engine.UnchangedFile.entry:type_name -> dedupe_engine.FileEntry
+	6,  // 12: dedupe_engine.FileRange.entry:type_name -> dedupe_engine.FileEntry
+	1,  // 13: dedupe_engine.BackupEnd.state:type_name -> dedupe_engine.JobState
+	18, // 14: dedupe_engine.BackupResponse.status_update:type_name -> dedupe_engine.BackupStatus
+	20, // 15: dedupe_engine.BackupResponse.error_message:type_name -> dedupe_engine.BackupError
+	17, // 16: dedupe_engine.BackupResponse.missing_chunks:type_name -> dedupe_engine.MissingChunks
+	16, // 17: dedupe_engine.BackupResponse.resume_point:type_name -> dedupe_engine.ResumePoint
+	15, // 18: dedupe_engine.BackupResponse.chunks_stored:type_name -> dedupe_engine.ChunksStored
+	6,  // 19: dedupe_engine.ResumePoint.partial_entry:type_name -> dedupe_engine.FileEntry
+	19, // 20: dedupe_engine.BackupStatus.chunking_profile:type_name -> dedupe_engine.ChunkingProfile
+	1,  // 21: dedupe_engine.BackupStatus.state:type_name -> dedupe_engine.JobState
+	2,  // 22: dedupe_engine.BackupStatus.failure_reason:type_name -> dedupe_engine.FailureReason
+	6,  // 23: dedupe_engine.PreviousSnapshotResponse.files:type_name -> dedupe_engine.FileEntry
+	6,  // 24: dedupe_engine.RestoreDataResponse.file_entry:type_name -> dedupe_engine.FileEntry
+	1,  // 25: dedupe_engine.ListJobsRequest.states:type_name -> dedupe_engine.JobState
+	30, // 26: dedupe_engine.ListJobsResponse.jobs:type_name -> dedupe_engine.JobInfo
+	1,  // 27: dedupe_engine.JobInfo.state:type_name -> dedupe_engine.JobState
+	2,  // 28: dedupe_engine.JobInfo.failure_reason:type_name -> dedupe_engine.FailureReason
+	31, // 29: dedupe_engine.JobInfo.stats:type_name -> dedupe_engine.JobStats
+	6,  // 30: dedupe_engine.ListFilesResponse.files:type_name -> dedupe_engine.FileEntry
+	38, // 31: dedupe_engine.SearchFilesResponse.matches:type_name -> dedupe_engine.FileMatch
+	41, // 32: dedupe_engine.ListVersionsResponse.versions:type_name -> dedupe_engine.FileVersion
+	6,  // 33: dedupe_engine.FileVersion.entry:type_name -> dedupe_engine.FileEntry
+	10, // 34: dedupe_engine.BackupService.StreamBackup:input_type -> dedupe_engine.BackupRequest
+	23, // 35: dedupe_engine.BackupService.InitiateRestore:input_type -> dedupe_engine.RestoreRequest
+	25, // 36: dedupe_engine.BackupService.StreamRestoreData:input_type -> dedupe_engine.RestoreDataRequest
+	21, // 37: dedupe_engine.BackupService.GetPreviousSnapshot:input_type -> dedupe_engine.PreviousSnapshotRequest
+	27, // 38: dedupe_engine.CatalogService.ListJobs:input_type -> dedupe_engine.ListJobsRequest
+	29, // 39: dedupe_engine.CatalogService.GetJob:input_type -> dedupe_engine.GetJobRequest
+	32, // 40: dedupe_engine.CatalogService.ListFiles:input_type -> dedupe_engine.ListFilesRequest
+	36, // 41: dedupe_engine.CatalogService.SearchFiles:input_type -> dedupe_engine.SearchFilesRequest
+	39, // 42: dedupe_engine.CatalogService.ListVersions:input_type -> dedupe_engine.ListVersionsRequest
+	34, // 43: dedupe_engine.CatalogService.DeleteJob:input_type -> dedupe_engine.DeleteJobRequest
+	14, // 44: dedupe_engine.BackupService.StreamBackup:output_type -> dedupe_engine.BackupResponse
+	24, // 45: dedupe_engine.BackupService.InitiateRestore:output_type -> dedupe_engine.RestoreResponse
+	26, // 46: dedupe_engine.BackupService.StreamRestoreData:output_type -> dedupe_engine.RestoreDataResponse
+	22, // 47: dedupe_engine.BackupService.GetPreviousSnapshot:output_type -> dedupe_engine.PreviousSnapshotResponse
+	28, // 48: dedupe_engine.CatalogService.ListJobs:output_type -> dedupe_engine.ListJobsResponse
+	30, // 49: dedupe_engine.CatalogService.GetJob:output_type -> dedupe_engine.JobInfo
+	33, // 50: dedupe_engine.CatalogService.ListFiles:output_type -> dedupe_engine.ListFilesResponse
+	37, // 51: dedupe_engine.CatalogService.SearchFiles:output_type -> dedupe_engine.SearchFilesResponse
+	40, // 52: dedupe_engine.CatalogService.ListVersions:output_type -> dedupe_engine.ListVersionsResponse
+	35, // 53: dedupe_engine.CatalogService.DeleteJob:output_type -> dedupe_engine.DeleteJobResponse
+	44, // [44:54] is the sub-list for method output_type
+	34, // [34:44] is the sub-list for method input_type
+	34, // [34:34] is the sub-list for extension type_name
+	34, // [34:34] is the sub-list for extension extendee
+	0,  // [0:34] is the sub-list for field type_name
 }
 
 func init() { file_pkg_api_dedupe_engine_proto_init() }
@@ -946,27 +3720,36 @@ func file_pkg_api_dedupe_engine_proto_init() {
 	if File_pkg_api_dedupe_engine_proto != nil {
 		return
 	}
-	file_pkg_api_dedupe_engine_proto_msgTypes[2].OneofWrappers = []any{
+	file_pkg_api_dedupe_engine_proto_msgTypes[7].OneofWrappers = []any{
 		(*BackupRequest_StartBackup)(nil),
 		(*BackupRequest_FileSegment)(nil),
 		(*BackupRequest_EndBackup)(nil),
+		(*BackupRequest_FileEntry)(nil),
+		(*BackupRequest_ChunkRefs)(nil),
+		(*BackupRequest_ChunkData)(nil),
+		(*BackupRequest_UnchangedFile)(nil),
+		(*BackupRequest_FileRange)(nil),
 	}
-	file_pkg_api_dedupe_engine_proto_msgTypes[4].OneofWrappers = []any{
+	file_pkg_api_dedupe_engine_proto_msgTypes[11].OneofWrappers = []any{
 		(*BackupResponse_StatusUpdate)(nil),
 		(*BackupResponse_ErrorMessage)(nil),
+		(*BackupResponse_MissingChunks)(nil),
+		(*BackupResponse_ResumePoint)(nil),
+		(*BackupResponse_ChunksStored)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_api_dedupe_engine_proto_rawDesc), len(file_pkg_api_dedupe_engine_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   11,
+			NumEnums:      3,
+			NumMessages:   39,
 			NumExtensions: 0,
-			NumServices:   1,
+			NumServices:   2,
 		},
 		GoTypes:           file_pkg_api_dedupe_engine_proto_goTypes,
 		DependencyIndexes: file_pkg_api_dedupe_engine_proto_depIdxs,
+		EnumInfos:         file_pkg_api_dedupe_engine_proto_enumTypes,
 		MessageInfos:      file_pkg_api_dedupe_engine_proto_msgTypes,
 	}.Build()
 	File_pkg_api_dedupe_engine_proto = out.File
@@ -19,9 +19,10 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	BackupService_StreamBackup_FullMethodName      = "/dedupe_engine.BackupService/StreamBackup"
-	BackupService_InitiateRestore_FullMethodName   = "/dedupe_engine.BackupService/InitiateRestore"
-	BackupService_StreamRestoreData_FullMethodName = "/dedupe_engine.BackupService/StreamRestoreData"
+	BackupService_StreamBackup_FullMethodName        = "/dedupe_engine.BackupService/StreamBackup"
+	BackupService_InitiateRestore_FullMethodName     = "/dedupe_engine.BackupService/InitiateRestore"
+	BackupService_StreamRestoreData_FullMethodName   = "/dedupe_engine.BackupService/StreamRestoreData"
+	BackupService_GetPreviousSnapshot_FullMethodName = "/dedupe_engine.BackupService/GetPreviousSnapshot"
 )
 
 // BackupServiceClient is the client API for BackupService service.
@@ -37,6 +38,9 @@ type BackupServiceClient interface {
 	InitiateRestore(ctx context.Context, in *RestoreRequest, opts ...grpc.CallOption) (*RestoreResponse, error)
 	// RPC for streaming restored data back to the client/stream handler.
 	StreamRestoreData(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[RestoreDataRequest, RestoreDataResponse], error)
+	// RPC for listing the regular files of the last successful backup of a
+	// client and source, which an incremental backup compares against.
+	GetPreviousSnapshot(ctx context.Context, in *PreviousSnapshotRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PreviousSnapshotResponse], error)
 }
 
 type backupServiceClient struct {
@@ -83,6 +87,25 @@ func (c *backupServiceClient) StreamRestoreData(ctx context.Context, opts ...grp
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type BackupService_StreamRestoreDataClient = grpc.BidiStreamingClient[RestoreDataRequest, RestoreDataResponse]
 
+func (c *backupServiceClient) GetPreviousSnapshot(ctx context.Context, in *PreviousSnapshotRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PreviousSnapshotResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &BackupService_ServiceDesc.Streams[2], BackupService_GetPreviousSnapshot_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PreviousSnapshotRequest, PreviousSnapshotResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type BackupService_GetPreviousSnapshotClient = grpc.ServerStreamingClient[PreviousSnapshotResponse]
+
 // BackupServiceServer is the server API for BackupService service.
 // All implementations must embed UnimplementedBackupServiceServer
 // for forward compatibility.
@@ -96,6 +119,9 @@ type BackupServiceServer interface {
 	InitiateRestore(context.Context, *RestoreRequest) (*RestoreResponse, error)
 	// RPC for streaming restored data back to the client/stream handler.
 	StreamRestoreData(grpc.BidiStreamingServer[RestoreDataRequest, RestoreDataResponse]) error
+	// RPC for listing the regular files of the last successful backup of a
+	// client and source, which an incremental backup compares against.
+	GetPreviousSnapshot(*PreviousSnapshotRequest, grpc.ServerStreamingServer[PreviousSnapshotResponse]) error
 	mustEmbedUnimplementedBackupServiceServer()
 }
 
@@ -115,6 +141,9 @@ func (UnimplementedBackupServiceServer) InitiateRestore(context.Context, *Restor
 func (UnimplementedBackupServiceServer) StreamRestoreData(grpc.BidiStreamingServer[RestoreDataRequest, RestoreDataResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method StreamRestoreData not implemented")
 }
+func (UnimplementedBackupServiceServer) GetPreviousSnapshot(*PreviousSnapshotRequest, grpc.ServerStreamingServer[PreviousSnapshotResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method GetPreviousSnapshot not implemented")
+}
 func (UnimplementedBackupServiceServer) mustEmbedUnimplementedBackupServiceServer() {}
 func (UnimplementedBackupServiceServer) testEmbeddedByValue()                       {}
 
@@ -168,6 +197,17 @@ func _BackupService_StreamRestoreData_Handler(srv interface{}, stream grpc.Serve
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type BackupService_StreamRestoreDataServer = grpc.BidiStreamingServer[RestoreDataRequest, RestoreDataResponse]
 
+func _BackupService_GetPreviousSnapshot_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PreviousSnapshotRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BackupServiceServer).GetPreviousSnapshot(m, &grpc.GenericServerStream[PreviousSnapshotRequest, PreviousSnapshotResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type BackupService_GetPreviousSnapshotServer = grpc.ServerStreamingServer[PreviousSnapshotResponse]
+
 // BackupService_ServiceDesc is the grpc.ServiceDesc for BackupService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -193,6 +233,321 @@ var BackupService_ServiceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "GetPreviousSnapshot",
+			Handler:       _BackupService_GetPreviousSnapshot_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pkg/api/dedupe_engine.proto",
+}
+
+const (
+	CatalogService_ListJobs_FullMethodName     = "/dedupe_engine.CatalogService/ListJobs"
+	CatalogService_GetJob_FullMethodName       = "/dedupe_engine.CatalogService/GetJob"
+	CatalogService_ListFiles_FullMethodName    = "/dedupe_engine.CatalogService/ListFiles"
+	CatalogService_SearchFiles_FullMethodName  = "/dedupe_engine.CatalogService/SearchFiles"
+	CatalogService_ListVersions_FullMethodName = "/dedupe_engine.CatalogService/ListVersions"
+	CatalogService_DeleteJob_FullMethodName    = "/dedupe_engine.CatalogService/DeleteJob"
+)
+
+// CatalogServiceClient is the client API for CatalogService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Lists, describes and deletes the backup jobs the ingest nodes recorded
+type CatalogServiceClient interface {
+	// RPC for listing backup jobs, most recently started first.
+	ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error)
+	// RPC for describing one backup job with its statistics.
+	GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*JobInfo, error)
+	// RPC for listing the entries of a backup job, ordered by path.
+	ListFiles(ctx context.Context, in *ListFilesRequest, opts ...grpc.CallOption) (*ListFilesResponse, error)
+	// RPC for searching the paths backed up by the restorable jobs of a client.
+	SearchFiles(ctx context.Context, in *SearchFilesRequest, opts ...grpc.CallOption) (*SearchFilesResponse, error)
+	// RPC for listing the distinct versions of a path across the restorable
+	// jobs of a client, newest first.
+	ListVersions(ctx context.Context, in *ListVersionsRequest, opts ...grpc.CallOption) (*ListVersionsResponse, error)
+	// RPC for deleting an ended backup job with its manifest.
+	DeleteJob(ctx context.Context, in *DeleteJobRequest, opts ...grpc.CallOption) (*DeleteJobResponse, error)
+}
+
+type catalogServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCatalogServiceClient(cc grpc.ClientConnInterface) CatalogServiceClient {
+	return &catalogServiceClient{cc}
+}
+
+func (c *catalogServiceClient) ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListJobsResponse)
+	err := c.cc.Invoke(ctx, CatalogService_ListJobs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catalogServiceClient) GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*JobInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(JobInfo)
+	err := c.cc.Invoke(ctx, CatalogService_GetJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catalogServiceClient) ListFiles(ctx context.Context, in *ListFilesRequest, opts ...grpc.CallOption) (*ListFilesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListFilesResponse)
+	err := c.cc.Invoke(ctx, CatalogService_ListFiles_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catalogServiceClient) SearchFiles(ctx context.Context, in *SearchFilesRequest, opts ...grpc.CallOption) (*SearchFilesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchFilesResponse)
+	err := c.cc.Invoke(ctx, CatalogService_SearchFiles_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catalogServiceClient) ListVersions(ctx context.Context, in *ListVersionsRequest, opts ...grpc.CallOption) (*ListVersionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListVersionsResponse)
+	err := c.cc.Invoke(ctx, CatalogService_ListVersions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catalogServiceClient) DeleteJob(ctx context.Context, in *DeleteJobRequest, opts ...grpc.CallOption) (*DeleteJobResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteJobResponse)
+	err := c.cc.Invoke(ctx, CatalogService_DeleteJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CatalogServiceServer is the server API for CatalogService service.
+// All implementations must embed UnimplementedCatalogServiceServer
+// for forward compatibility.
+//
+// Lists, describes and deletes the backup jobs the ingest nodes recorded
+type CatalogServiceServer interface {
+	// RPC for listing backup jobs, most recently started first.
+	ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error)
+	// RPC for describing one backup job with its statistics.
+	GetJob(context.Context, *GetJobRequest) (*JobInfo, error)
+	// RPC for listing the entries of a backup job, ordered by path.
+	ListFiles(context.Context, *ListFilesRequest) (*ListFilesResponse, error)
+	// RPC for searching the paths backed up by the restorable jobs of a client.
+	SearchFiles(context.Context, *SearchFilesRequest) (*SearchFilesResponse, error)
+	// RPC for listing the distinct versions of a path across the restorable
+	// jobs of a client, newest first.
+	ListVersions(context.Context, *ListVersionsRequest) (*ListVersionsResponse, error)
+	// RPC for deleting an ended backup job with its manifest.
+	DeleteJob(context.Context, *DeleteJobRequest) (*DeleteJobResponse, error)
+	mustEmbedUnimplementedCatalogServiceServer()
+}
+
+// UnimplementedCatalogServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCatalogServiceServer struct{}
+
+func (UnimplementedCatalogServiceServer) ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListJobs not implemented")
+}
+func (UnimplementedCatalogServiceServer) GetJob(context.Context, *GetJobRequest) (*JobInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJob not implemented")
+}
+func (UnimplementedCatalogServiceServer) ListFiles(context.Context, *ListFilesRequest) (*ListFilesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFiles not implemented")
+}
+func (UnimplementedCatalogServiceServer) SearchFiles(context.Context, *SearchFilesRequest) (*SearchFilesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchFiles not implemented")
+}
+func (UnimplementedCatalogServiceServer) ListVersions(context.Context, *ListVersionsRequest) (*ListVersionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListVersions not implemented")
+}
+func (UnimplementedCatalogServiceServer) DeleteJob(context.Context, *DeleteJobRequest) (*DeleteJobResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteJob not implemented")
+}
+func (UnimplementedCatalogServiceServer) mustEmbedUnimplementedCatalogServiceServer() {}
+func (UnimplementedCatalogServiceServer) testEmbeddedByValue()                        {}
+
+// UnsafeCatalogServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CatalogServiceServer will
+// result in compilation errors.
+type UnsafeCatalogServiceServer interface {
+	mustEmbedUnimplementedCatalogServiceServer()
+}
+
+func RegisterCatalogServiceServer(s grpc.ServiceRegistrar, srv CatalogServiceServer) {
+	// If the following call pancis, it indicates UnimplementedCatalogServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&CatalogService_ServiceDesc, srv)
+}
+
+func _CatalogService_ListJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListJobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatalogServiceServer).ListJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CatalogService_ListJobs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatalogServiceServer).ListJobs(ctx, req.(*ListJobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatalogService_GetJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatalogServiceServer).GetJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CatalogService_GetJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatalogServiceServer).GetJob(ctx, req.(*GetJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatalogService_ListFiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFilesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatalogServiceServer).ListFiles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CatalogService_ListFiles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatalogServiceServer).ListFiles(ctx, req.(*ListFilesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatalogService_SearchFiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchFilesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatalogServiceServer).SearchFiles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CatalogService_SearchFiles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatalogServiceServer).SearchFiles(ctx, req.(*SearchFilesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatalogService_ListVersions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListVersionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatalogServiceServer).ListVersions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CatalogService_ListVersions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatalogServiceServer).ListVersions(ctx, req.(*ListVersionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatalogService_DeleteJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatalogServiceServer).DeleteJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CatalogService_DeleteJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatalogServiceServer).DeleteJob(ctx, req.(*DeleteJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// CatalogService_ServiceDesc is the grpc.ServiceDesc for CatalogService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CatalogService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dedupe_engine.CatalogService",
+	HandlerType: (*CatalogServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListJobs",
+			Handler:    _CatalogService_ListJobs_Handler,
+		},
+		{
+			MethodName: "GetJob",
+			Handler:    _CatalogService_GetJob_Handler,
+		},
+		{
+			MethodName: "ListFiles",
+			Handler:    _CatalogService_ListFiles_Handler,
+		},
+		{
+			MethodName: "SearchFiles",
+			Handler:    _CatalogService_SearchFiles_Handler,
+		},
+		{
+			MethodName: "ListVersions",
+			Handler:    _CatalogService_ListVersions_Handler,
+		},
+		{
+			MethodName: "DeleteJob",
+			Handler:    _CatalogService_DeleteJob_Handler,
+		},
 	},
+	Streams:  []grpc.StreamDesc{},
 	Metadata: "pkg/api/dedupe_engine.proto",
 }
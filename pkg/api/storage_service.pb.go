@@ -261,6 +261,574 @@ func (x *GetChunkResponse) GetErrorMessage() string {
 	return ""
 }
 
+type StoreChunkAck struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Fingerprint   string                 `protobuf:"bytes,1,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	Success       bool                   `protobuf:"varint,2,opt,name=success,proto3" json:"success,omitempty"`
+	ErrorMessage  string                 `protobuf:"bytes,3,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StoreChunkAck) Reset() {
+	*x = StoreChunkAck{}
+	mi := &file_pkg_api_storage_service_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StoreChunkAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StoreChunkAck) ProtoMessage() {}
+
+func (x *StoreChunkAck) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_storage_service_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StoreChunkAck.ProtoReflect.Descriptor instead.
+func (*StoreChunkAck) Descriptor() ([]byte, []int) {
+	return file_pkg_api_storage_service_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *StoreChunkAck) GetFingerprint() string {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return ""
+}
+
+func (x *StoreChunkAck) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *StoreChunkAck) GetErrorMessage() string {
+	if x != nil {
+		return x.ErrorMessage
+	}
+	return ""
+}
+
+type StoreChunksResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Acks          []*StoreChunkAck       `protobuf:"bytes,1,rep,name=acks,proto3" json:"acks,omitempty"` // One acknowledgement per received chunk, in order
+	StorageNodeId string                 `protobuf:"bytes,2,opt,name=storage_node_id,json=storageNodeId,proto3" json:"storage_node_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StoreChunksResponse) Reset() {
+	*x = StoreChunksResponse{}
+	mi := &file_pkg_api_storage_service_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StoreChunksResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StoreChunksResponse) ProtoMessage() {}
+
+func (x *StoreChunksResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_storage_service_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StoreChunksResponse.ProtoReflect.Descriptor instead.
+func (*StoreChunksResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_api_storage_service_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *StoreChunksResponse) GetAcks() []*StoreChunkAck {
+	if x != nil {
+		return x.Acks
+	}
+	return nil
+}
+
+func (x *StoreChunksResponse) GetStorageNodeId() string {
+	if x != nil {
+		return x.StorageNodeId
+	}
+	return ""
+}
+
+type HasChunksRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Fingerprints  []string               `protobuf:"bytes,1,rep,name=fingerprints,proto3" json:"fingerprints,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HasChunksRequest) Reset() {
+	*x = HasChunksRequest{}
+	mi := &file_pkg_api_storage_service_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HasChunksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HasChunksRequest) ProtoMessage() {}
+
+func (x *HasChunksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_storage_service_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HasChunksRequest.ProtoReflect.Descriptor instead.
+func (*HasChunksRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_api_storage_service_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *HasChunksRequest) GetFingerprints() []string {
+	if x != nil {
+		return x.Fingerprints
+	}
+	return nil
+}
+
+type HasChunksResponse struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	PresentFingerprints []string               `protobuf:"bytes,1,rep,name=present_fingerprints,json=presentFingerprints,proto3" json:"present_fingerprints,omitempty"` // Subset of the requested fingerprints that are stored
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *HasChunksResponse) Reset() {
+	*x = HasChunksResponse{}
+	mi := &file_pkg_api_storage_service_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HasChunksResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HasChunksResponse) ProtoMessage() {}
+
+func (x *HasChunksResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_storage_service_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HasChunksResponse.ProtoReflect.Descriptor instead.
+func (*HasChunksResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_api_storage_service_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *HasChunksResponse) GetPresentFingerprints() []string {
+	if x != nil {
+		return x.PresentFingerprints
+	}
+	return nil
+}
+
+type DeleteChunkRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Fingerprint   string                 `protobuf:"bytes,1,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteChunkRequest) Reset() {
+	*x = DeleteChunkRequest{}
+	mi := &file_pkg_api_storage_service_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteChunkRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteChunkRequest) ProtoMessage() {}
+
+func (x *DeleteChunkRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_storage_service_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteChunkRequest.ProtoReflect.Descriptor instead.
+func (*DeleteChunkRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_api_storage_service_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DeleteChunkRequest) GetFingerprint() string {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return ""
+}
+
+type DeleteChunkResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	ErrorMessage  string                 `protobuf:"bytes,2,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteChunkResponse) Reset() {
+	*x = DeleteChunkResponse{}
+	mi := &file_pkg_api_storage_service_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteChunkResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteChunkResponse) ProtoMessage() {}
+
+func (x *DeleteChunkResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_storage_service_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteChunkResponse.ProtoReflect.Descriptor instead.
+func (*DeleteChunkResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_api_storage_service_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DeleteChunkResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *DeleteChunkResponse) GetErrorMessage() string {
+	if x != nil {
+		return x.ErrorMessage
+	}
+	return ""
+}
+
+type NodeStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NodeStatusRequest) Reset() {
+	*x = NodeStatusRequest{}
+	mi := &file_pkg_api_storage_service_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NodeStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NodeStatusRequest) ProtoMessage() {}
+
+func (x *NodeStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_storage_service_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NodeStatusRequest.ProtoReflect.Descriptor instead.
+func (*NodeStatusRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_api_storage_service_proto_rawDescGZIP(), []int{10}
+}
+
+type NodeStatusResponse struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	StorageNodeId    string                 `protobuf:"bytes,1,opt,name=storage_node_id,json=storageNodeId,proto3" json:"storage_node_id,omitempty"`
+	UsedBytes        int64                  `protobuf:"varint,2,opt,name=used_bytes,json=usedBytes,proto3" json:"used_bytes,omitempty"`
+	FreeBytes        int64                  `protobuf:"varint,3,opt,name=free_bytes,json=freeBytes,proto3" json:"free_bytes,omitempty"`             // Remaining capacity; 0 when capacity_bytes is unset
+	CapacityBytes    int64                  `protobuf:"varint,4,opt,name=capacity_bytes,json=capacityBytes,proto3" json:"capacity_bytes,omitempty"` // Configured capacity; 0 means unlimited
+	ChunkCount       int64                  `protobuf:"varint,5,opt,name=chunk_count,json=chunkCount,proto3" json:"chunk_count,omitempty"`
+	UsageUpdatedTime int64                  `protobuf:"varint,6,opt,name=usage_updated_time,json=usageUpdatedTime,proto3" json:"usage_updated_time,omitempty"` // Unix time of the last full usage scan
+	Draining         bool                   `protobuf:"varint,7,opt,name=draining,proto3" json:"draining,omitempty"`
+	Full             bool                   `protobuf:"varint,8,opt,name=full,proto3" json:"full,omitempty"`                                              // Free space is below the configured reserve
+	AcceptingWrites  bool                   `protobuf:"varint,9,opt,name=accepting_writes,json=acceptingWrites,proto3" json:"accepting_writes,omitempty"` // False when draining or full
+	ReadErrorRate    float64                `protobuf:"fixed64,10,opt,name=read_error_rate,json=readErrorRate,proto3" json:"read_error_rate,omitempty"`   // Fraction of failed reads over the recent window
+	WriteErrorRate   float64                `protobuf:"fixed64,11,opt,name=write_error_rate,json=writeErrorRate,proto3" json:"write_error_rate,omitempty"`
+	Reads            int64                  `protobuf:"varint,12,opt,name=reads,proto3" json:"reads,omitempty"`   // Reads in the recent window
+	Writes           int64                  `protobuf:"varint,13,opt,name=writes,proto3" json:"writes,omitempty"` // Writes in the recent window
+	UptimeSeconds    int64                  `protobuf:"varint,14,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *NodeStatusResponse) Reset() {
+	*x = NodeStatusResponse{}
+	mi := &file_pkg_api_storage_service_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NodeStatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NodeStatusResponse) ProtoMessage() {}
+
+func (x *NodeStatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_storage_service_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NodeStatusResponse.ProtoReflect.Descriptor instead.
+func (*NodeStatusResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_api_storage_service_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *NodeStatusResponse) GetStorageNodeId() string {
+	if x != nil {
+		return x.StorageNodeId
+	}
+	return ""
+}
+
+func (x *NodeStatusResponse) GetUsedBytes() int64 {
+	if x != nil {
+		return x.UsedBytes
+	}
+	return 0
+}
+
+func (x *NodeStatusResponse) GetFreeBytes() int64 {
+	if x != nil {
+		return x.FreeBytes
+	}
+	return 0
+}
+
+func (x *NodeStatusResponse) GetCapacityBytes() int64 {
+	if x != nil {
+		return x.CapacityBytes
+	}
+	return 0
+}
+
+func (x *NodeStatusResponse) GetChunkCount() int64 {
+	if x != nil {
+		return x.ChunkCount
+	}
+	return 0
+}
+
+func (x *NodeStatusResponse) GetUsageUpdatedTime() int64 {
+	if x != nil {
+		return x.UsageUpdatedTime
+	}
+	return 0
+}
+
+func (x *NodeStatusResponse) GetDraining() bool {
+	if x != nil {
+		return x.Draining
+	}
+	return false
+}
+
+func (x *NodeStatusResponse) GetFull() bool {
+	if x != nil {
+		return x.Full
+	}
+	return false
+}
+
+func (x *NodeStatusResponse) GetAcceptingWrites() bool {
+	if x != nil {
+		return x.AcceptingWrites
+	}
+	return false
+}
+
+func (x *NodeStatusResponse) GetReadErrorRate() float64 {
+	if x != nil {
+		return x.ReadErrorRate
+	}
+	return 0
+}
+
+func (x *NodeStatusResponse) GetWriteErrorRate() float64 {
+	if x != nil {
+		return x.WriteErrorRate
+	}
+	return 0
+}
+
+func (x *NodeStatusResponse) GetReads() int64 {
+	if x != nil {
+		return x.Reads
+	}
+	return 0
+}
+
+func (x *NodeStatusResponse) GetWrites() int64 {
+	if x != nil {
+		return x.Writes
+	}
+	return 0
+}
+
+func (x *NodeStatusResponse) GetUptimeSeconds() int64 {
+	if x != nil {
+		return x.UptimeSeconds
+	}
+	return 0
+}
+
+type SetDrainModeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Draining      bool                   `protobuf:"varint,1,opt,name=draining,proto3" json:"draining,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetDrainModeRequest) Reset() {
+	*x = SetDrainModeRequest{}
+	mi := &file_pkg_api_storage_service_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetDrainModeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetDrainModeRequest) ProtoMessage() {}
+
+func (x *SetDrainModeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_storage_service_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetDrainModeRequest.ProtoReflect.Descriptor instead.
+func (*SetDrainModeRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_api_storage_service_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *SetDrainModeRequest) GetDraining() bool {
+	if x != nil {
+		return x.Draining
+	}
+	return false
+}
+
+type SetDrainModeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Draining      bool                   `protobuf:"varint,1,opt,name=draining,proto3" json:"draining,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetDrainModeResponse) Reset() {
+	*x = SetDrainModeResponse{}
+	mi := &file_pkg_api_storage_service_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetDrainModeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetDrainModeResponse) ProtoMessage() {}
+
+func (x *SetDrainModeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_storage_service_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetDrainModeResponse.ProtoReflect.Descriptor instead.
+func (*SetDrainModeResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_api_storage_service_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *SetDrainModeResponse) GetDraining() bool {
+	if x != nil {
+		return x.Draining
+	}
+	return false
+}
+
 var File_pkg_api_storage_service_proto protoreflect.FileDescriptor
 
 const file_pkg_api_storage_service_proto_rawDesc = "" +
@@ -283,11 +851,57 @@ const file_pkg_api_storage_service_proto_rawDesc = "" +
 	"chunk_data\x18\x01 \x01(\fR\tchunkData\x12\x12\n" +
 	"\x04size\x18\x02 \x01(\x03R\x04size\x12\x14\n" +
 	"\x05found\x18\x03 \x01(\bR\x05found\x12#\n" +
-	"\rerror_message\x18\x04 \x01(\tR\ferrorMessage2\xb8\x01\n" +
+	"\rerror_message\x18\x04 \x01(\tR\ferrorMessage\"p\n" +
+	"\rStoreChunkAck\x12 \n" +
+	"\vfingerprint\x18\x01 \x01(\tR\vfingerprint\x12\x18\n" +
+	"\asuccess\x18\x02 \x01(\bR\asuccess\x12#\n" +
+	"\rerror_message\x18\x03 \x01(\tR\ferrorMessage\"q\n" +
+	"\x13StoreChunksResponse\x122\n" +
+	"\x04acks\x18\x01 \x03(\v2\x1e.storage_service.StoreChunkAckR\x04acks\x12&\n" +
+	"\x0fstorage_node_id\x18\x02 \x01(\tR\rstorageNodeId\"6\n" +
+	"\x10HasChunksRequest\x12\"\n" +
+	"\ffingerprints\x18\x01 \x03(\tR\ffingerprints\"F\n" +
+	"\x11HasChunksResponse\x121\n" +
+	"\x14present_fingerprints\x18\x01 \x03(\tR\x13presentFingerprints\"6\n" +
+	"\x12DeleteChunkRequest\x12 \n" +
+	"\vfingerprint\x18\x01 \x01(\tR\vfingerprint\"T\n" +
+	"\x13DeleteChunkResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12#\n" +
+	"\rerror_message\x18\x02 \x01(\tR\ferrorMessage\"\x13\n" +
+	"\x11NodeStatusRequest\"\xf2\x03\n" +
+	"\x12NodeStatusResponse\x12&\n" +
+	"\x0fstorage_node_id\x18\x01 \x01(\tR\rstorageNodeId\x12\x1d\n" +
+	"\n" +
+	"used_bytes\x18\x02 \x01(\x03R\tusedBytes\x12\x1d\n" +
+	"\n" +
+	"free_bytes\x18\x03 \x01(\x03R\tfreeBytes\x12%\n" +
+	"\x0ecapacity_bytes\x18\x04 \x01(\x03R\rcapacityBytes\x12\x1f\n" +
+	"\vchunk_count\x18\x05 \x01(\x03R\n" +
+	"chunkCount\x12,\n" +
+	"\x12usage_updated_time\x18\x06 \x01(\x03R\x10usageUpdatedTime\x12\x1a\n" +
+	"\bdraining\x18\a \x01(\bR\bdraining\x12\x12\n" +
+	"\x04full\x18\b \x01(\bR\x04full\x12)\n" +
+	"\x10accepting_writes\x18\t \x01(\bR\x0facceptingWrites\x12&\n" +
+	"\x0fread_error_rate\x18\n" +
+	" \x01(\x01R\rreadErrorRate\x12(\n" +
+	"\x10write_error_rate\x18\v \x01(\x01R\x0ewriteErrorRate\x12\x14\n" +
+	"\x05reads\x18\f \x01(\x03R\x05reads\x12\x16\n" +
+	"\x06writes\x18\r \x01(\x03R\x06writes\x12%\n" +
+	"\x0euptime_seconds\x18\x0e \x01(\x03R\ruptimeSeconds\"1\n" +
+	"\x13SetDrainModeRequest\x12\x1a\n" +
+	"\bdraining\x18\x01 \x01(\bR\bdraining\"2\n" +
+	"\x14SetDrainModeResponse\x12\x1a\n" +
+	"\bdraining\x18\x01 \x01(\bR\bdraining2\xf5\x04\n" +
 	"\x0eStorageService\x12U\n" +
 	"\n" +
 	"StoreChunk\x12\".storage_service.StoreChunkRequest\x1a#.storage_service.StoreChunkResponse\x12O\n" +
-	"\bGetChunk\x12 .storage_service.GetChunkRequest\x1a!.storage_service.GetChunkResponseB7Z5github.com/radhakrishnan.venkat/dedupe-engine/pkg/apib\x06proto3"
+	"\bGetChunk\x12 .storage_service.GetChunkRequest\x1a!.storage_service.GetChunkResponse\x12Y\n" +
+	"\vStoreChunks\x12\".storage_service.StoreChunkRequest\x1a$.storage_service.StoreChunksResponse(\x01\x12R\n" +
+	"\tHasChunks\x12!.storage_service.HasChunksRequest\x1a\".storage_service.HasChunksResponse\x12X\n" +
+	"\vDeleteChunk\x12#.storage_service.DeleteChunkRequest\x1a$.storage_service.DeleteChunkResponse\x12U\n" +
+	"\n" +
+	"NodeStatus\x12\".storage_service.NodeStatusRequest\x1a#.storage_service.NodeStatusResponse\x12[\n" +
+	"\fSetDrainMode\x12$.storage_service.SetDrainModeRequest\x1a%.storage_service.SetDrainModeResponseB7Z5github.com/radhakrishnan.venkat/dedupe-engine/pkg/apib\x06proto3"
 
 var (
 	file_pkg_api_storage_service_proto_rawDescOnce sync.Once
@@ -301,23 +915,44 @@ func file_pkg_api_storage_service_proto_rawDescGZIP() []byte {
 	return file_pkg_api_storage_service_proto_rawDescData
 }
 
-var file_pkg_api_storage_service_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_pkg_api_storage_service_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
 var file_pkg_api_storage_service_proto_goTypes = []any{
-	(*StoreChunkRequest)(nil),  // 0: storage_service.StoreChunkRequest
-	(*StoreChunkResponse)(nil), // 1: storage_service.StoreChunkResponse
-	(*GetChunkRequest)(nil),    // 2: storage_service.GetChunkRequest
-	(*GetChunkResponse)(nil),   // 3: storage_service.GetChunkResponse
+	(*StoreChunkRequest)(nil),    // 0: storage_service.StoreChunkRequest
+	(*StoreChunkResponse)(nil),   // 1: storage_service.StoreChunkResponse
+	(*GetChunkRequest)(nil),      // 2: storage_service.GetChunkRequest
+	(*GetChunkResponse)(nil),     // 3: storage_service.GetChunkResponse
+	(*StoreChunkAck)(nil),        // 4: storage_service.StoreChunkAck
+	(*StoreChunksResponse)(nil),  // 5: storage_service.StoreChunksResponse
+	(*HasChunksRequest)(nil),     // 6: storage_service.HasChunksRequest
+	(*HasChunksResponse)(nil),    // 7: storage_service.HasChunksResponse
+	(*DeleteChunkRequest)(nil),   // 8: storage_service.DeleteChunkRequest
+	(*DeleteChunkResponse)(nil),  // 9: storage_service.DeleteChunkResponse
+	(*NodeStatusRequest)(nil),    // 10: storage_service.NodeStatusRequest
+	(*NodeStatusResponse)(nil),   // 11: storage_service.NodeStatusResponse
+	(*SetDrainModeRequest)(nil),  // 12: storage_service.SetDrainModeRequest
+	(*SetDrainModeResponse)(nil), // 13: storage_service.SetDrainModeResponse
 }
 var file_pkg_api_storage_service_proto_depIdxs = []int32{
-	0, // 0: storage_service.StorageService.StoreChunk:input_type -> storage_service.StoreChunkRequest
-	2, // 1: storage_service.StorageService.GetChunk:input_type -> storage_service.GetChunkRequest
-	1, // 2: storage_service.StorageService.StoreChunk:output_type -> storage_service.StoreChunkResponse
-	3, // 3: storage_service.StorageService.GetChunk:output_type -> storage_service.GetChunkResponse
-	2, // [2:4] is the sub-list for method output_type
-	0, // [0:2] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	4,  // 0: storage_service.StoreChunksResponse.acks:type_name -> storage_service.StoreChunkAck
+	0,  // 1: storage_service.StorageService.StoreChunk:input_type -> storage_service.StoreChunkRequest
+	2,  // 2: storage_service.StorageService.GetChunk:input_type -> storage_service.GetChunkRequest
+	0,  // 3: storage_service.StorageService.StoreChunks:input_type -> storage_service.StoreChunkRequest
+	6,  // 4: storage_service.StorageService.HasChunks:input_type -> storage_service.HasChunksRequest
+	8,  // 5: storage_service.StorageService.DeleteChunk:input_type -> storage_service.DeleteChunkRequest
+	10, // 6: storage_service.StorageService.NodeStatus:input_type -> storage_service.NodeStatusRequest
+	12, // 7: storage_service.StorageService.SetDrainMode:input_type -> storage_service.SetDrainModeRequest
+	1,  // 8: storage_service.StorageService.StoreChunk:output_type -> storage_service.StoreChunkResponse
+	3,  // 9: storage_service.StorageService.GetChunk:output_type -> storage_service.GetChunkResponse
+	5,  // 10: storage_service.StorageService.StoreChunks:output_type -> storage_service.StoreChunksResponse
+	7,  // 11: storage_service.StorageService.HasChunks:output_type -> storage_service.HasChunksResponse
+	9,  // 12: storage_service.StorageService.DeleteChunk:output_type -> storage_service.DeleteChunkResponse
+	11, // 13: storage_service.StorageService.NodeStatus:output_type -> storage_service.NodeStatusResponse
+	13, // 14: storage_service.StorageService.SetDrainMode:output_type -> storage_service.SetDrainModeResponse
+	8,  // [8:15] is the sub-list for method output_type
+	1,  // [1:8] is the sub-list for method input_type
+	1,  // [1:1] is the sub-list for extension type_name
+	1,  // [1:1] is the sub-list for extension extendee
+	0,  // [0:1] is the sub-list for field type_name
 }
 
 func init() { file_pkg_api_storage_service_proto_init() }
@@ -331,7 +966,7 @@ func file_pkg_api_storage_service_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_api_storage_service_proto_rawDesc), len(file_pkg_api_storage_service_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   4,
+			NumMessages:   14,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,8 +19,13 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	StorageService_StoreChunk_FullMethodName = "/storage_service.StorageService/StoreChunk"
-	StorageService_GetChunk_FullMethodName   = "/storage_service.StorageService/GetChunk"
+	StorageService_StoreChunk_FullMethodName   = "/storage_service.StorageService/StoreChunk"
+	StorageService_GetChunk_FullMethodName     = "/storage_service.StorageService/GetChunk"
+	StorageService_StoreChunks_FullMethodName  = "/storage_service.StorageService/StoreChunks"
+	StorageService_HasChunks_FullMethodName    = "/storage_service.StorageService/HasChunks"
+	StorageService_DeleteChunk_FullMethodName  = "/storage_service.StorageService/DeleteChunk"
+	StorageService_NodeStatus_FullMethodName   = "/storage_service.StorageService/NodeStatus"
+	StorageService_SetDrainMode_FullMethodName = "/storage_service.StorageService/SetDrainMode"
 )
 
 // StorageServiceClient is the client API for StorageService service.
@@ -31,6 +36,16 @@ type StorageServiceClient interface {
 	StoreChunk(ctx context.Context, in *StoreChunkRequest, opts ...grpc.CallOption) (*StoreChunkResponse, error)
 	// Retrieve a chunk from MinIO
 	GetChunk(ctx context.Context, in *GetChunkRequest, opts ...grpc.CallOption) (*GetChunkResponse, error)
+	// Store a batch of chunks over one stream, acknowledging each chunk
+	StoreChunks(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[StoreChunkRequest, StoreChunksResponse], error)
+	// Report which of the given fingerprints are already stored
+	HasChunks(ctx context.Context, in *HasChunksRequest, opts ...grpc.CallOption) (*HasChunksResponse, error)
+	// Remove a chunk from this node, e.g. after it has been rebalanced elsewhere
+	DeleteChunk(ctx context.Context, in *DeleteChunkRequest, opts ...grpc.CallOption) (*DeleteChunkResponse, error)
+	// Report capacity, usage, error rates and whether the node accepts writes
+	NodeStatus(ctx context.Context, in *NodeStatusRequest, opts ...grpc.CallOption) (*NodeStatusResponse, error)
+	// Enter or leave drain mode: a draining node rejects writes but keeps serving reads
+	SetDrainMode(ctx context.Context, in *SetDrainModeRequest, opts ...grpc.CallOption) (*SetDrainModeResponse, error)
 }
 
 type storageServiceClient struct {
@@ -61,6 +76,59 @@ func (c *storageServiceClient) GetChunk(ctx context.Context, in *GetChunkRequest
 	return out, nil
 }
 
+func (c *storageServiceClient) StoreChunks(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[StoreChunkRequest, StoreChunksResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &StorageService_ServiceDesc.Streams[0], StorageService_StoreChunks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StoreChunkRequest, StoreChunksResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StorageService_StoreChunksClient = grpc.ClientStreamingClient[StoreChunkRequest, StoreChunksResponse]
+
+func (c *storageServiceClient) HasChunks(ctx context.Context, in *HasChunksRequest, opts ...grpc.CallOption) (*HasChunksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HasChunksResponse)
+	err := c.cc.Invoke(ctx, StorageService_HasChunks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) DeleteChunk(ctx context.Context, in *DeleteChunkRequest, opts ...grpc.CallOption) (*DeleteChunkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteChunkResponse)
+	err := c.cc.Invoke(ctx, StorageService_DeleteChunk_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) NodeStatus(ctx context.Context, in *NodeStatusRequest, opts ...grpc.CallOption) (*NodeStatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NodeStatusResponse)
+	err := c.cc.Invoke(ctx, StorageService_NodeStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) SetDrainMode(ctx context.Context, in *SetDrainModeRequest, opts ...grpc.CallOption) (*SetDrainModeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetDrainModeResponse)
+	err := c.cc.Invoke(ctx, StorageService_SetDrainMode_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // StorageServiceServer is the server API for StorageService service.
 // All implementations must embed UnimplementedStorageServiceServer
 // for forward compatibility.
@@ -69,6 +137,16 @@ type StorageServiceServer interface {
 	StoreChunk(context.Context, *StoreChunkRequest) (*StoreChunkResponse, error)
 	// Retrieve a chunk from MinIO
 	GetChunk(context.Context, *GetChunkRequest) (*GetChunkResponse, error)
+	// Store a batch of chunks over one stream, acknowledging each chunk
+	StoreChunks(grpc.ClientStreamingServer[StoreChunkRequest, StoreChunksResponse]) error
+	// Report which of the given fingerprints are already stored
+	HasChunks(context.Context, *HasChunksRequest) (*HasChunksResponse, error)
+	// Remove a chunk from this node, e.g. after it has been rebalanced elsewhere
+	DeleteChunk(context.Context, *DeleteChunkRequest) (*DeleteChunkResponse, error)
+	// Report capacity, usage, error rates and whether the node accepts writes
+	NodeStatus(context.Context, *NodeStatusRequest) (*NodeStatusResponse, error)
+	// Enter or leave drain mode: a draining node rejects writes but keeps serving reads
+	SetDrainMode(context.Context, *SetDrainModeRequest) (*SetDrainModeResponse, error)
 	mustEmbedUnimplementedStorageServiceServer()
 }
 
@@ -85,6 +163,21 @@ func (UnimplementedStorageServiceServer) StoreChunk(context.Context, *StoreChunk
 func (UnimplementedStorageServiceServer) GetChunk(context.Context, *GetChunkRequest) (*GetChunkResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetChunk not implemented")
 }
+func (UnimplementedStorageServiceServer) StoreChunks(grpc.ClientStreamingServer[StoreChunkRequest, StoreChunksResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method StoreChunks not implemented")
+}
+func (UnimplementedStorageServiceServer) HasChunks(context.Context, *HasChunksRequest) (*HasChunksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HasChunks not implemented")
+}
+func (UnimplementedStorageServiceServer) DeleteChunk(context.Context, *DeleteChunkRequest) (*DeleteChunkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteChunk not implemented")
+}
+func (UnimplementedStorageServiceServer) NodeStatus(context.Context, *NodeStatusRequest) (*NodeStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NodeStatus not implemented")
+}
+func (UnimplementedStorageServiceServer) SetDrainMode(context.Context, *SetDrainModeRequest) (*SetDrainModeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetDrainMode not implemented")
+}
 func (UnimplementedStorageServiceServer) mustEmbedUnimplementedStorageServiceServer() {}
 func (UnimplementedStorageServiceServer) testEmbeddedByValue()                        {}
 
@@ -142,6 +235,85 @@ func _StorageService_GetChunk_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StorageService_StoreChunks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(StorageServiceServer).StoreChunks(&grpc.GenericServerStream[StoreChunkRequest, StoreChunksResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StorageService_StoreChunksServer = grpc.ClientStreamingServer[StoreChunkRequest, StoreChunksResponse]
+
+func _StorageService_HasChunks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HasChunksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).HasChunks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_HasChunks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).HasChunks(ctx, req.(*HasChunksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_DeleteChunk_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteChunkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).DeleteChunk(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_DeleteChunk_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).DeleteChunk(ctx, req.(*DeleteChunkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_NodeStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NodeStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).NodeStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_NodeStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).NodeStatus(ctx, req.(*NodeStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_SetDrainMode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetDrainModeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).SetDrainMode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_SetDrainMode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).SetDrainMode(ctx, req.(*SetDrainModeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // StorageService_ServiceDesc is the grpc.ServiceDesc for StorageService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -157,7 +329,29 @@ var StorageService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetChunk",
 			Handler:    _StorageService_GetChunk_Handler,
 		},
+		{
+			MethodName: "HasChunks",
+			Handler:    _StorageService_HasChunks_Handler,
+		},
+		{
+			MethodName: "DeleteChunk",
+			Handler:    _StorageService_DeleteChunk_Handler,
+		},
+		{
+			MethodName: "NodeStatus",
+			Handler:    _StorageService_NodeStatus_Handler,
+		},
+		{
+			MethodName: "SetDrainMode",
+			Handler:    _StorageService_SetDrainMode_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StoreChunks",
+			Handler:       _StorageService_StoreChunks_Handler,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "pkg/api/storage_service.proto",
 }